@@ -3,6 +3,7 @@ package main
 import (
 	"context"
 	"database/sql"
+	"flag"
 	"log"
 	"net/http"
 	"os"
@@ -11,20 +12,24 @@ import (
 	"time"
 
 	"github.com/SanthoshCheemala/FLARE/backend/internal/config"
+	"github.com/SanthoshCheemala/FLARE/backend/internal/logging"
 	"github.com/SanthoshCheemala/FLARE/backend/internal/handlers"
 	"github.com/SanthoshCheemala/FLARE/backend/internal/jobs"
-	"github.com/SanthoshCheemala/FLARE/backend/internal/middleware"
 	"github.com/SanthoshCheemala/FLARE/backend/internal/repository"
-	"github.com/go-chi/chi/v5"
-	chimiddleware "github.com/go-chi/chi/v5/middleware"
 	_ "github.com/mattn/go-sqlite3"
 )
 
 func main() {
-	cfg, err := config.Load()
+	var opts config.Options
+	opts.RegisterFlags(flag.CommandLine)
+	flag.Parse()
+
+	cfg, err := config.LoadFrom(opts)
 	if err != nil {
 		log.Fatalf("Failed to load config: %v", err)
 	}
+	logging.Install(os.Stderr)
+	logging.SetLevel(cfg.Log.Level)
 
 	// Ensure data directory exists for SQLite
 	if cfg.DatabaseDriver() == "sqlite3" {
@@ -47,52 +52,32 @@ func main() {
 	log.Println("Connected to database successfully")
 
 	repo := repository.New(db)
+	repo.SetQueryTimeout(cfg.Database.QueryTimeout)
 	if err := repo.InitSchema(); err != nil {
 		log.Fatalf("Failed to initialize schema: %v", err)
 	}
+	if cfg.DatabaseDriver() == "sqlite3" {
+		drift, err := repo.CheckSchema(context.Background())
+		if err != nil {
+			log.Printf("Warning: failed to check database schema: %v", err)
+		}
+		for _, d := range drift {
+			log.Printf("Warning: schema drift: %s", d)
+		}
+	}
 	jobManager := jobs.NewManager(cfg.PSI.MaxScreenings)
 	handler := handlers.NewHandler(repo, jobManager, cfg, nil)
 
-	r := chi.NewRouter()
-
-	r.Use(chimiddleware.RequestID)
-	r.Use(chimiddleware.RealIP)
-	r.Use(middleware.Logger)
-	r.Use(chimiddleware.Recoverer)
-	r.Use(middleware.CORS([]string{"http://localhost:3000", "*"}))
-
-	// WebSocket endpoint (must be outside Timeout middleware)
-	r.Get("/ws/logs", handler.StreamLogs)
-
-	// API endpoints with timeout
-	r.Group(func(r chi.Router) {
-		r.Use(chimiddleware.Timeout(60 * time.Second))
-
-		r.Get("/health", func(w http.ResponseWriter, r *http.Request) {
-			w.WriteHeader(http.StatusOK)
-			w.Write([]byte("OK"))
-		})
-
-		// All endpoints are now public (no auth required)
-		r.Post("/lists/customers/upload", handler.UploadCustomerList)
-		r.Post("/lists/sanctions/upload", handler.UploadSanctionList)
-		r.Get("/lists/customers", handler.GetCustomerLists)
-		r.Get("/lists/customers/{id}/headers", handler.GetCustomerListHeaders)
-		r.Delete("/lists/customers/{id}", handler.DeleteCustomerList)
-		r.Get("/lists/sanctions", handler.GetSanctionLists)
-		r.Delete("/lists/sanctions/{id}", handler.DeleteSanctionList)
-
-		r.Post("/screenings", handler.StartScreening)
-		r.Get("/screenings/{jobId}/status", handler.ScreeningStatus)
-		r.Get("/screenings/{jobId}/events", handler.ScreeningEvents)
-		r.Get("/screenings/{jobId}/results", handler.GetScreeningResults)
-		
-		r.Patch("/results/{resultId}/status", handler.UpdateResultStatus)
-		
-		r.Get("/dashboard/stats", handler.GetStats)
-		r.Get("/performance/metrics", handler.GetPerformanceMetrics)
+	evictCtx, stopEviction := context.WithCancel(context.Background())
+	defer stopEviction()
+	go jobManager.RunEviction(evictCtx, cfg.PSI.JobRetention, time.Minute)
+
+	go config.WatchReload(evictCtx, opts, cfg, func(next *config.Config) {
+		logging.SetLevel(next.Log.Level)
 	})
 
+	r := handlers.NewRouter(handler)
+
 	addr := cfg.Server.Host + ":" + cfg.Server.Port
 	srv := &http.Server{
 		Addr:         addr,
@@ -100,11 +85,18 @@ func main() {
 		ReadTimeout:  cfg.Server.ReadTimeout,
 		WriteTimeout: cfg.Server.WriteTimeout,
 		IdleTimeout:  120 * time.Second,
+		Protocols:    cfg.Server.Protocols(),
 	}
 
 	go func() {
-		log.Printf("Starting server on %s", addr)
-		if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+		log.Printf("Starting server on %s (tls=%t h2c=%t compression=%t)", addr, cfg.Server.TLS(), cfg.Server.H2C, cfg.Server.Compression)
+		var err error
+		if cfg.Server.TLS() {
+			err = srv.ListenAndServeTLS(cfg.Server.TLSCertFile, cfg.Server.TLSKeyFile)
+		} else {
+			err = srv.ListenAndServe()
+		}
+		if err != nil && err != http.ErrServerClosed {
 			log.Fatalf("Server failed: %v", err)
 		}
 	}()
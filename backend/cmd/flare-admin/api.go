@@ -0,0 +1,150 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/SanthoshCheemala/FLARE/backend/internal/apierror"
+)
+
+// adminClient talks to the PSI server's list and admin APIs
+type adminClient struct {
+	serverURL  string
+	adminToken string
+	http       *http.Client
+}
+
+func newAdminClient(serverURL, adminToken string) *adminClient {
+	return &adminClient{
+		serverURL:  serverURL,
+		adminToken: adminToken,
+		http:       &http.Client{Timeout: 5 * time.Minute},
+	}
+}
+
+// call sends a request and decodes a JSON response into out (if non-nil)
+func (c *adminClient) call(method, path string, body io.Reader, contentType string, out interface{}) error {
+	req, err := http.NewRequest(method, c.serverURL+path, body)
+	if err != nil {
+		return err
+	}
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	if c.adminToken != "" {
+		req.Header.Set("Authorization", "Bearer "+c.adminToken)
+	}
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return fmt.Errorf("request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return apierror.FromResponse(resp)
+	}
+	if out == nil {
+		return nil
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("failed to decode response: %w", err)
+	}
+	return nil
+}
+
+func (c *adminClient) getJSON(path string, out interface{}) error {
+	return c.call("GET", path, nil, "", out)
+}
+
+func (c *adminClient) postJSON(path string, in, out interface{}) error {
+	return c.sendJSON("POST", path, in, out)
+}
+
+func (c *adminClient) putJSON(path string, in, out interface{}) error {
+	return c.sendJSON("PUT", path, in, out)
+}
+
+func (c *adminClient) sendJSON(method, path string, in, out interface{}) error {
+	var body io.Reader
+	if in != nil {
+		data, err := json.Marshal(in)
+		if err != nil {
+			return err
+		}
+		body = bytes.NewReader(data)
+	}
+	return c.call(method, path, body, "application/json", out)
+}
+
+// download writes the body of a GET to w. Downloads are not bound by the
+// client timeout, since archives can take longer than any API call.
+func (c *adminClient) download(path string, w io.Writer) error {
+	req, err := http.NewRequest("GET", c.serverURL+path, nil)
+	if err != nil {
+		return err
+	}
+	if c.adminToken != "" {
+		req.Header.Set("Authorization", "Bearer "+c.adminToken)
+	}
+
+	resp, err := (&http.Client{Transport: c.http.Transport}).Do(req)
+	if err != nil {
+		return fmt.Errorf("request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return apierror.FromResponse(resp)
+	}
+	_, err = io.Copy(w, resp.Body)
+	return err
+}
+
+func (c *adminClient) delete(path string) error {
+	return c.call("DELETE", path, nil, "", nil)
+}
+
+// uploadList posts a sanction CSV. A non-zero listID uploads a new version
+// of that list.
+func (c *adminClient) uploadList(path, name, source, category, description string, listID int64, out interface{}) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	var buf bytes.Buffer
+	mw := multipart.NewWriter(&buf)
+	part, err := mw.CreateFormFile("file", filepath.Base(path))
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(part, f); err != nil {
+		return err
+	}
+	fields := map[string]string{"name": name, "source": source, "category": category, "description": description}
+	if listID > 0 {
+		fields["list_id"] = fmt.Sprintf("%d", listID)
+	}
+	for k, v := range fields {
+		if v == "" {
+			continue
+		}
+		if err := mw.WriteField(k, v); err != nil {
+			return err
+		}
+	}
+	if err := mw.Close(); err != nil {
+		return err
+	}
+
+	return c.call("POST", "/lists/sanctions/upload", &buf, mw.FormDataContentType(), out)
+}
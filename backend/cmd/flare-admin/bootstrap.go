@@ -0,0 +1,57 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/SanthoshCheemala/FLARE/backend/internal/bootstrap"
+	"github.com/SanthoshCheemala/FLARE/backend/internal/repository"
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// runBootstrap loads a fixture directory straight into a service database.
+// It does not talk to the server API.
+func runBootstrap(_ *adminClient, args []string) error {
+	fs := flag.NewFlagSet("bootstrap", flag.ExitOnError)
+	dir := fs.String("dir", "./fixtures/default", "fixture directory containing "+bootstrap.ManifestFile)
+	side := fs.String("side", "server", "which database to load: server or client")
+	driver := fs.String("driver", "sqlite3", "database driver")
+	dsn := fs.String("db", "", "database DSN (default ./data/flare_server.db or ./data/flare.db by side)")
+	fs.Parse(args)
+
+	if *dsn == "" {
+		*dsn = "./data/flare_server.db"
+		if bootstrap.Side(*side) == bootstrap.ClientSide {
+			*dsn = "./data/flare.db"
+		}
+	}
+	if *driver == "sqlite3" {
+		if err := os.MkdirAll(filepath.Dir(*dsn), 0755); err != nil {
+			return err
+		}
+	}
+
+	db, err := sql.Open(*driver, *dsn)
+	if err != nil {
+		return fmt.Errorf("open database: %w", err)
+	}
+	defer db.Close()
+
+	repo := repository.New(db)
+	if err := repo.InitSchema(); err != nil {
+		return fmt.Errorf("initialize schema: %w", err)
+	}
+
+	res, err := bootstrap.Run(context.Background(), repo, *dir, bootstrap.Side(*side))
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Users: %d created, %d updated\n", res.UsersCreated, res.UsersUpdated)
+	fmt.Printf("Lists: %d loaded (%d records), %d unchanged\n", res.ListsLoaded, res.Records, res.ListsSkipped)
+	return nil
+}
@@ -0,0 +1,764 @@
+// Command flare-admin manages a FLARE PSI server: sanction lists, global
+// state rebuilds, sessions, stats and API keys. It also bootstraps service
+// databases from fixture directories.
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/SanthoshCheemala/FLARE/backend/internal/treearchive"
+)
+
+type command struct {
+	usage string
+	run   func(c *adminClient, args []string) error
+}
+
+var commands = map[string]command{
+	"lists":          {"lists [-q query] [-source src] [-category cat] [-limit n] [-offset n]", runLists},
+	"upload":         {"upload -name NAME [-source SRC] [-category CAT] [-description TEXT] [-list-id ID] FILE.csv", runUpload},
+	"delete-list":    {"delete-list ID", runDeleteList},
+	"rebuild":        {"rebuild", runRebuild},
+	"rebuild-status": {"rebuild-status", runRebuildStatus},
+	"trees-archive":  {"trees-archive [-out FILE] [-verify FILE]", runTreesArchive},
+	"rehash":         {"rehash", runRehash},
+	"rehash-status":  {"rehash-status", runRehashStatus},
+	"sessions":       {"sessions", runSessions},
+	"expire-session": {"expire-session SESSION_ID", runExpireSession},
+	"events":         {"events [-session ID] [-event TYPE] [-since DATE] [-limit n]", runEvents},
+	"usage":          {"usage [-from YYYY-MM] [-to YYYY-MM] [-csv] [-noise]", runUsage},
+	"stats":          {"stats", runStats},
+	"keys":           {"keys", runKeys},
+	"create-key":     {"create-key NAME", runCreateKey},
+	"revoke-key":     {"revoke-key ID", runRevokeKey},
+	"quota":          {"quota ID", runQuota},
+	"set-quota":      {"set-quota [-sessions-per-day n] [-ciphertexts n] [-concurrent n] [-min-resolve n] ID", runSetQuota},
+	"packs":          {"packs", runPacks},
+	"create-pack":    {"create-pack -name NAME [-description TEXT] -lists ID,ID,... PACK_ID", runCreatePack},
+	"update-pack":    {"update-pack [-name NAME] [-description TEXT] [-lists ID,ID,...] PACK_ID", runUpdatePack},
+	"delete-pack":    {"delete-pack PACK_ID", runDeletePack},
+	"bootstrap":      {"bootstrap [-dir DIR] [-side server|client] [-db DSN]", runBootstrap},
+}
+
+func main() {
+	serverURL := flag.String("server", envOr("FLARE_SERVER_URL", "http://localhost:8081"), "PSI server URL")
+	adminToken := flag.String("token", os.Getenv("FLARE_ADMIN_TOKEN"), "admin API token (default $FLARE_ADMIN_TOKEN)")
+	flag.Usage = usage
+	flag.Parse()
+
+	if flag.NArg() == 0 {
+		usage()
+		os.Exit(2)
+	}
+
+	cmd, ok := commands[flag.Arg(0)]
+	if !ok {
+		fmt.Fprintf(os.Stderr, "unknown command %q\n\n", flag.Arg(0))
+		usage()
+		os.Exit(2)
+	}
+
+	client := newAdminClient(*serverURL, *adminToken)
+	if err := cmd.run(client, flag.Args()[1:]); err != nil {
+		fmt.Fprintf(os.Stderr, "flare-admin %s: %v\n", flag.Arg(0), err)
+		os.Exit(1)
+	}
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "Usage: flare-admin [-server URL] [-token TOKEN] <command> [args]")
+	fmt.Fprintln(os.Stderr, "\nCommands:")
+	names := make([]string, 0, len(commands))
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(os.Stderr, "  %s\n", commands[name].usage)
+	}
+	fmt.Fprintln(os.Stderr, "\nFlags:")
+	flag.PrintDefaults()
+}
+
+func envOr(key, def string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+	return def
+}
+
+func runLists(c *adminClient, args []string) error {
+	fs := flag.NewFlagSet("lists", flag.ExitOnError)
+	query := fs.String("q", "", "filter by name or description")
+	source := fs.String("source", "", "filter by source")
+	category := fs.String("category", "", "filter by category (SANCTIONS, PEP, ADVERSE_MEDIA, INTERNAL)")
+	limit := fs.Int("limit", 50, "page size")
+	offset := fs.Int("offset", 0, "page offset")
+	fs.Parse(args)
+
+	path := fmt.Sprintf("/lists/sanctions?limit=%d&offset=%d", *limit, *offset)
+	if *query != "" {
+		path += "&q=" + url.QueryEscape(*query)
+	}
+	if *source != "" {
+		path += "&source=" + url.QueryEscape(*source)
+	}
+	if *category != "" {
+		path += "&category=" + url.QueryEscape(*category)
+	}
+
+	var page struct {
+		Lists []struct {
+			ID          int64     `json:"id"`
+			Name        string    `json:"name"`
+			Source      string    `json:"source"`
+			Category    string    `json:"category"`
+			RecordCount int       `json:"recordCount"`
+			Version     int       `json:"version"`
+			UpdatedAt   time.Time `json:"updatedAt"`
+		} `json:"lists"`
+		Total int `json:"total"`
+	}
+	if err := c.getJSON(path, &page); err != nil {
+		return err
+	}
+
+	tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "ID\tNAME\tSOURCE\tCATEGORY\tRECORDS\tVERSION\tUPDATED")
+	for _, l := range page.Lists {
+		fmt.Fprintf(tw, "%d\t%s\t%s\t%s\t%d\t%d\t%s\n", l.ID, l.Name, l.Source, l.Category, l.RecordCount, l.Version, l.UpdatedAt.Format(time.RFC3339))
+	}
+	tw.Flush()
+	fmt.Printf("%d of %d lists\n", len(page.Lists), page.Total)
+	return nil
+}
+
+func runUpload(c *adminClient, args []string) error {
+	fs := flag.NewFlagSet("upload", flag.ExitOnError)
+	name := fs.String("name", "", "list name")
+	source := fs.String("source", "", "list source, e.g. OFAC")
+	category := fs.String("category", "", "list category: SANCTIONS (default), PEP, ADVERSE_MEDIA or INTERNAL")
+	description := fs.String("description", "", "list description")
+	listID := fs.Int64("list-id", 0, "upload a new version of this existing list")
+	fs.Parse(args)
+
+	if fs.NArg() != 1 {
+		return fmt.Errorf("expected exactly one CSV file")
+	}
+
+	var resp struct {
+		ID      int64 `json:"id"`
+		Version int   `json:"version"`
+	}
+	if err := c.uploadList(fs.Arg(0), *name, *source, *category, *description, *listID, &resp); err != nil {
+		return err
+	}
+	fmt.Printf("Uploaded list %d (version %d)\n", resp.ID, resp.Version)
+	return nil
+}
+
+func runDeleteList(c *adminClient, args []string) error {
+	id, err := singleIDArg(args)
+	if err != nil {
+		return err
+	}
+	if err := c.delete(fmt.Sprintf("/lists/sanctions/%d", id)); err != nil {
+		return err
+	}
+	fmt.Printf("Deleted list %d\n", id)
+	return nil
+}
+
+func runRebuild(c *adminClient, args []string) error {
+	if err := c.postJSON("/admin/rebuild", nil, nil); err != nil {
+		return err
+	}
+	fmt.Println("Global PSI state rebuild started; follow it with rebuild-status")
+	return nil
+}
+
+func runRebuildStatus(c *adminClient, args []string) error {
+	var status struct {
+		State        string     `json:"state"`
+		ActiveSlot   string     `json:"activeSlot"`
+		BuildSlot    string     `json:"buildSlot"`
+		Queued       int        `json:"queued"`
+		Records      int        `json:"records"`
+		BatchesDone  int        `json:"batchesDone"`
+		BatchesTotal int        `json:"batchesTotal"`
+		StartedAt    *time.Time `json:"startedAt"`
+		FinishedAt   *time.Time `json:"finishedAt"`
+		Error        string     `json:"error"`
+	}
+	if err := c.getJSON("/admin/rebuild/status", &status); err != nil {
+		return err
+	}
+
+	fmt.Printf("State:    %s\n", status.State)
+	fmt.Printf("Serving:  slot %s\n", status.ActiveSlot)
+	if status.StartedAt == nil {
+		return nil
+	}
+	fmt.Printf("Building: slot %s, %d records, %d/%d trees\n", status.BuildSlot, status.Records, status.BatchesDone, status.BatchesTotal)
+	if status.FinishedAt != nil {
+		fmt.Printf("Took:     %s\n", status.FinishedAt.Sub(*status.StartedAt).Round(time.Second))
+	} else {
+		fmt.Printf("Elapsed:  %s\n", time.Since(*status.StartedAt).Round(time.Second))
+	}
+	if status.Queued > 0 {
+		fmt.Printf("Queued:   %d\n", status.Queued)
+	}
+	if status.Error != "" {
+		fmt.Printf("Error:    %s\n", status.Error)
+	}
+	return nil
+}
+
+// runTreesArchive downloads the live global trees as an archive and checks
+// it, or with -verify only checks an archive already on disk
+func runTreesArchive(c *adminClient, args []string) error {
+	fs := flag.NewFlagSet("trees-archive", flag.ExitOnError)
+	out := fs.String("out", "flare-trees.tar.gz", "archive to write")
+	verify := fs.String("verify", "", "check this archive's checksums instead of downloading")
+	fs.Parse(args)
+
+	path := *verify
+	if path == "" {
+		path = *out
+		f, err := os.Create(path)
+		if err != nil {
+			return err
+		}
+		err = c.download("/admin/trees/archive", f)
+		if cerr := f.Close(); err == nil {
+			err = cerr
+		}
+		if err != nil {
+			os.Remove(path)
+			return err
+		}
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	entries, err := treearchive.Verify(f)
+	if err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+
+	tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "ENTRY	SIZE	SHA256")
+	for _, e := range entries {
+		fmt.Fprintf(tw, "%s\t%d\t%s\n", e.Name, e.Size, e.SHA256)
+	}
+	tw.Flush()
+	fmt.Printf("%s: %d entries, checksums match\n", path, len(entries))
+	return nil
+}
+
+func runRehash(c *adminClient, args []string) error {
+	if err := c.postJSON("/admin/rehash", nil, nil); err != nil {
+		return err
+	}
+	fmt.Println("Record rehash started; follow it with rehash-status")
+	return nil
+}
+
+func runRehashStatus(c *adminClient, args []string) error {
+	var status struct {
+		State          string     `json:"state"`
+		Version        int        `json:"version"`
+		CustomersDone  int        `json:"customersDone"`
+		CustomersTotal int        `json:"customersTotal"`
+		SanctionsDone  int        `json:"sanctionsDone"`
+		SanctionsTotal int        `json:"sanctionsTotal"`
+		StartedAt      *time.Time `json:"startedAt"`
+		FinishedAt     *time.Time `json:"finishedAt"`
+		Error          string     `json:"error"`
+	}
+	if err := c.getJSON("/admin/rehash/status", &status); err != nil {
+		return err
+	}
+
+	fmt.Printf("State:     %s\n", status.State)
+	fmt.Printf("Version:   %d\n", status.Version)
+	if status.StartedAt == nil {
+		return nil
+	}
+	fmt.Printf("Sanctions: %d/%d\n", status.SanctionsDone, status.SanctionsTotal)
+	fmt.Printf("Customers: %d/%d\n", status.CustomersDone, status.CustomersTotal)
+	if status.FinishedAt != nil {
+		fmt.Printf("Took:      %s\n", status.FinishedAt.Sub(*status.StartedAt).Round(time.Second))
+	} else {
+		fmt.Printf("Elapsed:   %s\n", time.Since(*status.StartedAt).Round(time.Second))
+	}
+	if status.Error != "" {
+		fmt.Printf("Error:     %s\n", status.Error)
+	}
+	return nil
+}
+
+func runSessions(c *adminClient, args []string) error {
+	var resp struct {
+		Sessions []struct {
+			ID         string    `json:"id"`
+			ListIDs    []string  `json:"listIds"`
+			Batched    bool      `json:"batched"`
+			MatchCount int       `json:"matchCount"`
+			CreatedAt  time.Time `json:"createdAt"`
+		} `json:"sessions"`
+	}
+	if err := c.getJSON("/admin/sessions", &resp); err != nil {
+		return err
+	}
+
+	tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "ID\tLISTS\tBATCHED\tMATCHES\tAGE")
+	for _, s := range resp.Sessions {
+		fmt.Fprintf(tw, "%s\t%v\t%t\t%d\t%s\n", s.ID, s.ListIDs, s.Batched, s.MatchCount, time.Since(s.CreatedAt).Round(time.Second))
+	}
+	return tw.Flush()
+}
+
+func runExpireSession(c *adminClient, args []string) error {
+	if len(args) != 1 {
+		return fmt.Errorf("expected a session ID")
+	}
+	if err := c.delete("/admin/sessions/" + url.PathEscape(args[0])); err != nil {
+		return err
+	}
+	fmt.Printf("Expired session %s\n", args[0])
+	return nil
+}
+
+func runEvents(c *adminClient, args []string) error {
+	fs := flag.NewFlagSet("events", flag.ExitOnError)
+	session := fs.String("session", "", "only events of this session")
+	event := fs.String("event", "", "only this event type (init, intersect, resolve, close, expire, error)")
+	since := fs.String("since", "", "only events at or after this time (RFC 3339 or YYYY-MM-DD)")
+	limit := fs.Int("limit", 100, "maximum number of events")
+	fs.Parse(args)
+
+	params := url.Values{"limit": {strconv.Itoa(*limit)}}
+	if *session != "" {
+		params.Set("session_id", *session)
+	}
+	if *event != "" {
+		params.Set("event", *event)
+	}
+	if *since != "" {
+		params.Set("since", *since)
+	}
+
+	var page struct {
+		Events []struct {
+			SessionID       string    `json:"sessionId"`
+			Event           string    `json:"event"`
+			Client          string    `json:"client"`
+			Batch           int       `json:"batch"`
+			CiphertextCount int       `json:"ciphertextCount"`
+			MatchCount      int       `json:"matchCount"`
+			DurationMs      int64     `json:"durationMs"`
+			Detail          string    `json:"detail"`
+			CreatedAt       time.Time `json:"createdAt"`
+		} `json:"events"`
+		Total int `json:"total"`
+	}
+	if err := c.getJSON("/admin/events?"+params.Encode(), &page); err != nil {
+		return err
+	}
+
+	tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "TIME\tSESSION\tEVENT\tCLIENT\tBATCH\tCIPHERTEXTS\tMATCHES\tDURATION\tDETAIL")
+	for _, e := range page.Events {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%d\t%d\t%d\t%s\t%s\n", e.CreatedAt.Format(time.RFC3339), e.SessionID, e.Event,
+			e.Client, e.Batch, e.CiphertextCount, e.MatchCount, time.Duration(e.DurationMs)*time.Millisecond, e.Detail)
+	}
+	tw.Flush()
+	fmt.Printf("%d of %d events\n", len(page.Events), page.Total)
+	return nil
+}
+
+func runUsage(c *adminClient, args []string) error {
+	fs := flag.NewFlagSet("usage", flag.ExitOnError)
+	from := fs.String("from", "", "first month (default the current month, or the last completed one with -noise)")
+	to := fs.String("to", "", "last month, inclusive (default -from)")
+	asCSV := fs.Bool("csv", false, "write CSV for chargeback instead of a table")
+	noise := fs.Bool("noise", false, "differentially private counts for sharing externally (needs PSI_STATS_EPSILON)")
+	fs.Parse(args)
+
+	params := url.Values{}
+	if *from != "" {
+		params.Set("from", *from)
+	}
+	if *to != "" {
+		params.Set("to", *to)
+	}
+	if *noise {
+		params.Set("noise", "true")
+	}
+
+	var resp struct {
+		Usage []struct {
+			Month         string  `json:"month"`
+			APIKeyID      int64   `json:"apiKeyId"`
+			APIKeyName    string  `json:"apiKeyName"`
+			Sessions      int     `json:"sessions"`
+			Intersections int     `json:"intersections"`
+			Matches       int     `json:"matches"`
+			Ciphertexts   int     `json:"ciphertexts"`
+			CPUSeconds    float64 `json:"cpuSeconds"`
+			Errors        int     `json:"errors"`
+		} `json:"usage"`
+	}
+	if err := c.getJSON("/admin/usage?"+params.Encode(), &resp); err != nil {
+		return err
+	}
+
+	// Noised usage leaves out ciphertexts and CPU time
+	header := []string{"month", "api_key_id", "api_key_name", "sessions", "intersections", "matches", "ciphertexts", "cpu_seconds", "errors"}
+	if *noise {
+		header = []string{"month", "api_key_id", "api_key_name", "sessions", "intersections", "matches", "errors"}
+	}
+	rows := make([][]string, 0, len(resp.Usage))
+	for _, u := range resp.Usage {
+		row := []string{u.Month, strconv.FormatInt(u.APIKeyID, 10), u.APIKeyName, strconv.Itoa(u.Sessions),
+			strconv.Itoa(u.Intersections), strconv.Itoa(u.Matches)}
+		if !*noise {
+			row = append(row, strconv.Itoa(u.Ciphertexts), strconv.FormatFloat(u.CPUSeconds, 'f', 3, 64))
+		}
+		rows = append(rows, append(row, strconv.Itoa(u.Errors)))
+	}
+
+	if *asCSV {
+		cw := csv.NewWriter(os.Stdout)
+		cw.Write(header)
+		cw.WriteAll(rows)
+		return cw.Error()
+	}
+
+	tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, strings.ToUpper(strings.ReplaceAll(strings.Join(header, "\t"), "_", " ")))
+	for _, row := range rows {
+		if row[1] == "0" {
+			row[2] = "(unattributed)"
+		}
+		fmt.Fprintln(tw, strings.Join(row, "\t"))
+	}
+	return tw.Flush()
+}
+
+func runStats(c *adminClient, args []string) error {
+	var stats map[string]interface{}
+	if err := c.getJSON("/dashboard/stats", &stats); err != nil {
+		return err
+	}
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	return enc.Encode(stats)
+}
+
+func runKeys(c *adminClient, args []string) error {
+	var resp struct {
+		Keys []struct {
+			ID         int64      `json:"id"`
+			Name       string     `json:"name"`
+			Prefix     string     `json:"prefix"`
+			CreatedAt  time.Time  `json:"createdAt"`
+			LastUsedAt *time.Time `json:"lastUsedAt"`
+			RevokedAt  *time.Time `json:"revokedAt"`
+		} `json:"keys"`
+	}
+	if err := c.getJSON("/admin/api-keys", &resp); err != nil {
+		return err
+	}
+
+	tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "ID\tNAME\tPREFIX\tCREATED\tLAST USED\tSTATUS")
+	for _, k := range resp.Keys {
+		lastUsed, status := "never", "active"
+		if k.LastUsedAt != nil {
+			lastUsed = k.LastUsedAt.Format(time.RFC3339)
+		}
+		if k.RevokedAt != nil {
+			status = "revoked " + k.RevokedAt.Format(time.RFC3339)
+		}
+		fmt.Fprintf(tw, "%d\t%s\t%s…\t%s\t%s\t%s\n", k.ID, k.Name, k.Prefix, k.CreatedAt.Format(time.RFC3339), lastUsed, status)
+	}
+	return tw.Flush()
+}
+
+func runCreateKey(c *adminClient, args []string) error {
+	if len(args) != 1 {
+		return fmt.Errorf("expected a key name")
+	}
+	var resp struct {
+		ID  int64  `json:"id"`
+		Key string `json:"key"`
+	}
+	if err := c.postJSON("/admin/api-keys", map[string]string{"name": args[0]}, &resp); err != nil {
+		return err
+	}
+	fmt.Printf("Created API key %d. Store it now; it cannot be shown again:\n%s\n", resp.ID, resp.Key)
+	return nil
+}
+
+func runRevokeKey(c *adminClient, args []string) error {
+	id, err := singleIDArg(args)
+	if err != nil {
+		return err
+	}
+	if err := c.delete(fmt.Sprintf("/admin/api-keys/%d", id)); err != nil {
+		return err
+	}
+	fmt.Printf("Revoked API key %d\n", id)
+	return nil
+}
+
+type apiKeyQuota struct {
+	MaxSessionsPerDay        int `json:"maxSessionsPerDay"`
+	MaxCiphertextsPerRequest int `json:"maxCiphertextsPerRequest"`
+	MaxConcurrentSessions    int `json:"maxConcurrentSessions"`
+	MinResolveCiphertexts    int `json:"minResolveCiphertexts"`
+}
+
+func runQuota(c *adminClient, args []string) error {
+	id, err := singleIDArg(args)
+	if err != nil {
+		return err
+	}
+	var resp struct {
+		Name               string      `json:"name"`
+		Quota              apiKeyQuota `json:"quota"`
+		SessionsToday      int         `json:"sessionsToday"`
+		ConcurrentSessions int         `json:"concurrentSessions"`
+	}
+	if err := c.getJSON(fmt.Sprintf("/admin/api-keys/%d/quota", id), &resp); err != nil {
+		return err
+	}
+
+	limit := func(n int) string {
+		if n == 0 {
+			return "unlimited"
+		}
+		return strconv.Itoa(n)
+	}
+	tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintf(tw, "API key %d (%s)\n", id, resp.Name)
+	fmt.Fprintln(tw, "LIMIT\tQUOTA\tCURRENT")
+	fmt.Fprintf(tw, "sessions per day\t%s\t%d\n", limit(resp.Quota.MaxSessionsPerDay), resp.SessionsToday)
+	fmt.Fprintf(tw, "concurrent sessions\t%s\t%d\n", limit(resp.Quota.MaxConcurrentSessions), resp.ConcurrentSessions)
+	fmt.Fprintf(tw, "ciphertexts per request\t%s\t-\n", limit(resp.Quota.MaxCiphertextsPerRequest))
+	minResolve := "server default"
+	if resp.Quota.MinResolveCiphertexts > 0 {
+		minResolve = strconv.Itoa(resp.Quota.MinResolveCiphertexts)
+	}
+	fmt.Fprintf(tw, "ciphertexts before resolve\t%s\t-\n", minResolve)
+	return tw.Flush()
+}
+
+// runSetQuota changes only the limits given on the command line; 0 removes a limit
+func runSetQuota(c *adminClient, args []string) error {
+	fs := flag.NewFlagSet("set-quota", flag.ExitOnError)
+	sessionsPerDay := fs.Int("sessions-per-day", 0, "sessions started per UTC day (0 = unlimited)")
+	ciphertexts := fs.Int("ciphertexts", 0, "ciphertexts per intersect request (0 = unlimited)")
+	concurrent := fs.Int("concurrent", 0, "live sessions at once (0 = unlimited)")
+	minResolve := fs.Int("min-resolve", 0, "ciphertexts a session intersects before it may resolve (0 = server default)")
+	fs.Parse(args)
+
+	id, err := singleIDArg(fs.Args())
+	if err != nil {
+		return err
+	}
+	path := fmt.Sprintf("/admin/api-keys/%d/quota", id)
+
+	var current struct {
+		Quota apiKeyQuota `json:"quota"`
+	}
+	if err := c.getJSON(path, &current); err != nil {
+		return err
+	}
+	quota := current.Quota
+	fs.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "sessions-per-day":
+			quota.MaxSessionsPerDay = *sessionsPerDay
+		case "ciphertexts":
+			quota.MaxCiphertextsPerRequest = *ciphertexts
+		case "concurrent":
+			quota.MaxConcurrentSessions = *concurrent
+		case "min-resolve":
+			quota.MinResolveCiphertexts = *minResolve
+		}
+	})
+
+	if err := c.putJSON(path, quota, nil); err != nil {
+		return err
+	}
+	fmt.Printf("API key %d quota: %d sessions/day, %d concurrent, %d ciphertexts/request (0 = unlimited), %d ciphertexts before resolve (0 = server default)\n",
+		id, quota.MaxSessionsPerDay, quota.MaxConcurrentSessions, quota.MaxCiphertextsPerRequest, quota.MinResolveCiphertexts)
+	return nil
+}
+
+// watchlistPack is a pack as returned by the admin API
+type watchlistPack struct {
+	ID          string    `json:"id"`
+	Name        string    `json:"name"`
+	Description string    `json:"description"`
+	Version     int       `json:"version"`
+	ListIDs     []int64   `json:"listIds"`
+	Categories  []string  `json:"categories"`
+	UpdatedAt   time.Time `json:"updatedAt"`
+}
+
+func runPacks(c *adminClient, args []string) error {
+	var resp struct {
+		Packs []watchlistPack `json:"packs"`
+	}
+	if err := c.getJSON("/admin/packs", &resp); err != nil {
+		return err
+	}
+
+	tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "ID\tNAME\tVERSION\tLISTS\tCATEGORIES\tUPDATED")
+	for _, p := range resp.Packs {
+		fmt.Fprintf(tw, "%s\t%s\t%d\t%s\t%s\t%s\n", p.ID, p.Name, p.Version, formatIDs(p.ListIDs),
+			strings.Join(p.Categories, ","), p.UpdatedAt.Format(time.RFC3339))
+	}
+	return tw.Flush()
+}
+
+func runCreatePack(c *adminClient, args []string) error {
+	fs := flag.NewFlagSet("create-pack", flag.ExitOnError)
+	name := fs.String("name", "", "pack name, e.g. \"Global Sanctions Pack\"")
+	description := fs.String("description", "", "pack description")
+	lists := fs.String("lists", "", "comma-separated IDs of the lists in the pack")
+	fs.Parse(args)
+
+	if fs.NArg() != 1 {
+		return fmt.Errorf("expected exactly one pack ID")
+	}
+	listIDs, err := parseIDs(*lists)
+	if err != nil {
+		return err
+	}
+
+	var pack watchlistPack
+	req := map[string]interface{}{"id": fs.Arg(0), "name": *name, "description": *description, "listIds": listIDs}
+	if err := c.postJSON("/admin/packs", req, &pack); err != nil {
+		return err
+	}
+	fmt.Printf("Created pack %s with lists %s\n", pack.ID, formatIDs(pack.ListIDs))
+	return nil
+}
+
+func runUpdatePack(c *adminClient, args []string) error {
+	fs := flag.NewFlagSet("update-pack", flag.ExitOnError)
+	name := fs.String("name", "", "pack name")
+	description := fs.String("description", "", "pack description")
+	lists := fs.String("lists", "", "comma-separated IDs of the lists in the pack")
+	fs.Parse(args)
+
+	if fs.NArg() != 1 {
+		return fmt.Errorf("expected exactly one pack ID")
+	}
+	packID := fs.Arg(0)
+
+	// Start from the current pack so unset flags keep their values
+	var resp struct {
+		Packs []watchlistPack `json:"packs"`
+	}
+	if err := c.getJSON("/admin/packs", &resp); err != nil {
+		return err
+	}
+	var pack *watchlistPack
+	for i := range resp.Packs {
+		if resp.Packs[i].ID == packID {
+			pack = &resp.Packs[i]
+		}
+	}
+	if pack == nil {
+		return fmt.Errorf("no pack %q", packID)
+	}
+
+	var err error
+	fs.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "name":
+			pack.Name = *name
+		case "description":
+			pack.Description = *description
+		case "lists":
+			pack.ListIDs, err = parseIDs(*lists)
+		}
+	})
+	if err != nil {
+		return err
+	}
+
+	req := map[string]interface{}{"name": pack.Name, "description": pack.Description, "listIds": pack.ListIDs}
+	if err := c.putJSON("/admin/packs/"+url.PathEscape(packID), req, pack); err != nil {
+		return err
+	}
+	fmt.Printf("Pack %s is at version %d with lists %s\n", pack.ID, pack.Version, formatIDs(pack.ListIDs))
+	return nil
+}
+
+func runDeletePack(c *adminClient, args []string) error {
+	if len(args) != 1 {
+		return fmt.Errorf("expected exactly one pack ID")
+	}
+	if err := c.delete("/admin/packs/" + url.PathEscape(args[0])); err != nil {
+		return err
+	}
+	fmt.Printf("Deleted pack %s\n", args[0])
+	return nil
+}
+
+// parseIDs parses a comma-separated list of IDs
+func parseIDs(s string) ([]int64, error) {
+	ids := make([]int64, 0)
+	for _, field := range strings.Split(s, ",") {
+		if field = strings.TrimSpace(field); field == "" {
+			continue
+		}
+		id, err := strconv.ParseInt(field, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid list ID %q", field)
+		}
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
+
+func formatIDs(ids []int64) string {
+	parts := make([]string, len(ids))
+	for i, id := range ids {
+		parts[i] = strconv.FormatInt(id, 10)
+	}
+	return strings.Join(parts, ",")
+}
+
+func singleIDArg(args []string) (int64, error) {
+	if len(args) != 1 {
+		return 0, fmt.Errorf("expected exactly one ID")
+	}
+	id, err := strconv.ParseInt(args[0], 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid ID %q", args[0])
+	}
+	return id, nil
+}
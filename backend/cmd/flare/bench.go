@@ -0,0 +1,93 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"runtime"
+	"text/tabwriter"
+	"time"
+
+	"github.com/SanthoshCheemala/FLARE/backend/internal/psiadapter"
+)
+
+// runBenchEncrypt measures client encryption of a synthetic customer list
+// with one worker and with more, and prints the speedup
+func runBenchEncrypt(args []string) error {
+	fs := flag.NewFlagSet("bench-encrypt", flag.ExitOnError)
+	records := fs.Int("records", 2000, "customer records to encrypt")
+	sanctions := fs.Int("sanctions", 100, "sanction records in the tree whose params are used")
+	workers := fs.Int("workers", runtime.NumCPU(), "encryption workers to compare against one")
+	rounds := fs.Int("rounds", 3, "runs per worker count; the fastest is reported")
+	verbose := fs.Bool("verbose", false, "show library logs")
+	fs.Parse(args)
+	if *records < 1 || *sanctions < 1 || *workers < 1 || *rounds < 1 {
+		return fmt.Errorf("-records, -sanctions, -workers and -rounds must be positive")
+	}
+	if !*verbose {
+		log.SetOutput(io.Discard)
+		defer log.SetOutput(os.Stderr)
+	}
+
+	workDir, err := os.MkdirTemp("", "flare-bench-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(workDir)
+
+	ctx := context.Background()
+	scheme, err := psiadapter.NewHashScheme(psiadapter.HashSHA256)
+	if err != nil {
+		return err
+	}
+	sanctionSet := make([]string, *sanctions)
+	for i := range sanctionSet {
+		sanctionSet[i] = psiadapter.SerializeSanction(fmt.Sprintf("Sanctioned Party %d", i), "1970-01-01", "XX", "BENCH")
+	}
+	fmt.Printf("Building a tree of %d sanctions...\n", *sanctions)
+	sc, err := psiadapter.NewAdapter(1).InitServer(ctx, sanctionSet, filepath.Join(workDir, "tree.db"), scheme)
+	if err != nil {
+		return fmt.Errorf("build tree: %w", err)
+	}
+
+	customers := make([]string, *records)
+	for i := range customers {
+		customers[i] = psiadapter.SerializeCustomer(fmt.Sprintf("Customer %d", i), "1980-01-01", "YY")
+	}
+
+	counts := []int{1}
+	if *workers > 1 {
+		counts = append(counts, *workers)
+	}
+	tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "WORKERS\tTIME\tRECORDS/S\tSPEEDUP")
+	var baseline time.Duration
+	for _, n := range counts {
+		adapter := psiadapter.NewAdapter(n)
+		best := time.Duration(0)
+		for r := 0; r < *rounds; r++ {
+			start := time.Now()
+			ciphertexts, err := adapter.EncryptClient(ctx, customers, sc)
+			if err != nil {
+				return fmt.Errorf("encrypt with %d workers: %w", n, err)
+			}
+			elapsed := time.Since(start)
+			if len(ciphertexts) != len(customers) {
+				return fmt.Errorf("%d workers returned %d ciphertexts for %d records", n, len(ciphertexts), len(customers))
+			}
+			if best == 0 || elapsed < best {
+				best = elapsed
+			}
+		}
+		if baseline == 0 {
+			baseline = best
+		}
+		fmt.Fprintf(tw, "%d\t%s\t%.1f\t%.2fx\n", n, best.Round(time.Millisecond),
+			float64(*records)/best.Seconds(), float64(baseline)/float64(best))
+	}
+	return tw.Flush()
+}
@@ -0,0 +1,261 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"text/tabwriter"
+	"time"
+
+	"github.com/SanthoshCheemala/FLARE/backend/internal/apierror"
+	"github.com/SanthoshCheemala/FLARE/backend/internal/bootstrap"
+	"github.com/SanthoshCheemala/FLARE/backend/internal/config"
+	"github.com/SanthoshCheemala/FLARE/backend/internal/handlers"
+	"github.com/SanthoshCheemala/FLARE/backend/internal/jobs"
+	"github.com/SanthoshCheemala/FLARE/backend/internal/models"
+	"github.com/SanthoshCheemala/FLARE/backend/internal/psiserver"
+	"github.com/SanthoshCheemala/FLARE/backend/internal/repository"
+	chimiddleware "github.com/go-chi/chi/v5/middleware"
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// demoTimeout bounds the whole screening, including PSI setup on the server
+const demoTimeout = 30 * time.Minute
+
+func runDemo(args []string) error {
+	fs := flag.NewFlagSet("demo", flag.ExitOnError)
+	fixtures := fs.String("fixtures", "fixtures/default", "fixture directory with sample lists")
+	keep := fs.Bool("keep", false, "keep the temporary working directory")
+	verbose := fs.Bool("verbose", false, "show service logs")
+	fs.Parse(args)
+
+	fixtureDir, err := filepath.Abs(*fixtures)
+	if err != nil {
+		return err
+	}
+	if _, err := bootstrap.LoadManifest(fixtureDir); err != nil {
+		return err
+	}
+
+	// Both services keep PSI state under relative ./data paths, so run
+	// inside a scratch directory
+	workDir, err := os.MkdirTemp("", "flare-demo-")
+	if err != nil {
+		return err
+	}
+	if *keep {
+		fmt.Printf("Working directory: %s\n", workDir)
+	} else {
+		defer os.RemoveAll(workDir)
+	}
+	cwd, err := os.Getwd()
+	if err != nil {
+		return err
+	}
+	if err := os.Chdir(workDir); err != nil {
+		return err
+	}
+	defer os.Chdir(cwd)
+	if err := os.MkdirAll("./data", 0755); err != nil {
+		return err
+	}
+
+	if !*verbose {
+		log.SetOutput(io.Discard)
+		defer log.SetOutput(os.Stderr)
+		chimiddleware.DefaultLogger = chimiddleware.RequestLogger(&chimiddleware.DefaultLogFormatter{
+			Logger: log.New(io.Discard, "", 0),
+		})
+	}
+
+	cfg, err := config.Load()
+	if err != nil {
+		return fmt.Errorf("load config: %w", err)
+	}
+	cfg.Server.AdminToken = ""
+	cfg.PSI.RequireAPIKey = false
+	// the sample customer list is smaller than the default minimum
+	cfg.PSI.MinResolveCiphertexts = 0
+	cfg.PSI.ServerAPIKey = ""
+	cfg.Export = config.ExportConfig{}
+
+	ctx := context.Background()
+
+	serverRepo, closeServerDB, err := openDemoDB("./data/flare_server.db")
+	if err != nil {
+		return err
+	}
+	defer closeServerDB()
+	if _, err := bootstrap.Run(ctx, serverRepo, fixtureDir, bootstrap.ServerSide); err != nil {
+		return fmt.Errorf("load server fixtures: %w", err)
+	}
+	serverURL, stopServer, err := serveDemo(psiserver.NewServer(serverRepo, cfg).Handler())
+	if err != nil {
+		return err
+	}
+	defer stopServer()
+	fmt.Printf("PSI server listening on %s\n", serverURL)
+
+	clientRepo, closeClientDB, err := openDemoDB("./data/flare.db?_parseTime=true")
+	if err != nil {
+		return err
+	}
+	defer closeClientDB()
+	if _, err := bootstrap.Run(ctx, clientRepo, fixtureDir, bootstrap.ClientSide); err != nil {
+		return fmt.Errorf("load client fixtures: %w", err)
+	}
+	cfg.PSI.ServerURL = serverURL
+	jobManager := jobs.NewManager(cfg.PSI.MaxScreenings)
+	clientURL, stopClient, err := serveDemo(handlers.NewRouter(handlers.NewHandler(clientRepo, jobManager, cfg, nil)))
+	if err != nil {
+		return err
+	}
+	defer stopClient()
+	fmt.Printf("Client backend listening on %s\n", clientURL)
+
+	return runDemoScreening(clientURL)
+}
+
+// openDemoDB opens and migrates a SQLite database
+func openDemoDB(dsn string) (*repository.Repository, func(), error) {
+	db, err := sql.Open("sqlite3", dsn)
+	if err != nil {
+		return nil, nil, err
+	}
+	repo := repository.New(db)
+	if err := repo.InitSchema(); err != nil {
+		db.Close()
+		return nil, nil, fmt.Errorf("initialize schema: %w", err)
+	}
+	return repo, func() { db.Close() }, nil
+}
+
+// serveDemo serves handler on a random loopback port
+func serveDemo(handler http.Handler) (string, func(), error) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return "", nil, err
+	}
+	srv := &http.Server{Handler: handler}
+	go srv.Serve(ln)
+
+	stop := func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		srv.Shutdown(ctx)
+	}
+	return "http://" + ln.Addr().String(), stop, nil
+}
+
+// runDemoScreening screens the first customer list against every sanction list
+// through the client API and prints the matches
+func runDemoScreening(clientURL string) error {
+	var customerLists []models.CustomerList
+	if err := demoCall("GET", clientURL+"/lists/customers", nil, &customerLists); err != nil {
+		return fmt.Errorf("list customer lists: %w", err)
+	}
+	var sanctionLists []models.SanctionList
+	if err := demoCall("GET", clientURL+"/lists/sanctions", nil, &sanctionLists); err != nil {
+		return fmt.Errorf("list sanction lists: %w", err)
+	}
+	if len(customerLists) == 0 || len(sanctionLists) == 0 {
+		return fmt.Errorf("fixtures must provide at least one customer list and one sanction list")
+	}
+
+	sanctionIDs := make([]int64, len(sanctionLists))
+	for i, l := range sanctionLists {
+		sanctionIDs[i] = l.ID
+	}
+	req := models.StartScreeningRequest{
+		Name:            "FLARE demo",
+		CustomerListID:  customerLists[0].ID,
+		SanctionListIDs: sanctionIDs,
+		ColumnMapping:   map[string]string{"name": "name", "dob": "dob", "country": "country"},
+	}
+	var started models.StartScreeningResponse
+	if err := demoCall("POST", clientURL+"/screenings", req, &started); err != nil {
+		return fmt.Errorf("start screening: %w", err)
+	}
+	fmt.Printf("Screening %q (%d customers) against %d sanction list(s)...\n",
+		customerLists[0].Name, customerLists[0].RecordCount, len(sanctionLists))
+
+	deadline := time.Now().Add(demoTimeout)
+	var job struct {
+		Status jobs.Status `json:"status"`
+		Error  string      `json:"error"`
+	}
+	for {
+		if err := demoCall("GET", clientURL+"/screenings/"+started.JobID+"/status", nil, &job); err != nil {
+			return fmt.Errorf("poll screening: %w", err)
+		}
+		if job.Status.Finished() {
+			break
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("screening did not finish within %s", demoTimeout)
+		}
+		time.Sleep(time.Second)
+	}
+	if job.Status != jobs.StatusCompleted {
+		return fmt.Errorf("screening %s: %s", job.Status, job.Error)
+	}
+
+	var page struct {
+		Results []models.ScreeningResultDetail `json:"results"`
+		Total   int64                          `json:"total"`
+	}
+	if err := demoCall("GET", clientURL+"/screenings/"+started.JobID+"/results?limit=500", nil, &page); err != nil {
+		return fmt.Errorf("fetch results: %w", err)
+	}
+
+	fmt.Printf("\n%d match(es)\n\n", page.Total)
+	if len(page.Results) == 0 {
+		return nil
+	}
+	tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "CUSTOMER\tDOB\tCOUNTRY\tSANCTIONED ENTITY\tPROGRAM\tSOURCE")
+	for _, r := range page.Results {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\n",
+			r.Customer.Name, r.Customer.DOB, r.Customer.Country, r.Sanction.Name, r.Sanction.Program, r.Sanction.Source)
+	}
+	return tw.Flush()
+}
+
+// demoCall sends a JSON request to an in-process service
+func demoCall(method, url string, in, out interface{}) error {
+	var body io.Reader
+	if in != nil {
+		data, err := json.Marshal(in)
+		if err != nil {
+			return err
+		}
+		body = bytes.NewReader(data)
+	}
+	req, err := http.NewRequest(method, url, body)
+	if err != nil {
+		return err
+	}
+	if in != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return apierror.FromResponse(resp)
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
@@ -0,0 +1,209 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/SanthoshCheemala/FLARE/backend/utils"
+)
+
+// importSummary is the -json output of flare import
+type importSummary struct {
+	Database string       `json:"database"`
+	Mode     string       `json:"mode"`
+	Files    []importFile `json:"files"`
+	Rows     int          `json:"rows"`
+	Failed   int          `json:"failed"`
+	Elapsed  string       `json:"elapsed"`
+}
+
+type importFile struct {
+	File      string `json:"file"`
+	Table     string `json:"table"`
+	Rows      int    `json:"rows"`
+	Inserted  int    `json:"inserted"`
+	Updated   int    `json:"updated"`
+	Unchanged int    `json:"unchanged"`
+	Skipped   int    `json:"skipped"`
+	Error     string `json:"error,omitempty"`
+}
+
+// runImport loads CSV files into a SQLite database with the CSV-to-SQLite
+// converter, one table per file
+func runImport(args []string) error {
+	fs := flag.NewFlagSet("import", flag.ExitOnError)
+	glob := fs.String("glob", "", "CSV files to load, e.g. 'data/*.csv'; files may also be given as arguments")
+	db := fs.String("db", "data/output.db", "SQLite database to load into")
+	workers := fs.Int("workers", 0, "files converted at once (0 = one per CPU core)")
+	batch := fs.Int("batch", 1000, "rows inserted per transaction")
+	mode := fs.String("mode", string(utils.LoadReplace), "replace, append or upsert existing tables")
+	keys := fs.String("keys", "", "comma-separated columns identifying a row, for upsert and keyed append")
+	asJSON := fs.Bool("json", false, "print a JSON summary on stdout; progress goes to stderr")
+	fs.Parse(args)
+
+	files := fs.Args()
+	if *glob != "" {
+		matches, err := filepath.Glob(*glob)
+		if err != nil {
+			return fmt.Errorf("-glob: %w", err)
+		}
+		files = append(files, matches...)
+	}
+	if len(files) == 0 {
+		return fmt.Errorf("no CSV files; give -glob or file arguments")
+	}
+	sort.Strings(files)
+	files = slices.Compact(files)
+	if *batch < 1 {
+		return fmt.Errorf("-batch must be positive")
+	}
+
+	// Messages and progress stay off stdout when it carries the summary
+	var out io.Writer = os.Stdout
+	if *asJSON {
+		out = os.Stderr
+	}
+	bar := newImportProgress(os.Stderr, files)
+	cfg := &utils.CSVToSQLiteConfig{
+		CSVFiles:     files,
+		OutputDBPath: *db,
+		MaxWorkers:   *workers,
+		BatchSize:    *batch,
+		CreateTables: true,
+		Mode:         utils.LoadMode(*mode),
+		KeyColumns:   splitList(*keys),
+		Progress:     bar.update,
+		Output:       bar.writer(out),
+	}
+
+	start := time.Now()
+	converter, err := utils.NewCSVToSQLiteConverter(cfg)
+	if err != nil {
+		return err
+	}
+	defer converter.Close()
+	results, err := converter.Convert()
+	bar.finish()
+	if err != nil {
+		return err
+	}
+
+	summary := importSummary{Database: *db, Mode: string(cfg.Mode), Elapsed: time.Since(start).Round(time.Millisecond).String()}
+	for _, r := range results {
+		f := importFile{File: r.FileName, Table: r.TableName, Rows: r.RowCount,
+			Inserted: r.Inserted, Updated: r.Updated, Unchanged: r.Unchanged, Skipped: r.Skipped}
+		if r.Error != nil {
+			f.Error = r.Error.Error()
+			summary.Failed++
+		}
+		summary.Rows += r.RowCount
+		summary.Files = append(summary.Files, f)
+	}
+
+	if *asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(summary); err != nil {
+			return err
+		}
+	} else {
+		fmt.Printf("%d file(s), %d rows into %s in %s\n", len(summary.Files), summary.Rows, summary.Database, summary.Elapsed)
+	}
+	if summary.Failed > 0 {
+		return fmt.Errorf("%d of %d file(s) failed", summary.Failed, len(results))
+	}
+	return nil
+}
+
+// importProgress draws one progress bar over every file being imported. It
+// draws only on a terminal; elsewhere it stays silent.
+type importProgress struct {
+	mu    sync.Mutex
+	w     io.Writer
+	tty   bool
+	total int64
+	read  map[string]int64 // Bytes read per file
+	rows  map[string]int
+}
+
+func newImportProgress(w *os.File, files []string) *importProgress {
+	p := &importProgress{w: w, read: make(map[string]int64), rows: make(map[string]int)}
+	if info, err := w.Stat(); err == nil {
+		p.tty = info.Mode()&os.ModeCharDevice != 0
+	}
+	for _, f := range files {
+		if info, err := os.Stat(f); err == nil {
+			p.total += info.Size()
+		}
+	}
+	return p
+}
+
+func (p *importProgress) update(u utils.CSVProgress) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.read[u.FileName] = u.BytesRead
+	p.rows[u.FileName] = u.Rows
+	p.drawLocked()
+}
+
+// drawLocked redraws the bar in place
+func (p *importProgress) drawLocked() {
+	if !p.tty {
+		return
+	}
+	var read int64
+	for _, n := range p.read {
+		read += n
+	}
+	var rows int
+	for _, n := range p.rows {
+		rows += n
+	}
+	frac := 1.0
+	if p.total > 0 {
+		frac = min(float64(read)/float64(p.total), 1)
+	}
+	const width = 30
+	filled := int(frac * width)
+	fmt.Fprintf(p.w, "\r[%s%s] %3.0f%%  %d rows", strings.Repeat("#", filled), strings.Repeat(".", width-filled), frac*100, rows)
+}
+
+// writer returns w with the bar cleared before each message and redrawn
+// after it, so messages do not land in the middle of the bar
+func (p *importProgress) writer(w io.Writer) io.Writer {
+	return progressWriter{p: p, w: w}
+}
+
+func (p *importProgress) finish() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.tty {
+		fmt.Fprint(p.w, "\r\033[K")
+	}
+}
+
+type progressWriter struct {
+	p *importProgress
+	w io.Writer
+}
+
+func (pw progressWriter) Write(b []byte) (int, error) {
+	pw.p.mu.Lock()
+	defer pw.p.mu.Unlock()
+	if pw.p.tty {
+		fmt.Fprint(pw.p.w, "\r\033[K")
+	}
+	n, err := pw.w.Write(b)
+	pw.p.drawLocked()
+	return n, err
+}
@@ -0,0 +1,61 @@
+// Command flare bundles FLARE tooling that runs without a deployed stack.
+//
+// The demo subcommand starts the PSI server and the client backend in one
+// process against temporary databases, loads a fixture directory, runs a
+// screening and prints the matches. The bench-encrypt subcommand times
+// client encryption with one worker and with several. The offline
+// subcommand carries a screening across an air gap in files: the authority
+// exports params and answers requests against its own PSI server, and the
+// client exports encrypted requests and imports the matches. The import
+// subcommand loads CSV files into a SQLite database, one table per file.
+package main
+
+import (
+	"fmt"
+	"os"
+)
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+		os.Exit(2)
+	}
+
+	switch os.Args[1] {
+	case "demo":
+		if err := runDemo(os.Args[2:]); err != nil {
+			fmt.Fprintf(os.Stderr, "flare demo: %v\n", err)
+			os.Exit(1)
+		}
+	case "bench-encrypt":
+		if err := runBenchEncrypt(os.Args[2:]); err != nil {
+			fmt.Fprintf(os.Stderr, "flare bench-encrypt: %v\n", err)
+			os.Exit(1)
+		}
+	case "offline":
+		if err := runOffline(os.Args[2:]); err != nil {
+			fmt.Fprintf(os.Stderr, "flare offline: %v\n", err)
+			os.Exit(1)
+		}
+	case "import":
+		if err := runImport(os.Args[2:]); err != nil {
+			fmt.Fprintf(os.Stderr, "flare import: %v\n", err)
+			os.Exit(1)
+		}
+	case "-h", "-help", "--help", "help":
+		usage()
+	default:
+		fmt.Fprintf(os.Stderr, "unknown command %q\n\n", os.Args[1])
+		usage()
+		os.Exit(2)
+	}
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "Usage: flare <command> [args]")
+	fmt.Fprintln(os.Stderr, "\nCommands:")
+	fmt.Fprintln(os.Stderr, "  demo [-fixtures DIR] [-keep] [-verbose]   run a screening against in-process services")
+	fmt.Fprintln(os.Stderr, "  bench-encrypt [-records N] [-workers N]    time client encryption with one and N workers")
+	fmt.Fprintln(os.Stderr, "  offline params|export|answer|import        screen across an air gap with files")
+	fmt.Fprintln(os.Stderr, "  import -glob 'data/*.csv' -db FILE [-json]  load CSV files into SQLite tables")
+}
@@ -0,0 +1,324 @@
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"runtime"
+	"strings"
+
+	"github.com/SanthoshCheemala/FLARE/backend/internal/client"
+	"github.com/SanthoshCheemala/FLARE/backend/internal/handlers"
+	"github.com/SanthoshCheemala/FLARE/backend/internal/models"
+	"github.com/SanthoshCheemala/FLARE/backend/internal/protocol"
+	"github.com/SanthoshCheemala/FLARE/backend/internal/psiadapter"
+	"github.com/SanthoshCheemala/FLARE/backend/internal/translit"
+)
+
+// runOffline runs a step of an offline screening. The authority runs params
+// and answer against its own PSI server; the client runs export and import
+// without any connection.
+func runOffline(args []string) error {
+	if len(args) == 0 {
+		offlineUsage()
+		return fmt.Errorf("missing step")
+	}
+	switch args[0] {
+	case "params":
+		return runOfflineParams(args[1:])
+	case "export":
+		return runOfflineExport(args[1:])
+	case "answer":
+		return runOfflineAnswer(args[1:])
+	case "import":
+		return runOfflineImport(args[1:])
+	default:
+		offlineUsage()
+		return fmt.Errorf("unknown step %q", args[0])
+	}
+}
+
+func offlineUsage() {
+	fmt.Fprintln(os.Stderr, "Usage: flare offline <step> [args]")
+	fmt.Fprintln(os.Stderr, "\nSteps, in order:")
+	fmt.Fprintln(os.Stderr, "  params -server URL -lists IDS -out FILE     authority: export the params to encrypt under")
+	fmt.Fprintln(os.Stderr, "  export -params FILE -customers CSV -out FILE  client: encrypt customers into a request")
+	fmt.Fprintln(os.Stderr, "  answer -server URL -request FILE -out FILE   authority: screen a request into a response")
+	fmt.Fprintln(os.Stderr, "  import -response FILE -customers CSV         client: write the matches as CSV")
+}
+
+// offlineServer returns a client of the authority's own PSI server
+func offlineServer(url, apiKey string) *client.PSIClient {
+	c := client.NewPSIClient(url)
+	c.SetAPIKey(apiKey)
+	return c
+}
+
+func runOfflineParams(args []string) error {
+	fs := flag.NewFlagSet("offline params", flag.ExitOnError)
+	server := fs.String("server", "http://localhost:8081", "URL of this authority's PSI server")
+	apiKey := fs.String("api-key", os.Getenv("PSI_SERVER_API_KEY"), "API key for the PSI server")
+	lists := fs.String("lists", "", "comma-separated IDs of the sanction lists to screen against")
+	columns := fs.String("columns", "name,dob,country", "columns customers are hashed with")
+	names := fs.String("transliteration", "", "scripts romanized before hashing, or \"all\"")
+	out := fs.String("out", "flare-params.json", "params bundle to write")
+	fs.Parse(args)
+	if *lists == "" {
+		return fmt.Errorf("-lists is required")
+	}
+	profile, err := translit.Parse(*names)
+	if err != nil {
+		return err
+	}
+	req := protocol.InitSessionRequest{
+		SanctionListIDs: splitList(*lists),
+		EnabledColumns:  splitList(*columns),
+		Transliteration: profile,
+	}
+
+	// The params must outlive the session they are issued in. Sessions with
+	// their own tree or hash salt get fresh ones each time, so a second
+	// session tells whether the client's ciphertexts could ever be screened.
+	ctx := context.Background()
+	psiServer := offlineServer(*server, *apiKey)
+	var bundle *protocol.ParamsBundle
+	var digest string
+	for i := 0; i < 2; i++ {
+		session, err := protocol.Open(ctx, psiServer, req)
+		if err != nil {
+			return fmt.Errorf("open session: %w", err)
+		}
+		resp := protocol.InitSessionResponse{
+			SessionID:       session.ID,
+			BatchParams:     session.Params,
+			Transliteration: profile,
+			Hash:            session.Hash,
+		}
+		if len(session.Params) == 1 {
+			resp.Params, resp.BatchParams = session.Params[0], nil
+		}
+		sessionDigest := protocol.ParamsDigest(session.Params, session.Hash)
+		if err := session.Close(ctx); err != nil {
+			log.Printf("Warning: failed to close session %s: %v", session.ID, err)
+		}
+		if session.Hash.Algorithm != psiadapter.HashSHA256 {
+			return fmt.Errorf("the server hashes with %s, whose salt is fresh per session; offline screening needs %s", session.Hash, psiadapter.HashSHA256)
+		}
+		if i == 0 {
+			bundle, digest = protocol.NewParamsBundle(req, &resp), sessionDigest
+		} else if sessionDigest != digest {
+			return fmt.Errorf("the server issues fresh params per session for these lists and columns; offline screening needs the global tree and the default columns")
+		}
+	}
+
+	if err := protocol.WriteBundle(*out, bundle); err != nil {
+		return err
+	}
+	fmt.Printf("Wrote params for %d batch(es) to %s\n", max(1, len(bundle.Session.BatchParams)), *out)
+	return nil
+}
+
+func runOfflineExport(args []string) error {
+	fs := flag.NewFlagSet("offline export", flag.ExitOnError)
+	paramsFile := fs.String("params", "flare-params.json", "params bundle from the authority")
+	customersFile := fs.String("customers", "", "customer list CSV")
+	mapping := fs.String("map", "", "column mapping, e.g. name=full_name,dob=birth_date")
+	dateOrder := fs.String("date-order", "", "order of ambiguous dates of birth: DMY or MDY")
+	workers := fs.Int("workers", runtime.NumCPU(), "encryption workers")
+	out := fs.String("out", "flare-request.json", "request bundle to write")
+	fs.Parse(args)
+
+	var params protocol.ParamsBundle
+	if err := protocol.ReadBundle(*paramsFile, protocol.KindParams, &params); err != nil {
+		return err
+	}
+	_, serialized, err := readOfflineCustomers(*customersFile, *mapping, *dateOrder, params.Request)
+	if err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+	recorder := protocol.NewRecorder(&params)
+	session, err := protocol.Open(ctx, recorder, params.Request)
+	if err != nil {
+		return err
+	}
+	psi := psiadapter.NewAdapter(*workers)
+	for b, batchParams := range session.Params {
+		pp, msg, le, err := psi.DeserializeParams(batchParams)
+		if err != nil {
+			return fmt.Errorf("deserialize params of batch %d: %w", b, err)
+		}
+		ciphertexts, err := psi.EncryptClient(ctx, serialized, &psiadapter.ServerContext{PP: pp, Msg: msg, LE: le, Hash: session.Hash})
+		if err != nil {
+			return fmt.Errorf("encrypt batch %d: %w", b, err)
+		}
+		if _, _, err := session.Intersect(ctx, b, ciphertexts); err != nil {
+			return err
+		}
+	}
+	session.Close(ctx)
+
+	if err := protocol.WriteBundle(*out, recorder.Bundle()); err != nil {
+		return err
+	}
+	fmt.Printf("Encrypted %d customers under %d batch(es) into %s\n", len(serialized), len(session.Params), *out)
+	return nil
+}
+
+func runOfflineAnswer(args []string) error {
+	fs := flag.NewFlagSet("offline answer", flag.ExitOnError)
+	server := fs.String("server", "http://localhost:8081", "URL of this authority's PSI server")
+	apiKey := fs.String("api-key", os.Getenv("PSI_SERVER_API_KEY"), "API key for the PSI server")
+	requestFile := fs.String("request", "flare-request.json", "request bundle from the client")
+	out := fs.String("out", "flare-response.json", "response bundle to write")
+	fs.Parse(args)
+
+	var request protocol.RequestBundle
+	if err := protocol.ReadBundle(*requestFile, protocol.KindRequest, &request); err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+	session, err := protocol.Open(ctx, offlineServer(*server, *apiKey), request.Request)
+	if err != nil {
+		return fmt.Errorf("open session: %w", err)
+	}
+	defer func() {
+		if err := session.Close(ctx); err != nil {
+			log.Printf("Warning: failed to close session %s: %v", session.ID, err)
+		}
+	}()
+	if protocol.ParamsDigest(session.Params, session.Hash) != request.ParamsDigest {
+		return fmt.Errorf("the server's params changed since the request was encrypted (lists updated or trees rebuilt); export new params for the client")
+	}
+	if len(request.Batches) != len(session.Params) {
+		return fmt.Errorf("request has %d batch(es), the session %d", len(request.Batches), len(session.Params))
+	}
+	defer session.Heartbeat(ctx)()
+
+	var matches []uint64
+	seen := make(map[uint64]bool)
+	for b, ciphertexts := range request.Batches {
+		batchMatches, _, err := session.Intersect(ctx, b, ciphertexts)
+		if err != nil {
+			return fmt.Errorf("batch %d intersection failed: %w", b, err)
+		}
+		for _, m := range batchMatches {
+			if !seen[m] {
+				seen[m] = true
+				matches = append(matches, m)
+			}
+		}
+	}
+	sanctions, err := session.Resolve(ctx, matches)
+	if err != nil {
+		return fmt.Errorf("resolve matches: %w", err)
+	}
+
+	if err := protocol.WriteBundle(*out, protocol.NewResponseBundle(&request, session.Hash, matches, sanctions)); err != nil {
+		return err
+	}
+	fmt.Printf("Found %d match hash(es); wrote the response to %s\n", len(matches), *out)
+	return nil
+}
+
+func runOfflineImport(args []string) error {
+	fs := flag.NewFlagSet("offline import", flag.ExitOnError)
+	responseFile := fs.String("response", "flare-response.json", "response bundle from the authority")
+	customersFile := fs.String("customers", "", "the customer list CSV the request was exported from")
+	mapping := fs.String("map", "", "the column mapping the request was exported with")
+	dateOrder := fs.String("date-order", "", "the date order the request was exported with")
+	out := fs.String("out", "", "matches CSV to write (default stdout)")
+	fs.Parse(args)
+
+	var response protocol.ResponseBundle
+	if err := protocol.ReadBundle(*responseFile, protocol.KindResponse, &response); err != nil {
+		return err
+	}
+	customers, serialized, err := readOfflineCustomers(*customersFile, *mapping, *dateOrder, response.Request)
+	if err != nil {
+		return err
+	}
+	scheme, err := response.Hash.Check()
+	if err != nil {
+		return err
+	}
+	matches, collisions := handlers.PairOfflineMatches(customers, serialized, response.Matches, response.Sanctions,
+		response.Request.EnabledColumns, response.Request.Transliteration, scheme)
+	if collisions > 0 {
+		fmt.Fprintf(os.Stderr, "Warning: %d hash collision(s) were not counted as matches\n", collisions)
+	}
+
+	w := io.Writer(os.Stdout)
+	if *out != "" {
+		f, err := os.Create(*out)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		w = f
+	}
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"customer_id", "customer_name", "customer_dob", "customer_country", "sanction_name", "sanction_dob", "sanction_country", "program", "source", "channel"})
+	for _, m := range matches {
+		cw.Write([]string{m.Customer.ExternalID, m.Customer.Name, m.Customer.DOB, m.Customer.Country,
+			m.Sanction.Name, m.Sanction.DOB, m.Sanction.Country, m.Sanction.Program, m.Sanction.Source, m.Channel})
+	}
+	cw.Flush()
+	if err := cw.Error(); err != nil {
+		return err
+	}
+	if *out != "" {
+		fmt.Printf("Wrote %d match(es) to %s\n", len(matches), *out)
+	}
+	return nil
+}
+
+// readOfflineCustomers reads and serializes a customer list for the session
+// req asks for. The document channel is not screened offline.
+func readOfflineCustomers(path, mapping, dateOrder string, req protocol.InitSessionRequest) ([]*models.Customer, []string, error) {
+	if path == "" {
+		return nil, nil, fmt.Errorf("-customers is required")
+	}
+	prefer, err := psiadapter.ParseDateOrder(dateOrder)
+	if err != nil {
+		return nil, nil, err
+	}
+	columnMapping := make(map[string]string)
+	for _, pair := range splitList(mapping) {
+		column, header, ok := strings.Cut(pair, "=")
+		if !ok {
+			return nil, nil, fmt.Errorf("invalid mapping %q (use column=header)", pair)
+		}
+		columnMapping[strings.TrimSpace(column)] = strings.TrimSpace(header)
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer f.Close()
+	customers, serialized, err := handlers.ReadCustomerCSV(f, 0, columnMapping, req.EnabledColumns, req.Transliteration, prefer, nil)
+	if err != nil {
+		return nil, nil, fmt.Errorf("read %s: %w", path, err)
+	}
+	if len(serialized) == 0 {
+		return nil, nil, fmt.Errorf("%s has no customers to screen", path)
+	}
+	return customers, serialized, nil
+}
+
+// splitList splits a comma-separated flag value, dropping empty items
+func splitList(s string) []string {
+	var items []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
@@ -14,6 +14,7 @@ require (
 	github.com/go-chi/chi/v5 v5.2.3
 	github.com/gorilla/websocket v1.5.3
 	github.com/tuneinsight/lattigo/v3 v3.0.6
+	gopkg.in/yaml.v3 v3.0.1
 )
 
 require golang.org/x/sys v0.38.0 // indirect
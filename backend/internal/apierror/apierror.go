@@ -0,0 +1,101 @@
+// Package apierror defines the JSON error envelope shared by the client
+// backend and the PSI server.
+package apierror
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/go-chi/chi/v5/middleware"
+)
+
+// Code identifies an error class that API consumers can branch on
+type Code string
+
+const (
+	CodeBadRequest      Code = "BAD_REQUEST"
+	CodeUnauthorized    Code = "UNAUTHORIZED"
+	CodeForbidden       Code = "FORBIDDEN"
+	CodeNotFound        Code = "NOT_FOUND"
+	CodeSessionNotFound Code = "SESSION_NOT_FOUND"
+	CodeSessionClosed   Code = "SESSION_CLOSED"
+	CodeListNotFound    Code = "LIST_NOT_FOUND"
+	CodePackNotFound    Code = "PACK_NOT_FOUND"
+	CodeJobNotFound     Code = "JOB_NOT_FOUND"
+	CodeRateLimited     Code = "RATE_LIMITED"
+	CodeQuotaExceeded   Code = "QUOTA_EXCEEDED"
+	CodeConflict        Code = "CONFLICT"
+	CodePSIFailed       Code = "PSI_FAILED"
+	CodeParamsChanged   Code = "PARAMS_CHANGED"
+	CodeUpstreamFailed  Code = "UPSTREAM_FAILED"
+	CodeUploadRejected  Code = "UPLOAD_REJECTED"
+	CodeBodyTooLarge    Code = "BODY_TOO_LARGE"
+	CodeDatabaseError   Code = "DATABASE_ERROR"
+	CodeInternal        Code = "INTERNAL_ERROR"
+)
+
+// Response is the error envelope written by every handler
+type Response struct {
+	Code      Code        `json:"code"`
+	Message   string      `json:"message"`
+	Details   interface{} `json:"details,omitempty"`
+	RequestID string      `json:"requestId,omitempty"`
+}
+
+// Write sends an error envelope with the given status
+func Write(w http.ResponseWriter, r *http.Request, status int, code Code, message string) {
+	WriteDetails(w, r, status, code, message, nil)
+}
+
+// WriteDetails sends an error envelope carrying extra details
+func WriteDetails(w http.ResponseWriter, r *http.Request, status int, code Code, message string, details interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(Response{
+		Code:      code,
+		Message:   message,
+		Details:   details,
+		RequestID: middleware.GetReqID(r.Context()),
+	})
+}
+
+// TooLarge sends 413 if err comes from reading a body past its size limit
+// (see http.MaxBytesReader) and reports whether it did
+func TooLarge(w http.ResponseWriter, r *http.Request, err error) bool {
+	var tooLarge *http.MaxBytesError
+	if !errors.As(err, &tooLarge) {
+		return false
+	}
+	WriteDetails(w, r, http.StatusRequestEntityTooLarge, CodeBodyTooLarge,
+		fmt.Sprintf("Request body exceeds the %d byte limit", tooLarge.Limit),
+		map[string]int64{"limitBytes": tooLarge.Limit})
+	return true
+}
+
+// Error is a non-2xx response received from a FLARE service
+type Error struct {
+	Status int
+	Response
+}
+
+func (e *Error) Error() string {
+	if e.Message == "" {
+		return fmt.Sprintf("server returned status %d", e.Status)
+	}
+	return fmt.Sprintf("server returned status %d: %s (%s)", e.Status, e.Message, e.Code)
+}
+
+// FromResponse decodes the error envelope of a failed response. Bodies that
+// are not envelopes are kept as the message.
+func FromResponse(resp *http.Response) *Error {
+	e := &Error{Status: resp.StatusCode}
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+	if err := json.Unmarshal(body, &e.Response); err != nil || e.Code == "" {
+		e.Response = Response{Code: CodeUpstreamFailed, Message: string(body)}
+	}
+	return e
+}
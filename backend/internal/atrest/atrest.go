@@ -0,0 +1,174 @@
+// Package atrest encrypts files the services keep on disk (uploaded lists
+// and spilled PSI state) with AES-256-GCM.
+//
+// Encrypted files start with a magic header, so files written before
+// encryption was enabled, and bundled fixtures, are still read as plaintext.
+package atrest
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+)
+
+// KeySize is the required key length in bytes (AES-256)
+const KeySize = 32
+
+// magic prefixes every encrypted file
+var magic = []byte("FLAREENC1\n")
+
+// ErrNoKey is returned when an encrypted file is read without a key
+var ErrNoKey = errors.New("file is encrypted but no storage encryption key is configured")
+
+// Cipher seals and opens files. A nil *Cipher stores plaintext.
+type Cipher struct {
+	aead cipher.AEAD
+}
+
+// New creates a Cipher from a base64-encoded 32-byte key. An empty key
+// returns nil, which disables encryption.
+func New(encodedKey string) (*Cipher, error) {
+	if encodedKey == "" {
+		return nil, nil
+	}
+	key, err := ParseKey(encodedKey)
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	return &Cipher{aead: aead}, nil
+}
+
+// ParseKey decodes and validates a base64-encoded key
+func ParseKey(encodedKey string) ([]byte, error) {
+	key, err := base64.StdEncoding.DecodeString(encodedKey)
+	if err != nil {
+		return nil, fmt.Errorf("storage encryption key is not valid base64: %w", err)
+	}
+	if len(key) != KeySize {
+		return nil, fmt.Errorf("storage encryption key must be %d bytes, got %d", KeySize, len(key))
+	}
+	return key, nil
+}
+
+// Enabled reports whether files are encrypted
+func (c *Cipher) Enabled() bool {
+	return c != nil
+}
+
+// Seal encrypts plaintext into the on-disk format
+func (c *Cipher) Seal(plaintext []byte) ([]byte, error) {
+	if c == nil {
+		return plaintext, nil
+	}
+	nonce := make([]byte, c.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	out := make([]byte, 0, len(magic)+len(nonce)+len(plaintext)+c.aead.Overhead())
+	out = append(out, magic...)
+	out = append(out, nonce...)
+	// The header is authenticated so it cannot be swapped
+	return c.aead.Seal(out, nonce, plaintext, magic), nil
+}
+
+// Open decrypts data in the on-disk format. Data without the header is
+// returned unchanged.
+func (c *Cipher) Open(data []byte) ([]byte, error) {
+	if !bytes.HasPrefix(data, magic) {
+		return data, nil
+	}
+	if c == nil {
+		return nil, ErrNoKey
+	}
+	data = data[len(magic):]
+	if len(data) < c.aead.NonceSize() {
+		return nil, errors.New("encrypted file is truncated")
+	}
+	nonce, sealed := data[:c.aead.NonceSize()], data[c.aead.NonceSize():]
+	plaintext, err := c.aead.Open(nil, nonce, sealed, magic)
+	if err != nil {
+		return nil, fmt.Errorf("decrypt file: %w", err)
+	}
+	return plaintext, nil
+}
+
+// WriteFile writes the contents of r to path, encrypted when enabled
+func (c *Cipher) WriteFile(path string, r io.Reader, perm os.FileMode) error {
+	if c == nil {
+		f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, perm)
+		if err != nil {
+			return err
+		}
+		if _, err := io.Copy(f, r); err != nil {
+			f.Close()
+			return err
+		}
+		return f.Close()
+	}
+
+	plaintext, err := io.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	sealed, err := c.Seal(plaintext)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, sealed, perm)
+}
+
+// ReadFile reads path, decrypting it if it is encrypted
+func (c *Cipher) ReadFile(path string) ([]byte, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return c.Open(data)
+}
+
+// OpenFile opens path for reading, decrypting it if it is encrypted.
+// Plaintext files are streamed; encrypted files are decrypted in memory.
+func (c *Cipher) OpenFile(path string) (io.ReadCloser, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	header := make([]byte, len(magic))
+	n, err := io.ReadFull(f, header)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		f.Close()
+		return nil, err
+	}
+	if n < len(magic) || !bytes.Equal(header, magic) {
+		if _, err := f.Seek(0, io.SeekStart); err != nil {
+			f.Close()
+			return nil, err
+		}
+		return f, nil
+	}
+
+	defer f.Close()
+	rest, err := io.ReadAll(f)
+	if err != nil {
+		return nil, err
+	}
+	plaintext, err := c.Open(append(header, rest...))
+	if err != nil {
+		return nil, err
+	}
+	return io.NopCloser(bytes.NewReader(plaintext)), nil
+}
@@ -0,0 +1,40 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"fmt"
+)
+
+// apiKeyPrefix marks FLARE API keys so they are recognisable in configs and logs
+const apiKeyPrefix = "flk_"
+
+// GenerateAPIKey returns a new random API key. Only its hash should be stored.
+func GenerateAPIKey() (string, error) {
+	b := make([]byte, 24)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("generate api key: %w", err)
+	}
+	return apiKeyPrefix + hex.EncodeToString(b), nil
+}
+
+// HashAPIKey returns the hex SHA-256 of key, used to look keys up at rest
+func HashAPIKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+// APIKeyPrefix returns the leading characters of key shown in listings
+func APIKeyPrefix(key string) string {
+	if len(key) <= len(apiKeyPrefix)+6 {
+		return key
+	}
+	return key[:len(apiKeyPrefix)+6]
+}
+
+// TokensEqual compares two secrets in constant time
+func TokensEqual(a, b string) bool {
+	return subtle.ConstantTimeCompare([]byte(a), []byte(b)) == 1
+}
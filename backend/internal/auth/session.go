@@ -0,0 +1,91 @@
+package auth
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+var ErrSessionMismatch = errors.New("token not valid for this session")
+
+// SessionClaims bind a PSI session token to one session and client host
+type SessionClaims struct {
+	SessionID  string `json:"sid"`
+	ClientHost string `json:"host"`
+	jwt.RegisteredClaims
+}
+
+// SessionTokenService issues and validates short-lived PSI session tokens
+type SessionTokenService struct {
+	secret []byte
+	expiry time.Duration
+	issuer string
+}
+
+func NewSessionTokenService(secret string, expiry time.Duration, issuer string) *SessionTokenService {
+	return &SessionTokenService{
+		secret: []byte(secret),
+		expiry: expiry,
+		issuer: issuer,
+	}
+}
+
+// Generate issues a token for sessionID usable only from clientHost.
+// The returned token ID lets the caller revoke it by forgetting the ID.
+func (s *SessionTokenService) Generate(sessionID, clientHost string) (token, tokenID string, expiresAt time.Time, err error) {
+	idBytes := make([]byte, 16)
+	if _, err := rand.Read(idBytes); err != nil {
+		return "", "", time.Time{}, fmt.Errorf("generate token id: %w", err)
+	}
+	tokenID = hex.EncodeToString(idBytes)
+	expiresAt = time.Now().Add(s.expiry)
+
+	claims := SessionClaims{
+		SessionID:  sessionID,
+		ClientHost: clientHost,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        tokenID,
+			ExpiresAt: jwt.NewNumericDate(expiresAt),
+			IssuedAt:  jwt.NewNumericDate(time.Now()),
+			Issuer:    s.issuer,
+		},
+	}
+
+	token, err = jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(s.secret)
+	if err != nil {
+		return "", "", time.Time{}, err
+	}
+	return token, tokenID, expiresAt, nil
+}
+
+// Validate checks the signature and expiry and that the token was issued
+// for sessionID to clientHost
+func (s *SessionTokenService) Validate(tokenString, sessionID, clientHost string) (*SessionClaims, error) {
+	token, err := jwt.ParseWithClaims(tokenString, &SessionClaims{}, func(token *jwt.Token) (interface{}, error) {
+		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+		}
+		return s.secret, nil
+	})
+	if err != nil {
+		if errors.Is(err, jwt.ErrTokenExpired) {
+			return nil, ErrExpiredToken
+		}
+		return nil, ErrInvalidToken
+	}
+
+	claims, ok := token.Claims.(*SessionClaims)
+	if !ok || !token.Valid {
+		return nil, ErrInvalidToken
+	}
+
+	if claims.SessionID != sessionID || claims.ClientHost != clientHost {
+		return nil, ErrSessionMismatch
+	}
+
+	return claims, nil
+}
@@ -0,0 +1,103 @@
+package auth
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// Headers carrying a signed PSI request
+const (
+	HeaderTimestamp = "X-Flare-Timestamp" // Unix seconds
+	HeaderNonce     = "X-Flare-Nonce"
+	HeaderSignature = "X-Flare-Signature"
+)
+
+var (
+	ErrUnsignedRequest  = errors.New("request is not signed")
+	ErrBadSignature     = errors.New("request signature does not match")
+	ErrStaleTimestamp   = errors.New("request timestamp outside the allowed window")
+	ErrNonceReused      = errors.New("request nonce already used")
+	ErrNonceCacheFull   = errors.New("too many recent requests to track nonces")
+	errInvalidTimestamp = errors.New("invalid request timestamp")
+)
+
+// GenerateSigningKey returns a random per-session key for signing requests.
+// The PSI server hands it out once, in the init response.
+func GenerateSigningKey() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("generate signing key: %w", err)
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// NewNonce returns a random single-use request nonce
+func NewNonce() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("generate nonce: %w", err)
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// SignRequest returns the hex HMAC-SHA256 of a request's method, path,
+// timestamp, nonce and body under key
+func SignRequest(key, method, path, timestamp, nonce string, body []byte) string {
+	bodySum := sha256.Sum256(body)
+	return SignDigest(key, method, path, timestamp, nonce, bodySum[:])
+}
+
+// SignDigest is SignRequest for a body streamed rather than held in memory,
+// given the SHA-256 of the body
+func SignDigest(key, method, path, timestamp, nonce string, bodySum []byte) string {
+	mac := hmac.New(sha256.New, []byte(key))
+	fmt.Fprintf(mac, "%s\n%s\n%s\n%s\n%s", method, path, timestamp, nonce, hex.EncodeToString(bodySum))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// VerifyRequest checks a request signature and that its timestamp is within
+// maxSkew of now. Callers must still reject reused nonces.
+func VerifyRequest(key, method, path, timestamp, nonce, signature string, body []byte, maxSkew time.Duration) error {
+	bodySum := sha256.Sum256(body)
+	return VerifyDigest(key, method, path, timestamp, nonce, signature, bodySum[:], maxSkew)
+}
+
+// VerifyDigest is VerifyRequest given the SHA-256 of the body
+func VerifyDigest(key, method, path, timestamp, nonce, signature string, bodySum []byte, maxSkew time.Duration) error {
+	if err := VerifyHeaders(timestamp, nonce, signature, maxSkew); err != nil {
+		return err
+	}
+	return VerifySignature(key, method, path, timestamp, nonce, signature, bodySum)
+}
+
+// VerifyHeaders checks that a request carries a timestamp, nonce and
+// signature and that its timestamp is within maxSkew of now. It needs no
+// body, so a streamed request can be checked before it is read.
+func VerifyHeaders(timestamp, nonce, signature string, maxSkew time.Duration) error {
+	if timestamp == "" || nonce == "" || signature == "" {
+		return ErrUnsignedRequest
+	}
+	secs, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return errInvalidTimestamp
+	}
+	if skew := time.Since(time.Unix(secs, 0)); skew > maxSkew || skew < -maxSkew {
+		return ErrStaleTimestamp
+	}
+	return nil
+}
+
+// VerifySignature checks a request signature given the SHA-256 of the body.
+// Callers must check the headers with VerifyHeaders too.
+func VerifySignature(key, method, path, timestamp, nonce, signature string, bodySum []byte) error {
+	if !hmac.Equal([]byte(signature), []byte(SignDigest(key, method, path, timestamp, nonce, bodySum))) {
+		return ErrBadSignature
+	}
+	return nil
+}
@@ -0,0 +1,369 @@
+// Package bootstrap loads fixture directories into a FLARE database.
+//
+// A fixture directory holds a fixtures.json manifest and the CSV files it
+// references. Loading is idempotent: users are upserted by email and lists
+// are matched by name, so re-running only reloads lists whose file changed.
+package bootstrap
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/csv"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/SanthoshCheemala/FLARE/backend/internal/auth"
+	"github.com/SanthoshCheemala/FLARE/backend/internal/models"
+	"github.com/SanthoshCheemala/FLARE/backend/internal/psiadapter"
+	"github.com/SanthoshCheemala/FLARE/backend/internal/repository"
+)
+
+// ManifestFile is the manifest name expected in a fixture directory
+const ManifestFile = "fixtures.json"
+
+// Side selects which half of a manifest to load
+type Side string
+
+const (
+	ServerSide Side = "server" // Sanctions authority database
+	ClientSide Side = "client" // Bank database
+)
+
+type UserFixture struct {
+	Email    string `json:"email"`
+	Password string `json:"password"`
+	Role     string `json:"role"`
+}
+
+type SanctionListFixture struct {
+	Name        string `json:"name"`
+	Source      string `json:"source"`
+	Category    string `json:"category"` // SANCTIONS (default), PEP, ADVERSE_MEDIA or INTERNAL
+	Description string `json:"description"`
+	File        string `json:"file"` // Relative to the fixture directory
+}
+
+type CustomerListFixture struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	File        string `json:"file"` // Relative to the fixture directory
+}
+
+// Manifest describes the fixtures for both services
+type Manifest struct {
+	Server struct {
+		Users         []UserFixture         `json:"users"`
+		SanctionLists []SanctionListFixture `json:"sanctionLists"`
+	} `json:"server"`
+	Client struct {
+		Users         []UserFixture         `json:"users"`
+		CustomerLists []CustomerListFixture `json:"customerLists"`
+	} `json:"client"`
+}
+
+// Result summarizes what a run changed
+type Result struct {
+	UsersCreated int
+	UsersUpdated int
+	ListsLoaded  int
+	ListsSkipped int // Unchanged since the last run
+	Records      int
+}
+
+// LoadManifest reads the manifest from dir
+func LoadManifest(dir string) (*Manifest, error) {
+	data, err := os.ReadFile(filepath.Join(dir, ManifestFile))
+	if err != nil {
+		return nil, fmt.Errorf("read manifest: %w", err)
+	}
+	var m Manifest
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, fmt.Errorf("parse manifest: %w", err)
+	}
+	return &m, nil
+}
+
+// Run loads one side of the fixtures in dir into repo
+func Run(ctx context.Context, repo *repository.Repository, dir string, side Side) (*Result, error) {
+	m, err := LoadManifest(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	res := &Result{}
+	switch side {
+	case ServerSide:
+		if err := loadUsers(ctx, repo, m.Server.Users, res); err != nil {
+			return res, err
+		}
+		for _, f := range m.Server.SanctionLists {
+			if err := loadSanctionList(ctx, repo, dir, f, res); err != nil {
+				return res, fmt.Errorf("sanction list %q: %w", f.Name, err)
+			}
+		}
+	case ClientSide:
+		if err := loadUsers(ctx, repo, m.Client.Users, res); err != nil {
+			return res, err
+		}
+		for _, f := range m.Client.CustomerLists {
+			if err := loadCustomerList(ctx, repo, dir, f, res); err != nil {
+				return res, fmt.Errorf("customer list %q: %w", f.Name, err)
+			}
+		}
+	default:
+		return nil, fmt.Errorf("unknown side %q", side)
+	}
+	return res, nil
+}
+
+func loadUsers(ctx context.Context, repo *repository.Repository, users []UserFixture, res *Result) error {
+	for _, u := range users {
+		hash, err := auth.HashPassword(u.Password)
+		if err != nil {
+			return fmt.Errorf("hash password for %s: %w", u.Email, err)
+		}
+		created, err := repo.UpsertUser(ctx, u.Email, hash, u.Role)
+		if err != nil {
+			return fmt.Errorf("upsert user %s: %w", u.Email, err)
+		}
+		if created {
+			res.UsersCreated++
+		} else {
+			res.UsersUpdated++
+		}
+	}
+	return nil
+}
+
+func loadSanctionList(ctx context.Context, repo *repository.Repository, dir string, f SanctionListFixture, res *Result) error {
+	category, ok := models.ParseListCategory(f.Category)
+	if !ok {
+		return fmt.Errorf("unknown category %q", f.Category)
+	}
+	path, checksum, err := fixtureFile(dir, f.File)
+	if err != nil {
+		return err
+	}
+
+	existing, err := repo.GetSanctionListChecksum(ctx, f.Name)
+	if err != nil {
+		return err
+	}
+	if existing != nil && existing.Checksum == checksum {
+		res.ListsSkipped++
+		return nil
+	}
+
+	var listID int64
+	if existing != nil {
+		listID = existing.ID
+		if _, err := repo.ReplaceSanctionListVersion(ctx, listID, path); err != nil {
+			return err
+		}
+	} else {
+		if listID, err = repo.CreateSanctionList(ctx, f.Name, f.Source, category, f.Description, path); err != nil {
+			return err
+		}
+	}
+
+	sanctions, err := ReadSanctions(path, f.Source, listID)
+	if err != nil {
+		return err
+	}
+	if err := repo.CreateSanctions(ctx, sanctions); err != nil {
+		return err
+	}
+
+	if err := repo.UpdateSanctionListCount(ctx, listID, len(sanctions)); err != nil {
+		return err
+	}
+	if err := repo.SetSanctionListChecksum(ctx, listID, checksum); err != nil {
+		return err
+	}
+
+	log.Printf("Loaded sanction list %q (%d records)", f.Name, len(sanctions))
+	res.ListsLoaded++
+	res.Records += len(sanctions)
+	return nil
+}
+
+func loadCustomerList(ctx context.Context, repo *repository.Repository, dir string, f CustomerListFixture, res *Result) error {
+	path, checksum, err := fixtureFile(dir, f.File)
+	if err != nil {
+		return err
+	}
+
+	existing, err := repo.GetCustomerListChecksum(ctx, f.Name)
+	if err != nil {
+		return err
+	}
+	if existing != nil && existing.Checksum == checksum {
+		res.ListsSkipped++
+		return nil
+	}
+
+	// The client reads customers from the CSV at screening time, so only
+	// the list and its record count are stored
+	count, err := countRecords(path)
+	if err != nil {
+		return err
+	}
+
+	var listID int64
+	if existing != nil {
+		listID = existing.ID
+		if err := repo.UpdateCustomerListFile(ctx, listID, path, count); err != nil {
+			return err
+		}
+	} else {
+		if listID, err = repo.CreateCustomerList(ctx, f.Name, f.Description, path, 0); err != nil {
+			return err
+		}
+		if err := repo.UpdateCustomerListRecordCount(ctx, listID, count); err != nil {
+			return err
+		}
+	}
+	if err := repo.SetCustomerListChecksum(ctx, listID, checksum); err != nil {
+		return err
+	}
+
+	log.Printf("Loaded customer list %q (%d records)", f.Name, count)
+	res.ListsLoaded++
+	res.Records += count
+	return nil
+}
+
+// ReadSanctions parses the sanctions CSV at path with ParseSanctions.
+// Fixtures carry no configuration, so their dates use the default order.
+func ReadSanctions(path, source string, listID int64) ([]*models.Sanction, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return ParseSanctions(file, source, listID, psiadapter.DefaultDateOrder)
+}
+
+// ParseSanctions parses a sanctions CSV with name, dob, country and
+// sanction_program (or program) columns, normalizing dates of birth to
+// YYYY-MM-DD (ambiguous ones in order unless the column tells) and hashing
+// each record canonically
+func ParseSanctions(r io.Reader, source string, listID int64, order psiadapter.DateOrder) ([]*models.Sanction, error) {
+	reader := csv.NewReader(r)
+	headers, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("read header: %w", err)
+	}
+	headerMap := make(map[string]int)
+	for i, h := range headers {
+		headerMap[strings.ToLower(strings.TrimSpace(h))] = i
+	}
+	getValue := func(record []string, col string) string {
+		if idx, ok := headerMap[col]; ok && idx < len(record) {
+			return strings.TrimSpace(record[idx])
+		}
+		return ""
+	}
+
+	var sanctions []*models.Sanction
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("read record: %w", err)
+		}
+
+		name := getValue(record, "name")
+		if name == "" {
+			continue
+		}
+		dob := getValue(record, "dob")
+		country := getValue(record, "country")
+		program := getValue(record, "sanction_program")
+		if program == "" {
+			program = getValue(record, "program")
+		}
+		sourceRef := getValue(record, "source_ref")
+		if sourceRef == "" {
+			sourceRef = getValue(record, "source_url")
+		}
+
+		entityType, ok := models.ParseEntityType(getValue(record, "entity_type"))
+		if !ok {
+			return nil, fmt.Errorf("%s: unknown entity type %q", name, getValue(record, "entity_type"))
+		}
+
+		sanction := &models.Sanction{
+			Name:         name,
+			DOB:          dob,
+			Country:      country,
+			Program:      program,
+			Source:       source,
+			ListID:       listID,
+			EntityType:   entityType,
+			Aliases:      models.ParseAliases(getValue(record, "aliases")),
+			IMONumber:    getValue(record, "imo_number"),
+			Registration: getValue(record, "registration"),
+			SourceRef:    sourceRef,
+		}
+		sanctions = append(sanctions, sanction)
+	}
+
+	psiadapter.NormalizeSanctionDOBs(sanctions, order)
+	for _, sanction := range sanctions {
+		sanction.Hash = psiadapter.RecordHash(sanction.EntityType, sanction.HashValues())
+		sanction.HashVersion = psiadapter.RecordHashVersion
+	}
+	return sanctions, nil
+}
+
+// fixtureFile resolves a manifest file reference and checksums its contents
+func fixtureFile(dir, name string) (string, string, error) {
+	path, err := filepath.Abs(filepath.Join(dir, name))
+	if err != nil {
+		return "", "", err
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return "", "", err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", "", err
+	}
+	return path, hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// countRecords counts the data rows of a CSV file
+func countRecords(path string) (int, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+
+	reader := csv.NewReader(f)
+	if _, err := reader.Read(); err != nil {
+		return 0, fmt.Errorf("read header: %w", err)
+	}
+	count := 0
+	for {
+		if _, err := reader.Read(); err == io.EOF {
+			break
+		} else if err != nil {
+			return 0, fmt.Errorf("read record: %w", err)
+		}
+		count++
+	}
+	return count, nil
+}
@@ -0,0 +1,98 @@
+package client
+
+import (
+	"io"
+	"net/http"
+	"sync"
+)
+
+// inProcessURL is the base URL of an in-process client's requests; only
+// their paths reach the server
+const inProcessURL = "http://in-process"
+
+// NewInProcessClient returns a client whose requests are served by handler,
+// normally psiserver.Server.Handler(), within this process instead of over
+// a network. Requests go through the server's whole middleware and handler
+// chain, so sessions behave exactly as over HTTP. Tests, the CLI and
+// single-process deployments use it.
+func NewInProcessClient(handler http.Handler) *PSIClient {
+	c := NewPSIClient(inProcessURL)
+	c.client.Transport = handlerTransport{handler}
+	return c
+}
+
+// handlerTransport is an http.RoundTripper that serves requests with a
+// handler. Response bodies are piped, so streamed responses work.
+type handlerTransport struct {
+	handler http.Handler
+}
+
+func (t handlerTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	sr := req.Clone(req.Context())
+	if sr.Body == nil {
+		sr.Body = http.NoBody
+	}
+	sr.RequestURI = req.URL.RequestURI()
+	// Session tokens are bound to the caller's host
+	sr.RemoteAddr = "127.0.0.1:0"
+
+	pr, pw := io.Pipe()
+	rw := &pipeResponseWriter{header: make(http.Header), body: pw, ready: make(chan struct{})}
+	go func() {
+		defer func() {
+			rw.WriteHeader(http.StatusOK) // In case the handler wrote nothing
+			pw.Close()
+		}()
+		t.handler.ServeHTTP(rw, sr)
+	}()
+
+	select {
+	case <-rw.ready:
+	case <-req.Context().Done():
+		pr.CloseWithError(req.Context().Err())
+		return nil, req.Context().Err()
+	}
+	return &http.Response{
+		Status:     http.StatusText(rw.status),
+		StatusCode: rw.status,
+		Proto:      "HTTP/1.1",
+		ProtoMajor: 1,
+		ProtoMinor: 1,
+		Header:     rw.sent,
+		Body:       pr,
+		Request:    req,
+	}, nil
+}
+
+// pipeResponseWriter hands a handler's response to RoundTrip: the header
+// once it is written, then the body through a pipe
+type pipeResponseWriter struct {
+	header http.Header
+	sent   http.Header // Snapshot of header when the response started
+	status int
+	body   *io.PipeWriter
+	once   sync.Once
+	ready  chan struct{}
+}
+
+func (w *pipeResponseWriter) Header() http.Header {
+	return w.header
+}
+
+func (w *pipeResponseWriter) WriteHeader(status int) {
+	w.once.Do(func() {
+		w.status = status
+		w.sent = w.header.Clone()
+		close(w.ready)
+	})
+}
+
+func (w *pipeResponseWriter) Write(p []byte) (int, error) {
+	w.WriteHeader(http.StatusOK)
+	return w.body.Write(p)
+}
+
+// Flush lets streaming handlers flush; writes already reach the reader
+func (w *pipeResponseWriter) Flush() {
+	w.WriteHeader(http.StatusOK)
+}
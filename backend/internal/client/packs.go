@@ -0,0 +1,92 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/SanthoshCheemala/FLARE/backend/internal/apierror"
+	"github.com/SanthoshCheemala/FLARE/backend/internal/models"
+	"github.com/gorilla/websocket"
+)
+
+// ListPacks returns the server's pack catalog
+func (c *PSIClient) ListPacks(ctx context.Context) ([]models.WatchlistPack, error) {
+	var result struct {
+		Packs []models.WatchlistPack `json:"packs"`
+	}
+	if err := c.getJSON(ctx, "/packs", &result); err != nil {
+		return nil, err
+	}
+	return result.Packs, nil
+}
+
+// GetPack returns one pack from the server's catalog
+func (c *PSIClient) GetPack(ctx context.Context, packID string) (*models.WatchlistPack, error) {
+	var pack models.WatchlistPack
+	if err := c.getJSON(ctx, "/packs/"+url.PathEscape(packID), &pack); err != nil {
+		return nil, err
+	}
+	return &pack, nil
+}
+
+func (c *PSIClient) getJSON(ctx context.Context, path string, out interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, "GET", c.serverURL+path, nil)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	resp, err := c.do(req)
+	if err != nil {
+		return fmt.Errorf("request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return apierror.FromResponse(resp)
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("failed to decode response: %w", err)
+	}
+	return nil
+}
+
+// WatchPack follows a pack's update stream, calling onUpdate with the pack's
+// current state and then each change. It returns nil once the pack is
+// deleted, and otherwise blocks until ctx ends or the connection fails.
+func (c *PSIClient) WatchPack(ctx context.Context, packID string, onUpdate func(models.PackUpdate)) error {
+	wsURL := "ws" + strings.TrimPrefix(c.serverURL, "http") + "/packs/" + url.PathEscape(packID) + "/updates"
+	header := http.Header{}
+	if c.apiKey != "" {
+		header.Set("X-API-Key", c.apiKey)
+	}
+
+	conn, resp, err := websocket.DefaultDialer.DialContext(ctx, wsURL, header)
+	if err != nil {
+		if resp != nil && resp.StatusCode != http.StatusSwitchingProtocols {
+			return apierror.FromResponse(resp)
+		}
+		return fmt.Errorf("connect to pack updates: %w", err)
+	}
+	defer conn.Close()
+
+	// Closing the connection unblocks the read when ctx ends
+	stop := context.AfterFunc(ctx, func() { conn.Close() })
+	defer stop()
+
+	for {
+		var u models.PackUpdate
+		if err := conn.ReadJSON(&u); err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			return fmt.Errorf("pack updates: %w", err)
+		}
+		onUpdate(u)
+		if u.Event == models.PackEventDeleted {
+			return nil
+		}
+	}
+}
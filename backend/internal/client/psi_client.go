@@ -1,22 +1,47 @@
-// Package client implements the PSI client.
+// Package client implements the HTTP transport of the PSI protocol, and the
+// client side of the server's list and pack endpoints.
 package client
 
 import (
 	"bytes"
 	"context"
+	"crypto/sha256"
 	"encoding/json"
 	"fmt"
 	"io"
+	"math"
 	"net/http"
+	"net/url"
+	"strconv"
+	"sync"
 	"time"
 
+	"github.com/SanthoshCheemala/FLARE/backend/internal/apierror"
+	"github.com/SanthoshCheemala/FLARE/backend/internal/auth"
 	"github.com/SanthoshCheemala/FLARE/backend/internal/models"
+	"github.com/SanthoshCheemala/FLARE/backend/internal/protocol"
 	"github.com/SanthoshCheemala/FLARE/backend/internal/psiadapter"
 )
 
+// PSIClient talks to the PSI server over HTTP. It is a protocol.Transport:
+// protocol.Session drives the exchange through it.
 type PSIClient struct {
 	serverURL string
 	client    *http.Client
+
+	apiKey string // Sent as X-API-Key when the server requires API keys
+
+	// maxRequestBody, if set, bounds intersect request bodies; larger
+	// batches are split across requests
+	maxRequestBody func(context.Context) (int, error)
+
+	mu          sync.Mutex
+	tokens      map[string]string // sessionID -> access token issued at init
+	signingKeys map[string]string // sessionID -> request signing key issued at init
+	// maxCiphertexts and maxIntersectBody are the server's caps on intersect
+	// requests, read from its capabilities at each init; 0 if none
+	maxCiphertexts   int
+	maxIntersectBody int64
 }
 
 func NewPSIClient(serverURL string) *PSIClient {
@@ -25,94 +50,242 @@ func NewPSIClient(serverURL string) *PSIClient {
 		client: &http.Client{
 			Timeout: 5 * time.Minute, // Long timeout for PSI operations
 		},
+		tokens:      make(map[string]string),
+		signingKeys: make(map[string]string),
 	}
 }
 
-type InitSessionRequest struct {
-	SanctionListIDs []string `json:"sanctionListIds"`
-	EnabledColumns  []string `json:"enabledColumns"`
+// SetAPIKey sets the API key presented to the PSI server on every request
+func (c *PSIClient) SetAPIKey(key string) {
+	c.apiKey = key
+}
+
+// do sends req with the client's API key
+func (c *PSIClient) do(req *http.Request) (*http.Response, error) {
+	if c.apiKey != "" {
+		req.Header.Set("X-API-Key", c.apiKey)
+	}
+	return c.client.Do(req)
 }
 
-type InitSessionResponse struct {
-	SessionID string                             `json:"sessionId"`
-	Params    *psiadapter.SerializedServerParams `json:"params"`
+// authorize attaches the session's access token to req
+func (c *PSIClient) authorize(req *http.Request, sessionID string) {
+	c.mu.Lock()
+	token := c.tokens[sessionID]
+	c.mu.Unlock()
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
 }
 
-func (c *PSIClient) InitSession(ctx context.Context, sanctionListIDs []string, enabledColumns []string) (string, *psiadapter.SerializedServerParams, error) {
-	reqBody := InitSessionRequest{
-		SanctionListIDs: sanctionListIDs,
-		EnabledColumns:  enabledColumns,
+// sign adds a timestamp, fresh nonce and signature over the body, which
+// writeBody writes, to req when the server issued a signing key for the
+// session. The body is hashed as it is written rather than buffered.
+func (c *PSIClient) sign(req *http.Request, sessionID string, writeBody func(io.Writer) error) error {
+	c.mu.Lock()
+	key := c.signingKeys[sessionID]
+	c.mu.Unlock()
+	if key == "" {
+		return nil // Older servers do not sign
+	}
+
+	nonce, err := auth.NewNonce()
+	if err != nil {
+		return err
+	}
+	bodySum := sha256.New()
+	if err := writeBody(bodySum); err != nil {
+		return err
 	}
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	req.Header.Set(auth.HeaderTimestamp, timestamp)
+	req.Header.Set(auth.HeaderNonce, nonce)
+	req.Header.Set(auth.HeaderSignature, auth.SignDigest(key, req.Method, req.URL.Path, timestamp, nonce, bodySum.Sum(nil)))
+	return nil
+}
+
+// InitSession opens a session and keeps the access token and signing key
+// the server issued for it
+func (c *PSIClient) InitSession(ctx context.Context, reqBody *protocol.InitSessionRequest) (*protocol.InitSessionResponse, error) {
 	jsonBody, err := json.Marshal(reqBody)
 	if err != nil {
-		return "", nil, fmt.Errorf("failed to marshal request: %w", err)
+		return nil, fmt.Errorf("failed to marshal request: %w", err)
 	}
 
 	req, err := http.NewRequestWithContext(ctx, "POST", c.serverURL+"/session/init", bytes.NewBuffer(jsonBody))
 	if err != nil {
-		return "", nil, fmt.Errorf("failed to create request: %w", err)
+		return nil, fmt.Errorf("failed to create request: %w", err)
 	}
 	req.Header.Set("Content-Type", "application/json")
 
-	resp, err := c.client.Do(req)
+	resp, err := c.do(req)
 	if err != nil {
-		return "", nil, fmt.Errorf("request failed: %w", err)
+		return nil, fmt.Errorf("request failed: %w", err)
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		return "", nil, fmt.Errorf("server returned status %d", resp.StatusCode)
+		return nil, apierror.FromResponse(resp)
 	}
 
-	var initResp InitSessionResponse
+	var initResp protocol.InitSessionResponse
 	if err := json.NewDecoder(resp.Body).Decode(&initResp); err != nil {
-		return "", nil, fmt.Errorf("failed to decode response: %w", err)
+		return nil, fmt.Errorf("failed to decode response: %w", err)
 	}
 
-	return initResp.SessionID, initResp.Params, nil
+	c.mu.Lock()
+	c.tokens[initResp.SessionID] = initResp.Token
+	c.signingKeys[initResp.SessionID] = initResp.SigningKey
+	c.mu.Unlock()
+
+	// Servers that predate capability discovery cap nothing
+	if caps, err := c.Capabilities(ctx); err == nil {
+		c.mu.Lock()
+		c.maxCiphertexts = caps.Limits.MaxCiphertextsPerRequest
+		c.maxIntersectBody = caps.Limits.MaxIntersectBodyBytes
+		c.mu.Unlock()
+	}
+	return &initResp, nil
 }
 
-type IntersectRequest struct {
-	SessionID   string                        `json:"sessionId"`
-	Ciphertexts []psiadapter.ClientCiphertext `json:"ciphertexts"`
+// Capabilities fetches what the server supports and the limits it applies
+// to this client
+func (c *PSIClient) Capabilities(ctx context.Context) (*protocol.Capabilities, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", c.serverURL+"/capabilities", nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	resp, err := c.do(req)
+	if err != nil {
+		return nil, fmt.Errorf("request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, apierror.FromResponse(resp)
+	}
+
+	var caps protocol.Capabilities
+	if err := json.NewDecoder(resp.Body).Decode(&caps); err != nil {
+		return nil, fmt.Errorf("failed to decode response: %w", err)
+	}
+	return &caps, nil
 }
 
-type IntersectResponse struct {
-	Matches []uint64 `json:"matches"`
+// Intersect sends a batch of ciphertexts to the Server. The request body is
+// streamed, one ciphertext at a time, instead of being marshaled whole.
+func (c *PSIClient) Intersect(ctx context.Context, reqBody *protocol.IntersectRequest) (*protocol.IntersectResponse, error) {
+	c.mu.Lock()
+	maxBody, maxCiphertexts := int(min(c.maxIntersectBody, math.MaxInt)), c.maxCiphertexts
+	c.mu.Unlock()
+	if c.maxRequestBody != nil {
+		limit, err := c.maxRequestBody(ctx)
+		if err != nil {
+			return nil, err
+		}
+		if maxBody == 0 || limit < maxBody {
+			maxBody = limit
+		}
+	}
+	if maxBody > 0 || (maxCiphertexts > 0 && len(reqBody.Ciphertexts) > maxCiphertexts) {
+		return c.intersectSplit(ctx, reqBody, maxBody, maxCiphertexts)
+	}
+	return c.intersect(ctx, reqBody)
 }
 
-func (c *PSIClient) Intersect(ctx context.Context, sessionID string, ciphertexts []psiadapter.ClientCiphertext) ([]uint64, error) {
-	reqBody := IntersectRequest{
-		SessionID:   sessionID,
-		Ciphertexts: ciphertexts,
+// intersectSplit sends a batch in as many requests as the body limit and
+// the ciphertext cap, where not 0, require. The server adds each request's
+// matches to the session.
+func (c *PSIClient) intersectSplit(ctx context.Context, reqBody *protocol.IntersectRequest, maxBody, maxCiphertexts int) (*protocol.IntersectResponse, error) {
+	limit := maxBody
+	if limit == 0 {
+		limit = math.MaxInt
 	}
-	jsonBody, err := json.Marshal(reqBody)
-	if err != nil {
-		return nil, fmt.Errorf("failed to marshal request: %w", err)
+	var empty bytes.Buffer
+	if err := protocol.WriteIntersectRequest(&empty, &protocol.IntersectRequest{SessionID: reqBody.SessionID, Batch: reqBody.Batch}); err != nil {
+		return nil, err
 	}
 
-	req, err := http.NewRequestWithContext(ctx, "POST", c.serverURL+"/session/intersect", bytes.NewBuffer(jsonBody))
+	merged := &protocol.IntersectResponse{}
+	send := func(ciphertexts []psiadapter.ClientCiphertext) error {
+		resp, err := c.intersect(ctx, &protocol.IntersectRequest{SessionID: reqBody.SessionID, Batch: reqBody.Batch, Ciphertexts: ciphertexts})
+		if err != nil {
+			return err
+		}
+		merged.Matches = append(merged.Matches, resp.Matches...)
+		merged.DurationMs += resp.DurationMs
+		return nil
+	}
+	start, size := 0, empty.Len()
+	for i, ct := range reqBody.Ciphertexts {
+		n := 0
+		if maxBody > 0 {
+			encoded, err := json.Marshal(ct)
+			if err != nil {
+				return nil, err
+			}
+			n = len(encoded) + 2 // Separator and newline
+			if empty.Len()+n > limit {
+				return nil, fmt.Errorf("a ciphertext of %d bytes exceeds the %d byte request limit", len(encoded), limit)
+			}
+		}
+		if size+n > limit || (maxCiphertexts > 0 && i-start == maxCiphertexts) {
+			if err := send(reqBody.Ciphertexts[start:i]); err != nil {
+				return nil, err
+			}
+			start, size = i, empty.Len()
+		}
+		size += n
+	}
+	if start < len(reqBody.Ciphertexts) || len(reqBody.Ciphertexts) == 0 {
+		if err := send(reqBody.Ciphertexts[start:]); err != nil {
+			return nil, err
+		}
+	}
+	return merged, nil
+}
+
+func (c *PSIClient) intersect(ctx context.Context, reqBody *protocol.IntersectRequest) (*protocol.IntersectResponse, error) {
+	writeBody := func(w io.Writer) error {
+		return protocol.WriteIntersectRequest(w, reqBody)
+	}
+	body := func() (io.ReadCloser, error) {
+		pr, pw := io.Pipe()
+		go func() { pw.CloseWithError(writeBody(pw)) }()
+		return pr, nil
+	}
+	bodyReader, _ := body()
+
+	req, err := http.NewRequestWithContext(ctx, "POST", c.serverURL+"/session/intersect", bodyReader)
 	if err != nil {
+		bodyReader.Close()
 		return nil, fmt.Errorf("failed to create request: %w", err)
 	}
+	// Lets the transport send the body again, e.g. on a redirect
+	req.GetBody = body
 	req.Header.Set("Content-Type", "application/json")
+	c.authorize(req, reqBody.SessionID)
+	if err := c.sign(req, reqBody.SessionID, writeBody); err != nil {
+		bodyReader.Close()
+		return nil, fmt.Errorf("failed to sign request: %w", err)
+	}
 
-	resp, err := c.client.Do(req)
+	resp, err := c.do(req)
 	if err != nil {
 		return nil, fmt.Errorf("request failed: %w", err)
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("server returned status %d", resp.StatusCode)
+		return nil, apierror.FromResponse(resp)
 	}
 
-	var intersectResp IntersectResponse
+	var intersectResp protocol.IntersectResponse
 	if err := json.NewDecoder(resp.Body).Decode(&intersectResp); err != nil {
 		return nil, fmt.Errorf("failed to decode response: %w", err)
 	}
-
-	return intersectResp.Matches, nil
+	return &intersectResp, nil
 }
 
 type SanctionList struct {
@@ -122,30 +295,62 @@ type SanctionList struct {
 	Source      string `json:"source"`
 	RecordCount int    `json:"recordCount"`
 	CreatedAt   string `json:"createdAt"`
+
+	Schema []models.ListField `json:"schema,omitempty"` // Custom fields sessions may enable
+}
+
+// sanctionListPageSize is the page size used when fetching every list
+const sanctionListPageSize = 500
+
+type SanctionListPage struct {
+	Lists  []SanctionList `json:"lists"`
+	Total  int            `json:"total"`
+	Limit  int            `json:"limit"`
+	Offset int            `json:"offset"`
 }
 
+// GetSanctionLists fetches every sanction list from the server, page by page
 func (c *PSIClient) GetSanctionLists(ctx context.Context) ([]SanctionList, error) {
-	req, err := http.NewRequestWithContext(ctx, "GET", c.serverURL+"/lists/sanctions", nil)
+	lists := make([]SanctionList, 0)
+	for {
+		page, err := c.ListSanctionLists(ctx, url.Values{
+			"limit":  {strconv.Itoa(sanctionListPageSize)},
+			"offset": {strconv.Itoa(len(lists))},
+		})
+		if err != nil {
+			return nil, err
+		}
+		lists = append(lists, page.Lists...)
+		if len(page.Lists) == 0 || len(lists) >= page.Total {
+			return lists, nil
+		}
+	}
+}
+
+// ListSanctionLists fetches one page of sanction lists; params are passed
+// through to the server (limit, offset, sort, q, source, created_after, created_before)
+func (c *PSIClient) ListSanctionLists(ctx context.Context, params url.Values) (*SanctionListPage, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", c.serverURL+"/lists/sanctions?"+params.Encode(), nil)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create request: %w", err)
 	}
 
-	resp, err := c.client.Do(req)
+	resp, err := c.do(req)
 	if err != nil {
 		return nil, fmt.Errorf("request failed: %w", err)
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("server returned status %d", resp.StatusCode)
+		return nil, apierror.FromResponse(resp)
 	}
 
-	var lists []SanctionList
-	if err := json.NewDecoder(resp.Body).Decode(&lists); err != nil {
+	var page SanctionListPage
+	if err := json.NewDecoder(resp.Body).Decode(&page); err != nil {
 		return nil, fmt.Errorf("failed to decode response: %w", err)
 	}
 
-	return lists, nil
+	return &page, nil
 }
 
 func (c *PSIClient) DeleteSanctionList(ctx context.Context, id int64) error {
@@ -154,79 +359,93 @@ func (c *PSIClient) DeleteSanctionList(ctx context.Context, id int64) error {
 		return fmt.Errorf("failed to create request: %w", err)
 	}
 
-	resp, err := c.client.Do(req)
+	resp, err := c.do(req)
 	if err != nil {
 		return fmt.Errorf("request failed: %w", err)
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("server returned status %d", resp.StatusCode)
+		return apierror.FromResponse(resp)
 	}
 
 	return nil
 }
 
-// ResolveSanctions fetches full sanction details for matched hashes from the Server
-func (c *PSIClient) ResolveSanctions(ctx context.Context, sessionID string, hashes []uint64) ([]*models.Sanction, error) {
-	// Convert hashes to int64 for JSON compatibility with server
-	hashesInt64 := make([]int64, len(hashes))
-	for i, h := range hashes {
-		hashesInt64[i] = int64(h)
-	}
-
-	reqBody, err := json.Marshal(map[string]interface{}{
-		"hashes": hashesInt64,
-	})
+// Resolve fetches full sanction details for matched hashes from the Server
+func (c *PSIClient) Resolve(ctx context.Context, sessionID string, reqBody *protocol.ResolveRequest) (*protocol.ResolveResponse, error) {
+	jsonBody, err := json.Marshal(reqBody)
 	if err != nil {
 		return nil, fmt.Errorf("failed to marshal request: %w", err)
 	}
 
 	url := fmt.Sprintf("%s/session/%s/resolve", c.serverURL, sessionID)
-	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewReader(reqBody))
+	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewReader(jsonBody))
 	if err != nil {
 		return nil, fmt.Errorf("failed to create request: %w", err)
 	}
 	req.Header.Set("Content-Type", "application/json")
+	c.authorize(req, sessionID)
 
-	resp, err := c.client.Do(req)
+	resp, err := c.do(req)
 	if err != nil {
 		return nil, fmt.Errorf("request failed: %w", err)
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(resp.Body)
-		return nil, fmt.Errorf("server returned status %d: %s", resp.StatusCode, string(body))
+		return nil, apierror.FromResponse(resp)
 	}
 
-	var result struct {
-		Sanctions []struct {
-			Hash    int64  `json:"hash"`
-			Name    string `json:"name"`
-			DOB     string `json:"dob"`
-			Country string `json:"country"`
-			Program string `json:"program"`
-			Source  string `json:"source"`
-		} `json:"sanctions"`
-	}
+	var result protocol.ResolveResponse
 	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
 		return nil, fmt.Errorf("failed to decode response: %w", err)
 	}
+	return &result, nil
+}
 
-	// Convert to Sanction models
-	sanctions := make([]*models.Sanction, len(result.Sanctions))
-	for i, s := range result.Sanctions {
-		sanctions[i] = &models.Sanction{
-			Hash:    s.Hash,
-			Name:    s.Name,
-			DOB:     s.DOB,
-			Country: s.Country,
-			Program: s.Program,
-			Source:  s.Source,
-			ListID:  0, // These are fetched from remote, no local list ID
-		}
+// CloseSession ends the session on the Server, revoking its access token
+func (c *PSIClient) CloseSession(ctx context.Context, sessionID string) error {
+	req, err := http.NewRequestWithContext(ctx, "DELETE", fmt.Sprintf("%s/session/%s", c.serverURL, sessionID), nil)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
 	}
+	c.authorize(req, sessionID)
+
+	c.mu.Lock()
+	delete(c.tokens, sessionID)
+	delete(c.signingKeys, sessionID)
+	c.mu.Unlock()
 
-	return sanctions, nil
+	resp, err := c.do(req)
+	if err != nil {
+		return fmt.Errorf("request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return apierror.FromResponse(resp)
+	}
+
+	return nil
+}
+
+// Ping tells the Server the session is still in use
+func (c *PSIClient) Ping(ctx context.Context, sessionID string) error {
+	req, err := http.NewRequestWithContext(ctx, "GET", fmt.Sprintf("%s/session/%s/ping", c.serverURL, sessionID), nil)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	c.authorize(req, sessionID)
+
+	resp, err := c.do(req)
+	if err != nil {
+		return fmt.Errorf("request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return apierror.FromResponse(resp)
+	}
+	return nil
 }
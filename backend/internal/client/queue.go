@@ -0,0 +1,26 @@
+package client
+
+import (
+	"time"
+
+	"github.com/SanthoshCheemala/FLARE/backend/internal/mq"
+)
+
+// queueBaseURL is the base URL of a queue client's requests; only their
+// paths reach the server
+const queueBaseURL = "http://mq"
+
+// NewQueueClient returns a client whose session requests travel over the
+// NATS queue at natsURL, as messages on subject, instead of HTTP
+// connections. The PSI server consumes them at its own pace and answers on
+// each request's reply inbox; timeout bounds the wait for one answer.
+// Intersect batches are split to fit the queue's message size. Only the
+// session exchange is served over the queue.
+func NewQueueClient(natsURL, subject string, timeout time.Duration) *PSIClient {
+	rt := &mq.RoundTripper{URL: natsURL, Name: "flare-client", Subject: subject, Timeout: timeout}
+	c := NewPSIClient(queueBaseURL)
+	c.client.Transport = rt
+	c.client.Timeout = 0 // The queue bounds each request
+	c.maxRequestBody = rt.MaxBody
+	return c
+}
@@ -1,18 +1,37 @@
 package config
 
 import (
-	"os"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
 	"strconv"
 	"strings"
 	"time"
+
+	"github.com/SanthoshCheemala/FLARE/backend/internal/atrest"
+	"github.com/SanthoshCheemala/FLARE/backend/internal/secrets"
 )
 
 type Config struct {
+	Env      string // APP_ENV: development, staging or production
+	Log      LogConfig
 	Server   ServerConfig
 	Database DatabaseConfig
 	JWT      JWTConfig
 	PSI      PSIConfig
 	Redis    RedisConfig
+	Export   ExportConfig
+	Storage  StorageConfig
+	Upload   UploadConfig
+	Review   ReviewConfig
+
+	values map[string]string // Resolved raw settings, compared on reload
+}
+
+// LogConfig controls log output. Level can be changed by a reload.
+type LogConfig struct {
+	Level string // debug, info, warn or error
 }
 
 type ServerConfig struct {
@@ -21,27 +40,155 @@ type ServerConfig struct {
 	ReadTimeout     time.Duration
 	WriteTimeout    time.Duration
 	ShutdownTimeout time.Duration
+	AdminToken      string // Bearer token for the PSI server admin API; empty disables it
+
+	Compression      bool   // Gzip responses for clients that accept it
+	CompressionLevel int    // Gzip level, 1 (fastest) to 9 (smallest)
+	H2C              bool   // Serve HTTP/2 without TLS (h2c) alongside HTTP/1.1
+	TLSCertFile      string // With TLSKeyFile, serves HTTPS, which negotiates HTTP/2
+	TLSKeyFile       string
+
+	// Default limit of request bodies in KB; 0 leaves them unlimited.
+	// Uploads, comments and PSI intersect requests have larger built-in
+	// limits.
+	MaxBodyKB int
+	// Per-route limits in KB overriding MaxBodyKB and the built-in ones,
+	// e.g. "/session/intersect=1048576,/auth/login=16"; see BodyLimitRoutes
+	BodyLimits string
 }
 
 type DatabaseConfig struct {
 	Driver   string // sqlite or postgres
 	DSN      string // Database connection string
 	MaxConns int
+	// QueryTimeout bounds each statement run outside a transaction, so a
+	// stalled database fails requests and screenings instead of hanging them
+	QueryTimeout time.Duration
 }
 
 type JWTConfig struct {
 	AccessSecret  string
 	RefreshSecret string
+	SessionSecret string // Signs PSI session tokens issued by the server
 	AccessExpiry  time.Duration
 	RefreshExpiry time.Duration
+	SessionExpiry time.Duration
 	Issuer        string
 }
 
 type PSIConfig struct {
-	TreeDBPath    string
-	MaxRAMGB      float64
-	MaxWorkers    int
-	MaxScreenings int
+	TreeDBPath       string
+	MaxRAMGB         float64
+	MaxWorkers       int
+	MaxScreenings    int
+	ResolveRateLimit int           // Resolve requests allowed per client per minute
+	JobRetention     time.Duration // How long finished jobs stay in memory
+	RequireAPIKey    bool          // PSI server: require X-API-Key on session endpoints
+	ServerAPIKey     string        // Client: API key presented to the PSI server
+	ServerURL        string        // Client: base URL of the PSI server
+	ResidentBatches  int           // PSI server: batch contexts kept in memory; 0 keeps all
+	TreeWorkers      int           // PSI server: batch trees built in parallel; 0 uses GOMAXPROCS
+	TreeBusyTimeout  time.Duration // PSI server: how long to retry an intersection on a locked tree
+	TreeReopens      int           // PSI server: times a tree whose handle went stale is reopened
+	// PSI server: sessions without a request for this long are expired and
+	// their dynamic trees removed. Clients ping while they encrypt.
+	SessionIdleTimeout time.Duration
+	// PSI server: reject intersect requests without a valid signature,
+	// timestamp and fresh nonce even for sessions without a signing key.
+	// Sessions issued one at init require it regardless.
+	RequireSignedRequests bool
+	SignatureMaxSkew      time.Duration // PSI server: accepted request timestamp drift
+	// PSI server: differential privacy budget per released count for
+	// shared aggregate statistics; 0 reports exact numbers
+	StatsEpsilon float64
+	// PSI server: matches one session contributes to a noised match count
+	// at most. One customer record can match several entries (aliases,
+	// documents, batches), so the cap, not 1, is the count's sensitivity.
+	StatsMatchCap int
+	// PSI server: intersect requests (and errors) one session contributes
+	// to a noised count at most, and so that count's sensitivity
+	StatsIntersectionCap int
+	// Scripts romanized in names before hashing, e.g. "cyrillic,greek" or
+	// "all"; see package translit. The client requests it for its
+	// screenings and the PSI server builds its global tree with it.
+	Transliteration string
+	// PSI server: hash for set elements, "sha256" (unkeyed, what older
+	// clients speak), "siphash" or "blake2b" (keyed with a per-session salt)
+	HashAlgorithm string
+	// Day and month order, "DMY" or "MDY", of numeric dates of birth with
+	// the year last in lists where no value tells (see
+	// psiadapter.DetectDateOrder). Both sides normalize dates with it.
+	DateOrder string
+	// Client: how long a StartScreening Idempotency-Key returns the job
+	// it first started
+	IdempotencyWindow time.Duration
+	// Client: how long a screening that failed after encryption can be
+	// resumed with POST /screenings/{jobId}/retry
+	RetryRetention time.Duration
+	// Client: size of the on-disk cache of encrypted customer lists reused
+	// across screenings; 0 disables it
+	CiphertextCacheMB int
+	// PSI server: ciphertexts accepted in one intersect request, which also
+	// sizes the intersect body limit; 0 caps neither. Clients split larger
+	// batches across requests.
+	MaxCiphertextsPerRequest int
+	// PSI server: ciphertexts a session must have intersected before it may
+	// resolve matches, so single records cannot be probed; 0 disables the
+	// check. API key quotas can override it; sessions without a key get the
+	// strictest of it and every key's.
+	MinResolveCiphertexts int
+	// PSI server: session quota of each client address calling without an
+	// API key: sessions started per UTC day and live at once; 0 is unlimited
+	AnonymousSessionsPerDay     int
+	AnonymousConcurrentSessions int
+	// NATS URL, nats://[user:pass@]host:port, of the queue for asynchronous
+	// screening; empty disables it. The client then sends its session
+	// requests through the queue, and the PSI server consumes them
+	// alongside HTTP.
+	QueueURL     string
+	QueueSubject string        // Subject session requests are published on
+	QueueWorkers int           // PSI server: queued requests handled at a time
+	QueueTimeout time.Duration // Client: how long one queued request may wait for its answer
+	// Client: what is kept of the sanction records the PSI server resolves
+	// for matches, "full", "redacted" or "reference"; see
+	// models.RetentionFull
+	SanctionRetention string
+	// Client: days after which kept sanction records are reduced to
+	// references; 0 keeps them as SanctionRetention stored them
+	SanctionRetentionDays int
+}
+
+// ExportConfig configures delivery of confirmed matches to external systems.
+// A connector is enabled when its URL is set.
+type ExportConfig struct {
+	WebhookURL string // Generic JSON webhook
+	STIXURL    string // Endpoint accepting STIX 2.1 bundles
+	AuthToken  string // Sent as a Bearer token to both endpoints
+	MaxRetries int
+	QueueSize  int
+}
+
+// ReviewConfig configures how match dispositions are reviewed
+type ReviewConfig struct {
+	// FourEyes makes a CONFIRMED disposition wait for a second user with
+	// the approver role before it takes effect or is exported
+	FourEyes bool
+	// SuppressionDays is how long a false positive marked for suppression
+	// is suppressed when the request gives no expiry
+	SuppressionDays int
+}
+
+// StorageConfig configures at-rest encryption of files written to disk
+type StorageConfig struct {
+	EncryptionKey string // Base64-encoded 32-byte AES-256 key; empty stores plaintext
+}
+
+// UploadConfig configures the checks uploaded list files pass before they
+// are stored; see package uploadscan
+type UploadConfig struct {
+	MaxMB       int           // Largest accepted file
+	ClamAVAddr  string        // clamd address, host:port or a unix socket path; empty skips virus scanning
+	ScanTimeout time.Duration // How long a virus scan may take
 }
 
 type RedisConfig struct {
@@ -52,84 +199,261 @@ type RedisConfig struct {
 	DB       int
 }
 
+// Load reads the configuration from FLARE_CONFIG or ./flare.yaml, if
+// present, and the environment
 func Load() (*Config, error) {
-	return &Config{
+	return LoadFrom(Options{})
+}
+
+// LoadFrom reads the configuration from the sources in opts and validates it
+func LoadFrom(opts Options) (*Config, error) {
+	l, err := newLoader(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	cfg := &Config{
+		Env: l.str("APP_ENV", "development"),
+		Log: LogConfig{
+			Level: l.str("LOG_LEVEL", "info"),
+		},
 		Server: ServerConfig{
-			Port:            getEnv("SERVER_PORT", "8080"),
-			Host:            getEnv("SERVER_HOST", "0.0.0.0"),
-			ReadTimeout:     getDurationEnv("SERVER_READ_TIMEOUT", 15*time.Second),
-			WriteTimeout:    getDurationEnv("SERVER_WRITE_TIMEOUT", 15*time.Second),
-			ShutdownTimeout: getDurationEnv("SERVER_SHUTDOWN_TIMEOUT", 10*time.Second),
+			Port:            l.str("SERVER_PORT", "8080"),
+			Host:            l.str("SERVER_HOST", "0.0.0.0"),
+			ReadTimeout:     l.duration("SERVER_READ_TIMEOUT", 15*time.Second),
+			WriteTimeout:    l.duration("SERVER_WRITE_TIMEOUT", 15*time.Second),
+			ShutdownTimeout: l.duration("SERVER_SHUTDOWN_TIMEOUT", 10*time.Second),
+			AdminToken:      l.str("ADMIN_TOKEN", ""),
+
+			Compression:      l.bool("SERVER_COMPRESSION", true),
+			CompressionLevel: l.int("SERVER_COMPRESSION_LEVEL", 5),
+			H2C:              l.bool("SERVER_H2C", false),
+			TLSCertFile:      l.str("SERVER_TLS_CERT", ""),
+			TLSKeyFile:       l.str("SERVER_TLS_KEY", ""),
+			MaxBodyKB:        l.int("SERVER_MAX_BODY_KB", 1024),
+			BodyLimits:       l.str("SERVER_BODY_LIMITS", ""),
 		},
 		Database: DatabaseConfig{
-			Driver:   getEnv("DB_DRIVER", "sqlite3"),
-			DSN:      getEnv("DB_DSN", "./data/flare.db"),
-			MaxConns: getIntEnv("DB_MAX_CONNS", 25),
+			Driver:       l.str("DB_DRIVER", "sqlite3"),
+			DSN:          l.str("DB_DSN", "./data/flare.db"),
+			MaxConns:     l.int("DB_MAX_CONNS", 25),
+			QueryTimeout: l.duration("DB_QUERY_TIMEOUT", 30*time.Second),
 		},
 		JWT: JWTConfig{
-			AccessSecret:  getEnv("JWT_ACCESS_SECRET", "change-this-secret"),
-			RefreshSecret: getEnv("JWT_REFRESH_SECRET", "change-this-refresh-secret"),
-			AccessExpiry:  getDurationEnv("JWT_ACCESS_EXPIRY", 15*time.Minute),
-			RefreshExpiry: getDurationEnv("JWT_REFRESH_EXPIRY", 7*24*time.Hour),
-			Issuer:        getEnv("JWT_ISSUER", "flare-api"),
+			AccessSecret:  l.str("JWT_ACCESS_SECRET", "change-this-secret"),
+			RefreshSecret: l.str("JWT_REFRESH_SECRET", "change-this-refresh-secret"),
+			SessionSecret: l.str("JWT_SESSION_SECRET", "change-this-session-secret"),
+			AccessExpiry:  l.duration("JWT_ACCESS_EXPIRY", 15*time.Minute),
+			RefreshExpiry: l.duration("JWT_REFRESH_EXPIRY", 7*24*time.Hour),
+			SessionExpiry: l.duration("JWT_SESSION_EXPIRY", 30*time.Minute),
+			Issuer:        l.str("JWT_ISSUER", "flare-api"),
 		},
 		PSI: PSIConfig{
-			TreeDBPath:    getEnv("PSI_TREE_PATH", "./data/trees"),
-			MaxRAMGB:      getFloatEnv("PSI_MAX_RAM_GB", 16.0),
-			MaxWorkers:    getIntEnv("PSI_MAX_WORKERS", 0), // 0 = auto
-			MaxScreenings: getIntEnv("PSI_MAX_CONCURRENT_SCREENINGS", 2),
+			TreeDBPath:       l.str("PSI_TREE_PATH", "./data/trees"),
+			MaxRAMGB:         l.float("PSI_MAX_RAM_GB", 16.0),
+			MaxWorkers:       l.int("PSI_MAX_WORKERS", 0), // 0 = auto
+			MaxScreenings:    l.int("PSI_MAX_CONCURRENT_SCREENINGS", 2),
+			ResolveRateLimit: l.int("PSI_RESOLVE_RATE_LIMIT", 30),
+			JobRetention:     l.duration("PSI_JOB_RETENTION", time.Hour),
+			RequireAPIKey:    l.bool("PSI_REQUIRE_API_KEY", false),
+			ServerAPIKey:     l.str("PSI_SERVER_API_KEY", ""),
+			ServerURL:        l.str("PSI_SERVER_URL", "http://localhost:8081"),
+			ResidentBatches:  l.int("PSI_RESIDENT_BATCHES", 2),
+			TreeWorkers:      l.int("PSI_TREE_WORKERS", 0),
+			TreeBusyTimeout:  l.duration("PSI_TREE_BUSY_TIMEOUT", 5*time.Second),
+			TreeReopens:      l.int("PSI_TREE_REOPENS", 2),
+
+			SessionIdleTimeout: l.duration("PSI_SESSION_IDLE_TIMEOUT", 5*time.Minute),
+
+			RequireSignedRequests: l.bool("PSI_REQUIRE_SIGNED_REQUESTS", true),
+			SignatureMaxSkew:      l.duration("PSI_SIGNATURE_MAX_SKEW", 5*time.Minute),
+			StatsEpsilon:          l.float("PSI_STATS_EPSILON", 0),
+			StatsMatchCap:         l.int("PSI_STATS_MATCH_CAP", 10),
+			StatsIntersectionCap:  l.int("PSI_STATS_INTERSECTION_CAP", 10),
+			Transliteration:       l.str("PSI_TRANSLITERATION", ""),
+			HashAlgorithm:         l.str("PSI_HASH_ALGORITHM", "sha256"),
+			DateOrder:             l.str("PSI_DATE_ORDER", "DMY"),
+			IdempotencyWindow:     l.duration("PSI_IDEMPOTENCY_WINDOW", 24*time.Hour),
+			RetryRetention:        l.duration("PSI_RETRY_RETENTION", 24*time.Hour),
+			CiphertextCacheMB:     l.int("PSI_CIPHERTEXT_CACHE_MB", 2048),
+			QueueURL:              l.str("PSI_QUEUE_URL", ""),
+			QueueSubject:          l.str("PSI_QUEUE_SUBJECT", "flare.psi"),
+			QueueWorkers:          l.int("PSI_QUEUE_WORKERS", 2),
+			QueueTimeout:          l.duration("PSI_QUEUE_TIMEOUT", 30*time.Minute),
+			SanctionRetention:     l.str("PSI_SANCTION_RETENTION", "full"),
+			SanctionRetentionDays: l.int("PSI_SANCTION_RETENTION_DAYS", 0),
+
+			MaxCiphertextsPerRequest: l.int("PSI_MAX_CIPHERTEXTS_PER_REQUEST", 5000),
+			MinResolveCiphertexts:    l.int("PSI_MIN_RESOLVE_CIPHERTEXTS", 100),
+
+			AnonymousSessionsPerDay:     l.int("PSI_ANONYMOUS_SESSIONS_PER_DAY", 20),
+			AnonymousConcurrentSessions: l.int("PSI_ANONYMOUS_CONCURRENT_SESSIONS", 2),
 		},
 		Redis: RedisConfig{
-			Enabled:  getBoolEnv("REDIS_ENABLED", false),
-			Host:     getEnv("REDIS_HOST", "localhost"),
-			Port:     getEnv("REDIS_PORT", "6379"),
-			Password: getEnv("REDIS_PASSWORD", ""),
-			DB:       getIntEnv("REDIS_DB", 0),
+			Enabled:  l.bool("REDIS_ENABLED", false),
+			Host:     l.str("REDIS_HOST", "localhost"),
+			Port:     l.str("REDIS_PORT", "6379"),
+			Password: l.str("REDIS_PASSWORD", ""),
+			DB:       l.int("REDIS_DB", 0),
 		},
-	}, nil
-}
+		Export: ExportConfig{
+			WebhookURL: l.str("EXPORT_WEBHOOK_URL", ""),
+			STIXURL:    l.str("EXPORT_STIX_URL", ""),
+			AuthToken:  l.str("EXPORT_AUTH_TOKEN", ""),
+			MaxRetries: l.int("EXPORT_MAX_RETRIES", 5),
+			QueueSize:  l.int("EXPORT_QUEUE_SIZE", 1000),
+		},
+		Storage: StorageConfig{
+			EncryptionKey: l.str("STORAGE_ENCRYPTION_KEY", ""),
+		},
+		Upload: UploadConfig{
+			MaxMB:       l.int("UPLOAD_MAX_MB", 10),
+			ClamAVAddr:  l.str("UPLOAD_CLAMAV_ADDR", ""),
+			ScanTimeout: l.duration("UPLOAD_SCAN_TIMEOUT", 30*time.Second),
+		},
+		Review: ReviewConfig{
+			FourEyes:        l.bool("REVIEW_FOUR_EYES", false),
+			SuppressionDays: l.int("REVIEW_SUPPRESSION_DAYS", 365),
+		},
+	}
 
-func getEnv(key, defaultValue string) string {
-	if value := os.Getenv(key); value != "" {
-		return value
+	l.checkUnknown()
+	l.validate(cfg)
+	if err := l.err(); err != nil {
+		return nil, err
 	}
-	return defaultValue
-}
+	cfg.values = l.values
 
-func getIntEnv(key string, defaultValue int) int {
-	if value := os.Getenv(key); value != "" {
-		if intVal, err := strconv.Atoi(value); err == nil {
-			return intVal
+	if err := cfg.loadSecrets(); err != nil {
+		return nil, err
+	}
+	if err := cfg.checkDefaultSecrets(); err != nil {
+		return nil, err
+	}
+
+	if cfg.Storage.EncryptionKey != "" {
+		if _, err := atrest.ParseKey(cfg.Storage.EncryptionKey); err != nil {
+			return nil, fmt.Errorf("STORAGE_ENCRYPTION_KEY: %w", err)
 		}
 	}
-	return defaultValue
+	return cfg, nil
+}
+
+// IsDevelopment reports whether the service runs in development mode
+func (c *Config) IsDevelopment() bool {
+	return c.Env == "" || c.Env == "development" || c.Env == "dev"
+}
+
+// TLS reports whether the server is configured to serve HTTPS
+func (s ServerConfig) TLS() bool {
+	return s.TLSCertFile != "" && s.TLSKeyFile != ""
+}
+
+// Protocols returns the HTTP versions the server accepts: HTTP/1.1 always,
+// HTTP/2 over TLS when TLS is configured, and HTTP/2 in cleartext when H2C
+// is enabled
+func (s ServerConfig) Protocols() *http.Protocols {
+	p := new(http.Protocols)
+	p.SetHTTP1(true)
+	p.SetHTTP2(s.TLS())
+	p.SetUnencryptedHTTP2(s.H2C)
+	return p
+}
+
+// BodyLimitRoutes returns the per-route body limits of SERVER_BODY_LIMITS
+// in bytes
+func (s ServerConfig) BodyLimitRoutes() map[string]int64 {
+	routes, _ := ParseBodyLimits(s.BodyLimits)
+	return routes
 }
 
-func getFloatEnv(key string, defaultValue float64) float64 {
-	if value := os.Getenv(key); value != "" {
-		if floatVal, err := strconv.ParseFloat(value, 64); err == nil {
-			return floatVal
+// ParseBodyLimits parses comma-separated pattern=KB pairs, where pattern is
+// a route as registered, e.g. /results/{resultId}/comments, into limits in
+// bytes. A limit of 0 leaves the route unlimited.
+func ParseBodyLimits(s string) (map[string]int64, error) {
+	routes := make(map[string]int64)
+	for _, pair := range strings.Split(s, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
 		}
+		pattern, kb, ok := strings.Cut(pair, "=")
+		pattern = strings.TrimSpace(pattern)
+		if !ok || !strings.HasPrefix(pattern, "/") {
+			return nil, fmt.Errorf("%q is not a /route=KB limit", pair)
+		}
+		n, err := strconv.ParseInt(strings.TrimSpace(kb), 10, 53)
+		if err != nil || n < 0 {
+			return nil, fmt.Errorf("%q: limit must be a number of KB, at least 0", pair)
+		}
+		routes[pattern] = n << 10
 	}
-	return defaultValue
+	return routes, nil
 }
 
-func getBoolEnv(key string, defaultValue bool) bool {
-	if value := os.Getenv(key); value != "" {
-		if boolVal, err := strconv.ParseBool(value); err == nil {
-			return boolVal
+// secretFields maps each secret name to the setting it populates
+func (c *Config) secretFields() map[string]*string {
+	return map[string]*string{
+		"ADMIN_TOKEN":            &c.Server.AdminToken,
+		"JWT_ACCESS_SECRET":      &c.JWT.AccessSecret,
+		"JWT_REFRESH_SECRET":     &c.JWT.RefreshSecret,
+		"JWT_SESSION_SECRET":     &c.JWT.SessionSecret,
+		"PSI_SERVER_API_KEY":     &c.PSI.ServerAPIKey,
+		"PSI_QUEUE_URL":          &c.PSI.QueueURL,
+		"EXPORT_AUTH_TOKEN":      &c.Export.AuthToken,
+		"STORAGE_ENCRYPTION_KEY": &c.Storage.EncryptionKey,
+	}
+}
+
+// loadSecrets overrides secret settings with values from the configured
+// secrets backend (SECRETS_BACKEND)
+func (c *Config) loadSecrets() error {
+	provider, err := secrets.FromEnv()
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	for name, field := range c.secretFields() {
+		value, err := provider.Get(ctx, name)
+		if errors.Is(err, secrets.ErrNotFound) {
+			continue
 		}
+		if err != nil {
+			return fmt.Errorf("load secret %s: %w", name, err)
+		}
+		*field = value
 	}
-	return defaultValue
+	return nil
+}
+
+// defaultSecrets are the built-in and example values of the JWT secrets
+var defaultSecrets = map[string]bool{
+	"change-this-secret":                           true,
+	"change-this-refresh-secret":                   true,
+	"change-this-session-secret":                   true,
+	"test-secret-key-change-in-production":         true,
+	"test-refresh-secret-key-change-in-production": true,
+	"test-session-secret-key-change-in-production": true,
 }
 
-func getDurationEnv(key string, defaultValue time.Duration) time.Duration {
-	if value := os.Getenv(key); value != "" {
-		if duration, err := time.ParseDuration(value); err == nil {
-			return duration
+// checkDefaultSecrets refuses default secrets outside development
+func (c *Config) checkDefaultSecrets() error {
+	if c.IsDevelopment() {
+		return nil
+	}
+
+	var names []string
+	for _, name := range []string{"JWT_ACCESS_SECRET", "JWT_REFRESH_SECRET", "JWT_SESSION_SECRET"} {
+		if defaultSecrets[*c.secretFields()[name]] {
+			names = append(names, name)
 		}
 	}
-	return defaultValue
+	if len(names) > 0 {
+		return fmt.Errorf("refusing to start in %s mode with default secrets: %s", c.Env, strings.Join(names, ", "))
+	}
+	return nil
 }
 
 func (c *Config) DatabaseDSN() string {
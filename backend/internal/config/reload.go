@@ -0,0 +1,70 @@
+package config
+
+import (
+	"context"
+	"log"
+	"os"
+	"os/signal"
+	"sort"
+	"syscall"
+)
+
+// Reloadable lists the settings that take effect on SIGHUP. Other changes
+// are logged and need a restart.
+var Reloadable = map[string]bool{
+	"LOG_LEVEL":              true,
+	"PSI_RESOLVE_RATE_LIMIT": true,
+}
+
+// WatchReload reloads the configuration from opts on every SIGHUP until ctx
+// is done. A valid reload is passed to apply, which should only read the
+// Reloadable settings from it; an invalid one is logged and ignored.
+func WatchReload(ctx context.Context, opts Options, current *Config, apply func(*Config)) {
+	running := make(map[string]string, len(current.values))
+	for key, value := range current.values {
+		running[key] = value
+	}
+
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	defer signal.Stop(hup)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-hup:
+		}
+
+		next, err := LoadFrom(opts)
+		if err != nil {
+			log.Printf("Warning: config reload failed, keeping the running configuration: %v", err)
+			continue
+		}
+
+		var applied, restart []string
+		for key, value := range next.values {
+			if running[key] == value {
+				continue
+			}
+			if Reloadable[key] {
+				applied = append(applied, key)
+			} else {
+				restart = append(restart, key)
+			}
+		}
+		sort.Strings(applied)
+		sort.Strings(restart)
+		if len(restart) > 0 {
+			log.Printf("Warning: config reload ignored changes that need a restart: %v", restart)
+		}
+		log.Printf("Config reloaded; applied %v", applied)
+
+		// Settings that need a restart keep their running value, so they
+		// are reported again until the service restarts
+		for _, key := range applied {
+			running[key] = next.values[key]
+		}
+		apply(next)
+	}
+}
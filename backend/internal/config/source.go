@@ -0,0 +1,255 @@
+package config
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// DefaultFile is the config file read from the working directory when
+// neither -config nor FLARE_CONFIG names one
+const DefaultFile = "flare.yaml"
+
+// Options selects the config sources layered over the built-in defaults.
+// Precedence is file < environment < Overrides.
+type Options struct {
+	File      string            // YAML config file; empty uses FLARE_CONFIG or ./flare.yaml if present
+	Overrides map[string]string // Setting name (e.g. SERVER_PORT) to value, usually from -set flags
+}
+
+// RegisterFlags adds -config and -set to fs
+func (o *Options) RegisterFlags(fs *flag.FlagSet) {
+	fs.StringVar(&o.File, "config", "", "config file (default $FLARE_CONFIG or ./"+DefaultFile+")")
+	fs.Var((*overrideFlag)(o), "set", "override a setting, e.g. -set PSI_RESOLVE_RATE_LIMIT=60 (repeatable)")
+}
+
+type overrideFlag Options
+
+func (f *overrideFlag) String() string {
+	if f == nil || len(f.Overrides) == 0 {
+		return ""
+	}
+	pairs := make([]string, 0, len(f.Overrides))
+	for k, v := range f.Overrides {
+		pairs = append(pairs, k+"="+v)
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, ",")
+}
+
+func (f *overrideFlag) Set(s string) error {
+	key, value, ok := strings.Cut(s, "=")
+	if !ok || strings.TrimSpace(key) == "" {
+		return fmt.Errorf("expected NAME=VALUE, got %q", s)
+	}
+	if f.Overrides == nil {
+		f.Overrides = make(map[string]string)
+	}
+	f.Overrides[strings.ToUpper(strings.TrimSpace(key))] = value
+	return nil
+}
+
+// fileValue is a setting read from the config file
+type fileValue struct {
+	path  string // Dotted path as written in the file, e.g. psi.max_workers
+	line  int
+	value string
+}
+
+// loader resolves settings from the layered sources and collects every
+// problem instead of stopping at the first one
+type loader struct {
+	fileName  string
+	file      map[string]fileValue
+	overrides map[string]string
+	used      map[string]bool
+	values    map[string]string // Resolved raw values, for reload diffs
+	problems  []string
+}
+
+func newLoader(opts Options) (*loader, error) {
+	l := &loader{
+		file:      make(map[string]fileValue),
+		overrides: opts.Overrides,
+		used:      make(map[string]bool),
+		values:    make(map[string]string),
+	}
+
+	path, required := opts.File, true
+	if path == "" {
+		path = os.Getenv("FLARE_CONFIG")
+	}
+	if path == "" {
+		path, required = DefaultFile, false
+	}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) && !required {
+		return l, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read config file: %w", err)
+	}
+	l.fileName = path
+
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("parse %s: %w", path, err)
+	}
+	if len(doc.Content) == 0 {
+		return l, nil // Empty file
+	}
+	root := doc.Content[0]
+	if root.Kind != yaml.MappingNode {
+		return nil, fmt.Errorf("%s:%d: top level must be a mapping of settings", path, root.Line)
+	}
+	l.flatten(root, nil, nil)
+	return l, nil
+}
+
+// flatten records the scalar leaves of a mapping. Nested keys are joined
+// with underscores and upper-cased, so psi: {max_workers: 4} sets
+// PSI_MAX_WORKERS.
+func (l *loader) flatten(node *yaml.Node, names, path []string) {
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		key, value := node.Content[i], node.Content[i+1]
+		keyNames := append(append([]string(nil), names...), strings.ToUpper(key.Value))
+		keyPath := append(append([]string(nil), path...), key.Value)
+		dotted := strings.Join(keyPath, ".")
+
+		switch value.Kind {
+		case yaml.MappingNode:
+			l.flatten(value, keyNames, keyPath)
+		case yaml.ScalarNode:
+			name := strings.Join(keyNames, "_")
+			if prev, ok := l.file[name]; ok {
+				l.problems = append(l.problems, fmt.Sprintf("%s:%d: %s: already set at line %d", l.fileName, key.Line, dotted, prev.line))
+				continue
+			}
+			l.file[name] = fileValue{path: dotted, line: key.Line, value: value.Value}
+		default:
+			l.problems = append(l.problems, fmt.Sprintf("%s:%d: %s: expected a single value", l.fileName, key.Line, dotted))
+		}
+	}
+}
+
+// lookup returns the value of key from the highest-precedence source that
+// sets it, with a description of that source for error messages
+func (l *loader) lookup(key string) (value, origin string, ok bool) {
+	l.used[key] = true
+	if v, ok := l.overrides[key]; ok {
+		return v, "-set " + key, true
+	}
+	if v := os.Getenv(key); v != "" {
+		return v, key + " (environment)", true
+	}
+	if fv, ok := l.file[key]; ok {
+		return fv.value, fmt.Sprintf("%s:%d: %s", l.fileName, fv.line, fv.path), true
+	}
+	return "", key, false
+}
+
+// origin describes where key was set, or just names it when it was not
+func (l *loader) origin(key string) string {
+	_, origin, _ := l.lookup(key)
+	return origin
+}
+
+func (l *loader) invalid(origin, format string, args ...interface{}) {
+	l.problems = append(l.problems, origin+": "+fmt.Sprintf(format, args...))
+}
+
+func (l *loader) str(key, defaultValue string) string {
+	value, _, ok := l.lookup(key)
+	if !ok {
+		value = defaultValue
+	}
+	l.values[key] = value
+	return value
+}
+
+func (l *loader) int(key string, defaultValue int) int {
+	value, origin, ok := l.lookup(key)
+	l.values[key] = value
+	if !ok {
+		return defaultValue
+	}
+	n, err := strconv.Atoi(strings.TrimSpace(value))
+	if err != nil {
+		l.invalid(origin, "%q is not an integer", value)
+		return defaultValue
+	}
+	return n
+}
+
+func (l *loader) float(key string, defaultValue float64) float64 {
+	value, origin, ok := l.lookup(key)
+	l.values[key] = value
+	if !ok {
+		return defaultValue
+	}
+	f, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+	if err != nil {
+		l.invalid(origin, "%q is not a number", value)
+		return defaultValue
+	}
+	return f
+}
+
+func (l *loader) bool(key string, defaultValue bool) bool {
+	value, origin, ok := l.lookup(key)
+	l.values[key] = value
+	if !ok {
+		return defaultValue
+	}
+	b, err := strconv.ParseBool(strings.TrimSpace(value))
+	if err != nil {
+		l.invalid(origin, "%q is not a boolean (use true or false)", value)
+		return defaultValue
+	}
+	return b
+}
+
+func (l *loader) duration(key string, defaultValue time.Duration) time.Duration {
+	value, origin, ok := l.lookup(key)
+	l.values[key] = value
+	if !ok {
+		return defaultValue
+	}
+	d, err := time.ParseDuration(strings.TrimSpace(value))
+	if err != nil {
+		l.invalid(origin, "%q is not a duration (e.g. 30s, 15m, 2h)", value)
+		return defaultValue
+	}
+	return d
+}
+
+// checkUnknown reports file and flag settings that no field reads, which
+// are almost always typos
+func (l *loader) checkUnknown() {
+	for name, fv := range l.file {
+		if !l.used[name] {
+			l.problems = append(l.problems, fmt.Sprintf("%s:%d: unknown setting %s", l.fileName, fv.line, fv.path))
+		}
+	}
+	for name := range l.overrides {
+		if !l.used[name] {
+			l.problems = append(l.problems, fmt.Sprintf("-set %s: unknown setting", name))
+		}
+	}
+}
+
+// err returns all problems found so far as a single error
+func (l *loader) err() error {
+	if len(l.problems) == 0 {
+		return nil
+	}
+	sort.Strings(l.problems)
+	return fmt.Errorf("invalid configuration:\n  %s", strings.Join(l.problems, "\n  "))
+}
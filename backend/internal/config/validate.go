@@ -0,0 +1,117 @@
+package config
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/SanthoshCheemala/FLARE/backend/internal/logging"
+	"github.com/SanthoshCheemala/FLARE/backend/internal/models"
+	"github.com/SanthoshCheemala/FLARE/backend/internal/psiadapter"
+	"github.com/SanthoshCheemala/FLARE/backend/internal/translit"
+)
+
+// validate checks values that parsed but are out of range
+func (l *loader) validate(cfg *Config) {
+	switch cfg.Env {
+	case "development", "dev", "staging", "production":
+	default:
+		l.invalid(l.origin("APP_ENV"), "%q is not an environment (use development, staging or production)", cfg.Env)
+	}
+	if _, err := logging.ParseLevel(cfg.Log.Level); err != nil {
+		l.invalid(l.origin("LOG_LEVEL"), "%v", err)
+	}
+
+	if port, err := strconv.Atoi(cfg.Server.Port); err != nil || port < 1 || port > 65535 {
+		l.invalid(l.origin("SERVER_PORT"), "%q is not a TCP port", cfg.Server.Port)
+	}
+	if cfg.Server.Compression && (cfg.Server.CompressionLevel < 1 || cfg.Server.CompressionLevel > 9) {
+		l.invalid(l.origin("SERVER_COMPRESSION_LEVEL"), "must be between 1 and 9, got %d", cfg.Server.CompressionLevel)
+	}
+	if (cfg.Server.TLSCertFile == "") != (cfg.Server.TLSKeyFile == "") {
+		l.invalid(l.origin("SERVER_TLS_CERT"), "SERVER_TLS_CERT and SERVER_TLS_KEY must be set together")
+	}
+	if _, err := ParseBodyLimits(cfg.Server.BodyLimits); err != nil {
+		l.invalid(l.origin("SERVER_BODY_LIMITS"), "%v", err)
+	}
+	switch cfg.Database.Driver {
+	case "sqlite3", "postgres":
+	default:
+		l.invalid(l.origin("DB_DRIVER"), "%q is not a supported driver (use sqlite3 or postgres)", cfg.Database.Driver)
+	}
+
+	positive := map[string]time.Duration{
+		"SERVER_READ_TIMEOUT":      cfg.Server.ReadTimeout,
+		"SERVER_WRITE_TIMEOUT":     cfg.Server.WriteTimeout,
+		"SERVER_SHUTDOWN_TIMEOUT":  cfg.Server.ShutdownTimeout,
+		"DB_QUERY_TIMEOUT":         cfg.Database.QueryTimeout,
+		"JWT_ACCESS_EXPIRY":        cfg.JWT.AccessExpiry,
+		"JWT_REFRESH_EXPIRY":       cfg.JWT.RefreshExpiry,
+		"JWT_SESSION_EXPIRY":       cfg.JWT.SessionExpiry,
+		"PSI_JOB_RETENTION":        cfg.PSI.JobRetention,
+		"PSI_SIGNATURE_MAX_SKEW":   cfg.PSI.SignatureMaxSkew,
+		"PSI_TREE_BUSY_TIMEOUT":    cfg.PSI.TreeBusyTimeout,
+		"PSI_IDEMPOTENCY_WINDOW":   cfg.PSI.IdempotencyWindow,
+		"PSI_SESSION_IDLE_TIMEOUT": cfg.PSI.SessionIdleTimeout,
+		"PSI_RETRY_RETENTION":      cfg.PSI.RetryRetention,
+		"PSI_QUEUE_TIMEOUT":        cfg.PSI.QueueTimeout,
+		"UPLOAD_SCAN_TIMEOUT":      cfg.Upload.ScanTimeout,
+	}
+	for key, d := range positive {
+		if d <= 0 {
+			l.invalid(l.origin(key), "must be positive, got %s", d)
+		}
+	}
+
+	atLeast := []struct {
+		key   string
+		value int
+		min   int
+	}{
+		{"DB_MAX_CONNS", cfg.Database.MaxConns, 1},
+		{"SERVER_MAX_BODY_KB", cfg.Server.MaxBodyKB, 0},
+		{"PSI_MAX_CIPHERTEXTS_PER_REQUEST", cfg.PSI.MaxCiphertextsPerRequest, 0},
+		{"PSI_MIN_RESOLVE_CIPHERTEXTS", cfg.PSI.MinResolveCiphertexts, 0},
+		{"PSI_ANONYMOUS_SESSIONS_PER_DAY", cfg.PSI.AnonymousSessionsPerDay, 0},
+		{"PSI_ANONYMOUS_CONCURRENT_SESSIONS", cfg.PSI.AnonymousConcurrentSessions, 0},
+		{"PSI_STATS_MATCH_CAP", cfg.PSI.StatsMatchCap, 1},
+		{"PSI_STATS_INTERSECTION_CAP", cfg.PSI.StatsIntersectionCap, 1},
+		{"PSI_MAX_WORKERS", cfg.PSI.MaxWorkers, 0},
+		{"PSI_MAX_CONCURRENT_SCREENINGS", cfg.PSI.MaxScreenings, 1},
+		{"PSI_CIPHERTEXT_CACHE_MB", cfg.PSI.CiphertextCacheMB, 0},
+		{"PSI_QUEUE_WORKERS", cfg.PSI.QueueWorkers, 1},
+		{"PSI_SANCTION_RETENTION_DAYS", cfg.PSI.SanctionRetentionDays, 0},
+		{"PSI_RESOLVE_RATE_LIMIT", cfg.PSI.ResolveRateLimit, 0},
+		{"PSI_RESIDENT_BATCHES", cfg.PSI.ResidentBatches, 0},
+		{"PSI_TREE_WORKERS", cfg.PSI.TreeWorkers, 0},
+		{"PSI_TREE_REOPENS", cfg.PSI.TreeReopens, 0},
+		{"EXPORT_MAX_RETRIES", cfg.Export.MaxRetries, 0},
+		{"REVIEW_SUPPRESSION_DAYS", cfg.Review.SuppressionDays, 1},
+		{"EXPORT_QUEUE_SIZE", cfg.Export.QueueSize, 1},
+		{"UPLOAD_MAX_MB", cfg.Upload.MaxMB, 1},
+		{"REDIS_DB", cfg.Redis.DB, 0},
+	}
+	for _, c := range atLeast {
+		if c.value < c.min {
+			l.invalid(l.origin(c.key), "must be at least %d, got %d", c.min, c.value)
+		}
+	}
+	if cfg.PSI.MaxRAMGB <= 0 {
+		l.invalid(l.origin("PSI_MAX_RAM_GB"), "must be positive, got %g", cfg.PSI.MaxRAMGB)
+	}
+	if cfg.PSI.StatsEpsilon < 0 {
+		l.invalid(l.origin("PSI_STATS_EPSILON"), "must not be negative, got %g", cfg.PSI.StatsEpsilon)
+	}
+	if _, err := translit.Parse(cfg.PSI.Transliteration); err != nil {
+		l.invalid(l.origin("PSI_TRANSLITERATION"), "%v", err)
+	}
+	if _, err := psiadapter.ParseDateOrder(cfg.PSI.DateOrder); err != nil {
+		l.invalid(l.origin("PSI_DATE_ORDER"), "%v", err)
+	}
+	if !models.IsRetention(cfg.PSI.SanctionRetention) {
+		l.invalid(l.origin("PSI_SANCTION_RETENTION"), "%q is not a retention policy (use full, redacted or reference)", cfg.PSI.SanctionRetention)
+	}
+	if !psiadapter.IsHashAlgorithm(cfg.PSI.HashAlgorithm) {
+		l.invalid(l.origin("PSI_HASH_ALGORITHM"), "%q is not a supported hash (use %s)", cfg.PSI.HashAlgorithm, strings.Join(psiadapter.HashAlgorithms, ", "))
+	}
+}
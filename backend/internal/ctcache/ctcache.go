@@ -0,0 +1,165 @@
+// Package ctcache keeps client ciphertexts on disk, keyed by everything they
+// were encrypted from, so a customer list screened again under unchanged
+// server params is not encrypted again. Entries carry a checksum, are
+// encrypted at rest like uploaded lists, and are evicted least recently used
+// first once the cache outgrows its size limit.
+package ctcache
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/SanthoshCheemala/FLARE/backend/internal/atrest"
+	"github.com/SanthoshCheemala/FLARE/backend/internal/psiadapter"
+)
+
+// entryExt names cache entries; anything else in the directory is ignored
+const entryExt = ".ct"
+
+// Store is a ciphertext cache in a directory. A nil Store caches nothing.
+type Store struct {
+	dir      string
+	files    *atrest.Cipher
+	maxBytes int64
+
+	mu sync.Mutex // Serializes writes and eviction
+}
+
+// New returns a cache in dir holding up to maxBytes of entries
+func New(dir string, files *atrest.Cipher, maxBytes int64) *Store {
+	return &Store{dir: dir, files: files, maxBytes: maxBytes}
+}
+
+// Key identifies the ciphertexts of customerData, as serialized for
+// hashing, under one batch's params and the session's hash scheme. The
+// serialized records reflect the list's contents, column mapping and
+// transliteration, so any change to those is a different key. Keyed
+// schemes have a fresh salt per session and never share a key.
+func Key(params *psiadapter.SerializedServerParams, scheme psiadapter.HashScheme, customerData []string) string {
+	h := sha256.New()
+	json.NewEncoder(h).Encode(params)
+	json.NewEncoder(h).Encode(scheme)
+	for _, s := range customerData {
+		fmt.Fprintf(h, "%s\n", s)
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// Get returns the ciphertexts stored under key. Entries that fail their
+// checksum are removed and reported as missing.
+func (s *Store) Get(key string) ([]psiadapter.ClientCiphertext, bool) {
+	if s == nil {
+		return nil, false
+	}
+	path := s.path(key)
+	data, err := s.files.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, false
+	}
+	var ciphertexts []psiadapter.ClientCiphertext
+	if err == nil {
+		err = decode(data, &ciphertexts)
+	}
+	if err != nil {
+		log.Printf("Warning: dropping corrupt ciphertext cache entry %s: %v", key, err)
+		os.Remove(path)
+		return nil, false
+	}
+	// The modification time orders eviction
+	now := time.Now()
+	os.Chtimes(path, now, now)
+	return ciphertexts, true
+}
+
+// Put stores ciphertexts under key, then evicts the least recently used
+// entries beyond the size limit
+func (s *Store) Put(key string, ciphertexts []psiadapter.ClientCiphertext) error {
+	if s == nil {
+		return nil
+	}
+	payload, err := json.Marshal(ciphertexts)
+	if err != nil {
+		return err
+	}
+	if int64(len(payload)) > s.maxBytes {
+		return fmt.Errorf("%d bytes of ciphertexts exceed the cache size", len(payload))
+	}
+	sum := sha256.Sum256(payload)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := os.MkdirAll(s.dir, 0700); err != nil {
+		return err
+	}
+	// Written aside and renamed, so a reader never sees a partial entry
+	tmp := s.path(key) + ".tmp"
+	if err := s.files.WriteFile(tmp, bytes.NewReader(append(sum[:], payload...)), 0600); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	if err := os.Rename(tmp, s.path(key)); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	s.evict()
+	return nil
+}
+
+// evict removes the least recently used entries until the cache fits its
+// size limit. s.mu is held.
+func (s *Store) evict() {
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		log.Printf("Warning: failed to list ciphertext cache: %v", err)
+		return
+	}
+	var infos []os.FileInfo
+	var total int64
+	for _, entry := range entries {
+		if filepath.Ext(entry.Name()) != entryExt {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		infos = append(infos, info)
+		total += info.Size()
+	}
+	sort.Slice(infos, func(i, j int) bool { return infos[i].ModTime().Before(infos[j].ModTime()) })
+	for _, info := range infos {
+		if total <= s.maxBytes {
+			return
+		}
+		if err := os.Remove(filepath.Join(s.dir, info.Name())); err != nil {
+			log.Printf("Warning: failed to evict ciphertext cache entry %s: %v", info.Name(), err)
+			continue
+		}
+		total -= info.Size()
+	}
+}
+
+func (s *Store) path(key string) string {
+	return filepath.Join(s.dir, key+entryExt)
+}
+
+// decode checks the checksum an entry starts with and decodes the rest
+func decode(data []byte, v interface{}) error {
+	if len(data) < sha256.Size {
+		return fmt.Errorf("entry is truncated")
+	}
+	sum, payload := data[:sha256.Size], data[sha256.Size:]
+	if got := sha256.Sum256(payload); !bytes.Equal(got[:], sum) {
+		return fmt.Errorf("checksum mismatch")
+	}
+	return json.Unmarshal(payload, v)
+}
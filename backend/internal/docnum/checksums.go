@@ -0,0 +1,177 @@
+package docnum
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// nationalIDChecks validate the national ID numbers of countries whose
+// numbers carry a checksum, keyed by ISO 3166 alpha-2 code
+var nationalIDChecks = map[string]func(string) error{
+	"CN": chineseResidentID,
+	"ES": spanishDNI,
+	"IN": aadhaar,
+	"SE": swedishPersonnummer,
+	"ZA": southAfricanID,
+}
+
+var errChecksum = errors.New("checksum does not match")
+
+// icaoCheck verifies the ICAO 9303 check digit of a machine-readable
+// document number, whose field is padded to 9 characters with '<'
+func icaoCheck(number, check string) error {
+	if len(check) != 1 || check[0] < '0' || check[0] > '9' {
+		return fmt.Errorf("passport number %s has no check digit", number)
+	}
+	field := number + strings.Repeat("<", max(0, 9-len(number)))
+	weights := [3]int{7, 3, 1}
+	sum := 0
+	for i, r := range field {
+		v := 0
+		switch {
+		case r >= '0' && r <= '9':
+			v = int(r - '0')
+		case r >= 'A' && r <= 'Z':
+			v = int(r-'A') + 10
+		}
+		sum += v * weights[i%3]
+	}
+	if sum%10 != int(check[0]-'0') {
+		return fmt.Errorf("passport number %s: %w", number, errChecksum)
+	}
+	return nil
+}
+
+func allDigits(s string) bool {
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return s != ""
+}
+
+// luhn reports whether the digits pass the Luhn check
+func luhn(digits string) bool {
+	sum := 0
+	for i := range digits {
+		d := int(digits[len(digits)-1-i] - '0')
+		if i%2 == 1 {
+			if d *= 2; d > 9 {
+				d -= 9
+			}
+		}
+		sum += d
+	}
+	return sum%10 == 0
+}
+
+// chineseResidentID checks the ISO 7064 MOD 11-2 digit of an 18-character
+// resident identity card number
+func chineseResidentID(n string) error {
+	if len(n) != 18 || !allDigits(n[:17]) {
+		return errors.New("not 17 digits and a check character")
+	}
+	weights := []int{7, 9, 10, 5, 8, 4, 2, 1, 6, 3, 7, 9, 10, 5, 8, 4, 2}
+	sum := 0
+	for i, w := range weights {
+		sum += int(n[i]-'0') * w
+	}
+	if "10X98765432"[sum%11] != n[17] {
+		return errChecksum
+	}
+	return nil
+}
+
+// spanishDNI checks the control letter of a DNI, or of an NIE whose leading
+// X, Y or Z stands for 0, 1 or 2
+func spanishDNI(n string) error {
+	if len(n) != 9 {
+		return errors.New("not 8 digits and a letter")
+	}
+	digits := n[:8]
+	if i := strings.IndexByte("XYZ", n[0]); i >= 0 {
+		digits = string(rune('0'+i)) + n[1:8]
+	}
+	if !allDigits(digits) {
+		return errors.New("not 8 digits and a letter")
+	}
+	value := 0
+	for _, r := range digits {
+		value = value*10 + int(r-'0')
+	}
+	if "TRWAGMYFPDXBNJZSQVHLCKE"[value%23] != n[8] {
+		return errChecksum
+	}
+	return nil
+}
+
+// verhoeff tables: multiplication in the dihedral group D5, the position
+// permutation, and (not needed for checking) the inverse
+var (
+	verhoeffD = [10][10]int{
+		{0, 1, 2, 3, 4, 5, 6, 7, 8, 9},
+		{1, 2, 3, 4, 0, 6, 7, 8, 9, 5},
+		{2, 3, 4, 0, 1, 7, 8, 9, 5, 6},
+		{3, 4, 0, 1, 2, 8, 9, 5, 6, 7},
+		{4, 0, 1, 2, 3, 9, 5, 6, 7, 8},
+		{5, 9, 8, 7, 6, 0, 4, 3, 2, 1},
+		{6, 5, 9, 8, 7, 1, 0, 4, 3, 2},
+		{7, 6, 5, 9, 8, 2, 1, 0, 4, 3},
+		{8, 7, 6, 5, 9, 3, 2, 1, 0, 4},
+		{9, 8, 7, 6, 5, 4, 3, 2, 1, 0},
+	}
+	verhoeffP = [8][10]int{
+		{0, 1, 2, 3, 4, 5, 6, 7, 8, 9},
+		{1, 5, 7, 6, 2, 8, 3, 0, 9, 4},
+		{5, 8, 0, 3, 7, 9, 6, 1, 4, 2},
+		{8, 9, 1, 6, 0, 4, 3, 5, 2, 7},
+		{9, 4, 5, 3, 1, 2, 6, 8, 7, 0},
+		{4, 2, 8, 6, 5, 7, 3, 9, 0, 1},
+		{2, 7, 9, 3, 8, 0, 6, 4, 1, 5},
+		{7, 0, 4, 6, 9, 1, 3, 2, 5, 8},
+	}
+)
+
+// aadhaar checks the Verhoeff digit of a 12-digit Aadhaar number, which
+// never starts with 0 or 1
+func aadhaar(n string) error {
+	if len(n) != 12 || !allDigits(n) || n[0] < '2' {
+		return errors.New("not 12 digits starting 2-9")
+	}
+	c := 0
+	for i := range n {
+		c = verhoeffD[c][verhoeffP[i%8][int(n[len(n)-1-i]-'0')]]
+	}
+	if c != 0 {
+		return errChecksum
+	}
+	return nil
+}
+
+// swedishPersonnummer checks the Luhn digit of a personnummer, written with
+// or without the century
+func swedishPersonnummer(n string) error {
+	if len(n) == 12 {
+		n = n[2:]
+	}
+	if len(n) != 10 || !allDigits(n) {
+		return errors.New("not 10 or 12 digits")
+	}
+	if !luhn(n) {
+		return errChecksum
+	}
+	return nil
+}
+
+// southAfricanID checks the Luhn digit of a 13-digit identity number
+func southAfricanID(n string) error {
+	if len(n) != 13 || !allDigits(n) {
+		return errors.New("not 13 digits")
+	}
+	if !luhn(n) {
+		return errChecksum
+	}
+	return nil
+}
@@ -0,0 +1,118 @@
+// Package docnum normalizes and validates identity document numbers
+// (passports and national IDs) for the document screening channel. A
+// number that fails its format or checksum is left out of screening rather
+// than risk a near-certain match on a typo or placeholder.
+package docnum
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Document kinds
+const (
+	KindPassport   = "passport"
+	KindNationalID = "national_id"
+)
+
+// attributeKinds maps the attribute keys documents are read from to their
+// kind
+var attributeKinds = map[string]string{
+	"passport":           KindPassport,
+	"passport_no":        KindPassport,
+	"passport_number":    KindPassport,
+	"national_id":        KindNationalID,
+	"national_id_no":     KindNationalID,
+	"national_id_number": KindNationalID,
+	"id_number":          KindNationalID,
+}
+
+// Document is a validated document number
+type Document struct {
+	Kind   string
+	Number string // Normalized
+}
+
+// placeholders are values lists use for an unknown number
+var placeholders = map[string]bool{"UNKNOWN": true, "NONE": true, "NA": true, "NIL": true, "NULL": true}
+
+// Normalize uppercases a document number and drops everything but letters
+// and digits
+func Normalize(number string) string {
+	var b strings.Builder
+	for _, r := range strings.ToUpper(number) {
+		if (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9') {
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+// Parse validates a document number of kind, held by someone of country
+// (ISO 3166 alpha-2, or empty), and returns it normalized. National IDs of
+// countries with a known checksum must pass it; passports written in
+// machine-readable form ("L898902C<3") must pass the ICAO 9303 check digit.
+func Parse(kind, number, country string) (Document, error) {
+	raw := strings.ToUpper(strings.TrimSpace(number))
+	n := Normalize(raw)
+	if placeholders[n] || strings.Trim(n, n[:min(1, len(n))]) == "" {
+		return Document{}, errors.New("missing or placeholder number")
+	}
+
+	switch kind {
+	case KindPassport:
+		if mrz, check, ok := strings.Cut(raw, "<"); ok {
+			n = Normalize(mrz)
+			if err := icaoCheck(n, strings.Trim(check, "<")); err != nil {
+				return Document{}, err
+			}
+		}
+		if len(n) < 5 || len(n) > 12 {
+			return Document{}, fmt.Errorf("passport number %s is not 5-12 characters", n)
+		}
+	case KindNationalID:
+		if len(n) < 4 || len(n) > 20 {
+			return Document{}, fmt.Errorf("national ID %s is not 4-20 characters", n)
+		}
+		if check, ok := nationalIDChecks[strings.ToUpper(strings.TrimSpace(country))]; ok {
+			if err := check(n); err != nil {
+				return Document{}, fmt.Errorf("national ID %s: %w", n, err)
+			}
+		}
+	default:
+		return Document{}, fmt.Errorf("unknown document kind %q", kind)
+	}
+	return Document{Kind: kind, Number: n}, nil
+}
+
+// FromAttributes returns the valid documents among a record's attributes,
+// sorted, and the number of document attributes that failed validation
+func FromAttributes(attributes map[string]string, country string) ([]Document, int) {
+	var docs []Document
+	invalid := 0
+	seen := make(map[Document]bool)
+	for key, value := range attributes {
+		kind, ok := attributeKinds[key]
+		if !ok || strings.TrimSpace(value) == "" {
+			continue
+		}
+		doc, err := Parse(kind, value, country)
+		if err != nil {
+			invalid++
+			continue
+		}
+		if !seen[doc] {
+			seen[doc] = true
+			docs = append(docs, doc)
+		}
+	}
+	sort.Slice(docs, func(i, j int) bool {
+		if docs[i].Kind != docs[j].Kind {
+			return docs[i].Kind < docs[j].Kind
+		}
+		return docs[i].Number < docs[j].Number
+	})
+	return docs, invalid
+}
@@ -0,0 +1,97 @@
+package docnum
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestNormalize(t *testing.T) {
+	for in, want := range map[string]string{
+		"ab 123-456": "AB123456",
+		"L898902C<3": "L898902C3",
+		"  x.y/z ":   "XYZ",
+		"№ 12":       "12",
+	} {
+		if got := Normalize(in); got != want {
+			t.Errorf("Normalize(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestParse(t *testing.T) {
+	for _, tc := range []struct {
+		kind, number, country string
+		want                  string // Normalized number, or empty if invalid
+	}{
+		{KindPassport, "ab 12345", "", "AB12345"},
+		{KindPassport, "L898902C<3", "", "L898902C"},
+		{KindPassport, "L898902C<<4", "", ""}, // Wrong check digit
+		{KindPassport, "L898902C<", "", ""},   // No check digit
+		{KindPassport, "AB12", "", ""},
+		{KindPassport, "AB1234567890X", "", ""},
+		{KindPassport, "unknown", "", ""},
+		{KindPassport, "N/A", "", ""},
+		{KindPassport, "000000000", "", ""},
+		{KindPassport, "  ", "", ""},
+		{KindNationalID, "123", "", ""},
+		{KindNationalID, "1234567", "", "1234567"},
+		{KindNationalID, "1234567", "fr", "1234567"}, // No known checksum
+		{KindNationalID, "11010519491231002x", "CN", "11010519491231002X"},
+		{KindNationalID, "110105194912310021", "CN", ""},
+		{KindNationalID, "12345678-Z", "es", "12345678Z"},
+		{KindNationalID, "12345678A", "ES", ""},
+		{KindNationalID, "X1234567L", "ES", "X1234567L"},
+		{KindNationalID, "X1234567Z", "ES", ""},
+		{KindNationalID, "2341 2341 2346", "IN", "234123412346"},
+		{KindNationalID, "234123412345", "IN", ""},
+		{KindNationalID, "134123412346", "IN", ""}, // Starts with 1
+		{KindNationalID, "811228-9874", "SE", "8112289874"},
+		{KindNationalID, "19811228-9874", "SE", "198112289874"},
+		{KindNationalID, "811228-9875", "SE", ""},
+		{KindNationalID, "8001015009087", "ZA", "8001015009087"},
+		{KindNationalID, "8001015009088", "ZA", ""},
+		{"driving_licence", "AB12345", "", ""},
+	} {
+		doc, err := Parse(tc.kind, tc.number, tc.country)
+		switch {
+		case tc.want == "" && err == nil:
+			t.Errorf("Parse(%s, %q, %q) accepted as %s", tc.kind, tc.number, tc.country, doc.Number)
+		case tc.want != "" && err != nil:
+			t.Errorf("Parse(%s, %q, %q): %v", tc.kind, tc.number, tc.country, err)
+		case tc.want != "" && doc != (Document{Kind: tc.kind, Number: tc.want}):
+			t.Errorf("Parse(%s, %q, %q) = %+v, want %s", tc.kind, tc.number, tc.country, doc, tc.want)
+		}
+	}
+}
+
+func TestChecksumError(t *testing.T) {
+	if _, err := Parse(KindNationalID, "8001015009088", "ZA"); !errors.Is(err, errChecksum) {
+		t.Errorf("err = %v, want errChecksum", err)
+	}
+	if _, err := Parse(KindNationalID, "800101500908", "ZA"); err == nil || errors.Is(err, errChecksum) {
+		t.Errorf("short number: err = %v, want a format error", err)
+	}
+}
+
+func TestFromAttributes(t *testing.T) {
+	docs, invalid := FromAttributes(map[string]string{
+		"passport":        "X9876543",
+		"passport_no":     "x987-6543",
+		"id_number":       "12345678Z",
+		"national_id":     "12345678A",
+		"passport_number": "none",
+		"name":            "Ivan Petrov",
+		"national_id_no":  "  ",
+	}, "ES")
+	want := []Document{
+		{Kind: KindNationalID, Number: "12345678Z"},
+		{Kind: KindPassport, Number: "X9876543"},
+	}
+	if !reflect.DeepEqual(docs, want) {
+		t.Errorf("documents %+v, want %+v", docs, want)
+	}
+	if invalid != 2 {
+		t.Errorf("invalid = %d, want 2", invalid)
+	}
+}
@@ -0,0 +1,85 @@
+package events
+
+import (
+	"log"
+	"slices"
+	"sync"
+	"time"
+)
+
+// subscriberBuffer is the number of undelivered events kept per subscriber
+const subscriberBuffer = 256
+
+// Bus fans published events out to subscribers. Each subscriber has its own
+// queue and goroutine, so a slow one never delays the publisher or the
+// others; when its queue is full, events for it are dropped and logged.
+// A nil *Bus discards everything published to it.
+type Bus struct {
+	mu   sync.RWMutex
+	subs []*subscriber
+}
+
+type subscriber struct {
+	name  string
+	types []Type // Empty receives every type
+	queue chan Envelope
+	done  chan struct{}
+}
+
+func NewBus() *Bus {
+	return &Bus{}
+}
+
+// Subscribe calls fn with every event of the given types, or of every type
+// if none is given, in publish order. The returned function unsubscribes
+// and waits for fn to return from its last event.
+func (b *Bus) Subscribe(name string, fn func(Envelope), types ...Type) (unsubscribe func()) {
+	s := &subscriber{
+		name:  name,
+		types: types,
+		queue: make(chan Envelope, subscriberBuffer),
+		done:  make(chan struct{}),
+	}
+	go func() {
+		defer close(s.done)
+		for e := range s.queue {
+			fn(e)
+		}
+	}()
+
+	b.mu.Lock()
+	b.subs = append(b.subs, s)
+	b.mu.Unlock()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			b.mu.Lock()
+			b.subs = slices.DeleteFunc(b.subs, func(other *subscriber) bool { return other == s })
+			close(s.queue)
+			b.mu.Unlock()
+			<-s.done
+		})
+	}
+}
+
+// Publish delivers e to every subscriber of its type without blocking
+func (b *Bus) Publish(e Event) {
+	if b == nil {
+		return
+	}
+	env := Envelope{Type: e.Type(), At: time.Now(), Data: e}
+
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	for _, s := range b.subs {
+		if len(s.types) > 0 && !slices.Contains(s.types, env.Type) {
+			continue
+		}
+		select {
+		case s.queue <- env:
+		default:
+			log.Printf("Event bus: %s is falling behind, dropping %s event", s.name, env.Type)
+		}
+	}
+}
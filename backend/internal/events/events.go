@@ -0,0 +1,90 @@
+// Package events carries the client backend's domain events from the code
+// where things happen (jobs, result reviews, list changes) to the subsystems
+// that react to them (exports, audit, live dashboards), so neither side
+// needs to know about the other.
+package events
+
+import "time"
+
+// Type names an event on the wire and in subscriptions
+type Type string
+
+const (
+	TypeJobStarted     Type = "job.started"
+	TypePhaseCompleted Type = "job.phase_completed"
+	TypeJobFinished    Type = "job.finished"
+	TypeMatchConfirmed Type = "match.confirmed"
+	TypeListUpdated    Type = "list.updated"
+)
+
+// Event is one of the typed events below
+type Event interface {
+	Type() Type
+}
+
+// JobStarted is published when a screening job starts running
+type JobStarted struct {
+	JobID     string `json:"jobId"`
+	Name      string `json:"name"`
+	CreatedBy int64  `json:"createdBy"`
+}
+
+// PhaseCompleted is published when a job records the duration of a phase,
+// e.g. "encryption" or "resolve"
+type PhaseCompleted struct {
+	JobID      string `json:"jobId"`
+	Phase      string `json:"phase"`
+	DurationMs int64  `json:"durationMs"`
+}
+
+// JobFinished is published when a job reaches a terminal status, and again
+// when re-resolving completes a job that failed to resolve its matches
+type JobFinished struct {
+	JobID      string `json:"jobId"`
+	Status     string `json:"status"`
+	MatchCount int    `json:"matchCount"`
+	Error      string `json:"error,omitempty"`
+}
+
+// MatchConfirmed is published when a CONFIRMED disposition takes effect:
+// directly, or on approval under four-eyes review
+type MatchConfirmed struct {
+	ResultID int64  `json:"resultId"`
+	By       string `json:"by"` // Email of the confirming user, or approver
+}
+
+// List kinds of ListUpdated
+const (
+	ListCustomer  = "customer"
+	ListSanction  = "sanction"  // A list on the PSI server
+	ListWatchlist = "watchlist" // A local watchlist
+	ListPack      = "pack"
+)
+
+// List actions of ListUpdated
+const (
+	ListCreated = "created"
+	ListChanged = "changed"
+	ListDeleted = "deleted"
+)
+
+// ListUpdated is published when a list screenings read from changes
+type ListUpdated struct {
+	Kind   string `json:"kind"`
+	ID     string `json:"id"` // List ID, or pack ID for packs
+	Action string `json:"action"`
+	By     string `json:"by,omitempty"` // Who made the change; empty for pack updates
+}
+
+func (JobStarted) Type() Type     { return TypeJobStarted }
+func (PhaseCompleted) Type() Type { return TypePhaseCompleted }
+func (JobFinished) Type() Type    { return TypeJobFinished }
+func (MatchConfirmed) Type() Type { return TypeMatchConfirmed }
+func (ListUpdated) Type() Type    { return TypeListUpdated }
+
+// Envelope is an event as delivered: stamped with its type and time
+type Envelope struct {
+	Type Type      `json:"type"`
+	At   time.Time `json:"at"`
+	Data Event     `json:"data"`
+}
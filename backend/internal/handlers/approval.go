@@ -0,0 +1,121 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/SanthoshCheemala/FLARE/backend/internal/apierror"
+	"github.com/SanthoshCheemala/FLARE/backend/internal/events"
+	"github.com/SanthoshCheemala/FLARE/backend/internal/middleware"
+	"github.com/SanthoshCheemala/FLARE/backend/internal/models"
+	"github.com/go-chi/chi/v5"
+)
+
+// approverRole may approve confirmations under four-eyes review
+const approverRole = "approver"
+
+// ApproveResult decides a confirmation awaiting four-eyes approval. An
+// approver other than the proposer sends {"approve": true} to confirm the
+// match, which exports it, or {"approve": false} to send it back to PENDING.
+func (h *Handler) ApproveResult(w http.ResponseWriter, r *http.Request) {
+	resultID, err := strconv.ParseInt(chi.URLParam(r, "resultId"), 10, 64)
+	if err != nil {
+		apierror.Write(w, r, http.StatusBadRequest, apierror.CodeBadRequest, "Invalid resultId")
+		return
+	}
+
+	var req struct {
+		Approve *bool `json:"approve"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Approve == nil {
+		apierror.Write(w, r, http.StatusBadRequest, apierror.CodeBadRequest, "approve must be true or false")
+		return
+	}
+
+	user := middleware.GetUser(r.Context())
+	if user == nil {
+		apierror.Write(w, r, http.StatusUnauthorized, apierror.CodeUnauthorized, "Approving a match requires a signed-in user")
+		return
+	}
+	if !user.HasRole(approverRole) {
+		apierror.Write(w, r, http.StatusForbidden, apierror.CodeForbidden, "Only approvers can approve matches")
+		return
+	}
+
+	result, err := h.repo.GetScreeningResultDetail(r.Context(), resultID)
+	if err != nil {
+		apierror.Write(w, r, http.StatusInternalServerError, apierror.CodeDatabaseError, "Failed to load result")
+		return
+	}
+	if result == nil {
+		apierror.Write(w, r, http.StatusNotFound, apierror.CodeNotFound, "Result not found")
+		return
+	}
+	if result.Status != "PENDING_APPROVAL" {
+		apierror.Write(w, r, http.StatusConflict, apierror.CodeConflict, "Result is not awaiting approval")
+		return
+	}
+	if result.ProposedBy == user.Email {
+		apierror.Write(w, r, http.StatusForbidden, apierror.CodeForbidden, "A match must be approved by someone other than its proposer")
+		return
+	}
+
+	// The update rechecks both conditions, in case of a concurrent decision
+	ok, err := h.repo.DecideResultApproval(r.Context(), resultID, user.Email, *req.Approve)
+	if err != nil {
+		log.Printf("Failed to record approval of result %d: %v", resultID, err)
+		apierror.Write(w, r, http.StatusInternalServerError, apierror.CodeDatabaseError, "Failed to record approval")
+		return
+	}
+	if !ok {
+		apierror.Write(w, r, http.StatusConflict, apierror.CodeConflict, "Result is not awaiting approval")
+		return
+	}
+
+	status, decision := "PENDING", "rejected"
+	if *req.Approve {
+		status, decision = "CONFIRMED", "approved"
+		h.events.Publish(events.MatchConfirmed{ResultID: resultID, By: user.Email})
+	}
+	log.Printf("Result %d %s by %s (proposed by %s)", resultID, decision, user.Email, result.ProposedBy)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success":    true,
+		"id":         resultID,
+		"status":     status,
+		"proposedBy": result.ProposedBy,
+		"decidedBy":  user.Email,
+	})
+}
+
+// exportResult pushes a confirmed match to external case management. It runs
+// on MatchConfirmed, so under four-eyes review only once the confirmation
+// is approved.
+func (h *Handler) exportResult(ctx context.Context, resultID int64) {
+	if !h.exporter.Enabled() {
+		return
+	}
+	detail, err := h.repo.GetScreeningResultDetail(ctx, resultID)
+	if err != nil || detail == nil {
+		log.Printf("Warning: failed to load result %d for export: %v", resultID, err)
+		return
+	}
+	if !h.releasable(detail) {
+		return
+	}
+	h.exporter.Export(detail)
+}
+
+// releasable reports whether a result may leave the system as a confirmed
+// match. Under four-eyes review that takes an approver too, which rules out
+// results confirmed before review was switched on.
+func (h *Handler) releasable(detail *models.ScreeningResultDetail) bool {
+	if detail.Status != "CONFIRMED" {
+		return false
+	}
+	return !h.fourEyes || detail.ApprovedBy != ""
+}
@@ -0,0 +1,59 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"testing"
+)
+
+func TestResultRoutesRequireSignIn(t *testing.T) {
+	h := newTestHandler(t, nil)
+	for _, tc := range []struct{ method, path, body string }{
+		{http.MethodPatch, "/results/1/status", `{"status": "CONFIRMED"}`},
+		{http.MethodPost, "/results/1/approval", `{"approve": true}`},
+		{http.MethodGet, "/results/1/comments", ""},
+		{http.MethodPost, "/results/1/comments", `{"body": "checked"}`},
+	} {
+		for _, token := range []string{"", "not-a-token"} {
+			if rec := serve(h, token, tc.method, tc.path, tc.body); rec.Code != http.StatusUnauthorized {
+				t.Errorf("%s %s with token %q: status = %d, want %d", tc.method, tc.path, token, rec.Code, http.StatusUnauthorized)
+			}
+		}
+	}
+}
+
+func TestFourEyesApproval(t *testing.T) {
+	h := newTestHandler(t, map[string]string{"REVIEW_FOUR_EYES": "true"})
+	analyst := signIn(t, h, 1, "analyst@bank.test", "analyst")
+	approver := signIn(t, h, 2, "approver@bank.test", approverRole)
+	resultID := seedResult(t, h)
+	statusPath := fmt.Sprintf("/results/%d/status", resultID)
+	approvalPath := fmt.Sprintf("/results/%d/approval", resultID)
+
+	if got := statusOf(t, serve(h, analyst, http.MethodPatch, statusPath, `{"status": "CONFIRMED"}`)); got != "PENDING_APPROVAL" {
+		t.Fatalf("confirmation by the analyst left status %s, want PENDING_APPROVAL", got)
+	}
+	if rec := serve(h, analyst, http.MethodPost, approvalPath, `{"approve": true}`); rec.Code != http.StatusForbidden {
+		t.Errorf("approval by a non-approver: status = %d, want %d", rec.Code, http.StatusForbidden)
+	}
+	detail, _ := h.repo.GetScreeningResultDetail(context.Background(), resultID)
+	if h.releasable(detail) {
+		t.Error("a confirmation awaiting approval is releasable")
+	}
+
+	if got := statusOf(t, serve(h, approver, http.MethodPost, approvalPath, `{"approve": true}`)); got != "CONFIRMED" {
+		t.Fatalf("approval left status %s, want CONFIRMED", got)
+	}
+	detail, _ = h.repo.GetScreeningResultDetail(context.Background(), resultID)
+	if detail.ProposedBy != "analyst@bank.test" || detail.ApprovedBy != "approver@bank.test" || !h.releasable(detail) {
+		t.Errorf("approved result proposed by %q, approved by %q, releasable %v", detail.ProposedBy, detail.ApprovedBy, h.releasable(detail))
+	}
+
+	// A result confirmed before four-eyes review was switched on has no
+	// approver and is not exported
+	detail.ApprovedBy = ""
+	if h.releasable(detail) {
+		t.Error("a confirmation without an approver is releasable under four-eyes review")
+	}
+}
@@ -0,0 +1,125 @@
+package handlers
+
+import (
+	"context"
+	"log"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/SanthoshCheemala/FLARE/backend/internal/models"
+)
+
+// attributeMappingPrefix marks column mapping keys that hash an attribute:
+// "attributes.passport" reads attribute passport from the mapped column and
+// adds it to the screening's enabled columns
+const attributeMappingPrefix = "attributes."
+
+// builtinCustomerHeaders are the headers read into customer fields when not
+// mapped; every other column of a customer list is kept as an attribute
+var builtinCustomerHeaders = []string{
+	"id", "customer_id", "name", "full_name", "dob", "country",
+	"entity_type", "type", "registration", "registration_number", "imo_number", "imo",
+}
+
+// attributeColumns returns the attributes a column mapping hashes, sorted
+// so both sides serialize them in the same order
+func attributeColumns(columnMapping map[string]string) []string {
+	var columns []string
+	for key, header := range columnMapping {
+		if name, ok := strings.CutPrefix(key, attributeMappingPrefix); ok && header != "" {
+			if name = models.AttributeKey(name); name != "" && !slices.Contains(models.BuiltinColumns, name) {
+				columns = append(columns, name)
+			}
+		}
+	}
+	sort.Strings(columns)
+	return slices.Compact(columns)
+}
+
+// customerAttributeReader returns a function that reads the attributes of a
+// customer list row: every column not read into a built-in field, and the
+// columns mapped to an attribute
+func customerAttributeReader(headers []string, mapping map[string]string) func(record []string) map[string]string {
+	index := make(map[string]int, len(headers))
+	for i, h := range headers {
+		index[strings.ToLower(strings.TrimSpace(h))] = i
+	}
+	used := make(map[int]bool)
+	for _, h := range builtinCustomerHeaders {
+		if i, ok := index[h]; ok {
+			used[i] = true
+		}
+	}
+	mapped := make(map[string]int)
+	for key, header := range mapping {
+		i, ok := index[strings.ToLower(strings.TrimSpace(header))]
+		if !ok {
+			continue
+		}
+		if name, ok := strings.CutPrefix(key, attributeMappingPrefix); ok {
+			mapped[models.AttributeKey(name)] = i
+		}
+		used[i] = true
+	}
+	extra := make(map[string]int)
+	for i, h := range headers {
+		if key := models.AttributeKey(h); !used[i] && key != "" {
+			extra[key] = i
+		}
+	}
+	if len(mapped) == 0 && len(extra) == 0 {
+		return func([]string) map[string]string { return nil }
+	}
+
+	return func(record []string) map[string]string {
+		attributes := make(map[string]string)
+		for _, columns := range []map[string]int{extra, mapped} {
+			for key, i := range columns {
+				if i < len(record) {
+					if v := strings.TrimSpace(record[i]); v != "" {
+						attributes[key] = v
+					}
+				}
+			}
+		}
+		return attributes
+	}
+}
+
+// attributeTypes returns the type hints of the custom fields among columns,
+// as the schemas of the server's lists and the local watchlists define
+// them. Fields without one are hashed as text.
+func (h *Handler) attributeTypes(ctx context.Context, columns []string) map[string]string {
+	var custom []string
+	for _, col := range columns {
+		if !slices.Contains(models.BuiltinColumns, col) {
+			custom = append(custom, col)
+		}
+	}
+	if len(custom) == 0 {
+		return nil
+	}
+
+	types := make(map[string]string, len(custom))
+	add := func(schema []models.ListField) {
+		for _, f := range schema {
+			if _, ok := types[f.Name]; !ok && slices.Contains(custom, f.Name) {
+				types[f.Name] = f.Type
+			}
+		}
+	}
+	if remote, err := h.psiClient.GetSanctionLists(ctx); err != nil {
+		log.Printf("Warning: failed to fetch list schemas for attributes %v: %v", custom, err)
+	} else {
+		for _, l := range remote {
+			add(l.Schema)
+		}
+	}
+	if local, err := h.repo.GetSanctionLists(ctx); err == nil {
+		for _, l := range local {
+			add(l.Schema)
+		}
+	}
+	return types
+}
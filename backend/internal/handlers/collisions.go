@@ -0,0 +1,49 @@
+package handlers
+
+import (
+	"slices"
+
+	"github.com/SanthoshCheemala/FLARE/backend/internal/models"
+	"github.com/SanthoshCheemala/FLARE/backend/internal/psiadapter"
+	"github.com/SanthoshCheemala/FLARE/backend/internal/translit"
+)
+
+// customerBuckets groups customer indexes by set-element hash. Hashes are
+// truncated to 64 bits, so distinct records can share one; every hash keeps
+// all of its customers rather than the last one seen. It also returns the
+// number of such collisions. Duplicate rows hash alike and are not counted.
+func customerBuckets(hashes []uint64, serialized []string) (map[int64][]int, int) {
+	buckets := make(map[int64][]int, len(hashes))
+	collisions := 0
+	for i, hash := range hashes {
+		key := int64(hash)
+		for _, j := range buckets[key] {
+			if serialized[j] != serialized[i] {
+				collisions++
+				break
+			}
+		}
+		buckets[key] = append(buckets[key], i)
+	}
+	return buckets, collisions
+}
+
+// sanctionBuckets groups resolved sanctions by the hash they matched
+func sanctionBuckets(sanctions []*models.Sanction) map[int64][]*models.Sanction {
+	buckets := make(map[int64][]*models.Sanction, len(sanctions))
+	for _, s := range sanctions {
+		buckets[s.Hash] = append(buckets[s.Hash], s)
+	}
+	return buckets
+}
+
+// recordsMatch reports whether a customer's serialized record is one of the
+// sanction's hash inputs under the session's schema, or for the document
+// channel one of its document numbers. A shared hash alone does not prove a
+// match, since it may be a collision.
+func recordsMatch(customer string, sanction *models.Sanction, columns []string, names translit.Profile) bool {
+	if psiadapter.IsDocumentInput(customer) {
+		return slices.Contains(psiadapter.DocumentHashInputs(sanction.Attributes, sanction.Country), customer)
+	}
+	return slices.Contains(psiadapter.SanctionHashInputs(sanction, columns, names), customer)
+}
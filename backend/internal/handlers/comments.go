@@ -0,0 +1,305 @@
+package handlers
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/SanthoshCheemala/FLARE/backend/internal/apierror"
+	"github.com/SanthoshCheemala/FLARE/backend/internal/middleware"
+	"github.com/SanthoshCheemala/FLARE/backend/internal/models"
+	"github.com/go-chi/chi/v5"
+)
+
+// Limits on comment attachments
+const (
+	maxCommentAttachments = 10
+	maxAttachmentBytes    = 10 << 20
+	maxCommentBodyBytes   = maxCommentAttachments*maxAttachmentBytes + 1<<20
+)
+
+// attachmentDir holds the files attached to result comments
+const attachmentDir = "./data/attachments"
+
+// ListResultComments returns a result's comment threads: top-level comments
+// oldest first, each with its replies nested
+func (h *Handler) ListResultComments(w http.ResponseWriter, r *http.Request) {
+	resultID, ok := h.commentResult(w, r)
+	if !ok {
+		return
+	}
+
+	comments, err := h.repo.ListResultComments(r.Context(), resultID)
+	if err != nil {
+		log.Printf("Failed to list comments for result %d: %v", resultID, err)
+		apierror.Write(w, r, http.StatusInternalServerError, apierror.CodeDatabaseError, "Failed to load comments")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"comments": threadComments(comments),
+		"count":    len(comments),
+	})
+}
+
+// AddResultComment posts a comment on a result. It takes JSON
+// ({"body", "parentId", "author"}) or, to attach files, a multipart form
+// with the same fields and one or more "attachments" files. The signed-in
+// user, when there is one, is the author.
+func (h *Handler) AddResultComment(w http.ResponseWriter, r *http.Request) {
+	resultID, ok := h.commentResult(w, r)
+	if !ok {
+		return
+	}
+
+	var req struct {
+		Body     string `json:"body"`
+		ParentID *int64 `json:"parentId"`
+		Author   string `json:"author"`
+	}
+	var files []*multipart.FileHeader
+	r.Body = http.MaxBytesReader(w, r.Body, maxCommentBodyBytes)
+	if strings.HasPrefix(r.Header.Get("Content-Type"), "multipart/form-data") {
+		if err := r.ParseMultipartForm(32 << 20); err != nil {
+			if apierror.TooLarge(w, r, err) {
+				return
+			}
+			apierror.Write(w, r, http.StatusBadRequest, apierror.CodeBadRequest, "Invalid multipart form")
+			return
+		}
+		defer r.MultipartForm.RemoveAll()
+		req.Body = r.FormValue("body")
+		req.Author = r.FormValue("author")
+		if v := r.FormValue("parentId"); v != "" {
+			id, err := strconv.ParseInt(v, 10, 64)
+			if err != nil {
+				apierror.Write(w, r, http.StatusBadRequest, apierror.CodeBadRequest, "Invalid parentId")
+				return
+			}
+			req.ParentID = &id
+		}
+		files = r.MultipartForm.File["attachments"]
+	} else if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		apierror.Write(w, r, http.StatusBadRequest, apierror.CodeBadRequest, "Invalid request body")
+		return
+	}
+
+	comment := &models.ResultComment{
+		ResultID: resultID,
+		ParentID: req.ParentID,
+		Author:   strings.TrimSpace(req.Author),
+		Body:     strings.TrimSpace(req.Body),
+	}
+	if user := middleware.GetUser(r.Context()); user != nil {
+		comment.AuthorID, comment.Author = user.UserID, user.Email
+	}
+	if comment.Author == "" {
+		apierror.Write(w, r, http.StatusBadRequest, apierror.CodeBadRequest, "author is required")
+		return
+	}
+	if comment.Body == "" && len(files) == 0 {
+		apierror.Write(w, r, http.StatusBadRequest, apierror.CodeBadRequest, "A comment needs a body or an attachment")
+		return
+	}
+	if len(files) > maxCommentAttachments {
+		apierror.Write(w, r, http.StatusBadRequest, apierror.CodeBadRequest,
+			fmt.Sprintf("At most %d attachments per comment", maxCommentAttachments))
+		return
+	}
+	if comment.ParentID != nil {
+		parent, err := h.repo.GetResultComment(r.Context(), *comment.ParentID)
+		if err != nil {
+			apierror.Write(w, r, http.StatusInternalServerError, apierror.CodeDatabaseError, "Failed to load parent comment")
+			return
+		}
+		if parent == nil || parent.ResultID != resultID {
+			apierror.Write(w, r, http.StatusBadRequest, apierror.CodeBadRequest, "parentId is not a comment on this result")
+			return
+		}
+	}
+
+	for _, fh := range files {
+		if fh.Size > maxAttachmentBytes {
+			h.removeAttachments(comment.Attachments)
+			apierror.Write(w, r, http.StatusBadRequest, apierror.CodeBadRequest,
+				fmt.Sprintf("Attachment %q exceeds %d MB", fh.Filename, maxAttachmentBytes>>20))
+			return
+		}
+		a, err := h.saveAttachment(resultID, fh)
+		if err != nil {
+			log.Printf("Failed to store attachment for result %d: %v", resultID, err)
+			h.removeAttachments(comment.Attachments)
+			apierror.Write(w, r, http.StatusInternalServerError, apierror.CodeInternal, "Failed to store attachment")
+			return
+		}
+		comment.Attachments = append(comment.Attachments, *a)
+	}
+
+	if err := h.repo.CreateResultComment(r.Context(), comment); err != nil {
+		log.Printf("Failed to save comment on result %d: %v", resultID, err)
+		h.removeAttachments(comment.Attachments)
+		apierror.Write(w, r, http.StatusInternalServerError, apierror.CodeDatabaseError, "Failed to save comment")
+		return
+	}
+	if comment.Attachments == nil {
+		comment.Attachments = make([]models.CommentAttachment, 0)
+	}
+	comment.CreatedAt = time.Now().UTC()
+	for i := range comment.Attachments {
+		comment.Attachments[i].CreatedAt = comment.CreatedAt
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(comment)
+}
+
+// DownloadCommentAttachment returns an attached file. It is always served
+// as a download so uploaded HTML cannot run in the browser.
+func (h *Handler) DownloadCommentAttachment(w http.ResponseWriter, r *http.Request) {
+	resultID, ok := h.commentResult(w, r)
+	if !ok {
+		return
+	}
+	commentID, err := strconv.ParseInt(chi.URLParam(r, "commentId"), 10, 64)
+	if err != nil {
+		apierror.Write(w, r, http.StatusBadRequest, apierror.CodeBadRequest, "Invalid commentId")
+		return
+	}
+	attachmentID, err := strconv.ParseInt(chi.URLParam(r, "attachmentId"), 10, 64)
+	if err != nil {
+		apierror.Write(w, r, http.StatusBadRequest, apierror.CodeBadRequest, "Invalid attachmentId")
+		return
+	}
+
+	a, err := h.repo.GetCommentAttachment(r.Context(), attachmentID)
+	if err != nil {
+		apierror.Write(w, r, http.StatusInternalServerError, apierror.CodeDatabaseError, "Failed to load attachment")
+		return
+	}
+	var comment *models.ResultComment
+	if a != nil && a.CommentID == commentID {
+		if comment, err = h.repo.GetResultComment(r.Context(), commentID); err != nil {
+			apierror.Write(w, r, http.StatusInternalServerError, apierror.CodeDatabaseError, "Failed to load comment")
+			return
+		}
+	}
+	if comment == nil || comment.ResultID != resultID {
+		apierror.Write(w, r, http.StatusNotFound, apierror.CodeNotFound, "Attachment not found")
+		return
+	}
+
+	data, err := h.files.ReadFile(a.FilePath)
+	if err != nil {
+		log.Printf("Failed to read attachment %d: %v", a.ID, err)
+		apierror.Write(w, r, http.StatusInternalServerError, apierror.CodeInternal, "Failed to read attachment")
+		return
+	}
+	w.Header().Set("Content-Type", a.ContentType)
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": a.FileName}))
+	w.Header().Set("Content-Length", strconv.Itoa(len(data)))
+	w.Write(data)
+}
+
+// commentResult parses the resultId URL parameter and checks the result
+// exists, writing the error response if not
+func (h *Handler) commentResult(w http.ResponseWriter, r *http.Request) (int64, bool) {
+	resultID, err := strconv.ParseInt(chi.URLParam(r, "resultId"), 10, 64)
+	if err != nil {
+		apierror.Write(w, r, http.StatusBadRequest, apierror.CodeBadRequest, "Invalid resultId")
+		return 0, false
+	}
+	result, err := h.repo.GetScreeningResultDetail(r.Context(), resultID)
+	if err != nil {
+		apierror.Write(w, r, http.StatusInternalServerError, apierror.CodeDatabaseError, "Failed to load result")
+		return 0, false
+	}
+	if result == nil {
+		apierror.Write(w, r, http.StatusNotFound, apierror.CodeNotFound, "Result not found")
+		return 0, false
+	}
+	return resultID, true
+}
+
+// saveAttachment writes an uploaded file through the at-rest cipher. The
+// stored name is generated; the client's file name is only kept as data.
+func (h *Handler) saveAttachment(resultID int64, fh *multipart.FileHeader) (*models.CommentAttachment, error) {
+	f, err := fh.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	data, err := io.ReadAll(io.LimitReader(f, maxAttachmentBytes+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > maxAttachmentBytes {
+		return nil, fmt.Errorf("attachment %q exceeds %d bytes", fh.Filename, maxAttachmentBytes)
+	}
+
+	dir := filepath.Join(attachmentDir, fmt.Sprintf("result_%d", resultID))
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, err
+	}
+	path := filepath.Join(dir, fmt.Sprintf("attachment_%d", time.Now().UnixNano()))
+	if err := h.files.WriteFile(path, bytes.NewReader(data), 0600); err != nil {
+		return nil, err
+	}
+
+	contentType := fh.Header.Get("Content-Type")
+	if contentType == "" || contentType == "application/octet-stream" {
+		contentType = http.DetectContentType(data)
+	}
+	sum := sha256.Sum256(data)
+	return &models.CommentAttachment{
+		FileName:    filepath.Base(fh.Filename),
+		ContentType: contentType,
+		Size:        int64(len(data)),
+		SHA256:      hex.EncodeToString(sum[:]),
+		FilePath:    path,
+	}, nil
+}
+
+// removeAttachments deletes files written for a comment that was not saved
+func (h *Handler) removeAttachments(attachments []models.CommentAttachment) {
+	for _, a := range attachments {
+		if err := os.Remove(a.FilePath); err != nil {
+			log.Printf("Warning: failed to remove attachment file %s: %v", a.FilePath, err)
+		}
+	}
+}
+
+// threadComments nests replies under the comment they answer. Replies to a
+// missing comment are shown at the top level.
+func threadComments(comments []models.ResultComment) []*models.ResultComment {
+	byID := make(map[int64]*models.ResultComment, len(comments))
+	for i := range comments {
+		byID[comments[i].ID] = &comments[i]
+	}
+	threads := make([]*models.ResultComment, 0)
+	for i := range comments {
+		c := &comments[i]
+		if c.ParentID != nil {
+			if parent, ok := byID[*c.ParentID]; ok {
+				parent.Replies = append(parent.Replies, c)
+				continue
+			}
+		}
+		threads = append(threads, c)
+	}
+	return threads
+}
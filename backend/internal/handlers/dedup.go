@@ -0,0 +1,83 @@
+package handlers
+
+import (
+	"slices"
+
+	"github.com/SanthoshCheemala/FLARE/backend/internal/models"
+	"github.com/SanthoshCheemala/FLARE/backend/internal/psiadapter"
+)
+
+// identityColumns are the sanction fields that identify an entity across
+// lists. The program is left out: lists sanction the same party under
+// different programs.
+var identityColumns = []string{"name", "dob", "country", "imo", "registration"}
+
+// sanctionIdentity is a sanction's entity type and normalized identifying
+// fields, equal for the same entity on different lists
+func sanctionIdentity(s *models.Sanction) string {
+	values := s.HashValues()
+	key := entityType(s.EntityType)
+	for _, column := range identityColumns {
+		key += "\x00" + psiadapter.NormalizeColumn(column, values[column])
+	}
+	return key
+}
+
+// membership is the list a match's sanction came from
+func membership(rec models.MatchRecord) models.ListMembership {
+	source := rec.Result.ListSource
+	if source == "" {
+		source = models.ListSourceRemote
+	}
+	return models.ListMembership{
+		ListID:     rec.Sanction.ListID,
+		ListSource: source,
+		Source:     rec.Sanction.Source,
+		Category:   rec.Sanction.Category,
+		Program:    rec.Sanction.Program,
+		SourceRef:  rec.Sanction.SourceRef,
+	}
+}
+
+// dedupeRecords consolidates the matches of one customer to the same entity
+// on several lists, or on both channels, into one record listing every
+// list. The first match of each is kept, unless it was suppressed and a
+// later one was not: a suppression covers one list's entry, and the entity
+// still needs review. A document match marks the kept record DOC_MATCH.
+func dedupeRecords(records []models.MatchRecord) []models.MatchRecord {
+	type key struct {
+		customer *models.Customer
+		identity string
+	}
+	index := make(map[key]int, len(records))
+	deduped := make([]models.MatchRecord, 0, len(records))
+	for _, rec := range records {
+		k := key{rec.Customer, sanctionIdentity(rec.Sanction)}
+		i, seen := index[k]
+		if !seen {
+			rec.Result.Lists = []models.ListMembership{membership(rec)}
+			index[k] = len(deduped)
+			deduped = append(deduped, rec)
+			continue
+		}
+
+		kept := &deduped[i]
+		lists := kept.Result.Lists
+		if m := membership(rec); !slices.Contains(lists, m) {
+			lists = append(lists, m)
+		}
+		document := kept.Result
+		if rec.Result.Channel == models.MatchChannelDocument {
+			document = rec.Result
+		}
+		if kept.Result.Status == "SUPPRESSED" && rec.Result.Status != "SUPPRESSED" {
+			*kept = rec
+		}
+		if document.Channel == models.MatchChannelDocument && kept.Result != document {
+			kept.Result.Channel = document.Channel
+			kept.Result.Explanation = document.Explanation
+		}
+		kept.Result.Lists = lists
+	}
+	return deduped
+}
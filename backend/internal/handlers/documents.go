@@ -0,0 +1,74 @@
+package handlers
+
+import (
+	"log"
+	"slices"
+
+	"github.com/SanthoshCheemala/FLARE/backend/internal/models"
+	"github.com/SanthoshCheemala/FLARE/backend/internal/psiadapter"
+)
+
+// documentProfile names the serialization profile of document channel
+// matches in their explanation
+const documentProfile = "document"
+
+// addDocumentInputs appends the document channel to a screening's customer
+// set: one element per valid passport or national ID number among each
+// customer's attributes, paired with its customer so resolution finds it
+// like any other element. Numbers failing their format or checksum are
+// left out.
+func addDocumentInputs(customers []*models.Customer, serialized []string) ([]*models.Customer, []string) {
+	added, invalid := 0, 0
+	for i := range len(customers) {
+		inputs, bad := psiadapter.CustomerDocumentInputs(customers[i])
+		invalid += bad
+		for _, input := range inputs {
+			customers = append(customers, customers[i])
+			serialized = append(serialized, input)
+			added++
+		}
+	}
+	if added > 0 || invalid > 0 {
+		log.Printf("Document channel: %d document numbers added, %d failed validation", added, invalid)
+	}
+	return customers, serialized
+}
+
+// hasDocumentInputs reports whether a customer set includes the document
+// channel
+func hasDocumentInputs(serialized []string) bool {
+	return slices.ContainsFunc(serialized, psiadapter.IsDocumentInput)
+}
+
+// customerCount is the number of customers in a set that may include the
+// document channel
+func customerCount(serialized []string) int {
+	n := 0
+	for _, s := range serialized {
+		if !psiadapter.IsDocumentInput(s) {
+			n++
+		}
+	}
+	return n
+}
+
+// matchChannel is the channel a customer's set element matched on
+func matchChannel(serialized string) string {
+	if psiadapter.IsDocumentInput(serialized) {
+		return models.MatchChannelDocument
+	}
+	return models.MatchChannelName
+}
+
+// explainDocumentMatch records a document channel match: the kind of
+// document and the normalized number both sides hold
+func explainDocumentMatch(serialized string, scheme psiadapter.HashScheme) *models.MatchExplanation {
+	kind, number, _ := psiadapter.ParseDocumentInput(serialized)
+	return &models.MatchExplanation{
+		Profile:    documentProfile,
+		Columns:    []string{kind},
+		Fields:     []models.FieldComparison{{Column: kind, Customer: number, Sanction: number, Equal: true}},
+		HashScheme: scheme.String(),
+		Serialized: serialized,
+	}
+}
@@ -0,0 +1,127 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/SanthoshCheemala/FLARE/backend/internal/events"
+)
+
+// Audit actions recorded from events
+const (
+	auditScreeningStart  = "SCREENING_START"
+	auditScreeningFinish = "SCREENING_FINISH"
+	auditMatchConfirm    = "MATCH_CONFIRM"
+	auditListUpdate      = "LIST_UPDATE"
+)
+
+const (
+	// eventStreamBuffer is the number of events queued per /events client
+	eventStreamBuffer = 64
+	// eventStreamKeepAlive is how often an idle /events stream sends a comment
+	eventStreamKeepAlive = 15 * time.Second
+)
+
+// subscribeEvents attaches the subsystems that react to domain events
+func (h *Handler) subscribeEvents() {
+	h.events.Subscribe("export", h.exportConfirmed, events.TypeMatchConfirmed)
+	h.events.Subscribe("audit", h.auditEvent,
+		events.TypeJobStarted, events.TypeJobFinished, events.TypeMatchConfirmed, events.TypeListUpdated)
+}
+
+// exportConfirmed pushes a confirmed match to external case management
+func (h *Handler) exportConfirmed(e events.Envelope) {
+	h.exportResult(context.Background(), e.Data.(events.MatchConfirmed).ResultID)
+}
+
+// auditEvent records jobs, confirmations and list changes in the audit log
+func (h *Handler) auditEvent(e events.Envelope) {
+	ctx := context.Background()
+	switch ev := e.Data.(type) {
+	case events.JobStarted:
+		h.auditEntity(ctx, actor{id: ev.CreatedBy, name: "screening"}, auditScreeningStart, "screening", ev.JobID,
+			map[string]interface{}{"name": ev.Name})
+	case events.JobFinished:
+		details := map[string]interface{}{"status": ev.Status, "matchCount": ev.MatchCount}
+		if ev.Error != "" {
+			details["error"] = ev.Error
+		}
+		h.auditEntity(ctx, actor{name: "screening"}, auditScreeningFinish, "screening", ev.JobID, details)
+	case events.MatchConfirmed:
+		h.auditEntity(ctx, actor{name: ev.By}, auditMatchConfirm, "result", strconv.FormatInt(ev.ResultID, 10), nil)
+	case events.ListUpdated:
+		by := ev.By
+		if by == "" {
+			by = "server"
+		}
+		h.auditEntity(ctx, actor{name: by}, auditListUpdate, ev.Kind+"_list", ev.ID,
+			map[string]interface{}{"action": ev.Action})
+	}
+}
+
+// publishListUpdate publishes a change to a list made by r's user
+func (h *Handler) publishListUpdate(r *http.Request, kind string, id int64, action string) {
+	h.events.Publish(events.ListUpdated{
+		Kind:   kind,
+		ID:     strconv.FormatInt(id, 10),
+		Action: action,
+		By:     requestActor(r.Context()).name,
+	})
+}
+
+// StreamEvents streams domain events as server-sent events, for live
+// dashboards. ?types= limits the stream to a comma-separated list of event
+// types, e.g. "job.finished,list.updated". Clients that fall behind lose
+// events rather than slowing the backend.
+func (h *Handler) StreamEvents(w http.ResponseWriter, r *http.Request) {
+	var types []events.Type
+	if t := r.URL.Query().Get("types"); t != "" {
+		for _, name := range strings.Split(t, ",") {
+			types = append(types, events.Type(strings.TrimSpace(name)))
+		}
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		fmt.Fprintf(w, "event: error\ndata: Streaming unsupported\n\n")
+		return
+	}
+
+	queue := make(chan events.Envelope, eventStreamBuffer)
+	unsubscribe := h.events.Subscribe("stream "+r.RemoteAddr, func(e events.Envelope) {
+		select {
+		case queue <- e:
+		default:
+			log.Printf("Event stream %s is falling behind, dropping %s event", r.RemoteAddr, e.Type)
+		}
+	}, types...)
+	defer unsubscribe()
+
+	fmt.Fprintf(w, ": connected\n\n")
+	flusher.Flush()
+
+	keepAlive := time.NewTicker(eventStreamKeepAlive)
+	defer keepAlive.Stop()
+	for {
+		select {
+		case e := <-queue:
+			data, _ := json.Marshal(e)
+			fmt.Fprintf(w, "event: %s\ndata: %s\n\n", e.Type, data)
+			flusher.Flush()
+		case <-keepAlive.C:
+			fmt.Fprintf(w, ": keep-alive\n\n")
+			flusher.Flush()
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
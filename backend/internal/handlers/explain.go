@@ -0,0 +1,50 @@
+package handlers
+
+import (
+	"github.com/SanthoshCheemala/FLARE/backend/internal/models"
+	"github.com/SanthoshCheemala/FLARE/backend/internal/psiadapter"
+	"github.com/SanthoshCheemala/FLARE/backend/internal/translit"
+)
+
+// explainMatch records why a customer matched a sanction under the session
+// schema: the profile and columns hashed, each column's normalized values,
+// and which of the sanction's names matched. serialized is the customer's
+// hash input.
+func explainMatch(customer *models.Customer, sanction *models.Sanction, serialized string, columns []string, names translit.Profile, scheme psiadapter.HashScheme) *models.MatchExplanation {
+	cols := psiadapter.EntityColumns(customer.EntityType, columns)
+	customerValues := customer.HashValues()
+	customerValues["name"] = names.Apply(customerValues["name"])
+
+	// Find the sanction name the customer matched: the primary name or one
+	// of the aliases
+	sanctionValues := sanction.HashValues()
+	var matchedAlias string
+	for _, alias := range append([]string{""}, sanction.Aliases...) {
+		v := sanction.HashValues()
+		if alias != "" {
+			v["name"] = alias
+		}
+		v["name"] = names.Apply(v["name"])
+		if psiadapter.SerializeEntity(sanction.EntityType, v, columns) == serialized {
+			sanctionValues, matchedAlias = v, alias
+			break
+		}
+	}
+
+	fields := make([]models.FieldComparison, len(cols))
+	for i, col := range cols {
+		c := psiadapter.NormalizeColumn(col, customerValues[col])
+		s := psiadapter.NormalizeColumn(col, sanctionValues[col])
+		fields[i] = models.FieldComparison{Column: col, Customer: c, Sanction: s, Equal: c == s}
+	}
+
+	return &models.MatchExplanation{
+		Profile:         psiadapter.ProfileName(customer.EntityType),
+		Columns:         cols,
+		Fields:          fields,
+		MatchedAlias:    matchedAlias,
+		Transliteration: names.String(),
+		HashScheme:      scheme.String(),
+		Serialized:      serialized,
+	}
+}
@@ -0,0 +1,33 @@
+package handlers
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/SanthoshCheemala/FLARE/backend/internal/psiadapter"
+	"github.com/SanthoshCheemala/FLARE/backend/internal/translit"
+)
+
+func FuzzReadCustomerCSV(f *testing.F) {
+	f.Add([]byte("id,name,dob,country\n1,John Smith,1990-01-02,US\n2,Jane Doe,02/01/1990,GB\n"), "")
+	f.Add([]byte("customer_id,full_name,date_of_birth\n7,\"Smith, John\",31/12/1980\n"), "full_name")
+	f.Add([]byte("\xef\xbb\xbfname\n\"unterminated\n"), "name")
+	f.Add([]byte("id,name,entity_type,imo\n1,Ever Given,vessel,9811000\n2,,organization,\n"), "")
+	f.Add([]byte("a,b\n1,2,3\n\n"), "b")
+	f.Add([]byte(""), "")
+	f.Fuzz(func(t *testing.T, data []byte, nameColumn string) {
+		var mapping map[string]string
+		if nameColumn != "" {
+			mapping = map[string]string{"name": nameColumn, "organization.name": nameColumn}
+		}
+		columns := []string{"name", "dob", "country"}
+		names := translit.Profile{Version: translit.Version, Scripts: []string{"cyrillic"}}
+		customers, serialized, err := ReadCustomerCSV(bytes.NewReader(data), 1, mapping, columns, names, psiadapter.DefaultDateOrder, nil)
+		if err != nil {
+			return
+		}
+		if len(customers) != len(serialized) {
+			t.Fatalf("%d customers but %d serialized elements", len(customers), len(serialized))
+		}
+	})
+}
@@ -3,12 +3,15 @@ package handlers
 
 import (
 	"bufio"
+	"bytes"
 	"context"
 	"encoding/csv"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"log"
+	"maps"
 	"net/http"
 	"os"
 	"path/filepath"
@@ -18,17 +21,35 @@ import (
 	"strings"
 	"time"
 
+	"github.com/SanthoshCheemala/FLARE/backend/internal/apierror"
+	"github.com/SanthoshCheemala/FLARE/backend/internal/atrest"
 	"github.com/SanthoshCheemala/FLARE/backend/internal/auth"
 	"github.com/SanthoshCheemala/FLARE/backend/internal/client"
 	"github.com/SanthoshCheemala/FLARE/backend/internal/config"
+	"github.com/SanthoshCheemala/FLARE/backend/internal/ctcache"
+	"github.com/SanthoshCheemala/FLARE/backend/internal/events"
+	"github.com/SanthoshCheemala/FLARE/backend/internal/integrations"
 	"github.com/SanthoshCheemala/FLARE/backend/internal/jobs"
+	"github.com/SanthoshCheemala/FLARE/backend/internal/metrics"
+	"github.com/SanthoshCheemala/FLARE/backend/internal/middleware"
 	"github.com/SanthoshCheemala/FLARE/backend/internal/models"
+	"github.com/SanthoshCheemala/FLARE/backend/internal/profiling"
+	"github.com/SanthoshCheemala/FLARE/backend/internal/protocol"
 	"github.com/SanthoshCheemala/FLARE/backend/internal/psiadapter"
+	"github.com/SanthoshCheemala/FLARE/backend/internal/rehash"
 	"github.com/SanthoshCheemala/FLARE/backend/internal/repository"
+	"github.com/SanthoshCheemala/FLARE/backend/internal/textenc"
+	"github.com/SanthoshCheemala/FLARE/backend/internal/translit"
+	"github.com/SanthoshCheemala/FLARE/backend/internal/uploadscan"
+	"github.com/SanthoshCheemala/FLARE/backend/internal/xlsx"
 	"github.com/go-chi/chi/v5"
 	"github.com/gorilla/websocket"
 )
 
+// encryptChunks is the number of chunks client encryption is split into so
+// progress and throughput can be reported while it runs
+const encryptChunks = 20
+
 func min(a, b int) int {
 	if a < b {
 		return a
@@ -41,20 +62,147 @@ type Handler struct {
 	jobManager *jobs.Manager
 	psi        *psiadapter.Adapter
 	psiClient  *client.PSIClient
+	// sessions carries the PSI session exchange: psiClient, or the message
+	// queue in asynchronous mode
+	sessions   protocol.Transport
 	auth       *auth.Service
+	exporter   *integrations.Exporter
+	events     *events.Bus // Domain events, consumed by exports, audit and /events
+	files      *atrest.Cipher      // Encrypts uploaded lists at rest; nil stores plaintext
+	uploads    *uploadscan.Checker // Scans uploaded lists before they are stored
+	psiConfig  config.PSIConfig
+	adminToken string
+	profiles   *profiling.Recorder // Captures profiles of a screening on request
+	packs      *packWatcher        // Follows subscribed watchlist packs
+	fourEyes   bool                // CONFIRMED needs a second user's approval
+	// suppressionDays is the default lifetime of a false-positive suppression
+	suppressionDays int
+	starting        startingKeys // Idempotency-Keys of screenings being started
+	rehash          *rehash.Job  // Rebuilds stored record hashes after a version change
+	// ciphertexts caches encrypted customer lists across screenings; nil
+	// when disabled
+	ciphertexts *ctcache.Store
+	// compression is the gzip level of responses; 0 disables compression
+	compression int
+	// bodyLimits caps request bodies by route
+	bodyLimits middleware.BodyLimits
 }
 
+// NewHandler builds the client backend's handlers. A nil authSvc signs
+// users in with the JWT settings of cfg.
 func NewHandler(repo *repository.Repository, jobManager *jobs.Manager, cfg *config.Config, authSvc *auth.Service) *Handler {
+	if authSvc == nil {
+		authSvc = auth.NewService(cfg.JWT.AccessSecret, cfg.JWT.RefreshSecret, cfg.JWT.AccessExpiry, cfg.JWT.RefreshExpiry, cfg.JWT.Issuer)
+	}
+
 	// Initialize PSI client pointing to the remote server
-	// In a real app, this URL would come from config
-	psiClient := client.NewPSIClient("http://localhost:8081")
+	psiClient := client.NewPSIClient(cfg.PSI.ServerURL)
+	psiClient.SetAPIKey(cfg.PSI.ServerAPIKey)
+	var sessions protocol.Transport = psiClient
+	if cfg.PSI.QueueURL != "" {
+		queueClient := client.NewQueueClient(cfg.PSI.QueueURL, cfg.PSI.QueueSubject, cfg.PSI.QueueTimeout)
+		queueClient.SetAPIKey(cfg.PSI.ServerAPIKey)
+		sessions = queueClient
+	}
+
+	files, err := atrest.New(cfg.Storage.EncryptionKey)
+	if err != nil {
+		log.Fatalf("Invalid storage encryption key: %v", err)
+	}
 
-	return &Handler{
+	bus := events.NewBus()
+	h := &Handler{
 		repo:       repo,
 		jobManager: jobManager,
 		psi:        psiadapter.NewAdapter(cfg.PSI.MaxWorkers),
 		psiClient:  psiClient,
+		sessions:   sessions,
 		auth:       authSvc,
+		exporter:   newExporter(cfg.Export),
+		events:     bus,
+		files:      files,
+		uploads:    uploadscan.New(cfg.Upload),
+		psiConfig:  cfg.PSI,
+		adminToken: cfg.Server.AdminToken,
+		profiles:   profiling.NewRecorder("./data/profiles"),
+		packs:      newPackWatcher(repo, psiClient, bus),
+		fourEyes:   cfg.Review.FourEyes,
+
+		suppressionDays: cfg.Review.SuppressionDays,
+		rehash:          rehash.New(repo),
+	}
+	if cfg.Server.Compression {
+		h.compression = cfg.Server.CompressionLevel
+	}
+	uploadBytes := int64(cfg.Upload.MaxMB)<<20 + 1<<20 // The file and its multipart framing
+	h.bodyLimits = middleware.BodyLimits{
+		Default: int64(cfg.Server.MaxBodyKB) << 10,
+		Routes: map[string]int64{
+			"/lists/customers/upload":      uploadBytes,
+			"/lists/sanctions/upload":      uploadBytes,
+			"/results/{resultId}/comments": maxCommentBodyBytes,
+		},
+	}
+	maps.Copy(h.bodyLimits.Routes, cfg.Server.BodyLimitRoutes())
+	if cfg.PSI.CiphertextCacheMB > 0 {
+		h.ciphertexts = ctcache.New("./data/ciphertexts", files, int64(cfg.PSI.CiphertextCacheMB)<<20)
+	}
+	h.exporter.Start(context.Background())
+	h.subscribeEvents()
+	h.packs.resume(context.Background())
+	// Persist final snapshots so history survives job eviction
+	jobManager.SetFinishHook(h.persistFinishedJob)
+	jobManager.SetBus(bus)
+	h.rehash.StartIfStale(context.Background())
+	go h.sweepCheckpoints(context.Background(), cfg.PSI.RetryRetention)
+	if cfg.PSI.SanctionRetentionDays > 0 {
+		go h.sweepSanctionCopies(context.Background(), cfg.PSI.SanctionRetentionDays)
+	}
+	return h
+}
+
+// newExporter builds a connector for each configured export endpoint
+func newExporter(cfg config.ExportConfig) *integrations.Exporter {
+	var connectors []*integrations.Connector
+	if cfg.WebhookURL != "" {
+		connectors = append(connectors, integrations.NewConnector("webhook", cfg.WebhookURL, cfg.AuthToken,
+			integrations.JSONFormat, cfg.MaxRetries, cfg.QueueSize))
+	}
+	if cfg.STIXURL != "" {
+		connectors = append(connectors, integrations.NewConnector("stix", cfg.STIXURL, cfg.AuthToken,
+			integrations.STIXFormat, cfg.MaxRetries, cfg.QueueSize))
+	}
+	return integrations.NewExporter(connectors...)
+}
+
+// writeUpstreamError relays an error envelope returned by the PSI server,
+// or reports a generic upstream failure when the server could not be reached
+func writeUpstreamError(w http.ResponseWriter, r *http.Request, err error, message string) {
+	var apiErr *apierror.Error
+	if errors.As(err, &apiErr) {
+		apierror.WriteDetails(w, r, apiErr.Status, apiErr.Code, apiErr.Message, apiErr.Details)
+		return
+	}
+	apierror.Write(w, r, http.StatusBadGateway, apierror.CodeUpstreamFailed, message)
+}
+
+// persistFinishedJob writes a finished job's final state to the screenings table
+func (h *Handler) persistFinishedJob(job *jobs.ScreeningJob) {
+	screening := &models.Screening{
+		JobID:            job.ID,
+		Status:           string(job.Status),
+		MatchCount:       job.MatchCount,
+		CustomerCount:    job.CustomerCount,
+		SanctionCount:    job.SanctionCount,
+		WorkerCount:      job.WorkerCount,
+		MemoryEstimateMB: job.MemoryEstimateMB,
+		MemoryLimitGB:    job.MemoryLimitGB,
+		StartedAt:        job.StartedAt,
+		FinishedAt:       job.FinishedAt,
+		Error:            job.Error,
+	}
+	if err := h.repo.UpdateScreeningFinal(context.Background(), screening); err != nil {
+		log.Printf("Warning: failed to persist final state of job %s: %v", job.ID, err)
 	}
 }
 
@@ -66,25 +214,25 @@ func (h *Handler) Login(w http.ResponseWriter, r *http.Request) {
 	}
 
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		apierror.Write(w, r, http.StatusBadRequest, apierror.CodeBadRequest, "Invalid request body")
 		return
 	}
 
 	user, err := h.repo.GetUserByEmail(r.Context(), req.Email)
 	if err != nil {
 		log.Printf("Login error for %s: %v", req.Email, err)
-		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		apierror.Write(w, r, http.StatusInternalServerError, apierror.CodeInternal, "Internal server error")
 		return
 	}
 
 	if user == nil || !auth.CheckPassword(req.Password, user.PasswordHash) {
 		// Use constant time comparison to prevent timing attacks (CheckPassword does this)
-		http.Error(w, "Invalid credentials", http.StatusUnauthorized)
+		apierror.Write(w, r, http.StatusUnauthorized, apierror.CodeUnauthorized, "Invalid credentials")
 		return
 	}
 
 	if !user.Active {
-		http.Error(w, "Account inactive", http.StatusForbidden)
+		apierror.Write(w, r, http.StatusForbidden, apierror.CodeForbidden, "Account inactive")
 		return
 	}
 
@@ -93,13 +241,13 @@ func (h *Handler) Login(w http.ResponseWriter, r *http.Request) {
 
 	accessToken, err := h.auth.GenerateAccessToken(user.ID, user.Email, user.Role)
 	if err != nil {
-		http.Error(w, "Failed to generate token", http.StatusInternalServerError)
+		apierror.Write(w, r, http.StatusInternalServerError, apierror.CodeInternal, "Failed to generate token")
 		return
 	}
 
 	refreshToken, err := h.auth.GenerateRefreshToken(user.ID, user.Email, user.Role)
 	if err != nil {
-		http.Error(w, "Failed to generate token", http.StatusInternalServerError)
+		apierror.Write(w, r, http.StatusInternalServerError, apierror.CodeInternal, "Failed to generate token")
 		return
 	}
 
@@ -118,16 +266,35 @@ func (h *Handler) Login(w http.ResponseWriter, r *http.Request) {
 func (h *Handler) UploadCustomerList(w http.ResponseWriter, r *http.Request) {
 	// Parse multipart form
 	if err := r.ParseMultipartForm(10 << 20); err != nil { // 10 MB max
-		http.Error(w, "File too large", http.StatusBadRequest)
+		if apierror.TooLarge(w, r, err) {
+			return
+		}
+		apierror.Write(w, r, http.StatusBadRequest, apierror.CodeBadRequest, "File too large")
 		return
 	}
 
-	file, _, err := r.FormFile("file")
+	file, header, err := r.FormFile("file")
 	if err != nil {
-		http.Error(w, "Missing file", http.StatusBadRequest)
+		apierror.Write(w, r, http.StatusBadRequest, apierror.CodeBadRequest, "Missing file")
 		return
 	}
 	defer file.Close()
+	content, err := h.uploads.Check(r.Context(), header.Filename, file)
+	if err != nil {
+		log.Printf("Refused customer upload %s: %v", header.Filename, err)
+		uploadscan.WriteError(w, r, err)
+		return
+	}
+	if xlsx.IsWorkbook(header.Filename, content) {
+		if content, err = xlsx.ToCSV(content, r.FormValue("sheet")); err != nil {
+			apierror.Write(w, r, http.StatusBadRequest, apierror.CodeBadRequest, fmt.Sprintf("Invalid spreadsheet: %v", err))
+			return
+		}
+	}
+	content, quality := textenc.ToUTF8(content)
+	if len(quality.Warnings) > 0 {
+		log.Printf("Warning: customer upload %s read as %s", header.Filename, quality.Encoding)
+	}
 
 	name := r.FormValue("name")
 	description := r.FormValue("description")
@@ -138,7 +305,7 @@ func (h *Handler) UploadCustomerList(w http.ResponseWriter, r *http.Request) {
 	// Save file to disk instead of DB
 	uploadDir := "./data/uploads"
 	if err := os.MkdirAll(uploadDir, 0700); err != nil {
-		http.Error(w, "Failed to create upload directory", http.StatusInternalServerError)
+		apierror.Write(w, r, http.StatusInternalServerError, apierror.CodeInternal, "Failed to create upload directory")
 		return
 	}
 
@@ -151,17 +318,8 @@ func (h *Handler) UploadCustomerList(w http.ResponseWriter, r *http.Request) {
 		log.Printf("Saving customer upload to: %s", absPath)
 	}
 
-	dst, err := os.Create(finalPath)
-	if err != nil {
-		http.Error(w, "Failed to save file", http.StatusInternalServerError)
-		return
-	}
-	defer dst.Close()
-
-	// Reset file pointer to beginning
-	file.Seek(0, 0)
-	if _, err := io.Copy(dst, file); err != nil {
-		http.Error(w, "Failed to write file", http.StatusInternalServerError)
+	if err := h.files.WriteFile(finalPath, bytes.NewReader(content), 0600); err != nil {
+		apierror.Write(w, r, http.StatusInternalServerError, apierror.CodeInternal, "Failed to write file")
 		return
 	}
 
@@ -176,7 +334,7 @@ func (h *Handler) UploadCustomerList(w http.ResponseWriter, r *http.Request) {
 	if err != nil {
 		log.Printf("Error creating customer list in DB: %v", err)
 		os.Remove(finalPath) // Cleanup
-		http.Error(w, fmt.Sprintf("Failed to create list: %v", err), http.StatusInternalServerError)
+		apierror.Write(w, r, http.StatusInternalServerError, apierror.CodeDatabaseError, fmt.Sprintf("Failed to create list: %v", err))
 		return
 	}
 	log.Printf("Created customer list ID %d with file path: %s", listID, absPath)
@@ -186,7 +344,7 @@ func (h *Handler) UploadCustomerList(w http.ResponseWriter, r *http.Request) {
 	
 	// Count lines for response (using CSV reader for accuracy)
 	count := 0
-	if csvFile, err := os.Open(finalPath); err == nil {
+	if csvFile, err := h.files.OpenFile(finalPath); err == nil {
 		defer csvFile.Close()
 		reader := csv.NewReader(csvFile)
 		// Skip header
@@ -207,25 +365,21 @@ func (h *Handler) UploadCustomerList(w http.ResponseWriter, r *http.Request) {
 	if err != nil {
 		log.Printf("Warning: failed to update record count: %v", err)
 	}
+	h.publishListUpdate(r, events.ListCustomer, listID, events.ListCreated)
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(map[string]interface{}{
-		"id":    listID,
-		"count": count,
+		"id":      listID,
+		"count":   count,
+		"quality": quality,
 	})
 }
 
-// UploadSanctionList handles uploading a new sanction list CSV
-func (h *Handler) UploadSanctionList(w http.ResponseWriter, r *http.Request) {
-	// In distributed mode, Client cannot upload sanctions.
-	http.Error(w, "Sanction upload is only allowed on the Sanctions Authority Server", http.StatusForbidden)
-}
-
 // GetCustomerLists returns available customer lists
 func (h *Handler) GetCustomerLists(w http.ResponseWriter, r *http.Request) {
 	lists, err := h.repo.GetCustomerLists(r.Context())
 	if err != nil {
-		http.Error(w, "Database error", http.StatusInternalServerError)
+		apierror.Write(w, r, http.StatusInternalServerError, apierror.CodeDatabaseError, "Database error")
 		return
 	}
 	w.Header().Set("Content-Type", "application/json")
@@ -237,13 +391,13 @@ func (h *Handler) GetCustomerListHeaders(w http.ResponseWriter, r *http.Request)
 	idStr := chi.URLParam(r, "id")
 	id, err := strconv.ParseInt(idStr, 10, 64)
 	if err != nil {
-		http.Error(w, "Invalid list ID", http.StatusBadRequest)
+		apierror.Write(w, r, http.StatusBadRequest, apierror.CodeBadRequest, "Invalid list ID")
 		return
 	}
 
 	lists, err := h.repo.GetCustomerLists(r.Context())
 	if err != nil {
-		http.Error(w, "Database error", http.StatusInternalServerError)
+		apierror.Write(w, r, http.StatusInternalServerError, apierror.CodeDatabaseError, "Database error")
 		return
 	}
 
@@ -256,13 +410,13 @@ func (h *Handler) GetCustomerListHeaders(w http.ResponseWriter, r *http.Request)
 	}
 
 	if filePath == "" {
-		http.Error(w, "List not found", http.StatusNotFound)
+		apierror.Write(w, r, http.StatusNotFound, apierror.CodeListNotFound, "List not found")
 		return
 	}
 
-	file, err := os.Open(filePath)
+	file, err := h.files.OpenFile(filePath)
 	if err != nil {
-		http.Error(w, "Failed to open file", http.StatusInternalServerError)
+		apierror.Write(w, r, http.StatusInternalServerError, apierror.CodeInternal, "Failed to open file")
 		return
 	}
 	defer file.Close()
@@ -270,7 +424,7 @@ func (h *Handler) GetCustomerListHeaders(w http.ResponseWriter, r *http.Request)
 	reader := csv.NewReader(file)
 	headers, err := reader.Read()
 	if err != nil {
-		http.Error(w, "Failed to read CSV headers", http.StatusInternalServerError)
+		apierror.Write(w, r, http.StatusInternalServerError, apierror.CodeInternal, "Failed to read CSV headers")
 		return
 	}
 
@@ -285,65 +439,190 @@ func (h *Handler) DeleteCustomerList(w http.ResponseWriter, r *http.Request) {
 	idStr := chi.URLParam(r, "id")
 	id, err := strconv.ParseInt(idStr, 10, 64)
 	if err != nil {
-		http.Error(w, "Invalid list ID", http.StatusBadRequest)
+		apierror.Write(w, r, http.StatusBadRequest, apierror.CodeBadRequest, "Invalid list ID")
 		return
 	}
 
 	if err := h.repo.DeleteCustomerList(r.Context(), id); err != nil {
 		log.Printf("Failed to delete customer list: %v", err)
-		http.Error(w, "Failed to delete customer list", http.StatusInternalServerError)
+		apierror.Write(w, r, http.StatusInternalServerError, apierror.CodeDatabaseError, "Failed to delete customer list")
 		return
 	}
+	h.publishListUpdate(r, events.ListCustomer, id, events.ListDeleted)
 
 	w.WriteHeader(http.StatusOK)
 	json.NewEncoder(w).Encode(map[string]bool{"success": true})
 }
 
-// GetSanctionLists returns available sanction lists from the Server
+// GetSanctionLists returns available sanction lists from the Server, or the
+// local watchlists with listSource=LOCAL
 func (h *Handler) GetSanctionLists(w http.ResponseWriter, r *http.Request) {
+	source, ok := models.ParseListSource(r.URL.Query().Get("listSource"))
+	if !ok || source == models.ListSourceHybrid {
+		apierror.Write(w, r, http.StatusBadRequest, apierror.CodeBadRequest, "listSource must be REMOTE or LOCAL")
+		return
+	}
+	if source == models.ListSourceLocal {
+		h.getWatchlists(w, r)
+		return
+	}
+
 	// Fetch from remote server
 	lists, err := h.psiClient.GetSanctionLists(r.Context())
 	if err != nil {
 		log.Printf("Failed to fetch sanction lists from server: %v", err)
-		http.Error(w, "Failed to fetch sanction lists", http.StatusInternalServerError)
+		writeUpstreamError(w, r, err, "Failed to fetch sanction lists")
 		return
 	}
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(lists)
 }
 
-// DeleteSanctionList deletes a sanction list (proxies to server)
+// DeleteSanctionList deletes a sanction list (proxies to server), or a local
+// watchlist with listSource=LOCAL
 func (h *Handler) DeleteSanctionList(w http.ResponseWriter, r *http.Request) {
 	idStr := chi.URLParam(r, "id")
 	id, err := strconv.ParseInt(idStr, 10, 64)
 	if err != nil {
-		http.Error(w, "Invalid list ID", http.StatusBadRequest)
+		apierror.Write(w, r, http.StatusBadRequest, apierror.CodeBadRequest, "Invalid list ID")
+		return
+	}
+	source, ok := models.ParseListSource(r.URL.Query().Get("listSource"))
+	if !ok || source == models.ListSourceHybrid {
+		apierror.Write(w, r, http.StatusBadRequest, apierror.CodeBadRequest, "listSource must be REMOTE or LOCAL")
+		return
+	}
+	if source == models.ListSourceLocal {
+		h.deleteWatchlist(w, r, id)
 		return
 	}
 
 	if err := h.psiClient.DeleteSanctionList(r.Context(), id); err != nil {
 		log.Printf("Failed to delete sanction list: %v", err)
-		http.Error(w, "Failed to delete sanction list", http.StatusInternalServerError)
+		writeUpstreamError(w, r, err, "Failed to delete sanction list")
 		return
 	}
+	h.publishListUpdate(r, events.ListSanction, id, events.ListDeleted)
 
 	w.WriteHeader(http.StatusOK)
 	json.NewEncoder(w).Encode(map[string]bool{"success": true})
 }
 
-// StartScreening initiates a new screening job
+// StartScreening initiates a new screening job. A request repeating the
+// Idempotency-Key of one that started a screening within the idempotency
+// window returns that screening's job instead of starting another.
 func (h *Handler) StartScreening(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		if apierror.TooLarge(w, r, err) {
+			return
+		}
+		apierror.Write(w, r, http.StatusBadRequest, apierror.CodeBadRequest, "Invalid request body")
+		return
+	}
 	var req models.StartScreeningRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
+	if err := json.Unmarshal(body, &req); err != nil {
+		apierror.Write(w, r, http.StatusBadRequest, apierror.CodeBadRequest, "Invalid request body")
+		return
+	}
+
+	idempotencyKey := strings.TrimSpace(r.Header.Get(idempotencyHeader))
+	bodyHash := requestHash(body)
+	by := requestActor(r.Context())
+	if len(idempotencyKey) > maxIdempotencyKey {
+		apierror.Write(w, r, http.StatusBadRequest, apierror.CodeBadRequest, fmt.Sprintf("Idempotency-Key must be at most %d characters", maxIdempotencyKey))
+		return
+	}
+	if idempotencyKey != "" {
+		key := startingKey{scope: idempotencyScope(r), key: idempotencyKey}
+		if !h.starting.acquire(key) {
+			apierror.Write(w, r, http.StatusConflict, apierror.CodeConflict, "A screening with this Idempotency-Key is being started; retry shortly")
+			return
+		}
+		defer h.starting.release(key)
+		if h.replayScreening(w, r, key, bodyHash) {
+			return
+		}
+	}
+
+	limits, err := h.screeningLimits(req)
+	if err != nil {
+		apierror.Write(w, r, http.StatusBadRequest, apierror.CodeBadRequest, err.Error())
+		return
+	}
+	source, ok := models.ParseListSource(req.ListSource)
+	if !ok {
+		apierror.Write(w, r, http.StatusBadRequest, apierror.CodeBadRequest, "listSource must be REMOTE, LOCAL or HYBRID")
+		return
+	}
+	if source != models.ListSourceHybrid && len(req.WatchlistIDs) > 0 {
+		apierror.Write(w, r, http.StatusBadRequest, apierror.CodeBadRequest, "watchlistIds are only used by HYBRID screenings")
+		return
+	}
+	if source == models.ListSourceLocal && len(req.PackIDs) > 0 {
+		apierror.Write(w, r, http.StatusBadRequest, apierror.CodeBadRequest, "Packs are only available from the PSI server")
+		return
+	}
+
+	categories := make([]string, 0, len(req.Categories))
+	for _, c := range req.Categories {
+		category, ok := models.ParseListCategory(c)
+		if !ok {
+			apierror.Write(w, r, http.StatusBadRequest, apierror.CodeBadRequest, fmt.Sprintf("Unknown list category %q", c))
+			return
+		}
+		categories = append(categories, category)
+	}
+	var packIDs []string
+	var watchlistIDs []int64
+	if source != models.ListSourceRemote {
+		// Local watchlists are selected when the screening starts
+		ids := req.SanctionListIDs
+		if source == models.ListSourceHybrid {
+			ids = req.WatchlistIDs
+		}
+		watchlistIDs, err = h.watchlistIDs(r.Context(), ids, categories)
+		if errors.Is(err, errUnknownWatchlist) {
+			apierror.Write(w, r, http.StatusNotFound, apierror.CodeListNotFound, err.Error())
+			return
+		}
+		if err != nil {
+			apierror.Write(w, r, http.StatusInternalServerError, apierror.CodeDatabaseError, "Failed to load watchlists")
+			return
+		}
+		if len(watchlistIDs) == 0 {
+			apierror.Write(w, r, http.StatusBadRequest, apierror.CodeBadRequest, "At least one watchlist is required")
+			return
+		}
+		if source == models.ListSourceLocal {
+			req.SanctionListIDs, watchlistIDs = watchlistIDs, nil
+		}
+	}
+	if source != models.ListSourceLocal {
+		if packIDs, err = h.screeningPackIDs(r.Context(), req); err != nil {
+			apierror.Write(w, r, http.StatusInternalServerError, apierror.CodeDatabaseError, "Failed to load pack subscriptions")
+			return
+		}
+	}
+	names, err := translit.Parse(h.psiConfig.Transliteration)
+	if req.Transliteration != nil {
+		names, err = translit.New(req.Transliteration)
+	}
+	if err != nil {
+		apierror.Write(w, r, http.StatusBadRequest, apierror.CodeBadRequest, err.Error())
 		return
 	}
 
 	// Generate job ID
 	jobID := fmt.Sprintf("screening_%d", time.Now().UnixNano())
 
-	// Create screening job (no user tracking)
-	job := h.jobManager.Create(jobID, req.Name, req.CustomerListID, req.SanctionListIDs, 0)
+	// Create screening job
+	job := h.jobManager.Create(jobID, req.Name, req.CustomerListID, req.SanctionListIDs, by.id)
+	job.Categories = categories
+	job.PackIDs = packIDs
+	job.Transliteration = names.Scripts
+	job.ListSource = source
+	job.WatchlistIDs = watchlistIDs
 
 	// Create screening record
 	screening := &models.Screening{
@@ -351,17 +630,26 @@ func (h *Handler) StartScreening(w http.ResponseWriter, r *http.Request) {
 		Name:            req.Name,
 		CustomerListID:  req.CustomerListID,
 		SanctionListIDs: req.SanctionListIDs,
+		ListSource:      source,
+		WatchlistIDs:    watchlistIDs,
 		Status:          "PENDING",
-		CreatedBy:       0,
+		WorkerCount:     limits.workers,
+		MemoryLimitGB:   limits.memoryGB,
+		CreatedBy:       by.id,
+		IdempotencyKey:  idempotencyKey,
+	}
+	if idempotencyKey != "" {
+		screening.IdempotencyScope = idempotencyScope(r)
+		screening.RequestHash = bodyHash
 	}
 
 	if err := h.repo.CreateScreening(r.Context(), screening); err != nil {
-		http.Error(w, fmt.Sprintf("Failed to create screening: %v", err), http.StatusInternalServerError)
+		apierror.Write(w, r, http.StatusInternalServerError, apierror.CodeDatabaseError, fmt.Sprintf("Failed to create screening: %v", err))
 		return
 	}
 
 	// Start screening in background - pass screening ID and mapping
-	go h.runScreening(job, screening.ID, req.ColumnMapping)
+	go h.runScreening(job, screening.ID, req.ColumnMapping, limits)
 
 	resp := models.StartScreeningResponse{
 		JobID: job.ID,
@@ -372,9 +660,123 @@ func (h *Handler) StartScreening(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(resp)
 }
 
+// screeningLimits are the resources one screening may use
+type screeningLimits struct {
+	workers  int
+	memoryGB float64
+}
+
+// screeningLimits resolves the requested per-job limits, defaulting to and
+// bounded by the server configuration
+func (h *Handler) screeningLimits(req models.StartScreeningRequest) (screeningLimits, error) {
+	limits := screeningLimits{workers: h.psi.GetWorkerCount(), memoryGB: h.psiConfig.MaxRAMGB}
+	if req.Workers < 0 || req.Workers > limits.workers {
+		return limits, fmt.Errorf("workers must be between 1 and %d", limits.workers)
+	}
+	if req.MaxMemoryGB < 0 || req.MaxMemoryGB > limits.memoryGB {
+		return limits, fmt.Errorf("maxMemoryGb must be between 0 and %g", limits.memoryGB)
+	}
+	if req.Workers > 0 {
+		limits.workers = req.Workers
+	}
+	if req.MaxMemoryGB > 0 {
+		limits.memoryGB = req.MaxMemoryGB
+	}
+	return limits, nil
+}
+
+// estimateSample is the number of recent screenings whose throughput
+// predicts the duration of a new one
+const estimateSample = 10
+
+// EstimateScreening predicts the memory, payload size, batch count and
+// duration of a screening without starting it
+func (h *Handler) EstimateScreening(w http.ResponseWriter, r *http.Request) {
+	var req models.EstimateScreeningRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		apierror.Write(w, r, http.StatusBadRequest, apierror.CodeBadRequest, "Invalid request body")
+		return
+	}
+	if len(req.SanctionListIDs) == 0 {
+		apierror.Write(w, r, http.StatusBadRequest, apierror.CodeBadRequest, "At least one sanction list is required")
+		return
+	}
+
+	customerLists, err := h.repo.GetCustomerLists(r.Context())
+	if err != nil {
+		apierror.Write(w, r, http.StatusInternalServerError, apierror.CodeDatabaseError, "Database error")
+		return
+	}
+	var customerList *models.CustomerList
+	for i := range customerLists {
+		if customerLists[i].ID == req.CustomerListID {
+			customerList = &customerLists[i]
+			break
+		}
+	}
+	if customerList == nil {
+		apierror.Write(w, r, http.StatusNotFound, apierror.CodeNotFound, "Customer list not found")
+		return
+	}
+
+	sanctionLists, err := h.psiClient.GetSanctionLists(r.Context())
+	if err != nil {
+		writeUpstreamError(w, r, err, "Failed to fetch sanction lists")
+		return
+	}
+	sanctionCounts := make(map[int64]int, len(sanctionLists))
+	for _, l := range sanctionLists {
+		sanctionCounts[l.ID] = l.RecordCount
+	}
+	sanctionRecords := 0
+	for _, id := range req.SanctionListIDs {
+		count, ok := sanctionCounts[id]
+		if !ok {
+			apierror.Write(w, r, http.StatusNotFound, apierror.CodeNotFound, fmt.Sprintf("Sanction list %d not found", id))
+			return
+		}
+		sanctionRecords += count
+	}
+
+	customers := customerList.RecordCount
+	batches := h.psi.EstimateBatches(sanctionRecords)
+	memoryGB := h.psi.EstimateMemory(customers, sanctionRecords) / 1024 // EstimateMemory reports MB
+	estimate := models.ScreeningEstimate{
+		CustomerRecords: customers,
+		SanctionRecords: sanctionRecords,
+		Batches:         batches,
+		MemoryGB:        memoryGB,
+		MaxMemoryGB:     h.psiConfig.MaxRAMGB,
+		FitsMemory:      memoryGB <= h.psiConfig.MaxRAMGB*0.85,
+		CiphertextBytes: h.psi.EstimateCiphertextBytes(customers, batches),
+	}
+
+	// Project the duration from the records per second of recent screenings
+	recent, err := h.repo.GetRecentScreeningMetrics(r.Context(), estimateSample)
+	if err != nil {
+		log.Printf("Warning: failed to load screening metrics for estimate: %v", err)
+	}
+	var records, totalMs int64
+	for _, m := range recent {
+		if m.TotalMs > 0 && m.RecordCount > 0 {
+			records += int64(m.RecordCount)
+			totalMs += m.TotalMs
+			estimate.ThroughputSample++
+		}
+	}
+	if records > 0 {
+		seconds := float64(customers) * float64(totalMs) / float64(records) / 1000
+		estimate.DurationSeconds = &seconds
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(estimate)
+}
+
 // runScreening executes the PSI screening process
-func (h *Handler) runScreening(job *jobs.ScreeningJob, screeningID int64, columnMapping map[string]string) {
-	ctx := context.Background()
+func (h *Handler) runScreening(job *jobs.ScreeningJob, screeningID int64, columnMapping map[string]string, limits screeningLimits) {
+	ctx := job.Context()
+	psi := h.psi.WithWorkers(limits.workers)
 
 	defer func() {
 		if r := recover(); r != nil {
@@ -385,146 +787,269 @@ func (h *Handler) runScreening(job *jobs.ScreeningJob, screeningID int64, column
 
 	log.Printf("Starting screening job %s (ID: %d)", job.ID, screeningID)
 	job.SetStatus(jobs.StatusRunning)
+	screeningStart := time.Now()
 
-	// Initialize performance monitor
-	perfMonitor := h.psi.NewPerformanceMonitor()
-	
-	// Helper function to get CPU usage (simplified)
-	getCPUUsage := func() float64 {
-		var m runtime.MemStats
-		runtime.ReadMemStats(&m)
-		// Rough CPU estimate based on GC activity and goroutines
-		return float64(runtime.NumGoroutine()) * 2.5
-	}
+	// Profiles bracket each phase when an operator armed a capture
+	capture := h.profiles.Begin(job.ID)
+	defer capture.Finish()
 
 	// Stage 1: Preparing data
+	capture.Phase(string(jobs.PhaseServerInit))
 	job.AddProgress(jobs.PhaseServerInit, 10, "Loading customer and sanction data", nil)
 	time.Sleep(500 * time.Millisecond)
 
-	// Determine enabled columns from mapping
-	var enabledColumns []string
-	if columnMapping != nil {
-		// We use a fixed order for consistency: name, dob, country, program
-		// Check which ones are mapped
-		if _, ok := columnMapping["name"]; ok && columnMapping["name"] != "" {
-			enabledColumns = append(enabledColumns, "name")
-		}
-		if _, ok := columnMapping["dob"]; ok && columnMapping["dob"] != "" {
-			enabledColumns = append(enabledColumns, "dob")
-		}
-		if _, ok := columnMapping["country"]; ok && columnMapping["country"] != "" {
-			enabledColumns = append(enabledColumns, "country")
-		}
-	}
-	// If empty, default to standard set
-	if len(enabledColumns) == 0 {
-		enabledColumns = []string{"name", "dob", "country"}
-	}
+	enabledColumns := screeningColumns(columnMapping)
+
+	// The scripts were validated when the screening was started
+	names, _ := translit.New(job.Transliteration)
 
 	// Load data from CSV directly
-	customerRecords, customerData, err := h.loadCustomerDataFromCSV(job.CustomerListID, columnMapping, enabledColumns)
+	customerRecords, customerData, err := h.loadCustomerDataFromCSV(ctx, job.CustomerListID, columnMapping, enabledColumns, names)
 	if err != nil {
 		job.SetError(err)
 		job.SetStatus(jobs.StatusFailed)
 		return
 	}
+	// Document numbers are screened in the same set, as their own channel
+	customers := len(customerData)
+	customerRecords, customerData = addDocumentInputs(customerRecords, customerData)
+
+	if job.ListSource != models.ListSourceRemote {
+		l := localScreening{
+			screeningID:    screeningID,
+			watchlistIDs:   job.SanctionListIDs,
+			customers:      customerRecords,
+			serialized:     customerData,
+			columnMapping:  columnMapping,
+			enabledColumns: enabledColumns,
+			names:          names,
+			memoryGB:       limits.memoryGB,
+			start:          screeningStart,
+		}
+		if job.ListSource == models.ListSourceHybrid {
+			l.watchlistIDs = job.WatchlistIDs
+			h.screenHybrid(ctx, job, psi, capture, l)
+			return
+		}
+		h.screenLocally(ctx, job, psi, capture, l)
+		return
+	}
 
 	// In distributed mode, we don't have sanction data locally
-	job.SetCounts(len(customerData), 0)
-	job.AddProgress(jobs.PhaseServerInit, 20, fmt.Sprintf("Loaded %d customers", len(customerData)), nil)
+	job.SetCounts(customers, 0)
+	job.SetWorkerInfo(psi.GetWorkerCount(), psi.EstimateMemory(len(customerData), 0), limits.memoryGB)
+	if err := psi.ValidateMemoryRequirement(len(customerData), 0, limits.memoryGB); err != nil {
+		job.SetError(fmt.Errorf("screening exceeds its memory limit: %w", err))
+		job.SetStatus(jobs.StatusFailed)
+		return
+	}
+	job.AddProgress(jobs.PhaseServerInit, 20, fmt.Sprintf("Loaded %d customers", customers), nil)
 
 	// Log first few entries for debugging
 	if len(customerData) > 0 {
 		log.Printf("Sample customer data (first 3): %v", customerData[:min(3, len(customerData))])
-		custHashes := psiadapter.HashDataPoints(customerData)
-		log.Printf("Sample customer hashes: %v", custHashes[:min(3, len(custHashes))])
 	}
 
-	// Stage 2: Initializing session with remote server
-	job.AddProgress(jobs.PhaseServerInit, 10, "Connecting to Sanctions Authority...", nil)
-	time.Sleep(500 * time.Millisecond)
+	// Progress is kept until the intersection succeeds, for a retry
+	checkpoint := h.retryCheckpoint(job, columnMapping)
+	session, matches, err := h.intersectRemote(ctx, job, psi, capture, &screeningSide{job: job}, checkpoint, customerData, enabledColumns, names)
+	keepSession := false
+	defer func() {
+		if session == nil || keepSession {
+			return
+		}
+		// The job's context is done if it was cancelled
+		if err := session.Close(context.Background()); err != nil {
+			log.Printf("Warning: failed to close session %s: %v", session.ID, err)
+		}
+	}()
+	if err != nil {
+		if ctx.Err() != nil {
+			// A cancelled screening can't be retried
+			checkpoint.remove()
+		}
+		job.SetError(err)
+		job.SetStatus(jobs.StatusFailed)
+		return
+	}
+	checkpoint.remove()
 
-	// Convert list IDs to strings
-	sanctionListIDs := make([]string, len(job.SanctionListIDs))
-	for i, id := range job.SanctionListIDs {
-		sanctionListIDs[i] = fmt.Sprintf("%d", id)
+	// Stage 5: Storing results
+	capture.Phase(string(jobs.PhasePersist))
+	job.AddProgress(jobs.PhasePersist, 90, "Saving results to database", nil)
+
+	// Persist the match hashes first, so resolution can be re-run if it fails
+	matched := &matchSet{
+		jobID:          job.ID,
+		screeningID:    screeningID,
+		customerListID: job.CustomerListID,
+		sessionID:      session.ID,
+		hashes:         matches,
+		customers:      customerRecords,
+		serialized:     customerData,
+		columnMapping:  columnMapping,
+		enabledColumns: enabledColumns,
+		names:          names,
+		scheme:         session.Hash,
+	}
+	if err := h.saveMatchSet(ctx, matched); err != nil {
+		log.Printf("Warning: failed to save match hashes of job %s: %v", job.ID, err)
 	}
 
-	// Call Server to init session
-	sessionID, serializedParams, err := h.psiClient.InitSession(ctx, sanctionListIDs, enabledColumns)
+	// Fetch matched sanctions from SERVER (distributed mode)
+	resolveStart := time.Now()
+	sanctionRecords, err := session.Resolve(ctx, matches)
 	if err != nil {
-		job.SetError(fmt.Errorf("failed to init session with server: %w", err))
+		log.Printf("Failed to resolve sanctions from server: %v", err)
+		// Leave the session open for POST /screenings/{jobId}/re-resolve
+		keepSession = true
+		job.SetError(fmt.Errorf("failed to resolve sanctions: %w", err))
 		job.SetStatus(jobs.StatusFailed)
 		return
 	}
+	job.RecordPhaseDuration("resolve", time.Since(resolveStart))
+	persistStart := time.Now()
 
-	job.AddProgress(jobs.PhaseServerInit, 40, "Received public parameters from server", nil)
-
-	// Deserialize params
-	pp, msg, le, err := h.psi.DeserializeParams(serializedParams)
+	resultIDs, collisions, err := h.persistMatches(ctx, matched, sanctionRecords)
 	if err != nil {
-		job.SetError(fmt.Errorf("failed to deserialize params: %w", err))
+		log.Printf("Failed to save screening results: %v", err)
+		// Nothing was saved; the matches can still be re-resolved
+		keepSession = true
+		job.SetError(fmt.Errorf("failed to save results: %w", err))
 		job.SetStatus(jobs.StatusFailed)
 		return
 	}
+	job.RecordPhaseDuration("persist", time.Since(persistStart))
+	h.completeScreening(ctx, job, screeningID, resultIDs, customers, collisions, screeningStart, jobs.StatusCompleted)
+}
 
-	// Construct a temporary ServerContext for encryption (we only need PP, Msg, LE)
-	serverCtx := &psiadapter.ServerContext{
-		PP:  pp,
-		Msg: msg,
-		LE:  le,
-	}
+// intersectRemote runs the PSI protocol with the server: it opens a session,
+// encrypts the customers under the params of each of the server's batches
+// and intersects them there. It returns the session, which the caller
+// resolves the matches in and closes, once one was opened, even with an
+// error. With a checkpoint, the
+// matches of each batch are recorded as it is intersected, and those a
+// failed run recorded under the same params are reused.
+func (h *Handler) intersectRemote(ctx context.Context, job *jobs.ScreeningJob, psi *psiadapter.Adapter, capture *profiling.Capture, side *screeningSide, checkpoint *retryCheckpoint, customerData []string, enabledColumns []string, names translit.Profile) (*protocol.Session, []uint64, error) {
+	// Instrumentation of the run, in the schema of the analytics report
+	rec := metrics.NewRecorder()
+	defer func() { job.SetMetrics(rec.Report()) }()
 
-	// Stage 3: Encrypting client data
-	job.AddProgress(jobs.PhaseClientEncrypt, 30, "Generating client keys and encrypting dataset...", nil)
-	time.Sleep(800 * time.Millisecond)
+	// Stage 2: Initializing session with remote server
+	side.progress(jobs.PhaseServerInit, 10, "Connecting to Sanctions Authority...", nil)
+	time.Sleep(500 * time.Millisecond)
+
+	// Convert list IDs to strings
+	sanctionListIDs := make([]string, len(job.SanctionListIDs))
+	for i, id := range job.SanctionListIDs {
+		sanctionListIDs[i] = fmt.Sprintf("%d", id)
+	}
 
-	ciphertexts, err := h.psi.EncryptClient(ctx, customerData, serverCtx)
+	// Call Server to init session
+	session, err := protocol.Open(ctx, h.sessions, protocol.InitSessionRequest{
+		SanctionListIDs: sanctionListIDs,
+		EnabledColumns:  enabledColumns,
+		Categories:      job.Categories,
+		PackIDs:         job.PackIDs,
+		Transliteration: names,
+		Documents:       hasDocumentInputs(customerData),
+	})
 	if err != nil {
-		job.SetError(fmt.Errorf("failed to encrypt client data: %w", err))
-		job.SetStatus(jobs.StatusFailed)
-		return
+		return nil, nil, fmt.Errorf("failed to init session with server: %w", err)
 	}
+	paramSets, scheme := session.Params, session.Hash
 
-	// Get performance metrics after encryption
-	metrics := perfMonitor.GetMetrics()
-	memStats := perfMonitor.GetMemoryUsage()
-	
-	throughput := float64(0)
-	memory := float64(0)
-	cpu := getCPUUsage()
-	
-	if thr, ok := metrics["throughput_ops_per_sec"].(float64); ok {
-		throughput = thr
+	log.Printf("Session %s hashes set elements with %s", session.ID, scheme)
+	defer session.Heartbeat(ctx)()
+	side.progress(jobs.PhaseServerInit, 40, "Received public parameters from server", nil)
+
+	// Deserialize params. Batched servers send one set per batch, each with
+	// its own tree root, so the dataset is encrypted once per batch.
+	encryptCtxs := make([]*psiadapter.ServerContext, len(paramSets))
+	for i, params := range paramSets {
+		pp, msg, le, err := psi.DeserializeParams(params)
+		if err != nil {
+			return session, nil, fmt.Errorf("failed to deserialize params for batch %d: %w", i, err)
+		}
+		// Construct a temporary ServerContext for encryption (we only need PP, Msg, LE)
+		encryptCtxs[i] = &psiadapter.ServerContext{
+			PP:   pp,
+			Msg:  msg,
+			LE:   le,
+			Hash: scheme,
+		}
 	}
-	if mem, ok := memStats["alloc_mb"].(float64); ok {
-		memory = mem
+	if len(encryptCtxs) > 1 {
+		log.Printf("Server uses %d batches; encrypting customer data once per batch", len(encryptCtxs))
 	}
 
-	job.AddProgress(jobs.PhaseClientEncrypt, 60, fmt.Sprintf("Encrypted %d records", len(ciphertexts)), map[string]string{
-		"encrypted_records": fmt.Sprintf("%d", len(ciphertexts)),
-		"throughput":        fmt.Sprintf("%.2f", throughput),
-		"memory":            fmt.Sprintf("%.2f", memory),
-		"cpu":               fmt.Sprintf("%.1f", cpu),
-	})
+	// A retry picks up the batches its failed run intersected, as long as
+	// they are encrypted from the same data under the same params
+	totalRecords := len(customerData) * len(encryptCtxs)
+	keys := make([]string, len(paramSets))
+	for i, params := range paramSets {
+		keys[i] = ctcache.Key(params, scheme, customerData)
+	}
+	checkpoint.resume(keys)
+	stopEncryption := rec.Phase("encryption")
+	ciphertextSets, err := h.encryptBatches(ctx, job, psi, capture, side, checkpoint, encryptCtxs, keys, customerData)
+	stopEncryption()
+	if err != nil {
+		return session, nil, err
+	}
+	rec.AddOperations(totalRecords)
+	if err := checkpoint.save(keys); err != nil {
+		log.Printf("Warning: failed to record the ciphertexts of job %s; it can't be retried: %v", job.ID, err)
+	}
+
+	side.progress(jobs.PhaseClientEncrypt, 60, fmt.Sprintf("Encrypted %d records", totalRecords), progressMetrics(rec, map[string]string{
+		"encrypted_records": fmt.Sprintf("%d", totalRecords),
+	}))
 
 	// Stage 4: Computing intersection (Remote)
-	job.AddProgress(jobs.PhaseIntersection, 70, "Sending encrypted data to server for intersection...", nil)
+	capture.Phase(string(jobs.PhaseIntersection))
+	side.progress(jobs.PhaseIntersection, 70, "Sending encrypted data to server for intersection...", nil)
 	time.Sleep(1 * time.Second)
 
 	// Log number of ciphertexts
-	log.Printf("Number of ciphertexts: %d", len(ciphertexts))
+	log.Printf("Number of ciphertexts: %d across %d batch(es)", totalRecords, len(ciphertextSets))
 
 	// Run intersection in background with heartbeat
 	type intersectResult struct {
-		matches []uint64
-		err     error
+		matches    []uint64
+		serverTime time.Duration
+		err        error
 	}
 	resultChan := make(chan intersectResult, 1)
 
+	intersectStart := time.Now()
 	go func() {
-		matches, err := h.psiClient.Intersect(ctx, sessionID, ciphertexts)
-		resultChan <- intersectResult{matches: matches, err: err}
+		// Each batch is intersected with the ciphertexts encrypted under
+		// its own params; the union of batch matches is the result. Batches
+		// a failed run intersected are not sent again.
+		var res intersectResult
+		seen := make(map[uint64]bool)
+		for b, ciphertexts := range ciphertextSets {
+			matches, ok := checkpoint.batchMatches(b)
+			if !ok {
+				var serverTime time.Duration
+				var err error
+				matches, serverTime, err = session.Intersect(ctx, b, ciphertexts)
+				if err != nil {
+					res.err = fmt.Errorf("batch %d intersection failed: %w", b, err)
+					break
+				}
+				res.serverTime += serverTime
+				checkpoint.recordBatch(b, matches)
+			}
+			for _, m := range matches {
+				if !seen[m] {
+					seen[m] = true
+					res.matches = append(res.matches, m)
+				}
+			}
+		}
+		resultChan <- res
 	}()
 
 	// Wait for result with heartbeat
@@ -538,33 +1063,21 @@ Loop:
 		select {
 		case res := <-resultChan:
 			if res.err != nil {
-				job.SetError(res.err)
-				job.SetStatus(jobs.StatusFailed)
-				return
+				return session, nil, res.err
 			}
 			matches = res.matches
+			// Round trip minus server compute time is attributed to the network
+			side.duration("intersection", res.serverTime)
+			side.duration("network", time.Since(intersectStart)-res.serverTime)
+			rec.RecordPhase("intersection", res.serverTime)
+			rec.RecordPhase("network", time.Since(intersectStart)-res.serverTime)
 			break Loop
 		case <-ticker.C:
+			// Intersection is overrunning its estimate; keep the ETA ahead of now
+			job.DelayEstimatedCompletion(time.Now().Add(10 * time.Second))
+
 			// Send heartbeat with updated metrics
-			metrics := perfMonitor.GetMetrics()
-			memStats := perfMonitor.GetMemoryUsage()
-			
-			throughput := float64(0)
-			memory := float64(0)
-			cpu := getCPUUsage()
-			
-			if thr, ok := metrics["throughput_ops_per_sec"].(float64); ok {
-				throughput = thr
-			}
-			if mem, ok := memStats["alloc_mb"].(float64); ok {
-				memory = mem
-			}
-			
-			job.AddProgress(jobs.PhaseIntersection, 75, "Intersecting... (this may take a few minutes)", map[string]string{
-				"throughput": fmt.Sprintf("%.2f", throughput),
-				"memory":     fmt.Sprintf("%.2f", memory),
-				"cpu":        fmt.Sprintf("%.1f", cpu),
-			})
+			side.progress(jobs.PhaseIntersection, 75, "Intersecting... (this may take a few minutes)", progressMetrics(rec, nil))
 		}
 	}
 
@@ -577,122 +1090,162 @@ Loop:
 		log.Printf("PSI returned 0 matches. This could be correct, or due to data mismatch.")
 	}
 	
-	// Get final performance metrics
-	finalMetrics := perfMonitor.GetMetrics()
-	finalMemStats := perfMonitor.GetMemoryUsage()
-	
-	finalThroughput := float64(0)
-	finalMemory := float64(0)
-	finalCPU := getCPUUsage()
-	
-	if thr, ok := finalMetrics["throughput_ops_per_sec"].(float64); ok {
-		finalThroughput = thr
-	}
-	if mem, ok := finalMemStats["alloc_mb"].(float64); ok {
-		finalMemory = mem
-	}
-	
-	job.AddProgress(jobs.PhaseIntersection, 85, fmt.Sprintf("Found %d potential matches", len(matches)), map[string]string{
+	side.progress(jobs.PhaseIntersection, 85, fmt.Sprintf("Found %d potential matches", len(matches)), progressMetrics(rec, map[string]string{
 		"potential_matches": fmt.Sprintf("%d", len(matches)),
-		"throughput":        fmt.Sprintf("%.2f", finalThroughput),
-		"memory":            fmt.Sprintf("%.2f", finalMemory),
-		"cpu":               fmt.Sprintf("%.1f", finalCPU),
-	})
+	}))
 
-	// Stage 5: Storing results
-	job.AddProgress(jobs.PhasePersist, 90, "Saving results to database", nil)
+	return session, matches, nil
+}
 
-	// Resolve matches using in-memory maps
-	var resultIDs []int64
-	
-	// Create a map of hash -> customer record
-	customerMap := make(map[int64]*models.Customer)
-	for i, hash := range psiadapter.HashDataPoints(customerData) {
-		customerMap[int64(hash)] = customerRecords[i]
+// progressMetrics adds the throughput (records/s), memory (MB) and CPU (%)
+// recorded so far to the metrics of a progress update
+func progressMetrics(rec *metrics.Recorder, extra map[string]string) map[string]string {
+	report := rec.Report()
+	m := map[string]string{
+		"throughput": fmt.Sprintf("%.2f", report.Throughput),
+		"memory":     fmt.Sprintf("%.2f", report.Resources.AllocMB),
+		"cpu":        fmt.Sprintf("%.1f", report.Resources.CPUPercent),
 	}
-
-	log.Printf("PSI returned %d match hashes", len(matches))
-	log.Printf("Customer map has %d entries", len(customerMap))
-
-	// Fetch matched sanctions from SERVER (distributed mode)
-	sanctionRecords, err := h.psiClient.ResolveSanctions(ctx, sessionID, matches)
-	if err != nil {
-		log.Printf("Failed to resolve sanctions from server: %v", err)
-		job.SetError(fmt.Errorf("failed to resolve sanctions: %w", err))
-		job.SetStatus(jobs.StatusFailed)
-		return
+	for k, v := range extra {
+		m[k] = v
 	}
+	return m
+}
 
-	// Create sanction hash map
-	sanctionMap := make(map[int64]*models.Sanction)
-	for i := range sanctionRecords {
-		sanctionMap[sanctionRecords[i].Hash] = sanctionRecords[i]
-	}
-	log.Printf("Resolved %d sanctions from server", len(sanctionMap))
+// encryptBatches encrypts the customers under the params of each batch,
+// reporting progress and the time remaining as it goes. Batches found in the
+// ciphertext cache under their key are not encrypted again, and batches the
+// checkpoint has matches for are not encrypted at all.
+func (h *Handler) encryptBatches(ctx context.Context, job *jobs.ScreeningJob, psi *psiadapter.Adapter, capture *profiling.Capture, side *screeningSide, checkpoint *retryCheckpoint, encryptCtxs []*psiadapter.ServerContext, keys []string, customerData []string) ([][]psiadapter.ClientCiphertext, error) {
+	// Stage 3: Encrypting client data
+	capture.Phase(string(jobs.PhaseClientEncrypt))
+	side.progress(jobs.PhaseClientEncrypt, 30, "Generating client keys and encrypting dataset...", nil)
+	time.Sleep(800 * time.Millisecond)
 
-	for _, matchHash := range matches {
-		customer, cOk := customerMap[int64(matchHash)]
-		sanction, sOk := sanctionMap[int64(matchHash)]
-		
-		log.Printf("Processing match hash %d: customer found=%v, sanction found=%v", matchHash, cOk, sOk)
-		
-		if cOk && sOk {
-			log.Printf("Match found: Customer=%s (%s, %s) <-> Sanction=%s (%s, %s, %s)", 
-				customer.Name, customer.DOB, customer.Country,
-				sanction.Name, sanction.DOB, sanction.Country, sanction.Program)
-			
-			// Ensure customer is in database (for client-side CSVs, they aren't inserted initially)
-			if customer.ID == 0 {
-				customer.Hash = int64(matchHash) // Ensure hash is set
-				if err := h.repo.CreateCustomer(ctx, customer); err != nil {
-					log.Printf("Warning: Failed to save customer to local DB: %v", err)
-					// We can't save the result without a customer ID
-					continue
-				}
-				log.Printf("Inserted matched customer %s with ID %d", customer.Name, customer.ID)
-			}
+	// Per-record intersection cost (server + network) of the last screening,
+	// used to project the time remaining after encryption
+	var intersectPerRecord time.Duration
+	if last, err := h.repo.GetLatestScreeningMetrics(ctx); err == nil && last != nil && last.RecordCount > 0 {
+		intersectPerRecord = time.Duration(last.IntersectionMs+last.NetworkMs) * time.Millisecond / time.Duration(last.RecordCount)
+	}
 
-			// Save sanction to database temporarily for result linking
-			if err := h.repo.CreateSanction(ctx, sanction); err != nil {
-				log.Printf("Warning: Failed to save sanction to local DB: %v", err)
-				// Continue anyway - we just won't have a local copy
+	ciphertextSets := make([][]psiadapter.ClientCiphertext, len(encryptCtxs))
+	var pending []int
+	for b, key := range keys {
+		if _, ok := checkpoint.batchMatches(b); ok {
+			continue
+		}
+		if ciphertexts, ok := h.ciphertexts.Get(key); ok {
+			ciphertextSets[b] = ciphertexts
+			continue
+		}
+		pending = append(pending, b)
+	}
+	if reused := len(keys) - len(pending); reused > 0 {
+		log.Printf("Reusing the ciphertexts of %d of %d batch(es)", reused, len(keys))
+		side.progress(jobs.PhaseClientEncrypt, 30, fmt.Sprintf("Reusing encrypted records of %d of %d batch(es)", reused, len(keys)), nil)
+	}
+	if len(pending) == 0 {
+		return ciphertextSets, nil
+	}
+
+	encryptStart := time.Now()
+	encryptRate := jobs.NewThroughput()
+	chunkSize := max(1, len(customerData)/encryptChunks)
+	totalRecords := len(customerData) * len(pending)
+	for i, b := range pending {
+		serverCtx := encryptCtxs[b]
+		ciphertexts := make([]psiadapter.ClientCiphertext, 0, len(customerData))
+		for start := 0; start < len(customerData); start += chunkSize {
+			end := min(start+chunkSize, len(customerData))
+			chunk, err := psi.EncryptClient(ctx, customerData[start:end], serverCtx)
+			if err != nil {
+				return nil, fmt.Errorf("failed to encrypt client data: %w", err)
 			}
-			
-			result := &models.ScreeningResult{
-				ScreeningID: screeningID,
-				CustomerID:  customer.ID,
-				SanctionID:  sanction.ID,
-				MatchScore:  1.0,
-				Status:      "PENDING",
+			ciphertexts = append(ciphertexts, chunk...)
+			encryptRate.Add(end - start)
+
+			done := i*len(customerData) + end
+			intersectEstimate := intersectPerRecord * time.Duration(totalRecords)
+			if intersectEstimate == 0 {
+				// No history yet: assume intersection costs about as much as encryption
+				intersectEstimate = time.Since(encryptStart) + encryptRate.Remaining(totalRecords-done)
 			}
-			
-			if err := h.repo.CreateScreeningResult(ctx, result); err != nil {
-				log.Printf("Failed to save result: %v", err)
-			} else {
-				resultIDs = append(resultIDs, result.ID)
-				log.Printf("Successfully saved screening result ID %d", result.ID)
+			job.SetEstimatedCompletion(time.Now().Add(encryptRate.Remaining(totalRecords-done) + intersectEstimate))
+			message := fmt.Sprintf("Encrypted %d/%d records", end, len(customerData))
+			if len(encryptCtxs) > 1 {
+				message += fmt.Sprintf(" (batch %d/%d)", b+1, len(encryptCtxs))
 			}
-		} else {
-			log.Printf("Warning: Match hash %d found but missing customer=%v or sanction=%v", matchHash, !cOk, !sOk)
+			side.progress(jobs.PhaseClientEncrypt, 30+30*done/totalRecords, message, map[string]string{
+				"records_per_sec": fmt.Sprintf("%.2f", encryptRate.PerSecond()),
+			})
+		}
+		ciphertextSets[b] = ciphertexts
+		if err := h.ciphertexts.Put(keys[b], ciphertexts); err != nil {
+			log.Printf("Warning: failed to cache the ciphertexts of batch %d: %v", b, err)
 		}
 	}
+	side.duration("encryption", time.Since(encryptStart))
+	return ciphertextSets, nil
+}
 
-	log.Printf("Total matches saved: %d", len(resultIDs))
+// completeScreening records the results and metrics of a screening whose
+// results were saved, and marks it with status, COMPLETED or PARTIAL
+func (h *Handler) completeScreening(ctx context.Context, job *jobs.ScreeningJob, screeningID int64, resultIDs []int64, recordCount, collisions int, screeningStart time.Time, status jobs.Status) {
 	job.SetResults(resultIDs, len(resultIDs))
+	job.RecordPhaseDuration("total", time.Since(screeningStart))
+	h.saveScreeningMetrics(ctx, job, screeningID, recordCount, collisions)
 
-	// Update screening status
-	h.repo.UpdateScreeningStatus(ctx, job.ID, "COMPLETED", len(resultIDs))
-
-	job.AddProgress(jobs.PhaseComplete, 100, fmt.Sprintf("Screening complete with %d matches", len(resultIDs)), map[string]string{
+	message := fmt.Sprintf("Screening complete with %d matches", len(resultIDs))
+	if status == jobs.StatusPartial {
+		message = fmt.Sprintf("Screening partially complete with %d matches: %s", len(resultIDs), job.GetSnapshot().Error)
+	}
+	job.AddProgress(jobs.PhaseComplete, 100, message, map[string]string{
 		"final_matches": fmt.Sprintf("%d", len(resultIDs)),
 	})
-	job.SetStatus(jobs.StatusCompleted)
+	job.SetStatus(status)
+}
+
+// screeningColumns returns the columns individuals are hashed with: those
+// of name, dob and country that are mapped, or all three if none is
+func screeningColumns(columnMapping map[string]string) []string {
+	var enabledColumns []string
+	// We use a fixed order for consistency: name, dob, country
+	for _, col := range []string{"name", "dob", "country"} {
+		if columnMapping[col] != "" {
+			enabledColumns = append(enabledColumns, col)
+		}
+	}
+	// If empty, default to standard set
+	if len(enabledColumns) == 0 {
+		enabledColumns = []string{"name", "dob", "country"}
+	}
+	return append(enabledColumns, attributeColumns(columnMapping)...)
+}
+
+// saveScreeningMetrics persists the job's measured phase durations
+func (h *Handler) saveScreeningMetrics(ctx context.Context, job *jobs.ScreeningJob, screeningID int64, recordCount, collisions int) {
+	durations := job.GetSnapshot().PhaseDurations
+	metrics := &models.ScreeningMetrics{
+		ScreeningID:    screeningID,
+		EncryptionMs:   durations["encryption"],
+		NetworkMs:      durations["network"],
+		IntersectionMs: durations["intersection"],
+		ResolveMs:      durations["resolve"],
+		PersistMs:      durations["persist"],
+		TotalMs:        durations["total"],
+		RecordCount:    recordCount,
+		HashCollisions: collisions,
+	}
+	if err := h.repo.CreateScreeningMetrics(ctx, metrics); err != nil {
+		log.Printf("Warning: failed to save screening metrics for job %s: %v", job.ID, err)
+	}
 }
 
 // Helper functions to load data from CSV
-func (h *Handler) loadCustomerDataFromCSV(listID int64, mapping map[string]string, enabledColumns []string) ([]*models.Customer, []string, error) {
+func (h *Handler) loadCustomerDataFromCSV(ctx context.Context, listID int64, mapping map[string]string, enabledColumns []string, names translit.Profile) ([]*models.Customer, []string, error) {
 	// Get list metadata to find file path
-	lists, err := h.repo.GetCustomerLists(context.Background())
+	lists, err := h.repo.GetCustomerLists(ctx)
 	if err != nil {
 		return nil, nil, err
 	}
@@ -710,13 +1263,27 @@ func (h *Handler) loadCustomerDataFromCSV(listID int64, mapping map[string]strin
 		return nil, nil, fmt.Errorf("no file path found for customer list ID %d", listID)
 	}
 
-	file, err := os.Open(filePath)
+	file, err := h.files.OpenFile(filePath)
 	if err != nil {
 		return nil, nil, err
 	}
 	defer file.Close()
 
-	reader := csv.NewReader(file)
+	// Dates of birth are read in the order the whole column follows
+	prefer, _ := psiadapter.ParseDateOrder(h.psiConfig.DateOrder)
+	// Hashed attributes are normalized as the lists' schemas type them
+	types := h.attributeTypes(ctx, enabledColumns)
+	return ReadCustomerCSV(file, listID, mapping, enabledColumns, names, prefer, types)
+}
+
+// ReadCustomerCSV reads a customer list and serializes each customer as the
+// PSI set element it is hashed as, under the column mapping, the hashed
+// columns, the name profile, the date order of the list's dates of birth and
+// the type hints of its custom fields. It returns the customers that could
+// be serialized alongside their serialization. The offline CLI reads lists
+// with it, so its customers hash as a screening's do.
+func ReadCustomerCSV(r io.Reader, listID int64, mapping map[string]string, enabledColumns []string, names translit.Profile, prefer psiadapter.DateOrder, types map[string]string) ([]*models.Customer, []string, error) {
+	reader := csv.NewReader(r)
 	headers, err := reader.Read()
 	if err != nil {
 		return nil, nil, err
@@ -728,6 +1295,17 @@ func (h *Handler) loadCustomerDataFromCSV(listID int64, mapping map[string]strin
 		headerMap[strings.ToLower(strings.TrimSpace(h))] = i
 	}
 	
+	// Mapping keys may be prefixed with an entity type (e.g.
+	// "organization.name") to read that type's rows from other columns
+	getTypedValue := func(record []string, entityType, colName string) string {
+		if mappedCol, ok := mapping[entityType+"."+colName]; ok && entityType != "" {
+			if idx, ok := headerMap[strings.ToLower(strings.TrimSpace(mappedCol))]; ok && idx < len(record) {
+				return record[idx]
+			}
+		}
+		return ""
+	}
+
 	getValue := func(record []string, colName string) string {
 		// Use mapping if provided
 		if mapping != nil {
@@ -750,11 +1328,22 @@ func (h *Handler) loadCustomerDataFromCSV(listID int64, mapping map[string]strin
 		if colName == "name" {
 			if idx, ok := headerMap["full_name"]; ok && idx < len(record) { return record[idx] }
 		}
+		if colName == "entity_type" {
+			if idx, ok := headerMap["type"]; ok && idx < len(record) { return record[idx] }
+		}
+		if colName == "registration" {
+			if idx, ok := headerMap["registration_number"]; ok && idx < len(record) { return record[idx] }
+		}
+		if colName == "imo_number" {
+			if idx, ok := headerMap["imo"]; ok && idx < len(record) { return record[idx] }
+		}
 		return ""
 	}
 
-	var records []*models.Customer
+	var customers, records []*models.Customer
 	var strings []string
+	skipped := 0
+	attributes := customerAttributeReader(headers, mapping)
 
 	for {
 		record, err := reader.Read()
@@ -765,38 +1354,68 @@ func (h *Handler) loadCustomerDataFromCSV(listID int64, mapping map[string]strin
 			continue
 		}
 
+		entityType, ok := models.ParseEntityType(getValue(record, "entity_type"))
+		if !ok {
+			skipped++
+			continue
+		}
+		value := func(colName string) string {
+			if v := getTypedValue(record, entityType, colName); v != "" {
+				return v
+			}
+			return getValue(record, colName)
+		}
+
 		customer := &models.Customer{
-			ExternalID: getValue(record, "id"),
-			Name:       getValue(record, "name"),
-			DOB:        getValue(record, "dob"),
-			Country:    getValue(record, "country"),
-			ListID:     listID,
+			ExternalID:   value("id"),
+			Name:         value("name"),
+			DOB:          value("dob"),
+			Country:      value("country"),
+			ListID:       listID,
+			EntityType:   entityType,
+			Registration: value("registration"),
+			IMONumber:    value("imo_number"),
+			Attributes:   attributes(record),
 		}
 		
 		if customer.Name == "" && len(record) >= 2 {
 			customer.Name = record[1]
 		}
+		customers = append(customers, customer)
+	}
 
-		records = append(records, customer)
-		
-		// Use dynamic serialization
-		vals := map[string]string{
-			"name":    customer.Name,
-			"dob":     customer.DOB,
-			"country": customer.Country,
+	psiadapter.NormalizeCustomerDOBs(customers, prefer)
+
+	for _, customer := range customers {
+		// Individuals use the mapped columns; other entity types use their
+		// serialization profile, matching how the server hashes sanctions.
+		// Only the hashed name is romanized; the stored record keeps it as is.
+		values := customer.HashValues()
+		values["name"] = names.Apply(values["name"])
+		for column, fieldType := range types {
+			values[column] = psiadapter.NormalizeField(fieldType, values[column], prefer)
 		}
-		strings = append(strings, psiadapter.SerializeDynamic(vals, enabledColumns))
+		serialized := psiadapter.SerializeEntity(customer.EntityType, values, enabledColumns)
+		if serialized == "" {
+			skipped++
+			continue
+		}
+		records = append(records, customer)
+		strings = append(strings, serialized)
+	}
+	if skipped > 0 {
+		log.Printf("Warning: skipped %d customers with an unknown entity type or missing identifier", skipped)
 	}
 
 	return records, strings, nil
 }
 
-func (h *Handler) loadSanctionDataFromCSV(listIDs []int64) ([]*models.Sanction, []string, error) {
+func (h *Handler) loadSanctionDataFromCSV(ctx context.Context, listIDs []int64) ([]*models.Sanction, []string, error) {
 	var allRecords []*models.Sanction
 	var allStrings []string
 
 	// Get all lists to find paths
-	lists, err := h.repo.GetSanctionLists(context.Background())
+	lists, err := h.repo.GetSanctionLists(ctx)
 	if err != nil {
 		return nil, nil, err
 	}
@@ -813,7 +1432,7 @@ func (h *Handler) loadSanctionDataFromCSV(listIDs []int64) ([]*models.Sanction,
 			continue
 		}
 
-		file, err := os.Open(filePath)
+		file, err := h.files.OpenFile(filePath)
 		if err != nil {
 			// Skip missing files or handle error
 			log.Printf("Warning: could not open sanction file %s: %v", filePath, err)
@@ -874,33 +1493,109 @@ func (h *Handler) loadSanctionDataFromCSV(listIDs []int64) ([]*models.Sanction,
 func (h *Handler) ScreeningStatus(w http.ResponseWriter, r *http.Request) {
 	jobID := chi.URLParam(r, "jobId")
 	if jobID == "" {
-		http.Error(w, "Missing jobId parameter", http.StatusBadRequest)
+		apierror.Write(w, r, http.StatusBadRequest, apierror.CodeBadRequest, "Missing jobId parameter")
+		return
+	}
+
+	job := h.jobManager.Get(jobID)
+	if job == nil {
+		// Finished jobs are evicted from memory; fall back to the stored record
+		screening, err := h.repo.GetScreeningByJobID(r.Context(), jobID)
+		if err != nil {
+			log.Printf("Error fetching screening %s: %v", jobID, err)
+			apierror.Write(w, r, http.StatusInternalServerError, apierror.CodeDatabaseError, "Failed to fetch screening")
+			return
+		}
+		if screening == nil {
+			apierror.Write(w, r, http.StatusNotFound, apierror.CodeJobNotFound, "Job not found")
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(screening)
 		return
 	}
 
+	snapshot := job.GetSnapshot()
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(&snapshot)
+}
+
+// CancelScreening cancels a pending or running screening. It stops at its
+// next check, between chunks of records or batches.
+func (h *Handler) CancelScreening(w http.ResponseWriter, r *http.Request) {
+	jobID := chi.URLParam(r, "jobId")
 	job := h.jobManager.Get(jobID)
 	if job == nil {
-		http.Error(w, "Job not found", http.StatusNotFound)
+		apierror.Write(w, r, http.StatusNotFound, apierror.CodeJobNotFound, "Job not found or already finished")
 		return
 	}
+	if status := job.GetSnapshot().Status; status != jobs.StatusPending && status != jobs.StatusRunning {
+		apierror.Write(w, r, http.StatusConflict, apierror.CodeConflict, fmt.Sprintf("Job is %s", status))
+		return
+	}
+	job.Cancel()
+	log.Printf("Cancelled screening job %s", jobID)
 
 	snapshot := job.GetSnapshot()
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(&snapshot)
 }
 
+// ListScreenings returns paginated screening history from the database.
+// The optional status parameter takes a comma-separated list of statuses.
+func (h *Handler) ListScreenings(w http.ResponseWriter, r *http.Request) {
+	limit := 50
+	offset := 0
+	if l := r.URL.Query().Get("limit"); l != "" {
+		if parsed, err := strconv.Atoi(l); err == nil && parsed > 0 {
+			limit = min(parsed, 500)
+		}
+	}
+	if o := r.URL.Query().Get("offset"); o != "" {
+		if parsed, err := strconv.Atoi(o); err == nil && parsed >= 0 {
+			offset = parsed
+		}
+	}
+
+	var statuses []string
+	if st := r.URL.Query().Get("status"); st != "" {
+		for _, status := range strings.Split(st, ",") {
+			if status = strings.ToUpper(strings.TrimSpace(status)); status != "" {
+				statuses = append(statuses, status)
+			}
+		}
+	}
+
+	screenings, total, err := h.repo.ListScreenings(r.Context(), statuses, limit, offset)
+	if err != nil {
+		log.Printf("Error listing screenings: %v", err)
+		apierror.Write(w, r, http.StatusInternalServerError, apierror.CodeDatabaseError, "Failed to list screenings")
+		return
+	}
+
+	response := map[string]interface{}{
+		"screenings": screenings,
+		"total":      total,
+		"limit":      limit,
+		"offset":     offset,
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
 // ScreeningEvents streams real-time progress via Server-Sent Events
 func (h *Handler) ScreeningEvents(w http.ResponseWriter, r *http.Request) {
 	jobID := chi.URLParam(r, "jobId")
 	if jobID == "" {
-		http.Error(w, "Missing jobId parameter", http.StatusBadRequest)
+		apierror.Write(w, r, http.StatusBadRequest, apierror.CodeBadRequest, "Missing jobId parameter")
 		return
 	}
 
 	job := h.jobManager.Get(jobID)
 	if job == nil {
 		log.Printf("SSE connection failed: Job %s not found", jobID)
-		http.Error(w, "Job not found", http.StatusNotFound)
+		apierror.Write(w, r, http.StatusNotFound, apierror.CodeJobNotFound, "Job not found")
 		return
 	}
 
@@ -917,17 +1612,22 @@ func (h *Handler) ScreeningEvents(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Subscribe to job progress
+	sub, err := job.Subscribe()
+	if err != nil {
+		log.Printf("SSE connection refused for job %s: %v", jobID, err)
+		apierror.Write(w, r, http.StatusTooManyRequests, apierror.CodeRateLimited, "Too many listeners for this job")
+		return
+	}
+	defer job.Unsubscribe(sub)
+
 	// Send initial connection message to keep connection alive
 	fmt.Fprintf(w, ": connected\n\n")
 	flusher.Flush()
 
-	// Subscribe to job progress
-	progressChan := job.Subscribe()
-	defer job.Unsubscribe(progressChan)
-
 	// Check if job is already done
 	snapshot := job.GetSnapshot()
-	if snapshot.Status == jobs.StatusCompleted || snapshot.Status == jobs.StatusFailed {
+	if snapshot.Status.Finished() {
 		// Send all past progress events
 		for _, p := range snapshot.Progress {
 			data, _ := json.Marshal(p)
@@ -940,23 +1640,26 @@ func (h *Handler) ScreeningEvents(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Stream progress events
+	// Stream progress events. Slow clients receive coalesced updates rather
+	// than losing them, and terminal events are always delivered.
 	for {
 		select {
-		case progress, ok := <-progressChan:
-			if !ok {
-				// Channel closed, job is done - send completion event
-				fmt.Fprintf(w, "event: done\ndata: Job completed\n\n")
-				flusher.Flush()
-				return
+		case <-sub.Notify():
+			events, closed := sub.Drain()
+			for _, progress := range events {
+				data, _ := json.Marshal(progress)
+				fmt.Fprintf(w, "data: %s\n\n", data)
 			}
-
-			data, _ := json.Marshal(progress)
-			fmt.Fprintf(w, "data: %s\n\n", data)
 			flusher.Flush()
-			
-			// If this is the final progress event, send completion signal
-			if progress.Phase == jobs.PhaseComplete || progress.Phase == "failed" {
+
+			for _, progress := range events {
+				// If this is the final progress event, send completion signal
+				if progress.Phase == jobs.PhaseComplete || progress.Phase == jobs.PhaseFailed {
+					closed = true
+				}
+			}
+			if closed {
+				// Job is done - send completion event
 				fmt.Fprintf(w, "event: done\ndata: Job completed\n\n")
 				flusher.Flush()
 				return
@@ -972,7 +1675,7 @@ func (h *Handler) ScreeningEvents(w http.ResponseWriter, r *http.Request) {
 func (h *Handler) GetScreeningResults(w http.ResponseWriter, r *http.Request) {
 	jobID := chi.URLParam(r, "jobId")
 	if jobID == "" {
-		http.Error(w, "Missing jobId parameter", http.StatusBadRequest)
+		apierror.Write(w, r, http.StatusBadRequest, apierror.CodeBadRequest, "Missing jobId parameter")
 		return
 	}
 
@@ -994,7 +1697,7 @@ func (h *Handler) GetScreeningResults(w http.ResponseWriter, r *http.Request) {
 	results, err := h.repo.GetScreeningResultsByJobID(r.Context(), jobID, limit, offset)
 	if err != nil {
 		log.Printf("Error fetching screening results for job %s: %v", jobID, err)
-		http.Error(w, "Failed to fetch results", http.StatusInternalServerError)
+		apierror.Write(w, r, http.StatusInternalServerError, apierror.CodeDatabaseError, "Failed to fetch results")
 		return
 	}
 
@@ -1004,11 +1707,17 @@ func (h *Handler) GetScreeningResults(w http.ResponseWriter, r *http.Request) {
 		totalCount = int64(len(results))
 	}
 
+	metrics, err := h.repo.GetScreeningMetricsByJobID(r.Context(), jobID)
+	if err != nil {
+		log.Printf("Error fetching screening metrics for job %s: %v", jobID, err)
+	}
+
 	response := map[string]interface{}{
 		"results": results,
 		"total":   totalCount,
 		"limit":   limit,
 		"offset":  offset,
+		"metrics": metrics,
 	}
 
 	w.Header().Set("Content-Type", "application/json")
@@ -1019,21 +1728,26 @@ func (h *Handler) GetScreeningResults(w http.ResponseWriter, r *http.Request) {
 func (h *Handler) UpdateResultStatus(w http.ResponseWriter, r *http.Request) {
 	resultIDStr := chi.URLParam(r, "resultId")
 	if resultIDStr == "" {
-		http.Error(w, "Missing resultId parameter", http.StatusBadRequest)
+		apierror.Write(w, r, http.StatusBadRequest, apierror.CodeBadRequest, "Missing resultId parameter")
 		return
 	}
 
 	resultID, err := strconv.ParseInt(resultIDStr, 10, 64)
 	if err != nil {
-		http.Error(w, "Invalid resultId", http.StatusBadRequest)
+		apierror.Write(w, r, http.StatusBadRequest, apierror.CodeBadRequest, "Invalid resultId")
 		return
 	}
 
+	// Suppress marks a false positive so identical matches in later
+	// screenings are SUPPRESSED; SuppressDays overrides its lifetime
 	var req struct {
-		Status string `json:"status"`
+		Status       string `json:"status"`
+		Suppress     bool   `json:"suppress"`
+		SuppressDays int    `json:"suppressDays"`
+		Reason       string `json:"reason"`
 	}
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		apierror.Write(w, r, http.StatusBadRequest, apierror.CodeBadRequest, "Invalid request body")
 		return
 	}
 
@@ -1044,32 +1758,92 @@ func (h *Handler) UpdateResultStatus(w http.ResponseWriter, r *http.Request) {
 		"FALSE_POSITIVE": true,
 	}
 	if !validStatuses[req.Status] {
-		http.Error(w, "Invalid status value", http.StatusBadRequest)
+		apierror.Write(w, r, http.StatusBadRequest, apierror.CodeBadRequest, "Invalid status value")
+		return
+	}
+	if req.Suppress && req.Status != "FALSE_POSITIVE" {
+		apierror.Write(w, r, http.StatusBadRequest, apierror.CodeBadRequest, "Only false positives can be suppressed")
 		return
 	}
+	if req.SuppressDays < 0 {
+		apierror.Write(w, r, http.StatusBadRequest, apierror.CodeBadRequest, "suppressDays must be positive")
+		return
+	}
+	if req.SuppressDays == 0 {
+		req.SuppressDays = h.suppressionDays
+	}
+
+	var proposedBy string
+	if user := middleware.GetUser(r.Context()); user != nil {
+		proposedBy = user.Email
+	}
+
+	// Under four-eyes review a confirmation waits for a second user
+	if req.Status == "CONFIRMED" && h.fourEyes {
+		if proposedBy == "" {
+			apierror.Write(w, r, http.StatusUnauthorized, apierror.CodeUnauthorized, "Confirming a match requires a signed-in user")
+			return
+		}
+		req.Status = "PENDING_APPROVAL"
+	}
 
 	// Update in database
-	if err := h.repo.UpdateResultStatus(r.Context(), resultID, req.Status); err != nil {
+	if err := h.repo.UpdateResultStatus(r.Context(), resultID, req.Status, proposedBy); err != nil {
 		log.Printf("Failed to update result status: %v", err)
-		http.Error(w, "Failed to update status", http.StatusInternalServerError)
+		apierror.Write(w, r, http.StatusInternalServerError, apierror.CodeDatabaseError, "Failed to update status")
 		return
 	}
 
 	log.Printf("Updated result %d status to %s", resultID, req.Status)
-	
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(map[string]interface{}{
+
+	if req.Status == "CONFIRMED" {
+		h.events.Publish(events.MatchConfirmed{ResultID: resultID, By: proposedBy})
+	}
+
+	response := map[string]interface{}{
 		"success": true,
 		"id":      resultID,
 		"status":  req.Status,
-	})
+	}
+	if req.Suppress {
+		s, err := h.suppressResult(r.Context(), resultID, requestActor(r.Context()), req.SuppressDays, req.Reason)
+		if err != nil {
+			log.Printf("Failed to suppress result %d: %v", resultID, err)
+			apierror.Write(w, r, http.StatusInternalServerError, apierror.CodeDatabaseError, "Status updated but the suppression could not be saved")
+			return
+		}
+		response["suppression"] = s
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
 }
 
 // GetStats returns dashboard statistics
 func (h *Handler) GetStats(w http.ResponseWriter, r *http.Request) {
 	totalScreenings, totalMatches, activeLists, recentScreenings, err := h.repo.GetDashboardStats(r.Context())
 	if err != nil {
-		http.Error(w, "Database error", http.StatusInternalServerError)
+		apierror.Write(w, r, http.StatusInternalServerError, apierror.CodeDatabaseError, "Database error")
+		return
+	}
+
+	// Trend window in days (default 30, max 365) and bucket size (day or week)
+	windowDays := 30
+	if wd := r.URL.Query().Get("window"); wd != "" {
+		if parsed, err := strconv.Atoi(wd); err == nil && parsed > 0 {
+			windowDays = min(parsed, 365)
+		}
+	}
+	interval := "day"
+	if r.URL.Query().Get("interval") == "week" {
+		interval = "week"
+	}
+
+	since := time.Now().AddDate(0, 0, -windowDays)
+	trend, err := h.repo.GetScreeningTrend(r.Context(), since, interval)
+	if err != nil {
+		log.Printf("Error computing screening trend: %v", err)
+		apierror.Write(w, r, http.StatusInternalServerError, apierror.CodeDatabaseError, "Database error")
 		return
 	}
 
@@ -1080,12 +1854,42 @@ func (h *Handler) GetStats(w http.ResponseWriter, r *http.Request) {
 		"recentScreenings": recentScreenings,
 		"systemStatus":     "Healthy",
 		"activeWorkers":    h.psi.GetWorkerCount(),
+		"trend": map[string]interface{}{
+			"windowDays": windowDays,
+			"interval":   interval,
+			"points":     trend,
+		},
 	}
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(stats)
 }
 
+// GetListAnalytics reports match rates and review outcomes per customer and sanction list
+func (h *Handler) GetListAnalytics(w http.ResponseWriter, r *http.Request) {
+	customerLists, err := h.repo.GetCustomerListAnalytics(r.Context())
+	if err != nil {
+		log.Printf("Error computing customer list analytics: %v", err)
+		apierror.Write(w, r, http.StatusInternalServerError, apierror.CodeDatabaseError, "Database error")
+		return
+	}
+
+	sanctionLists, err := h.repo.GetSanctionListAnalytics(r.Context())
+	if err != nil {
+		log.Printf("Error computing sanction list analytics: %v", err)
+		apierror.Write(w, r, http.StatusInternalServerError, apierror.CodeDatabaseError, "Database error")
+		return
+	}
+
+	response := map[string]interface{}{
+		"customerLists": customerLists,
+		"sanctionLists": sanctionLists,
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
 // GetPerformanceMetrics returns real-time system performance metrics
 func (h *Handler) GetPerformanceMetrics(w http.ResponseWriter, r *http.Request) {
 	var m runtime.MemStats
@@ -1096,59 +1900,52 @@ func (h *Handler) GetPerformanceMetrics(w http.ResponseWriter, r *http.Request)
 	totalAllocMB := float64(m.TotalAlloc) / 1024 / 1024
 	sysMB := float64(m.Sys) / 1024 / 1024
 
-	// Get the latest screening for metrics (if any)
-	_, _, _, recentScreenings, _ := h.repo.GetDashboardStats(r.Context())
-	
-	// Default values - will be updated from last screening if available
+	// Use the measured phase timings of the latest screening (if any)
+	latest, err := h.repo.GetLatestScreeningMetrics(r.Context())
+	if err != nil {
+		log.Printf("Error fetching latest screening metrics: %v", err)
+	}
+
 	perfMetrics := map[string]interface{}{
-		"total_time_seconds":        0.0,
-		"total_time_formatted":      "0s",
-		"key_gen_time_seconds":      0.0,
-		"key_gen_time_formatted":    "0s",
-		"key_gen_percent":           0.0,
-		"hashing_time_seconds":      0.0,
-		"hashing_time_formatted":    "0s",
-		"hashing_percent":           0.0,
-		"witness_time_seconds":      0.0,
-		"witness_time_formatted":    "0s",
-		"witness_percent":           0.0,
-		"intersection_time_seconds": 0.0,
-		"intersection_time_formatted": "0s",
-		"intersection_percent":      0.0,
-		"num_workers":               h.psi.GetWorkerCount(),
-		"total_operations":          0,
-		"throughput_ops_per_sec":    0.0,
-	}
-
-	// If we have recent screenings, estimate metrics based on last one
-	if len(recentScreenings) > 0 && recentScreenings[0].Status == "COMPLETED" {
-		lastScreening := recentScreenings[0]
-		if !lastScreening.FinishedAt.IsZero() && !lastScreening.CreatedAt.IsZero() {
-			duration := lastScreening.FinishedAt.Sub(lastScreening.CreatedAt).Seconds()
-			if duration > 0 {
-				perfMetrics["total_time_seconds"] = duration
-				perfMetrics["total_time_formatted"] = fmt.Sprintf("%.2fs", duration)
-				perfMetrics["total_operations"] = lastScreening.CustomerCount
-				perfMetrics["throughput_ops_per_sec"] = float64(lastScreening.CustomerCount) / duration
-				
-				// Estimate phase breakdowns (typical PSI distribution)
-				perfMetrics["key_gen_time_seconds"] = duration * 0.15
-				perfMetrics["key_gen_time_formatted"] = fmt.Sprintf("%.2fs", duration * 0.15)
-				perfMetrics["key_gen_percent"] = 15.0
-				
-				perfMetrics["hashing_time_seconds"] = duration * 0.10
-				perfMetrics["hashing_time_formatted"] = fmt.Sprintf("%.2fs", duration * 0.10)
-				perfMetrics["hashing_percent"] = 10.0
-				
-				perfMetrics["witness_time_seconds"] = duration * 0.25
-				perfMetrics["witness_time_formatted"] = fmt.Sprintf("%.2fs", duration * 0.25)
-				perfMetrics["witness_percent"] = 25.0
-				
-				perfMetrics["intersection_time_seconds"] = duration * 0.50
-				perfMetrics["intersection_time_formatted"] = fmt.Sprintf("%.2fs", duration * 0.50)
-				perfMetrics["intersection_percent"] = 50.0
-			}
+		"total_time_seconds":     0.0,
+		"total_time_formatted":   "0s",
+		"phases":                 []map[string]interface{}{},
+		"num_workers":            h.psi.GetWorkerCount(),
+		"total_operations":       0,
+		"throughput_ops_per_sec": 0.0,
+		"hash_collisions":        0,
+		"library_errors":         psiadapter.LibraryErrors(),
+	}
+
+	if latest != nil && latest.TotalMs > 0 {
+		total := float64(latest.TotalMs) / 1000
+		perfMetrics["total_time_seconds"] = total
+		perfMetrics["total_time_formatted"] = fmt.Sprintf("%.2fs", total)
+		perfMetrics["total_operations"] = latest.RecordCount
+		perfMetrics["throughput_ops_per_sec"] = float64(latest.RecordCount) / total
+		perfMetrics["hash_collisions"] = latest.HashCollisions
+
+		phaseTimes := []struct {
+			name string
+			ms   int64
+		}{
+			{"Encryption", latest.EncryptionMs},
+			{"Network", latest.NetworkMs},
+			{"Intersection", latest.IntersectionMs},
+			{"Resolve", latest.ResolveMs},
+			{"Persist", latest.PersistMs},
+		}
+		phases := make([]map[string]interface{}, 0, len(phaseTimes))
+		for _, p := range phaseTimes {
+			seconds := float64(p.ms) / 1000
+			phases = append(phases, map[string]interface{}{
+				"name":           p.name,
+				"time_seconds":   seconds,
+				"time_formatted": fmt.Sprintf("%.2fs", seconds),
+				"percent":        float64(p.ms) / float64(latest.TotalMs) * 100,
+			})
 		}
+		perfMetrics["phases"] = phases
 	}
 
 	memMetrics := map[string]interface{}{
@@ -0,0 +1,108 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/SanthoshCheemala/FLARE/backend/internal/config"
+	"github.com/SanthoshCheemala/FLARE/backend/internal/jobs"
+	"github.com/SanthoshCheemala/FLARE/backend/internal/models"
+	"github.com/SanthoshCheemala/FLARE/backend/internal/repository"
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// newTestHandler returns handlers over a fresh in-memory database,
+// configured with the defaults and overrides (setting name to value). It
+// runs in a temporary working directory, where the backend keeps its files.
+func newTestHandler(t *testing.T, overrides map[string]string) *Handler {
+	t.Helper()
+	t.Chdir(t.TempDir())
+
+	name := strings.NewReplacer("/", "_", " ", "_").Replace(t.Name())
+	db, err := sql.Open("sqlite3", fmt.Sprintf("file:%s?mode=memory&cache=shared", name))
+	if err != nil {
+		t.Fatalf("open database: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	repo := repository.New(db)
+	if err := repo.InitSchema(); err != nil {
+		t.Fatalf("initialize schema: %v", err)
+	}
+	cfg, err := config.LoadFrom(config.Options{Overrides: overrides})
+	if err != nil {
+		t.Fatalf("load config: %v", err)
+	}
+	return NewHandler(repo, jobs.NewManager(1), cfg, nil)
+}
+
+// serve sends a request through the router, as the user signed in with
+// token unless it is empty
+func serve(h *Handler, token, method, path, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	rec := httptest.NewRecorder()
+	NewRouter(h).ServeHTTP(rec, req)
+	return rec
+}
+
+// signIn returns an access token for a user with role
+func signIn(t *testing.T, h *Handler, id int64, email, role string) string {
+	t.Helper()
+	token, err := h.auth.GenerateAccessToken(id, email, role)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return token
+}
+
+// seedResult stores a PENDING result matching a customer to a sanction
+func seedResult(t *testing.T, h *Handler) int64 {
+	t.Helper()
+	ctx := context.Background()
+	customerListID, err := h.repo.CreateCustomerList(ctx, "customers", "", "", 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	customer := &models.Customer{ExternalID: "C1", Name: "Alice Smith", ListID: customerListID, Hash: 101}
+	if err := h.repo.CreateCustomer(ctx, customer); err != nil {
+		t.Fatal(err)
+	}
+	sanctionListID, err := h.repo.CreateSanctionList(ctx, "OFAC SDN", "OFAC", models.ListCategorySanctions, "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sanction := &models.Sanction{Source: "OFAC", Name: "Alice Smith", ListID: sanctionListID, Hash: 101}
+	if err := h.repo.CreateSanction(ctx, sanction); err != nil {
+		t.Fatal(err)
+	}
+	result := &models.ScreeningResult{ScreeningID: 1, CustomerID: customer.ID, SanctionID: sanction.ID, MatchScore: 0.9, Status: "PENDING"}
+	if err := h.repo.CreateScreeningResult(ctx, result); err != nil {
+		t.Fatal(err)
+	}
+	return result.ID
+}
+
+// statusOf decodes the status of a result update or approval response
+func statusOf(t *testing.T, rec *httptest.ResponseRecorder) string {
+	t.Helper()
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status code = %d: %s", rec.Code, rec.Body)
+	}
+	var resp struct {
+		Status string `json:"status"`
+	}
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+		t.Fatal(err)
+	}
+	return resp.Status
+}
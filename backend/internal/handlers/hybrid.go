@@ -0,0 +1,197 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"runtime/debug"
+	"sync"
+	"time"
+
+	"github.com/SanthoshCheemala/FLARE/backend/internal/jobs"
+	"github.com/SanthoshCheemala/FLARE/backend/internal/models"
+	"github.com/SanthoshCheemala/FLARE/backend/internal/profiling"
+	"github.com/SanthoshCheemala/FLARE/backend/internal/psiadapter"
+)
+
+// screeningSide reports the progress of one side of a screening: the
+// server's lists or the local watchlists. A one-sided screening reports
+// straight to its job. The two sides of a HYBRID screening share one job,
+// so their messages are prefixed with the side, the job's percentage is the
+// mean of theirs and the local side's phase durations are prefixed with
+// "local_", leaving the remote ones to the screening metrics.
+type screeningSide struct {
+	job    *jobs.ScreeningJob
+	label  string
+	prefix string
+	shared *hybridProgress
+	phase  jobs.Phase
+}
+
+// hybridProgress is the latest percentage of each side of a HYBRID screening
+type hybridProgress struct {
+	mu      sync.Mutex
+	percent map[string]int
+}
+
+func (s *screeningSide) progress(phase jobs.Phase, percent int, message string, metrics map[string]string) {
+	if s.shared == nil {
+		s.job.AddProgress(phase, percent, message, metrics)
+		return
+	}
+	s.shared.mu.Lock()
+	defer s.shared.mu.Unlock()
+	s.phase = phase
+	s.shared.percent[s.label] = percent
+	total := 0
+	for _, p := range s.shared.percent {
+		total += p
+	}
+	s.job.AddProgress(phase, total/2, s.label+": "+message, metrics)
+}
+
+func (s *screeningSide) duration(name string, d time.Duration) {
+	s.job.RecordPhaseDuration(s.prefix+name, d)
+}
+
+// fail reports that the side stopped with err. The side counts as done
+// towards the job's percentage.
+func (s *screeningSide) fail(err error) {
+	s.progress(s.phase, 100, "failed: "+err.Error(), nil)
+}
+
+// hybridOutcome is what one side of a HYBRID screening found
+type hybridOutcome struct {
+	matches   *matchSet
+	sanctions []*models.Sanction
+	err       error
+}
+
+// screenHybrid runs a HYBRID screening: the server's lists and the local
+// watchlists are screened concurrently and the matches of both saved
+// together, each result recording the side it came from. If one side fails
+// the matches of the other are still saved and the screening ends PARTIAL.
+func (h *Handler) screenHybrid(ctx context.Context, job *jobs.ScreeningJob, psi *psiadapter.Adapter, capture *profiling.Capture, l localScreening) {
+	shared := &hybridProgress{percent: make(map[string]int)}
+	remote := &screeningSide{job: job, label: "Remote", shared: shared, phase: jobs.PhaseServerInit}
+	local := &screeningSide{job: job, label: "Local", prefix: "local_", shared: shared, phase: jobs.PhaseServerInit}
+	job.SetCounts(customerCount(l.serialized), 0)
+
+	var remoteOut, localOut hybridOutcome
+	var wg sync.WaitGroup
+	wg.Add(2)
+	// Captures are not safe for concurrent use; only the remote side marks
+	// its phases
+	go h.runHybridSide(&wg, remote, &remoteOut, func() (*matchSet, []*models.Sanction, error) {
+		return h.screenRemoteSide(ctx, job, psi, capture, remote, l)
+	})
+	go h.runHybridSide(&wg, local, &localOut, func() (*matchSet, []*models.Sanction, error) {
+		return h.screenLocalSide(ctx, job, psi, local, l)
+	})
+	wg.Wait()
+	if ctx.Err() != nil {
+		// Cancelled; the job is already CANCELLED
+		return
+	}
+
+	switch {
+	case remoteOut.err != nil && localOut.err != nil:
+		job.SetError(fmt.Errorf("remote lists: %v; local watchlists: %v", remoteOut.err, localOut.err))
+		job.SetStatus(jobs.StatusFailed)
+		return
+	case remoteOut.err != nil:
+		job.SetError(fmt.Errorf("remote lists failed: %w", remoteOut.err))
+	case localOut.err != nil:
+		job.SetError(fmt.Errorf("local watchlists failed: %w", localOut.err))
+	}
+	status := jobs.StatusCompleted
+	if remoteOut.err != nil || localOut.err != nil {
+		status = jobs.StatusPartial
+	}
+
+	capture.Phase(string(jobs.PhasePersist))
+	job.AddProgress(jobs.PhasePersist, 90, "Saving results to database", nil)
+	persistStart := time.Now()
+	// Both sides pair the same customers, so they are paired here rather
+	// than concurrently, and the results saved in one transaction
+	var records []models.MatchRecord
+	collisions := 0
+	for _, out := range []hybridOutcome{remoteOut, localOut} {
+		if out.err != nil {
+			continue
+		}
+		sideRecords, sideCollisions := h.matchRecords(ctx, out.matches, out.sanctions)
+		records = append(records, sideRecords...)
+		collisions += sideCollisions
+	}
+	resultIDs, err := h.saveMatchRecords(ctx, job.ID, l.screeningID, records)
+	if err != nil {
+		job.SetError(fmt.Errorf("failed to save results: %w", err))
+		job.SetStatus(jobs.StatusFailed)
+		return
+	}
+	job.RecordPhaseDuration("persist", time.Since(persistStart))
+	h.completeScreening(ctx, job, l.screeningID, resultIDs, customerCount(l.serialized), collisions, l.start, status)
+}
+
+// runHybridSide runs one side of a HYBRID screening into out. A panic fails
+// the side only.
+func (h *Handler) runHybridSide(wg *sync.WaitGroup, side *screeningSide, out *hybridOutcome, screen func() (*matchSet, []*models.Sanction, error)) {
+	defer wg.Done()
+	defer func() {
+		if r := recover(); r != nil {
+			log.Printf("Screening panic on %s side: %v\nStack: %s", side.label, r, debug.Stack())
+			out.err = fmt.Errorf("panic: %v", r)
+		}
+		if out.err != nil {
+			side.fail(out.err)
+		}
+	}()
+	out.matches, out.sanctions, out.err = screen()
+}
+
+// screenRemoteSide intersects the customers with the server's lists and
+// resolves the matches. The session is closed either way: HYBRID screenings
+// are not re-resolved.
+func (h *Handler) screenRemoteSide(ctx context.Context, job *jobs.ScreeningJob, psi *psiadapter.Adapter, capture *profiling.Capture, side *screeningSide, l localScreening) (*matchSet, []*models.Sanction, error) {
+	if err := psi.ValidateMemoryRequirement(len(l.serialized), 0, l.memoryGB); err != nil {
+		return nil, nil, fmt.Errorf("screening exceeds its memory limit: %w", err)
+	}
+	session, matches, err := h.intersectRemote(ctx, job, psi, capture, side, nil, l.serialized, l.enabledColumns, l.names)
+	if session != nil {
+		defer func() {
+			// The job's context is done if it was cancelled
+			if err := session.Close(context.Background()); err != nil {
+				log.Printf("Warning: failed to close session %s: %v", session.ID, err)
+			}
+		}()
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	side.progress(jobs.PhasePersist, 90, "Resolving matches", nil)
+	resolveStart := time.Now()
+	sanctionRecords, err := session.Resolve(ctx, matches)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to resolve sanctions: %w", err)
+	}
+	side.duration("resolve", time.Since(resolveStart))
+	side.progress(jobs.PhasePersist, 100, fmt.Sprintf("Resolved %d sanctions", len(sanctionRecords)), nil)
+
+	m := l.matchSet(job, session.Hash, matches)
+	m.sessionID = session.ID
+	m.listSource = models.ListSourceRemote
+	return m, sanctionRecords, nil
+}
+
+// screenLocalSide intersects the customers with the local watchlists and
+// resolves the matches from them
+func (h *Handler) screenLocalSide(ctx context.Context, job *jobs.ScreeningJob, psi *psiadapter.Adapter, side *screeningSide, l localScreening) (*matchSet, []*models.Sanction, error) {
+	scheme, matches, resolved, err := h.intersectLocal(ctx, job, psi, nil, side, l)
+	if err != nil {
+		return nil, nil, err
+	}
+	side.progress(jobs.PhasePersist, 100, fmt.Sprintf("Resolved %d entries", len(resolved)), nil)
+	return l.matchSet(job, scheme, matches), resolved, nil
+}
@@ -0,0 +1,105 @@
+package handlers
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/SanthoshCheemala/FLARE/backend/internal/apierror"
+	"github.com/SanthoshCheemala/FLARE/backend/internal/models"
+)
+
+// idempotencyHeader names the header clients set to retry StartScreening
+// safely
+const idempotencyHeader = "Idempotency-Key"
+
+// maxIdempotencyKey bounds the length of an Idempotency-Key
+const maxIdempotencyKey = 255
+
+// startingKey is an Idempotency-Key in the scope of whoever sent it.
+// Callers pick their keys independently, so one caller's key never reaches
+// another's screening.
+type startingKey struct {
+	scope string // See idempotencyScope
+	key   string
+}
+
+// idempotencyScope names who sent a request: the signed-in user, or for an
+// anonymous request the client address (after RealIP), which is all that
+// tells anonymous callers apart
+func idempotencyScope(r *http.Request) string {
+	if by := requestActor(r.Context()); by.id != 0 {
+		return fmt.Sprintf("user %d", by.id)
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	return "client " + host
+}
+
+// startingKeys are the Idempotency-Keys of StartScreening requests being
+// handled. A retry arriving before the first request stored its screening
+// is refused rather than starting a second job.
+type startingKeys struct {
+	mu   sync.Mutex
+	keys map[startingKey]bool
+}
+
+// acquire marks key as in flight, reporting false if it already is
+func (k *startingKeys) acquire(key startingKey) bool {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	if k.keys[key] {
+		return false
+	}
+	if k.keys == nil {
+		k.keys = make(map[startingKey]bool)
+	}
+	k.keys[key] = true
+	return true
+}
+
+func (k *startingKeys) release(key startingKey) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	delete(k.keys, key)
+}
+
+// requestHash fingerprints a request body, so a key reused for a different
+// request is told apart from a retry
+func requestHash(body []byte) string {
+	sum := sha256.Sum256(body)
+	return hex.EncodeToString(sum[:])
+}
+
+// replayScreening answers a StartScreening request whose Idempotency-Key
+// started a screening in the same scope within the configured window: with
+// that screening's job, or a conflict when the key came with a different
+// request. It reports whether it wrote a response.
+func (h *Handler) replayScreening(w http.ResponseWriter, r *http.Request, key startingKey, hash string) bool {
+	existing, err := h.repo.GetScreeningByIdempotencyKey(r.Context(), key.scope, key.key, time.Now().Add(-h.psiConfig.IdempotencyWindow))
+	if err != nil {
+		apierror.Write(w, r, http.StatusInternalServerError, apierror.CodeDatabaseError, "Failed to look up Idempotency-Key")
+		return true
+	}
+	if existing == nil {
+		return false
+	}
+	if existing.RequestHash != hash {
+		apierror.Write(w, r, http.StatusUnprocessableEntity, apierror.CodeConflict,
+			"Idempotency-Key was already used for a different screening request")
+		return true
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Idempotent-Replayed", "true")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(models.StartScreeningResponse{JobID: existing.JobID})
+	return true
+}
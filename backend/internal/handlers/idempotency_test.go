@@ -0,0 +1,52 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/SanthoshCheemala/FLARE/backend/internal/auth"
+	"github.com/SanthoshCheemala/FLARE/backend/internal/models"
+)
+
+func TestIdempotencyKeyScopes(t *testing.T) {
+	h := newTestHandler(t, nil)
+	request := func(remoteAddr string, user *auth.UserContext) *http.Request {
+		r := httptest.NewRequest(http.MethodPost, "/screenings", nil)
+		r.RemoteAddr = remoteAddr
+		if user != nil {
+			r = r.WithContext(auth.SetUserContext(r.Context(), user))
+		}
+		return r
+	}
+	alice := &auth.UserContext{UserID: 5, Email: "alice@bank.test"}
+
+	// One screening started with the key by an anonymous client and one by a user
+	for i, r := range []*http.Request{request("10.0.0.1:5000", nil), request("10.0.0.1:5000", alice)} {
+		s := &models.Screening{JobID: []string{"job-anon", "job-alice"}[i], Status: "RUNNING",
+			IdempotencyScope: idempotencyScope(r), IdempotencyKey: "key-1", RequestHash: "hash"}
+		if err := h.repo.CreateScreening(context.Background(), s); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, tc := range []struct {
+		name       string
+		r          *http.Request
+		wantReplay bool
+	}{
+		{"same client, new port", request("10.0.0.1:6000", nil), true},
+		{"another client", request("10.0.0.2:5000", nil), false},
+		{"same user, another client", request("10.0.0.2:5000", alice), true},
+		{"another user", request("10.0.0.1:5000", &auth.UserContext{UserID: 6}), false},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			key := startingKey{scope: idempotencyScope(tc.r), key: "key-1"}
+			if replayed := h.replayScreening(rec, tc.r, key, "hash"); replayed != tc.wantReplay {
+				t.Errorf("replayed = %v, want %v (scope %q)", replayed, tc.wantReplay, key.scope)
+			}
+		})
+	}
+}
@@ -0,0 +1,180 @@
+package handlers
+
+import (
+	"math"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// mappingSynonyms are the normalized header names (lower case, letters and
+// digits only) taken to mean each mappable field, best match first
+var mappingSynonyms = map[string][]string{
+	"id":           {"id", "customerid", "custid", "clientid", "externalid", "accountid", "customernumber", "clientnumber", "customerref", "reference", "ref"},
+	"name":         {"name", "fullname", "customername", "clientname", "legalname", "accountname", "holdername", "partyname"},
+	"dob":          {"dob", "dateofbirth", "birthdate", "birthday", "born", "birth"},
+	"country":      {"country", "countrycode", "nationality", "citizenship", "residencecountry", "countryofresidence", "jurisdiction"},
+	"entity_type":  {"entitytype", "type", "partytype", "customertype"},
+	"registration": {"registration", "registrationnumber", "regno", "companynumber", "tailnumber"},
+	"imo_number":   {"imonumber", "imo", "imono"},
+}
+
+// isoCountryCodes lists the ISO 3166-1 alpha-2 codes
+const isoCountryCodes = "AD AE AF AG AI AL AM AO AQ AR AS AT AU AW AX AZ BA BB BD BE BF BG BH BI BJ BL BM BN BO BQ BR BS BT BV BW BY BZ " +
+	"CA CC CD CF CG CH CI CK CL CM CN CO CR CU CV CW CX CY CZ DE DJ DK DM DO DZ EC EE EG EH ER ES ET FI FJ FK FM FO FR " +
+	"GA GB GD GE GF GG GH GI GL GM GN GP GQ GR GS GT GU GW GY HK HM HN HR HT HU ID IE IL IM IN IO IQ IR IS IT JE JM JO JP " +
+	"KE KG KH KI KM KN KP KR KW KY KZ LA LB LC LI LK LR LS LT LU LV LY MA MC MD ME MF MG MH MK ML MM MN MO MP MQ MR MS MT " +
+	"MU MV MW MX MY MZ NA NC NE NF NG NI NL NO NP NR NU NZ OM PA PE PF PG PH PK PL PM PN PR PS PT PW PY QA RE RO RS RU RW " +
+	"SA SB SC SD SE SG SH SI SJ SK SL SM SN SO SR SS ST SV SX SY SZ TC TD TF TG TH TJ TK TL TM TN TO TR TT TV TW TZ UA UG " +
+	"UM US UY UZ VA VC VE VG VI VN VU WF WS YE YT ZA ZM ZW"
+
+// minMappingConfidence is the score below which no column is suggested
+const minMappingConfidence = 0.5
+
+// mappingSuggestion is the column suggested for one field
+type mappingSuggestion struct {
+	Field      string  `json:"field"`
+	Column     string  `json:"column"`
+	Confidence float64 `json:"confidence"` // 0 to 1
+	Reason     string  `json:"reason"`
+}
+
+// suggestColumnMapping suggests a columnMapping for a customer list from its
+// header names and a sample of its rows. Each column is suggested for at
+// most one field, best scores first.
+func suggestColumnMapping(columns []previewColumn, records [][]string) []mappingSuggestion {
+	var candidates []mappingSuggestion
+	for field, synonyms := range mappingSynonyms {
+		for i, col := range columns {
+			values := make([]string, 0, len(records))
+			for _, record := range records {
+				if v := strings.TrimSpace(record[i]); v != "" {
+					values = append(values, v)
+				}
+			}
+
+			score, reason := headerScore(col.Name, synonyms)
+			if s, r := valueScore(field, col, values); s > 0 {
+				score += s
+				reason = strings.TrimPrefix(reason+"; "+r, "; ")
+			}
+			if score >= minMappingConfidence {
+				candidates = append(candidates, mappingSuggestion{Field: field, Column: col.Name, Confidence: math.Min(score, 1), Reason: reason})
+			}
+		}
+	}
+
+	sort.SliceStable(candidates, func(a, b int) bool {
+		if candidates[a].Confidence != candidates[b].Confidence {
+			return candidates[a].Confidence > candidates[b].Confidence
+		}
+		return candidates[a].Field < candidates[b].Field
+	})
+	usedFields := make(map[string]bool)
+	usedColumns := make(map[string]bool)
+	suggestions := make([]mappingSuggestion, 0)
+	for _, c := range candidates {
+		if usedFields[c.Field] || usedColumns[c.Column] {
+			continue
+		}
+		usedFields[c.Field], usedColumns[c.Column] = true, true
+		suggestions = append(suggestions, c)
+	}
+	sort.Slice(suggestions, func(a, b int) bool { return suggestions[a].Field < suggestions[b].Field })
+	return suggestions
+}
+
+// headerScore rates a header against a field's synonyms: 1 for an exact
+// match, 0.6 when a synonym is one of its words
+func headerScore(header string, synonyms []string) (float64, string) {
+	normalized := normalizeHeader(header)
+	for _, s := range synonyms {
+		if normalized == s {
+			return 1, "header name"
+		}
+	}
+	words := strings.FieldsFunc(strings.ToLower(header), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	for _, s := range synonyms {
+		for _, w := range words {
+			if w == s && len(s) > 2 {
+				return 0.6, "header contains " + s
+			}
+		}
+	}
+	return 0, ""
+}
+
+// valueScore rates how well a column's sampled values fit a field
+func valueScore(field string, col previewColumn, values []string) (float64, string) {
+	if len(values) == 0 {
+		return 0, ""
+	}
+	switch field {
+	case "dob":
+		if col.Type != columnTypeDate {
+			return 0, ""
+		}
+		births := fraction(values, plausibleBirthDate)
+		return 0.7 * births, "values are birth dates"
+	case "country":
+		codes := fraction(values, func(v string) bool {
+			return len(v) == 2 && strings.Contains(isoCountryCodes, strings.ToUpper(v))
+		})
+		if codes < 0.8 {
+			return 0, ""
+		}
+		return 0.7 * codes, "values are ISO country codes"
+	case "name":
+		if col.Type != columnTypeString {
+			return 0, ""
+		}
+		spaced := fraction(values, func(v string) bool { return strings.Contains(v, " ") })
+		return 0.3 * spaced, "values are multi-word names"
+	case "id":
+		seen := make(map[string]bool, len(values))
+		for _, v := range values {
+			seen[v] = true
+		}
+		if len(seen) < len(values) || strings.ContainsAny(strings.Join(values, ""), " ") {
+			return 0, ""
+		}
+		return 0.2, "values are unique identifiers"
+	}
+	return 0, ""
+}
+
+// plausibleBirthDate reports whether v is a date in the last 130 years
+func plausibleBirthDate(v string) bool {
+	for _, layout := range previewDateLayouts {
+		if t, err := time.Parse(layout, v); err == nil {
+			return t.Before(time.Now()) && t.Year() > time.Now().Year()-130
+		}
+	}
+	return false
+}
+
+// fraction returns the share of values for which ok holds
+func fraction(values []string, ok func(string) bool) float64 {
+	n := 0
+	for _, v := range values {
+		if ok(v) {
+			n++
+		}
+	}
+	return float64(n) / float64(len(values))
+}
+
+// normalizeHeader lower-cases a header and drops everything but letters and
+// digits, so "Date of Birth" and "date_of_birth" compare equal
+func normalizeHeader(header string) string {
+	var b strings.Builder
+	for _, r := range strings.ToLower(header) {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,41 @@
+package handlers
+
+import (
+	"log"
+
+	"github.com/SanthoshCheemala/FLARE/backend/internal/models"
+	"github.com/SanthoshCheemala/FLARE/backend/internal/psiadapter"
+	"github.com/SanthoshCheemala/FLARE/backend/internal/translit"
+)
+
+// OfflineMatch is a customer and a sanction record it matched in an offline
+// screening
+type OfflineMatch struct {
+	Customer *models.Customer
+	Sanction *models.Sanction
+	Channel  string // models.MatchChannelName or models.MatchChannelDocument
+}
+
+// PairOfflineMatches pairs the customers behind an offline screening's match
+// hashes with its resolved sanctions, confirming each pair on the full
+// records as a screening does. It returns the pairs and the number of hash
+// collisions.
+func PairOfflineMatches(customers []*models.Customer, serialized []string, hashes []uint64, sanctions []*models.Sanction, columns []string, names translit.Profile, scheme psiadapter.HashScheme) ([]OfflineMatch, int) {
+	customerMap, collisions := customerBuckets(scheme.Hash(serialized), serialized)
+	sanctionMap := sanctionBuckets(sanctions)
+
+	var matches []OfflineMatch
+	for _, hash := range hashes {
+		for _, ci := range customerMap[int64(hash)] {
+			for _, sanction := range sanctionMap[int64(hash)] {
+				if !recordsMatch(serialized[ci], sanction, columns, names) {
+					collisions++
+					log.Printf("Warning: hash collision on %d: customer %s does not match sanction %s", hash, customers[ci].ExternalID, sanction.Name)
+					continue
+				}
+				matches = append(matches, OfflineMatch{Customer: customers[ci], Sanction: sanction, Channel: matchChannel(serialized[ci])})
+			}
+		}
+	}
+	return matches, collisions
+}
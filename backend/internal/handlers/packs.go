@@ -0,0 +1,225 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/SanthoshCheemala/FLARE/backend/internal/apierror"
+	"github.com/SanthoshCheemala/FLARE/backend/internal/client"
+	"github.com/SanthoshCheemala/FLARE/backend/internal/events"
+	"github.com/SanthoshCheemala/FLARE/backend/internal/models"
+	"github.com/SanthoshCheemala/FLARE/backend/internal/repository"
+	"github.com/go-chi/chi/v5"
+)
+
+// Reconnect delays for pack update streams
+const (
+	packRetryMin = 5 * time.Second
+	packRetryMax = 5 * time.Minute
+)
+
+// packWatcher follows the update stream of every subscribed pack and
+// records the latest version the server pushed
+type packWatcher struct {
+	repo   *repository.Repository
+	client *client.PSIClient
+	events *events.Bus // Receives a ListUpdated for each pack update
+
+	mu      sync.Mutex
+	cancels map[string]context.CancelFunc
+}
+
+func newPackWatcher(repo *repository.Repository, psiClient *client.PSIClient, bus *events.Bus) *packWatcher {
+	return &packWatcher{repo: repo, client: psiClient, events: bus, cancels: make(map[string]context.CancelFunc)}
+}
+
+// resume watches the packs subscribed to before a restart
+func (pw *packWatcher) resume(ctx context.Context) {
+	subs, err := pw.repo.ListPackSubscriptions(ctx)
+	if err != nil {
+		log.Printf("Warning: failed to load pack subscriptions: %v", err)
+		return
+	}
+	for _, s := range subs {
+		if !s.Deleted {
+			pw.watch(s.PackID)
+		}
+	}
+}
+
+// watch starts following packID unless it is already followed
+func (pw *packWatcher) watch(packID string) {
+	pw.mu.Lock()
+	defer pw.mu.Unlock()
+	if _, ok := pw.cancels[packID]; ok {
+		return
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	pw.cancels[packID] = cancel
+	go pw.run(ctx, packID)
+}
+
+func (pw *packWatcher) unwatch(packID string) {
+	pw.mu.Lock()
+	defer pw.mu.Unlock()
+	if cancel, ok := pw.cancels[packID]; ok {
+		cancel()
+		delete(pw.cancels, packID)
+	}
+}
+
+// run keeps one pack's update stream connected, backing off while the
+// server is unreachable, until the pack is deleted or unwatched
+func (pw *packWatcher) run(ctx context.Context, packID string) {
+	backoff := packRetryMin
+	for {
+		err := pw.client.WatchPack(ctx, packID, func(u models.PackUpdate) {
+			backoff = packRetryMin
+			deleted := u.Event == models.PackEventDeleted
+			if err := pw.repo.UpdatePackSubscription(ctx, packID, u.Pack.Name, u.Pack.Version, deleted); err != nil {
+				log.Printf("Warning: failed to record update of pack %s: %v", packID, err)
+			}
+			action := events.ListChanged
+			if deleted {
+				action = events.ListDeleted
+				log.Printf("Warning: subscribed pack %s was deleted by the server", packID)
+			} else {
+				log.Printf("Pack %s is at version %d (%d lists)", packID, u.Pack.Version, u.Pack.ListCount)
+			}
+			pw.events.Publish(events.ListUpdated{Kind: events.ListPack, ID: packID, Action: action})
+		})
+		if ctx.Err() != nil || err == nil {
+			return
+		}
+
+		var apiErr *apierror.Error
+		if errors.As(err, &apiErr) && apiErr.Code == apierror.CodePackNotFound {
+			log.Printf("Warning: subscribed pack %s no longer exists on the server", packID)
+			if err := pw.repo.UpdatePackSubscription(ctx, packID, "", 0, true); err != nil {
+				log.Printf("Warning: failed to record deletion of pack %s: %v", packID, err)
+			}
+			pw.events.Publish(events.ListUpdated{Kind: events.ListPack, ID: packID, Action: events.ListDeleted})
+			return
+		}
+
+		log.Printf("Warning: pack %s update stream failed: %v; retrying in %s", packID, err, backoff)
+		select {
+		case <-time.After(backoff):
+		case <-ctx.Done():
+			return
+		}
+		if backoff *= 2; backoff > packRetryMax {
+			backoff = packRetryMax
+		}
+	}
+}
+
+// screeningPackIDs returns the packs a screening uses: those it names, or
+// the client's live subscriptions when it names no lists, categories or packs
+func (h *Handler) screeningPackIDs(ctx context.Context, req models.StartScreeningRequest) ([]string, error) {
+	if len(req.PackIDs) > 0 || len(req.SanctionListIDs) > 0 || len(req.Categories) > 0 {
+		return req.PackIDs, nil
+	}
+	subs, err := h.repo.ListPackSubscriptions(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var ids []string
+	for _, s := range subs {
+		if !s.Deleted {
+			ids = append(ids, s.PackID)
+		}
+	}
+	return ids, nil
+}
+
+// ListPacks returns the server's pack catalog, marking subscribed packs
+func (h *Handler) ListPacks(w http.ResponseWriter, r *http.Request) {
+	packs, err := h.psiClient.ListPacks(r.Context())
+	if err != nil {
+		log.Printf("Failed to fetch packs from server: %v", err)
+		writeUpstreamError(w, r, err, "Failed to fetch packs")
+		return
+	}
+	subs, err := h.repo.ListPackSubscriptions(r.Context())
+	if err != nil {
+		apierror.Write(w, r, http.StatusInternalServerError, apierror.CodeDatabaseError, "Failed to load pack subscriptions")
+		return
+	}
+	subscribed := make(map[string]bool, len(subs))
+	for _, s := range subs {
+		subscribed[s.PackID] = true
+	}
+
+	type catalogEntry struct {
+		models.WatchlistPack
+		Subscribed bool `json:"subscribed"`
+	}
+	entries := make([]catalogEntry, len(packs))
+	for i, p := range packs {
+		entries[i] = catalogEntry{WatchlistPack: p, Subscribed: subscribed[p.ID]}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{"packs": entries})
+}
+
+// ListPackSubscriptions returns the packs this client subscribes to
+func (h *Handler) ListPackSubscriptions(w http.ResponseWriter, r *http.Request) {
+	subs, err := h.repo.ListPackSubscriptions(r.Context())
+	if err != nil {
+		apierror.Write(w, r, http.StatusInternalServerError, apierror.CodeDatabaseError, "Failed to load pack subscriptions")
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{"subscriptions": subs})
+}
+
+// SubscribePack subscribes to a server pack and starts following its updates
+func (h *Handler) SubscribePack(w http.ResponseWriter, r *http.Request) {
+	packID := chi.URLParam(r, "id")
+	pack, err := h.psiClient.GetPack(r.Context(), packID)
+	if err != nil {
+		writeUpstreamError(w, r, err, "Failed to fetch pack")
+		return
+	}
+
+	created, err := h.repo.SubscribePack(r.Context(), pack.ID, pack.Name, pack.Version)
+	if err != nil {
+		apierror.Write(w, r, http.StatusInternalServerError, apierror.CodeDatabaseError, "Failed to store subscription")
+		return
+	}
+	h.packs.watch(pack.ID)
+
+	status := http.StatusOK
+	if created {
+		log.Printf("Subscribed to pack %s (%s) at version %d", pack.ID, pack.Name, pack.Version)
+		status = http.StatusCreated
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(pack)
+}
+
+// UnsubscribePack stops following a pack
+func (h *Handler) UnsubscribePack(w http.ResponseWriter, r *http.Request) {
+	packID := chi.URLParam(r, "id")
+	removed, err := h.repo.UnsubscribePack(r.Context(), packID)
+	if err != nil {
+		apierror.Write(w, r, http.StatusInternalServerError, apierror.CodeDatabaseError, "Failed to remove subscription")
+		return
+	}
+	if !removed {
+		apierror.Write(w, r, http.StatusNotFound, apierror.CodePackNotFound, "Not subscribed to this pack")
+		return
+	}
+	h.packs.unwatch(packID)
+
+	log.Printf("Unsubscribed from pack %s", packID)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]bool{"success": true})
+}
@@ -0,0 +1,332 @@
+package handlers
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math/rand"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/SanthoshCheemala/FLARE/backend/internal/apierror"
+	"github.com/SanthoshCheemala/FLARE/backend/internal/models"
+	"github.com/SanthoshCheemala/FLARE/backend/internal/protocol"
+	"github.com/SanthoshCheemala/FLARE/backend/internal/psiadapter"
+	"github.com/SanthoshCheemala/FLARE/backend/internal/translit"
+)
+
+// Records screened by PreflightScreening
+const (
+	defaultPreflightSample = 100
+	maxPreflightSample     = 1000
+)
+
+// Codes of the warnings PreflightScreening reports
+const (
+	warnUnknownColumn  = "unknown_column"    // The mapping names a header the list does not have
+	warnSkippedRecords = "skipped_records"   // Records dropped for an unknown entity type or missing identifier
+	warnEmptyValues    = "empty_values"      // A hashed field is empty in most of the sample
+	warnDOBFormat      = "dob_format"        // Dates of birth that could not be normalized to YYYY-MM-DD
+	warnCountryFormat  = "country_format"    // Countries are not two-letter codes
+	warnNameFormat     = "name_format"       // Names without a letter, likely an ID column
+	warnNoMatches      = "no_sample_matches" // Nothing matched and the mapping looks wrong
+)
+
+// sanctionDOBLayout is the format dates of birth are normalized to before
+// hashing; loaded DOBs still in another format could not be read
+const sanctionDOBLayout = "2006-01-02"
+
+// preflightExamples is the number of offending values a warning quotes
+const preflightExamples = 3
+
+// PreflightScreening screens a random sample of a customer list with the
+// settings of a screening, without saving anything, and reports the sample
+// matches along with mapping problems likely to cause missed matches
+func (h *Handler) PreflightScreening(w http.ResponseWriter, r *http.Request) {
+	var req models.PreflightScreeningRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		apierror.Write(w, r, http.StatusBadRequest, apierror.CodeBadRequest, "Invalid request body")
+		return
+	}
+	if source, ok := models.ParseListSource(req.ListSource); !ok || source != models.ListSourceRemote {
+		apierror.Write(w, r, http.StatusBadRequest, apierror.CodeBadRequest, "Pre-flight checks screen against the PSI server's lists")
+		return
+	}
+	sampleSize := req.SampleSize
+	if sampleSize == 0 {
+		sampleSize = defaultPreflightSample
+	}
+	if sampleSize < 0 || sampleSize > maxPreflightSample {
+		apierror.Write(w, r, http.StatusBadRequest, apierror.CodeBadRequest, fmt.Sprintf("sampleSize must be between 1 and %d", maxPreflightSample))
+		return
+	}
+
+	categories := make([]string, 0, len(req.Categories))
+	for _, c := range req.Categories {
+		category, ok := models.ParseListCategory(c)
+		if !ok {
+			apierror.Write(w, r, http.StatusBadRequest, apierror.CodeBadRequest, fmt.Sprintf("Unknown list category %q", c))
+			return
+		}
+		categories = append(categories, category)
+	}
+	packIDs, err := h.screeningPackIDs(r.Context(), req.StartScreeningRequest)
+	if err != nil {
+		apierror.Write(w, r, http.StatusInternalServerError, apierror.CodeDatabaseError, "Failed to load pack subscriptions")
+		return
+	}
+	names, err := translit.Parse(h.psiConfig.Transliteration)
+	if req.Transliteration != nil {
+		names, err = translit.New(req.Transliteration)
+	}
+	if err != nil {
+		apierror.Write(w, r, http.StatusBadRequest, apierror.CodeBadRequest, err.Error())
+		return
+	}
+
+	lists, err := h.repo.GetCustomerLists(r.Context())
+	if err != nil {
+		apierror.Write(w, r, http.StatusInternalServerError, apierror.CodeDatabaseError, "Database error")
+		return
+	}
+	var list *models.CustomerList
+	for i := range lists {
+		if lists[i].ID == req.CustomerListID {
+			list = &lists[i]
+			break
+		}
+	}
+	if list == nil {
+		apierror.Write(w, r, http.StatusNotFound, apierror.CodeListNotFound, "List not found")
+		return
+	}
+	headers, err := h.customerListHeaders(list.FilePath)
+	if err != nil {
+		apierror.Write(w, r, http.StatusInternalServerError, apierror.CodeInternal, "Failed to read CSV headers")
+		return
+	}
+
+	enabledColumns := screeningColumns(req.ColumnMapping)
+	customers, serialized, err := h.loadCustomerDataFromCSV(r.Context(), list.ID, req.ColumnMapping, enabledColumns, names)
+	if err != nil {
+		apierror.Write(w, r, http.StatusInternalServerError, apierror.CodeInternal, fmt.Sprintf("Failed to load customers: %v", err))
+		return
+	}
+
+	// Sample without replacement, keeping list order
+	picked := rand.Perm(len(customers))[:min(sampleSize, len(customers))]
+	sort.Ints(picked)
+	sample := make([]*models.Customer, len(picked))
+	sampleData := make([]string, len(picked))
+	for i, p := range picked {
+		sample[i], sampleData[i] = customers[p], serialized[p]
+	}
+
+	preflight := models.ScreeningPreflight{
+		CustomerRecords:  len(customers),
+		SampleSize:       len(sample),
+		MatchedCustomers: make([]string, 0),
+		EnabledColumns:   enabledColumns,
+		Warnings:         diagnoseMapping(headers, req.ColumnMapping, list.RecordCount, len(customers), sample, enabledColumns),
+	}
+
+	if len(sample) > 0 {
+		sanctionListIDs := make([]string, len(req.SanctionListIDs))
+		for i, id := range req.SanctionListIDs {
+			sanctionListIDs[i] = fmt.Sprintf("%d", id)
+		}
+		hashes, scheme, err := h.screenSample(r.Context(), sampleData, protocol.InitSessionRequest{
+			SanctionListIDs: sanctionListIDs,
+			EnabledColumns:  enabledColumns,
+			Categories:      categories,
+			PackIDs:         packIDs,
+			Transliteration: names,
+		})
+		var psiErr *psiadapter.PSIError
+		if errors.As(err, &psiErr) {
+			apierror.Write(w, r, http.StatusInternalServerError, apierror.CodePSIFailed, err.Error())
+			return
+		}
+		if err != nil {
+			writeUpstreamError(w, r, err, "Failed to screen sample")
+			return
+		}
+
+		buckets, _ := customerBuckets(scheme.Hash(sampleData), sampleData)
+		matched := make(map[int]bool)
+		for _, hash := range hashes {
+			for _, i := range buckets[int64(hash)] {
+				matched[i] = true
+			}
+		}
+		for i, c := range sample {
+			if matched[i] {
+				preflight.MatchedCustomers = append(preflight.MatchedCustomers, c.ExternalID)
+			}
+		}
+		preflight.Matches = len(preflight.MatchedCustomers)
+	}
+
+	if preflight.Matches == 0 && len(preflight.Warnings) > 0 {
+		preflight.Warnings = append(preflight.Warnings, models.PreflightWarning{
+			Code:    warnNoMatches,
+			Message: "No sampled customer matched and the mapping looks suspicious; fix the warnings above before screening the full list",
+		})
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(preflight)
+}
+
+// screenSample runs the PSI protocol for serialized customers in a session
+// of its own and returns the match hashes and the session's hash scheme
+func (h *Handler) screenSample(ctx context.Context, serialized []string, init protocol.InitSessionRequest) ([]uint64, psiadapter.HashScheme, error) {
+	session, err := protocol.Open(ctx, h.sessions, init)
+	if err != nil {
+		return nil, psiadapter.HashScheme{}, fmt.Errorf("failed to init session with server: %w", err)
+	}
+	defer func() {
+		if err := session.Close(context.Background()); err != nil {
+			log.Printf("Warning: failed to close session %s: %v", session.ID, err)
+		}
+	}()
+	scheme := session.Hash
+
+	var hashes []uint64
+	seen := make(map[uint64]bool)
+	for b, params := range session.Params {
+		pp, msg, le, err := h.psi.DeserializeParams(params)
+		if err != nil {
+			return nil, scheme, fmt.Errorf("failed to deserialize params for batch %d: %w", b, err)
+		}
+		ciphertexts, err := h.psi.EncryptClient(ctx, serialized, &psiadapter.ServerContext{PP: pp, Msg: msg, LE: le, Hash: scheme})
+		if err != nil {
+			return nil, scheme, fmt.Errorf("failed to encrypt sample: %w", err)
+		}
+		matches, _, err := session.Intersect(ctx, b, ciphertexts)
+		if err != nil {
+			return nil, scheme, fmt.Errorf("batch %d intersection failed: %w", b, err)
+		}
+		for _, m := range matches {
+			if !seen[m] {
+				seen[m] = true
+				hashes = append(hashes, m)
+			}
+		}
+	}
+	return hashes, scheme, nil
+}
+
+// customerListHeaders reads the header row of a customer list CSV
+func (h *Handler) customerListHeaders(filePath string) ([]string, error) {
+	file, err := h.files.OpenFile(filePath)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return csv.NewReader(file).Read()
+}
+
+// diagnoseMapping looks for mapping problems: columns missing from the list,
+// records dropped of the recordCount it has (loaded were kept), and hashed
+// values in the sample that are empty or in a format the sanction lists do
+// not use
+func diagnoseMapping(headers []string, mapping map[string]string, recordCount, loaded int, sample []*models.Customer, enabledColumns []string) []models.PreflightWarning {
+	warnings := make([]models.PreflightWarning, 0)
+
+	known := make(map[string]bool, len(headers))
+	for _, header := range headers {
+		known[strings.ToLower(strings.TrimSpace(header))] = true
+	}
+	fields := make([]string, 0, len(mapping))
+	for field := range mapping {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+	for _, field := range fields {
+		if col := mapping[field]; col != "" && !known[strings.ToLower(strings.TrimSpace(col))] {
+			warnings = append(warnings, models.PreflightWarning{
+				Code:    warnUnknownColumn,
+				Field:   field,
+				Message: fmt.Sprintf("%s is mapped to %q, which is not a column of the list", field, col),
+			})
+		}
+	}
+
+	// Records are only dropped once the mapping is applied, so the shortfall
+	// against the list's count is what the mapping loses
+	if loaded < recordCount {
+		warnings = append(warnings, models.PreflightWarning{
+			Code:     warnSkippedRecords,
+			Message:  fmt.Sprintf("%d of %d records have an unknown entity type or lack the identifier their type is hashed with, and would not be screened", recordCount-loaded, recordCount),
+			Affected: recordCount - loaded,
+		})
+	}
+
+	// Values of each hashed field across the sample, by field
+	values := make(map[string][]string)
+	empty := make(map[string]int)
+	for _, c := range sample {
+		hashValues := c.HashValues()
+		for _, col := range psiadapter.EntityColumns(c.EntityType, enabledColumns) {
+			v := strings.TrimSpace(hashValues[col])
+			if v == "" {
+				empty[col]++
+				continue
+			}
+			values[col] = append(values[col], v)
+		}
+	}
+
+	for _, col := range []string{"name", "dob", "country", "registration", "imo"} {
+		total := len(values[col]) + empty[col]
+		if total > 0 && empty[col]*2 > total {
+			warnings = append(warnings, models.PreflightWarning{
+				Code:     warnEmptyValues,
+				Field:    col,
+				Message:  fmt.Sprintf("%s is empty in %d of %d sampled records that hash it; check its mapping", col, empty[col], total),
+				Affected: empty[col],
+			})
+		}
+	}
+
+	check := func(code, field string, bad func(string) bool, message string) {
+		var affected int
+		var examples []string
+		for _, v := range values[field] {
+			if bad(v) {
+				affected++
+				if len(examples) < preflightExamples {
+					examples = append(examples, v)
+				}
+			}
+		}
+		if affected > 0 {
+			warnings = append(warnings, models.PreflightWarning{
+				Code:     code,
+				Field:    field,
+				Message:  fmt.Sprintf("%d of %d sampled values: %s", affected, len(values[field]), message),
+				Affected: affected,
+				Examples: examples,
+			})
+		}
+	}
+	check(warnDOBFormat, "dob", func(v string) bool {
+		_, err := time.Parse(sanctionDOBLayout, v)
+		return err != nil
+	}, "dates of birth could not be read as dates and are hashed as written, so these customers cannot match")
+	check(warnCountryFormat, "country", func(v string) bool {
+		return len(v) != 2
+	}, "countries are not two-letter ISO codes, which sanction lists use")
+	check(warnNameFormat, "name", func(v string) bool {
+		return strings.IndexFunc(v, unicode.IsLetter) < 0
+	}, "names contain no letters; the name may be mapped to an identifier column")
+
+	return warnings
+}
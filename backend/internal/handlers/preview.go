@@ -0,0 +1,206 @@
+package handlers
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/SanthoshCheemala/FLARE/backend/internal/apierror"
+	"github.com/go-chi/chi/v5"
+)
+
+// Rows returned by PreviewCustomerList
+const (
+	defaultPreviewRows = 20
+	maxPreviewRows     = 200
+)
+
+// Column types inferred by PreviewCustomerList, narrowest first
+const (
+	columnTypeEmpty   = "empty" // No value in any sampled row
+	columnTypeInteger = "integer"
+	columnTypeNumber  = "number"
+	columnTypeBoolean = "boolean"
+	columnTypeDate    = "date"
+	columnTypeString  = "string"
+)
+
+// previewDateLayouts are the date formats recognized in customer lists
+var previewDateLayouts = []string{
+	"2006-01-02", "2006/01/02", "02/01/2006", "01/02/2006", "02-01-2006", "02.01.2006",
+	"2 Jan 2006", "January 2, 2006", time.RFC3339,
+}
+
+// previewColumn describes one column of a customer list preview
+type previewColumn struct {
+	Name  string `json:"name"`
+	Type  string `json:"type"`
+	Empty int    `json:"empty"` // Sampled rows without a value
+}
+
+// PreviewCustomerList returns the first rows of a customer list CSV with
+// their values typed, and the type inferred for each column, so mappings
+// can be configured against real data. rows (default 20, at most 200) sets
+// how many are read. suggestedMapping is a columnMapping guessed from the
+// header names and sampled values.
+func (h *Handler) PreviewCustomerList(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		apierror.Write(w, r, http.StatusBadRequest, apierror.CodeBadRequest, "Invalid list ID")
+		return
+	}
+	limit := defaultPreviewRows
+	if v := r.URL.Query().Get("rows"); v != "" {
+		if limit, err = strconv.Atoi(v); err != nil || limit < 1 {
+			apierror.Write(w, r, http.StatusBadRequest, apierror.CodeBadRequest, "rows must be a positive integer")
+			return
+		}
+		limit = min(limit, maxPreviewRows)
+	}
+
+	lists, err := h.repo.GetCustomerLists(r.Context())
+	if err != nil {
+		apierror.Write(w, r, http.StatusInternalServerError, apierror.CodeDatabaseError, "Database error")
+		return
+	}
+	var filePath string
+	var recordCount int
+	for _, l := range lists {
+		if l.ID == id {
+			filePath, recordCount = l.FilePath, l.RecordCount
+			break
+		}
+	}
+	if filePath == "" {
+		apierror.Write(w, r, http.StatusNotFound, apierror.CodeListNotFound, "List not found")
+		return
+	}
+
+	file, err := h.files.OpenFile(filePath)
+	if err != nil {
+		apierror.Write(w, r, http.StatusInternalServerError, apierror.CodeInternal, "Failed to open file")
+		return
+	}
+	defer file.Close()
+
+	reader := csv.NewReader(file)
+	reader.FieldsPerRecord = -1
+	headers, err := reader.Read()
+	if err != nil {
+		apierror.Write(w, r, http.StatusInternalServerError, apierror.CodeInternal, "Failed to read CSV headers")
+		return
+	}
+	var records [][]string
+	for len(records) < limit {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			apierror.Write(w, r, http.StatusInternalServerError, apierror.CodeInternal, "Failed to parse CSV: "+err.Error())
+			return
+		}
+		// Short rows are padded so every row lines up with the headers
+		for len(record) < len(headers) {
+			record = append(record, "")
+		}
+		records = append(records, record[:len(headers)])
+	}
+
+	columns := make([]previewColumn, len(headers))
+	for i, name := range headers {
+		columns[i] = previewColumn{Name: strings.TrimSpace(name), Type: columnTypeEmpty}
+		for _, record := range records {
+			value := strings.TrimSpace(record[i])
+			if value == "" {
+				columns[i].Empty++
+				continue
+			}
+			columns[i].Type = widenColumnType(columns[i].Type, valueType(value))
+		}
+	}
+
+	rows := make([][]interface{}, len(records))
+	for n, record := range records {
+		rows[n] = make([]interface{}, len(headers))
+		for i, value := range record {
+			rows[n][i] = typedValue(strings.TrimSpace(value), columns[i].Type)
+		}
+	}
+
+	suggestions := suggestColumnMapping(columns, records)
+	mapping := make(map[string]string, len(suggestions))
+	for _, s := range suggestions {
+		mapping[s.Field] = s.Column
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"listId":             id,
+		"headers":            headers,
+		"columns":            columns,
+		"rows":               rows,
+		"recordCount":        recordCount,
+		"suggestedMapping":   mapping,
+		"mappingSuggestions": suggestions,
+	})
+}
+
+// valueType returns the narrowest type a non-empty value parses as.
+// Integers with a leading zero are identifiers and stay strings.
+func valueType(value string) string {
+	if _, err := strconv.ParseInt(value, 10, 64); err == nil {
+		if len(value) > 1 && (value[0] == '0' || strings.HasPrefix(value, "-0")) {
+			return columnTypeString
+		}
+		return columnTypeInteger
+	}
+	if _, err := strconv.ParseFloat(value, 64); err == nil {
+		return columnTypeNumber
+	}
+	if strings.EqualFold(value, "true") || strings.EqualFold(value, "false") {
+		return columnTypeBoolean
+	}
+	for _, layout := range previewDateLayouts {
+		if _, err := time.Parse(layout, value); err == nil {
+			return columnTypeDate
+		}
+	}
+	return columnTypeString
+}
+
+// widenColumnType returns the type of a column of type current that also
+// holds a value of type next
+func widenColumnType(current, next string) string {
+	switch {
+	case current == columnTypeEmpty || current == next:
+		return next
+	case current == columnTypeInteger && next == columnTypeNumber,
+		current == columnTypeNumber && next == columnTypeInteger:
+		return columnTypeNumber
+	}
+	return columnTypeString
+}
+
+// typedValue converts value to its column's type. Empty values are null;
+// dates and strings are returned as is.
+func typedValue(value, columnType string) interface{} {
+	if value == "" {
+		return nil
+	}
+	switch columnType {
+	case columnTypeInteger:
+		n, _ := strconv.ParseInt(value, 10, 64)
+		return n
+	case columnTypeNumber:
+		f, _ := strconv.ParseFloat(value, 64)
+		return f
+	case columnTypeBoolean:
+		return strings.EqualFold(value, "true")
+	}
+	return value
+}
@@ -0,0 +1,50 @@
+package handlers
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+
+	"github.com/SanthoshCheemala/FLARE/backend/internal/apierror"
+	"github.com/go-chi/chi/v5"
+)
+
+// CaptureProfile arms CPU and heap profiling of the next screening
+func (h *Handler) CaptureProfile(w http.ResponseWriter, r *http.Request) {
+	h.profiles.Arm()
+	log.Printf("Profile capture armed for the next screening")
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"armed": true,
+	})
+}
+
+// ListProfiles returns the captured profiles and the capture state
+func (h *Handler) ListProfiles(w http.ResponseWriter, r *http.Request) {
+	captures, err := h.profiles.List()
+	if err != nil {
+		apierror.Write(w, r, http.StatusInternalServerError, apierror.CodeInternal, "Failed to list profiles")
+		return
+	}
+	armed, running := h.profiles.Status()
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"armed":     armed,
+		"capturing": running,
+		"profiles":  captures,
+	})
+}
+
+// DownloadProfile serves one captured profile file for `go tool pprof`
+func (h *Handler) DownloadProfile(w http.ResponseWriter, r *http.Request) {
+	path, err := h.profiles.Path(chi.URLParam(r, "jobId"), chi.URLParam(r, "name"))
+	if err != nil {
+		apierror.Write(w, r, http.StatusNotFound, apierror.CodeNotFound, "Profile not found")
+		return
+	}
+	w.Header().Set("Content-Type", "application/octet-stream")
+	http.ServeFile(w, r, path)
+}
@@ -0,0 +1,26 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/SanthoshCheemala/FLARE/backend/internal/apierror"
+)
+
+// StartRehash rebuilds stale stored customer and sanction hashes in the
+// background
+func (h *Handler) StartRehash(w http.ResponseWriter, r *http.Request) {
+	if !h.rehash.Start() {
+		apierror.Write(w, r, http.StatusConflict, apierror.CodeConflict, "A rehash is already running")
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(map[string]bool{"started": true})
+}
+
+// RehashStatus reports progress of the current or last rehash
+func (h *Handler) RehashStatus(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(h.rehash.Status())
+}
@@ -0,0 +1,273 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+
+	"github.com/SanthoshCheemala/FLARE/backend/internal/apierror"
+	"github.com/SanthoshCheemala/FLARE/backend/internal/jobs"
+	"github.com/SanthoshCheemala/FLARE/backend/internal/models"
+	"github.com/SanthoshCheemala/FLARE/backend/internal/protocol"
+	"github.com/SanthoshCheemala/FLARE/backend/internal/psiadapter"
+	"github.com/SanthoshCheemala/FLARE/backend/internal/translit"
+	"github.com/go-chi/chi/v5"
+)
+
+// matchSet is what resolving a screening's matches needs from its PSI phase
+type matchSet struct {
+	jobID          string
+	screeningID    int64
+	customerListID int64
+	sessionID      string
+	hashes         []uint64
+	customers      []*models.Customer
+	serialized     []string // Hash input of each customer
+	columnMapping  map[string]string
+	enabledColumns []string
+	names          translit.Profile
+	scheme         psiadapter.HashScheme
+	listSource     string // Of the sanctions; empty for the PSI server's
+}
+
+// saveMatchSet persists the match hashes and the session settings needed to
+// re-run resolution. The customers are reloaded from the list instead.
+func (h *Handler) saveMatchSet(ctx context.Context, m *matchSet) error {
+	return h.repo.SaveScreeningMatches(ctx, &models.ScreeningMatches{
+		ScreeningID:     m.screeningID,
+		SessionID:       m.sessionID,
+		Hashes:          m.hashes,
+		HashAlgorithm:   m.scheme.Algorithm,
+		HashVersion:     m.scheme.Version,
+		HashSalt:        m.scheme.Salt,
+		ColumnMapping:   m.columnMapping,
+		EnabledColumns:  m.enabledColumns,
+		Transliteration: m.names.Scripts,
+	})
+}
+
+// persistMatches records a result for each customer and sanction pair behind
+// the match hashes whose full records agree, replacing any earlier results
+// of the screening. The results are saved atomically. It returns the result
+// IDs and the number of hash collisions.
+func (h *Handler) persistMatches(ctx context.Context, m *matchSet, sanctionRecords []*models.Sanction) ([]int64, int, error) {
+	records, collisions := h.matchRecords(ctx, m, sanctionRecords)
+	resultIDs, err := h.saveMatchRecords(ctx, m.jobID, m.screeningID, records)
+	return resultIDs, collisions, err
+}
+
+// matchRecords pairs the customers and sanctions behind each match hash
+// whose full records agree. It returns the pairs and the number of hash
+// collisions.
+func (h *Handler) matchRecords(ctx context.Context, m *matchSet, sanctionRecords []*models.Sanction) ([]models.MatchRecord, int) {
+	var records []models.MatchRecord
+
+	// Create a map of hash -> customer records
+	customerMap, collisions := customerBuckets(m.scheme.Hash(m.serialized), m.serialized)
+	if collisions > 0 {
+		log.Printf("Warning: %d hash collisions between distinct customer records", collisions)
+	}
+	log.Printf("PSI returned %d match hashes", len(m.hashes))
+	log.Printf("Customer map has %d entries", len(customerMap))
+
+	// Create sanction hash map. One hash can resolve to several sanctions:
+	// the same party on two lists, or distinct records that collide.
+	sanctionMap := sanctionBuckets(sanctionRecords)
+	log.Printf("Resolved %d sanctions from server", len(sanctionRecords))
+
+	// Known false positives are recorded as SUPPRESSED
+	suppressions, err := h.repo.ActiveSuppressions(ctx, m.customerListID)
+	if err != nil {
+		log.Printf("Warning: failed to load suppressions, no matches will be suppressed: %v", err)
+	}
+
+	for _, matchHash := range m.hashes {
+		customers := customerMap[int64(matchHash)]
+		sanctions := sanctionMap[int64(matchHash)]
+		cOk, sOk := len(customers) > 0, len(sanctions) > 0
+
+		log.Printf("Processing match hash %d: customer found=%v, sanction found=%v", matchHash, cOk, sOk)
+
+		if !cOk || !sOk {
+			log.Printf("Warning: Match hash %d found but missing customer=%v or sanction=%v", matchHash, !cOk, !sOk)
+			continue
+		}
+
+		for _, ci := range customers {
+			customer := m.customers[ci]
+			for _, sanction := range sanctions {
+				// The hash is truncated to 64 bits, so confirm the full
+				// records agree before recording a match
+				if !recordsMatch(m.serialized[ci], sanction, m.enabledColumns, m.names) {
+					collisions++
+					log.Printf("Warning: hash collision on %d: customer %s does not match sanction %s", matchHash, customer.ExternalID, sanction.Name)
+					continue
+				}
+
+				log.Printf("Match found: Customer=%s (%s, %s) <-> Sanction=%s (%s, %s, %s)",
+					customer.Name, customer.DOB, customer.Country,
+					sanction.Name, sanction.DOB, sanction.Country, sanction.Program)
+
+				result := &models.ScreeningResult{
+					MatchScore: 1.0,
+					Status:     "PENDING",
+					ListSource: m.listSource,
+					Channel:    matchChannel(m.serialized[ci]),
+				}
+				if result.Channel == models.MatchChannelDocument {
+					result.Explanation = explainDocumentMatch(m.serialized[ci], m.scheme)
+				} else {
+					result.Explanation = explainMatch(customer, sanction, m.serialized[ci], m.enabledColumns, m.names, m.scheme)
+				}
+				// Suppressions made from a record reduced by retention
+				// name it by its record hash
+				id, ok := suppressions[matchFingerprint(customer, sanction)]
+				if !ok && len(suppressions) > 0 {
+					recordHash := psiadapter.RecordHash(sanction.EntityType, sanction.HashValues())
+					id, ok = suppressions[referenceFingerprint(customer, sanction.Source, sanction.EntityType, recordHash)]
+				}
+				if ok {
+					result.Status = "SUPPRESSED"
+					result.SuppressionID = &id
+				}
+
+				records = append(records, models.MatchRecord{Customer: customer, Sanction: sanction, Result: result})
+			}
+		}
+	}
+
+	return records, collisions
+}
+
+// saveMatchRecords replaces the results of a screening with records,
+// consolidating matches of one customer to the same entity on several
+// lists, and returns their IDs
+func (h *Handler) saveMatchRecords(ctx context.Context, jobID string, screeningID int64, records []models.MatchRecord) ([]int64, error) {
+	deduped := dedupeRecords(records)
+	if n := len(records) - len(deduped); n > 0 {
+		log.Printf("Consolidated %d matches of entities on several lists", n)
+	}
+	records = deduped
+	// Customers and sanctions are saved with the result for linking, under
+	// their record hash rather than the session's match hash
+	for _, rec := range records {
+		rec.Customer.Hash = psiadapter.RecordHash(rec.Customer.EntityType, rec.Customer.HashValues())
+		rec.Customer.HashVersion = psiadapter.RecordHashVersion
+		rec.Sanction.Hash = psiadapter.RecordHash(rec.Sanction.EntityType, rec.Sanction.HashValues())
+		rec.Sanction.HashVersion = psiadapter.RecordHashVersion
+	}
+	// The hashes identify reduced records at the authority
+	h.retainSanctions(records)
+	if err := h.repo.SaveScreeningResults(ctx, screeningID, records); err != nil {
+		return nil, err
+	}
+
+	resultIDs := make([]int64, 0, len(records))
+	suppressionHits := make(map[int64]int)
+	for _, rec := range records {
+		resultIDs = append(resultIDs, rec.Result.ID)
+		if rec.Result.SuppressionID != nil {
+			suppressionHits[*rec.Result.SuppressionID]++
+		}
+	}
+	log.Printf("Total matches saved: %d", len(resultIDs))
+	h.recordSuppressionHits(ctx, jobID, screeningID, suppressionHits)
+	return resultIDs, nil
+}
+
+// ReResolveScreening re-runs resolution of a screening whose resolve step
+// failed, from the match hashes persisted after the intersection. It needs
+// the PSI server session, which is left open when resolution fails, to still
+// be alive; the PSI protocol is not re-run.
+func (h *Handler) ReResolveScreening(w http.ResponseWriter, r *http.Request) {
+	jobID := chi.URLParam(r, "jobId")
+	screening, err := h.repo.GetScreeningByJobID(r.Context(), jobID)
+	if err != nil {
+		apierror.Write(w, r, http.StatusInternalServerError, apierror.CodeDatabaseError, "Failed to load screening")
+		return
+	}
+	if screening == nil {
+		apierror.Write(w, r, http.StatusNotFound, apierror.CodeNotFound, "Screening not found")
+		return
+	}
+	if screening.ListSource != models.ListSourceRemote {
+		apierror.Write(w, r, http.StatusConflict, apierror.CodeConflict, "Screenings of local watchlists are resolved in-process; run the screening again")
+		return
+	}
+	stored, err := h.repo.GetScreeningMatches(r.Context(), screening.ID)
+	if err != nil {
+		apierror.Write(w, r, http.StatusInternalServerError, apierror.CodeDatabaseError, "Failed to load match hashes")
+		return
+	}
+	if stored == nil {
+		apierror.Write(w, r, http.StatusConflict, apierror.CodeConflict, "Screening has no recorded match hashes; it did not reach resolution")
+		return
+	}
+	if stored.Resolved {
+		apierror.Write(w, r, http.StatusConflict, apierror.CodeConflict, "Screening matches are already resolved")
+		return
+	}
+
+	scheme, err := psiadapter.HashScheme{Algorithm: stored.HashAlgorithm, Version: stored.HashVersion, Salt: stored.HashSalt}.Check()
+	if err != nil {
+		apierror.Write(w, r, http.StatusInternalServerError, apierror.CodeInternal, fmt.Sprintf("Stored hash scheme is invalid: %v", err))
+		return
+	}
+	names, _ := translit.New(stored.Transliteration)
+	customers, serialized, err := h.loadCustomerDataFromCSV(r.Context(), screening.CustomerListID, stored.ColumnMapping, stored.EnabledColumns, names)
+	if err != nil {
+		apierror.Write(w, r, http.StatusInternalServerError, apierror.CodeInternal, fmt.Sprintf("Failed to reload customer list: %v", err))
+		return
+	}
+	customers, serialized = addDocumentInputs(customers, serialized)
+
+	session := protocol.Attach(h.sessions, stored.SessionID)
+	sanctionRecords, err := session.Resolve(r.Context(), stored.Hashes)
+	if err != nil {
+		log.Printf("Re-resolve of job %s failed: %v", jobID, err)
+		writeUpstreamError(w, r, err, "Failed to resolve sanctions")
+		return
+	}
+
+	resultIDs, collisions, err := h.persistMatches(r.Context(), &matchSet{
+		jobID:          jobID,
+		screeningID:    screening.ID,
+		customerListID: screening.CustomerListID,
+		sessionID:      stored.SessionID,
+		hashes:         stored.Hashes,
+		customers:      customers,
+		serialized:     serialized,
+		columnMapping:  stored.ColumnMapping,
+		enabledColumns: stored.EnabledColumns,
+		names:          names,
+		scheme:         scheme,
+	}, sanctionRecords)
+	if err != nil {
+		log.Printf("Re-resolve of job %s failed to save results: %v", jobID, err)
+		apierror.Write(w, r, http.StatusInternalServerError, apierror.CodeDatabaseError, "Failed to save results")
+		return
+	}
+
+	screening.Status = string(jobs.StatusCompleted)
+	screening.MatchCount = len(resultIDs)
+	screening.Error = ""
+	if err := h.repo.UpdateScreeningFinal(r.Context(), screening); err != nil {
+		log.Printf("Warning: failed to update screening %s after re-resolve: %v", jobID, err)
+	}
+	if job := h.jobManager.Get(jobID); job != nil {
+		job.SetReResolved(resultIDs)
+	}
+	if err := session.Close(r.Context()); err != nil {
+		log.Printf("Warning: failed to close session %s: %v", stored.SessionID, err)
+	}
+	log.Printf("Re-resolved job %s: %d matches, %d hash collisions", jobID, len(resultIDs), collisions)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"jobId":          jobID,
+		"matches":        len(resultIDs),
+		"hashCollisions": collisions,
+	})
+}
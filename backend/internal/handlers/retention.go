@@ -0,0 +1,80 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/SanthoshCheemala/FLARE/backend/internal/apierror"
+	"github.com/SanthoshCheemala/FLARE/backend/internal/models"
+)
+
+// retentionSweepInterval is how often kept sanction records past their
+// retention are reduced
+const retentionSweepInterval = time.Hour
+
+// retainSanctions reduces the sanction records the PSI server resolved to
+// what the retention policy keeps, before they are saved with their results.
+// Records of local watchlists are the client's own and are kept whole.
+func (h *Handler) retainSanctions(records []models.MatchRecord) {
+	policy := h.psiConfig.SanctionRetention
+	if policy == models.RetentionFull || policy == "" {
+		return
+	}
+	for _, rec := range records {
+		if rec.Result.ListSource == models.ListSourceLocal {
+			continue
+		}
+		rec.Sanction.Retain(policy)
+		if rec.Result.Explanation != nil {
+			rec.Result.Explanation.Retain(policy)
+		}
+	}
+}
+
+// sweepSanctionCopies reduces kept sanction records to references once their
+// results are older than days
+func (h *Handler) sweepSanctionCopies(ctx context.Context, days int) {
+	ticker := time.NewTicker(retentionSweepInterval)
+	defer ticker.Stop()
+	for {
+		n, err := h.repo.ReduceSanctionCopies(ctx, time.Now().AddDate(0, 0, -days))
+		if err != nil {
+			log.Printf("Warning: failed to reduce kept sanction records: %v", err)
+		} else if n > 0 {
+			log.Printf("Reduced %d sanction records older than %d days to references", n, days)
+		}
+
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// PurgeSanctionCopies reduces the sanction records kept for results older
+// than olderThanDays, by default PSI_SANCTION_RETENTION_DAYS, to references.
+// olderThanDays=0 reduces all of them.
+func (h *Handler) PurgeSanctionCopies(w http.ResponseWriter, r *http.Request) {
+	days := h.psiConfig.SanctionRetentionDays
+	if v := r.URL.Query().Get("olderThanDays"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			apierror.Write(w, r, http.StatusBadRequest, apierror.CodeBadRequest, "olderThanDays must be a non-negative number")
+			return
+		}
+		days = n
+	}
+	n, err := h.repo.ReduceSanctionCopies(r.Context(), time.Now().AddDate(0, 0, -days))
+	if err != nil {
+		apierror.Write(w, r, http.StatusInternalServerError, apierror.CodeDatabaseError, "Failed to reduce kept sanction records")
+		return
+	}
+	log.Printf("Reduced %d sanction records older than %d days to references on request", n, days)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]int{"reduced": n})
+}
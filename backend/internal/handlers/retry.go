@@ -0,0 +1,250 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"time"
+
+	"github.com/SanthoshCheemala/FLARE/backend/internal/apierror"
+	"github.com/SanthoshCheemala/FLARE/backend/internal/atrest"
+	"github.com/SanthoshCheemala/FLARE/backend/internal/jobs"
+	"github.com/SanthoshCheemala/FLARE/backend/internal/models"
+	"github.com/go-chi/chi/v5"
+)
+
+// retryDir holds a checkpoint per remote screening, kept after a failure so
+// the screening can be retried from where it stopped
+const retryDir = "./data/retries"
+
+// retrySweepInterval is how often expired checkpoints are removed
+const retrySweepInterval = time.Hour
+
+// retryState is how far a remote screening got, and the settings it was
+// started with
+type retryState struct {
+	// Keys of each batch's ciphertexts in the ciphertext cache
+	Keys            []string          `json:"keys"`
+	Matches         map[int][]uint64  `json:"matches,omitempty"` // Of each batch already intersected
+	ColumnMapping   map[string]string `json:"columnMapping,omitempty"`
+	Categories      []string          `json:"categories,omitempty"`
+	PackIDs         []string          `json:"packIds,omitempty"`
+	Transliteration []string          `json:"transliteration,omitempty"`
+}
+
+// retryCheckpoint persists the progress of one remote screening. A nil
+// checkpoint records nothing.
+type retryCheckpoint struct {
+	files *atrest.Cipher
+	dir   string
+	state retryState
+}
+
+// retryCheckpoint returns the checkpoint of job, started with its settings
+func (h *Handler) retryCheckpoint(job *jobs.ScreeningJob, columnMapping map[string]string) *retryCheckpoint {
+	return &retryCheckpoint{
+		files: h.files,
+		dir:   filepath.Join(retryDir, job.ID),
+		state: retryState{
+			ColumnMapping:   columnMapping,
+			Categories:      job.Categories,
+			PackIDs:         job.PackIDs,
+			Transliteration: job.Transliteration,
+		},
+	}
+}
+
+// resume adopts the matches a failed run recorded if its batches had the
+// same ciphertext keys, that is, the same params and customer data
+func (c *retryCheckpoint) resume(keys []string) {
+	if c == nil {
+		return
+	}
+	state, err := readRetryState(c.files, c.dir)
+	if err != nil || !slices.Equal(state.Keys, keys) {
+		return
+	}
+	c.state.Matches = state.Matches
+	if len(state.Matches) > 0 {
+		log.Printf("Resuming from %d intersected batch(es) of %d", len(state.Matches), len(keys))
+	}
+}
+
+// save records the ciphertext keys of each batch, which makes the screening
+// retryable
+func (c *retryCheckpoint) save(keys []string) error {
+	if c == nil {
+		return nil
+	}
+	if err := os.MkdirAll(c.dir, 0700); err != nil {
+		return err
+	}
+	c.state.Keys = keys
+	return c.writeState()
+}
+
+// batchMatches returns the matches of batch b if it was intersected before
+func (c *retryCheckpoint) batchMatches(b int) ([]uint64, bool) {
+	if c == nil {
+		return nil, false
+	}
+	matches, ok := c.state.Matches[b]
+	return matches, ok
+}
+
+// recordBatch stores the matches of batch b, so a retry skips it
+func (c *retryCheckpoint) recordBatch(b int, matches []uint64) {
+	if c == nil || c.state.Keys == nil {
+		return
+	}
+	if c.state.Matches == nil {
+		c.state.Matches = make(map[int][]uint64)
+	}
+	c.state.Matches[b] = matches
+	if err := c.writeState(); err != nil {
+		log.Printf("Warning: failed to record intersected batch %d in %s: %v", b, c.dir, err)
+	}
+}
+
+// remove deletes the checkpoint once its screening no longer needs it
+func (c *retryCheckpoint) remove() {
+	if c == nil {
+		return
+	}
+	if err := os.RemoveAll(c.dir); err != nil {
+		log.Printf("Warning: failed to remove retry checkpoint %s: %v", c.dir, err)
+	}
+}
+
+func (c *retryCheckpoint) writeState() error {
+	data, err := json.Marshal(c.state)
+	if err != nil {
+		return err
+	}
+	return c.files.WriteFile(filepath.Join(c.dir, "state.json"), bytes.NewReader(data), 0600)
+}
+
+func readRetryState(files *atrest.Cipher, dir string) (*retryState, error) {
+	data, err := files.ReadFile(filepath.Join(dir, "state.json"))
+	if err != nil {
+		return nil, err
+	}
+	var state retryState
+	if err := json.Unmarshal(data, &state); err != nil {
+		return nil, err
+	}
+	return &state, nil
+}
+
+// RetryScreening re-runs a remote screening that failed after encrypting its
+// customers. When a new session with the PSI server has the same params, it
+// skips the batches the failed run intersected and takes the ciphertexts of
+// the rest from the ciphertext cache; otherwise the customers are encrypted
+// again. Screenings that failed to resolve their matches are re-resolved
+// instead.
+func (h *Handler) RetryScreening(w http.ResponseWriter, r *http.Request) {
+	jobID := chi.URLParam(r, "jobId")
+	screening, err := h.repo.GetScreeningByJobID(r.Context(), jobID)
+	if err != nil {
+		apierror.Write(w, r, http.StatusInternalServerError, apierror.CodeDatabaseError, "Failed to load screening")
+		return
+	}
+	if screening == nil {
+		apierror.Write(w, r, http.StatusNotFound, apierror.CodeNotFound, "Screening not found")
+		return
+	}
+	if screening.ListSource != models.ListSourceRemote {
+		apierror.Write(w, r, http.StatusConflict, apierror.CodeConflict, "Only screenings against the PSI server can be retried; run the screening again")
+		return
+	}
+
+	// One retry at a time; the job it starts is visible to later requests
+	retryKey := startingKey{key: "retry " + jobID}
+	if !h.starting.acquire(retryKey) {
+		apierror.Write(w, r, http.StatusConflict, apierror.CodeConflict, "Screening is already being retried")
+		return
+	}
+	defer h.starting.release(retryKey)
+
+	status := jobs.Status(screening.Status)
+	if job := h.jobManager.Get(jobID); job != nil {
+		status = job.GetSnapshot().Status
+	}
+	if status != jobs.StatusFailed {
+		apierror.Write(w, r, http.StatusConflict, apierror.CodeConflict, fmt.Sprintf("Screening is %s; only failed screenings can be retried", status))
+		return
+	}
+	stored, err := h.repo.GetScreeningMatches(r.Context(), screening.ID)
+	if err != nil {
+		apierror.Write(w, r, http.StatusInternalServerError, apierror.CodeDatabaseError, "Failed to load match hashes")
+		return
+	}
+	if stored != nil && !stored.Resolved {
+		apierror.Write(w, r, http.StatusConflict, apierror.CodeConflict, "Screening failed after its intersection; re-resolve it instead")
+		return
+	}
+	state, err := readRetryState(h.files, filepath.Join(retryDir, jobID))
+	if os.IsNotExist(err) {
+		apierror.Write(w, r, http.StatusConflict, apierror.CodeConflict, "Screening has no retry checkpoint; it failed before encryption finished or the checkpoint expired. Start a new screening")
+		return
+	}
+	if err != nil {
+		apierror.Write(w, r, http.StatusInternalServerError, apierror.CodeInternal, fmt.Sprintf("Failed to read retry checkpoint: %v", err))
+		return
+	}
+
+	// The failed job is replaced by a fresh one under the same ID
+	job := h.jobManager.Create(jobID, screening.Name, screening.CustomerListID, screening.SanctionListIDs, screening.CreatedBy)
+	job.Categories = state.Categories
+	job.PackIDs = state.PackIDs
+	job.Transliteration = state.Transliteration
+	job.ListSource = models.ListSourceRemote
+	limits := screeningLimits{workers: screening.WorkerCount, memoryGB: screening.MemoryLimitGB}
+	log.Printf("Retrying screening job %s: %d of %d batch(es) already intersected", jobID, len(state.Matches), len(state.Keys))
+	go h.runScreening(job, screening.ID, state.ColumnMapping, limits)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(models.StartScreeningResponse{JobID: jobID})
+}
+
+// sweepCheckpoints removes the checkpoints of screenings that failed more
+// than retention ago and were not retried
+func (h *Handler) sweepCheckpoints(ctx context.Context, retention time.Duration) {
+	ticker := time.NewTicker(retrySweepInterval)
+	defer ticker.Stop()
+	for {
+		entries, err := os.ReadDir(retryDir)
+		if err != nil && !os.IsNotExist(err) {
+			log.Printf("Warning: failed to list retry checkpoints: %v", err)
+		}
+		for _, entry := range entries {
+			if job := h.jobManager.Get(entry.Name()); job != nil && !job.GetSnapshot().Status.Finished() {
+				continue
+			}
+			dir := filepath.Join(retryDir, entry.Name())
+			info, err := os.Stat(filepath.Join(dir, "state.json"))
+			if err != nil {
+				info, err = entry.Info()
+			}
+			if err != nil || time.Since(info.ModTime()) < retention {
+				continue
+			}
+			if err := os.RemoveAll(dir); err != nil {
+				log.Printf("Warning: failed to remove retry checkpoint %s: %v", dir, err)
+			}
+		}
+
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
@@ -0,0 +1,81 @@
+package handlers
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/SanthoshCheemala/FLARE/backend/internal/apierror"
+	"github.com/SanthoshCheemala/FLARE/backend/internal/models"
+	"github.com/go-chi/chi/v5"
+)
+
+// ListCustomerRisk returns customers with matches ordered by risk score, for
+// prioritizing review. Optional filters: listId and tier (HIGH, MEDIUM, LOW).
+func (h *Handler) ListCustomerRisk(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	limit, offset := 50, 0
+	if l, err := strconv.Atoi(q.Get("limit")); err == nil && l > 0 {
+		limit = min(l, 1000)
+	}
+	if o, err := strconv.Atoi(q.Get("offset")); err == nil && o > 0 {
+		offset = o
+	}
+
+	var listID int64
+	if v := q.Get("listId"); v != "" {
+		id, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			apierror.Write(w, r, http.StatusBadRequest, apierror.CodeBadRequest, "Invalid listId")
+			return
+		}
+		listID = id
+	}
+	tier := strings.ToUpper(q.Get("tier"))
+	switch tier {
+	case "", models.RiskTierHigh, models.RiskTierMedium, models.RiskTierLow:
+	default:
+		apierror.Write(w, r, http.StatusBadRequest, apierror.CodeBadRequest, "tier must be HIGH, MEDIUM or LOW")
+		return
+	}
+
+	customers, total, err := h.repo.ListCustomerRisk(r.Context(), listID, tier, limit, offset)
+	if err != nil {
+		log.Printf("Error computing customer risk: %v", err)
+		apierror.Write(w, r, http.StatusInternalServerError, apierror.CodeDatabaseError, "Database error")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"customers": customers,
+		"total":     total,
+		"limit":     limit,
+		"offset":    offset,
+	})
+}
+
+// GetCustomerRisk returns one customer's match history and risk tier
+func (h *Handler) GetCustomerRisk(w http.ResponseWriter, r *http.Request) {
+	customerID, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		apierror.Write(w, r, http.StatusBadRequest, apierror.CodeBadRequest, "Invalid customer ID")
+		return
+	}
+
+	risk, err := h.repo.GetCustomerRisk(r.Context(), customerID)
+	if err != nil {
+		log.Printf("Error computing risk of customer %d: %v", customerID, err)
+		apierror.Write(w, r, http.StatusInternalServerError, apierror.CodeDatabaseError, "Database error")
+		return
+	}
+	if risk == nil {
+		apierror.Write(w, r, http.StatusNotFound, apierror.CodeNotFound, "Customer not found")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(risk)
+}
@@ -0,0 +1,107 @@
+package handlers
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/SanthoshCheemala/FLARE/backend/internal/middleware"
+	"github.com/SanthoshCheemala/FLARE/backend/internal/profiling"
+	"github.com/go-chi/chi/v5"
+	chimiddleware "github.com/go-chi/chi/v5/middleware"
+)
+
+// NewRouter builds the client backend's HTTP routes
+func NewRouter(h *Handler) *chi.Mux {
+	r := chi.NewRouter()
+
+	r.Use(chimiddleware.RequestID)
+	r.Use(chimiddleware.RealIP)
+	r.Use(middleware.Logger)
+	r.Use(chimiddleware.Recoverer)
+	r.Use(middleware.CORS([]string{"http://localhost:3000", "*"}))
+	r.Use(middleware.BodyLimit(h.bodyLimits))
+	if h.compression > 0 {
+		r.Use(middleware.Compress(h.compression))
+	}
+
+	// WebSocket endpoint (must be outside Timeout middleware)
+	r.Get("/ws/logs", h.StreamLogs)
+	// Server-sent domain events are long-lived too
+	r.Get("/events", h.StreamEvents)
+
+	// Profiling, guarded by ADMIN_TOKEN. CPU profiles and traces run for
+	// longer than the API timeout.
+	r.Group(func(r chi.Router) {
+		r.Use(middleware.AdminToken(h.adminToken))
+
+		r.Route("/debug/pprof", profiling.Register)
+		r.Post("/admin/profiles/capture", h.CaptureProfile)
+		r.Get("/admin/profiles", h.ListProfiles)
+		r.Get("/admin/profiles/{jobId}/{name}", h.DownloadProfile)
+		r.Post("/admin/rehash", h.StartRehash)
+		r.Get("/admin/rehash/status", h.RehashStatus)
+		r.Post("/admin/sanction-copies/purge", h.PurgeSanctionCopies)
+	})
+
+	// API endpoints with timeout
+	r.Group(func(r chi.Router) {
+		r.Use(chimiddleware.Timeout(60 * time.Second))
+		r.Use(middleware.OptionalAuth(h.auth))
+
+		r.Get("/health", func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusOK)
+			w.Write([]byte("OK"))
+		})
+
+		r.Post("/auth/login", h.Login)
+
+		r.Post("/lists/customers/upload", h.UploadCustomerList)
+		r.Post("/lists/sanctions/upload", h.UploadSanctionList)
+		r.Get("/lists/customers", h.GetCustomerLists)
+		r.Get("/lists/customers/{id}/headers", h.GetCustomerListHeaders)
+		r.Get("/lists/customers/{id}/preview", h.PreviewCustomerList)
+		r.Delete("/lists/customers/{id}", h.DeleteCustomerList)
+		r.Get("/lists/sanctions", h.GetSanctionLists)
+		r.Delete("/lists/sanctions/{id}", h.DeleteSanctionList)
+
+		r.Get("/packs", h.ListPacks)
+		r.Get("/packs/subscriptions", h.ListPackSubscriptions)
+		r.Post("/packs/{id}/subscribe", h.SubscribePack)
+		r.Delete("/packs/{id}/subscribe", h.UnsubscribePack)
+
+		r.Post("/screenings", h.StartScreening)
+		r.Post("/screenings/estimate", h.EstimateScreening)
+		r.Post("/screenings/preflight", h.PreflightScreening)
+		r.Get("/screenings", h.ListScreenings)
+		r.Get("/screenings/{jobId}/status", h.ScreeningStatus)
+		r.Get("/screenings/{jobId}/events", h.ScreeningEvents)
+		r.Get("/screenings/{jobId}/results", h.GetScreeningResults)
+		r.Post("/screenings/{jobId}/re-resolve", h.ReResolveScreening)
+		r.Post("/screenings/{jobId}/retry", h.RetryScreening)
+		r.Post("/screenings/{jobId}/cancel", h.CancelScreening)
+
+		r.Get("/suppressions", h.ListSuppressions)
+		r.Get("/suppressions/{id}", h.GetSuppression)
+		r.Delete("/suppressions/{id}", h.RevokeSuppression)
+
+		// Reviewing a result is attributed to a signed-in user, which
+		// four-eyes approval depends on
+		r.Group(func(r chi.Router) {
+			r.Use(middleware.Auth(h.auth))
+
+			r.Patch("/results/{resultId}/status", h.UpdateResultStatus)
+			r.Post("/results/{resultId}/approval", h.ApproveResult)
+			r.Get("/results/{resultId}/comments", h.ListResultComments)
+			r.Post("/results/{resultId}/comments", h.AddResultComment)
+			r.Get("/results/{resultId}/comments/{commentId}/attachments/{attachmentId}", h.DownloadCommentAttachment)
+		})
+
+		r.Get("/dashboard/stats", h.GetStats)
+		r.Get("/analytics/lists", h.GetListAnalytics)
+		r.Get("/customers/risk", h.ListCustomerRisk)
+		r.Get("/customers/{id}/risk", h.GetCustomerRisk)
+		r.Get("/performance/metrics", h.GetPerformanceMetrics)
+	})
+
+	return r
+}
@@ -0,0 +1,246 @@
+package handlers
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"hash"
+	"log"
+	"net/http"
+	"sort"
+	"strconv"
+
+	"github.com/SanthoshCheemala/FLARE/backend/internal/apierror"
+	"github.com/SanthoshCheemala/FLARE/backend/internal/middleware"
+	"github.com/SanthoshCheemala/FLARE/backend/internal/models"
+	"github.com/go-chi/chi/v5"
+)
+
+// Audit actions recorded for suppressions
+const (
+	auditSuppressionCreate = "SUPPRESSION_CREATE"
+	auditSuppressionRevoke = "SUPPRESSION_REVOKE"
+	auditSuppressionApply  = "SUPPRESSION_APPLY"
+)
+
+// matchFingerprint identifies a customer and sanction pair by the customer's
+// list and external ID and the contents of both records. Records are new
+// rows in every screening, so their IDs cannot be used. Sanction records
+// reduced by the retention policy are identified by their record hash.
+func matchFingerprint(customer *models.Customer, sanction *models.Sanction) string {
+	if sanction.Retention == models.RetentionRedacted || sanction.Retention == models.RetentionReference {
+		return referenceFingerprint(customer, sanction.Source, sanction.EntityType, sanction.Hash)
+	}
+	h := sha256.New()
+	fmt.Fprintf(h, "%d\x00%s\x00%s\x00", customer.ListID, customer.ExternalID, entityType(customer.EntityType))
+	writeValues(h, customer.HashValues())
+	fmt.Fprintf(h, "%s\x00%s\x00", sanction.Source, entityType(sanction.EntityType))
+	writeValues(h, sanction.HashValues())
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// referenceFingerprint identifies a pair whose sanction record was reduced by
+// the retention policy, by the record hash it kept instead of its contents
+func referenceFingerprint(customer *models.Customer, source, sanctionType string, recordHash int64) string {
+	h := sha256.New()
+	fmt.Fprintf(h, "%d\x00%s\x00%s\x00", customer.ListID, customer.ExternalID, entityType(customer.EntityType))
+	writeValues(h, customer.HashValues())
+	fmt.Fprintf(h, "ref\x00%s\x00%s\x00%d", source, entityType(sanctionType), recordHash)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// entityType returns t, reading empty as an individual as the database does
+func entityType(t string) string {
+	if t == "" {
+		return models.EntityIndividual
+	}
+	return t
+}
+
+// writeValues writes a record's values to h in key order
+func writeValues(h hash.Hash, values map[string]string) {
+	keys := make([]string, 0, len(values))
+	for k := range values {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		fmt.Fprintf(h, "%s=%s\x00", k, values[k])
+	}
+}
+
+// actor identifies who made a change, for the audit trail
+type actor struct {
+	id   int64 // User ID; 0 for anonymous requests
+	name string
+}
+
+// requestActor returns the signed-in user of a request
+func requestActor(ctx context.Context) actor {
+	if user := middleware.GetUser(ctx); user != nil {
+		return actor{id: user.UserID, name: user.Email}
+	}
+	return actor{name: "anonymous"}
+}
+
+// audit records an action on an entity. Failures are logged, not returned:
+// the action has already happened.
+func (h *Handler) audit(ctx context.Context, by actor, action, entityType string, entityID int64, details map[string]interface{}) {
+	h.auditEntity(ctx, by, action, entityType, strconv.FormatInt(entityID, 10), details)
+}
+
+// auditEntity is audit for entities identified by a string, such as jobs
+func (h *Handler) auditEntity(ctx context.Context, by actor, action, entityType, entityID string, details map[string]interface{}) {
+	if details == nil {
+		details = make(map[string]interface{})
+	}
+	details["by"] = by.name
+	entry := &models.AuditLog{
+		ActorID:    by.id,
+		Action:     action,
+		EntityType: entityType,
+		EntityID:   entityID,
+		Details:    details,
+	}
+	if err := h.repo.CreateAuditLog(ctx, entry); err != nil {
+		log.Printf("Warning: failed to audit %s of %s %s: %v", action, entityType, entityID, err)
+	}
+}
+
+// suppressResult creates a suppression from a result marked FALSE_POSITIVE
+func (h *Handler) suppressResult(ctx context.Context, resultID int64, by actor, days int, reason string) (*models.Suppression, error) {
+	detail, err := h.repo.GetScreeningResultDetail(ctx, resultID)
+	if err != nil {
+		return nil, err
+	}
+	if detail == nil {
+		return nil, fmt.Errorf("result %d not found", resultID)
+	}
+
+	s := &models.Suppression{
+		Fingerprint:        matchFingerprint(&detail.Customer, &detail.Sanction),
+		CustomerListID:     detail.Customer.ListID,
+		CustomerExternalID: detail.Customer.ExternalID,
+		SanctionSource:     detail.Sanction.Source,
+		SanctionName:       detail.Sanction.Name,
+		ResultID:           resultID,
+		Reason:             reason,
+		CreatedBy:          by.name,
+	}
+	if err := h.repo.CreateSuppression(ctx, s, days); err != nil {
+		return nil, err
+	}
+	h.audit(ctx, by, auditSuppressionCreate, "suppression", s.ID, map[string]interface{}{
+		"resultId":  resultID,
+		"reason":    reason,
+		"expiresAt": s.ExpiresAt,
+	})
+	return s, nil
+}
+
+// ListSuppressions returns active suppressions, or all with ?all=true
+func (h *Handler) ListSuppressions(w http.ResponseWriter, r *http.Request) {
+	all, _ := strconv.ParseBool(r.URL.Query().Get("all"))
+	suppressions, err := h.repo.ListSuppressions(r.Context(), all)
+	if err != nil {
+		log.Printf("Failed to list suppressions: %v", err)
+		apierror.Write(w, r, http.StatusInternalServerError, apierror.CodeDatabaseError, "Failed to list suppressions")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"suppressions": suppressions,
+		"count":        len(suppressions),
+	})
+}
+
+// GetSuppression returns a suppression with its audit trail
+func (h *Handler) GetSuppression(w http.ResponseWriter, r *http.Request) {
+	s, ok := h.loadSuppression(w, r)
+	if !ok {
+		return
+	}
+	trail, err := h.repo.ListAuditLogs(r.Context(), "suppression", strconv.FormatInt(s.ID, 10))
+	if err != nil {
+		log.Printf("Failed to load audit trail of suppression %d: %v", s.ID, err)
+		apierror.Write(w, r, http.StatusInternalServerError, apierror.CodeDatabaseError, "Failed to load audit trail")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"suppression": s,
+		"auditTrail":  trail,
+	})
+}
+
+// RevokeSuppression ends a suppression, so the match is reviewed again in
+// the next screening
+func (h *Handler) RevokeSuppression(w http.ResponseWriter, r *http.Request) {
+	s, ok := h.loadSuppression(w, r)
+	if !ok {
+		return
+	}
+	by := requestActor(r.Context())
+	revoked, err := h.repo.RevokeSuppression(r.Context(), s.ID, by.name)
+	if err != nil {
+		log.Printf("Failed to revoke suppression %d: %v", s.ID, err)
+		apierror.Write(w, r, http.StatusInternalServerError, apierror.CodeDatabaseError, "Failed to revoke suppression")
+		return
+	}
+	if !revoked {
+		apierror.Write(w, r, http.StatusConflict, apierror.CodeConflict, "Suppression is already revoked")
+		return
+	}
+	h.audit(r.Context(), by, auditSuppressionRevoke, "suppression", s.ID, nil)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"id":      s.ID,
+	})
+}
+
+// loadSuppression loads the suppression named by the id URL parameter,
+// writing the error response if there is none
+func (h *Handler) loadSuppression(w http.ResponseWriter, r *http.Request) (*models.Suppression, bool) {
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		apierror.Write(w, r, http.StatusBadRequest, apierror.CodeBadRequest, "Invalid suppression ID")
+		return nil, false
+	}
+	s, err := h.repo.GetSuppression(r.Context(), id)
+	if err != nil {
+		apierror.Write(w, r, http.StatusInternalServerError, apierror.CodeDatabaseError, "Failed to load suppression")
+		return nil, false
+	}
+	if s == nil {
+		apierror.Write(w, r, http.StatusNotFound, apierror.CodeNotFound, "Suppression not found")
+		return nil, false
+	}
+	return s, true
+}
+
+// recordSuppressionHits counts the matches each suppression suppressed in a
+// screening and adds them to its audit trail
+func (h *Handler) recordSuppressionHits(ctx context.Context, jobID string, screeningID int64, hits map[int64]int) {
+	if len(hits) == 0 {
+		return
+	}
+	if err := h.repo.RecordSuppressionHits(ctx, hits); err != nil {
+		log.Printf("Warning: failed to record suppression hits for job %s: %v", jobID, err)
+	}
+	total := 0
+	for id, n := range hits {
+		total += n
+		h.audit(ctx, actor{name: "screening"}, auditSuppressionApply, "suppression", id, map[string]interface{}{
+			"jobId":       jobID,
+			"screeningId": screeningID,
+			"matches":     n,
+		})
+	}
+	log.Printf("Suppressed %d known false positives in job %s", total, jobID)
+}
@@ -0,0 +1,363 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"time"
+
+	"github.com/SanthoshCheemala/FLARE/backend/internal/apierror"
+	"github.com/SanthoshCheemala/FLARE/backend/internal/bootstrap"
+	"github.com/SanthoshCheemala/FLARE/backend/internal/events"
+	"github.com/SanthoshCheemala/FLARE/backend/internal/jobs"
+	"github.com/SanthoshCheemala/FLARE/backend/internal/models"
+	"github.com/SanthoshCheemala/FLARE/backend/internal/profiling"
+	"github.com/SanthoshCheemala/FLARE/backend/internal/psiadapter"
+	"github.com/SanthoshCheemala/FLARE/backend/internal/textenc"
+	"github.com/SanthoshCheemala/FLARE/backend/internal/translit"
+	"github.com/SanthoshCheemala/FLARE/backend/internal/uploadscan"
+	"github.com/SanthoshCheemala/FLARE/backend/internal/xlsx"
+)
+
+// Local watchlists are sanction lists hosted by the client backend, such as
+// internal blacklists. Like customer lists they are kept as uploaded files
+// and read when screened; screenings with listSource LOCAL run both sides of
+// the PSI protocol in-process, so the lists never leave the bank.
+
+// errUnknownWatchlist is returned for a local watchlist ID that does not exist
+var errUnknownWatchlist = errors.New("unknown watchlist")
+
+// UploadSanctionList uploads a local watchlist CSV, in the format of the PSI
+// server's sanction lists. Category defaults to INTERNAL.
+func (h *Handler) UploadSanctionList(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseMultipartForm(10 << 20); err != nil { // 10 MB max
+		if apierror.TooLarge(w, r, err) {
+			return
+		}
+		apierror.Write(w, r, http.StatusBadRequest, apierror.CodeBadRequest, "File too large")
+		return
+	}
+	file, header, err := r.FormFile("file")
+	if err != nil {
+		apierror.Write(w, r, http.StatusBadRequest, apierror.CodeBadRequest, "Missing file")
+		return
+	}
+	defer file.Close()
+	content, err := h.uploads.Check(r.Context(), header.Filename, file)
+	if err != nil {
+		log.Printf("Refused watchlist upload %s: %v", header.Filename, err)
+		uploadscan.WriteError(w, r, err)
+		return
+	}
+	if xlsx.IsWorkbook(header.Filename, content) {
+		if content, err = xlsx.ToCSV(content, r.FormValue("sheet")); err != nil {
+			apierror.Write(w, r, http.StatusBadRequest, apierror.CodeBadRequest, fmt.Sprintf("Invalid spreadsheet: %v", err))
+			return
+		}
+	}
+	content, quality := textenc.ToUTF8(content)
+	if len(quality.Warnings) > 0 {
+		log.Printf("Warning: watchlist upload %s read as %s", header.Filename, quality.Encoding)
+	}
+
+	name := r.FormValue("name")
+	if name == "" {
+		name = fmt.Sprintf("Watchlist %s", time.Now().Format("2006-01-02 15:04"))
+	}
+	source := r.FormValue("source")
+	if source == "" {
+		source = models.ListSourceLocal
+	}
+	category := models.ListCategoryInternal
+	if c := r.FormValue("category"); c != "" {
+		var ok bool
+		if category, ok = models.ParseListCategory(c); !ok {
+			apierror.Write(w, r, http.StatusBadRequest, apierror.CodeBadRequest, fmt.Sprintf("Unknown list category %q", c))
+			return
+		}
+	}
+
+	uploadDir := "./data/uploads"
+	if err := os.MkdirAll(uploadDir, 0700); err != nil {
+		apierror.Write(w, r, http.StatusInternalServerError, apierror.CodeInternal, "Failed to create upload directory")
+		return
+	}
+	finalPath := filepath.Join(uploadDir, fmt.Sprintf("watchlist_%d.csv", time.Now().UnixNano()))
+	if err := h.files.WriteFile(finalPath, bytes.NewReader(content), 0600); err != nil {
+		apierror.Write(w, r, http.StatusInternalServerError, apierror.CodeInternal, "Failed to write file")
+		return
+	}
+	absPath, err := filepath.Abs(finalPath)
+	if err != nil {
+		absPath = finalPath
+	}
+
+	// Parse once now so a malformed list is refused at upload
+	entries, err := h.readWatchlist(absPath, source, 0)
+	if err != nil {
+		os.Remove(finalPath)
+		apierror.Write(w, r, http.StatusBadRequest, apierror.CodeBadRequest, fmt.Sprintf("Invalid watchlist: %v", err))
+		return
+	}
+
+	listID, err := h.repo.CreateSanctionList(r.Context(), name, source, category, r.FormValue("description"), absPath)
+	if err != nil {
+		os.Remove(finalPath)
+		apierror.Write(w, r, http.StatusInternalServerError, apierror.CodeDatabaseError, fmt.Sprintf("Failed to create list: %v", err))
+		return
+	}
+	if err := h.repo.UpdateSanctionListCount(r.Context(), listID, len(entries)); err != nil {
+		log.Printf("Warning: failed to update record count: %v", err)
+	}
+	log.Printf("Created local watchlist %d with %d entries", listID, len(entries))
+	h.publishListUpdate(r, events.ListWatchlist, listID, events.ListCreated)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"id":      listID,
+		"count":   len(entries),
+		"quality": quality,
+	})
+}
+
+// getWatchlists returns the local watchlists
+func (h *Handler) getWatchlists(w http.ResponseWriter, r *http.Request) {
+	lists, err := h.repo.GetSanctionLists(r.Context())
+	if err != nil {
+		apierror.Write(w, r, http.StatusInternalServerError, apierror.CodeDatabaseError, "Database error")
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(lists)
+}
+
+// deleteWatchlist deletes a local watchlist and its file
+func (h *Handler) deleteWatchlist(w http.ResponseWriter, r *http.Request, id int64) {
+	lists, err := h.repo.GetSanctionLists(r.Context())
+	if err != nil {
+		apierror.Write(w, r, http.StatusInternalServerError, apierror.CodeDatabaseError, "Database error")
+		return
+	}
+	var filePath string
+	for _, l := range lists {
+		if l.ID == id {
+			filePath = l.FilePath
+			break
+		}
+	}
+
+	found, err := h.repo.DeleteWatchlist(r.Context(), id)
+	if err != nil {
+		apierror.Write(w, r, http.StatusInternalServerError, apierror.CodeDatabaseError, "Failed to delete watchlist")
+		return
+	}
+	if !found {
+		apierror.Write(w, r, http.StatusNotFound, apierror.CodeListNotFound, "List not found")
+		return
+	}
+	if filePath != "" {
+		if err := os.Remove(filePath); err != nil && !os.IsNotExist(err) {
+			log.Printf("Warning: failed to remove watchlist file %s: %v", filePath, err)
+		}
+	}
+	h.publishListUpdate(r, events.ListWatchlist, id, events.ListDeleted)
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]bool{"success": true})
+}
+
+// watchlistIDs returns the local watchlists a screening selects: ids and
+// every list in categories. An unknown ID returns errUnknownWatchlist.
+func (h *Handler) watchlistIDs(ctx context.Context, ids []int64, categories []string) ([]int64, error) {
+	lists, err := h.repo.GetSanctionLists(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var selected []int64
+	for _, id := range ids {
+		if !slices.ContainsFunc(lists, func(l models.SanctionList) bool { return l.ID == id }) {
+			return nil, fmt.Errorf("%w: %d", errUnknownWatchlist, id)
+		}
+		if !slices.Contains(selected, id) {
+			selected = append(selected, id)
+		}
+	}
+	for _, l := range lists {
+		if slices.Contains(categories, l.Category) && !slices.Contains(selected, l.ID) {
+			selected = append(selected, l.ID)
+		}
+	}
+	return selected, nil
+}
+
+// readWatchlist parses the entries of a local watchlist file
+func (h *Handler) readWatchlist(path, source string, listID int64) ([]*models.Sanction, error) {
+	file, err := h.files.OpenFile(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	order, _ := psiadapter.ParseDateOrder(h.psiConfig.DateOrder)
+	return bootstrap.ParseSanctions(file, source, listID, order)
+}
+
+// localScreening is what screenLocally needs from the loading phase of a
+// screening
+type localScreening struct {
+	screeningID    int64
+	watchlistIDs   []int64
+	customers      []*models.Customer
+	serialized     []string
+	columnMapping  map[string]string
+	enabledColumns []string
+	names          translit.Profile
+	memoryGB       float64
+	start          time.Time
+}
+
+// screenLocally runs a LOCAL screening: it intersects the customers with
+// the selected watchlists in-process and resolves the matches from the
+// lists themselves
+func (h *Handler) screenLocally(ctx context.Context, job *jobs.ScreeningJob, psi *psiadapter.Adapter, capture *profiling.Capture, l localScreening) {
+	scheme, matches, resolved, err := h.intersectLocal(ctx, job, psi, capture, &screeningSide{job: job}, l)
+	if err != nil {
+		job.SetError(err)
+		job.SetStatus(jobs.StatusFailed)
+		return
+	}
+
+	capture.Phase(string(jobs.PhasePersist))
+	job.AddProgress(jobs.PhasePersist, 90, "Saving results to database", nil)
+	persistStart := time.Now()
+	resultIDs, collisions, err := h.persistMatches(ctx, l.matchSet(job, scheme, matches), resolved)
+	if err != nil {
+		job.SetError(fmt.Errorf("failed to save results: %w", err))
+		job.SetStatus(jobs.StatusFailed)
+		return
+	}
+	job.RecordPhaseDuration("persist", time.Since(persistStart))
+	h.completeScreening(ctx, job, l.screeningID, resultIDs, customerCount(l.serialized), collisions, l.start, jobs.StatusCompleted)
+}
+
+// matchSet returns the matches of the customers against local watchlists
+// for resolution
+func (l localScreening) matchSet(job *jobs.ScreeningJob, scheme psiadapter.HashScheme, matches []uint64) *matchSet {
+	return &matchSet{
+		jobID:          job.ID,
+		screeningID:    l.screeningID,
+		customerListID: job.CustomerListID,
+		hashes:         matches,
+		customers:      l.customers,
+		serialized:     l.serialized,
+		columnMapping:  l.columnMapping,
+		enabledColumns: l.enabledColumns,
+		names:          l.names,
+		scheme:         scheme,
+		listSource:     models.ListSourceLocal,
+	}
+}
+
+// intersectLocal builds the set of the selected watchlists with the
+// adapter, as the PSI server would, and intersects the customers with it.
+// Each matched entry is returned once per matching hash, carrying that
+// hash, as the server resolves them.
+func (h *Handler) intersectLocal(ctx context.Context, job *jobs.ScreeningJob, psi *psiadapter.Adapter, capture *profiling.Capture, side *screeningSide, l localScreening) (psiadapter.HashScheme, []uint64, []*models.Sanction, error) {
+	var scheme psiadapter.HashScheme
+	side.progress(jobs.PhaseServerInit, 25, "Loading local watchlists", nil)
+	lists, err := h.repo.GetSanctionLists(ctx)
+	if err != nil {
+		return scheme, nil, nil, fmt.Errorf("failed to load watchlists: %w", err)
+	}
+	var entries []*models.Sanction
+	for _, list := range lists {
+		if !slices.Contains(l.watchlistIDs, list.ID) {
+			continue
+		}
+		listEntries, err := h.readWatchlist(list.FilePath, list.Source, list.ID)
+		if err != nil {
+			return scheme, nil, nil, fmt.Errorf("failed to read watchlist %d: %w", list.ID, err)
+		}
+		for _, e := range listEntries {
+			e.Category = list.Category
+		}
+		entries = append(entries, listEntries...)
+	}
+
+	// Each entry contributes its name and every alias, as on the server,
+	// and its document numbers when the customers have any
+	documents := hasDocumentInputs(l.serialized)
+	var set []string
+	var owners []int
+	for i, e := range entries {
+		inputs := psiadapter.SanctionHashInputs(e, l.enabledColumns, l.names)
+		if documents {
+			inputs = append(inputs, psiadapter.DocumentHashInputs(e.Attributes, e.Country)...)
+		}
+		for _, input := range inputs {
+			set = append(set, input)
+			owners = append(owners, i)
+		}
+	}
+	job.SetCounts(customerCount(l.serialized), len(entries))
+	job.SetWorkerInfo(psi.GetWorkerCount(), psi.EstimateMemory(len(l.serialized), len(set)), l.memoryGB)
+	if err := psi.ValidateMemoryRequirement(len(l.serialized), len(set), l.memoryGB); err != nil {
+		return scheme, nil, nil, fmt.Errorf("screening exceeds its memory limit: %w", err)
+	}
+	if len(set) == 0 {
+		return scheme, nil, nil, errors.New("the selected watchlists have no entries")
+	}
+
+	scheme, err = psiadapter.NewHashScheme(h.psiConfig.HashAlgorithm)
+	if err != nil {
+		return scheme, nil, nil, err
+	}
+
+	// The trees only live for this screening
+	treeDir := filepath.Join(h.psiConfig.TreeDBPath, "local_"+job.ID)
+	if err := os.MkdirAll(treeDir, 0700); err != nil {
+		return scheme, nil, nil, fmt.Errorf("failed to create tree directory: %w", err)
+	}
+	defer os.RemoveAll(treeDir)
+
+	side.progress(jobs.PhaseServerInit, 40, fmt.Sprintf("Building watchlist set of %d entries", len(entries)), nil)
+	buildStart := time.Now()
+	watchlistCtx, err := psi.InitServerBatched(ctx, set, filepath.Join(treeDir, "watchlist"), scheme, nil)
+	if err != nil {
+		return scheme, nil, nil, fmt.Errorf("failed to build watchlist set: %w", err)
+	}
+	defer psi.CleanupBatchContext(watchlistCtx)
+	side.duration("build", time.Since(buildStart))
+
+	capture.Phase(string(jobs.PhaseIntersection))
+	side.progress(jobs.PhaseIntersection, 60, "Encrypting and intersecting in-process...", nil)
+	intersectStart := time.Now()
+	matches, err := psi.DetectIntersectionBatched(ctx, watchlistCtx, l.serialized)
+	if err != nil {
+		return scheme, nil, nil, fmt.Errorf("local intersection failed: %w", err)
+	}
+	side.duration("intersection", time.Since(intersectStart))
+	side.progress(jobs.PhaseIntersection, 85, fmt.Sprintf("Found %d potential matches", len(matches)), map[string]string{
+		"potential_matches": fmt.Sprintf("%d", len(matches)),
+	})
+
+	matched := make(map[uint64]bool, len(matches))
+	for _, m := range matches {
+		matched[m] = true
+	}
+	var resolved []*models.Sanction
+	for i, hash := range scheme.Hash(set) {
+		if matched[hash] {
+			entry := *entries[owners[i]]
+			entry.Hash = int64(hash)
+			resolved = append(resolved, &entry)
+		}
+	}
+	return scheme, matches, resolved, nil
+}
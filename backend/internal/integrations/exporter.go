@@ -0,0 +1,169 @@
+// Package integrations pushes confirmed matches to external case-management
+// and SIEM systems.
+package integrations
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/SanthoshCheemala/FLARE/backend/internal/models"
+)
+
+const (
+	initialBackoff = time.Second
+	maxBackoff     = time.Minute
+)
+
+// Format encodes a confirmed match into a connector's wire format
+type Format func(match *models.ScreeningResultDetail) ([]byte, error)
+
+// JSONFormat sends the match as plain JSON
+func JSONFormat(match *models.ScreeningResultDetail) ([]byte, error) {
+	return json.Marshal(map[string]interface{}{
+		"event":       "match.confirmed",
+		"confirmedAt": time.Now().UTC(),
+		"match":       match,
+	})
+}
+
+// Connector delivers matches to one endpoint. Each connector has its own
+// queue and worker so a slow or failing endpoint does not hold up others.
+type Connector struct {
+	name       string
+	url        string
+	authToken  string
+	format     Format
+	maxRetries int
+	client     *http.Client
+	queue      chan *models.ScreeningResultDetail
+}
+
+func NewConnector(name, url, authToken string, format Format, maxRetries, queueSize int) *Connector {
+	return &Connector{
+		name:       name,
+		url:        url,
+		authToken:  authToken,
+		format:     format,
+		maxRetries: maxRetries,
+		client:     &http.Client{Timeout: 30 * time.Second},
+		queue:      make(chan *models.ScreeningResultDetail, queueSize),
+	}
+}
+
+// Name returns the connector's name used in logs
+func (c *Connector) Name() string {
+	return c.name
+}
+
+// Enqueue queues match for delivery, reporting false if the queue is full
+func (c *Connector) Enqueue(match *models.ScreeningResultDetail) bool {
+	select {
+	case c.queue <- match:
+		return true
+	default:
+		return false
+	}
+}
+
+// Run delivers queued matches until ctx is done, retrying failed
+// deliveries with exponential backoff
+func (c *Connector) Run(ctx context.Context) {
+	for {
+		select {
+		case match := <-c.queue:
+			c.deliver(ctx, match)
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func (c *Connector) deliver(ctx context.Context, match *models.ScreeningResultDetail) {
+	body, err := c.format(match)
+	if err != nil {
+		log.Printf("Export %s: failed to encode result %d: %v", c.name, match.ID, err)
+		return
+	}
+
+	backoff := initialBackoff
+	for attempt := 0; ; attempt++ {
+		err := c.send(ctx, body)
+		if err == nil {
+			log.Printf("Export %s: delivered result %d", c.name, match.ID)
+			return
+		}
+		if attempt >= c.maxRetries {
+			log.Printf("Export %s: giving up on result %d after %d attempts: %v", c.name, match.ID, attempt+1, err)
+			return
+		}
+		log.Printf("Export %s: delivery of result %d failed (attempt %d), retrying in %v: %v",
+			c.name, match.ID, attempt+1, backoff, err)
+
+		select {
+		case <-time.After(backoff):
+		case <-ctx.Done():
+			return
+		}
+		backoff = min(backoff*2, maxBackoff)
+	}
+}
+
+func (c *Connector) send(ctx context.Context, body []byte) error {
+	req, err := http.NewRequestWithContext(ctx, "POST", c.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if c.authToken != "" {
+		req.Header.Set("Authorization", "Bearer "+c.authToken)
+	}
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		respBody, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("endpoint returned status %d: %s", resp.StatusCode, string(respBody))
+	}
+	return nil
+}
+
+// Exporter fans confirmed matches out to every configured connector
+type Exporter struct {
+	connectors []*Connector
+}
+
+func NewExporter(connectors ...*Connector) *Exporter {
+	return &Exporter{connectors: connectors}
+}
+
+// Start launches a delivery worker per connector
+func (e *Exporter) Start(ctx context.Context) {
+	for _, c := range e.connectors {
+		go c.Run(ctx)
+	}
+}
+
+// Enabled reports whether any connector is configured
+func (e *Exporter) Enabled() bool {
+	return len(e.connectors) > 0
+}
+
+// Export queues match on every connector. A full queue drops the match
+// for that connector only.
+func (e *Exporter) Export(match *models.ScreeningResultDetail) {
+	for _, c := range e.connectors {
+		if !c.Enqueue(match) {
+			log.Printf("Export %s: queue full, dropping result %d", c.Name(), match.ID)
+		}
+	}
+}
@@ -0,0 +1,121 @@
+package integrations
+
+import (
+	"crypto/rand"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/SanthoshCheemala/FLARE/backend/internal/models"
+)
+
+// STIXFormat encodes the match as a STIX 2.1 bundle holding an identity for
+// the sanctioned party and a sighting of it in the screened customer base
+func STIXFormat(match *models.ScreeningResultDetail) ([]byte, error) {
+	identityID, err := stixID("identity")
+	if err != nil {
+		return nil, err
+	}
+	sightingID, err := stixID("sighting")
+	if err != nil {
+		return nil, err
+	}
+	bundleID, err := stixID("bundle")
+	if err != nil {
+		return nil, err
+	}
+
+	now := stixTime(time.Now())
+	seen := stixTime(match.CreatedAt)
+
+	identity := map[string]interface{}{
+		"type":           "identity",
+		"spec_version":   "2.1",
+		"id":             identityID,
+		"created":        now,
+		"modified":       now,
+		"name":           match.Sanction.Name,
+		"identity_class": stixIdentityClass(match.Sanction.EntityType),
+		"labels":         []string{strings.ToLower(match.Sanction.Category)},
+		"description": fmt.Sprintf("Sanctioned party from %s (program %s, country %s)",
+			match.Sanction.Source, match.Sanction.Program, match.Sanction.Country),
+	}
+	if ref := stixSourceReference(match.Sanction); ref != nil {
+		identity["external_references"] = []map[string]interface{}{ref}
+	}
+
+	sighting := map[string]interface{}{
+		"type":            "sighting",
+		"spec_version":    "2.1",
+		"id":              sightingID,
+		"created":         now,
+		"modified":        now,
+		"first_seen":      seen,
+		"last_seen":       seen,
+		"count":           1,
+		"sighting_of_ref": identityID,
+		"confidence":      int(match.MatchScore * 100),
+		"description": fmt.Sprintf("Confirmed match of customer %s (%s) in screening %d",
+			match.Customer.ExternalID, match.Customer.Name, match.ScreeningID),
+		"external_references": []map[string]interface{}{
+			{
+				"source_name": "flare",
+				"external_id": fmt.Sprintf("result-%d", match.ID),
+			},
+		},
+	}
+
+	return json.Marshal(map[string]interface{}{
+		"type":    "bundle",
+		"id":      bundleID,
+		"objects": []interface{}{identity, sighting},
+	})
+}
+
+// stixID returns a STIX identifier with a random (version 4) UUID
+func stixID(objectType string) (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("generate stix id: %w", err)
+	}
+	b[6] = (b[6] & 0x0f) | 0x40
+	b[8] = (b[8] & 0x3f) | 0x80
+	return fmt.Sprintf("%s--%x-%x-%x-%x-%x", objectType, b[0:4], b[4:6], b[6:8], b[8:10], b[10:16]), nil
+}
+
+func stixTime(t time.Time) string {
+	return t.UTC().Format("2006-01-02T15:04:05.000Z")
+}
+
+// stixSourceReference points at the sanction's entry in the official list:
+// a URL when its source reference is one, else the entry ID. It is nil when
+// the entry has no source reference.
+func stixSourceReference(s models.Sanction) map[string]interface{} {
+	if s.SourceRef == "" {
+		return nil
+	}
+	ref := map[string]interface{}{"source_name": s.Source}
+	if s.Source == "" {
+		ref["source_name"] = "sanctions-list"
+	}
+	if u, err := url.Parse(s.SourceRef); err == nil && (u.Scheme == "http" || u.Scheme == "https") {
+		ref["url"] = s.SourceRef
+	} else {
+		ref["external_id"] = s.SourceRef
+	}
+	return ref
+}
+
+// stixIdentityClass maps a sanction entity type to a STIX identity class.
+// Vessels and aircraft have no class of their own.
+func stixIdentityClass(entityType string) string {
+	switch entityType {
+	case "", models.EntityIndividual:
+		return "individual"
+	case models.EntityOrganization:
+		return "organization"
+	}
+	return "unknown"
+}
@@ -0,0 +1,37 @@
+package jobs
+
+import "time"
+
+// Throughput measures the processing rate of a phase as work completes
+type Throughput struct {
+	start time.Time
+	done  int
+}
+
+func NewThroughput() *Throughput {
+	return &Throughput{start: time.Now()}
+}
+
+// Add records n more completed items
+func (t *Throughput) Add(n int) {
+	t.done += n
+}
+
+// PerSecond returns items processed per second so far
+func (t *Throughput) PerSecond() float64 {
+	elapsed := time.Since(t.start).Seconds()
+	if elapsed <= 0 || t.done == 0 {
+		return 0
+	}
+	return float64(t.done) / elapsed
+}
+
+// Remaining estimates the time needed to finish total items at the
+// observed rate. It returns 0 when no rate is known yet.
+func (t *Throughput) Remaining(total int) time.Duration {
+	rate := t.PerSecond()
+	if rate == 0 || t.done >= total {
+		return 0
+	}
+	return time.Duration(float64(total-t.done) / rate * float64(time.Second))
+}
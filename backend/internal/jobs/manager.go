@@ -4,6 +4,9 @@ import (
 	"context"
 	"sync"
 	"time"
+
+	"github.com/SanthoshCheemala/FLARE/backend/internal/events"
+	"github.com/SanthoshCheemala/FLARE/backend/internal/metrics"
 )
 
 type Status string
@@ -14,8 +17,16 @@ const (
 	StatusCompleted Status = "COMPLETED"
 	StatusFailed    Status = "FAILED"
 	StatusCancelled Status = "CANCELLED"
+	// StatusPartial is a HYBRID screening that completed on one side only;
+	// the results of that side are saved
+	StatusPartial Status = "PARTIAL"
 )
 
+// Finished reports whether a job in status s has stopped
+func (s Status) Finished() bool {
+	return s == StatusCompleted || s == StatusPartial || s == StatusFailed || s == StatusCancelled
+}
+
 type Phase string
 
 const (
@@ -24,37 +35,56 @@ const (
 	PhaseIntersection  Phase = "intersection"
 	PhasePersist       Phase = "persist"
 	PhaseComplete      Phase = "complete"
+	PhaseFailed        Phase = "failed"
 )
 
 type Progress struct {
-	Phase     Phase             `json:"phase"`
-	Percent   int               `json:"percent"`
-	Message   string            `json:"message"`
-	Timestamp time.Time         `json:"timestamp"`
-	Metrics   map[string]string `json:"metrics,omitempty"`
+	Phase               Phase             `json:"phase"`
+	Percent             int               `json:"percent"`
+	Message             string            `json:"message"`
+	Timestamp           time.Time         `json:"timestamp"`
+	Metrics             map[string]string `json:"metrics,omitempty"`
+	EstimatedCompletion *time.Time        `json:"estimatedCompletion,omitempty"`
 }
 
 type ScreeningJob struct {
-	ID                string     `json:"id"`
-	Name              string     `json:"name"`
-	Status            Status     `json:"status"`
-	Progress          []Progress `json:"progress"`
-	CustomerListID    int64      `json:"customerListId"`
-	SanctionListIDs   []int64    `json:"sanctionListIds"`
-	ResultIDs         []int64    `json:"resultIds,omitempty"`
-	MatchCount        int        `json:"matchCount"`
-	CustomerCount     int        `json:"customerCount"`
-	SanctionCount     int        `json:"sanctionCount"`
-	StartedAt         time.Time  `json:"startedAt,omitempty"`
-	FinishedAt        time.Time  `json:"finishedAt,omitempty"`
-	Error             string     `json:"error,omitempty"`
-	CreatedBy         int64      `json:"createdBy"`
-	WorkerCount       int        `json:"workerCount"`
-	MemoryEstimateMB  float64    `json:"memoryEstimateMb"`
+	ID               string     `json:"id"`
+	Name             string     `json:"name"`
+	Status           Status     `json:"status"`
+	Progress         []Progress `json:"progress"`
+	CustomerListID   int64      `json:"customerListId"`
+	SanctionListIDs  []int64    `json:"sanctionListIds"`
+	Categories       []string   `json:"categories,omitempty"`
+	PackIDs          []string   `json:"packIds,omitempty"`
+	Transliteration  []string   `json:"transliteration,omitempty"`
+	ListSource       string     `json:"listSource,omitempty"`   // models.ListSourceLocal or ListSourceHybrid for local watchlists
+	WatchlistIDs     []int64    `json:"watchlistIds,omitempty"` // Local watchlists of a HYBRID screening
+	ResultIDs        []int64    `json:"resultIds,omitempty"`
+	MatchCount       int        `json:"matchCount"`
+	CustomerCount    int        `json:"customerCount"`
+	SanctionCount    int        `json:"sanctionCount"`
+	StartedAt        time.Time  `json:"startedAt,omitempty"`
+	FinishedAt       time.Time  `json:"finishedAt,omitempty"`
+	Error            string     `json:"error,omitempty"`
+	CreatedBy        int64      `json:"createdBy"`
+	WorkerCount      int        `json:"workerCount"`
+	MemoryEstimateMB float64    `json:"memoryEstimateMb"`
+	MemoryLimitGB    float64    `json:"memoryLimitGb"`
+	// PhaseDurations holds measured durations in milliseconds keyed by
+	// phase name (encryption, network, intersection, resolve, persist)
+	PhaseDurations map[string]int64 `json:"phaseDurationsMs,omitempty"`
+	// EstimatedCompletion is the latest throughput-based ETA
+	EstimatedCompletion time.Time `json:"estimatedCompletion,omitempty"`
+	// Metrics is the instrumentation of the PSI run, in the schema of the
+	// analytics report; set when a remote intersection ends
+	Metrics *metrics.Report `json:"metrics,omitempty"`
+
 	mu                sync.RWMutex
 	ctx               context.Context
 	cancel            context.CancelFunc
-	progressListeners []chan Progress
+	progressListeners []*Subscription
+	onFinish          func(*ScreeningJob)
+	bus               *events.Bus
 }
 
 type Manager struct {
@@ -62,6 +92,8 @@ type Manager struct {
 	jobs          map[string]*ScreeningJob
 	maxConcurrent int
 	running       int
+	onFinish      func(*ScreeningJob)
+	bus           *events.Bus
 }
 
 func NewManager(maxConcurrent int) *Manager {
@@ -86,10 +118,12 @@ func (m *Manager) Create(id, name string, customerListID int64, sanctionListIDs
 		CreatedBy:         createdBy,
 		ctx:               ctx,
 		cancel:            cancel,
-		progressListeners: []chan Progress{},
+		progressListeners: []*Subscription{},
 	}
 
 	m.mu.Lock()
+	job.onFinish = m.onFinish
+	job.bus = m.bus
 	m.jobs[id] = job
 	m.mu.Unlock()
 
@@ -113,6 +147,59 @@ func (m *Manager) List() []*ScreeningJob {
 	return jobs
 }
 
+// SetFinishHook registers fn to receive a snapshot of every job that
+// reaches a terminal status, e.g. to persist it before eviction
+func (m *Manager) SetFinishHook(fn func(*ScreeningJob)) {
+	m.mu.Lock()
+	m.onFinish = fn
+	m.mu.Unlock()
+}
+
+// SetBus makes jobs created from now on publish their lifecycle on bus:
+// JobStarted, PhaseCompleted for each recorded phase, and JobFinished
+func (m *Manager) SetBus(bus *events.Bus) {
+	m.mu.Lock()
+	m.bus = bus
+	m.mu.Unlock()
+}
+
+// EvictFinished removes jobs that finished more than ttl ago and returns
+// how many were evicted
+func (m *Manager) EvictFinished(ttl time.Duration) int {
+	cutoff := time.Now().Add(-ttl)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	evicted := 0
+	for id, job := range m.jobs {
+		job.mu.RLock()
+		finishedAt := job.FinishedAt
+		job.mu.RUnlock()
+
+		if !finishedAt.IsZero() && finishedAt.Before(cutoff) {
+			delete(m.jobs, id)
+			evicted++
+		}
+	}
+	return evicted
+}
+
+// RunEviction evicts finished jobs older than ttl every interval until ctx is done
+func (m *Manager) RunEviction(ctx context.Context, ttl, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			m.EvictFinished(ttl)
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
 func (m *Manager) CanStart() bool {
 	m.mu.RLock()
 	defer m.mu.RUnlock()
@@ -142,36 +229,47 @@ func (j *ScreeningJob) AddProgress(phase Phase, percent int, message string, met
 		Timestamp: time.Now(),
 		Metrics:   metrics,
 	}
+	if !j.EstimatedCompletion.IsZero() && phase != PhaseComplete {
+		eta := j.EstimatedCompletion
+		p.EstimatedCompletion = &eta
+	}
 	j.Progress = append(j.Progress, p)
 
-	// Notify listeners
+	// Notify listeners; subscriptions never block the publisher
 	for _, listener := range j.progressListeners {
-		select {
-		case listener <- p:
-		default:
-			// Don't block if listener is slow
-		}
+		listener.publish(p)
 	}
 	j.mu.Unlock()
 }
 
-func (j *ScreeningJob) Subscribe() chan Progress {
+// Subscribe registers a progress listener. It fails with
+// ErrTooManyListeners once MaxListenersPerJob are attached.
+func (j *ScreeningJob) Subscribe() (*Subscription, error) {
 	j.mu.Lock()
 	defer j.mu.Unlock()
 
-	ch := make(chan Progress, 10)
-	j.progressListeners = append(j.progressListeners, ch)
-	return ch
+	if len(j.progressListeners) >= MaxListenersPerJob {
+		return nil, ErrTooManyListeners
+	}
+
+	sub := newSubscription()
+	if !j.FinishedAt.IsZero() {
+		// Job already finished; nothing more will be published
+		sub.close()
+		return sub, nil
+	}
+	j.progressListeners = append(j.progressListeners, sub)
+	return sub, nil
 }
 
-func (j *ScreeningJob) Unsubscribe(ch chan Progress) {
+func (j *ScreeningJob) Unsubscribe(sub *Subscription) {
 	j.mu.Lock()
 	defer j.mu.Unlock()
 
 	for i, listener := range j.progressListeners {
-		if listener == ch {
+		if listener == sub {
 			j.progressListeners = append(j.progressListeners[:i], j.progressListeners[i+1:]...)
-			close(ch)
+			sub.close()
 			break
 		}
 	}
@@ -179,18 +277,55 @@ func (j *ScreeningJob) Unsubscribe(ch chan Progress) {
 
 func (j *ScreeningJob) SetStatus(status Status) {
 	j.mu.Lock()
+	// A cancelled job stays cancelled, whatever its screening reports as
+	// it stops
+	if j.Status == StatusCancelled {
+		j.mu.Unlock()
+		return
+	}
 	j.Status = status
 	if status == StatusRunning && j.StartedAt.IsZero() {
 		j.StartedAt = time.Now()
+		j.bus.Publish(events.JobStarted{JobID: j.ID, Name: j.Name, CreatedBy: j.CreatedBy})
 	}
-	if status == StatusCompleted || status == StatusFailed || status == StatusCancelled {
+	if status.Finished() && j.FinishedAt.IsZero() {
 		j.FinishedAt = time.Now()
+		j.bus.Publish(events.JobFinished{JobID: j.ID, Status: string(status), MatchCount: j.MatchCount, Error: j.Error})
+
+		// Failed and cancelled jobs get an explicit terminal event so
+		// subscribers can tell how the job ended
+		if status == StatusFailed || status == StatusCancelled {
+			message := "Screening cancelled"
+			if status == StatusFailed {
+				message = "Screening failed"
+				if j.Error != "" {
+					message = "Screening failed: " + j.Error
+				}
+			}
+			p := Progress{
+				Phase:     PhaseFailed,
+				Message:   message,
+				Timestamp: j.FinishedAt,
+			}
+			j.Progress = append(j.Progress, p)
+			for _, listener := range j.progressListeners {
+				listener.publish(p)
+			}
+		}
 
-		// Close all listeners
+		// Close all listeners; they still drain anything queued
 		for _, listener := range j.progressListeners {
-			close(listener)
+			listener.close()
 		}
 		j.progressListeners = nil
+
+		if j.onFinish != nil {
+			onFinish := j.onFinish
+			j.mu.Unlock()
+			snapshot := j.GetSnapshot()
+			onFinish(&snapshot)
+			return
+		}
 	}
 	j.mu.Unlock()
 }
@@ -210,6 +345,18 @@ func (j *ScreeningJob) SetResults(resultIDs []int64, matchCount int) {
 	j.mu.Unlock()
 }
 
+// SetReResolved records results rebuilt after the job failed to resolve its
+// matches, and marks it completed
+func (j *ScreeningJob) SetReResolved(resultIDs []int64) {
+	j.mu.Lock()
+	j.Status = StatusCompleted
+	j.Error = ""
+	j.ResultIDs = resultIDs
+	j.MatchCount = len(resultIDs)
+	j.bus.Publish(events.JobFinished{JobID: j.ID, Status: string(j.Status), MatchCount: j.MatchCount})
+	j.mu.Unlock()
+}
+
 func (j *ScreeningJob) SetCounts(customerCount, sanctionCount int) {
 	j.mu.Lock()
 	j.CustomerCount = customerCount
@@ -217,16 +364,58 @@ func (j *ScreeningJob) SetCounts(customerCount, sanctionCount int) {
 	j.mu.Unlock()
 }
 
-func (j *ScreeningJob) SetWorkerInfo(workerCount int, memoryMB float64) {
+// SetWorkerInfo records the resources the job runs with
+func (j *ScreeningJob) SetWorkerInfo(workerCount int, memoryMB, memoryLimitGB float64) {
 	j.mu.Lock()
 	j.WorkerCount = workerCount
 	j.MemoryEstimateMB = memoryMB
+	j.MemoryLimitGB = memoryLimitGB
+	j.mu.Unlock()
+}
+
+// SetEstimatedCompletion updates the ETA published with subsequent progress events
+func (j *ScreeningJob) SetEstimatedCompletion(eta time.Time) {
+	j.mu.Lock()
+	j.EstimatedCompletion = eta
+	j.mu.Unlock()
+}
+
+// DelayEstimatedCompletion moves the ETA out to eta if it is currently
+// earlier, so an overrunning phase doesn't report a time in the past
+func (j *ScreeningJob) DelayEstimatedCompletion(eta time.Time) {
+	j.mu.Lock()
+	if j.EstimatedCompletion.Before(eta) {
+		j.EstimatedCompletion = eta
+	}
+	j.mu.Unlock()
+}
+
+// RecordPhaseDuration stores the measured duration of a named phase
+func (j *ScreeningJob) RecordPhaseDuration(name string, d time.Duration) {
+	j.mu.Lock()
+	if j.PhaseDurations == nil {
+		j.PhaseDurations = make(map[string]int64)
+	}
+	j.PhaseDurations[name] = d.Milliseconds()
+	if name != "total" {
+		j.bus.Publish(events.PhaseCompleted{JobID: j.ID, Phase: name, DurationMs: d.Milliseconds()})
+	}
+	j.mu.Unlock()
+}
+
+// SetMetrics stores the instrumentation of the job's PSI run. The report
+// is shared with snapshots and must not be changed afterwards.
+func (j *ScreeningJob) SetMetrics(report metrics.Report) {
+	j.mu.Lock()
+	j.Metrics = &report
 	j.mu.Unlock()
 }
 
+// Cancel marks the job cancelled and cancels its context, which stops its
+// screening at the next check
 func (j *ScreeningJob) Cancel() {
-	j.cancel()
 	j.SetStatus(StatusCancelled)
+	j.cancel()
 }
 
 func (j *ScreeningJob) Context() context.Context {
@@ -237,23 +426,40 @@ func (j *ScreeningJob) GetSnapshot() ScreeningJob {
 	j.mu.RLock()
 	defer j.mu.RUnlock()
 
+	var durations map[string]int64
+	if j.PhaseDurations != nil {
+		durations = make(map[string]int64, len(j.PhaseDurations))
+		for k, v := range j.PhaseDurations {
+			durations[k] = v
+		}
+	}
+
 	// Create a copy without the internal fields
 	return ScreeningJob{
-		ID:               j.ID,
-		Name:             j.Name,
-		Status:           j.Status,
-		Progress:         append([]Progress{}, j.Progress...),
-		CustomerListID:   j.CustomerListID,
-		SanctionListIDs:  append([]int64{}, j.SanctionListIDs...),
-		ResultIDs:        append([]int64{}, j.ResultIDs...),
-		MatchCount:       j.MatchCount,
-		CustomerCount:    j.CustomerCount,
-		SanctionCount:    j.SanctionCount,
-		StartedAt:        j.StartedAt,
-		FinishedAt:       j.FinishedAt,
-		Error:            j.Error,
-		CreatedBy:        j.CreatedBy,
-		WorkerCount:      j.WorkerCount,
-		MemoryEstimateMB: j.MemoryEstimateMB,
+		ID:                  j.ID,
+		Name:                j.Name,
+		Status:              j.Status,
+		Progress:            append([]Progress{}, j.Progress...),
+		CustomerListID:      j.CustomerListID,
+		SanctionListIDs:     append([]int64{}, j.SanctionListIDs...),
+		Categories:          append([]string(nil), j.Categories...),
+		PackIDs:             append([]string(nil), j.PackIDs...),
+		Transliteration:     append([]string(nil), j.Transliteration...),
+		ListSource:          j.ListSource,
+		WatchlistIDs:        append([]int64(nil), j.WatchlistIDs...),
+		ResultIDs:           append([]int64{}, j.ResultIDs...),
+		MatchCount:          j.MatchCount,
+		CustomerCount:       j.CustomerCount,
+		SanctionCount:       j.SanctionCount,
+		StartedAt:           j.StartedAt,
+		FinishedAt:          j.FinishedAt,
+		Error:               j.Error,
+		CreatedBy:           j.CreatedBy,
+		WorkerCount:         j.WorkerCount,
+		MemoryEstimateMB:    j.MemoryEstimateMB,
+		MemoryLimitGB:       j.MemoryLimitGB,
+		PhaseDurations:      durations,
+		EstimatedCompletion: j.EstimatedCompletion,
+		Metrics:             j.Metrics,
 	}
 }
@@ -0,0 +1,114 @@
+package jobs
+
+import (
+	"errors"
+	"sync"
+)
+
+const (
+	// MaxListenersPerJob caps concurrent progress subscribers on one job
+	MaxListenersPerJob = 16
+	// subscriptionBuffer is the number of undelivered events kept per subscriber
+	subscriptionBuffer = 32
+)
+
+var ErrTooManyListeners = errors.New("too many listeners for job")
+
+// Subscription delivers a job's progress events to one consumer without
+// ever blocking the publisher. When the consumer falls behind, queued events
+// are coalesced so the latest progress of each phase is always delivered,
+// and terminal events are never dropped.
+type Subscription struct {
+	mu     sync.Mutex
+	queue  []Progress
+	notify chan struct{}
+	closed bool
+}
+
+func newSubscription() *Subscription {
+	return &Subscription{
+		queue:  make([]Progress, 0, subscriptionBuffer),
+		notify: make(chan struct{}, 1),
+	}
+}
+
+// Notify is signalled whenever events are queued or the subscription closes
+func (s *Subscription) Notify() <-chan struct{} {
+	return s.notify
+}
+
+// Drain returns queued events in order and whether the subscription has
+// closed. Once closed is true, no further events will arrive.
+func (s *Subscription) Drain() (events []Progress, closed bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	events = s.queue
+	s.queue = make([]Progress, 0, subscriptionBuffer)
+	return events, s.closed
+}
+
+func (s *Subscription) publish(p Progress) {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		return
+	}
+	s.enqueue(p)
+	s.mu.Unlock()
+	s.signal()
+}
+
+// enqueue appends p, coalescing older events when the buffer is full.
+// Callers must hold s.mu.
+func (s *Subscription) enqueue(p Progress) {
+	if len(s.queue) < subscriptionBuffer || isTerminal(p) {
+		s.queue = append(s.queue, p)
+		return
+	}
+
+	// Replace the pending event of the same phase with the newer one
+	for i := len(s.queue) - 1; i >= 0; i-- {
+		if s.queue[i].Phase == p.Phase && !isTerminal(s.queue[i]) {
+			s.queue = append(s.queue[:i], s.queue[i+1:]...)
+			s.queue = append(s.queue, p)
+			return
+		}
+	}
+
+	// Otherwise drop the oldest event that is superseded by a later one of its phase
+	for i, old := range s.queue {
+		if isTerminal(old) {
+			continue
+		}
+		for _, later := range s.queue[i+1:] {
+			if later.Phase == old.Phase {
+				s.queue = append(s.queue[:i], s.queue[i+1:]...)
+				s.queue = append(s.queue, p)
+				return
+			}
+		}
+	}
+
+	// Every queued event is the latest of its phase; keep them all
+	s.queue = append(s.queue, p)
+}
+
+func (s *Subscription) close() {
+	s.mu.Lock()
+	s.closed = true
+	s.mu.Unlock()
+	s.signal()
+}
+
+func (s *Subscription) signal() {
+	select {
+	case s.notify <- struct{}{}:
+	default:
+		// A wakeup is already pending; the consumer will drain everything
+	}
+}
+
+func isTerminal(p Progress) bool {
+	return p.Phase == PhaseComplete || p.Phase == PhaseFailed
+}
@@ -0,0 +1,87 @@
+// Package logging filters the standard logger by level.
+//
+// FLARE logs through the standard log package, so levels are inferred from
+// each line: "[DEBUG]" lines are debug, "Warning" lines are warnings, lines
+// starting with "Error" or "Failed" are errors and everything else is info.
+package logging
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"log"
+	"strings"
+	"sync/atomic"
+)
+
+// Level is a log severity
+type Level int32
+
+const (
+	LevelDebug Level = iota
+	LevelInfo
+	LevelWarn
+	LevelError
+)
+
+var levelNames = map[string]Level{
+	"debug": LevelDebug,
+	"info":  LevelInfo,
+	"warn":  LevelWarn,
+	"error": LevelError,
+}
+
+var current atomic.Int32
+
+func init() {
+	current.Store(int32(LevelInfo))
+}
+
+// ParseLevel parses debug, info, warn or error
+func ParseLevel(name string) (Level, error) {
+	level, ok := levelNames[strings.ToLower(strings.TrimSpace(name))]
+	if !ok {
+		return 0, fmt.Errorf("unknown log level %q (use debug, info, warn or error)", name)
+	}
+	return level, nil
+}
+
+// SetLevel changes the minimum level written by the standard logger. It is
+// safe to call while other goroutines are logging.
+func SetLevel(name string) error {
+	level, err := ParseLevel(name)
+	if err != nil {
+		return err
+	}
+	current.Store(int32(level))
+	return nil
+}
+
+// Install routes the standard logger through the level filter
+func Install(out io.Writer) {
+	log.SetOutput(&filterWriter{out: out})
+}
+
+type filterWriter struct {
+	out io.Writer
+}
+
+func (w *filterWriter) Write(p []byte) (int, error) {
+	if levelOf(p) < Level(current.Load()) {
+		return len(p), nil
+	}
+	return w.out.Write(p)
+}
+
+// levelOf classifies one log line, which includes the logger's prefix
+func levelOf(line []byte) Level {
+	switch {
+	case bytes.Contains(line, []byte("[DEBUG]")):
+		return LevelDebug
+	case bytes.Contains(line, []byte("Warning")):
+		return LevelWarn
+	case bytes.Contains(line, []byte(" Error")), bytes.Contains(line, []byte(" Failed")):
+		return LevelError
+	}
+	return LevelInfo
+}
@@ -0,0 +1,10 @@
+//go:build !unix
+
+package metrics
+
+import "time"
+
+// processCPUTime returns 0: process CPU time is only read on Unix
+func processCPUTime() time.Duration {
+	return 0
+}
@@ -0,0 +1,97 @@
+package metrics
+
+import (
+	"math"
+	"math/bits"
+	"slices"
+	"time"
+)
+
+// histogramSubBuckets is the number of equal-width buckets each power of two
+// is split into, so a bucket's bounds are within 1/8 of its values
+const histogramSubBuckets = 8
+
+// LatencyStats summarizes measured operation latencies. Percentiles
+// interpolate linearly between the closest ranks of the sorted samples.
+type LatencyStats struct {
+	Count     int               `json:"count"`
+	Min       time.Duration     `json:"min"`
+	Max       time.Duration     `json:"max"`
+	Mean      time.Duration     `json:"mean"`
+	Median    time.Duration     `json:"median"`
+	P90       time.Duration     `json:"p90"`
+	P95       time.Duration     `json:"p95"`
+	P99       time.Duration     `json:"p99"`
+	Histogram []HistogramBucket `json:"histogram"` // Non-empty buckets, in order
+}
+
+// HistogramBucket counts the samples in [LowerBound, UpperBound)
+type HistogramBucket struct {
+	LowerBound time.Duration `json:"lowerBound"`
+	UpperBound time.Duration `json:"upperBound"`
+	Count      int           `json:"count"`
+}
+
+// ComputeLatencyStats summarizes samples. Negative samples count as zero.
+func ComputeLatencyStats(samples []time.Duration) LatencyStats {
+	if len(samples) == 0 {
+		return LatencyStats{Histogram: []HistogramBucket{}}
+	}
+	sorted := make([]time.Duration, len(samples))
+	var total float64
+	for i, s := range samples {
+		if s > 0 {
+			sorted[i] = s
+		}
+		total += float64(sorted[i])
+	}
+	slices.Sort(sorted)
+
+	return LatencyStats{
+		Count:     len(sorted),
+		Min:       sorted[0],
+		Max:       sorted[len(sorted)-1],
+		Mean:      time.Duration(math.Round(total / float64(len(sorted)))),
+		Median:    percentile(sorted, 50),
+		P90:       percentile(sorted, 90),
+		P95:       percentile(sorted, 95),
+		P99:       percentile(sorted, 99),
+		Histogram: latencyHistogram(sorted),
+	}
+}
+
+// percentile returns the p-th percentile (0-100) of sorted samples
+func percentile(sorted []time.Duration, p float64) time.Duration {
+	rank := p / 100 * float64(len(sorted)-1)
+	lo := int(math.Floor(rank))
+	hi := min(lo+1, len(sorted)-1)
+	frac := rank - float64(lo)
+	return sorted[lo] + time.Duration(math.Round(frac*float64(sorted[hi]-sorted[lo])))
+}
+
+// latencyHistogram buckets sorted samples HDR-style: exact below
+// histogramSubBuckets nanoseconds, then each power of two split into
+// histogramSubBuckets equal buckets
+func latencyHistogram(sorted []time.Duration) []HistogramBucket {
+	buckets := []HistogramBucket{}
+	for _, s := range sorted {
+		lo, hi := histogramBucket(uint64(s))
+		if n := len(buckets); n > 0 && buckets[n-1].LowerBound == lo {
+			buckets[n-1].Count++
+			continue
+		}
+		buckets = append(buckets, HistogramBucket{LowerBound: lo, UpperBound: hi, Count: 1})
+	}
+	return buckets
+}
+
+// histogramBucket returns the bounds of the bucket holding v nanoseconds
+func histogramBucket(v uint64) (time.Duration, time.Duration) {
+	if v < histogramSubBuckets {
+		return time.Duration(v), time.Duration(v + 1)
+	}
+	exp := bits.Len64(v) - 1
+	width := uint64(1) << (exp - bits.Len64(histogramSubBuckets-1))
+	lo := v - (v-uint64(1)<<exp)%width
+	return time.Duration(lo), time.Duration(lo + width)
+}
@@ -0,0 +1,167 @@
+// Package metrics instruments PSI runs: phase timings, operation
+// throughput and latency, noise levels and process resource use.
+//
+// Backend screenings and the analytics report both record into a Recorder
+// and emit its Report, so the two describe a run with one schema.
+package metrics
+
+import (
+	"runtime"
+	"sync"
+	"time"
+)
+
+// Report is the instrumentation of one PSI run
+type Report struct {
+	ElapsedMs  int64            `json:"elapsedMs"`
+	PhasesMs   map[string]int64 `json:"phasesMs"` // Measured phases, e.g. encryption, intersection
+	Operations int              `json:"operations"`
+	// Throughput is operations per second of elapsed time
+	Throughput float64      `json:"throughputOpsPerSec"`
+	Latency    LatencyStats `json:"latency"` // Of the operations whose duration was observed
+	Noise      NoiseStats   `json:"noise"`
+	Resources  Resources    `json:"resources"`
+}
+
+// NoiseStats summarizes the noise of decrypted PSI values, as a fraction
+// of the modulus
+type NoiseStats struct {
+	Samples int     `json:"samples"`
+	Max     float64 `json:"max"`
+	Avg     float64 `json:"avg"` // Mean of the per-operation averages
+}
+
+// Resources is the process's resource use. CPUPercent is the process CPU
+// time over the run as a share of every core, 0 where it can't be measured.
+type Resources struct {
+	AllocMB    float64 `json:"allocMb"`
+	SysMB      float64 `json:"sysMb"`
+	NumGC      uint32  `json:"numGc"`
+	Goroutines int     `json:"goroutines"`
+	CPUPercent float64 `json:"cpuPercent"`
+}
+
+// Recorder collects the instrumentation of one run. It is safe for
+// concurrent use.
+type Recorder struct {
+	mu        sync.Mutex
+	start     time.Time
+	startCPU  time.Duration
+	elapsed   time.Duration // Set by SetElapsed; zero measures from start
+	phases    map[string]time.Duration
+	ops       int
+	latencies []time.Duration
+	noise     NoiseStats
+	noiseSum  float64
+}
+
+// NewRecorder starts recording a run
+func NewRecorder() *Recorder {
+	return &Recorder{start: time.Now(), startCPU: processCPUTime(), phases: make(map[string]time.Duration)}
+}
+
+// Phase starts timing the named phase and returns the function that stops
+// it. Time spent in a phase more than once adds up.
+func (r *Recorder) Phase(name string) func() {
+	start := time.Now()
+	return func() { r.RecordPhase(name, time.Since(start)) }
+}
+
+// RecordPhase adds d to the named phase
+func (r *Recorder) RecordPhase(name string, d time.Duration) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.phases[name] += d
+}
+
+// AddOperations counts n more completed operations
+func (r *Recorder) AddOperations(n int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.ops += n
+}
+
+// ObserveLatency records the duration of one operation
+func (r *Recorder) ObserveLatency(d time.Duration) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.latencies = append(r.latencies, d)
+}
+
+// ObserveNoise records the maximum and average noise of one operation
+func (r *Recorder) ObserveNoise(maxNoise, avgNoise float64) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.noise.Samples++
+	if maxNoise > r.noise.Max {
+		r.noise.Max = maxNoise
+	}
+	r.noiseSum += avgNoise
+	r.noise.Avg = r.noiseSum / float64(r.noise.Samples)
+}
+
+// SetElapsed fixes the run's elapsed time, for runs recorded after the
+// fact. Otherwise it is the time since NewRecorder.
+func (r *Recorder) SetElapsed(d time.Duration) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.elapsed = d
+}
+
+// Throughput returns operations per second so far
+func (r *Recorder) Throughput() float64 {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return throughput(r.ops, r.elapsedLocked())
+}
+
+// Report returns what was recorded so far
+func (r *Recorder) Report() Report {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	elapsed := r.elapsedLocked()
+	phases := make(map[string]int64, len(r.phases))
+	for name, d := range r.phases {
+		phases[name] = d.Milliseconds()
+	}
+	return Report{
+		ElapsedMs:  elapsed.Milliseconds(),
+		PhasesMs:   phases,
+		Operations: r.ops,
+		Throughput: throughput(r.ops, elapsed),
+		Latency:    ComputeLatencyStats(r.latencies),
+		Noise:      r.noise,
+		Resources:  r.resourcesLocked(),
+	}
+}
+
+func (r *Recorder) elapsedLocked() time.Duration {
+	if r.elapsed > 0 {
+		return r.elapsed
+	}
+	return time.Since(r.start)
+}
+
+func (r *Recorder) resourcesLocked() Resources {
+	var m runtime.MemStats
+	runtime.ReadMemStats(&m)
+	res := Resources{
+		AllocMB:    float64(m.Alloc) / (1 << 20),
+		SysMB:      float64(m.Sys) / (1 << 20),
+		NumGC:      m.NumGC,
+		Goroutines: runtime.NumGoroutine(),
+	}
+	if cpu := processCPUTime(); cpu > 0 {
+		if wall := time.Since(r.start); wall > 0 {
+			res.CPUPercent = 100 * float64(cpu-r.startCPU) / float64(wall) / float64(runtime.NumCPU())
+		}
+	}
+	return res
+}
+
+func throughput(ops int, elapsed time.Duration) float64 {
+	if ops == 0 || elapsed <= 0 {
+		return 0
+	}
+	return float64(ops) / elapsed.Seconds()
+}
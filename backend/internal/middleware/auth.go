@@ -3,29 +3,46 @@ package middleware
 import (
 	"context"
 	"net/http"
+	"strings"
 
+	"github.com/SanthoshCheemala/FLARE/backend/internal/apierror"
 	"github.com/SanthoshCheemala/FLARE/backend/internal/auth"
 )
 
 func Auth(authSvc *auth.Service) func(http.Handler) http.Handler {
+	return authenticate(authSvc, true)
+}
+
+// OptionalAuth signs in the user of requests that carry an access token and
+// lets requests without one through anonymously. A token that is presented
+// but invalid is still refused.
+func OptionalAuth(authSvc *auth.Service) func(http.Handler) http.Handler {
+	return authenticate(authSvc, false)
+}
+
+func authenticate(authSvc *auth.Service, required bool) func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			authHeader := r.Header.Get("Authorization")
 			if authHeader == "" {
-				http.Error(w, "Authorization header required", http.StatusUnauthorized)
+				if !required {
+					next.ServeHTTP(w, r)
+					return
+				}
+				apierror.Write(w, r, http.StatusUnauthorized, apierror.CodeUnauthorized, "Authorization header required")
 				return
 			}
 
 			// Expect "Bearer <token>"
 			if len(authHeader) < 7 || authHeader[:7] != "Bearer " {
-				http.Error(w, "Invalid authorization header format", http.StatusUnauthorized)
+				apierror.Write(w, r, http.StatusUnauthorized, apierror.CodeUnauthorized, "Invalid authorization header format")
 				return
 			}
 
 			tokenString := authHeader[7:]
 			claims, err := authSvc.ValidateAccessToken(tokenString)
 			if err != nil {
-				http.Error(w, "Invalid or expired token", http.StatusUnauthorized)
+				apierror.Write(w, r, http.StatusUnauthorized, apierror.CodeUnauthorized, "Invalid or expired token")
 				return
 			}
 
@@ -41,12 +58,31 @@ func Auth(authSvc *auth.Service) func(http.Handler) http.Handler {
 	}
 }
 
+// AdminToken requires the configured admin bearer token. The guarded routes
+// are disabled when no token is configured.
+func AdminToken(token string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if token == "" {
+				apierror.Write(w, r, http.StatusForbidden, apierror.CodeForbidden, "Admin API is disabled; set ADMIN_TOKEN to enable it")
+				return
+			}
+			presented := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+			if !auth.TokensEqual(presented, token) {
+				apierror.Write(w, r, http.StatusUnauthorized, apierror.CodeUnauthorized, "Invalid admin token")
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
 func RequireRole(roles ...string) func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			userCtx := auth.GetUserContext(r.Context())
 			if userCtx == nil {
-				http.Error(w, "Unauthorized", http.StatusUnauthorized)
+				apierror.Write(w, r, http.StatusUnauthorized, apierror.CodeUnauthorized, "Unauthorized")
 				return
 			}
 
@@ -62,7 +98,7 @@ func RequireRole(roles ...string) func(http.Handler) http.Handler {
 				}
 			}
 
-			http.Error(w, "Forbidden", http.StatusForbidden)
+			apierror.Write(w, r, http.StatusForbidden, apierror.CodeForbidden, "Forbidden")
 		})
 	}
 }
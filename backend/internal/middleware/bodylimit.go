@@ -0,0 +1,54 @@
+package middleware
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/SanthoshCheemala/FLARE/backend/internal/apierror"
+	"github.com/go-chi/chi/v5"
+)
+
+// BodyLimits caps the size of request bodies per route
+type BodyLimits struct {
+	Default int64            // Bytes; 0 leaves bodies unlimited
+	Routes  map[string]int64 // Route pattern, e.g. /session/intersect, to bytes, overriding Default
+}
+
+// Limit returns the limit of the route pattern
+func (l BodyLimits) Limit(pattern string) int64 {
+	if n, ok := l.Routes[pattern]; ok {
+		return n
+	}
+	return l.Default
+}
+
+// BodyLimit caps request bodies at their route's limit. Requests declaring
+// a longer body are refused with 413 before it is read. Other bodies are
+// cut off at the limit, so decoding them fails with an error that
+// apierror.TooLarge answers with 413. It must be used on the router, where
+// it looks up the route the request is for.
+func BodyLimit(limits BodyLimits) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			pattern := r.URL.Path
+			if rctx := chi.RouteContext(r.Context()); rctx != nil && rctx.Routes != nil {
+				if p := rctx.Routes.Find(chi.NewRouteContext(), r.Method, r.URL.Path); p != "" {
+					pattern = p
+				}
+			}
+			limit := limits.Limit(pattern)
+			if limit <= 0 || r.Body == nil || r.Body == http.NoBody {
+				next.ServeHTTP(w, r)
+				return
+			}
+			if r.ContentLength > limit {
+				apierror.WriteDetails(w, r, http.StatusRequestEntityTooLarge, apierror.CodeBodyTooLarge,
+					fmt.Sprintf("Request body of %d bytes exceeds the %d byte limit of %s", r.ContentLength, limit, pattern),
+					map[string]int64{"limitBytes": limit})
+				return
+			}
+			r.Body = http.MaxBytesReader(w, r.Body, limit)
+			next.ServeHTTP(w, r)
+		})
+	}
+}
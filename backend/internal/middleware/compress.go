@@ -0,0 +1,23 @@
+package middleware
+
+import (
+	"net/http"
+
+	chimiddleware "github.com/go-chi/chi/v5/middleware"
+)
+
+// compressible are the response types worth compressing. Serialized PSI
+// params and result sets are JSON and can run to tens of megabytes; binary
+// downloads such as profiles are already compressed.
+var compressible = []string{
+	"application/json",
+	"text/plain",
+	"text/csv",
+	"text/html",
+}
+
+// Compress gzips responses of compressible types for clients that accept it.
+// Event streams are left uncompressed so each event flushes as it is sent.
+func Compress(level int) func(http.Handler) http.Handler {
+	return chimiddleware.Compress(level, compressible...)
+}
@@ -1,6 +1,12 @@
 package models
 
-import "time"
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+	"unicode"
+)
 
 type Customer struct {
 	ID         int64     `json:"id"`
@@ -11,6 +17,54 @@ type Customer struct {
 	Hash       int64     `json:"hash"` // Changed to int64 for SQLite compatibility
 	ListID     int64     `json:"listId"`
 	CreatedAt  time.Time `json:"createdAt"`
+
+	EntityType   string `json:"entityType"`             // One of the Entity* constants
+	Registration string `json:"registration,omitempty"` // Company registration or aircraft tail number
+	IMONumber    string `json:"imoNumber,omitempty"`    // Vessels
+
+	// Attributes holds the list's other columns, such as a passport number
+	// or address, keyed by AttributeKey of their header
+	Attributes map[string]string `json:"attributes,omitempty"`
+	// HashVersion is the psiadapter.RecordHashVersion Hash was computed
+	// under; 0 for records stored before hashes were versioned
+	HashVersion int `json:"hashVersion,omitempty"`
+}
+
+// HashValues returns the fields serialization profiles can hash, including
+// attributes
+func (c *Customer) HashValues() map[string]string {
+	values := map[string]string{
+		"name":         c.Name,
+		"dob":          c.DOB,
+		"country":      c.Country,
+		"imo":          c.IMONumber,
+		"registration": c.Registration,
+	}
+	for k, v := range c.Attributes {
+		if _, ok := values[k]; !ok {
+			values[k] = v
+		}
+	}
+	return values
+}
+
+// AttributeKey turns an upload header into an attribute key: lowercase,
+// with runs of other characters than letters and digits as underscores
+func AttributeKey(header string) string {
+	var b strings.Builder
+	underscore := false
+	for _, r := range strings.ToLower(strings.TrimSpace(header)) {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			if underscore && b.Len() > 0 {
+				b.WriteByte('_')
+			}
+			underscore = false
+			b.WriteRune(r)
+		} else {
+			underscore = true
+		}
+	}
+	return b.String()
 }
 
 type CustomerList struct {
@@ -34,18 +88,288 @@ type Sanction struct {
 	ListID    int64     `json:"listId"`
 	UpdatedAt time.Time `json:"updatedAt"`
 	Version   int       `json:"version"`
+
+	EntityType   string   `json:"entityType"` // One of the Entity* constants
+	Aliases      []string `json:"aliases,omitempty"`
+	IMONumber    string   `json:"imoNumber,omitempty"`    // Vessels
+	Registration string   `json:"registration,omitempty"` // Aircraft tail number or company registration
+	Category     string   `json:"category"`               // Category of the list the entry came from
+
+	// Attributes holds the values of the list schema's custom fields,
+	// normalized by their type
+	Attributes map[string]string `json:"attributes,omitempty"`
+	// HashVersion is the psiadapter.RecordHashVersion Hash was computed
+	// under; 0 for records stored before hashes were versioned
+	HashVersion int `json:"hashVersion,omitempty"`
+	// Retention is what the client kept of a record the PSI server
+	// resolved, one of the Retention* constants
+	Retention string `json:"retention,omitempty"`
+	// ValidFrom and ValidTo bound when the entry is listed; nil leaves that
+	// side open. ValidTo is exclusive, so a delisted entry stops matching
+	// at that instant.
+	ValidFrom *time.Time `json:"validFrom,omitempty"`
+	ValidTo   *time.Time `json:"validTo,omitempty"`
+	// SourceRef points at the entry in the official list: its entry ID or
+	// a URL. It is kept under every retention policy.
+	SourceRef string `json:"sourceRef,omitempty"`
+}
+
+// InEffect reports whether the entry is listed at t
+func (s *Sanction) InEffect(t time.Time) bool {
+	return (s.ValidFrom == nil || !t.Before(*s.ValidFrom)) && (s.ValidTo == nil || t.Before(*s.ValidTo))
+}
+
+// What the client keeps of a sanction record the PSI server resolved for a
+// match. Authority data kept locally may be bound by a sharing agreement.
+const (
+	RetentionFull     = "full"     // The whole record
+	RetentionRedacted = "redacted" // Name, program, source, category and entity type
+	// RetentionReference keeps only the record hash, which the authority
+	// can look the record up by, the source list and the source reference
+	RetentionReference = "reference"
+)
+
+// IsRetention reports whether policy is one of the Retention* constants
+func IsRetention(policy string) bool {
+	switch policy {
+	case RetentionFull, RetentionRedacted, RetentionReference:
+		return true
+	}
+	return false
+}
+
+// Retain reduces a resolved record to what policy keeps
+func (s *Sanction) Retain(policy string) {
+	switch policy {
+	case RetentionRedacted:
+		s.DOB, s.Country, s.IMONumber, s.Registration = "", "", "", ""
+		s.Aliases, s.Attributes = nil, nil
+	case RetentionReference:
+		s.Name, s.DOB, s.Country, s.Program, s.IMONumber, s.Registration, s.Category = "", "", "", "", "", "", ""
+		s.Aliases, s.Attributes = nil, nil
+	default:
+		return
+	}
+	s.Retention = policy
+}
+
+// Sanctioned entity types. Each is hashed with its own serialization
+// profile; see psiadapter.SerializeEntity.
+const (
+	EntityIndividual   = "individual"
+	EntityOrganization = "organization"
+	EntityVessel       = "vessel"
+	EntityAircraft     = "aircraft"
+)
+
+// ParseEntityType maps an entity type as written in uploads to one of the
+// Entity* constants. Empty means individual.
+func ParseEntityType(s string) (string, bool) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "", "individual", "person":
+		return EntityIndividual, true
+	case "organization", "organisation", "entity", "company":
+		return EntityOrganization, true
+	case "vessel", "ship":
+		return EntityVessel, true
+	case "aircraft":
+		return EntityAircraft, true
+	}
+	return "", false
+}
+
+// Watchlist categories. Each list belongs to one category, and matches carry
+// the category of their list so they can be routed to the right workflow.
+const (
+	ListCategorySanctions    = "SANCTIONS"
+	ListCategoryPEP          = "PEP"
+	ListCategoryAdverseMedia = "ADVERSE_MEDIA"
+	ListCategoryInternal     = "INTERNAL"
+)
+
+// ListCategories lists the valid watchlist categories
+var ListCategories = []string{ListCategorySanctions, ListCategoryPEP, ListCategoryAdverseMedia, ListCategoryInternal}
+
+// ParseListCategory maps a category as written in uploads and requests to
+// one of the ListCategory* constants. Empty means SANCTIONS.
+func ParseListCategory(s string) (string, bool) {
+	switch strings.ToUpper(strings.TrimSpace(strings.ReplaceAll(s, "-", "_"))) {
+	case "", "SANCTIONS", "SANCTION":
+		return ListCategorySanctions, true
+	case "PEP", "PEPS":
+		return ListCategoryPEP, true
+	case "ADVERSE_MEDIA":
+		return ListCategoryAdverseMedia, true
+	case "INTERNAL":
+		return ListCategoryInternal, true
+	}
+	return "", false
+}
+
+// Sources of the lists a screening runs against
+const (
+	ListSourceRemote = "REMOTE" // Lists of the PSI server
+	ListSourceLocal  = "LOCAL"  // Watchlists hosted by the client backend, screened in-process
+	ListSourceHybrid = "HYBRID" // Both, in one job
+)
+
+// ParseListSource maps a list source as written in requests to one of the
+// ListSource* constants. Empty means REMOTE.
+func ParseListSource(s string) (string, bool) {
+	switch strings.ToUpper(strings.TrimSpace(s)) {
+	case "", ListSourceRemote:
+		return ListSourceRemote, true
+	case ListSourceLocal:
+		return ListSourceLocal, true
+	case ListSourceHybrid:
+		return ListSourceHybrid, true
+	}
+	return "", false
+}
+
+// ParseAliases splits an uploaded aliases cell. Aliases are separated by
+// semicolons or pipes.
+func ParseAliases(cell string) []string {
+	var aliases []string
+	for _, a := range strings.FieldsFunc(cell, func(r rune) bool { return r == ';' || r == '|' }) {
+		if a = strings.TrimSpace(a); a != "" {
+			aliases = append(aliases, a)
+		}
+	}
+	return aliases
+}
+
+// HashValues returns the fields serialization profiles can hash, including
+// custom fields
+func (s *Sanction) HashValues() map[string]string {
+	values := map[string]string{
+		"name":         s.Name,
+		"dob":          s.DOB,
+		"country":      s.Country,
+		"program":      s.Program,
+		"imo":          s.IMONumber,
+		"registration": s.Registration,
+	}
+	for k, v := range s.Attributes {
+		if _, ok := values[k]; !ok {
+			values[k] = v
+		}
+	}
+	return values
+}
+
+// Type hints of custom list fields. They decide how values are normalized
+// before hashing.
+const (
+	FieldTypeText       = "text"       // Trimmed and lowercased
+	FieldTypeName       = "name"       // As text, with inner whitespace collapsed
+	FieldTypeDate       = "date"       // Normalized to YYYY-MM-DD
+	FieldTypeCountry    = "country"    // As text
+	FieldTypeIdentifier = "identifier" // Without spaces, hyphens and dots
+	FieldTypeNumber     = "number"     // Without thousands separators
+)
+
+// FieldTypes lists the valid field type hints
+var FieldTypes = []string{FieldTypeText, FieldTypeName, FieldTypeDate, FieldTypeCountry, FieldTypeIdentifier, FieldTypeNumber}
+
+// BuiltinColumns are the sanction fields every list has. Custom fields
+// cannot reuse their names.
+var BuiltinColumns = []string{"name", "dob", "country", "program", "imo", "registration", "entity_type", "aliases"}
+
+// ListField is a custom column of a sanction list's schema
+type ListField struct {
+	Name   string `json:"name"`             // Key sessions name in their enabled columns
+	Column string `json:"column,omitempty"` // Upload header it is read from; empty means Name
+	Type   string `json:"type"`             // One of the FieldType* hints; empty means text
+}
+
+// Header returns the upload header the field is read from
+func (f ListField) Header() string {
+	if f.Column != "" {
+		return f.Column
+	}
+	return f.Name
+}
+
+// ValidateSchema checks a list schema and defaults its field types. Field
+// names are lowercase identifiers, unique and not built in.
+func ValidateSchema(fields []ListField) error {
+	seen := make(map[string]bool, len(fields))
+	for i := range fields {
+		f := &fields[i]
+		f.Name = strings.ToLower(strings.TrimSpace(f.Name))
+		if f.Name == "" || strings.Trim(f.Name, "abcdefghijklmnopqrstuvwxyz0123456789_") != "" {
+			return fmt.Errorf("field %d: name %q must be letters, digits and underscores", i+1, f.Name)
+		}
+		if slices.Contains(BuiltinColumns, f.Name) {
+			return fmt.Errorf("field %q is built in", f.Name)
+		}
+		if seen[f.Name] {
+			return fmt.Errorf("field %q is defined twice", f.Name)
+		}
+		seen[f.Name] = true
+		if f.Type == "" {
+			f.Type = FieldTypeText
+		}
+		if !slices.Contains(FieldTypes, f.Type) {
+			return fmt.Errorf("field %q: unknown type %q (one of %s)", f.Name, f.Type, strings.Join(FieldTypes, ", "))
+		}
+	}
+	return nil
 }
 
 type SanctionList struct {
 	ID          int64     `json:"id"`
 	Name        string    `json:"name"`
 	Source      string    `json:"source"`
+	Category    string    `json:"category"` // One of the ListCategory* constants
 	Description string    `json:"description"`
 	FilePath    string    `json:"-"` // Internal use only
 	RecordCount int       `json:"recordCount"`
 	Version     int       `json:"version"`
+	Active      bool      `json:"active"` // Inactive lists are stored but not screened against
 	UpdatedAt   time.Time `json:"updatedAt"`
 	CreatedAt   time.Time `json:"createdAt"`
+	// Schema defines the list's custom fields, read from each upload
+	Schema []ListField `json:"schema,omitempty"`
+}
+
+// WatchlistPack is a named bundle of lists that clients screen against by
+// pack ID instead of by list ID. Version increases whenever the pack's lists
+// or their contents change.
+type WatchlistPack struct {
+	ID          string    `json:"id"` // Slug, e.g. global-sanctions
+	Name        string    `json:"name"`
+	Description string    `json:"description"`
+	Version     int       `json:"version"`
+	ListIDs     []int64   `json:"listIds,omitempty"` // Omitted from the client catalog
+	ListCount   int       `json:"listCount"`
+	Categories  []string  `json:"categories"`
+	UpdatedAt   time.Time `json:"updatedAt"`
+	CreatedAt   time.Time `json:"createdAt"`
+}
+
+// Pack update events pushed to subscribers
+const (
+	PackEventUpdated = "updated"
+	PackEventDeleted = "deleted"
+)
+
+// PackUpdate is one message on a pack's update stream
+type PackUpdate struct {
+	Event string        `json:"event"`
+	Pack  WatchlistPack `json:"pack"`
+}
+
+// PackSubscription records a client's subscription to a server pack and the
+// latest version it has seen
+type PackSubscription struct {
+	PackID       string     `json:"packId"`
+	Name         string     `json:"name"`
+	Version      int        `json:"version"`
+	Deleted      bool       `json:"deleted"` // The server removed the pack
+	UpdatedAt    *time.Time `json:"updatedAt,omitempty"`
+	SubscribedAt time.Time  `json:"subscribedAt"`
 }
 
 type Screening struct {
@@ -54,16 +378,124 @@ type Screening struct {
 	Name             string    `json:"name"`
 	CustomerListID   int64     `json:"customerListId"`
 	SanctionListIDs  []int64   `json:"sanctionListIds"`
+	ListSource       string    `json:"listSource"`             // Whether SanctionListIDs are server lists or local watchlists
+	WatchlistIDs     []int64   `json:"watchlistIds,omitempty"` // Local watchlists of a HYBRID screening
 	Status           string    `json:"status"`
 	MatchCount       int       `json:"matchCount"`
 	CustomerCount    int       `json:"customerCount"`
 	SanctionCount    int       `json:"sanctionCount"`
 	WorkerCount      int       `json:"workerCount"`
 	MemoryEstimateMB float64   `json:"memoryEstimateMb"`
+	MemoryLimitGB    float64   `json:"memoryLimitGb"`
 	StartedAt        time.Time `json:"startedAt,omitempty"`
 	FinishedAt       time.Time `json:"finishedAt,omitempty"`
+	Error            string    `json:"error,omitempty"`
 	CreatedBy        int64     `json:"createdBy"`
 	CreatedAt        time.Time `json:"createdAt"`
+	// IdempotencyKey is the Idempotency-Key the screening was started with,
+	// in IdempotencyScope (the user or client who sent it); RequestHash
+	// fingerprints that request's body
+	IdempotencyKey   string `json:"idempotencyKey,omitempty"`
+	IdempotencyScope string `json:"-"`
+	RequestHash      string `json:"-"`
+}
+
+// ScreeningMatches are the raw match hashes of a screening, saved after the
+// intersection with the session settings needed to resolve them again
+type ScreeningMatches struct {
+	ScreeningID     int64             `json:"screeningId"`
+	SessionID       string            `json:"sessionId"`
+	Hashes          []uint64          `json:"hashes"`
+	HashAlgorithm   string            `json:"hashAlgorithm"`
+	HashVersion     int               `json:"hashVersion"`
+	HashSalt        []byte            `json:"-"`
+	ColumnMapping   map[string]string `json:"columnMapping"`
+	EnabledColumns  []string          `json:"enabledColumns"`
+	Transliteration []string          `json:"transliteration"`
+	Resolved        bool              `json:"resolved"`
+	CreatedAt       time.Time         `json:"createdAt"`
+}
+
+// ListMembership is one list a matched entity appears on
+type ListMembership struct {
+	ListID     int64  `json:"listId"`
+	ListSource string `json:"listSource"` // REMOTE or LOCAL
+	Source     string `json:"source"`     // OFAC, UN, EU, ...
+	Category   string `json:"category"`
+	Program    string `json:"program"`
+	SourceRef  string `json:"sourceRef,omitempty"` // The entry in this list
+}
+
+// MatchRecord is one match of a screening to persist. The customer and
+// sanction are inserted with the result when they have no ID yet.
+type MatchRecord struct {
+	Customer *Customer
+	Sanction *Sanction
+	Result   *ScreeningResult
+}
+
+// ScreeningMetrics holds measured phase durations for one screening
+type ScreeningMetrics struct {
+	ID             int64     `json:"id"`
+	ScreeningID    int64     `json:"screeningId"`
+	EncryptionMs   int64     `json:"encryptionMs"`
+	NetworkMs      int64     `json:"networkMs"`
+	IntersectionMs int64     `json:"intersectionMs"`
+	ResolveMs      int64     `json:"resolveMs"`
+	PersistMs      int64     `json:"persistMs"`
+	TotalMs        int64     `json:"totalMs"`
+	RecordCount    int       `json:"recordCount"`
+	HashCollisions int       `json:"hashCollisions"` // Distinct records sharing a hash, found locally or at resolve
+	CreatedAt      time.Time `json:"createdAt"`
+}
+
+// ScreeningTrendPoint aggregates screenings created in one day or week
+type ScreeningTrendPoint struct {
+	Period        string  `json:"period"` // YYYY-MM-DD for days, YYYY-Www for weeks
+	Screenings    int     `json:"screenings"`
+	Matches       int     `json:"matches"`
+	Customers     int     `json:"customers"`
+	MatchRate     float64 `json:"matchRate"` // Matches per screened customer
+	AvgDurationMs float64 `json:"avgDurationMs"`
+}
+
+// ListAnalytics summarizes how a customer or sanction list performs in screenings
+type ListAnalytics struct {
+	ListID            int64   `json:"listId"`
+	Name              string  `json:"name"`
+	Screenings        int     `json:"screenings"`
+	Matches           int     `json:"matches"`
+	Confirmed         int     `json:"confirmed"`
+	FalsePositives    int     `json:"falsePositives"`
+	FalsePositiveRate float64 `json:"falsePositiveRate"` // Share of reviewed matches marked false positive
+	// AvgResolutionSeconds is the mean time from match to disposition
+	AvgResolutionSeconds float64 `json:"avgResolutionSeconds"`
+}
+
+// Customer risk tiers, highest first
+const (
+	RiskTierHigh   = "HIGH"   // A match was confirmed
+	RiskTierMedium = "MEDIUM" // Matches await review, or hits recur across screenings
+	RiskTierLow    = "LOW"    // Every match was cleared as a false positive
+	RiskTierNone   = "NONE"   // Never matched
+)
+
+// CustomerRisk aggregates a customer's matches across all screenings of
+// their list. A customer is identified by list and external ID, so the
+// history survives the list being re-uploaded.
+type CustomerRisk struct {
+	CustomerID     int64    `json:"customerId"` // Latest record of the customer
+	ExternalID     string   `json:"externalId"`
+	Name           string   `json:"name"`
+	ListID         int64    `json:"listId"`
+	Screenings     int      `json:"screenings"` // Screenings in which the customer matched
+	Hits           int      `json:"hits"`
+	Confirmed      int      `json:"confirmed"`
+	FalsePositives int      `json:"falsePositives"`
+	Pending        int      `json:"pending"`  // Awaiting disposition or approval
+	Programs       []string `json:"programs"` // Programs of matches not cleared as false positives
+	Score          int      `json:"score"`
+	Tier           string   `json:"tier"`
 }
 
 type ScreeningResult struct {
@@ -72,11 +504,100 @@ type ScreeningResult struct {
 	CustomerID     int64     `json:"customerId"`
 	SanctionID     int64     `json:"sanctionId"`
 	MatchScore     float64   `json:"matchScore"`
-	Status         string    `json:"status"` // PENDING, CLEARED, FLAGGED
+	Status         string    `json:"status"` // PENDING, PENDING_APPROVAL, CONFIRMED, FALSE_POSITIVE, SUPPRESSED
 	InvestigatorID *int64    `json:"investigatorId,omitempty"`
 	Notes          string    `json:"notes,omitempty"`
 	CreatedAt      time.Time `json:"createdAt"`
 	UpdatedAt      time.Time `json:"updatedAt"`
+	// ProposedBy set the current status; under four-eyes review a
+	// confirmation is PENDING_APPROVAL until a second user approves it
+	ProposedBy string     `json:"proposedBy,omitempty"`
+	ApprovedBy string     `json:"approvedBy,omitempty"`
+	ApprovedAt *time.Time `json:"approvedAt,omitempty"`
+	// SuppressionID is the suppression that marked the match SUPPRESSED
+	SuppressionID *int64 `json:"suppressionId,omitempty"`
+	// ListSource is where the matched entry came from: REMOTE for the PSI
+	// server's lists, LOCAL for the client's watchlists
+	ListSource string `json:"listSource"`
+	// Lists are the lists the matched entity appears on. Matches of one
+	// customer to the same entity on several lists are consolidated into
+	// one result; results from older screenings have none.
+	Lists []ListMembership `json:"lists,omitempty"`
+	// Explanation is recorded when the match is found; results from older
+	// screenings have none
+	Explanation *MatchExplanation `json:"explanation,omitempty"`
+	// Channel is NAME_MATCH for matches on the hashed name, date of birth
+	// and country, DOC_MATCH for matches on a passport or national ID number
+	Channel string `json:"channel"`
+}
+
+// Match channels. A document match is a near-certain hit and is listed
+// ahead of name matches for review.
+const (
+	MatchChannelName     = "NAME_MATCH"
+	MatchChannelDocument = "DOC_MATCH"
+)
+
+// MatchExplanation records why a customer matched a sanction, so an
+// investigator need not re-derive the hashes
+type MatchExplanation struct {
+	Profile         string            `json:"profile"`                   // Serialization profile: "individual", the entity type or "document"
+	Columns         []string          `json:"columns"`                   // Columns hashed under the session schema, in order
+	Fields          []FieldComparison `json:"fields"`                    // One per column
+	MatchedAlias    string            `json:"matchedAlias,omitempty"`    // Sanction alias that matched, if not the primary name
+	Transliteration string            `json:"transliteration,omitempty"` // Name profile, e.g. "v1:cyrillic"
+	HashScheme      string            `json:"hashScheme"`                // e.g. "siphash/v1"
+	Serialized      string            `json:"serialized"`                // The hash input both sides produced
+}
+
+// Retain drops the sanction values a retention policy does not keep: all of
+// them for references, all but the name for redacted records
+func (e *MatchExplanation) Retain(policy string) {
+	if policy != RetentionRedacted && policy != RetentionReference {
+		return
+	}
+	for i := range e.Fields {
+		if policy == RetentionReference || e.Fields[i].Column != "name" {
+			e.Fields[i].Sanction = ""
+		}
+	}
+	if policy == RetentionReference {
+		e.MatchedAlias = ""
+	}
+}
+
+// FieldComparison compares one hashed column of a match after normalization
+type FieldComparison struct {
+	Column   string `json:"column"`
+	Customer string `json:"customer"`
+	Sanction string `json:"sanction"`
+	Equal    bool   `json:"equal"`
+}
+
+// ResultComment is one entry in the investigation thread of a screening
+// result. Replies name the comment they answer in ParentID.
+type ResultComment struct {
+	ID          int64               `json:"id"`
+	ResultID    int64               `json:"resultId"`
+	ParentID    *int64              `json:"parentId,omitempty"`
+	AuthorID    int64               `json:"authorId,omitempty"` // 0 when posted without a signed-in user
+	Author      string              `json:"author"`
+	Body        string              `json:"body"`
+	Attachments []CommentAttachment `json:"attachments"`
+	Replies     []*ResultComment    `json:"replies,omitempty"`
+	CreatedAt   time.Time           `json:"createdAt"`
+}
+
+// CommentAttachment is a file of evidence attached to a result comment
+type CommentAttachment struct {
+	ID          int64     `json:"id"`
+	CommentID   int64     `json:"commentId"`
+	FileName    string    `json:"fileName"`
+	ContentType string    `json:"contentType"`
+	Size        int64     `json:"size"`
+	SHA256      string    `json:"sha256"`
+	FilePath    string    `json:"-"`
+	CreatedAt   time.Time `json:"createdAt"`
 }
 
 type ScreeningResultDetail struct {
@@ -89,7 +610,7 @@ type User struct {
 	ID              int64      `json:"id"`
 	Email           string     `json:"email"`
 	PasswordHash    string     `json:"-"`
-	Role            string     `json:"role"` // admin, compliance, viewer
+	Role            string     `json:"role"` // admin, compliance, approver, viewer
 	TwoFactorSecret string     `json:"-"`
 	Active          bool       `json:"active"`
 	LastLoginAt     *time.Time `json:"lastLoginAt,omitempty"`
@@ -97,6 +618,91 @@ type User struct {
 	UpdatedAt       time.Time  `json:"updatedAt"`
 }
 
+// APIKey identifies a client of the PSI server. The key itself is shown
+// once at creation; only its hash is stored.
+type APIKey struct {
+	ID         int64       `json:"id"`
+	Name       string      `json:"name"`
+	Prefix     string      `json:"prefix"`
+	KeyHash    string      `json:"-"`
+	CreatedAt  time.Time   `json:"createdAt"`
+	LastUsedAt *time.Time  `json:"lastUsedAt,omitempty"`
+	RevokedAt  *time.Time  `json:"revokedAt,omitempty"`
+	Quota      APIKeyQuota `json:"quota"`
+}
+
+// APIKeyQuota limits what one API key may do on the PSI server. Zero means
+// unlimited.
+type APIKeyQuota struct {
+	MaxSessionsPerDay        int `json:"maxSessionsPerDay"`        // Sessions started per UTC day
+	MaxCiphertextsPerRequest int `json:"maxCiphertextsPerRequest"` // Ciphertexts in one intersect request
+	MaxConcurrentSessions    int `json:"maxConcurrentSessions"`    // Live sessions at once
+	// Ciphertexts a session must have intersected before it may resolve;
+	// 0 applies the server's PSI_MIN_RESOLVE_CIPHERTEXTS
+	MinResolveCiphertexts int `json:"minResolveCiphertexts"`
+}
+
+// Session event types recorded by the PSI server
+const (
+	SessionEventInit      = "init"
+	SessionEventIntersect = "intersect"
+	SessionEventResolve   = "resolve"
+	SessionEventClose     = "close"
+	SessionEventExpire    = "expire" // Ended by an admin, or "idle" when the client vanished
+	SessionEventError     = "error"
+)
+
+// SessionEvent is one PSI phase of a session, kept for forensics
+type SessionEvent struct {
+	ID              int64     `json:"id"`
+	SessionID       string    `json:"sessionId"` // Empty for inits that failed before a session existed
+	Event           string    `json:"event"`
+	Client          string    `json:"client"`   // Remote host
+	APIKeyID        int64     `json:"apiKeyId"` // 0 when the request carried no valid API key
+	Batch           int       `json:"batch"`
+	CiphertextCount int       `json:"ciphertextCount"`
+	MatchCount      int       `json:"matchCount"`
+	DurationMs      int64     `json:"durationMs"`
+	Detail          string    `json:"detail,omitempty"`
+	CreatedAt       time.Time `json:"createdAt"`
+}
+
+// UsageRecord is one API key's PSI usage in one month
+type UsageRecord struct {
+	Month         string  `json:"month"` // YYYY-MM
+	APIKeyID      int64   `json:"apiKeyId"`
+	APIKeyName    string  `json:"apiKeyName"`
+	Sessions      int     `json:"sessions"`
+	Intersections int     `json:"intersections"`
+	Matches       int     `json:"matches"`
+	Ciphertexts   int     `json:"ciphertexts"`
+	CPUSeconds    float64 `json:"cpuSeconds"` // Server time spent building trees and intersecting
+	Errors        int     `json:"errors"`
+}
+
+// Suppression marks a customer and sanction pair as a known false positive.
+// Until it expires or is revoked, screenings record the identical match as
+// SUPPRESSED rather than PENDING. Fingerprint covers both records, so a
+// change to either brings the match back for review.
+type Suppression struct {
+	ID                 int64      `json:"id"`
+	Fingerprint        string     `json:"fingerprint"`
+	CustomerListID     int64      `json:"customerListId"`
+	CustomerExternalID string     `json:"customerExternalId"`
+	SanctionSource     string     `json:"sanctionSource"`
+	SanctionName       string     `json:"sanctionName"`
+	ResultID           int64      `json:"resultId"` // The false positive it was created from
+	Reason             string     `json:"reason,omitempty"`
+	CreatedBy          string     `json:"createdBy,omitempty"`
+	CreatedAt          time.Time  `json:"createdAt"`
+	ExpiresAt          time.Time  `json:"expiresAt"`
+	RevokedBy          string     `json:"revokedBy,omitempty"`
+	RevokedAt          *time.Time `json:"revokedAt,omitempty"`
+	Hits               int        `json:"hits"` // Matches suppressed so far
+	LastHitAt          *time.Time `json:"lastHitAt,omitempty"`
+	Active             bool       `json:"active"`
+}
+
 type AuditLog struct {
 	ID         int64                  `json:"id"`
 	ActorID    int64                  `json:"actorId"`
@@ -131,17 +737,97 @@ type LoginResponse struct {
 	User         User   `json:"user"`
 }
 
+// StartScreeningRequest starts a screening. ColumnMapping maps fields (id,
+// name, dob, country, entity_type, registration, imo_number) to CSV headers;
+// keys prefixed with an entity type, e.g. "organization.name", apply only to
+// rows of that type. Categories adds every server list in those categories
+// (SANCTIONS, PEP, ADVERSE_MEDIA, INTERNAL) to SanctionListIDs and PackIDs
+// adds the lists of those server packs. A screening naming none of them uses
+// the client's pack subscriptions, if any. Transliteration names the scripts
+// romanized in names (see package translit); nil uses PSI_TRANSLITERATION.
+// ListSource LOCAL screens against the client's own watchlists instead: the
+// list IDs and categories select those, and packs are not available.
+// ListSource HYBRID screens against both in one job: SanctionListIDs,
+// categories and packs select the server lists and WatchlistIDs and
+// categories the local watchlists.
 type StartScreeningRequest struct {
 	Name            string            `json:"name"`
 	CustomerListID  int64             `json:"customerListId"`
 	SanctionListIDs []int64           `json:"sanctionListIds"`
+	Categories      []string          `json:"categories,omitempty"`
+	PackIDs         []string          `json:"packIds,omitempty"`
 	ColumnMapping   map[string]string `json:"columnMapping"`
+	Workers         int               `json:"workers,omitempty"`     // 0 uses the server default
+	MaxMemoryGB     float64           `json:"maxMemoryGb,omitempty"` // 0 uses the server limit
+	Transliteration []string          `json:"transliteration,omitempty"`
+	ListSource      string            `json:"listSource,omitempty"` // REMOTE (default), LOCAL or HYBRID
+	WatchlistIDs    []int64           `json:"watchlistIds,omitempty"`
 }
 
 type StartScreeningResponse struct {
 	JobID string `json:"jobId"`
 }
 
+// EstimateScreeningRequest selects the lists of a screening to estimate
+type EstimateScreeningRequest struct {
+	CustomerListID  int64   `json:"customerListId"`
+	SanctionListIDs []int64 `json:"sanctionListIds"`
+}
+
+// ScreeningEstimate predicts the cost of a screening without running it
+type ScreeningEstimate struct {
+	CustomerRecords  int      `json:"customerRecords"`
+	SanctionRecords  int      `json:"sanctionRecords"`
+	Batches          int      `json:"batches"`
+	MemoryGB         float64  `json:"memoryGb"`
+	MaxMemoryGB      float64  `json:"maxMemoryGb"`
+	FitsMemory       bool     `json:"fitsMemory"`
+	CiphertextBytes  int64    `json:"ciphertextBytes"`
+	DurationSeconds  *float64 `json:"durationSeconds"`  // Nil until a screening has completed
+	ThroughputSample int      `json:"throughputSample"` // Past screenings the duration is based on
+}
+
+// PreflightScreeningRequest screens a random sample of a customer list with
+// the settings of a screening before the full run is started
+type PreflightScreeningRequest struct {
+	StartScreeningRequest
+	SampleSize int `json:"sampleSize,omitempty"` // 0 samples 100 records
+}
+
+// PreflightWarning is a problem with a screening's mapping found in its
+// sample, such as dates in a format the sanction lists do not use
+type PreflightWarning struct {
+	Code     string   `json:"code"`
+	Field    string   `json:"field,omitempty"`
+	Message  string   `json:"message"`
+	Affected int      `json:"affected"`           // Records showing the problem
+	Examples []string `json:"examples,omitempty"` // A few offending values
+}
+
+// ScreeningPreflight is the outcome of screening a sample of a customer list
+type ScreeningPreflight struct {
+	CustomerRecords  int                `json:"customerRecords"` // Records the mapping loads from the list
+	SampleSize       int                `json:"sampleSize"`
+	Matches          int                `json:"matches"`          // Sampled customers matching a sanction
+	MatchedCustomers []string           `json:"matchedCustomers"` // Their external IDs
+	EnabledColumns   []string           `json:"enabledColumns"`
+	Warnings         []PreflightWarning `json:"warnings"`
+}
+
+// UploadQuality reports how an uploaded list's text was read
+type UploadQuality struct {
+	Encoding string          `json:"encoding"` // Detected encoding, converted to UTF-8 if different
+	Warnings []UploadWarning `json:"warnings"`
+}
+
+// UploadWarning is a problem found while reading an uploaded list
+type UploadWarning struct {
+	Code     string `json:"code"`
+	Message  string `json:"message"`
+	Affected int    `json:"affected"`       // Rows showing the problem
+	Rows     []int  `json:"rows,omitempty"` // The first of those rows, counting the header as 1
+}
+
 type UpdateMatchRequest struct {
 	Status string `json:"status"`
 	Notes  string `json:"notes,omitempty"`
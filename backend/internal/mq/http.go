@@ -0,0 +1,281 @@
+package mq
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// FrameAllowance is the part of a message kept for the frame header, so a
+// request body may take up the server's max payload less this
+const FrameAllowance = 8 << 10
+
+// A message carries one HTTP request or response frame: a JSON header, a
+// newline, then the body. Requests fit in one message; responses, which go
+// to the requester's own inbox, may be split into parts.
+type requestHeader struct {
+	Method string      `json:"method"`
+	Path   string      `json:"path"` // Path and query
+	Header http.Header `json:"header,omitempty"`
+}
+
+type responseHeader struct {
+	Part   int         `json:"part"`
+	Parts  int         `json:"parts"`
+	Status int         `json:"status,omitempty"` // Set on the first part
+	Header http.Header `json:"header,omitempty"` // Set on the first part
+}
+
+func encodeFrame(header interface{}, body []byte) ([]byte, error) {
+	data, err := json.Marshal(header)
+	if err != nil {
+		return nil, err
+	}
+	data = append(data, '\n')
+	return append(data, body...), nil
+}
+
+func decodeFrame(data []byte, header interface{}) ([]byte, error) {
+	head, body, ok := bytes.Cut(data, []byte{'\n'})
+	if !ok {
+		return nil, errors.New("frame has no header")
+	}
+	if err := json.Unmarshal(head, header); err != nil {
+		return nil, fmt.Errorf("invalid frame header: %w", err)
+	}
+	return body, nil
+}
+
+// RoundTripper sends HTTP requests as messages on Subject and waits up to
+// Timeout for the reply. It connects on first use and again after the
+// connection is lost.
+type RoundTripper struct {
+	URL     string
+	Name    string // Client name shown in the NATS server's monitoring
+	Subject string
+	Timeout time.Duration
+
+	mu   sync.Mutex
+	conn *Conn
+}
+
+// Conn returns the open connection, dialing one if there is none
+func (t *RoundTripper) Conn(ctx context.Context) (*Conn, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.conn != nil && t.conn.Err() == nil {
+		return t.conn, nil
+	}
+	conn, err := Dial(ctx, t.URL, t.Name)
+	if err != nil {
+		return nil, err
+	}
+	t.conn = conn
+	return conn, nil
+}
+
+// MaxBody is the largest request body the queue carries
+func (t *RoundTripper) MaxBody(ctx context.Context) (int, error) {
+	conn, err := t.Conn(ctx)
+	if err != nil {
+		return 0, err
+	}
+	return conn.MaxPayload() - FrameAllowance, nil
+}
+
+func (t *RoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	var body []byte
+	if req.Body != nil {
+		var err error
+		body, err = io.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+	}
+	frame, err := encodeFrame(requestHeader{Method: req.Method, Path: req.URL.RequestURI(), Header: req.Header}, body)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(req.Context(), t.Timeout)
+	defer cancel()
+	conn, err := t.Conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+	inbox := NewInbox()
+	sub, err := conn.Subscribe(inbox, "")
+	if err != nil {
+		return nil, err
+	}
+	defer sub.Unsubscribe()
+	if err := conn.Publish(t.Subject, inbox, frame); err != nil {
+		return nil, err
+	}
+
+	var (
+		first   responseHeader
+		payload bytes.Buffer
+	)
+	for part := 0; part == 0 || part < first.Parts; part++ {
+		var msg *Msg
+		select {
+		case msg = <-sub.C():
+		case <-conn.Done():
+			return nil, conn.Err()
+		case <-ctx.Done():
+			if req.Context().Err() == nil {
+				return nil, fmt.Errorf("mq: no reply on %s within %s; is the PSI server consuming the queue?", t.Subject, t.Timeout)
+			}
+			return nil, ctx.Err()
+		}
+		var header responseHeader
+		data, err := decodeFrame(msg.Data, &header)
+		if err != nil {
+			return nil, fmt.Errorf("mq: %w", err)
+		}
+		if header.Part != part {
+			return nil, fmt.Errorf("mq: reply part %d arrived as part %d", header.Part, part)
+		}
+		if part == 0 {
+			first = header
+		}
+		payload.Write(data)
+	}
+
+	return &http.Response{
+		Status:        http.StatusText(first.Status),
+		StatusCode:    first.Status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        first.Header,
+		Body:          io.NopCloser(&payload),
+		ContentLength: int64(payload.Len()),
+		Request:       req,
+	}, nil
+}
+
+// Serve answers the HTTP requests published on subject with handler until
+// ctx ends or the connection is lost. Servers sharing a queue group split
+// the requests between them; each handles workers requests at a time and
+// leaves the rest queued.
+func Serve(ctx context.Context, conn *Conn, subject, queue string, workers int, handler http.Handler) error {
+	sub, err := conn.Subscribe(subject, queue)
+	if err != nil {
+		return err
+	}
+	defer sub.Unsubscribe()
+
+	var wg sync.WaitGroup
+	for i := 0; i < max(1, workers); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case msg := <-sub.C():
+					serveMsg(ctx, conn, msg, handler)
+				case <-ctx.Done():
+					return
+				case <-conn.Done():
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	return conn.Err()
+}
+
+// serveMsg handles one request message and publishes the response to its
+// reply subject
+func serveMsg(ctx context.Context, conn *Conn, msg *Msg, handler http.Handler) {
+	if msg.Reply == "" {
+		log.Printf("Warning: dropping queued request on %s without a reply subject", msg.Subject)
+		return
+	}
+	rw := &bufferedResponse{header: make(http.Header)}
+	var header requestHeader
+	body, err := decodeFrame(msg.Data, &header)
+	if err != nil {
+		rw.WriteHeader(http.StatusBadRequest)
+		fmt.Fprintf(rw, "Invalid queued request: %v", err)
+	} else if r, err := http.NewRequestWithContext(ctx, header.Method, "http://mq"+header.Path, bytes.NewReader(body)); err != nil {
+		rw.WriteHeader(http.StatusBadRequest)
+		fmt.Fprintf(rw, "Invalid queued request: %v", err)
+	} else {
+		if header.Header != nil {
+			r.Header = header.Header
+		}
+		r.RequestURI = header.Path
+		// Session tokens are bound to the caller's host; every queued
+		// request comes from the queue
+		r.RemoteAddr = "mq:0"
+		handler.ServeHTTP(rw, r)
+	}
+	if err := publishResponse(conn, msg.Reply, rw); err != nil {
+		log.Printf("Warning: failed to reply to queued %s %s: %v", header.Method, header.Path, err)
+	}
+}
+
+// publishResponse sends a response in as many parts as the server's max
+// payload requires
+func publishResponse(conn *Conn, reply string, rw *bufferedResponse) error {
+	if rw.status == 0 {
+		rw.status = http.StatusOK
+	}
+	body := rw.body.Bytes()
+	partSize := conn.MaxPayload() - FrameAllowance
+	if partSize <= 0 {
+		return fmt.Errorf("server max payload of %d bytes is too small", conn.MaxPayload())
+	}
+	parts := max(1, (len(body)+partSize-1)/partSize)
+	for part := 0; part < parts; part++ {
+		header := responseHeader{Part: part, Parts: parts}
+		if part == 0 {
+			header.Status, header.Header = rw.status, rw.header
+		}
+		frame, err := encodeFrame(header, body[min(part*partSize, len(body)):min((part+1)*partSize, len(body))])
+		if err != nil {
+			return err
+		}
+		if err := conn.Publish(reply, "", frame); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// bufferedResponse collects a handler's response
+type bufferedResponse struct {
+	header http.Header
+	status int
+	body   bytes.Buffer
+}
+
+func (w *bufferedResponse) Header() http.Header {
+	return w.header
+}
+
+func (w *bufferedResponse) WriteHeader(status int) {
+	if w.status == 0 {
+		w.status = status
+	}
+}
+
+func (w *bufferedResponse) Write(p []byte) (int, error) {
+	w.WriteHeader(http.StatusOK)
+	return w.body.Write(p)
+}
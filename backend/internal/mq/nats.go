@@ -0,0 +1,377 @@
+// Package mq carries PSI requests over a NATS message queue instead of
+// direct HTTP connections. It speaks the NATS core text protocol itself
+// (CONNECT, PUB, SUB, MSG, PING/PONG), which is all the queue mode needs.
+// TLS and JetStream persistence are not supported: a message lost with a
+// connection fails its request, which the client retries like any other
+// failed request.
+package mq
+
+import (
+	"bufio"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultPort is the port of NATS URLs without one
+const DefaultPort = "4222"
+
+// subBuffer is how many received messages a subscription holds before the
+// connection stops reading, leaving the rest queued at the server
+const subBuffer = 64
+
+var (
+	ErrClosed     = errors.New("mq: connection closed")
+	ErrMaxPayload = errors.New("mq: message exceeds the server's max payload")
+)
+
+// Msg is a message received on a subscription
+type Msg struct {
+	Subject string
+	Reply   string // Subject to answer on; empty if no answer is expected
+	Data    []byte
+}
+
+// serverInfo is the part of the server's INFO the client uses
+type serverInfo struct {
+	ServerID     string `json:"server_id"`
+	MaxPayload   int    `json:"max_payload"`
+	TLSRequired  bool   `json:"tls_required"`
+	AuthRequired bool   `json:"auth_required"`
+}
+
+// Conn is a connection to a NATS server. Its methods are safe for
+// concurrent use. A Conn is not reconnected: once Done is closed, dial a
+// new one.
+type Conn struct {
+	nc   net.Conn
+	info serverInfo
+
+	wmu sync.Mutex // Serializes writes
+	bw  *bufio.Writer
+
+	mu      sync.Mutex
+	subs    map[int64]*Subscription
+	nextSID int64
+	err     error // Why the connection closed
+	done    chan struct{}
+}
+
+// Dial connects to the NATS server at rawURL, nats://[user:pass@]host[:port]
+// or nats://token@host[:port]. name identifies the client in the server's
+// monitoring.
+func Dial(ctx context.Context, rawURL, name string) (*Conn, error) {
+	if !strings.Contains(rawURL, "://") {
+		rawURL = "nats://" + rawURL
+	}
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("mq: invalid URL: %w", err)
+	}
+	if u.Scheme != "nats" {
+		return nil, fmt.Errorf("mq: unsupported scheme %q (use nats://)", u.Scheme)
+	}
+	addr := u.Host
+	if u.Port() == "" {
+		addr = net.JoinHostPort(u.Hostname(), DefaultPort)
+	}
+
+	var dialer net.Dialer
+	nc, err := dialer.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("mq: connect to %s: %w", addr, err)
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		nc.SetDeadline(deadline)
+	}
+	c := &Conn{
+		nc:   nc,
+		bw:   bufio.NewWriter(nc),
+		subs: make(map[int64]*Subscription),
+		done: make(chan struct{}),
+	}
+	br := bufio.NewReaderSize(nc, 64<<10)
+	if err := c.handshake(br, u, name); err != nil {
+		nc.Close()
+		return nil, err
+	}
+	nc.SetDeadline(time.Time{})
+	go c.readLoop(br)
+	return c, nil
+}
+
+// handshake reads the server's INFO, sends CONNECT and waits for the PONG
+// answering a PING, which confirms the server accepted the connection
+func (c *Conn) handshake(br *bufio.Reader, u *url.URL, name string) error {
+	line, err := readLine(br)
+	if err != nil {
+		return fmt.Errorf("mq: read server info: %w", err)
+	}
+	op, args := splitOp(line)
+	if op != "INFO" {
+		return fmt.Errorf("mq: expected INFO, got %q", op)
+	}
+	if err := json.Unmarshal([]byte(args), &c.info); err != nil {
+		return fmt.Errorf("mq: invalid server info: %w", err)
+	}
+	if c.info.TLSRequired {
+		return errors.New("mq: the server requires TLS, which is not supported")
+	}
+
+	connect := map[string]interface{}{
+		"verbose":  false,
+		"pedantic": false,
+		"lang":     "go",
+		"version":  "flare",
+		"protocol": 1,
+		"name":     name,
+	}
+	if u.User != nil {
+		if pass, ok := u.User.Password(); ok {
+			connect["user"], connect["pass"] = u.User.Username(), pass
+		} else {
+			connect["auth_token"] = u.User.Username()
+		}
+	}
+	payload, err := json.Marshal(connect)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(c.bw, "CONNECT %s\r\nPING\r\n", payload)
+	if err := c.bw.Flush(); err != nil {
+		return fmt.Errorf("mq: send connect: %w", err)
+	}
+	for {
+		line, err := readLine(br)
+		if err != nil {
+			return fmt.Errorf("mq: read connect reply: %w", err)
+		}
+		switch op, args := splitOp(line); op {
+		case "PONG":
+			return nil
+		case "-ERR":
+			return fmt.Errorf("mq: server refused connection: %s", strings.Trim(args, "' "))
+		}
+	}
+}
+
+// MaxPayload is the largest message the server accepts
+func (c *Conn) MaxPayload() int {
+	return c.info.MaxPayload
+}
+
+// Done is closed once the connection is closed or lost
+func (c *Conn) Done() <-chan struct{} {
+	return c.done
+}
+
+// Err returns why the connection closed, or nil while it is open
+func (c *Conn) Err() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.err
+}
+
+// Close closes the connection
+func (c *Conn) Close() error {
+	c.close(ErrClosed)
+	return nil
+}
+
+func (c *Conn) close(err error) {
+	c.mu.Lock()
+	if c.err != nil {
+		c.mu.Unlock()
+		return
+	}
+	c.err = err
+	close(c.done)
+	c.mu.Unlock()
+	c.nc.Close()
+}
+
+// Publish sends data on subject. reply, if set, is the subject the
+// receiver answers on.
+func (c *Conn) Publish(subject, reply string, data []byte) error {
+	if c.info.MaxPayload > 0 && len(data) > c.info.MaxPayload {
+		return fmt.Errorf("%w (%d bytes, limit %d)", ErrMaxPayload, len(data), c.info.MaxPayload)
+	}
+	if reply != "" {
+		reply += " "
+	}
+	return c.write(func(w *bufio.Writer) {
+		fmt.Fprintf(w, "PUB %s %s%d\r\n", subject, reply, len(data))
+		w.Write(data)
+		w.WriteString("\r\n")
+	})
+}
+
+func (c *Conn) write(fn func(*bufio.Writer)) error {
+	if err := c.Err(); err != nil {
+		return err
+	}
+	c.wmu.Lock()
+	defer c.wmu.Unlock()
+	fn(c.bw)
+	if err := c.bw.Flush(); err != nil {
+		c.close(fmt.Errorf("mq: write: %w", err))
+		return c.Err()
+	}
+	return nil
+}
+
+// Subscription receives the messages published on a subject
+type Subscription struct {
+	c   *Conn
+	sid int64
+	ch  chan *Msg
+	// stop is closed by Unsubscribe, so a full subscription does not block
+	// the connection's reader forever
+	stop     chan struct{}
+	stopOnce sync.Once
+}
+
+// Subscribe receives the messages published on subject, which may end in
+// the wildcard ">". Subscribers sharing a non-empty queue group each get a
+// share of the messages rather than all of them.
+func (c *Conn) Subscribe(subject, queue string) (*Subscription, error) {
+	c.mu.Lock()
+	c.nextSID++
+	sub := &Subscription{c: c, sid: c.nextSID, ch: make(chan *Msg, subBuffer), stop: make(chan struct{})}
+	c.subs[sub.sid] = sub
+	c.mu.Unlock()
+
+	if queue != "" {
+		queue += " "
+	}
+	err := c.write(func(w *bufio.Writer) {
+		fmt.Fprintf(w, "SUB %s %s%d\r\n", subject, queue, sub.sid)
+	})
+	if err != nil {
+		sub.Unsubscribe()
+		return nil, err
+	}
+	return sub, nil
+}
+
+// C delivers the subscription's messages. It is never closed; watch the
+// connection's Done as well.
+func (s *Subscription) C() <-chan *Msg {
+	return s.ch
+}
+
+// Unsubscribe stops the subscription
+func (s *Subscription) Unsubscribe() error {
+	s.stopOnce.Do(func() { close(s.stop) })
+	s.c.mu.Lock()
+	_, ok := s.c.subs[s.sid]
+	delete(s.c.subs, s.sid)
+	s.c.mu.Unlock()
+	if !ok {
+		return nil
+	}
+	return s.c.write(func(w *bufio.Writer) {
+		fmt.Fprintf(w, "UNSUB %d\r\n", s.sid)
+	})
+}
+
+// NewInbox returns a unique subject to receive replies on
+func NewInbox() string {
+	var b [12]byte
+	rand.Read(b[:])
+	return "_INBOX." + hex.EncodeToString(b[:])
+}
+
+// readLoop reads server operations until the connection fails
+func (c *Conn) readLoop(br *bufio.Reader) {
+	for {
+		line, err := readLine(br)
+		if err != nil {
+			c.close(fmt.Errorf("mq: connection lost: %w", err))
+			return
+		}
+		op, args := splitOp(line)
+		switch op {
+		case "MSG":
+			msg, sid, err := readMsg(br, args)
+			if err != nil {
+				c.close(fmt.Errorf("mq: %w", err))
+				return
+			}
+			c.deliver(sid, msg)
+		case "PING":
+			c.write(func(w *bufio.Writer) { w.WriteString("PONG\r\n") })
+		case "-ERR":
+			c.close(fmt.Errorf("mq: server error: %s", strings.Trim(args, "' ")))
+			return
+		}
+		// +OK, PONG and INFO updates need no action
+	}
+}
+
+// deliver hands msg to its subscription. A subscription whose buffer is
+// full holds up the reader, so the server queues further messages.
+func (c *Conn) deliver(sid int64, msg *Msg) {
+	c.mu.Lock()
+	sub := c.subs[sid]
+	c.mu.Unlock()
+	if sub == nil {
+		return
+	}
+	select {
+	case sub.ch <- msg:
+	case <-sub.stop:
+	case <-c.done:
+	}
+}
+
+// readMsg reads the payload of a MSG whose arguments are
+// "<subject> <sid> [reply] <size>"
+func readMsg(br *bufio.Reader, args string) (*Msg, int64, error) {
+	fields := strings.Fields(args)
+	if len(fields) != 3 && len(fields) != 4 {
+		return nil, 0, fmt.Errorf("malformed MSG %q", args)
+	}
+	sid, err := strconv.ParseInt(fields[1], 10, 64)
+	if err != nil {
+		return nil, 0, fmt.Errorf("malformed MSG sid %q", fields[1])
+	}
+	size, err := strconv.Atoi(fields[len(fields)-1])
+	if err != nil || size < 0 {
+		return nil, 0, fmt.Errorf("malformed MSG size %q", fields[len(fields)-1])
+	}
+	msg := &Msg{Subject: fields[0]}
+	if len(fields) == 4 {
+		msg.Reply = fields[2]
+	}
+	data := make([]byte, size+2) // Payload and CRLF
+	if _, err := io.ReadFull(br, data); err != nil {
+		return nil, 0, err
+	}
+	msg.Data = data[:size]
+	return msg, sid, nil
+}
+
+func readLine(br *bufio.Reader) (string, error) {
+	line, err := br.ReadString('\n')
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimRight(line, "\r\n"), nil
+}
+
+// splitOp splits a protocol line into its operation and arguments
+func splitOp(line string) (string, string) {
+	op, args, _ := strings.Cut(line, " ")
+	return strings.ToUpper(op), args
+}
@@ -0,0 +1,305 @@
+package mq
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeServer is an in-process NATS server speaking the subset of the
+// protocol Conn uses. Every subscriber of a subject gets its messages;
+// queue groups are ignored.
+type fakeServer struct {
+	ln         net.Listener
+	maxPayload int
+
+	mu       sync.Mutex
+	conns    map[*fakeConn]bool
+	connects []map[string]interface{} // CONNECT options, in order
+	pongs    chan struct{}            // A PONG from a client
+}
+
+type fakeConn struct {
+	nc   net.Conn
+	wmu  sync.Mutex
+	subs map[string]string // Subject by sid; guarded by fakeServer.mu
+}
+
+// newFakeServer starts a server accepting messages of up to maxPayload bytes
+func newFakeServer(t *testing.T, maxPayload int) *fakeServer {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &fakeServer{ln: ln, maxPayload: maxPayload, conns: make(map[*fakeConn]bool), pongs: make(chan struct{}, 8)}
+	t.Cleanup(func() {
+		ln.Close()
+		s.drop()
+	})
+	go func() {
+		for {
+			nc, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			fc := &fakeConn{nc: nc, subs: make(map[string]string)}
+			s.mu.Lock()
+			s.conns[fc] = true
+			s.mu.Unlock()
+			go s.serve(fc)
+		}
+	}()
+	return s
+}
+
+func (s *fakeServer) url() string {
+	return "nats://" + s.ln.Addr().String()
+}
+
+func (fc *fakeConn) send(format string, args ...interface{}) {
+	fc.wmu.Lock()
+	defer fc.wmu.Unlock()
+	fmt.Fprintf(fc.nc, format, args...)
+}
+
+func (s *fakeServer) serve(fc *fakeConn) {
+	defer func() {
+		fc.nc.Close()
+		s.mu.Lock()
+		delete(s.conns, fc)
+		s.mu.Unlock()
+	}()
+	fc.send("INFO {\"server_id\":\"fake\",\"max_payload\":%d}\r\n", s.maxPayload)
+
+	br := bufio.NewReader(fc.nc)
+	for {
+		line, err := readLine(br)
+		if err != nil {
+			return
+		}
+		op, args := splitOp(line)
+		switch op {
+		case "CONNECT":
+			var opts map[string]interface{}
+			if err := json.Unmarshal([]byte(args), &opts); err != nil {
+				fc.send("-ERR 'invalid connect'\r\n")
+				return
+			}
+			s.mu.Lock()
+			s.connects = append(s.connects, opts)
+			s.mu.Unlock()
+		case "PING":
+			fc.send("PONG\r\n")
+		case "PONG":
+			select {
+			case s.pongs <- struct{}{}:
+			default:
+			}
+		case "SUB":
+			fields := strings.Fields(args)
+			s.mu.Lock()
+			fc.subs[fields[len(fields)-1]] = fields[0]
+			s.mu.Unlock()
+		case "UNSUB":
+			s.mu.Lock()
+			delete(fc.subs, args)
+			s.mu.Unlock()
+		case "PUB":
+			fields := strings.Fields(args)
+			size, _ := strconv.Atoi(fields[len(fields)-1])
+			data := make([]byte, size+2)
+			if _, err := io.ReadFull(br, data); err != nil {
+				return
+			}
+			reply := ""
+			if len(fields) == 3 {
+				reply = fields[1] + " "
+			}
+			s.route(fields[0], reply, data[:size])
+		}
+	}
+}
+
+// route delivers a published message to every matching subscription
+func (s *fakeServer) route(subject, reply string, data []byte) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for fc := range s.conns {
+		for sid, sub := range fc.subs {
+			if sub == subject || strings.HasSuffix(sub, ">") && strings.HasPrefix(subject, strings.TrimSuffix(sub, ">")) {
+				fc.send("MSG %s %s %s%d\r\n%s\r\n", subject, sid, reply, len(data), data)
+			}
+		}
+	}
+}
+
+// subscribed reports whether a client subscribes to subject
+func (s *fakeServer) subscribed(subject string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for fc := range s.conns {
+		for _, sub := range fc.subs {
+			if sub == subject {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// ping sends PING to every client
+func (s *fakeServer) ping() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for fc := range s.conns {
+		fc.send("PING\r\n")
+	}
+}
+
+// drop closes every client connection, as a server restart would
+func (s *fakeServer) drop() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for fc := range s.conns {
+		fc.nc.Close()
+		delete(s.conns, fc)
+	}
+}
+
+func dialFake(t *testing.T, rawURL string) *Conn {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	conn, err := Dial(ctx, rawURL, "test")
+	if err != nil {
+		t.Fatalf("Dial: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+func TestPublish(t *testing.T) {
+	s := newFakeServer(t, 64)
+	conn := dialFake(t, strings.Replace(s.url(), "nats://", "nats://secret@", 1))
+
+	s.mu.Lock()
+	opts := s.connects[0]
+	s.mu.Unlock()
+	if opts["name"] != "test" || opts["auth_token"] != "secret" {
+		t.Errorf("CONNECT options %v, want name test and the URL's token", opts)
+	}
+	if conn.MaxPayload() != 64 {
+		t.Errorf("MaxPayload = %d, want 64", conn.MaxPayload())
+	}
+
+	sub, err := conn.Subscribe("greet.>", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := conn.Publish("greet.bank", "_INBOX.x", []byte("hello\r\nworld")); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case msg := <-sub.C():
+		if msg.Subject != "greet.bank" || msg.Reply != "_INBOX.x" || string(msg.Data) != "hello\r\nworld" {
+			t.Errorf("got %q from %s replying to %s", msg.Data, msg.Subject, msg.Reply)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("no message delivered")
+	}
+
+	if err := conn.Publish("greet.bank", "", make([]byte, 65)); !errors.Is(err, ErrMaxPayload) {
+		t.Errorf("oversized publish: err = %v, want ErrMaxPayload", err)
+	}
+}
+
+func TestPingPong(t *testing.T) {
+	s := newFakeServer(t, 1<<20)
+	conn := dialFake(t, s.url())
+
+	// A client that does not answer is disconnected by a real server
+	for i := 0; i < 3; i++ {
+		s.ping()
+		select {
+		case <-s.pongs:
+		case <-time.After(2 * time.Second):
+			t.Fatalf("ping %d not answered", i)
+		}
+	}
+	if err := conn.Err(); err != nil {
+		t.Errorf("connection closed after pings: %v", err)
+	}
+}
+
+func TestRoundTripperReconnects(t *testing.T) {
+	s := newFakeServer(t, 1<<20)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, "%s %s", r.Method, r.URL.Path)
+	})
+	// serve consumes the queue on a new connection, as the PSI server does
+	// after losing one
+	serve := func() {
+		conn := dialFake(t, s.url())
+		go Serve(ctx, conn, "flare.psi", "psi", 1, handler)
+		deadline := time.Now().Add(2 * time.Second)
+		for !s.subscribed("flare.psi") {
+			if time.Now().After(deadline) {
+				t.Fatal("server did not subscribe")
+			}
+			time.Sleep(5 * time.Millisecond)
+		}
+	}
+
+	rt := &RoundTripper{URL: s.url(), Name: "client", Subject: "flare.psi", Timeout: 2 * time.Second}
+	client := &http.Client{Transport: rt}
+	get := func(path string) string {
+		t.Helper()
+		resp, err := client.Get("http://mq" + path)
+		if err != nil {
+			t.Fatalf("GET %s: %v", path, err)
+		}
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(resp.Body)
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("GET %s: status %d", path, resp.StatusCode)
+		}
+		return string(body)
+	}
+
+	serve()
+	if got := get("/capabilities"); got != "GET /capabilities" {
+		t.Errorf("first reply %q", got)
+	}
+	first, err := rt.Conn(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s.drop()
+	select {
+	case <-first.Done():
+	case <-time.After(2 * time.Second):
+		t.Fatal("lost connection not noticed")
+	}
+	serve()
+	if got := get("/session/init"); got != "GET /session/init" {
+		t.Errorf("reply after reconnecting %q", got)
+	}
+	if second, err := rt.Conn(ctx); err != nil || second == first {
+		t.Errorf("round tripper did not dial a new connection (err %v)", err)
+	}
+}
@@ -0,0 +1,216 @@
+// Package profiling serves the pprof endpoints and captures CPU and heap
+// profiles of one screening on request.
+//
+// An operator arms the recorder; the next screening to start then runs
+// under the CPU profiler and writes a heap profile at each phase boundary.
+// Profiles are stored under <dir>/<job ID>/.
+package profiling
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/http/pprof"
+	"os"
+	"path/filepath"
+	"regexp"
+	runtimepprof "runtime/pprof"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// CPUProfile is the file name of the CPU profile covering the whole job
+const CPUProfile = "cpu.pprof"
+
+// ErrNotFound is returned for unknown jobs or profile files
+var ErrNotFound = errors.New("profile not found")
+
+// namePattern matches job IDs and profile file names accepted by Path
+var namePattern = regexp.MustCompile(`^[A-Za-z0-9_.-]+$`)
+
+// safeName reports whether s can be used as a single path element
+func safeName(s string) bool {
+	return namePattern.MatchString(s) && s != "." && s != ".."
+}
+
+// Register mounts the net/http/pprof handlers on r
+func Register(r chi.Router) {
+	r.HandleFunc("/", pprof.Index)
+	r.HandleFunc("/cmdline", pprof.Cmdline)
+	r.HandleFunc("/profile", pprof.Profile)
+	r.HandleFunc("/symbol", pprof.Symbol)
+	r.HandleFunc("/trace", pprof.Trace)
+	r.HandleFunc("/{name}", func(w http.ResponseWriter, r *http.Request) {
+		pprof.Handler(chi.URLParam(r, "name")).ServeHTTP(w, r)
+	})
+}
+
+// Recorder captures profiles of the next screening once armed
+type Recorder struct {
+	dir string
+
+	mu      sync.Mutex
+	armed   bool
+	running string // Job being captured; CPU profiling is process-wide
+}
+
+// NewRecorder stores captures under dir
+func NewRecorder(dir string) *Recorder {
+	return &Recorder{dir: dir}
+}
+
+// Arm requests a capture of the next screening to start
+func (r *Recorder) Arm() {
+	r.mu.Lock()
+	r.armed = true
+	r.mu.Unlock()
+}
+
+// Status reports whether a capture is armed and which job is being captured
+func (r *Recorder) Status() (armed bool, running string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.armed, r.running
+}
+
+// Begin starts capturing jobID if the recorder is armed. It returns nil
+// otherwise; a nil Capture ignores every call.
+func (r *Recorder) Begin(jobID string) *Capture {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if !r.armed || r.running != "" {
+		return nil
+	}
+	if !safeName(jobID) {
+		log.Printf("Warning: cannot profile job with unsafe ID %q", jobID)
+		return nil
+	}
+
+	dir := filepath.Join(r.dir, jobID)
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		log.Printf("Warning: failed to create profile directory for job %s: %v", jobID, err)
+		return nil
+	}
+	cpu, err := os.Create(filepath.Join(dir, CPUProfile))
+	if err != nil {
+		log.Printf("Warning: failed to create CPU profile for job %s: %v", jobID, err)
+		return nil
+	}
+	if err := runtimepprof.StartCPUProfile(cpu); err != nil {
+		// Someone is using /debug/pprof/profile; try again next screening
+		cpu.Close()
+		os.Remove(cpu.Name())
+		log.Printf("Warning: CPU profiler busy, job %s not profiled: %v", jobID, err)
+		return nil
+	}
+
+	r.armed = false
+	r.running = jobID
+	log.Printf("Profiling screening %s into %s", jobID, dir)
+	return &Capture{recorder: r, jobID: jobID, dir: dir, cpu: cpu}
+}
+
+// Capture records the profiles of one job
+type Capture struct {
+	recorder *Recorder
+	jobID    string
+	dir      string
+	cpu      *os.File
+	seq      int
+	done     bool
+}
+
+// Phase writes a heap profile marking the start of phase
+func (c *Capture) Phase(phase string) {
+	if c == nil || c.done {
+		return
+	}
+	c.seq++
+	c.writeHeap(fmt.Sprintf("heap_%02d_%s.pprof", c.seq, phase))
+}
+
+// Finish stops the CPU profile and writes a final heap profile. It is safe
+// to call more than once.
+func (c *Capture) Finish() {
+	if c == nil || c.done {
+		return
+	}
+	c.done = true
+	runtimepprof.StopCPUProfile()
+	if err := c.cpu.Close(); err != nil {
+		log.Printf("Warning: failed to close CPU profile for job %s: %v", c.jobID, err)
+	}
+	c.writeHeap(fmt.Sprintf("heap_%02d_end.pprof", c.seq+1))
+
+	c.recorder.mu.Lock()
+	c.recorder.running = ""
+	c.recorder.mu.Unlock()
+}
+
+func (c *Capture) writeHeap(name string) {
+	f, err := os.Create(filepath.Join(c.dir, name))
+	if err != nil {
+		log.Printf("Warning: failed to write heap profile for job %s: %v", c.jobID, err)
+		return
+	}
+	defer f.Close()
+	if err := runtimepprof.Lookup("heap").WriteTo(f, 0); err != nil {
+		log.Printf("Warning: failed to write heap profile for job %s: %v", c.jobID, err)
+	}
+}
+
+// JobProfiles lists the profiles captured for one job
+type JobProfiles struct {
+	JobID      string    `json:"jobId"`
+	Files      []string  `json:"files"`
+	CapturedAt time.Time `json:"capturedAt"`
+}
+
+// List returns every capture, newest first
+func (r *Recorder) List() ([]JobProfiles, error) {
+	entries, err := os.ReadDir(r.dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return []JobProfiles{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	captures := make([]JobProfiles, 0, len(entries))
+	for _, e := range entries {
+		if !e.IsDir() {
+			continue
+		}
+		info, err := e.Info()
+		if err != nil {
+			continue
+		}
+		files, err := os.ReadDir(filepath.Join(r.dir, e.Name()))
+		if err != nil {
+			continue
+		}
+		jp := JobProfiles{JobID: e.Name(), Files: make([]string, 0, len(files)), CapturedAt: info.ModTime()}
+		for _, f := range files {
+			jp.Files = append(jp.Files, f.Name())
+		}
+		captures = append(captures, jp)
+	}
+	sort.Slice(captures, func(i, j int) bool { return captures[i].CapturedAt.After(captures[j].CapturedAt) })
+	return captures, nil
+}
+
+// Path returns the file holding profile name of jobID
+func (r *Recorder) Path(jobID, name string) (string, error) {
+	if !safeName(jobID) || !safeName(name) {
+		return "", ErrNotFound
+	}
+	path := filepath.Join(r.dir, jobID, name)
+	if info, err := os.Stat(path); err != nil || info.IsDir() {
+		return "", ErrNotFound
+	}
+	return path, nil
+}
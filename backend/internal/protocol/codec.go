@@ -0,0 +1,118 @@
+package protocol
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/SanthoshCheemala/FLARE/backend/internal/psiadapter"
+)
+
+// WriteIntersectRequest writes req as JSON a ciphertext at a time. The
+// server intersects ciphertexts as it reads them, so they come last.
+func WriteIntersectRequest(w io.Writer, req *IntersectRequest) error {
+	bw := bufio.NewWriter(w)
+	sessionID, err := json.Marshal(req.SessionID)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(bw, `{"sessionId":%s,"batch":%d,"ciphertexts":[`, sessionID, req.Batch)
+	enc := json.NewEncoder(bw)
+	for i, ct := range req.Ciphertexts {
+		if i > 0 {
+			bw.WriteByte(',')
+		}
+		if err := enc.Encode(ct); err != nil {
+			return err
+		}
+	}
+	bw.WriteString("]}")
+	return bw.Flush()
+}
+
+// DecodeIntersectRequest decodes an intersect request into req without
+// collecting its ciphertexts: each is passed to fn as it is read. begin is
+// called once sessionId and batch are known, before the first ciphertext,
+// or at the end of a request without ciphertexts.
+func DecodeIntersectRequest(dec *json.Decoder, req *IntersectRequest, begin func() error, fn func(psiadapter.ClientCiphertext) error) error {
+	if err := expectDelim(dec, '{'); err != nil {
+		return err
+	}
+	begun := false
+	for dec.More() {
+		tok, err := dec.Token()
+		if err != nil {
+			return err
+		}
+		key, _ := tok.(string)
+		if begun && (key == "sessionId" || key == "batch") {
+			return fmt.Errorf("%s must precede ciphertexts", key)
+		}
+		switch key {
+		case "sessionId":
+			err = dec.Decode(&req.SessionID)
+		case "batch":
+			err = dec.Decode(&req.Batch)
+		case "ciphertexts":
+			if begun {
+				return errors.New("ciphertexts given twice")
+			}
+			begun = true
+			if err := begin(); err != nil {
+				return err
+			}
+			err = decodeCiphertexts(dec, fn)
+		default:
+			var skip json.RawMessage
+			err = dec.Decode(&skip)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	if err := expectDelim(dec, '}'); err != nil {
+		return err
+	}
+	if !begun {
+		return begin()
+	}
+	return nil
+}
+
+// decodeCiphertexts reads a JSON array of ciphertexts, or null, passing
+// each to fn
+func decodeCiphertexts(dec *json.Decoder, fn func(psiadapter.ClientCiphertext) error) error {
+	tok, err := dec.Token()
+	if err != nil {
+		return err
+	}
+	if tok == nil {
+		return nil
+	}
+	if d, ok := tok.(json.Delim); !ok || d != '[' {
+		return errors.New("ciphertexts must be an array")
+	}
+	for dec.More() {
+		var ct psiadapter.ClientCiphertext
+		if err := dec.Decode(&ct); err != nil {
+			return err
+		}
+		if err := fn(ct); err != nil {
+			return err
+		}
+	}
+	return expectDelim(dec, ']')
+}
+
+func expectDelim(dec *json.Decoder, want json.Delim) error {
+	tok, err := dec.Token()
+	if err != nil {
+		return err
+	}
+	if d, ok := tok.(json.Delim); !ok || d != want {
+		return fmt.Errorf("expected %q", want)
+	}
+	return nil
+}
@@ -0,0 +1,123 @@
+package protocol
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/SanthoshCheemala/FLARE/backend/internal/psiadapter"
+)
+
+func FuzzDecodeIntersectRequest(f *testing.F) {
+	var valid bytes.Buffer
+	if err := WriteIntersectRequest(&valid, &IntersectRequest{SessionID: "s-1", Batch: 2}); err != nil {
+		f.Fatal(err)
+	}
+	f.Add(valid.Bytes())
+	f.Add([]byte(`{"sessionId":"s-1","batch":0,"ciphertexts":null}`))
+	f.Add([]byte(`{"ciphertexts":[],"sessionId":"late"}`))
+	f.Add([]byte(`{"sessionId":"s-1","ciphertexts":[{}],"ciphertexts":[]}`))
+	f.Add([]byte(`{"sessionId":"s-1","ciphertexts":{"a":1}}`))
+	f.Add([]byte(`[`))
+	f.Fuzz(func(t *testing.T, data []byte) {
+		var req IntersectRequest
+		begins := 0
+		var cts []psiadapter.ClientCiphertext
+		err := DecodeIntersectRequest(json.NewDecoder(bytes.NewReader(data)), &req,
+			func() error { begins++; return nil },
+			func(ct psiadapter.ClientCiphertext) error { cts = append(cts, ct); return nil })
+		if begins > 1 {
+			t.Fatalf("begin called %d times", begins)
+		}
+		if err != nil {
+			return
+		}
+		if begins != 1 {
+			t.Fatal("request decoded without calling begin")
+		}
+
+		// What was decoded encodes to a request that decodes alike
+		req.Ciphertexts = cts
+		var buf bytes.Buffer
+		if err := WriteIntersectRequest(&buf, &req); err != nil {
+			t.Fatalf("re-encode: %v", err)
+		}
+		var again IntersectRequest
+		n := 0
+		err = DecodeIntersectRequest(json.NewDecoder(&buf), &again,
+			func() error { return nil },
+			func(psiadapter.ClientCiphertext) error { n++; return nil })
+		if err != nil {
+			t.Fatalf("decode re-encoded %q: %v", buf.String(), err)
+		}
+		if again.SessionID != req.SessionID || again.Batch != req.Batch || n != len(cts) {
+			t.Fatalf("round trip gave session %q batch %d with %d ciphertexts, want %q, %d, %d",
+				again.SessionID, again.Batch, n, req.SessionID, req.Batch, len(cts))
+		}
+	})
+}
+
+func FuzzInitSessionRequest(f *testing.F) {
+	f.Add([]byte(`{"sanctionListIds":["1","2"],"enabledColumns":["name","dob"],"hashAlgorithms":["sha256"]}`))
+	f.Add([]byte(`{"transliteration":{"version":1,"scripts":["cyrillic"]}}`))
+	f.Add([]byte(`{"transliteration":{"version":99,"scripts":["?"]},"documents":true}`))
+	f.Add([]byte(`{"sanctionListIds":null,"categories":[""],"packIds":[]}`))
+	f.Fuzz(func(t *testing.T, data []byte) {
+		// What the server checks of a request before opening a session
+		var req InitSessionRequest
+		if err := json.Unmarshal(data, &req); err != nil {
+			return
+		}
+		if names, err := req.Transliteration.Check(); err == nil {
+			if _, err := names.Check(); err != nil {
+				t.Fatalf("checked profile %v fails its check: %v", names, err)
+			}
+		}
+	})
+}
+
+// initTransport answers InitSession with a fixed response
+type initTransport struct {
+	resp *InitSessionResponse
+}
+
+func (t *initTransport) InitSession(ctx context.Context, req *InitSessionRequest) (*InitSessionResponse, error) {
+	return t.resp, nil
+}
+
+func (t *initTransport) Intersect(ctx context.Context, req *IntersectRequest) (*IntersectResponse, error) {
+	return &IntersectResponse{}, nil
+}
+
+func (t *initTransport) Resolve(ctx context.Context, sessionID string, req *ResolveRequest) (*ResolveResponse, error) {
+	return &ResolveResponse{}, nil
+}
+
+func (t *initTransport) Ping(ctx context.Context, sessionID string) error         { return nil }
+func (t *initTransport) CloseSession(ctx context.Context, sessionID string) error { return nil }
+
+func FuzzOpen(f *testing.F) {
+	f.Add([]byte(`{"sessionId":"s-1","params":{},"token":"t"}`))
+	f.Add([]byte(`{"sessionId":"s-1","batchParams":[{},null],"hash":{"algorithm":"siphash","version":1,"salt":"MDEyMzQ1Njc4OWFiY2RlZg=="}}`))
+	f.Add([]byte(`{"hash":{"algorithm":"blake2b","version":1,"salt":"c2hvcnQ="}}`))
+	f.Add([]byte(`{"transliteration":{"version":1,"scripts":["greek"]},"idleTimeoutSeconds":-5}`))
+	f.Fuzz(func(t *testing.T, data []byte) {
+		// A hostile or broken server controls the whole init response
+		var resp InitSessionResponse
+		if err := json.Unmarshal(data, &resp); err != nil {
+			return
+		}
+		s, err := Open(context.Background(), &initTransport{resp: &resp}, InitSessionRequest{})
+		if err != nil {
+			return
+		}
+		if len(s.Params) == 0 {
+			t.Fatal("session opened without params")
+		}
+		if _, err := s.Hash.Check(); err != nil {
+			t.Fatalf("session opened with unusable hash scheme: %v", err)
+		}
+		s.Hash.HashOne("john smith|1990-01-02|us")
+	})
+}
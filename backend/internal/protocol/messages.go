@@ -0,0 +1,198 @@
+// Package protocol defines the PSI exchange between the client backend and
+// the PSI server independently of how its messages travel. A session is
+// opened with the sanction lists and columns to screen, the client's
+// ciphertexts are intersected a batch at a time, the matches are resolved to
+// sanction records and the session is closed.
+//
+// The messages below are the wire format of every transport. A Transport
+// carries one message exchange at a time; Session drives the exchange over
+// any Transport and enforces its order. The HTTP transport, and its
+// in-process and message-queue variants, is client.PSIClient; Recorder is
+// the client side of an offline session, whose messages travel in files.
+package protocol
+
+import (
+	"time"
+
+	"github.com/SanthoshCheemala/FLARE/backend/internal/models"
+	"github.com/SanthoshCheemala/FLARE/backend/internal/psiadapter"
+	"github.com/SanthoshCheemala/FLARE/backend/internal/translit"
+)
+
+type InitSessionRequest struct {
+	SanctionListIDs []string `json:"sanctionListIds"`      // IDs of lists to screen against
+	EnabledColumns  []string `json:"enabledColumns"`       // Columns to use for hashing (schema)
+	Categories      []string `json:"categories,omitempty"` // Also screen every list in these categories (PEP, ...)
+	PackIDs         []string `json:"packIds,omitempty"`    // Also screen every list in these packs
+	// Transliteration romanizes names before hashing. The client hashes its
+	// records with the same profile, so an unsupported one is rejected.
+	Transliteration translit.Profile `json:"transliteration"`
+	// HashAlgorithms lists the hashes the client supports; Open sets it.
+	// Clients that predate negotiation send none and only speak sha256.
+	HashAlgorithms []string `json:"hashAlgorithms"`
+	// Documents adds the document channel: each sanction's valid passport
+	// and national ID numbers are hashed into the set alongside its names
+	Documents bool `json:"documents,omitempty"`
+}
+
+type InitSessionResponse struct {
+	SessionID string                             `json:"sessionId"`
+	Params    *psiadapter.SerializedServerParams `json:"params"`
+	// BatchParams is set for batched sessions: one parameter set per batch.
+	// Ciphertexts for batch i must be encrypted with BatchParams[i].
+	BatchParams []*psiadapter.SerializedServerParams `json:"batchParams,omitempty"`
+	Token       string                               `json:"token"`     // Must accompany intersect/resolve calls
+	ExpiresAt   time.Time                            `json:"expiresAt"` // Token expiry
+	// SigningKey signs intersect requests; see auth.SignDigest
+	SigningKey string `json:"signingKey"`
+	// Transliteration is the name profile the session's set was hashed with
+	Transliteration translit.Profile `json:"transliteration"`
+	// Documents reports whether the set includes the document channel. It
+	// is false from servers without the document channel.
+	Documents bool `json:"documents"`
+	// Hash is the scheme the client must hash its set with. It is absent
+	// from servers that predate negotiation.
+	Hash psiadapter.HashScheme `json:"hash"`
+	// IdleTimeoutSeconds is how long the session survives without a
+	// request; clients ping well within it. It is 0 from servers that never
+	// expire idle sessions.
+	IdleTimeoutSeconds int `json:"idleTimeoutSeconds"`
+}
+
+type IntersectRequest struct {
+	SessionID string `json:"sessionId"`
+	// Batch is the index of the batch whose params encrypted Ciphertexts.
+	// Unbatched sessions only accept batch 0.
+	Batch int `json:"batch"`
+	// Ciphertexts are intersected as they are read, so they must follow
+	// sessionId and batch in the body
+	Ciphertexts []psiadapter.ClientCiphertext `json:"ciphertexts"`
+}
+
+type IntersectResponse struct {
+	Matches    []uint64 `json:"matches"`
+	DurationMs int64    `json:"durationMs"` // Server-side intersection time
+}
+
+// ResolveRequest asks for the records behind matched hashes. Hashes are
+// sent as int64 for JSON compatibility.
+type ResolveRequest struct {
+	Hashes []int64 `json:"hashes"`
+}
+
+type ResolveResponse struct {
+	Sanctions []ResolvedSanction `json:"sanctions"`
+}
+
+// ResolvedSanction is a sanction record revealed for a matched hash. A
+// sanction whose aliases or documents matched separately is returned once
+// per hash.
+type ResolvedSanction struct {
+	Hash    int64  `json:"hash"` // The session's hash of the matched input
+	Name    string `json:"name"`
+	DOB     string `json:"dob"`
+	Country string `json:"country"`
+	Program string `json:"program"`
+	Source  string `json:"source"`
+
+	EntityType   string            `json:"entityType"`
+	Aliases      []string          `json:"aliases"`
+	IMONumber    string            `json:"imoNumber"`
+	Registration string            `json:"registration"`
+	Category     string            `json:"category"`
+	Attributes   map[string]string `json:"attributes"`
+	SourceRef    string            `json:"sourceRef,omitempty"` // Official list entry ID or URL
+}
+
+// Sanction converts the record to the client's model. It has no local list
+// ID: the record belongs to the server's lists.
+func (s ResolvedSanction) Sanction() *models.Sanction {
+	return &models.Sanction{
+		Hash:    s.Hash,
+		Name:    s.Name,
+		DOB:     s.DOB,
+		Country: s.Country,
+		Program: s.Program,
+		Source:  s.Source,
+
+		EntityType:   s.EntityType,
+		Aliases:      s.Aliases,
+		IMONumber:    s.IMONumber,
+		Registration: s.Registration,
+		Category:     s.Category,
+		Attributes:   s.Attributes,
+		SourceRef:    s.SourceRef,
+	}
+}
+
+type PingResponse struct {
+	SessionID          string `json:"sessionId"`
+	IdleTimeoutSeconds int    `json:"idleTimeoutSeconds"`
+}
+
+// Version is the version of the exchange this package speaks. It changes
+// only when a message changes in a way older peers cannot read; fields they
+// ignore do not change it.
+const Version = 1
+
+// Capabilities describes what a PSI server supports, so clients can set up
+// sessions from it instead of assuming
+type Capabilities struct {
+	ProtocolVersions []int `json:"protocolVersions"`
+	// Columns lists the built-in columns sessions may enable, then the
+	// custom fields of the active lists
+	Columns        []ColumnCapability        `json:"columns"`
+	DefaultColumns []string                  `json:"defaultColumns"` // Used when a session enables none
+	EntityProfiles map[string][]string       `json:"entityProfiles"` // Columns hashed for non-individual entities
+	Normalization  NormalizationCapabilities `json:"normalization"`
+	Limits         LimitCapabilities         `json:"limits"`
+	Batching       BatchingCapabilities      `json:"batching"`
+}
+
+// ColumnCapability is a column a session may enable and how its values are
+// normalized before hashing
+type ColumnCapability struct {
+	Name          string  `json:"name"`
+	Normalization string  `json:"normalization"`
+	Type          string  `json:"type,omitempty"`    // Field type of a custom field
+	ListIDs       []int64 `json:"listIds,omitempty"` // Lists defining a custom field
+}
+
+// NormalizationCapabilities are the profiles elements are normalized and
+// hashed with
+type NormalizationCapabilities struct {
+	TransliterationVersion int      `json:"transliterationVersion"`
+	Scripts                []string `json:"scripts"` // Scripts sessions may romanize
+	// GlobalTransliteration is the profile of the shared default-schema
+	// set; sessions requesting another build their own
+	GlobalTransliteration string   `json:"globalTransliteration"`
+	HashAlgorithm         string   `json:"hashAlgorithm"` // The hash sessions are opened with
+	HashAlgorithms        []string `json:"hashAlgorithms"`
+	HashVersion           int      `json:"hashVersion"`
+	DateOrder             string   `json:"dateOrder"` // Reading of ambiguous numeric dates
+	Documents             bool     `json:"documents"` // Sessions may add the document channel
+}
+
+// LimitCapabilities are the limits applied to the requesting client. Zero
+// means no limit.
+type LimitCapabilities struct {
+	MaxCiphertextsPerRequest int   `json:"maxCiphertextsPerRequest"`
+	MaxIntersectBodyBytes    int64 `json:"maxIntersectBodyBytes"`
+	MinResolveCiphertexts    int   `json:"minResolveCiphertexts"`
+	IdleTimeoutSeconds       int   `json:"idleTimeoutSeconds"`
+	SignedRequests           bool  `json:"signedRequests"` // Unsigned intersect requests are rejected
+}
+
+// BatchingCapabilities describes how ciphertexts are intersected
+type BatchingCapabilities struct {
+	// Batches is how many parameter sets the shared default-schema set is
+	// split into: 0 before it is built, 1 when it is not batched. Sessions
+	// with other columns or documents have one batch of their own.
+	Batches int `json:"batches"`
+	// ChunkSize is how many ciphertexts of a request are intersected at a
+	// time
+	ChunkSize int `json:"chunkSize"`
+	// SplitRequests reports that a batch's ciphertexts may be sent across
+	// several intersect requests, whose matches add up in the session
+	SplitRequests bool `json:"splitRequests"`
+}
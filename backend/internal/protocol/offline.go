@@ -0,0 +1,193 @@
+package protocol
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"time"
+
+	"github.com/SanthoshCheemala/FLARE/backend/internal/models"
+	"github.com/SanthoshCheemala/FLARE/backend/internal/psiadapter"
+)
+
+// Offline screening carries the exchange across an air gap in files. The
+// authority exports a ParamsBundle from a session it opened, the client
+// encrypts its customers under those params into a RequestBundle, and the
+// authority answers it with a ResponseBundle. The ciphertexts are only
+// intersected in a later session, so offline screening needs params that
+// outlive a session: those of the global tree, hashed with unkeyed sha256.
+
+// BundleVersion is the format of the bundles written by this version
+const BundleVersion = 1
+
+// Bundle kinds
+const (
+	KindParams   = "flare.offline.params"
+	KindRequest  = "flare.offline.request"
+	KindResponse = "flare.offline.response"
+)
+
+// ErrOffline is returned for requests an offline session can't make
+var ErrOffline = errors.New("not available in an offline session")
+
+type bundleHeader struct {
+	Kind      string    `json:"kind"`
+	Version   int       `json:"version"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// ParamsBundle holds the params an authority issued for a session request,
+// for a client that can't reach it to encrypt under
+type ParamsBundle struct {
+	bundleHeader
+	Request InitSessionRequest `json:"request"`
+	// Session is the authority's answer, without the session's token and
+	// signing key; the session itself was closed
+	Session InitSessionResponse `json:"session"`
+}
+
+// RequestBundle is a client's screening request: the session it asks for
+// and its customers' ciphertexts under each batch's params
+type RequestBundle struct {
+	bundleHeader
+	Request InitSessionRequest `json:"request"`
+	// ParamsDigest identifies the params the ciphertexts were encrypted
+	// under; a session with other params can't intersect them
+	ParamsDigest string                          `json:"paramsDigest"`
+	Batches      [][]psiadapter.ClientCiphertext `json:"batches"`
+}
+
+// ResponseBundle is the authority's answer to a RequestBundle: the matched
+// hashes and the sanction records behind them
+type ResponseBundle struct {
+	bundleHeader
+	Request      InitSessionRequest    `json:"request"`
+	Hash         psiadapter.HashScheme `json:"hash"` // The scheme customers were hashed with
+	ParamsDigest string                `json:"paramsDigest"`
+	Matches      []uint64              `json:"matches"`
+	Sanctions    []*models.Sanction    `json:"sanctions"`
+}
+
+// NewParamsBundle records the params of an open session
+func NewParamsBundle(req InitSessionRequest, resp *InitSessionResponse) *ParamsBundle {
+	session := *resp
+	session.Token, session.SigningKey = "", ""
+	return &ParamsBundle{bundleHeader: newHeader(KindParams), Request: req, Session: session}
+}
+
+// NewResponseBundle records the matches and sanctions of an answered request
+func NewResponseBundle(req *RequestBundle, hash psiadapter.HashScheme, matches []uint64, sanctions []*models.Sanction) *ResponseBundle {
+	return &ResponseBundle{
+		bundleHeader: newHeader(KindResponse),
+		Request:      req.Request,
+		Hash:         hash,
+		ParamsDigest: req.ParamsDigest,
+		Matches:      matches,
+		Sanctions:    sanctions,
+	}
+}
+
+func newHeader(kind string) bundleHeader {
+	return bundleHeader{Kind: kind, Version: BundleVersion, CreatedAt: time.Now().UTC()}
+}
+
+// ParamsDigest identifies a session's batch params and hash scheme
+func ParamsDigest(params []*psiadapter.SerializedServerParams, hash psiadapter.HashScheme) string {
+	h := sha256.New()
+	json.NewEncoder(h).Encode(params)
+	json.NewEncoder(h).Encode(hash)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// WriteBundle writes a bundle to path
+func WriteBundle(path string, bundle interface{}) error {
+	data, err := json.Marshal(bundle)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0600)
+}
+
+// ReadBundle reads a bundle of the given kind from path
+func ReadBundle(path, kind string, bundle interface{}) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	var header bundleHeader
+	if err := json.Unmarshal(data, &header); err != nil {
+		return fmt.Errorf("%s is not a bundle: %w", path, err)
+	}
+	if header.Kind != kind {
+		return fmt.Errorf("%s is a %q bundle, expected %q", path, header.Kind, kind)
+	}
+	if header.Version != BundleVersion {
+		return fmt.Errorf("%s has bundle version %d; this version reads %d", path, header.Version, BundleVersion)
+	}
+	return json.NewDecoder(bytes.NewReader(data)).Decode(bundle)
+}
+
+// Recorder is the Transport of the client side of an offline session. It
+// answers session init from a params bundle and records intersect requests
+// into a request bundle; their matches arrive later in the response bundle.
+type Recorder struct {
+	params  *ParamsBundle
+	request *RequestBundle
+}
+
+// NewRecorder returns a recorder answering from params
+func NewRecorder(params *ParamsBundle) *Recorder {
+	return &Recorder{params: params}
+}
+
+// Bundle returns the request bundle recorded so far
+func (r *Recorder) Bundle() *RequestBundle {
+	return r.request
+}
+
+func (r *Recorder) InitSession(ctx context.Context, req *InitSessionRequest) (*InitSessionResponse, error) {
+	want := r.params.Request
+	if !slices.Equal(req.SanctionListIDs, want.SanctionListIDs) || !slices.Equal(req.EnabledColumns, want.EnabledColumns) ||
+		!slices.Equal(req.Categories, want.Categories) || !slices.Equal(req.PackIDs, want.PackIDs) ||
+		req.Transliteration.String() != want.Transliteration.String() || req.Documents != want.Documents {
+		return nil, errors.New("the params bundle was issued for a different session request")
+	}
+	resp := r.params.Session
+	params := resp.BatchParams
+	if len(params) == 0 {
+		params = []*psiadapter.SerializedServerParams{resp.Params}
+	}
+	r.request = &RequestBundle{
+		bundleHeader: newHeader(KindRequest),
+		Request:      want,
+		ParamsDigest: ParamsDigest(params, resp.Hash),
+		Batches:      make([][]psiadapter.ClientCiphertext, len(params)),
+	}
+	return &resp, nil
+}
+
+func (r *Recorder) Intersect(ctx context.Context, req *IntersectRequest) (*IntersectResponse, error) {
+	if r.request == nil || req.Batch < 0 || req.Batch >= len(r.request.Batches) {
+		return nil, fmt.Errorf("batch %d is not in the params bundle", req.Batch)
+	}
+	r.request.Batches[req.Batch] = append(r.request.Batches[req.Batch], req.Ciphertexts...)
+	return &IntersectResponse{}, nil
+}
+
+func (r *Recorder) Resolve(ctx context.Context, sessionID string, req *ResolveRequest) (*ResolveResponse, error) {
+	return nil, fmt.Errorf("resolve: %w", ErrOffline)
+}
+
+func (r *Recorder) Ping(ctx context.Context, sessionID string) error {
+	return nil
+}
+
+func (r *Recorder) CloseSession(ctx context.Context, sessionID string) error {
+	return nil
+}
@@ -0,0 +1,211 @@
+package protocol
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/SanthoshCheemala/FLARE/backend/internal/models"
+	"github.com/SanthoshCheemala/FLARE/backend/internal/psiadapter"
+)
+
+// State is where a session is in the exchange
+type State int
+
+const (
+	StateOpen        State = iota // Initialized; nothing intersected yet
+	StateIntersected              // At least one batch intersected; matches may be resolved
+	StateClosed                   // Closed; no further requests
+)
+
+func (s State) String() string {
+	switch s {
+	case StateOpen:
+		return "open"
+	case StateIntersected:
+		return "intersected"
+	case StateClosed:
+		return "closed"
+	}
+	return fmt.Sprintf("State(%d)", int(s))
+}
+
+var (
+	ErrClosed         = errors.New("session is closed")
+	ErrNotIntersected = errors.New("session has intersected nothing to resolve")
+)
+
+// Session is one run of the PSI exchange over a Transport. Its methods are
+// safe for concurrent use.
+type Session struct {
+	ID string
+	// Params holds one parameter set per server batch; ciphertexts for
+	// batch i are encrypted with Params[i]. It is nil for attached sessions.
+	Params []*psiadapter.SerializedServerParams
+	Hash   psiadapter.HashScheme // The scheme to hash the client set with
+	// IdleTimeout is how long the server keeps the session without a
+	// request; 0 if it never expires idle sessions
+	IdleTimeout time.Duration
+
+	t     Transport
+	mu    sync.Mutex
+	state State
+}
+
+// Open starts a session with the server behind t and checks that the server
+// honoured the request
+func Open(ctx context.Context, t Transport, req InitSessionRequest) (*Session, error) {
+	req.HashAlgorithms = psiadapter.HashAlgorithms
+	resp, err := t.InitSession(ctx, &req)
+	if err != nil {
+		return nil, err
+	}
+	s := &Session{
+		ID:          resp.SessionID,
+		Params:      resp.BatchParams,
+		IdleTimeout: time.Duration(resp.IdleTimeoutSeconds) * time.Second,
+		t:           t,
+	}
+	if len(s.Params) == 0 {
+		s.Params = []*psiadapter.SerializedServerParams{resp.Params}
+	}
+
+	// Servers without transliteration support ignore the profile and would
+	// hash names unromanized, so no customer could match
+	if got, want := resp.Transliteration.String(), req.Transliteration.String(); got != want {
+		s.closeQuietly()
+		return nil, fmt.Errorf("server hashed names with transliteration %q, requested %q", got, want)
+	}
+	if s.Hash, err = resp.Hash.Check(); err != nil {
+		s.closeQuietly()
+		return nil, fmt.Errorf("server sent an unusable hash scheme: %w", err)
+	}
+	return s, nil
+}
+
+// Attach returns a session opened earlier, known only by its ID, whose
+// matches are to be resolved again. The transport must still hold the
+// session's credentials.
+func Attach(t Transport, sessionID string) *Session {
+	return &Session{ID: sessionID, t: t, state: StateIntersected}
+}
+
+// State returns where the session is in the exchange
+func (s *Session) State() State {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.state
+}
+
+// Intersect sends ciphertexts encrypted with the params of the given batch
+// and returns the matches along with the time the server reported spending
+// on the intersection itself
+func (s *Session) Intersect(ctx context.Context, batch int, ciphertexts []psiadapter.ClientCiphertext) ([]uint64, time.Duration, error) {
+	if err := s.check(); err != nil {
+		return nil, 0, err
+	}
+	if s.Params != nil && (batch < 0 || batch >= len(s.Params)) {
+		return nil, 0, fmt.Errorf("batch %d out of range (session has %d batches)", batch, len(s.Params))
+	}
+	resp, err := s.t.Intersect(ctx, &IntersectRequest{SessionID: s.ID, Batch: batch, Ciphertexts: ciphertexts})
+	if err != nil {
+		return nil, 0, err
+	}
+	s.mu.Lock()
+	if s.state == StateOpen {
+		s.state = StateIntersected
+	}
+	s.mu.Unlock()
+	return resp.Matches, time.Duration(resp.DurationMs) * time.Millisecond, nil
+}
+
+// Resolve fetches the sanction records behind matched hashes. The server
+// only reveals hashes its intersections matched in this session.
+func (s *Session) Resolve(ctx context.Context, hashes []uint64) ([]*models.Sanction, error) {
+	if err := s.check(); err != nil {
+		return nil, err
+	}
+	if s.State() == StateOpen && len(hashes) > 0 {
+		return nil, ErrNotIntersected
+	}
+	req := &ResolveRequest{Hashes: make([]int64, len(hashes))}
+	for i, h := range hashes {
+		req.Hashes[i] = int64(h)
+	}
+	resp, err := s.t.Resolve(ctx, s.ID, req)
+	if err != nil {
+		return nil, err
+	}
+	sanctions := make([]*models.Sanction, len(resp.Sanctions))
+	for i, r := range resp.Sanctions {
+		sanctions[i] = r.Sanction()
+	}
+	return sanctions, nil
+}
+
+// Ping tells the server the session is still in use
+func (s *Session) Ping(ctx context.Context) error {
+	if err := s.check(); err != nil {
+		return err
+	}
+	return s.t.Ping(ctx, s.ID)
+}
+
+// Heartbeat pings the session at a third of the server's idle timeout until
+// the returned stop function is called or ctx ends, so long encryptions and
+// intersections do not look like a vanished client. It does nothing for
+// servers that never expire idle sessions.
+func (s *Session) Heartbeat(ctx context.Context) (stop func()) {
+	if s.IdleTimeout <= 0 {
+		return func() {}
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		ticker := time.NewTicker(s.IdleTimeout / 3)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				// A missed ping is retried on the next tick; the session
+				// only expires after three in a row
+				s.Ping(ctx)
+			}
+		}
+	}()
+	return func() {
+		cancel()
+		<-done
+	}
+}
+
+// Close ends the session on the server, revoking its access token. Closing
+// a closed session does nothing.
+func (s *Session) Close(ctx context.Context) error {
+	s.mu.Lock()
+	if s.state == StateClosed {
+		s.mu.Unlock()
+		return nil
+	}
+	s.state = StateClosed
+	s.mu.Unlock()
+	return s.t.CloseSession(ctx, s.ID)
+}
+
+// closeQuietly closes a session Open is abandoning
+func (s *Session) closeQuietly() {
+	s.Close(context.Background())
+}
+
+func (s *Session) check() error {
+	if s.State() == StateClosed {
+		return ErrClosed
+	}
+	return nil
+}
@@ -0,0 +1,19 @@
+package protocol
+
+import "context"
+
+// Transport carries the PSI exchange to a server. Each method is one
+// request and its response; the order of requests is Session's concern.
+// Credentials the server issues with a session, such as its access token
+// and signing key, belong to the transport, which attaches them to the
+// session's later requests and forgets them once it is closed.
+//
+// Errors the server reports keep their apierror type, whatever the
+// transport, so callers can tell a rejected request from a lost one.
+type Transport interface {
+	InitSession(ctx context.Context, req *InitSessionRequest) (*InitSessionResponse, error)
+	Intersect(ctx context.Context, req *IntersectRequest) (*IntersectResponse, error)
+	Resolve(ctx context.Context, sessionID string, req *ResolveRequest) (*ResolveResponse, error)
+	Ping(ctx context.Context, sessionID string) error
+	CloseSession(ctx context.Context, sessionID string) error
+}
@@ -1,22 +1,37 @@
 package psiadapter
 
 import (
+	"bytes"
 	"context"
+	"encoding/gob"
+	"errors"
 	"fmt"
+	"log"
+	"os"
+	"path/filepath"
 	"runtime"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/SanthoshCheemala/LE-PSI/pkg/LE"
 	"github.com/SanthoshCheemala/LE-PSI/pkg/matrix"
 	"github.com/SanthoshCheemala/LE-PSI/pkg/psi"
 	"github.com/tuneinsight/lattigo/v3/ring"
 
+	"github.com/SanthoshCheemala/FLARE/backend/internal/atrest"
 	"github.com/SanthoshCheemala/FLARE/backend/utils"
 )
 
 // Adapter wraps the LE-PSI library for cleaner integration
 type Adapter struct {
-	maxWorkers int
+	maxWorkers      int
+	residentBatches int            // Batch contexts kept in memory; 0 keeps all
+	treeWorkers     int            // Batch trees built in parallel; 0 uses GOMAXPROCS
+	files           *atrest.Cipher // Encrypts spilled batch contexts and trees; nil stores plaintext
+	treeBusyTimeout time.Duration  // How long an operation on a locked tree is retried
+	treeReopens     int            // Reopens of a stale tree per operation
+	batchSize       int            // Records per batch; 0 sizes batches from available RAM
 }
 
 func NewAdapter(maxWorkers int) *Adapter {
@@ -24,61 +39,339 @@ func NewAdapter(maxWorkers int) *Adapter {
 		maxWorkers = runtime.NumCPU()
 	}
 	return &Adapter{
-		maxWorkers: maxWorkers,
+		maxWorkers:      maxWorkers,
+		treeBusyTimeout: defaultTreeBusyTimeout,
+		treeReopens:     defaultTreeReopens,
 	}
 }
 
+// WithWorkers returns a copy of the adapter limited to n workers, for
+// operations that run with their own parallelism. Values below one keep
+// the adapter's worker count.
+func (a *Adapter) WithWorkers(n int) *Adapter {
+	c := *a
+	if n > 0 {
+		c.maxWorkers = n
+	}
+	return &c
+}
+
+// SetResidentBatches sets how many batch contexts InitServerBatched keeps in
+// memory. Zero or less keeps every batch resident.
+func (a *Adapter) SetResidentBatches(n int) {
+	a.residentBatches = n
+}
+
+// SetTreeWorkers sets how many batch trees InitServerBatched builds in
+// parallel, and how many goroutines hash the set InitServer builds a tree
+// for. Values below one use GOMAXPROCS.
+func (a *Adapter) SetTreeWorkers(n int) {
+	a.treeWorkers = n
+}
+
+// buildWorkers returns the number of tree workers
+func (a *Adapter) buildWorkers() int {
+	if a.treeWorkers > 0 {
+		return a.treeWorkers
+	}
+	return runtime.GOMAXPROCS(0)
+}
+
+// SetFileCipher sets the cipher used for batch contexts spilled to disk and
+// for tree databases (see sealTree)
+func (a *Adapter) SetFileCipher(c *atrest.Cipher) {
+	a.files = c
+}
+
 // ServerContext holds the PSI server state
 type ServerContext struct {
-	Hashes   []uint64
-	TreePath string
-	Ctx      *psi.ServerInitContext
-	PP       *matrix.Vector
-	Msg      *ring.Poly
-	LE       *LE.LE
+	Hashes      []uint64
+	TreePath    string
+	LibraryPath string // Tree database the library opens: TreePath, or its working copy if the tree is sealed
+	Ctx         *psi.ServerInitContext
+	PP          *matrix.Vector
+	Msg         *ring.Poly
+	LE          *LE.LE
+	Hash        HashScheme // Hashes set elements for this tree
+
+	mu    sync.RWMutex   // Guards Ctx while the tree is reopened
+	files *atrest.Cipher // Seals the tree; nil if it is not sealed
+
+	encryptMu     sync.Mutex
+	encryptParams []encryptParams // Copies of PP, Msg and LE for encryption workers
+}
+
+// encryptParams is one encryption worker's copy of a tree's public params
+type encryptParams struct {
+	pp  *matrix.Vector
+	msg *ring.Poly
+	le  *LE.LE
+}
+
+// workerParams returns n copies of sc's public params, the first of them
+// sc's own. The library doesn't document ClientEncrypt as safe for
+// concurrent calls on shared params, so each encryption worker gets its own
+// copy. Copies are made once per context and reused.
+func (sc *ServerContext) workerParams(n int) ([]encryptParams, error) {
+	sc.encryptMu.Lock()
+	defer sc.encryptMu.Unlock()
+	if len(sc.encryptParams) == 0 {
+		sc.encryptParams = append(sc.encryptParams, encryptParams{pp: sc.PP, msg: sc.Msg, le: sc.LE})
+	}
+	if len(sc.encryptParams) >= n {
+		return sc.encryptParams[:n], nil
+	}
+
+	serialized, err := psiSerialize(sc)
+	if err != nil {
+		return nil, err
+	}
+	for len(sc.encryptParams) < n {
+		var p encryptParams
+		if err := guardLE(PhaseDeserialize, -1, func() (err error) {
+			p.pp, p.msg, p.le, err = leDeserializeParameters(serialized)
+			return err
+		}); err != nil {
+			return nil, fmt.Errorf("copy params for encryption worker: %w", err)
+		}
+		sc.encryptParams = append(sc.encryptParams, p)
+	}
+	return sc.encryptParams, nil
 }
 
 // ClientCiphertext represents encrypted client data
 // We alias this to the library's type or wrap it
 type ClientCiphertext = psi.Cxtx
 
-// InitServer initializes the PSI server context with sanction data
-func (a *Adapter) InitServer(ctx context.Context, sanctionSet []string, treePath string) (*ServerContext, error) {
-	// Hash the sanction set
-	hashes := HashDataPoints(sanctionSet)
+// leChunkSize is how many records are passed to one LE library call, so a
+// done context is noticed between chunks. The library parallelizes
+// intersection within a chunk; EncryptClient spreads chunks over workers.
+const leChunkSize = 256
+
+// The LE library calls the adapter makes. Tests swap in a stand-in that
+// matches hashes in the clear, so encryption and intersection are also
+// given the path of the tree they are for.
+var (
+	leServerInitialize      = psi.ServerInitialize
+	leGetPublicParameters   = psi.GetPublicParameters
+	leSerializeParameters   = psi.SerializeParameters
+	leDeserializeParameters = psi.DeserializeParameters
+	leClientEncrypt         = func(treePath string, hashes []uint64, pp *matrix.Vector, msg *ring.Poly, le *LE.LE) []ClientCiphertext {
+		return psi.ClientEncrypt(hashes, pp, msg, le)
+	}
+	leDetectIntersection = func(treePath string, psiCtx *psi.ServerInitContext, ciphertexts []ClientCiphertext) ([]uint64, error) {
+		return psi.DetectIntersectionWithContext(psiCtx, ciphertexts)
+	}
+)
+
+// hashContext hashes set under scheme a chunk at a time on up to workers
+// goroutines, stopping when ctx is done. The hashes keep the order of set.
+func hashContext(ctx context.Context, scheme HashScheme, set []string, workers int) ([]uint64, error) {
+	hashes := make([]uint64, len(set))
+	numChunks := (len(set) + leChunkSize - 1) / leChunkSize
+	workers = min(max(1, workers), numChunks)
+
+	var wg sync.WaitGroup
+	next := make(chan int)
+	go func() {
+		defer close(next)
+		for c := 0; c < numChunks; c++ {
+			select {
+			case next <- c:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for c := range next {
+				start := c * leChunkSize
+				end := min(start+leChunkSize, len(set))
+				copy(hashes[start:end], scheme.Hash(set[start:end]))
+			}
+		}()
+	}
+	wg.Wait()
 
-	psiCtx, err := psi.ServerInitialize(hashes, treePath)
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return hashes, nil
+}
+
+// InitServer initializes the PSI server context with sanction data hashed
+// under scheme. The set is hashed on the adapter's tree workers; the tree
+// itself is built by one library call. It returns ctx.Err() as soon as ctx
+// is done.
+func (a *Adapter) InitServer(ctx context.Context, sanctionSet []string, treePath string, scheme HashScheme) (*ServerContext, error) {
+	hashes, err := hashContext(ctx, scheme, sanctionSet, a.buildWorkers())
+	if err != nil {
+		return nil, err
+	}
+	return a.initServerHashes(ctx, hashes, treePath, scheme)
+}
+
+// initServerHashes builds the tree for hashes at treePath, sealing it when
+// the adapter has a file cipher, and writes its manifest. The build cannot
+// be interrupted: when ctx is done it is abandoned, and finishes in the
+// background.
+func (a *Adapter) initServerHashes(ctx context.Context, hashes []uint64, treePath string, scheme HashScheme) (*ServerContext, error) {
+	var (
+		psiCtx *psi.ServerInitContext
+		pp     *matrix.Vector
+		msg    *ring.Poly
+		le     *LE.LE
+	)
+	libraryPath := treePath
+	if a.files.Enabled() {
+		libraryPath = treePath + treeWorkSuffix
+	}
+	err := callLE(ctx, PhaseInit, -1, func() (err error) {
+		if psiCtx, err = leServerInitialize(hashes, libraryPath); err != nil {
+			return err
+		}
+		pp, msg, le = leGetPublicParameters(psiCtx)
+		return nil
+	})
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
 	if err != nil {
 		return nil, fmt.Errorf("server initialize: %w", err)
 	}
-	pp, msg, le := psi.GetPublicParameters(psiCtx)
 
 	serverCtx := &ServerContext{
-		Hashes:   hashes,
-		TreePath: treePath,
-		Ctx:      psiCtx,
-		PP:       pp,
-		Msg:      msg,
-		LE:       le,
+		Hashes:      hashes,
+		TreePath:    treePath,
+		LibraryPath: libraryPath,
+		Ctx:         psiCtx,
+		PP:          pp,
+		Msg:         msg,
+		LE:          le,
+		Hash:        scheme,
+		files:       a.files,
+	}
+
+	if serverCtx.sealed() {
+		if err := sealTree(serverCtx); err != nil {
+			return nil, fmt.Errorf("seal tree %s: %w", treePath, err)
+		}
+	}
+	if err := enableTreeWAL(libraryPath); err != nil {
+		log.Printf("Warning: tree %s stays in rollback journal mode: %v", libraryPath, err)
+	}
+	if err := writeTreeManifest(serverCtx); err != nil {
+		log.Printf("Warning: no manifest written for tree %s: %v", treePath, err)
 	}
 
 	return serverCtx, nil
 }
 
-// EncryptClient encrypts the client dataset with server's public parameters
+// EncryptClient encrypts the client dataset with server's public parameters,
+// hashing it under sc's scheme. The dataset is split into chunks encrypted
+// by up to maxWorkers goroutines; the ciphertexts keep the order of
+// clientSet. It stops between chunks when ctx is done and returns ctx.Err().
 func (a *Adapter) EncryptClient(ctx context.Context, clientSet []string, sc *ServerContext) ([]ClientCiphertext, error) {
-	hashes := HashDataPoints(clientSet)
+	if len(clientSet) == 0 {
+		return []ClientCiphertext{}, nil
+	}
+	// Chunks are small enough to give every worker a share
+	workers := max(1, min(a.maxWorkers, len(clientSet)))
+	chunkSize := min(leChunkSize, (len(clientSet)+workers-1)/workers)
+	numChunks := (len(clientSet) + chunkSize - 1) / chunkSize
+	workers = min(workers, numChunks)
+
+	params, err := sc.workerParams(workers)
+	if err != nil {
+		return nil, err
+	}
 
-	ciphers := psi.ClientEncrypt(hashes, sc.PP, sc.Msg, sc.LE)
+	encryptCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var (
+		wg         sync.WaitGroup
+		errOnce    sync.Once
+		encryptErr error
+	)
+	chunks := make([][]ClientCiphertext, numChunks)
+	next := make(chan int)
+	go func() {
+		defer close(next)
+		for i := 0; i < numChunks; i++ {
+			select {
+			case next <- i:
+			case <-encryptCtx.Done():
+				return
+			}
+		}
+	}()
+
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func(p encryptParams) {
+			defer wg.Done()
+			for i := range next {
+				start := i * chunkSize
+				end := min(start+chunkSize, len(clientSet))
+				hashes := sc.Hash.Hash(clientSet[start:end])
+
+				var chunk []ClientCiphertext
+				if err := callLE(encryptCtx, PhaseEncrypt, start, func() error {
+					chunk = leClientEncrypt(sc.LibraryPath, hashes, p.pp, p.msg, p.le)
+					return nil
+				}); err != nil {
+					errOnce.Do(func() {
+						encryptErr = err
+						cancel()
+					})
+					return
+				}
+				chunks[i] = chunk
+			}
+		}(params[w])
+	}
+	wg.Wait()
 
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if encryptErr != nil {
+		return nil, encryptErr
+	}
+	ciphers := make([]ClientCiphertext, 0, len(clientSet))
+	for _, chunk := range chunks {
+		ciphers = append(ciphers, chunk...)
+	}
 	return ciphers, nil
 }
 
-// DetectIntersection finds matching hashes between client and server sets
+// DetectIntersection finds matching hashes between client and server sets.
+// A locked or stale tree database is retried and reopened as configured by
+// SetTreeDBOptions. It stops between chunks of ciphertexts when ctx is done
+// and returns ctx.Err().
 func (a *Adapter) DetectIntersection(ctx context.Context, sc *ServerContext, ciphertexts []ClientCiphertext) ([]uint64, error) {
-	matches, err := psi.DetectIntersectionWithContext(sc.Ctx, ciphertexts)
-	if err != nil {
-		return nil, fmt.Errorf("detect intersection: %w", err)
+	var matches []uint64
+	for start := 0; start < len(ciphertexts); start += leChunkSize {
+		end := min(start+leChunkSize, len(ciphertexts))
+
+		var chunk []uint64
+		err := a.withTree(ctx, sc, func(psiCtx *psi.ServerInitContext) error {
+			return callLE(ctx, PhaseIntersect, start, func() (err error) {
+				chunk, err = leDetectIntersection(sc.LibraryPath, psiCtx, ciphertexts[start:end])
+				return err
+			})
+		})
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		if err != nil {
+			return nil, fmt.Errorf("detect intersection: %w", err)
+		}
+		matches = append(matches, chunk...)
 	}
 
 	return matches, nil
@@ -91,12 +384,41 @@ func (a *Adapter) EstimateMemory(customerCount, sanctionCount int) float64 {
 	return float64(totalRecords) * 35.0 / 1000.0
 }
 
+// ciphertextBytesPerRecord is the rough size of one encrypted client record
+// on the wire with the default LE parameters
+const ciphertextBytesPerRecord = 40 * 1024
+
+// IntersectBodyBytes is the body limit of an intersect request carrying
+// at most maxCiphertexts ciphertexts: twice their rough size, for JSON and
+// parameter overhead, plus room for the rest of the request. 0 ciphertexts
+// (no cap) gives 0, no limit.
+func IntersectBodyBytes(maxCiphertexts int) int64 {
+	if maxCiphertexts <= 0 {
+		return 0
+	}
+	return 2*int64(maxCiphertexts)*ciphertextBytesPerRecord + 1<<20
+}
+
+// EstimateCiphertextBytes estimates the intersect payload for a client set.
+// Batched servers receive the whole set once per batch.
+func (a *Adapter) EstimateCiphertextBytes(customerCount, batches int) int64 {
+	return int64(customerCount) * int64(max(1, batches)) * ciphertextBytesPerRecord
+}
+
+// EstimateBatches predicts how many batches a server with this adapter's
+// memory would split sanctionCount records into
+func (a *Adapter) EstimateBatches(sanctionCount int) int {
+	batchSize := a.CalculateOptimalBatchSize()
+	return max(1, (sanctionCount+batchSize-1)/batchSize)
+}
+
 // GetWorkerCount returns the number of workers that will be used
 func (a *Adapter) GetWorkerCount() int {
 	return a.maxWorkers
 }
 
-// HashDataPoints converts strings to uint64 hashes using the utils package
+// HashDataPoints converts strings to uint64 hashes using the utils package.
+// This is the legacy unkeyed scheme; sessions hash with their HashScheme.
 func HashDataPoints(dataPoints []string) []uint64 {
 	return utils.HashDataPoints(dataPoints)
 }
@@ -127,15 +449,43 @@ func SerializeSanction(name, dob, country, program string) string {
 func SerializeDynamic(values map[string]string, columns []string) string {
 	var parts []string
 	for _, col := range columns {
-		val := values[col]
-		if col == "name" || col == "country" || col == "program" {
-			val = normalizeString(val)
-		}
-		parts = append(parts, val)
+		parts = append(parts, NormalizeColumn(col, values[col]))
 	}
 	return strings.Join(parts, "|")
 }
 
+// NormalizeColumn returns a column value as it is serialized for hashing
+func NormalizeColumn(column, value string) string {
+	switch column {
+	case "name", "country", "program":
+		return normalizeString(value)
+	case "imo", "registration":
+		return normalizeIdentifier(value)
+	case "dob":
+		// Lists normalize their dates on load; only unambiguous ones are
+		// rewritten here
+		return NormalizeDate(value, "")
+	}
+	// Custom list fields were normalized by type when stored
+	return normalizeString(value)
+}
+
+// ColumnNormalization describes what NormalizeColumn does to a column's
+// values, for servers to publish. Keep the two in step.
+func ColumnNormalization(column string) string {
+	switch column {
+	case "name":
+		return "transliterated by the session's profile, lowercased and trimmed"
+	case "country", "program":
+		return "lowercased and trimmed"
+	case "imo", "registration":
+		return "lowercased without spaces, hyphens, dots or a leading IMO"
+	case "dob":
+		return "YYYY-MM-DD; numeric dates with the year last are read in the lists' date order"
+	}
+	return "normalized by its field type when stored, then lowercased and trimmed"
+}
+
 // normalizeString performs basic normalization (lowercase, trim)
 func normalizeString(s string) string {
 	// Normalize to lowercase and trim whitespace for consistent matching
@@ -146,7 +496,8 @@ func normalizeString(s string) string {
 
 // ValidateMemoryRequirement checks if operation fits within memory constraints
 func (a *Adapter) ValidateMemoryRequirement(customerCount, sanctionCount int, maxRAMGB float64) error {
-	estimate := a.EstimateMemory(customerCount, sanctionCount)
+	// EstimateMemory reports MB
+	estimate := a.EstimateMemory(customerCount, sanctionCount) / 1024
 	threshold := maxRAMGB * 0.85 // Use 85% threshold for safety
 
 	if estimate > threshold {
@@ -160,69 +511,75 @@ type SerializedServerParams = psi.SerializableParams
 
 // SerializeParams serializes the server's public parameters using the library's method
 func (a *Adapter) SerializeParams(sc *ServerContext) (*SerializedServerParams, error) {
-	params := psi.SerializeParameters(sc.PP, sc.Msg, sc.LE)
-	return params, nil
+	return psiSerialize(sc)
+}
+
+func psiSerialize(sc *ServerContext) (params *SerializedServerParams, err error) {
+	err = guardLE(PhaseSerialize, -1, func() error {
+		params = leSerializeParameters(sc.PP, sc.Msg, sc.LE)
+		return nil
+	})
+	return params, err
 }
 
 // DeserializeParams reconstructs the server parameters using the library's method
 func (a *Adapter) DeserializeParams(params *SerializedServerParams) (*matrix.Vector, *ring.Poly, *LE.LE, error) {
-	pp, msg, le, err := psi.DeserializeParameters(params)
+	var (
+		pp  *matrix.Vector
+		msg *ring.Poly
+		le  *LE.LE
+	)
+	err := guardLE(PhaseDeserialize, -1, func() (err error) {
+		pp, msg, le, err = leDeserializeParameters(params)
+		return err
+	})
 	if err != nil {
 		return nil, nil, nil, fmt.Errorf("library deserialize failed: %w", err)
 	}
 	return pp, msg, le, nil
 }
 
-// PerformanceMonitor wraps the PSI library's performance monitor
-type PerformanceMonitor struct {
-	monitor *psi.PerformanceMonitor
-}
-
-// NewPerformanceMonitor creates a new performance monitor
-func (a *Adapter) NewPerformanceMonitor() *PerformanceMonitor {
-	return &PerformanceMonitor{
-		monitor: psi.NewPerformanceMonitor(),
-	}
-}
-
-// GetMetrics returns all performance metrics from the PSI library
-func (pm *PerformanceMonitor) GetMetrics() map[string]interface{} {
-	if pm.monitor == nil {
-		return make(map[string]interface{})
-	}
-	return pm.monitor.GetMetrics()
-}
-
-// GetMemoryUsage returns current memory statistics
-func (pm *PerformanceMonitor) GetMemoryUsage() map[string]interface{} {
-	if pm.monitor == nil {
-		return make(map[string]interface{})
-	}
-	return pm.monitor.GetMemoryUsage()
-}
-
-// GetThroughput calculates operations per second
-func (pm *PerformanceMonitor) GetThroughput() float64 {
-	if pm.monitor == nil {
-		return 0.0
-	}
-	return pm.monitor.GetThroughput()
-}
-
 // ============================================================================
 // BATCH PSI SUPPORT - For large datasets that exceed RAM limits
 // ============================================================================
 
-// BatchServerContext holds context for batch-processed PSI with large datasets
+// BatchServerContext holds context for batch-processed PSI with large datasets.
+// Every batch has its own tree and public parameters, so client data must be
+// encrypted once per batch and intersected against the matching batch only.
+//
+// Only the most recently used batches stay in memory; the rest are spilled
+// to disk and loaded again on demand (see Batch).
 type BatchServerContext struct {
-	Batches        []*ServerContext // Individual batch contexts
-	BatchSize      int              // Records per batch
-	TotalRecords   int              // Total server records
-	TreePathPrefix string           // Prefix for batch tree files
+	BatchSize      int                       // Records per batch
+	TotalRecords   int                       // Total server records
+	TreePathPrefix string                    // Prefix for batch tree files
+	Params         []*SerializedServerParams // Public params of every batch, always resident
+	Hash           HashScheme                // Hashes set elements for every batch
+
+	mu          sync.Mutex
+	files       *atrest.Cipher
+	rebuild     func(i int) (*ServerContext, error) // Rebuilds a batch that failed validation
+	spillDir    string                              // Holds spilled batch contexts
+	spillPaths  []string                            // Per batch; "" if the batch could not be spilled
+	resident    map[int]*ServerContext              // Batches currently in memory
+	loading     map[int]*batchLoad                  // Batches being loaded or rebuilt
+	lru         []int                               // Resident batch indexes, least recently used first
+	maxResident int                                 // 0 keeps every batch resident
+}
+
+// batchLoad is one load of a batch, shared by every caller that asks for the
+// batch while it runs
+type batchLoad struct {
+	done chan struct{}
+	sc   *ServerContext
+	err  error
 }
 
 // CalculateOptimalBatchSize determines batch size based on available RAM
 func (a *Adapter) CalculateOptimalBatchSize() int {
+	if a.batchSize > 0 {
+		return a.batchSize
+	}
 	var m runtime.MemStats
 	runtime.ReadMemStats(&m)
 
@@ -255,83 +612,366 @@ func (a *Adapter) ShouldUseBatching(recordCount int) bool {
 	return recordCount > optimalBatch
 }
 
+// BuildProgress is called as batch trees finish building
+type BuildProgress func(done, total int)
+
 // InitServerBatched initializes PSI with batch processing for large datasets
-// It automatically determines batch size based on available RAM
-func (a *Adapter) InitServerBatched(ctx context.Context, sanctionSet []string, treePathPrefix string) (*BatchServerContext, error) {
+// It automatically determines batch size based on available RAM. Batch trees
+// are built by up to the adapter's tree worker count in parallel; progress,
+// if non-nil, is called after each batch completes.
+func (a *Adapter) InitServerBatched(ctx context.Context, sanctionSet []string, treePathPrefix string, scheme HashScheme, progress BuildProgress) (*BatchServerContext, error) {
 	batchSize := a.CalculateOptimalBatchSize()
 	totalRecords := len(sanctionSet)
 
-	if totalRecords <= batchSize {
-		// No batching needed, use single context
-		sc, err := a.InitServer(ctx, sanctionSet, treePathPrefix+".db")
-		if err != nil {
-			return nil, err
-		}
-		return &BatchServerContext{
-			Batches:        []*ServerContext{sc},
-			BatchSize:      batchSize,
-			TotalRecords:   totalRecords,
-			TreePathPrefix: treePathPrefix,
-		}, nil
-	}
-
 	// Calculate number of batches
-	numBatches := (totalRecords + batchSize - 1) / batchSize
+	numBatches := max(1, (totalRecords+batchSize-1)/batchSize)
+
+	spillDir, err := os.MkdirTemp(filepath.Dir(treePathPrefix), filepath.Base(treePathPrefix)+"_ctx_")
+	if err != nil {
+		return nil, fmt.Errorf("create batch spill directory: %w", err)
+	}
 
 	bsc := &BatchServerContext{
-		Batches:        make([]*ServerContext, 0, numBatches),
 		BatchSize:      batchSize,
 		TotalRecords:   totalRecords,
 		TreePathPrefix: treePathPrefix,
+		Params:         make([]*SerializedServerParams, numBatches),
+		Hash:           scheme,
+		spillDir:       spillDir,
+		spillPaths:     make([]string, numBatches),
+		resident:       make(map[int]*ServerContext),
+		loading:        make(map[int]*batchLoad),
+		maxResident:    a.residentBatches,
+		files:          a.files,
+	}
+	hashes := make([][]uint64, numBatches)
+	bsc.rebuild = func(i int) (*ServerContext, error) {
+		// Rebuilds happen on load, outside any one request, so they
+		// always run to completion
+		return a.initServerHashes(context.Background(), hashes[i], batchTreePath(treePathPrefix, i, numBatches), scheme)
 	}
 
-	// Initialize each batch sequentially to manage RAM
-	for i := 0; i < numBatches; i++ {
-		start := i * batchSize
-		end := start + batchSize
-		if end > totalRecords {
-			end = totalRecords
-		}
+	// Each worker holds one batch's tree in memory while it builds, so the
+	// worker count multiplies peak RAM
+	workers := min(a.buildWorkers(), numBatches)
+	if workers > 1 {
+		log.Printf("Building %d batch trees with %d workers", numBatches, workers)
+	}
 
-		batchData := sanctionSet[start:end]
-		treePath := fmt.Sprintf("%s_batch%d.db", treePathPrefix, i)
+	buildCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var (
+		wg       sync.WaitGroup
+		errOnce  sync.Once
+		buildErr error
+		done     int
+	)
+	next := make(chan int)
+	go func() {
+		defer close(next)
+		for i := 0; i < numBatches; i++ {
+			select {
+			case next <- i:
+			case <-buildCtx.Done():
+				return
+			}
+		}
+	}()
+
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range next {
+				if err := a.buildBatch(buildCtx, bsc, sanctionSet, hashes, i, numBatches); err != nil {
+					errOnce.Do(func() {
+						buildErr = err
+						cancel()
+					})
+					return
+				}
 
-		sc, err := a.InitServer(ctx, batchData, treePath)
-		if err != nil {
-			// Cleanup already initialized batches
-			for _, prev := range bsc.Batches {
-				if prev != nil && prev.TreePath != "" {
-					// Cleanup would happen here
+				bsc.mu.Lock()
+				done++
+				completed := done
+				bsc.mu.Unlock()
+				if progress != nil {
+					progress(completed, numBatches)
 				}
+
+				// Force GC between batches to free memory
+				runtime.GC()
 			}
-			return nil, fmt.Errorf("batch %d init failed: %w", i, err)
+		}()
+	}
+	wg.Wait()
+
+	if ctx.Err() != nil {
+		buildErr = ctx.Err()
+	}
+	if buildErr != nil {
+		a.CleanupBatchContext(bsc)
+		return nil, buildErr
+	}
+	return bsc, nil
+}
+
+// buildBatch builds the tree for batch i, spills it to disk and admits it to
+// the resident set
+func (a *Adapter) buildBatch(ctx context.Context, bsc *BatchServerContext, sanctionSet []string, hashes [][]uint64, i, numBatches int) error {
+	start := i * bsc.BatchSize
+	end := min(start+bsc.BatchSize, len(sanctionSet))
+
+	// Batches are already built in parallel
+	batchHashes, err := hashContext(ctx, bsc.Hash, sanctionSet[start:end], 1)
+	if err != nil {
+		return err
+	}
+	hashes[i] = batchHashes
+	sc, err := a.initServerHashes(ctx, hashes[i], batchTreePath(bsc.TreePathPrefix, i, numBatches), bsc.Hash)
+	if err != nil {
+		return fmt.Errorf("batch %d init failed: %w", i, err)
+	}
+	params, err := a.SerializeParams(sc)
+	if err != nil {
+		return fmt.Errorf("batch %d: serialize params: %w", i, err)
+	}
+
+	spillPath := ""
+	if bsc.maxResident > 0 {
+		spillPath = filepath.Join(bsc.spillDir, fmt.Sprintf("batch%d.ctx", i))
+		if err := spillContext(bsc.files, sc, spillPath); err != nil {
+			// Keep the batch resident rather than fail the whole build
+			log.Printf("Warning: batch %d cannot be spilled to disk and stays in memory: %v", i, err)
+			spillPath = ""
 		}
+	}
 
-		bsc.Batches = append(bsc.Batches, sc)
+	bsc.mu.Lock()
+	bsc.Params[i] = params
+	bsc.spillPaths[i] = spillPath
+	bsc.admitLocked(i, sc)
+	bsc.mu.Unlock()
+	return nil
+}
 
-		// Force GC between batches to free memory
-		runtime.GC()
+// ErrBatchRebuilt is returned by Batch when a batch failed validation and was
+// rebuilt with new public parameters
+var ErrBatchRebuilt = errors.New("batch was rebuilt with new parameters")
+
+// batchTreePath names the tree database of batch i
+func batchTreePath(prefix string, i, numBatches int) string {
+	if numBatches == 1 {
+		// No batching needed, use single context
+		return prefix + ".db"
 	}
+	return fmt.Sprintf("%s_batch%d.db", prefix, i)
+}
 
-	return bsc, nil
+// Len returns the number of batches
+func (bsc *BatchServerContext) Len() int {
+	return len(bsc.Params)
+}
+
+// TreePaths returns the tree database of every batch, in batch order
+func (bsc *BatchServerContext) TreePaths() []string {
+	paths := make([]string, bsc.Len())
+	for i := range paths {
+		paths[i] = batchTreePath(bsc.TreePathPrefix, i, len(paths))
+	}
+	return paths
+}
+
+// Resident returns the number of batches currently in memory
+func (bsc *BatchServerContext) Resident() int {
+	bsc.mu.Lock()
+	defer bsc.mu.Unlock()
+	return len(bsc.resident)
+}
+
+// Batch returns batch i, loading it from disk if it was evicted. The
+// returned context stays usable after it is evicted from the cache.
+//
+// Loads run outside the lock, so batches load in parallel; callers asking
+// for a batch that is already loading wait for that load.
+func (bsc *BatchServerContext) Batch(i int) (*ServerContext, error) {
+	if i < 0 || i >= bsc.Len() {
+		return nil, fmt.Errorf("batch %d out of range (%d batches)", i, bsc.Len())
+	}
+
+	bsc.mu.Lock()
+	if sc, ok := bsc.resident[i]; ok {
+		bsc.touchLocked(i)
+		bsc.mu.Unlock()
+		return sc, nil
+	}
+	if l, ok := bsc.loading[i]; ok {
+		bsc.mu.Unlock()
+		<-l.done
+		return l.sc, l.err
+	}
+	l := &batchLoad{done: make(chan struct{})}
+	bsc.loading[i] = l
+	spillPath := bsc.spillPaths[i]
+	bsc.mu.Unlock()
+
+	sc, params, spillPath, err := bsc.load(i, spillPath)
+
+	bsc.mu.Lock()
+	delete(bsc.loading, i)
+	if sc != nil {
+		if params != nil {
+			bsc.Params[i] = params
+			bsc.spillPaths[i] = spillPath
+		}
+		bsc.admitLocked(i, sc)
+	}
+	bsc.mu.Unlock()
+
+	if params != nil {
+		// The batch's params changed with the rebuild, so callers holding
+		// the old params must start over
+		sc, err = nil, ErrBatchRebuilt
+	}
+	l.sc, l.err = sc, err
+	close(l.done)
+	return sc, err
+}
+
+// load reads batch i from spillPath and verifies its tree, rebuilding the
+// batch if either fails. A rebuilt batch comes with its new params and spill
+// path.
+func (bsc *BatchServerContext) load(i int, spillPath string) (*ServerContext, *SerializedServerParams, string, error) {
+	var err error
+	if bsc.files.Enabled() {
+		// Decoding the context opens the tree, so its working copy must
+		// exist first
+		treePath := batchTreePath(bsc.TreePathPrefix, i, bsc.Len())
+		err = restoreTree(bsc.files, treePath, treePath+treeWorkSuffix)
+	}
+	var sc *ServerContext
+	if err == nil {
+		sc, err = loadContext(bsc.files, spillPath)
+	}
+	if err == nil {
+		sc.files = bsc.files
+		err = VerifyTree(sc)
+	}
+	if err == nil {
+		return sc, nil, spillPath, nil
+	}
+	if bsc.rebuild == nil {
+		return nil, nil, "", fmt.Errorf("load batch %d: %w", i, err)
+	}
+
+	log.Printf("Batch %d failed validation, rebuilding: %v", i, err)
+	if sc, err = bsc.rebuild(i); err != nil {
+		return nil, nil, "", fmt.Errorf("rebuild batch %d: %w", i, err)
+	}
+	if spillPath != "" {
+		if err := spillContext(bsc.files, sc, spillPath); err != nil {
+			log.Printf("Warning: rebuilt batch %d cannot be spilled to disk and stays in memory: %v", i, err)
+			spillPath = ""
+		}
+	}
+	params, err := psiSerialize(sc)
+	if err != nil {
+		return nil, nil, "", fmt.Errorf("rebuild batch %d: %w", i, err)
+	}
+	return sc, params, spillPath, nil
+}
+
+// ParamsSnapshot returns the current public params of every batch
+func (bsc *BatchServerContext) ParamsSnapshot() []*SerializedServerParams {
+	bsc.mu.Lock()
+	defer bsc.mu.Unlock()
+	return append([]*SerializedServerParams(nil), bsc.Params...)
+}
+
+// admitLocked makes batch i resident and evicts the least recently used
+// batches beyond the limit, removing the working copies of sealed trees.
+// Batches without a spill file are never evicted.
+func (bsc *BatchServerContext) admitLocked(i int, sc *ServerContext) {
+	bsc.resident[i] = sc
+	bsc.touchLocked(i)
+	if bsc.maxResident <= 0 {
+		return
+	}
+
+	evictable := 0
+	for _, idx := range bsc.lru {
+		if bsc.spillPaths[idx] != "" {
+			evictable++
+		}
+	}
+	for n := 0; n < len(bsc.lru) && evictable > bsc.maxResident; {
+		idx := bsc.lru[n]
+		if idx == i || bsc.spillPaths[idx] == "" {
+			n++
+			continue
+		}
+		if sc := bsc.resident[idx]; sc.sealed() {
+			removeWorkingCopy(sc.LibraryPath)
+		}
+		delete(bsc.resident, idx)
+		bsc.lru = append(bsc.lru[:n], bsc.lru[n+1:]...)
+		evictable--
+	}
+}
+
+// touchLocked marks batch i as most recently used
+func (bsc *BatchServerContext) touchLocked(i int) {
+	for n, idx := range bsc.lru {
+		if idx == i {
+			bsc.lru = append(bsc.lru[:n], bsc.lru[n+1:]...)
+			break
+		}
+	}
+	bsc.lru = append(bsc.lru, i)
+}
+
+// spillContext writes a batch context to path. The context includes the
+// batch's secret key material, so it is encrypted when a cipher is set.
+func spillContext(files *atrest.Cipher, sc *ServerContext, path string) error {
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(sc); err != nil {
+		return err
+	}
+	return files.WriteFile(path, &buf, 0600)
+}
+
+// loadContext reads a batch context written by spillContext
+func loadContext(files *atrest.Cipher, path string) (*ServerContext, error) {
+	if path == "" {
+		return nil, fmt.Errorf("batch was not spilled")
+	}
+	data, err := files.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var sc ServerContext
+	if err := gob.NewDecoder(bytes.NewReader(data)).Decode(&sc); err != nil {
+		return nil, err
+	}
+	return &sc, nil
 }
 
 // DetectIntersectionBatched runs intersection detection across all batches
 // and aggregates the results
 func (a *Adapter) DetectIntersectionBatched(ctx context.Context, bsc *BatchServerContext, clientSet []string) ([]uint64, error) {
-	if len(bsc.Batches) == 1 {
-		// Single batch, use standard detection
-		ciphers, err := a.EncryptClient(ctx, clientSet, bsc.Batches[0])
+	allMatches := make(map[uint64]bool)
+
+	for i := 0; i < bsc.Len(); i++ {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		batch, err := bsc.Batch(i)
 		if err != nil {
 			return nil, err
 		}
-		return a.DetectIntersection(ctx, bsc.Batches[0], ciphers)
-	}
 
-	// Multiple batches: aggregate matches
-	allMatches := make(map[uint64]bool)
-
-	for i, batch := range bsc.Batches {
 		// Encrypt client data with this batch's parameters
 		ciphers, err := a.EncryptClient(ctx, clientSet, batch)
 		if err != nil {
@@ -362,11 +1002,13 @@ func (a *Adapter) DetectIntersectionBatched(ctx context.Context, bsc *BatchServe
 	return result, nil
 }
 
-// CleanupBatchContext removes temporary files created during batch processing
+// CleanupBatchContext removes the spilled batch contexts. Batches that are
+// not resident can no longer be loaded afterwards.
 func (a *Adapter) CleanupBatchContext(bsc *BatchServerContext) {
-	if bsc == nil {
+	if bsc == nil || bsc.spillDir == "" {
 		return
 	}
-	// Note: Tree files are managed by the caller or cleaned up on server shutdown
-	// This is a placeholder for any additional cleanup needed
+	if err := os.RemoveAll(bsc.spillDir); err != nil {
+		log.Printf("Warning: failed to remove batch spill directory %s: %v", bsc.spillDir, err)
+	}
 }
@@ -0,0 +1,321 @@
+package psiadapter
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/SanthoshCheemala/LE-PSI/pkg/LE"
+	"github.com/SanthoshCheemala/LE-PSI/pkg/matrix"
+	"github.com/SanthoshCheemala/LE-PSI/pkg/psi"
+	"github.com/tuneinsight/lattigo/v3/ring"
+)
+
+// clearLE stands in for the LE library in tests. A tree holds its hashes in
+// the clear, and the hashes encrypted for a tree are queued and taken back
+// in order by the intersections against it, so it needs a single
+// encryption worker.
+type clearLE struct {
+	mu      sync.Mutex
+	trees   map[string]map[uint64]bool // Tree path to its hashes
+	pending map[string][]uint64        // Tree path to hashes encrypted but not yet intersected
+}
+
+// useClearLE replaces the LE library with a clearLE until the test ends
+func useClearLE(t *testing.T) *clearLE {
+	t.Helper()
+	c := &clearLE{trees: make(map[string]map[uint64]bool), pending: make(map[string][]uint64)}
+
+	init, params, serialize, deserialize := leServerInitialize, leGetPublicParameters, leSerializeParameters, leDeserializeParameters
+	encrypt, detect := leClientEncrypt, leDetectIntersection
+	t.Cleanup(func() {
+		leServerInitialize, leGetPublicParameters, leSerializeParameters, leDeserializeParameters = init, params, serialize, deserialize
+		leClientEncrypt, leDetectIntersection = encrypt, detect
+	})
+
+	leServerInitialize = c.serverInitialize
+	leGetPublicParameters = func(*psi.ServerInitContext) (*matrix.Vector, *ring.Poly, *LE.LE) { return nil, nil, nil }
+	leSerializeParameters = func(*matrix.Vector, *ring.Poly, *LE.LE) *psi.SerializableParams { return &psi.SerializableParams{} }
+	leDeserializeParameters = func(*psi.SerializableParams) (*matrix.Vector, *ring.Poly, *LE.LE, error) { return nil, nil, nil, nil }
+	leClientEncrypt = c.clientEncrypt
+	leDetectIntersection = c.detectIntersection
+	return c
+}
+
+func (c *clearLE) serverInitialize(hashes []uint64, treePath string) (*psi.ServerInitContext, error) {
+	tree := make(map[uint64]bool, len(hashes))
+	for _, h := range hashes {
+		tree[h] = true
+	}
+	// A file for the manifest to cover, as the library's tree database
+	if err := os.WriteFile(treePath, fmt.Appendf(nil, "%v", hashes), 0600); err != nil {
+		return nil, err
+	}
+	c.mu.Lock()
+	c.trees[treePath] = tree
+	c.mu.Unlock()
+	return &psi.ServerInitContext{}, nil
+}
+
+func (c *clearLE) clientEncrypt(treePath string, hashes []uint64, _ *matrix.Vector, _ *ring.Poly, _ *LE.LE) []ClientCiphertext {
+	c.mu.Lock()
+	c.pending[treePath] = append(c.pending[treePath], hashes...)
+	c.mu.Unlock()
+	return make([]ClientCiphertext, len(hashes))
+}
+
+func (c *clearLE) detectIntersection(treePath string, _ *psi.ServerInitContext, ciphertexts []ClientCiphertext) ([]uint64, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	pending := c.pending[treePath]
+	if len(pending) < len(ciphertexts) {
+		return nil, fmt.Errorf("%d ciphertexts for %s, %d encrypted", len(ciphertexts), treePath, len(pending))
+	}
+	var matches []uint64
+	for _, h := range pending[:len(ciphertexts)] {
+		if c.trees[treePath][h] {
+			matches = append(matches, h)
+		}
+	}
+	c.pending[treePath] = pending[len(ciphertexts):]
+	return matches, nil
+}
+
+func TestDetectIntersectionBatchedSpilled(t *testing.T) {
+	useClearLE(t)
+	a := NewAdapter(1)
+	a.batchSize = 50
+	a.SetResidentBatches(1)
+
+	scheme, err := NewHashScheme(HashSipHash)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sanctions := make([]string, 150)
+	for i := range sanctions {
+		sanctions[i] = fmt.Sprintf("sanction %03d|1970-01-01|us", i)
+	}
+	bsc, err := a.InitServerBatched(context.Background(), sanctions, filepath.Join(t.TempDir(), "tree"), scheme, nil)
+	if err != nil {
+		t.Fatalf("InitServerBatched: %v", err)
+	}
+	t.Cleanup(func() { a.CleanupBatchContext(bsc) })
+	if bsc.Len() != 3 {
+		t.Fatalf("%d batches, want 3", bsc.Len())
+	}
+	if n := bsc.Resident(); n != 1 {
+		t.Fatalf("%d batches resident after the build, want 1", n)
+	}
+
+	// One customer in each batch, and two in none
+	customers := []string{sanctions[7], "customer a|1980-02-02|gb", sanctions[72], sanctions[149], "customer b|1990-03-03|fr"}
+	want := scheme.Hash([]string{sanctions[7], sanctions[72], sanctions[149]})
+	slices.Sort(want)
+
+	// The first run loads the batches spilled during the build, the second
+	// those spilled during the first run
+	for run := 1; run <= 2; run++ {
+		got, err := a.DetectIntersectionBatched(context.Background(), bsc, customers)
+		if err != nil {
+			t.Fatalf("run %d: DetectIntersectionBatched: %v", run, err)
+		}
+		slices.Sort(got)
+		if !slices.Equal(got, want) {
+			t.Errorf("run %d: matches %v, want %v", run, got, want)
+		}
+		if n := bsc.Resident(); n != 1 {
+			t.Errorf("run %d: %d batches resident, want 1", run, n)
+		}
+	}
+}
+
+// buildBatches builds n batches of 50 sanctions with one resident
+func buildBatches(t *testing.T, n int) (*Adapter, *BatchServerContext) {
+	t.Helper()
+	a := NewAdapter(1)
+	a.batchSize = 50
+	a.SetResidentBatches(1)
+
+	scheme, err := NewHashScheme(HashSipHash)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sanctions := make([]string, 50*n)
+	for i := range sanctions {
+		sanctions[i] = fmt.Sprintf("sanction %03d|1970-01-01|us", i)
+	}
+	bsc, err := a.InitServerBatched(context.Background(), sanctions, filepath.Join(t.TempDir(), "tree"), scheme, nil)
+	if err != nil {
+		t.Fatalf("InitServerBatched: %v", err)
+	}
+	t.Cleanup(func() { a.CleanupBatchContext(bsc) })
+	return a, bsc
+}
+
+func TestBatchConcurrentLoads(t *testing.T) {
+	useClearLE(t)
+	_, bsc := buildBatches(t, 4)
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 4*20)
+	for i := 0; i < bsc.Len(); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for range 20 {
+				sc, err := bsc.Batch(i)
+				if err != nil {
+					errs <- fmt.Errorf("batch %d: %w", i, err)
+					return
+				}
+				if want := batchTreePath(bsc.TreePathPrefix, i, bsc.Len()); sc.TreePath != want {
+					errs <- fmt.Errorf("batch %d: got tree %s, want %s", i, sc.TreePath, want)
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Error(err)
+	}
+	if n := bsc.Resident(); n != 1 {
+		t.Errorf("%d batches resident, want 1", n)
+	}
+}
+
+func TestBatchConcurrentRebuilds(t *testing.T) {
+	useClearLE(t)
+	_, bsc := buildBatches(t, 4)
+	for i := range 2 {
+		if err := os.WriteFile(batchTreePath(bsc.TreePathPrefix, i, bsc.Len()), []byte("corrupt"), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Each rebuild waits for the other, so they fail unless they run at
+	// the same time
+	var (
+		started  sync.WaitGroup
+		mu       sync.Mutex
+		rebuilds = make(map[int]int)
+	)
+	started.Add(2)
+	bothStarted := make(chan struct{})
+	go func() { started.Wait(); close(bothStarted) }()
+	rebuild := bsc.rebuild
+	bsc.rebuild = func(i int) (*ServerContext, error) {
+		mu.Lock()
+		rebuilds[i]++
+		mu.Unlock()
+		started.Done()
+		select {
+		case <-bothStarted:
+		case <-time.After(5 * time.Second):
+			return nil, errors.New("rebuilds of different batches ran one at a time")
+		}
+		return rebuild(i)
+	}
+	before := bsc.ParamsSnapshot()
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 4)
+	for _, i := range []int{0, 0, 1, 1} {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			// Callers that find the batch already rebuilt get it
+			if _, err := bsc.Batch(i); err != nil && !errors.Is(err, ErrBatchRebuilt) {
+				errs <- fmt.Errorf("batch %d: %w", i, err)
+			}
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Error(err)
+	}
+
+	after := bsc.ParamsSnapshot()
+	for i := range 2 {
+		if rebuilds[i] != 1 {
+			t.Errorf("batch %d rebuilt %d times, want 1", i, rebuilds[i])
+		}
+		if after[i] == before[i] {
+			t.Errorf("batch %d params not replaced by the rebuild", i)
+		}
+		if _, err := bsc.Batch(i); err != nil {
+			t.Errorf("batch %d after rebuild: %v", i, err)
+		}
+	}
+}
+
+func TestTreeBuildIndependentOfWorkers(t *testing.T) {
+	useClearLE(t)
+	scheme, err := NewHashScheme(HashSipHash)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sanctions := make([]string, 1000)
+	for i := range sanctions {
+		sanctions[i] = fmt.Sprintf("sanction %04d|1970-01-01|us", i)
+	}
+
+	// build returns the tree InitServer builds and those of
+	// InitServerBatched, as the test library writes them
+	build := func(workers int) (tree []byte, batches [][]byte) {
+		a := NewAdapter(1)
+		a.batchSize = 200
+		a.SetTreeWorkers(workers)
+		dir := t.TempDir()
+
+		sc, err := a.InitServer(context.Background(), sanctions, filepath.Join(dir, "tree.db"), scheme)
+		if err != nil {
+			t.Fatalf("%d workers: InitServer: %v", workers, err)
+		}
+		if !slices.Equal(sc.Hashes, scheme.Hash(sanctions)) {
+			t.Errorf("%d workers: hashes out of order", workers)
+		}
+		if tree, err = os.ReadFile(sc.TreePath); err != nil {
+			t.Fatal(err)
+		}
+
+		bsc, err := a.InitServerBatched(context.Background(), sanctions, filepath.Join(dir, "batch"), scheme, nil)
+		if err != nil {
+			t.Fatalf("%d workers: InitServerBatched: %v", workers, err)
+		}
+		t.Cleanup(func() { a.CleanupBatchContext(bsc) })
+		for _, path := range bsc.TreePaths() {
+			data, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			batches = append(batches, data)
+		}
+		return tree, batches
+	}
+
+	wantTree, wantBatches := build(1)
+	if len(wantBatches) != 5 {
+		t.Fatalf("%d batches, want 5", len(wantBatches))
+	}
+	for _, workers := range []int{2, 4, 0} {
+		tree, batches := build(workers)
+		if !bytes.Equal(tree, wantTree) {
+			t.Errorf("%d workers: tree differs from one worker's", workers)
+		}
+		for i := range wantBatches {
+			if i >= len(batches) || !bytes.Equal(batches[i], wantBatches[i]) {
+				t.Errorf("%d workers: batch %d differs from one worker's", workers, i)
+			}
+		}
+	}
+}
@@ -0,0 +1,163 @@
+package psiadapter
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/SanthoshCheemala/FLARE/backend/internal/models"
+)
+
+// Dates of birth are hashed in ISO 8601 (YYYY-MM-DD) form, so the same date
+// written as 14/03/1968 on one side and 1968-03-14 on the other still
+// matches. Numeric dates with the year last are ambiguous between day-month
+// and month-day order; a column's order is detected from its values that
+// are not, and a configured preference decides when none is.
+
+// DateOrder is the order of day and month in numeric dates with the year
+// last
+type DateOrder string
+
+const (
+	DateOrderDMY DateOrder = "DMY" // 01/02/1990 is 1 February 1990
+	DateOrderMDY DateOrder = "MDY" // 01/02/1990 is 2 January 1990
+
+	// DefaultDateOrder reads ambiguous dates when no order is configured
+	DefaultDateOrder = DateOrderDMY
+)
+
+// isoDateLayout is the form dates are normalized to
+const isoDateLayout = "2006-01-02"
+
+var (
+	yearLastDate  = regexp.MustCompile(`^(\d{1,2})[/.\-](\d{1,2})[/.\-](\d{4})$`)
+	yearFirstDate = regexp.MustCompile(`^(\d{4})[/.\-](\d{1,2})[/.\-](\d{1,2})$`)
+)
+
+// namedDateLayouts are the non-numeric date formats recognized. Month names
+// match regardless of case.
+var namedDateLayouts = []string{
+	"2 Jan 2006", "2 January 2006", "Jan 2, 2006", "January 2, 2006",
+	"2-Jan-2006", "02-Jan-2006", time.RFC3339, "2006-01-02T15:04:05", "2006-01-02 15:04:05",
+}
+
+// ParseDateOrder parses a date order setting, DMY or MDY in any case. An
+// empty setting is DefaultDateOrder.
+func ParseDateOrder(s string) (DateOrder, error) {
+	switch strings.ToUpper(strings.TrimSpace(s)) {
+	case "":
+		return DefaultDateOrder, nil
+	case string(DateOrderDMY):
+		return DateOrderDMY, nil
+	case string(DateOrderMDY):
+		return DateOrderMDY, nil
+	}
+	return "", fmt.Errorf("unknown date order %q (want DMY or MDY)", s)
+}
+
+// DetectDateOrder returns the order of a column of dates: the one its
+// unambiguous year-last dates (a day above 12) mostly follow, or prefer if
+// they do not tell
+func DetectDateOrder(values []string, prefer DateOrder) DateOrder {
+	dmy, mdy := 0, 0
+	for _, v := range values {
+		m := yearLastDate.FindStringSubmatch(strings.TrimSpace(v))
+		if m == nil {
+			continue
+		}
+		first, _ := strconv.Atoi(m[1])
+		second, _ := strconv.Atoi(m[2])
+		switch {
+		case first > 12 && second <= 12:
+			dmy++
+		case second > 12 && first <= 12:
+			mdy++
+		}
+	}
+	switch {
+	case dmy > mdy:
+		return DateOrderDMY
+	case mdy > dmy:
+		return DateOrderMDY
+	}
+	return prefer
+}
+
+// NormalizeDate returns value as YYYY-MM-DD. Year-last numeric dates are
+// read in order unless only the other order gives a valid date; with an
+// empty order, ambiguous ones are returned unchanged. Values that are not a
+// recognized date, such as a bare year, are returned trimmed.
+func NormalizeDate(value string, order DateOrder) string {
+	v := strings.TrimSpace(value)
+	if m := yearFirstDate.FindStringSubmatch(v); m != nil {
+		return isoDate(v, m[1], m[2], m[3])
+	}
+	if m := yearLastDate.FindStringSubmatch(v); m != nil {
+		first, _ := strconv.Atoi(m[1])
+		second, _ := strconv.Atoi(m[2])
+		day, month := m[1], m[2]
+		switch {
+		case first > 12 || second > 12:
+			if first <= 12 {
+				day, month = m[2], m[1]
+			}
+		case first == second:
+		case order == DateOrderMDY:
+			day, month = m[2], m[1]
+		case order != DateOrderDMY:
+			return v
+		}
+		return isoDate(v, m[3], month, day)
+	}
+	for _, layout := range namedDateLayouts {
+		if t, err := time.Parse(layout, v); err == nil {
+			return t.Format(isoDateLayout)
+		}
+	}
+	return v
+}
+
+// isoDate formats a date from its numeric fields, or returns original if
+// they do not form one (such as 31 February)
+func isoDate(original, year, month, day string) string {
+	y, _ := strconv.Atoi(year)
+	m, _ := strconv.Atoi(month)
+	d, _ := strconv.Atoi(day)
+	t := time.Date(y, time.Month(m), d, 0, 0, 0, 0, time.UTC)
+	if t.Year() != y || int(t.Month()) != m || t.Day() != d {
+		return original
+	}
+	return t.Format(isoDateLayout)
+}
+
+// NormalizeCustomerDOBs rewrites the dates of birth of a customer list as
+// YYYY-MM-DD, reading ambiguous ones in the list's detected order. It
+// returns that order.
+func NormalizeCustomerDOBs(customers []*models.Customer, prefer DateOrder) DateOrder {
+	dobs := make([]string, len(customers))
+	for i, c := range customers {
+		dobs[i] = c.DOB
+	}
+	order := DetectDateOrder(dobs, prefer)
+	for _, c := range customers {
+		c.DOB = NormalizeDate(c.DOB, order)
+	}
+	return order
+}
+
+// NormalizeSanctionDOBs rewrites the dates of birth of a sanction list as
+// YYYY-MM-DD, reading ambiguous ones in the list's detected order. Record
+// hashes must be computed afterwards.
+func NormalizeSanctionDOBs(sanctions []*models.Sanction, prefer DateOrder) DateOrder {
+	dobs := make([]string, len(sanctions))
+	for i, s := range sanctions {
+		dobs[i] = s.DOB
+	}
+	order := DetectDateOrder(dobs, prefer)
+	for _, s := range sanctions {
+		s.DOB = NormalizeDate(s.DOB, order)
+	}
+	return order
+}
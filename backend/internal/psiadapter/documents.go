@@ -0,0 +1,54 @@
+package psiadapter
+
+import (
+	"strings"
+
+	"github.com/SanthoshCheemala/FLARE/backend/internal/docnum"
+	"github.com/SanthoshCheemala/FLARE/backend/internal/models"
+)
+
+// documentPrefix tags the set elements of the document channel, so a
+// document number can never collide with a name-channel input
+const documentPrefix = "doc|"
+
+// DocumentHashInputs returns the document channel's set elements for a
+// record: one per valid passport or national ID number among its
+// attributes, as "doc|<kind>|<number>". country, the record's ISO 3166
+// alpha-2 code if known, selects the national ID checksum. Numbers failing
+// validation are left out.
+func DocumentHashInputs(attributes map[string]string, country string) []string {
+	inputs, _ := documentInputs(attributes, country)
+	return inputs
+}
+
+// CustomerDocumentInputs returns a customer's document channel elements, as
+// DocumentHashInputs, and the number of document attributes that failed
+// validation
+func CustomerDocumentInputs(c *models.Customer) ([]string, int) {
+	return documentInputs(c.Attributes, c.Country)
+}
+
+func documentInputs(attributes map[string]string, country string) ([]string, int) {
+	docs, invalid := docnum.FromAttributes(attributes, country)
+	inputs := make([]string, len(docs))
+	for i, d := range docs {
+		inputs[i] = documentPrefix + d.Kind + "|" + d.Number
+	}
+	return inputs, invalid
+}
+
+// IsDocumentInput reports whether a hash input belongs to the document
+// channel
+func IsDocumentInput(serialized string) bool {
+	return strings.HasPrefix(serialized, documentPrefix)
+}
+
+// ParseDocumentInput returns the kind and number of a document channel
+// input
+func ParseDocumentInput(serialized string) (kind, number string, ok bool) {
+	rest, ok := strings.CutPrefix(serialized, documentPrefix)
+	if !ok {
+		return "", "", false
+	}
+	return strings.Cut(rest, "|")
+}
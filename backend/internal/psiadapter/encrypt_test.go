@@ -0,0 +1,56 @@
+package psiadapter
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+// BenchmarkEncryptClient encrypts 2000 customers under the params of a 100
+// record tree with one worker and with one per CPU. The ratio of the two is
+// the speedup of the worker pool:
+//
+//	go test ./internal/psiadapter -run '^$' -bench EncryptClient
+func BenchmarkEncryptClient(b *testing.B) {
+	log.SetOutput(io.Discard)
+	b.Cleanup(func() { log.SetOutput(os.Stderr) })
+
+	ctx := context.Background()
+	scheme, err := NewHashScheme(HashSHA256)
+	if err != nil {
+		b.Fatal(err)
+	}
+	sanctions := make([]string, 100)
+	for i := range sanctions {
+		sanctions[i] = SerializeSanction(fmt.Sprintf("Sanctioned Party %d", i), "1970-01-01", "XX", "BENCH")
+	}
+	sc, err := NewAdapter(1).InitServer(ctx, sanctions, filepath.Join(b.TempDir(), "tree.db"), scheme)
+	if err != nil {
+		b.Fatalf("build tree: %v", err)
+	}
+	customers := make([]string, 2000)
+	for i := range customers {
+		customers[i] = SerializeCustomer(fmt.Sprintf("Customer %d", i), "1980-01-01", "YY")
+	}
+
+	counts := []int{1}
+	if n := runtime.NumCPU(); n > 1 {
+		counts = append(counts, n)
+	}
+	for _, workers := range counts {
+		b.Run(fmt.Sprintf("workers=%d", workers), func(b *testing.B) {
+			a := NewAdapter(workers)
+			for b.Loop() {
+				if _, err := a.EncryptClient(ctx, customers, sc); err != nil {
+					b.Fatal(err)
+				}
+			}
+			b.ReportMetric(float64(b.N*len(customers))/b.Elapsed().Seconds(), "records/s")
+		})
+	}
+}
@@ -0,0 +1,118 @@
+package psiadapter
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"runtime/debug"
+	"sync"
+)
+
+// Phases of the LE library calls reported in PSIError
+const (
+	PhaseInit        = "init"
+	PhaseEncrypt     = "encrypt"
+	PhaseIntersect   = "intersect"
+	PhaseSerialize   = "serialize"
+	PhaseDeserialize = "deserialize"
+)
+
+// ErrLibraryPanic is wrapped by a PSIError for a recovered library panic
+var ErrLibraryPanic = errors.New("LE library panicked")
+
+// PSIError is an error returned by, or a panic recovered from, an LE library
+// call
+type PSIError struct {
+	Phase  string // One of the Phase constants
+	Record int    // First record of the chunk the call handled; -1 if not per record
+	Panic  bool   // Err wraps ErrLibraryPanic and the panic value
+	Err    error
+}
+
+func (e *PSIError) Error() string {
+	if e.Record >= 0 {
+		return fmt.Sprintf("psi %s at record %d: %v", e.Phase, e.Record, e.Err)
+	}
+	return fmt.Sprintf("psi %s: %v", e.Phase, e.Err)
+}
+
+func (e *PSIError) Unwrap() error {
+	return e.Err
+}
+
+// LibraryErrorCount counts the failures of one phase
+type LibraryErrorCount struct {
+	Errors int64 `json:"errors"`
+	Panics int64 `json:"panics"`
+}
+
+var libraryErrors = struct {
+	sync.Mutex
+	counts map[string]LibraryErrorCount
+}{counts: make(map[string]LibraryErrorCount)}
+
+// LibraryErrors returns the LE library errors and panics of this process by
+// phase
+func LibraryErrors() map[string]LibraryErrorCount {
+	libraryErrors.Lock()
+	defer libraryErrors.Unlock()
+	counts := make(map[string]LibraryErrorCount, len(libraryErrors.counts))
+	for phase, c := range libraryErrors.counts {
+		counts[phase] = c
+	}
+	return counts
+}
+
+func countLibraryError(e *PSIError) {
+	libraryErrors.Lock()
+	c := libraryErrors.counts[e.Phase]
+	if e.Panic {
+		c.Panics++
+	} else {
+		c.Errors++
+	}
+	libraryErrors.counts[e.Phase] = c
+	libraryErrors.Unlock()
+}
+
+// guardLE runs op, an LE library call, converting an error it returns or a
+// panic it raises into a counted PSIError
+func guardLE(phase string, record int, op func() error) (err error) {
+	defer func() {
+		if p := recover(); p != nil {
+			log.Printf("LE library panic in %s (record %d): %v\n%s", phase, record, p, debug.Stack())
+			err = &PSIError{Phase: phase, Record: record, Panic: true, Err: fmt.Errorf("%w: %v", ErrLibraryPanic, p)}
+		}
+		var psiErr *PSIError
+		if errors.As(err, &psiErr) {
+			countLibraryError(psiErr)
+		}
+	}()
+
+	if err := op(); err != nil {
+		return &PSIError{Phase: phase, Record: record, Err: err}
+	}
+	return nil
+}
+
+// callLE runs op through guardLE on its own goroutine, as LE library calls
+// cannot be interrupted, and returns ctx.Err() as soon as ctx is done. An
+// abandoned call runs on in the background until it returns; op must not
+// publish its results otherwise than through variables the caller only
+// reads when callLE returns nil.
+func callLE(ctx context.Context, phase string, record int, op func() error) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	done := make(chan error, 1)
+	go func() {
+		done <- guardLE(phase, record, op)
+	}()
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
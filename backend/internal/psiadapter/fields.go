@@ -0,0 +1,40 @@
+package psiadapter
+
+import (
+	"strings"
+
+	"github.com/SanthoshCheemala/FLARE/backend/internal/models"
+)
+
+// NormalizeField canonicalizes a custom list field's value by its type hint
+// (see models.FieldType*). Values are stored this way on upload, so hashing
+// them again through NormalizeColumn leaves them unchanged; clients hashing
+// the same field must normalize their values with the same hint.
+func NormalizeField(fieldType, value string, order DateOrder) string {
+	switch fieldType {
+	case models.FieldTypeName:
+		return normalizeString(strings.Join(strings.Fields(value), " "))
+	case models.FieldTypeDate:
+		return NormalizeDate(value, order)
+	case models.FieldTypeIdentifier:
+		return normalizeIdentifier(value)
+	case models.FieldTypeNumber:
+		return strings.NewReplacer(",", "", " ", "", "_", "").Replace(strings.TrimSpace(value))
+	}
+	return normalizeString(value)
+}
+
+// SchemaAttributes reads the values of a list schema's fields from an
+// upload row, normalized by type. Empty values are left out.
+func SchemaAttributes(fields []models.ListField, value func(header string) string, order DateOrder) map[string]string {
+	if len(fields) == 0 {
+		return nil
+	}
+	attributes := make(map[string]string, len(fields))
+	for _, f := range fields {
+		if v := NormalizeField(f.Type, value(f.Header()), order); v != "" {
+			attributes[f.Name] = v
+		}
+	}
+	return attributes
+}
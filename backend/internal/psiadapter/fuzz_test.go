@@ -0,0 +1,65 @@
+package psiadapter
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func FuzzSerializeDynamic(f *testing.F) {
+	f.Add("John Smith", "1990-01-02", "US", "IMO 9074729", "")
+	f.Add(" ÉLODIE|Dupont ", "02/01/1990", "fr", "", "x")
+	f.Add("", "31/31/31", "", "imo", "\x00\xff")
+	f.Fuzz(func(t *testing.T, name, dob, country, imo, custom string) {
+		columns := []string{"name", "dob", "country", "imo", "custom"}
+		values := map[string]string{"name": name, "dob": dob, "country": country, "imo": imo, "custom": custom}
+
+		got := SerializeDynamic(values, columns)
+		if again := SerializeDynamic(values, columns); again != got {
+			t.Fatalf("serialization differs between calls: %q, %q", got, again)
+		}
+		if n := strings.Count(got, "|"); n < len(columns)-1 {
+			t.Fatalf("%q has %d separators for %d columns", got, n, len(columns))
+		}
+	})
+}
+
+func FuzzHashOne(f *testing.F) {
+	salt := []byte("0123456789abcdef")
+	f.Add(HashSHA256, 0, []byte(nil), "john smith|1990-01-02|us")
+	f.Add(HashSipHash, HashVersion, salt, "john smith|1990-01-02|us")
+	f.Add(HashBLAKE2b, HashVersion, salt, "")
+	f.Add(HashBLAKE2b, HashVersion, []byte("short"), "x")
+	f.Add("md5", 7, salt, "x")
+	f.Fuzz(func(t *testing.T, algorithm string, version int, salt []byte, data string) {
+		// Schemes arrive from the other party; only checked ones are used
+		scheme, err := HashScheme{Algorithm: algorithm, Version: version, Salt: salt}.Check()
+		if err != nil {
+			return
+		}
+		h := scheme.HashOne(data)
+		if again := scheme.HashOne(data); again != h {
+			t.Fatalf("%s hashed %q to %d, then %d", scheme, data, h, again)
+		}
+		if scheme.Algorithm == HashSHA256 && h != HashOne(data) {
+			t.Fatalf("sha256 scheme hashed %q to %d, legacy HashOne to %d", data, h, HashOne(data))
+		}
+	})
+}
+
+func FuzzDeserializeParams(f *testing.F) {
+	f.Add([]byte(`{}`))
+	f.Add([]byte(`null`))
+	f.Add([]byte(`{"X":"AAECAw=="}`))
+	f.Add([]byte(`{"X":null,"Y":[1,2,3]}`))
+	a := NewAdapter(1)
+	f.Fuzz(func(t *testing.T, data []byte) {
+		// Params reach the client in the server's init response
+		var params SerializedServerParams
+		if err := json.Unmarshal(data, &params); err != nil {
+			return
+		}
+		// Whatever the library makes of them, the call returns
+		a.DeserializeParams(&params)
+	})
+}
@@ -0,0 +1,181 @@
+package psiadapter
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"fmt"
+	"math/bits"
+	"strings"
+
+	"golang.org/x/crypto/blake2b"
+)
+
+// HashVersion is the serialization version mixed into the domain tag of the
+// keyed hashes. Bump it whenever serialized set elements change, so hashes
+// of the old and new forms can never collide.
+const HashVersion = 1
+
+// Hash algorithms for PSI set elements
+const (
+	// HashSHA256 is the legacy unkeyed SHA-256 truncation. Every session
+	// hashes an element alike, so hashes can be linked across sessions.
+	HashSHA256 = "sha256"
+	// HashSipHash is SipHash-2-4 keyed with the session salt
+	HashSipHash = "siphash"
+	// HashBLAKE2b is BLAKE2b-64 keyed with the session salt
+	HashBLAKE2b = "blake2b"
+)
+
+// HashAlgorithms lists the supported hash algorithms
+var HashAlgorithms = []string{HashSHA256, HashSipHash, HashBLAKE2b}
+
+// hashSaltSize is the salt length of the keyed algorithms; SipHash takes a
+// 128-bit key
+const hashSaltSize = 16
+
+// HashScheme selects how a session hashes its set elements. The server picks
+// it at session init and both parties must use it. The zero value is the
+// legacy SHA-256 scheme spoken by peers that predate negotiation.
+type HashScheme struct {
+	Algorithm string `json:"algorithm"`
+	Version   int    `json:"version,omitempty"`
+	Salt      []byte `json:"salt,omitempty"` // Random per session; keyed algorithms only
+}
+
+// NewHashScheme returns a scheme for algorithm with a fresh random salt
+func NewHashScheme(algorithm string) (HashScheme, error) {
+	if algorithm == "" || algorithm == HashSHA256 {
+		return HashScheme{Algorithm: HashSHA256}, nil
+	}
+	if !IsHashAlgorithm(algorithm) {
+		return HashScheme{}, fmt.Errorf("unknown hash algorithm %q (use %s)", algorithm, strings.Join(HashAlgorithms, ", "))
+	}
+	salt := make([]byte, hashSaltSize)
+	if _, err := rand.Read(salt); err != nil {
+		return HashScheme{}, fmt.Errorf("generate hash salt: %w", err)
+	}
+	return HashScheme{Algorithm: algorithm, Version: HashVersion, Salt: salt}, nil
+}
+
+// IsHashAlgorithm reports whether algorithm is supported
+func IsHashAlgorithm(algorithm string) bool {
+	for _, a := range HashAlgorithms {
+		if a == algorithm {
+			return true
+		}
+	}
+	return false
+}
+
+// Check validates a scheme received from the other party and returns it in
+// canonical form
+func (h HashScheme) Check() (HashScheme, error) {
+	switch h.Algorithm {
+	case "", HashSHA256:
+		return HashScheme{Algorithm: HashSHA256}, nil
+	case HashSipHash, HashBLAKE2b:
+	default:
+		return HashScheme{}, fmt.Errorf("unknown hash algorithm %q", h.Algorithm)
+	}
+	if h.Version != HashVersion {
+		return HashScheme{}, fmt.Errorf("hash version %d is not supported (this side uses version %d)", h.Version, HashVersion)
+	}
+	if len(h.Salt) != hashSaltSize {
+		return HashScheme{}, fmt.Errorf("%s salt must be %d bytes, got %d", h.Algorithm, hashSaltSize, len(h.Salt))
+	}
+	return h, nil
+}
+
+// String names the scheme for logs; it never includes the salt
+func (h HashScheme) String() string {
+	if h.Algorithm == "" || h.Algorithm == HashSHA256 {
+		return HashSHA256
+	}
+	return fmt.Sprintf("%s/v%d", h.Algorithm, h.Version)
+}
+
+// domain is the tag prefixed to every keyed hash input, so hashes of one
+// algorithm and serialization version never equal those of another
+func (h HashScheme) domain() []byte {
+	return []byte(fmt.Sprintf("flare-psi/%s/v%d\x00", h.Algorithm, h.Version))
+}
+
+// Hash hashes serialized set elements under the scheme
+func (h HashScheme) Hash(data []string) []uint64 {
+	switch h.Algorithm {
+	case HashSipHash, HashBLAKE2b:
+	default:
+		return HashDataPoints(data)
+	}
+
+	domain := h.domain()
+	hashes := make([]uint64, len(data))
+	buf := make([]byte, 0, 256)
+	for i, s := range data {
+		buf = append(append(buf[:0], domain...), s...)
+		if h.Algorithm == HashSipHash {
+			hashes[i] = sipHash24(h.Salt, buf)
+			continue
+		}
+		mac, err := blake2b.New(8, h.Salt)
+		if err != nil {
+			// Only an oversized key fails, and Check bounds the salt
+			panic(err)
+		}
+		mac.Write(buf)
+		hashes[i] = binary.BigEndian.Uint64(mac.Sum(nil))
+	}
+	return hashes
+}
+
+// HashOne hashes a single serialized element under the scheme
+func (h HashScheme) HashOne(data string) uint64 {
+	return h.Hash([]string{data})[0]
+}
+
+// sipHash24 is SipHash-2-4 of msg under a 16-byte key
+func sipHash24(key, msg []byte) uint64 {
+	k0 := binary.LittleEndian.Uint64(key[0:8])
+	k1 := binary.LittleEndian.Uint64(key[8:16])
+	v0 := k0 ^ 0x736f6d6570736575
+	v1 := k1 ^ 0x646f72616e646f6d
+	v2 := k0 ^ 0x6c7967656e657261
+	v3 := k1 ^ 0x7465646279746573
+
+	round := func() {
+		v0 += v1
+		v1 = bits.RotateLeft64(v1, 13) ^ v0
+		v0 = bits.RotateLeft64(v0, 32)
+		v2 += v3
+		v3 = bits.RotateLeft64(v3, 16) ^ v2
+		v0 += v3
+		v3 = bits.RotateLeft64(v3, 21) ^ v0
+		v2 += v1
+		v1 = bits.RotateLeft64(v1, 17) ^ v2
+		v2 = bits.RotateLeft64(v2, 32)
+	}
+
+	n := len(msg)
+	for ; len(msg) >= 8; msg = msg[8:] {
+		m := binary.LittleEndian.Uint64(msg)
+		v3 ^= m
+		round()
+		round()
+		v0 ^= m
+	}
+	var last [8]byte
+	copy(last[:], msg)
+	last[7] = byte(n)
+	m := binary.LittleEndian.Uint64(last[:])
+	v3 ^= m
+	round()
+	round()
+	v0 ^= m
+
+	v2 ^= 0xff
+	round()
+	round()
+	round()
+	round()
+	return v0 ^ v1 ^ v2 ^ v3
+}
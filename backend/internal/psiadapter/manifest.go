@@ -0,0 +1,120 @@
+package psiadapter
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"time"
+)
+
+// TreeManifestSuffix is appended to a tree path to name its manifest
+const TreeManifestSuffix = ".manifest.json"
+
+// ErrTreeInvalid is returned when a tree database does not match its manifest
+var ErrTreeInvalid = errors.New("tree database failed validation")
+
+// TreeManifest is written next to each tree database so a damaged or
+// mismatched file is caught before it reaches the LE library
+type TreeManifest struct {
+	ParamsFingerprint string    `json:"paramsFingerprint"` // SHA-256 of the serialized public params
+	RecordCount       int       `json:"recordCount"`
+	SHA256            string    `json:"sha256"` // Of the tree database file
+	Size              int64     `json:"size"`
+	CreatedAt         time.Time `json:"createdAt"`
+}
+
+// writeTreeManifest records the state of sc's tree database
+func writeTreeManifest(sc *ServerContext) error {
+	fingerprint, err := paramsFingerprint(sc)
+	if err != nil {
+		return err
+	}
+	sum, size, err := fileSHA256(sc.TreePath)
+	if err != nil {
+		return err
+	}
+
+	data, err := json.MarshalIndent(TreeManifest{
+		ParamsFingerprint: fingerprint,
+		RecordCount:       len(sc.Hashes),
+		SHA256:            sum,
+		Size:              size,
+		CreatedAt:         time.Now().UTC(),
+	}, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(sc.TreePath+TreeManifestSuffix, data, 0600)
+}
+
+// VerifyTree checks sc's tree database against its manifest. Trees without
+// a manifest are not checked.
+func VerifyTree(sc *ServerContext) error {
+	data, err := os.ReadFile(sc.TreePath + TreeManifestSuffix)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("read tree manifest: %w", err)
+	}
+	var m TreeManifest
+	if err := json.Unmarshal(data, &m); err != nil {
+		return fmt.Errorf("%w: unreadable manifest: %v", ErrTreeInvalid, err)
+	}
+
+	if m.RecordCount != len(sc.Hashes) {
+		return fmt.Errorf("%w: manifest has %d records, context has %d", ErrTreeInvalid, m.RecordCount, len(sc.Hashes))
+	}
+	fingerprint, err := paramsFingerprint(sc)
+	if err != nil {
+		return err
+	}
+	if fingerprint != m.ParamsFingerprint {
+		return fmt.Errorf("%w: parameter fingerprint mismatch", ErrTreeInvalid)
+	}
+	sum, size, err := fileSHA256(sc.TreePath)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrTreeInvalid, err)
+	}
+	if size != m.Size {
+		return fmt.Errorf("%w: size %d, expected %d", ErrTreeInvalid, size, m.Size)
+	}
+	if sum != m.SHA256 {
+		return fmt.Errorf("%w: checksum mismatch", ErrTreeInvalid)
+	}
+	return nil
+}
+
+// paramsFingerprint hashes the serialized public parameters
+func paramsFingerprint(sc *ServerContext) (string, error) {
+	params, err := psiSerialize(sc)
+	if err != nil {
+		return "", err
+	}
+	data, err := json.Marshal(params)
+	if err != nil {
+		return "", fmt.Errorf("serialize params: %w", err)
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// fileSHA256 returns the hex SHA-256 and size of the file at path
+func fileSHA256(path string) (string, int64, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", 0, err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	size, err := io.Copy(h, f)
+	if err != nil {
+		return "", 0, err
+	}
+	return hex.EncodeToString(h.Sum(nil)), size, nil
+}
@@ -0,0 +1,131 @@
+package psiadapter
+
+import (
+	"slices"
+	"strings"
+
+	"github.com/SanthoshCheemala/FLARE/backend/internal/models"
+	"github.com/SanthoshCheemala/FLARE/backend/internal/translit"
+)
+
+// entityProfiles lists the columns hashed for each non-individual entity
+// type. Individuals are hashed with the session's enabled columns.
+var entityProfiles = map[string][]string{
+	"organization": {"name", "registration", "country"},
+	"vessel":       {"imo"},
+	"aircraft":     {"registration"},
+}
+
+// entityKeys are the columns that must be present for a profile to identify
+// an entity; without them every such entity would hash alike
+var entityKeys = map[string]string{
+	"vessel":   "imo",
+	"aircraft": "registration",
+}
+
+// EntityColumns returns the columns hashed for entityType. Individuals and
+// unknown types use individualColumns.
+func EntityColumns(entityType string, individualColumns []string) []string {
+	if cols, ok := entityProfiles[entityType]; ok {
+		return cols
+	}
+	return individualColumns
+}
+
+// EntityProfiles returns the columns hashed for each non-individual entity
+// type
+func EntityProfiles() map[string][]string {
+	profiles := make(map[string][]string, len(entityProfiles))
+	for entityType, cols := range entityProfiles {
+		profiles[entityType] = slices.Clone(cols)
+	}
+	return profiles
+}
+
+// ProfileName names the serialization profile entityType is hashed with:
+// the type itself, or "individual" for individuals and unknown types
+func ProfileName(entityType string) string {
+	if _, ok := entityProfiles[entityType]; ok {
+		return entityType
+	}
+	return models.EntityIndividual
+}
+
+// SerializeEntity builds the hash input for one entity using its type's
+// profile. Non-individual inputs are prefixed with the type so a vessel IMO
+// can never collide with an aircraft registration. It returns "" when the
+// entity lacks the identifier its profile needs.
+func SerializeEntity(entityType string, values map[string]string, individualColumns []string) string {
+	cols, ok := entityProfiles[entityType]
+	if !ok {
+		return SerializeDynamic(values, individualColumns)
+	}
+	if key, ok := entityKeys[entityType]; ok && normalizeIdentifier(values[key]) == "" {
+		return ""
+	}
+	return entityType + "|" + SerializeDynamic(values, cols)
+}
+
+// SerializeEntityVariants returns the distinct hash inputs for an entity:
+// one for its primary name and one per alias
+func SerializeEntityVariants(entityType string, values map[string]string, aliases []string, individualColumns []string) []string {
+	seen := make(map[string]bool, 1+len(aliases))
+	variants := make([]string, 0, 1+len(aliases))
+	add := func(v map[string]string) {
+		s := SerializeEntity(entityType, v, individualColumns)
+		if s != "" && !seen[s] {
+			seen[s] = true
+			variants = append(variants, s)
+		}
+	}
+
+	add(values)
+	for _, alias := range aliases {
+		if strings.TrimSpace(alias) == "" {
+			continue
+		}
+		v := make(map[string]string, len(values))
+		for k, val := range values {
+			v[k] = val
+		}
+		v["name"] = alias
+		add(v)
+	}
+	return variants
+}
+
+// SanctionHashInputs returns the PSI set elements for one sanction under a
+// session's schema: the sanction's name and each alias, romanized by names
+// and serialized with its entity type's profile. Individuals use columns.
+// The server hashes these; the client recomputes them to confirm a match.
+func SanctionHashInputs(s *models.Sanction, columns []string, names translit.Profile) []string {
+	values := s.HashValues()
+	values["name"] = names.Apply(values["name"])
+	aliases := make([]string, len(s.Aliases))
+	for i, a := range s.Aliases {
+		aliases[i] = names.Apply(a)
+	}
+	return SerializeEntityVariants(s.EntityType, values, aliases, columns)
+}
+
+// RecordHashVersion versions RecordHash. Bump it whenever the serialization
+// or normalization behind RecordHash changes; stored records hashed under an
+// older version are then rehashed in the background (see package rehash).
+const RecordHashVersion = 1
+
+// RecordHash is the hash stored with a sanction or customer record.
+// Individuals keep the legacy name|dob|country|program hash.
+func RecordHash(entityType string, values map[string]string) int64 {
+	if _, ok := entityProfiles[entityType]; !ok {
+		return int64(HashOne(SerializeSanction(values["name"], values["dob"], values["country"], values["program"])))
+	}
+	return int64(HashOne(SerializeEntity(entityType, values, nil)))
+}
+
+// normalizeIdentifier canonicalizes registry numbers: lowercase without
+// spaces, hyphens or dots, and without a leading "IMO"
+func normalizeIdentifier(s string) string {
+	s = strings.ToLower(strings.TrimSpace(s))
+	s = strings.TrimPrefix(s, "imo")
+	return strings.NewReplacer(" ", "", "-", "", ".", "").Replace(s)
+}
@@ -0,0 +1,232 @@
+package psiadapter
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/SanthoshCheemala/LE-PSI/pkg/psi"
+	"github.com/mattn/go-sqlite3"
+
+	"github.com/SanthoshCheemala/FLARE/backend/internal/atrest"
+)
+
+// Tree databases are SQLite files. The LE library opens one when a tree is
+// built or its context is decoded, and keeps the handle in the context for
+// as long as the tree is in use.
+
+// ErrTreeUnavailable is returned when a tree database stays locked past the
+// busy timeout, or its handle keeps failing after being reopened
+var ErrTreeUnavailable = errors.New("tree database unavailable")
+
+// Defaults for SetTreeDBOptions
+const (
+	defaultTreeBusyTimeout = 5 * time.Second
+	defaultTreeReopens     = 2
+	maxTreeBackoff         = 500 * time.Millisecond
+)
+
+// SetTreeDBOptions sets how long an operation on a locked tree database is
+// retried, and how many times a tree whose handle went stale is reopened
+// during one operation
+func (a *Adapter) SetTreeDBOptions(busyTimeout time.Duration, reopens int) {
+	a.treeBusyTimeout = busyTimeout
+	a.treeReopens = reopens
+}
+
+// enableTreeWAL switches a tree database to write-ahead logging. The mode
+// is stored in the file, so every handle the library opens on it later
+// reads without being blocked by a writer.
+func enableTreeWAL(path string) error {
+	if _, err := os.Stat(path); err != nil {
+		return err
+	}
+	db, err := sql.Open("sqlite3", path)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	var mode string
+	if err := db.QueryRow("PRAGMA journal_mode=WAL").Scan(&mode); err != nil {
+		return err
+	}
+	if !strings.EqualFold(mode, "wal") {
+		return fmt.Errorf("journal mode is %s", mode)
+	}
+	return nil
+}
+
+// treeFailure classifies an error returned by the LE library
+type treeFailure int
+
+const (
+	treeOther treeFailure = iota
+	treeBusy              // The database is locked; the operation may be retried
+	treeStale             // The handle is unusable; the tree must be reopened
+)
+
+func classifyTreeError(err error) treeFailure {
+	var se sqlite3.Error
+	if errors.As(err, &se) {
+		switch se.Code {
+		case sqlite3.ErrBusy, sqlite3.ErrLocked:
+			return treeBusy
+		case sqlite3.ErrIoErr, sqlite3.ErrCorrupt, sqlite3.ErrCantOpen, sqlite3.ErrNotADB, sqlite3.ErrSchema:
+			return treeStale
+		}
+	}
+
+	// The library does not always wrap driver errors, so match their text
+	msg := strings.ToLower(err.Error())
+	switch {
+	case strings.Contains(msg, "database is locked"),
+		strings.Contains(msg, "database table is locked"):
+		return treeBusy
+	case strings.Contains(msg, "database is closed"),
+		strings.Contains(msg, "connection is already closed"),
+		strings.Contains(msg, "disk i/o error"),
+		strings.Contains(msg, "unable to open database"),
+		strings.Contains(msg, "database disk image is malformed"):
+		return treeStale
+	}
+	return treeOther
+}
+
+// withTree runs op on sc's library context. While the tree database is
+// locked op is retried with backoff, up to the busy timeout; when its
+// handle went stale the tree is reopened and op retried. Giving up on
+// either returns an error wrapping ErrTreeUnavailable.
+func (a *Adapter) withTree(ctx context.Context, sc *ServerContext, op func(*psi.ServerInitContext) error) error {
+	deadline := time.Now().Add(a.treeBusyTimeout)
+	backoff := 10 * time.Millisecond
+	reopens := 0
+	for {
+		psiCtx := sc.libraryContext()
+		err := op(psiCtx)
+		if err == nil {
+			return nil
+		}
+
+		switch classifyTreeError(err) {
+		case treeBusy:
+			if time.Now().Add(backoff).After(deadline) {
+				return fmt.Errorf("%w: %s still locked after %s: %v", ErrTreeUnavailable, sc.TreePath, a.treeBusyTimeout, err)
+			}
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(backoff):
+			}
+			backoff = min(backoff*2, maxTreeBackoff)
+		case treeStale:
+			if reopens >= a.treeReopens {
+				return fmt.Errorf("%w: %s failed after %d reopens: %v", ErrTreeUnavailable, sc.TreePath, reopens, err)
+			}
+			reopens++
+			log.Printf("Warning: tree %s failed, reopening (%d/%d): %v", sc.TreePath, reopens, a.treeReopens, err)
+			if rerr := sc.reopen(psiCtx); rerr != nil {
+				return fmt.Errorf("%w: reopen %s: %v (after: %v)", ErrTreeUnavailable, sc.TreePath, rerr, err)
+			}
+		default:
+			return err
+		}
+	}
+}
+
+// libraryContext returns the current LE library context of sc
+func (sc *ServerContext) libraryContext() *psi.ServerInitContext {
+	sc.mu.RLock()
+	defer sc.mu.RUnlock()
+	return sc.Ctx
+}
+
+// reopen replaces sc's library context with a copy decoded from its
+// encoding, which opens the tree database again as loading a spilled batch
+// does. The public params are unchanged, so sessions using sc carry on.
+// stale is the context that failed; if another caller has already replaced
+// it, reopen does nothing.
+func (sc *ServerContext) reopen(stale *psi.ServerInitContext) error {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+	if sc.Ctx != stale {
+		return nil
+	}
+	if err := VerifyTree(sc); err != nil {
+		return err
+	}
+	// A fresh working copy also replaces one removed when the batch was
+	// evicted
+	if sc.sealed() {
+		if err := restoreTree(sc.files, sc.TreePath, sc.LibraryPath); err != nil {
+			return err
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(stale); err != nil {
+		return fmt.Errorf("encode context: %w", err)
+	}
+	var fresh psi.ServerInitContext
+	if err := gob.NewDecoder(&buf).Decode(&fresh); err != nil {
+		return fmt.Errorf("decode context: %w", err)
+	}
+	sc.Ctx = &fresh
+	return nil
+}
+
+// treeWorkSuffix names the working copy of a sealed tree
+const treeWorkSuffix = ".work"
+
+// Trees are sealed when the adapter has a file cipher: the database at
+// TreePath is encrypted, and the library opens a plaintext working copy next
+// to it. Working copies exist only while a tree is loaded; evicted batches
+// leave just the sealed tree, and their working copy is restored from it
+// when they are loaded again.
+
+// sealed reports whether sc's tree is stored encrypted
+func (sc *ServerContext) sealed() bool {
+	return sc.files.Enabled() && sc.LibraryPath != sc.TreePath
+}
+
+// sealTree encrypts sc's working copy to TreePath
+func sealTree(sc *ServerContext) error {
+	f, err := os.Open(sc.LibraryPath)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return sc.files.WriteFile(sc.TreePath, f, 0600)
+}
+
+// restoreTree writes the working copy of the sealed tree at treePath. The
+// copy is renamed into place, so handles still open on an earlier copy are
+// not disturbed.
+func restoreTree(files *atrest.Cipher, treePath, libraryPath string) error {
+	data, err := files.ReadFile(treePath)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrTreeInvalid, err)
+	}
+	tmp := libraryPath + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, libraryPath)
+}
+
+// removeWorkingCopy removes the working copy of a sealed tree with its
+// write-ahead log
+func removeWorkingCopy(libraryPath string) {
+	for _, suffix := range []string{"", "-wal", "-shm"} {
+		if err := os.Remove(libraryPath + suffix); err != nil && !errors.Is(err, os.ErrNotExist) {
+			log.Printf("Warning: failed to remove tree working copy %s: %v", libraryPath+suffix, err)
+		}
+	}
+}
@@ -0,0 +1,83 @@
+package psiadapter
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"testing"
+
+	"github.com/SanthoshCheemala/FLARE/backend/internal/atrest"
+)
+
+func TestSealedTrees(t *testing.T) {
+	useClearLE(t)
+	files, err := atrest.New(base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{7}, 32)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	a := NewAdapter(1)
+	a.batchSize = 50
+	a.SetResidentBatches(1)
+	a.SetFileCipher(files)
+
+	scheme, err := NewHashScheme(HashSipHash)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sanctions := make([]string, 150)
+	for i := range sanctions {
+		sanctions[i] = fmt.Sprintf("sanction %03d|1970-01-01|us", i)
+	}
+	bsc, err := a.InitServerBatched(context.Background(), sanctions, filepath.Join(t.TempDir(), "tree"), scheme, nil)
+	if err != nil {
+		t.Fatalf("InitServerBatched: %v", err)
+	}
+	t.Cleanup(func() { a.CleanupBatchContext(bsc) })
+
+	// The test library writes a tree's hashes in decimal
+	hashes := scheme.Hash(sanctions)
+	for i, path := range bsc.TreePaths() {
+		raw, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		plain, err := files.ReadFile(path)
+		if err != nil {
+			t.Fatalf("batch %d: decrypt tree: %v", i, err)
+		}
+		if bytes.Equal(raw, plain) {
+			t.Errorf("batch %d: tree stored in plaintext", i)
+		}
+		if h := strconv.FormatUint(hashes[i*50], 10); !bytes.Contains(plain, []byte(h)) || bytes.Contains(raw, []byte(h)) {
+			t.Errorf("batch %d: hash %s readable in the stored tree", i, h)
+		}
+
+		// Only the resident batch has a working copy
+		_, err = os.Stat(path + treeWorkSuffix)
+		if resident := i == bsc.Len()-1; resident != (err == nil) {
+			t.Errorf("batch %d: working copy present = %v, want %v", i, err == nil, resident)
+		}
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			t.Fatal(err)
+		}
+	}
+
+	// Evicted batches get their working copy back when loaded
+	customers := []string{sanctions[7], "customer a|1980-02-02|gb", sanctions[72]}
+	want := scheme.Hash([]string{sanctions[7], sanctions[72]})
+	slices.Sort(want)
+	got, err := a.DetectIntersectionBatched(context.Background(), bsc, customers)
+	if err != nil {
+		t.Fatalf("DetectIntersectionBatched: %v", err)
+	}
+	slices.Sort(got)
+	if !slices.Equal(got, want) {
+		t.Errorf("matches %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,246 @@
+package psiserver
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/SanthoshCheemala/FLARE/backend/internal/apierror"
+	"github.com/SanthoshCheemala/FLARE/backend/internal/auth"
+	"github.com/SanthoshCheemala/FLARE/backend/internal/models"
+	"github.com/go-chi/chi/v5"
+)
+
+// adminRoutes mounts the operator API used by flare-admin
+func (s *Server) adminRoutes(r chi.Router) {
+	r.Use(s.adminAuth)
+
+	r.Get("/sessions", s.handleAdminListSessions)
+	r.Delete("/sessions/{sessionID}", s.handleAdminExpireSession)
+	r.Get("/sessions/{sessionID}/events", s.handleAdminListEvents)
+	r.Get("/events", s.handleAdminListEvents)
+	r.Get("/usage", s.handleAdminUsage)
+	r.Post("/rebuild", s.handleAdminRebuild)
+	r.Get("/rebuild/status", s.handleAdminRebuildStatus)
+	r.Get("/trees/archive", s.handleAdminTreeArchive)
+	r.Post("/rehash", s.handleAdminRehash)
+	r.Get("/rehash/status", s.handleAdminRehashStatus)
+
+	r.Get("/api-keys", s.handleAdminListAPIKeys)
+	r.Post("/api-keys", s.handleAdminCreateAPIKey)
+	r.Delete("/api-keys/{id}", s.handleAdminRevokeAPIKey)
+	r.Get("/api-keys/{id}/quota", s.handleAdminGetQuota)
+	r.Put("/api-keys/{id}/quota", s.handleAdminSetQuota)
+
+	r.Get("/packs", s.handleAdminListPacks)
+	r.Post("/packs", s.handleAdminCreatePack)
+	r.Put("/packs/{id}", s.handleAdminUpdatePack)
+	r.Delete("/packs/{id}", s.handleAdminDeletePack)
+}
+
+// adminAuth requires the configured admin token. The admin API is disabled
+// when no token is configured.
+func (s *Server) adminAuth(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if s.cfg.Server.AdminToken == "" {
+			apierror.Write(w, r, http.StatusForbidden, apierror.CodeForbidden, "Admin API is disabled; set ADMIN_TOKEN to enable it")
+			return
+		}
+		token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !auth.TokensEqual(token, s.cfg.Server.AdminToken) {
+			apierror.Write(w, r, http.StatusUnauthorized, apierror.CodeUnauthorized, "Invalid admin token")
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+type ctxKey int
+
+const apiKeyCtxKey ctxKey = iota
+
+// requestAPIKey returns the API key that authenticated the request, or nil
+// if there was none
+func requestAPIKey(ctx context.Context) *models.APIKey {
+	k, _ := ctx.Value(apiKeyCtxKey).(*models.APIKey)
+	return k
+}
+
+// apiKeyID returns the ID of the API key that authenticated the request, or
+// 0 if there was none
+func apiKeyID(ctx context.Context) int64 {
+	if k := requestAPIKey(ctx); k != nil {
+		return k.ID
+	}
+	return 0
+}
+
+// requireAPIKey checks the X-API-Key header against active keys. A missing
+// key is refused when PSI_REQUIRE_API_KEY is set and otherwise gets the
+// anonymous quota; a presented key that is unknown or revoked is always
+// refused. Valid keys are attributed for usage accounting.
+func (s *Server) requireAPIKey(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		required := s.cfg.PSI.RequireAPIKey
+
+		key := r.Header.Get("X-API-Key")
+		if key == "" {
+			if required {
+				apierror.Write(w, r, http.StatusUnauthorized, apierror.CodeUnauthorized, "X-API-Key header required")
+				return
+			}
+			next.ServeHTTP(w, r)
+			return
+		}
+		apiKey, err := s.repo.GetActiveAPIKeyByHash(r.Context(), auth.HashAPIKey(key))
+		if err != nil {
+			apierror.Write(w, r, http.StatusInternalServerError, apierror.CodeDatabaseError, "Database error")
+			return
+		}
+		if apiKey == nil {
+			apierror.Write(w, r, http.StatusUnauthorized, apierror.CodeUnauthorized, "Invalid or revoked API key")
+			return
+		}
+		if err := s.repo.TouchAPIKey(r.Context(), apiKey.ID); err != nil {
+			log.Printf("Warning: failed to record use of API key %d: %v", apiKey.ID, err)
+		}
+
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), apiKeyCtxKey, apiKey)))
+	})
+}
+
+func (s *Server) handleAdminListSessions(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"sessions": s.sessions.List(),
+	})
+}
+
+// handleAdminExpireSession ends a session without needing its access token
+func (s *Server) handleAdminExpireSession(w http.ResponseWriter, r *http.Request) {
+	sessionID := chi.URLParam(r, "sessionID")
+	if !s.sessions.Delete(sessionID) {
+		apierror.Write(w, r, http.StatusNotFound, apierror.CodeSessionNotFound, "Session not found or expired")
+		return
+	}
+
+	log.Printf("Session %s expired by admin", sessionID)
+	s.recordEvent(r, models.SessionEvent{SessionID: sessionID, Event: models.SessionEventExpire})
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]bool{"success": true})
+}
+
+// handleAdminRebuild rebuilds the global PSI state in the background
+func (s *Server) handleAdminRebuild(w http.ResponseWriter, r *http.Request) {
+	go func() {
+		if err := s.initGlobalState(); err != nil {
+			log.Printf("Admin-triggered rebuild failed: %v", err)
+		}
+	}()
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(map[string]bool{"started": true})
+}
+
+// handleAdminRebuildStatus reports progress of the current or last rebuild
+func (s *Server) handleAdminRebuildStatus(w http.ResponseWriter, r *http.Request) {
+	status := s.rebuild.status()
+	status.ActiveSlot = s.globalState().Slot
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(status)
+}
+
+// handleAdminRehash rebuilds stale stored sanction hashes in the background
+func (s *Server) handleAdminRehash(w http.ResponseWriter, r *http.Request) {
+	if !s.rehash.Start() {
+		apierror.Write(w, r, http.StatusConflict, apierror.CodeConflict, "A rehash is already running")
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(map[string]bool{"started": true})
+}
+
+// handleAdminRehashStatus reports progress of the current or last rehash
+func (s *Server) handleAdminRehashStatus(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(s.rehash.Status())
+}
+
+func (s *Server) handleAdminListAPIKeys(w http.ResponseWriter, r *http.Request) {
+	keys, err := s.repo.ListAPIKeys(r.Context())
+	if err != nil {
+		apierror.Write(w, r, http.StatusInternalServerError, apierror.CodeDatabaseError, "Database error")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"keys": keys,
+	})
+}
+
+// handleAdminCreateAPIKey issues a key. The plaintext key is only ever
+// returned in this response.
+func (s *Server) handleAdminCreateAPIKey(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Name string `json:"name"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || strings.TrimSpace(req.Name) == "" {
+		apierror.Write(w, r, http.StatusBadRequest, apierror.CodeBadRequest, "Request must include a name")
+		return
+	}
+
+	key, err := auth.GenerateAPIKey()
+	if err != nil {
+		apierror.Write(w, r, http.StatusInternalServerError, apierror.CodeInternal, "Failed to generate API key")
+		return
+	}
+
+	apiKey := &models.APIKey{
+		Name:    strings.TrimSpace(req.Name),
+		Prefix:  auth.APIKeyPrefix(key),
+		KeyHash: auth.HashAPIKey(key),
+	}
+	if err := s.repo.CreateAPIKey(r.Context(), apiKey); err != nil {
+		apierror.Write(w, r, http.StatusInternalServerError, apierror.CodeDatabaseError, "Failed to store API key")
+		return
+	}
+
+	log.Printf("API key %d (%s) created", apiKey.ID, apiKey.Name)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"id":     apiKey.ID,
+		"name":   apiKey.Name,
+		"prefix": apiKey.Prefix,
+		"key":    key,
+	})
+}
+
+func (s *Server) handleAdminRevokeAPIKey(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		apierror.Write(w, r, http.StatusBadRequest, apierror.CodeBadRequest, "Invalid API key ID")
+		return
+	}
+
+	revoked, err := s.repo.RevokeAPIKey(r.Context(), id)
+	if err != nil {
+		apierror.Write(w, r, http.StatusInternalServerError, apierror.CodeDatabaseError, "Database error")
+		return
+	}
+	if !revoked {
+		apierror.Write(w, r, http.StatusNotFound, apierror.CodeNotFound, "API key not found or already revoked")
+		return
+	}
+
+	log.Printf("API key %d revoked", id)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]bool{"success": true})
+}
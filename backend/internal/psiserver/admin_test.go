@@ -0,0 +1,92 @@
+package psiserver
+
+import (
+	"bytes"
+	"context"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/SanthoshCheemala/FLARE/backend/internal/models"
+)
+
+func TestListMutationsRequireAdmin(t *testing.T) {
+	s := newTestServer(t, map[string]string{"ADMIN_TOKEN": "admin-secret"})
+
+	for _, tc := range []struct {
+		method, path, body string
+	}{
+		{http.MethodPatch, "/lists/sanctions/1", `{"active": false}`},
+		{http.MethodPut, "/lists/sanctions/1/schema", `{"fields": [{"name": "passport", "type": "identifier"}]}`},
+		{http.MethodGet, "/dashboard/stats", ""},
+		{http.MethodPost, "/lists/sanctions/upload", ""},
+		{http.MethodDelete, "/lists/sanctions/1", ""},
+	} {
+		for _, token := range []string{"", "wrong"} {
+			req := httptest.NewRequest(tc.method, tc.path, strings.NewReader(tc.body))
+			if token != "" {
+				req.Header.Set("Authorization", "Bearer "+token)
+			}
+			rec := httptest.NewRecorder()
+			s.Handler().ServeHTTP(rec, req)
+			if rec.Code != http.StatusUnauthorized {
+				t.Errorf("%s %s with token %q: status = %d, want %d", tc.method, tc.path, token, rec.Code, http.StatusUnauthorized)
+			}
+		}
+
+		// The list does not exist, but the request gets past auth
+		req := httptest.NewRequest(tc.method, tc.path, strings.NewReader(tc.body))
+		req.Header.Set("Authorization", "Bearer admin-secret")
+		rec := httptest.NewRecorder()
+		s.Handler().ServeHTTP(rec, req)
+		if rec.Code == http.StatusUnauthorized || rec.Code == http.StatusForbidden {
+			t.Errorf("%s %s with the admin token: status = %d", tc.method, tc.path, rec.Code)
+		}
+	}
+}
+
+// An upload's schema field replaces the list's schema like PUT schema does,
+// so it needs the admin token too
+func TestUploadSchemaRequiresAdmin(t *testing.T) {
+	s := newTestServer(t, map[string]string{"ADMIN_TOKEN": "admin-secret"})
+	ctx := context.Background()
+	listID, err := s.repo.CreateSanctionList(ctx, "OFAC", "ofac", models.ListCategorySanctions, "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	mw.WriteField("list_id", strconv.FormatInt(listID, 10))
+	mw.WriteField("schema", `[{"name": "passport", "type": "identifier"}]`)
+	fw, _ := mw.CreateFormFile("file", "sanctions.csv")
+	fw.Write([]byte("name,passport\nIvan Petrov,AB123\n"))
+	mw.Close()
+
+	req := httptest.NewRequest(http.MethodPost, "/lists/sanctions/upload", &body)
+	req.Header.Set("Content-Type", mw.FormDataContentType())
+	rec := httptest.NewRecorder()
+	s.Handler().ServeHTTP(rec, req)
+	if rec.Code != http.StatusUnauthorized {
+		t.Errorf("upload with a schema and no token: status = %d, want %d", rec.Code, http.StatusUnauthorized)
+	}
+	if fields, err := s.repo.GetSanctionListSchema(ctx, listID); err != nil || len(fields) != 0 {
+		t.Errorf("schema after refused upload = %v, %v; want it unchanged", fields, err)
+	}
+}
+
+func TestCORSAllowsListMutations(t *testing.T) {
+	s := newTestServer(t, nil)
+
+	rec := httptest.NewRecorder()
+	s.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodOptions, "/lists/sanctions/1", nil))
+	allowed := rec.Header().Get("Access-Control-Allow-Methods")
+	for _, method := range []string{http.MethodPatch, http.MethodPut} {
+		if !strings.Contains(allowed, method) {
+			t.Errorf("preflight allows %q, missing %s", allowed, method)
+		}
+	}
+}
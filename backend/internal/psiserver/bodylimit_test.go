@@ -0,0 +1,40 @@
+package psiserver
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/SanthoshCheemala/FLARE/backend/internal/apierror"
+	"github.com/SanthoshCheemala/FLARE/backend/internal/psiadapter"
+)
+
+func TestIntersectBodyLimitDefault(t *testing.T) {
+	s := newTestServer(t, nil)
+
+	limit := s.bodyLimits().Limit("/session/intersect")
+	if want := psiadapter.IntersectBodyBytes(s.cfg.PSI.MaxCiphertextsPerRequest); limit <= 0 || limit != want {
+		t.Fatalf("intersect body limit = %d, want %d", limit, want)
+	}
+
+	// The body is never read, so it need not be as long as it claims
+	req := httptest.NewRequest(http.MethodPost, "/session/intersect", strings.NewReader("{}"))
+	req.ContentLength = limit + 1
+	rec := httptest.NewRecorder()
+	s.Handler().ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusRequestEntityTooLarge {
+		t.Fatalf("status = %d, want %d", rec.Code, http.StatusRequestEntityTooLarge)
+	}
+	var body struct {
+		Code apierror.Code `json:"code"`
+	}
+	if err := json.NewDecoder(rec.Body).Decode(&body); err != nil {
+		t.Fatalf("decode error response: %v", err)
+	}
+	if body.Code != apierror.CodeBodyTooLarge {
+		t.Errorf("code = %q, want %q", body.Code, apierror.CodeBodyTooLarge)
+	}
+}
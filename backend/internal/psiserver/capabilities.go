@@ -0,0 +1,107 @@
+package psiserver
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+
+	"github.com/SanthoshCheemala/FLARE/backend/internal/apierror"
+	"github.com/SanthoshCheemala/FLARE/backend/internal/protocol"
+	"github.com/SanthoshCheemala/FLARE/backend/internal/psiadapter"
+	"github.com/SanthoshCheemala/FLARE/backend/internal/translit"
+)
+
+// defaultColumns are enabled for sessions that enable none, and are the
+// columns of the shared global set
+var defaultColumns = []string{"name", "dob", "country"}
+
+// handleCapabilities describes the columns, normalization, limits and
+// batching sessions get, with the limits of the requesting API key
+func (s *Server) handleCapabilities(w http.ResponseWriter, r *http.Request) {
+	lists, err := s.repo.GetSanctionLists(r.Context())
+	if err != nil {
+		apierror.Write(w, r, http.StatusInternalServerError, apierror.CodeDatabaseError, "Database error")
+		return
+	}
+
+	columns := make([]protocol.ColumnCapability, 0, len(hashableColumns))
+	for _, col := range hashableColumns {
+		columns = append(columns, protocol.ColumnCapability{Name: col, Normalization: psiadapter.ColumnNormalization(col)})
+	}
+	custom := make(map[string]int) // Field name -> index in columns
+	for _, l := range lists {
+		if !l.Active {
+			continue
+		}
+		for _, f := range l.Schema {
+			i, ok := custom[f.Name]
+			if !ok {
+				i = len(columns)
+				custom[f.Name] = i
+				columns = append(columns, protocol.ColumnCapability{
+					Name:          f.Name,
+					Normalization: psiadapter.ColumnNormalization(f.Name),
+					Type:          f.Type,
+				})
+			}
+			columns[i].ListIDs = append(columns[i].ListIDs, l.ID)
+		}
+	}
+
+	global := s.globalState()
+	batches := 0
+	switch {
+	case global.BatchContext != nil:
+		batches = global.BatchContext.Len()
+	case global.Params != nil:
+		batches = 1
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(protocol.Capabilities{
+		ProtocolVersions: []int{protocol.Version},
+		Columns:          columns,
+		DefaultColumns:   defaultColumns,
+		EntityProfiles:   psiadapter.EntityProfiles(),
+		Normalization: protocol.NormalizationCapabilities{
+			TransliterationVersion: translit.Version,
+			Scripts:                translit.Scripts,
+			GlobalTransliteration:  global.Transliteration.String(),
+			HashAlgorithm:          s.cfg.PSI.HashAlgorithm,
+			HashAlgorithms:         psiadapter.HashAlgorithms,
+			HashVersion:            psiadapter.HashVersion,
+			DateOrder:              s.cfg.PSI.DateOrder,
+			Documents:              true,
+		},
+		Limits: s.capabilityLimits(r),
+		Batching: protocol.BatchingCapabilities{
+			Batches:       batches,
+			ChunkSize:     intersectChunk,
+			SplitRequests: true,
+		},
+	})
+}
+
+// capabilityLimits returns the limits checkCiphertextQuota and
+// checkResolveMinimum apply to the request's API key
+func (s *Server) capabilityLimits(r *http.Request) protocol.LimitCapabilities {
+	limits := protocol.LimitCapabilities{
+		MaxCiphertextsPerRequest: s.cfg.PSI.MaxCiphertextsPerRequest,
+		MaxIntersectBodyBytes:    s.bodyLimits().Limit("/session/intersect"),
+		IdleTimeoutSeconds:       s.idleTimeoutSeconds(),
+		SignedRequests:           true, // Every session is issued a signing key
+	}
+	key := requestAPIKey(r.Context())
+	if key != nil {
+		if n := key.Quota.MaxCiphertextsPerRequest; n > 0 && (limits.MaxCiphertextsPerRequest == 0 || n < limits.MaxCiphertextsPerRequest) {
+			limits.MaxCiphertextsPerRequest = n
+		}
+	}
+	minimum, err := s.resolveMinimum(r.Context(), key)
+	if err != nil {
+		log.Printf("Warning: failed to look up the resolve minimum: %v", err)
+		minimum = s.cfg.PSI.MinResolveCiphertexts
+	}
+	limits.MinResolveCiphertexts = minimum
+	return limits
+}
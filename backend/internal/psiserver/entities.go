@@ -0,0 +1,11 @@
+package psiserver
+
+// firstValue returns the first non-empty column among names
+func firstValue(record []string, get func([]string, string) string, names ...string) string {
+	for _, name := range names {
+		if v := get(record, name); v != "" {
+			return v
+		}
+	}
+	return ""
+}
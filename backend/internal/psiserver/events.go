@@ -0,0 +1,82 @@
+package psiserver
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/SanthoshCheemala/FLARE/backend/internal/apierror"
+	"github.com/SanthoshCheemala/FLARE/backend/internal/models"
+	"github.com/SanthoshCheemala/FLARE/backend/internal/repository"
+	"github.com/go-chi/chi/v5"
+)
+
+// recordEvent stores a session event for r. Failures are logged and never
+// fail the request.
+func (s *Server) recordEvent(r *http.Request, e models.SessionEvent) {
+	e.Client = clientKey(r)
+	e.APIKeyID = apiKeyID(r.Context())
+	// Keep the record even if the client hung up
+	ctx := context.WithoutCancel(r.Context())
+	if err := s.repo.CreateSessionEvent(ctx, &e); err != nil {
+		log.Printf("Warning: failed to record %s event for session %q: %v", e.Event, e.SessionID, err)
+	}
+}
+
+// recordError stores an error event for sessionID
+func (s *Server) recordError(r *http.Request, sessionID, detail string) {
+	s.recordEvent(r, models.SessionEvent{SessionID: sessionID, Event: models.SessionEventError, Detail: detail})
+}
+
+// handleAdminListEvents returns a page of session events, newest first.
+// Supported query params: session_id, event, since (RFC 3339 or
+// YYYY-MM-DD), limit and offset.
+func (s *Server) handleAdminListEvents(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	filter := repository.SessionEventFilter{
+		SessionID: query.Get("session_id"),
+		Event:     query.Get("event"),
+		Limit:     100,
+	}
+	if sessionID := chi.URLParam(r, "sessionID"); sessionID != "" {
+		filter.SessionID = sessionID
+	}
+
+	if l := query.Get("limit"); l != "" {
+		limit, err := strconv.Atoi(l)
+		if err != nil || limit <= 0 {
+			apierror.Write(w, r, http.StatusBadRequest, apierror.CodeBadRequest, "Invalid limit")
+			return
+		}
+		filter.Limit = min(limit, 1000)
+	}
+	if o := query.Get("offset"); o != "" {
+		offset, err := strconv.Atoi(o)
+		if err != nil || offset < 0 {
+			apierror.Write(w, r, http.StatusBadRequest, apierror.CodeBadRequest, "Invalid offset")
+			return
+		}
+		filter.Offset = offset
+	}
+	var err error
+	if filter.Since, err = parseDateParam(query.Get("since")); err != nil {
+		apierror.Write(w, r, http.StatusBadRequest, apierror.CodeBadRequest, "Invalid since")
+		return
+	}
+
+	events, total, err := s.repo.ListSessionEvents(r.Context(), filter)
+	if err != nil {
+		apierror.Write(w, r, http.StatusInternalServerError, apierror.CodeDatabaseError, "Database error")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"events": events,
+		"total":  total,
+		"limit":  filter.Limit,
+		"offset": filter.Offset,
+	})
+}
@@ -0,0 +1,37 @@
+package psiserver
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/SanthoshCheemala/FLARE/backend/internal/config"
+	"github.com/SanthoshCheemala/FLARE/backend/internal/repository"
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// newTestServer returns a server over a fresh in-memory database, configured
+// with the defaults and overrides (setting name to value). It runs in a
+// temporary working directory, where the server keeps its trees.
+func newTestServer(t *testing.T, overrides map[string]string) *Server {
+	t.Helper()
+	t.Chdir(t.TempDir())
+
+	name := strings.NewReplacer("/", "_", " ", "_").Replace(t.Name())
+	db, err := sql.Open("sqlite3", fmt.Sprintf("file:%s?mode=memory&cache=shared", name))
+	if err != nil {
+		t.Fatalf("open database: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	repo := repository.New(db)
+	if err := repo.InitSchema(); err != nil {
+		t.Fatalf("initialize schema: %v", err)
+	}
+	cfg, err := config.LoadFrom(config.Options{Overrides: overrides})
+	if err != nil {
+		t.Fatalf("load config: %v", err)
+	}
+	return NewServer(repo, cfg)
+}
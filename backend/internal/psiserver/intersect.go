@@ -0,0 +1,180 @@
+package psiserver
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/SanthoshCheemala/FLARE/backend/internal/apierror"
+	"github.com/SanthoshCheemala/FLARE/backend/internal/models"
+	"github.com/SanthoshCheemala/FLARE/backend/internal/protocol"
+	"github.com/SanthoshCheemala/FLARE/backend/internal/psiadapter"
+)
+
+// intersectChunk is how many ciphertexts of a request are decoded before
+// they are intersected, which bounds the memory a request holds
+const intersectChunk = 256
+
+// errResponded stops handling an intersect request whose error response
+// was already written
+var errResponded = errors.New("response written")
+
+// handleIntersect intersects a request's ciphertexts a chunk at a time as
+// they are decoded, so neither the body nor the ciphertexts are held in
+// memory whole. The signature covers the whole body, so a signed body is
+// spooled to disk first and the signature, timestamp and nonce are all
+// checked before the first chunk is intersected.
+func (s *Server) handleIntersect(w http.ResponseWriter, r *http.Request) {
+	var body io.Reader = r.Body
+	spool, bodySum, ok := s.spoolSignedBody(w, r)
+	if !ok {
+		return
+	}
+	if spool != nil {
+		defer removeSpool(spool)
+		body = spool
+	}
+
+	var (
+		req        protocol.IntersectRequest
+		sessionCtx *SessionContext
+		target     *psiadapter.ServerContext
+		matches    []uint64
+		count      int
+		elapsed    time.Duration
+	)
+	chunk := make([]psiadapter.ClientCiphertext, 0, intersectChunk)
+	intersect := func() error {
+		if len(chunk) == 0 {
+			return nil
+		}
+		count += len(chunk)
+		if !s.checkCiphertextQuota(w, r, req.SessionID, count) {
+			return errResponded
+		}
+		start := time.Now()
+		found, err := s.adapter.DetectIntersection(r.Context(), target, chunk)
+		elapsed += time.Since(start)
+		if err != nil {
+			log.Printf("Intersection failed (batch %d): %v", req.Batch, err)
+			s.recordError(r, req.SessionID, fmt.Sprintf("intersect: batch %d failed: %v", req.Batch, err))
+			if errors.Is(err, psiadapter.ErrTreeUnavailable) {
+				apierror.Write(w, r, http.StatusServiceUnavailable, apierror.CodePSIFailed, "Tree database unavailable; retry the request")
+				return errResponded
+			}
+			apierror.Write(w, r, http.StatusInternalServerError, apierror.CodePSIFailed, "Intersection failed")
+			return errResponded
+		}
+		matches = append(matches, found...)
+		chunk = chunk[:0]
+		return nil
+	}
+
+	begin := func() error {
+		var err error
+		sessionCtx, err = s.authorizeSession(r, req.SessionID)
+		if err != nil {
+			s.recordError(r, req.SessionID, "intersect: "+err.Error())
+			writeSessionAuthError(w, r, err)
+			return errResponded
+		}
+		err = s.checkSignatureHeaders(r, sessionCtx)
+		if err == nil {
+			err = s.verifySignedRequest(r, sessionCtx, bodySum)
+		}
+		if err != nil {
+			s.recordError(r, req.SessionID, "intersect: "+err.Error())
+			apierror.Write(w, r, http.StatusUnauthorized, apierror.CodeUnauthorized, "Rejected request: "+err.Error())
+			return errResponded
+		}
+		target, err = s.intersectTarget(w, r, sessionCtx, req.SessionID, req.Batch)
+		return err
+	}
+	err := protocol.DecodeIntersectRequest(json.NewDecoder(body), &req, begin, func(ct psiadapter.ClientCiphertext) error {
+		chunk = append(chunk, ct)
+		if len(chunk) < intersectChunk {
+			return nil
+		}
+		return intersect()
+	})
+	if err == nil {
+		err = intersect()
+	}
+	if errors.Is(err, errResponded) {
+		return
+	}
+	if apierror.TooLarge(w, r, err) {
+		return
+	}
+	if err != nil {
+		apierror.Write(w, r, http.StatusBadRequest, apierror.CodeBadRequest, "Invalid request body: "+err.Error())
+		return
+	}
+
+	if sessionCtx.BatchContext != nil {
+		log.Printf("Batch %d/%d: found %d matches", req.Batch+1, sessionCtx.BatchContext.Len(), len(matches))
+	}
+
+	// Remember the match set so resolve can only reveal records that were
+	// actually found by intersection for this session
+	if !s.sessions.RecordMatches(req.SessionID, matches, count) {
+		apierror.Write(w, r, http.StatusGone, apierror.CodeSessionClosed, "Session was closed during intersection")
+		return
+	}
+
+	resp := protocol.IntersectResponse{
+		Matches:    matches,
+		DurationMs: elapsed.Milliseconds(),
+	}
+	s.recordEvent(r, models.SessionEvent{
+		SessionID:       req.SessionID,
+		Event:           models.SessionEventIntersect,
+		Batch:           req.Batch,
+		CiphertextCount: count,
+		MatchCount:      len(matches),
+		DurationMs:      resp.DurationMs,
+	})
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+// intersectTarget returns the context of the batch a request targets,
+// writing the error response if there is none. Global sessions created in
+// batch mode carry their batch context. Ciphertexts only decrypt correctly
+// against the batch whose params encrypted them, so each request targets
+// exactly one batch.
+func (s *Server) intersectTarget(w http.ResponseWriter, r *http.Request, sessionCtx *SessionContext, sessionID string, batch int) (*psiadapter.ServerContext, error) {
+	if sessionCtx.BatchContext == nil {
+		if batch != 0 {
+			apierror.Write(w, r, http.StatusBadRequest, apierror.CodeBadRequest, "Session is not batched")
+			return nil, errResponded
+		}
+		return sessionCtx.ServerContext, nil
+	}
+
+	numBatches := sessionCtx.BatchContext.Len()
+	if batch < 0 || batch >= numBatches {
+		apierror.Write(w, r, http.StatusBadRequest, apierror.CodeBadRequest,
+			fmt.Sprintf("Batch %d out of range (session has %d batches)", batch, numBatches))
+		return nil, errResponded
+	}
+	target, err := sessionCtx.BatchContext.Batch(batch)
+	if errors.Is(err, psiadapter.ErrBatchRebuilt) {
+		s.recordError(r, sessionID, fmt.Sprintf("intersect: batch %d was rebuilt", batch))
+		apierror.Write(w, r, http.StatusConflict, apierror.CodeParamsChanged,
+			fmt.Sprintf("Batch %d was rebuilt; start a new session for fresh parameters", batch))
+		return nil, errResponded
+	}
+	if err != nil {
+		log.Printf("Failed to load batch %d: %v", batch, err)
+		s.recordError(r, sessionID, fmt.Sprintf("intersect: failed to load batch %d: %v", batch, err))
+		apierror.Write(w, r, http.StatusInternalServerError, apierror.CodePSIFailed, "Failed to load batch")
+		return nil, errResponded
+	}
+	return target, nil
+}
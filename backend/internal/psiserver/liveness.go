@@ -0,0 +1,53 @@
+package psiserver
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/SanthoshCheemala/FLARE/backend/internal/models"
+	"github.com/SanthoshCheemala/FLARE/backend/internal/protocol"
+	"github.com/go-chi/chi/v5"
+)
+
+// handlePing keeps a session alive. Any authorized session request counts
+// as a sign of life; ping lets a client busy encrypting show it without
+// doing anything else.
+func (s *Server) handlePing(w http.ResponseWriter, r *http.Request) {
+	sessionID := chi.URLParam(r, "sessionID")
+	if _, err := s.authorizeSession(r, sessionID); err != nil {
+		writeSessionAuthError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(protocol.PingResponse{
+		SessionID:          sessionID,
+		IdleTimeoutSeconds: s.idleTimeoutSeconds(),
+	})
+}
+
+func (s *Server) idleTimeoutSeconds() int {
+	return int(s.cfg.PSI.SessionIdleTimeout / time.Second)
+}
+
+// expireIdleSessions ends sessions whose client has stopped sending
+// requests, so a vanished client's dynamic tree and batch pins are freed
+// well before its token would expire
+func (s *Server) expireIdleSessions() {
+	timeout := s.cfg.PSI.SessionIdleTimeout
+	ticker := time.NewTicker(max(timeout/4, time.Second))
+	defer ticker.Stop()
+
+	for range ticker.C {
+		for _, id := range s.sessions.ExpireIdle(time.Now().Add(-timeout)) {
+			log.Printf("Session %s expired after %s without a request", id, timeout)
+			e := models.SessionEvent{SessionID: id, Event: models.SessionEventExpire, Detail: "idle"}
+			if err := s.repo.CreateSessionEvent(context.Background(), &e); err != nil {
+				log.Printf("Warning: failed to record %s event for session %q: %v", e.Event, id, err)
+			}
+		}
+	}
+}
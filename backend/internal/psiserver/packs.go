@@ -0,0 +1,356 @@
+package psiserver
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/SanthoshCheemala/FLARE/backend/internal/apierror"
+	"github.com/SanthoshCheemala/FLARE/backend/internal/models"
+	"github.com/go-chi/chi/v5"
+	"github.com/gorilla/websocket"
+)
+
+// packIDPattern restricts pack IDs to URL-safe slugs
+var packIDPattern = regexp.MustCompile(`^[a-z0-9][a-z0-9-]{0,63}$`)
+
+// packPingInterval keeps idle update streams open through proxies and
+// detects clients that went away
+const packPingInterval = 30 * time.Second
+
+// packHub fans pack changes out to the update streams subscribed to them.
+// Each stream holds only the latest update: a slow client skips straight to
+// the current pack version.
+type packHub struct {
+	mu   sync.Mutex
+	subs map[string]map[chan models.PackUpdate]struct{}
+}
+
+func newPackHub() *packHub {
+	return &packHub{subs: make(map[string]map[chan models.PackUpdate]struct{})}
+}
+
+func (h *packHub) subscribe(packID string) chan models.PackUpdate {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	ch := make(chan models.PackUpdate, 1)
+	if h.subs[packID] == nil {
+		h.subs[packID] = make(map[chan models.PackUpdate]struct{})
+	}
+	h.subs[packID][ch] = struct{}{}
+	return ch
+}
+
+func (h *packHub) unsubscribe(packID string, ch chan models.PackUpdate) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	delete(h.subs[packID], ch)
+	if len(h.subs[packID]) == 0 {
+		delete(h.subs, packID)
+	}
+}
+
+func (h *packHub) publish(u models.PackUpdate) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for ch := range h.subs[u.Pack.ID] {
+		select {
+		case <-ch: // Drop the stale update
+		default:
+		}
+		ch <- u
+	}
+}
+
+// catalogPack hides the authority's internal list IDs from clients
+func catalogPack(p models.WatchlistPack) models.WatchlistPack {
+	p.ListIDs = nil
+	return p
+}
+
+// notifyPacks pushes the current state of each pack to its subscribers
+func (s *Server) notifyPacks(ctx context.Context, ids []string) {
+	for _, id := range ids {
+		p, err := s.repo.GetPack(ctx, id)
+		if err != nil {
+			log.Printf("Warning: failed to load pack %s for update: %v", id, err)
+			continue
+		}
+		if p == nil {
+			continue
+		}
+		s.packs.publish(models.PackUpdate{Event: models.PackEventUpdated, Pack: catalogPack(*p)})
+	}
+}
+
+// packRequest creates or replaces a pack
+type packRequest struct {
+	ID          string  `json:"id"` // Create only
+	Name        string  `json:"name"`
+	Description string  `json:"description"`
+	ListIDs     []int64 `json:"listIds"`
+}
+
+// validatePackLists checks that every list in a pack exists
+func (s *Server) validatePackLists(ctx context.Context, ids []int64) (string, error) {
+	lists, err := s.repo.GetSanctionLists(ctx)
+	if err != nil {
+		return "", err
+	}
+	known := make(map[int64]bool, len(lists))
+	for _, l := range lists {
+		known[l.ID] = true
+	}
+	for _, id := range ids {
+		if !known[id] {
+			return fmt.Sprintf("Unknown list %d", id), nil
+		}
+	}
+	return "", nil
+}
+
+func (s *Server) handleAdminListPacks(w http.ResponseWriter, r *http.Request) {
+	packs, err := s.repo.ListPacks(r.Context())
+	if err != nil {
+		apierror.Write(w, r, http.StatusInternalServerError, apierror.CodeDatabaseError, "Failed to list packs")
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{"packs": packs})
+}
+
+func (s *Server) handleAdminCreatePack(w http.ResponseWriter, r *http.Request) {
+	var req packRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		apierror.Write(w, r, http.StatusBadRequest, apierror.CodeBadRequest, "Invalid request body")
+		return
+	}
+	req.ID = strings.TrimSpace(req.ID)
+	if !packIDPattern.MatchString(req.ID) {
+		apierror.Write(w, r, http.StatusBadRequest, apierror.CodeBadRequest,
+			"Pack ID must be 1-64 lowercase letters, digits or dashes, starting with a letter or digit")
+		return
+	}
+	if strings.TrimSpace(req.Name) == "" {
+		apierror.Write(w, r, http.StatusBadRequest, apierror.CodeBadRequest, "Request must include a name")
+		return
+	}
+	if msg, err := s.validatePackLists(r.Context(), req.ListIDs); err != nil || msg != "" {
+		writePackListError(w, r, msg, err)
+		return
+	}
+
+	existing, err := s.repo.GetPack(r.Context(), req.ID)
+	if err != nil {
+		apierror.Write(w, r, http.StatusInternalServerError, apierror.CodeDatabaseError, "Database error")
+		return
+	}
+	if existing != nil {
+		apierror.Write(w, r, http.StatusConflict, apierror.CodeConflict, "Pack "+req.ID+" already exists")
+		return
+	}
+
+	pack := &models.WatchlistPack{
+		ID:          req.ID,
+		Name:        strings.TrimSpace(req.Name),
+		Description: req.Description,
+		ListIDs:     req.ListIDs,
+	}
+	if err := s.repo.CreatePack(r.Context(), pack); err != nil {
+		apierror.Write(w, r, http.StatusInternalServerError, apierror.CodeDatabaseError, "Failed to store pack")
+		return
+	}
+	created, err := s.repo.GetPack(r.Context(), req.ID)
+	if err != nil || created == nil {
+		apierror.Write(w, r, http.StatusInternalServerError, apierror.CodeDatabaseError, "Failed to load pack")
+		return
+	}
+
+	log.Printf("Pack %s (%s) created with lists %v", created.ID, created.Name, created.ListIDs)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(created)
+}
+
+func (s *Server) handleAdminUpdatePack(w http.ResponseWriter, r *http.Request) {
+	var req packRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		apierror.Write(w, r, http.StatusBadRequest, apierror.CodeBadRequest, "Invalid request body")
+		return
+	}
+	if strings.TrimSpace(req.Name) == "" {
+		apierror.Write(w, r, http.StatusBadRequest, apierror.CodeBadRequest, "Request must include a name")
+		return
+	}
+	if msg, err := s.validatePackLists(r.Context(), req.ListIDs); err != nil || msg != "" {
+		writePackListError(w, r, msg, err)
+		return
+	}
+
+	id := chi.URLParam(r, "id")
+	updated, err := s.repo.UpdatePack(r.Context(), &models.WatchlistPack{
+		ID:          id,
+		Name:        strings.TrimSpace(req.Name),
+		Description: req.Description,
+		ListIDs:     req.ListIDs,
+	})
+	if err != nil {
+		apierror.Write(w, r, http.StatusInternalServerError, apierror.CodeDatabaseError, "Failed to update pack")
+		return
+	}
+	if !updated {
+		apierror.Write(w, r, http.StatusNotFound, apierror.CodePackNotFound, "Pack not found")
+		return
+	}
+	pack, err := s.repo.GetPack(r.Context(), id)
+	if err != nil || pack == nil {
+		apierror.Write(w, r, http.StatusInternalServerError, apierror.CodeDatabaseError, "Failed to load pack")
+		return
+	}
+
+	log.Printf("Pack %s updated to version %d with lists %v", pack.ID, pack.Version, pack.ListIDs)
+	s.packs.publish(models.PackUpdate{Event: models.PackEventUpdated, Pack: catalogPack(*pack)})
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(pack)
+}
+
+func (s *Server) handleAdminDeletePack(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+	pack, err := s.repo.GetPack(r.Context(), id)
+	if err != nil {
+		apierror.Write(w, r, http.StatusInternalServerError, apierror.CodeDatabaseError, "Database error")
+		return
+	}
+	if pack == nil {
+		apierror.Write(w, r, http.StatusNotFound, apierror.CodePackNotFound, "Pack not found")
+		return
+	}
+	if _, err := s.repo.DeletePack(r.Context(), id); err != nil {
+		apierror.Write(w, r, http.StatusInternalServerError, apierror.CodeDatabaseError, "Failed to delete pack")
+		return
+	}
+
+	log.Printf("Pack %s deleted", id)
+	s.packs.publish(models.PackUpdate{Event: models.PackEventDeleted, Pack: catalogPack(*pack)})
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]bool{"success": true})
+}
+
+func writePackListError(w http.ResponseWriter, r *http.Request, msg string, err error) {
+	if err != nil {
+		apierror.Write(w, r, http.StatusInternalServerError, apierror.CodeDatabaseError, "Failed to load lists")
+		return
+	}
+	apierror.Write(w, r, http.StatusBadRequest, apierror.CodeListNotFound, msg)
+}
+
+// handleListPacks returns the pack catalog clients subscribe from
+func (s *Server) handleListPacks(w http.ResponseWriter, r *http.Request) {
+	packs, err := s.repo.ListPacks(r.Context())
+	if err != nil {
+		apierror.Write(w, r, http.StatusInternalServerError, apierror.CodeDatabaseError, "Failed to list packs")
+		return
+	}
+	for i := range packs {
+		packs[i] = catalogPack(packs[i])
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{"packs": packs})
+}
+
+func (s *Server) handleGetPack(w http.ResponseWriter, r *http.Request) {
+	pack, err := s.repo.GetPack(r.Context(), chi.URLParam(r, "id"))
+	if err != nil {
+		apierror.Write(w, r, http.StatusInternalServerError, apierror.CodeDatabaseError, "Database error")
+		return
+	}
+	if pack == nil {
+		apierror.Write(w, r, http.StatusNotFound, apierror.CodePackNotFound, "Pack not found")
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(catalogPack(*pack))
+}
+
+var packUpgrader = websocket.Upgrader{
+	// Clients are backends authenticated by API key, not browsers
+	CheckOrigin: func(r *http.Request) bool { return true },
+}
+
+// handlePackUpdates streams a pack over a WebSocket: its current state on
+// connect, then a message each time it changes, until it is deleted
+func (s *Server) handlePackUpdates(w http.ResponseWriter, r *http.Request) {
+	// Subscribe before loading the snapshot so no change is missed
+	id := chi.URLParam(r, "id")
+	updates := s.packs.subscribe(id)
+	defer s.packs.unsubscribe(id, updates)
+
+	pack, err := s.repo.GetPack(r.Context(), id)
+	if err != nil {
+		apierror.Write(w, r, http.StatusInternalServerError, apierror.CodeDatabaseError, "Database error")
+		return
+	}
+	if pack == nil {
+		apierror.Write(w, r, http.StatusNotFound, apierror.CodePackNotFound, "Pack not found")
+		return
+	}
+
+	conn, err := packUpgrader.Upgrade(w, r, nil)
+	if err != nil {
+		log.Printf("Warning: pack update stream upgrade failed: %v", err)
+		return
+	}
+	defer conn.Close()
+
+	// The read loop only notices the client closing the connection
+	closed := make(chan struct{})
+	go func() {
+		defer close(closed)
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}()
+
+	if err := conn.WriteJSON(models.PackUpdate{Event: models.PackEventUpdated, Pack: catalogPack(*pack)}); err != nil {
+		return
+	}
+	ping := time.NewTicker(packPingInterval)
+	defer ping.Stop()
+	for {
+		select {
+		case u := <-updates:
+			if err := conn.WriteJSON(u); err != nil {
+				return
+			}
+			if u.Event == models.PackEventDeleted {
+				conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, "pack deleted"))
+				return
+			}
+		case <-ping.C:
+			if err := conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(10*time.Second)); err != nil {
+				return
+			}
+		case <-closed:
+			return
+		}
+	}
+}
+
+// listChanged bumps and pushes every pack holding listID after its records
+// changed
+func (s *Server) listChanged(ctx context.Context, listID int64) {
+	ids, err := s.repo.BumpPacksForList(ctx, listID)
+	if err != nil {
+		log.Printf("Warning: failed to update packs for list %d: %v", listID, err)
+		return
+	}
+	s.notifyPacks(ctx, ids)
+}
@@ -0,0 +1,159 @@
+package psiserver
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/binary"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/SanthoshCheemala/FLARE/backend/internal/models"
+	"github.com/SanthoshCheemala/FLARE/backend/internal/repository"
+)
+
+// laplaceNoise makes aggregate counts epsilon-differentially private so they
+// can be shared outside the authority. Each count is released with its own
+// epsilon, so a response with k noised counts spends k*epsilon in total.
+type laplaceNoise struct {
+	epsilon float64
+	// matchCap bounds the matches one session adds to a match count, and so
+	// is that count's sensitivity. A customer record can match several
+	// sanction entries, through aliases, documents or duplicates across
+	// batches, and the server cannot tell which ciphertexts belong to one
+	// record, so contributions are capped per session, which bounds every
+	// record in it too.
+	matchCap int
+	// intersectionCap does the same for intersect requests and errors: a
+	// session may send any number of them
+	intersectionCap int
+}
+
+// newLaplaceNoise returns the noise configured for the server; its epsilon
+// is 0 when shared statistics are exact
+func (s *Server) newLaplaceNoise() laplaceNoise {
+	return laplaceNoise{
+		epsilon:         s.cfg.PSI.StatsEpsilon,
+		matchCap:        s.cfg.PSI.StatsMatchCap,
+		intersectionCap: s.cfg.PSI.StatsIntersectionCap,
+	}
+}
+
+// caps bounds each session's share of the counts read for noising
+func (n laplaceNoise) caps() repository.SessionCaps {
+	return repository.SessionCaps{Intersections: n.intersectionCap, Matches: n.matchCap, Errors: n.intersectionCap}
+}
+
+// count returns v plus Laplace noise of scale sensitivity/epsilon, rounded
+// and clamped at zero. Sensitivity is how much the unit being protected
+// (one session start or intersect request, or one session's capped matches)
+// can change v.
+func (n laplaceNoise) count(v int, sensitivity float64) int {
+	noisy := math.Round(float64(v) + laplace(sensitivity/n.epsilon))
+	if noisy < 0 {
+		return 0
+	}
+	return int(noisy)
+}
+
+// describe tells consumers how the numbers they received were perturbed
+func (n laplaceNoise) describe() map[string]interface{} {
+	return map[string]interface{}{
+		"mechanism":               "laplace",
+		"epsilon":                 n.epsilon,
+		"matchSensitivity":        n.matchCap,
+		"intersectionSensitivity": n.intersectionCap,
+	}
+}
+
+// laplace samples from a zero-mean Laplace distribution. It reads
+// crypto/rand so the noise cannot be predicted and subtracted.
+func laplace(scale float64) float64 {
+	var b [8]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		panic("crypto/rand failed: " + err.Error())
+	}
+	// Uniform in (-0.5, 0.5), excluding the endpoints where the log diverges
+	u := (float64(binary.BigEndian.Uint64(b[:])>>11)+0.5)/(1<<53) - 0.5
+	return -scale * math.Copysign(1, u) * math.Log(1-2*math.Abs(u))
+}
+
+// noisyUsage is a UsageRecord safe to share externally, read with caps().
+// Ciphertext counts and
+// CPU time are left out: one session can move them by an unbounded amount,
+// so no fixed sensitivity calibrates them.
+type noisyUsage struct {
+	Month         string `json:"month"`
+	APIKeyID      int64  `json:"apiKeyId"`
+	APIKeyName    string `json:"apiKeyName"`
+	Sessions      int    `json:"sessions"`
+	Intersections int    `json:"intersections"`
+	Matches       int    `json:"matches"`
+	Errors        int    `json:"errors"`
+}
+
+func (n laplaceNoise) usage(u models.UsageRecord) noisyUsage {
+	return noisyUsage{
+		Month:         u.Month,
+		APIKeyID:      u.APIKeyID,
+		APIKeyName:    u.APIKeyName,
+		Sessions:      n.count(u.Sessions, 1),
+		Intersections: n.count(u.Intersections, float64(n.intersectionCap)),
+		Matches:       n.count(u.Matches, float64(n.matchCap)),
+		Errors:        n.count(u.Errors, float64(n.intersectionCap)),
+	}
+}
+
+// releases keeps the noised statistics already released for each reporting
+// period. Fresh noise on every request could be averaged away by asking
+// repeatedly, so the first request in a period fixes the numbers every
+// caller gets until the period ends. Releases live in memory; a restart
+// draws them again.
+type releases struct {
+	mu     sync.Mutex
+	day    string // UTC day the totals were drawn for
+	totals [2]int // Noised screenings and matches
+	months map[string][]noisyUsage
+}
+
+func newReleases() *releases {
+	return &releases{months: make(map[string][]noisyUsage)}
+}
+
+// dailyTotals returns the noised session and match totals released today,
+// drawing them on the day's first call
+func (rl *releases) dailyTotals(ctx context.Context, repo *repository.Repository, n laplaceNoise) (screenings, matches int, err error) {
+	day := time.Now().UTC().Format("2006-01-02")
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+	if rl.day != day {
+		sessions, found, err := repo.GetSessionTotals(ctx, n.matchCap)
+		if err != nil {
+			return 0, 0, err
+		}
+		rl.day = day
+		rl.totals = [2]int{n.count(sessions, 1), n.count(found, float64(n.matchCap))}
+	}
+	return rl.totals[0], rl.totals[1], nil
+}
+
+// monthlyUsage returns the noised usage released for a completed month,
+// drawing it on the first call for that month
+func (rl *releases) monthlyUsage(ctx context.Context, repo *repository.Repository, n laplaceNoise, month time.Time) ([]noisyUsage, error) {
+	key := month.Format(monthLayout)
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+	if records, ok := rl.months[key]; ok {
+		return records, nil
+	}
+	usage, err := repo.GetUsage(ctx, month, month.AddDate(0, 1, 0), n.caps())
+	if err != nil {
+		return nil, err
+	}
+	records := make([]noisyUsage, len(usage))
+	for i, u := range usage {
+		records[i] = n.usage(u)
+	}
+	rl.months[key] = records
+	return records, nil
+}
@@ -0,0 +1,82 @@
+package psiserver
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/SanthoshCheemala/FLARE/backend/internal/models"
+)
+
+func TestNoisedStatsReleasedOncePerDay(t *testing.T) {
+	s := newTestServer(t, map[string]string{"ADMIN_TOKEN": "admin-secret", "PSI_STATS_EPSILON": "0.1"})
+	for _, e := range []models.SessionEvent{
+		{SessionID: "s1", Event: "init"},
+		{SessionID: "s1", Event: "intersect", MatchCount: 40},
+	} {
+		if err := s.repo.CreateSessionEvent(context.Background(), &e); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	get := func() map[string]interface{} {
+		req := httptest.NewRequest(http.MethodGet, "/dashboard/stats", nil)
+		req.Header.Set("Authorization", "Bearer admin-secret")
+		rec := httptest.NewRecorder()
+		s.Handler().ServeHTTP(rec, req)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("status = %d: %s", rec.Code, rec.Body)
+		}
+		var stats map[string]interface{}
+		if err := json.NewDecoder(rec.Body).Decode(&stats); err != nil {
+			t.Fatal(err)
+		}
+		return stats
+	}
+
+	// With epsilon 0.1 fresh draws would almost never agree five times
+	first := get()
+	for i := 0; i < 4; i++ {
+		stats := get()
+		if stats["totalScreenings"] != first["totalScreenings"] || stats["totalMatches"] != first["totalMatches"] {
+			t.Fatalf("request %d got %v and %v, first got %v and %v", i+2,
+				stats["totalScreenings"], stats["totalMatches"], first["totalScreenings"], first["totalMatches"])
+		}
+	}
+	noise, _ := first["noise"].(map[string]interface{})
+	if noise["intersectionSensitivity"] != float64(10) {
+		t.Errorf("noise = %v, want intersectionSensitivity 10", noise)
+	}
+}
+
+func TestNoisedUsageCompletedMonthsOnly(t *testing.T) {
+	s := newTestServer(t, map[string]string{"ADMIN_TOKEN": "admin-secret", "PSI_STATS_EPSILON": "1"})
+	now := time.Now().UTC()
+	current := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
+
+	usage := func(query string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/admin/usage?"+query, nil)
+		req.Header.Set("Authorization", "Bearer admin-secret")
+		rec := httptest.NewRecorder()
+		s.Handler().ServeHTTP(rec, req)
+		return rec
+	}
+
+	if rec := usage("noise=true&to=" + current.Format(monthLayout)); rec.Code != http.StatusBadRequest {
+		t.Errorf("noised usage of the current month: status = %d, want %d", rec.Code, http.StatusBadRequest)
+	}
+	if rec := usage("noise=true"); rec.Code != http.StatusOK {
+		t.Fatalf("noised usage: status = %d: %s", rec.Code, rec.Body)
+	}
+	last := current.AddDate(0, -1, 0).Format(monthLayout)
+	if _, ok := s.released.months[last]; !ok {
+		t.Errorf("noised usage of %s was not kept for later requests", last)
+	}
+	// Exact usage is not limited to completed months
+	if rec := usage("to=" + current.Format(monthLayout)); rec.Code != http.StatusOK {
+		t.Errorf("exact usage of the current month: status = %d", rec.Code)
+	}
+}
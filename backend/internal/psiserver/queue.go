@@ -0,0 +1,62 @@
+package psiserver
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/SanthoshCheemala/FLARE/backend/internal/apierror"
+	"github.com/SanthoshCheemala/FLARE/backend/internal/mq"
+)
+
+// queueGroup is the NATS queue group of PSI servers, so each queued request
+// is handled by one of them
+const queueGroup = "flare-psi"
+
+// maxQueueBackoff caps the wait between attempts to reach the queue
+const maxQueueBackoff = time.Minute
+
+// ServeQueue consumes session requests from the message queue configured by
+// PSI_QUEUE_URL until ctx ends, reconnecting when the connection is lost.
+// Requests are handled PSI_QUEUE_WORKERS at a time by the same handlers as
+// over HTTP; the rest wait in the queue. Queued requests all come from the
+// host "mq", so they share one resolve rate limit.
+func (s *Server) ServeQueue(ctx context.Context) {
+	handler := queueRoutes(s.Handler())
+	backoff := time.Second
+	for ctx.Err() == nil {
+		conn, err := mq.Dial(ctx, s.cfg.PSI.QueueURL, "flare-psi-server")
+		if err != nil {
+			log.Printf("Warning: failed to connect to the queue; retrying in %s: %v", backoff, err)
+		} else {
+			log.Printf("Consuming session requests from %s with %d worker(s)", s.cfg.PSI.QueueSubject, s.cfg.PSI.QueueWorkers)
+			backoff = time.Second
+			err = mq.Serve(ctx, conn, s.cfg.PSI.QueueSubject, queueGroup, s.cfg.PSI.QueueWorkers, handler)
+			conn.Close()
+			if ctx.Err() != nil {
+				return
+			}
+			log.Printf("Warning: queue connection lost; reconnecting in %s: %v", backoff, err)
+		}
+		select {
+		case <-time.After(backoff):
+		case <-ctx.Done():
+			return
+		}
+		backoff = min(2*backoff, maxQueueBackoff)
+	}
+}
+
+// queueRoutes only lets the session exchange and capabilities through:
+// lists, packs and admin endpoints stay HTTP only
+func queueRoutes(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.HasPrefix(r.URL.Path, "/session/") && r.URL.Path != "/capabilities" {
+			apierror.Write(w, r, http.StatusNotFound, apierror.CodeNotFound, "Only session requests are served over the queue")
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,244 @@
+package psiserver
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/SanthoshCheemala/FLARE/backend/internal/apierror"
+	"github.com/SanthoshCheemala/FLARE/backend/internal/models"
+	"github.com/go-chi/chi/v5"
+)
+
+// quotaHolder is who a session quota applies to: the request's API key, or
+// for a request without one its client address
+type quotaHolder struct {
+	apiKeyID int64
+	client   string // Set when apiKeyID is 0
+	quota    models.APIKeyQuota
+}
+
+// requestQuotaHolder returns the holder of the request's session quota.
+// Each client address calling without an API key gets the anonymous quota
+// on its own.
+func (s *Server) requestQuotaHolder(r *http.Request) quotaHolder {
+	if key := requestAPIKey(r.Context()); key != nil {
+		return quotaHolder{apiKeyID: key.ID, quota: key.Quota}
+	}
+	return quotaHolder{client: clientKey(r), quota: models.APIKeyQuota{
+		MaxSessionsPerDay:     s.cfg.PSI.AnonymousSessionsPerDay,
+		MaxConcurrentSessions: s.cfg.PSI.AnonymousConcurrentSessions,
+	}}
+}
+
+func (h quotaHolder) String() string {
+	if h.apiKeyID == 0 {
+		return "client " + h.client
+	}
+	return fmt.Sprintf("API key %d", h.apiKeyID)
+}
+
+// liveSessions counts the holder's live sessions
+func (s *Server) liveSessions(h quotaHolder) int {
+	if h.apiKeyID == 0 {
+		return s.sessions.CountForClient(h.client)
+	}
+	return s.sessions.CountForAPIKey(h.apiKeyID)
+}
+
+// sessionsSince counts the sessions the holder started at or after since
+func (s *Server) sessionsSince(r *http.Request, h quotaHolder, since time.Time) (int, error) {
+	if h.apiKeyID == 0 {
+		return s.repo.CountClientSessionEvents(r.Context(), h.client, models.SessionEventInit, since)
+	}
+	return s.repo.CountSessionEvents(r.Context(), h.apiKeyID, models.SessionEventInit, since)
+}
+
+// checkSessionQuota enforces the daily and concurrent session limits of the
+// request's API key, or the anonymous ones for requests without a key,
+// before a session is started. It writes the error response and returns
+// false when a limit is reached.
+func (s *Server) checkSessionQuota(w http.ResponseWriter, r *http.Request) bool {
+	holder := s.requestQuotaHolder(r)
+	quota := holder.quota
+
+	if quota.MaxConcurrentSessions > 0 {
+		if n := s.liveSessions(holder); n >= quota.MaxConcurrentSessions {
+			s.recordError(r, "", fmt.Sprintf("quota: %d of %d concurrent sessions in use", n, quota.MaxConcurrentSessions))
+			apierror.WriteDetails(w, r, http.StatusTooManyRequests, apierror.CodeQuotaExceeded,
+				"Concurrent session limit reached; close a session and retry",
+				map[string]int{"maxConcurrentSessions": quota.MaxConcurrentSessions})
+			return false
+		}
+	}
+
+	if quota.MaxSessionsPerDay > 0 {
+		now := time.Now().UTC()
+		day := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+		n, err := s.sessionsSince(r, holder, day)
+		if err != nil {
+			// Fail open: accounting problems should not stop screening
+			log.Printf("Warning: failed to count sessions for %s: %v", holder, err)
+			return true
+		}
+		if n >= quota.MaxSessionsPerDay {
+			s.recordError(r, "", fmt.Sprintf("quota: %d of %d sessions used today", n, quota.MaxSessionsPerDay))
+			w.Header().Set("Retry-After", strconv.Itoa(int(day.AddDate(0, 0, 1).Sub(now).Seconds())+1))
+			apierror.WriteDetails(w, r, http.StatusTooManyRequests, apierror.CodeQuotaExceeded,
+				"Daily session limit reached",
+				map[string]int{"maxSessionsPerDay": quota.MaxSessionsPerDay})
+			return false
+		}
+	}
+	return true
+}
+
+// checkCiphertextQuota rejects intersect requests carrying more ciphertexts
+// than the server accepts in one request, with 413, or than the request's
+// API key allows, with 403
+func (s *Server) checkCiphertextQuota(w http.ResponseWriter, r *http.Request, sessionID string, count int) bool {
+	if limit := s.cfg.PSI.MaxCiphertextsPerRequest; limit > 0 && count > limit {
+		s.recordError(r, sessionID, fmt.Sprintf("intersect: more than %d ciphertexts in one request", limit))
+		apierror.WriteDetails(w, r, http.StatusRequestEntityTooLarge, apierror.CodeBodyTooLarge,
+			fmt.Sprintf("Request has more than %d ciphertexts; split the batch across requests", limit),
+			map[string]int{"maxCiphertextsPerRequest": limit})
+		return false
+	}
+	key := requestAPIKey(r.Context())
+	if key == nil || key.Quota.MaxCiphertextsPerRequest <= 0 || count <= key.Quota.MaxCiphertextsPerRequest {
+		return true
+	}
+
+	s.recordError(r, sessionID, fmt.Sprintf("quota: %d ciphertexts exceeds limit of %d", count, key.Quota.MaxCiphertextsPerRequest))
+	apierror.WriteDetails(w, r, http.StatusForbidden, apierror.CodeQuotaExceeded,
+		fmt.Sprintf("Request has %d ciphertexts; this API key allows %d per request", count, key.Quota.MaxCiphertextsPerRequest),
+		map[string]int{"maxCiphertextsPerRequest": key.Quota.MaxCiphertextsPerRequest})
+	return false
+}
+
+// resolveMinimum returns the ciphertexts a session must have intersected
+// before it may resolve. A key's quota overrides the server's minimum.
+// Without a key (nil) the strictest of the server's and every active key's
+// minimum applies, so a client cannot escape its key's minimum by leaving
+// the key out.
+func (s *Server) resolveMinimum(ctx context.Context, key *models.APIKey) (int, error) {
+	if key != nil && key.Quota.MinResolveCiphertexts > 0 {
+		return key.Quota.MinResolveCiphertexts, nil
+	}
+	if key != nil {
+		return s.cfg.PSI.MinResolveCiphertexts, nil
+	}
+	strictest, err := s.repo.MaxMinResolveCiphertexts(ctx)
+	if err != nil {
+		return 0, err
+	}
+	return max(s.cfg.PSI.MinResolveCiphertexts, strictest), nil
+}
+
+// checkResolveMinimum rejects resolve requests of sessions that have
+// intersected fewer ciphertexts than resolveMinimum requires. A session of
+// one or a few records would otherwise tell whether chosen individuals are
+// sanctioned. The request's key only counts if it started the session.
+func (s *Server) checkResolveMinimum(w http.ResponseWriter, r *http.Request, sessionID string, sc SessionContext) bool {
+	key := requestAPIKey(r.Context())
+	if key != nil && key.ID != sc.APIKeyID {
+		key = nil
+	}
+	minimum, err := s.resolveMinimum(r.Context(), key)
+	if err != nil {
+		apierror.Write(w, r, http.StatusInternalServerError, apierror.CodeDatabaseError, "Database error")
+		return false
+	}
+	ciphertexts := sc.Ciphertexts
+	if ciphertexts >= minimum {
+		return true
+	}
+
+	log.Printf("Rejected resolve for session %s from %s: %d ciphertexts intersected, %d required", sessionID, clientKey(r), ciphertexts, minimum)
+	s.recordEvent(r, models.SessionEvent{
+		SessionID:       sessionID,
+		Event:           models.SessionEventError,
+		CiphertextCount: ciphertexts,
+		Detail:          fmt.Sprintf("resolve: %d ciphertexts intersected, fewer than the %d required", ciphertexts, minimum),
+	})
+	apierror.WriteDetails(w, r, http.StatusForbidden, apierror.CodeForbidden,
+		fmt.Sprintf("Session intersected %d ciphertexts; resolving requires at least %d", ciphertexts, minimum),
+		map[string]int{"minResolveCiphertexts": minimum, "ciphertexts": ciphertexts})
+	return false
+}
+
+// handleAdminGetQuota shows a key's quota and its current consumption
+func (s *Server) handleAdminGetQuota(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		apierror.Write(w, r, http.StatusBadRequest, apierror.CodeBadRequest, "Invalid API key ID")
+		return
+	}
+	key, err := s.repo.GetAPIKey(r.Context(), id)
+	if err != nil {
+		apierror.Write(w, r, http.StatusInternalServerError, apierror.CodeDatabaseError, "Database error")
+		return
+	}
+	if key == nil {
+		apierror.Write(w, r, http.StatusNotFound, apierror.CodeNotFound, "API key not found")
+		return
+	}
+
+	now := time.Now().UTC()
+	day := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	sessionsToday, err := s.repo.CountSessionEvents(r.Context(), id, models.SessionEventInit, day)
+	if err != nil {
+		apierror.Write(w, r, http.StatusInternalServerError, apierror.CodeDatabaseError, "Database error")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"id":                 key.ID,
+		"name":               key.Name,
+		"quota":              key.Quota,
+		"sessionsToday":      sessionsToday,
+		"concurrentSessions": s.sessions.CountForAPIKey(id),
+	})
+}
+
+// handleAdminSetQuota replaces a key's quota. Omitted or zero limits are
+// unlimited, or for the resolve minimum the server's.
+func (s *Server) handleAdminSetQuota(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		apierror.Write(w, r, http.StatusBadRequest, apierror.CodeBadRequest, "Invalid API key ID")
+		return
+	}
+	var quota models.APIKeyQuota
+	if err := json.NewDecoder(r.Body).Decode(&quota); err != nil {
+		apierror.Write(w, r, http.StatusBadRequest, apierror.CodeBadRequest, "Invalid request body")
+		return
+	}
+	if quota.MaxSessionsPerDay < 0 || quota.MaxCiphertextsPerRequest < 0 || quota.MaxConcurrentSessions < 0 ||
+		quota.MinResolveCiphertexts < 0 {
+		apierror.Write(w, r, http.StatusBadRequest, apierror.CodeBadRequest, "Quota limits must not be negative")
+		return
+	}
+
+	updated, err := s.repo.UpdateAPIKeyQuota(r.Context(), id, quota)
+	if err != nil {
+		apierror.Write(w, r, http.StatusInternalServerError, apierror.CodeDatabaseError, "Database error")
+		return
+	}
+	if !updated {
+		apierror.Write(w, r, http.StatusNotFound, apierror.CodeNotFound, "API key not found")
+		return
+	}
+
+	log.Printf("API key %d quota set to %+v", id, quota)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"id":    id,
+		"quota": quota,
+	})
+}
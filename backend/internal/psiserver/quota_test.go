@@ -0,0 +1,130 @@
+package psiserver
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/SanthoshCheemala/FLARE/backend/internal/auth"
+	"github.com/SanthoshCheemala/FLARE/backend/internal/models"
+)
+
+func TestPresentedAPIKeyMustBeValid(t *testing.T) {
+	// Keys are optional, but one that is sent must be valid
+	s := newTestServer(t, map[string]string{"PSI_REQUIRE_API_KEY": "false"})
+	ctx := context.Background()
+	revoked := &models.APIKey{Name: "old", Prefix: "flr_old", KeyHash: auth.HashAPIKey("flr_old-key")}
+	if err := s.repo.CreateAPIKey(ctx, revoked); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.repo.RevokeAPIKey(ctx, revoked.ID); err != nil {
+		t.Fatal(err)
+	}
+	active := &models.APIKey{Name: "bank-a", Prefix: "flr_a", KeyHash: auth.HashAPIKey("flr_a-key")}
+	if err := s.repo.CreateAPIKey(ctx, active); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tc := range []struct {
+		key  string
+		want int
+	}{
+		{"", http.StatusOK},
+		{"flr_a-key", http.StatusOK},
+		{"flr_unknown", http.StatusUnauthorized},
+		{"flr_old-key", http.StatusUnauthorized},
+	} {
+		req := httptest.NewRequest(http.MethodGet, "/capabilities", nil)
+		if tc.key != "" {
+			req.Header.Set("X-API-Key", tc.key)
+		}
+		rec := httptest.NewRecorder()
+		s.Handler().ServeHTTP(rec, req)
+		if rec.Code != tc.want {
+			t.Errorf("key %q: status = %d, want %d", tc.key, rec.Code, tc.want)
+		}
+	}
+}
+
+func TestAnonymousSessionQuota(t *testing.T) {
+	check := func(s *Server, remoteAddr string) int {
+		req := httptest.NewRequest(http.MethodPost, "/session/init", nil)
+		req.RemoteAddr = remoteAddr
+		rec := httptest.NewRecorder()
+		if s.checkSessionQuota(rec, req) {
+			return http.StatusOK
+		}
+		return rec.Code
+	}
+
+	t.Run("concurrent", func(t *testing.T) {
+		s := newTestServer(t, map[string]string{"PSI_ANONYMOUS_CONCURRENT_SESSIONS": "1"})
+		s.sessions.Add("anon", &SessionContext{Client: "10.0.0.1"})
+		s.sessions.Add("keyed", &SessionContext{Client: "10.0.0.2", APIKeyID: 7})
+
+		if got := check(s, "10.0.0.1:5000"); got != http.StatusTooManyRequests {
+			t.Errorf("client with a live session: status = %d, want %d", got, http.StatusTooManyRequests)
+		}
+		// Sessions started with a key do not count against the address
+		if got := check(s, "10.0.0.2:5000"); got != http.StatusOK {
+			t.Errorf("other client: status = %d, want %d", got, http.StatusOK)
+		}
+	})
+
+	t.Run("daily", func(t *testing.T) {
+		s := newTestServer(t, map[string]string{"PSI_ANONYMOUS_SESSIONS_PER_DAY": "1"})
+		init := models.SessionEvent{SessionID: "s1", Event: models.SessionEventInit, Client: "10.0.0.1"}
+		if err := s.repo.CreateSessionEvent(context.Background(), &init); err != nil {
+			t.Fatal(err)
+		}
+
+		if got := check(s, "10.0.0.1:5000"); got != http.StatusTooManyRequests {
+			t.Errorf("client that used its session: status = %d, want %d", got, http.StatusTooManyRequests)
+		}
+		if got := check(s, "10.0.0.2:5000"); got != http.StatusOK {
+			t.Errorf("other client: status = %d, want %d", got, http.StatusOK)
+		}
+	})
+}
+
+func TestResolveMinimum(t *testing.T) {
+	s := newTestServer(t, map[string]string{"PSI_MIN_RESOLVE_CIPHERTEXTS": "50"})
+	ctx := context.Background()
+	strict := &models.APIKey{Name: "bank-a", Prefix: "flr_a", KeyHash: auth.HashAPIKey("flr_a-key")}
+	if err := s.repo.CreateAPIKey(ctx, strict); err != nil {
+		t.Fatal(err)
+	}
+	strict.Quota.MinResolveCiphertexts = 500
+	if _, err := s.repo.UpdateAPIKeyQuota(ctx, strict.ID, strict.Quota); err != nil {
+		t.Fatal(err)
+	}
+	plain := &models.APIKey{ID: 99, Name: "bank-b"}
+
+	for _, tc := range []struct {
+		name string
+		key  *models.APIKey
+		want int
+	}{
+		{"key quota", strict, 500},
+		{"key without quota", plain, 50},
+		// Leaving the key out must not lower the minimum
+		{"no key", nil, 500},
+	} {
+		got, err := s.resolveMinimum(ctx, tc.key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != tc.want {
+			t.Errorf("%s: minimum = %d, want %d", tc.name, got, tc.want)
+		}
+	}
+
+	// A key that did not start the session does not apply to it
+	req := httptest.NewRequest(http.MethodPost, "/psi/resolve", nil)
+	req = req.WithContext(context.WithValue(req.Context(), apiKeyCtxKey, plain))
+	rec := httptest.NewRecorder()
+	if s.checkResolveMinimum(rec, req, "s1", SessionContext{APIKeyID: strict.ID, Ciphertexts: 100}) {
+		t.Error("resolve allowed below the session key's minimum")
+	}
+}
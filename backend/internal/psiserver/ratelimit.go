@@ -0,0 +1,75 @@
+package psiserver
+
+import (
+	"net"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// rateLimiter is a fixed-window limiter keyed by client address
+type rateLimiter struct {
+	mu      sync.Mutex
+	limit   int
+	window  time.Duration
+	windows map[string]*rateWindow
+}
+
+type rateWindow struct {
+	start time.Time
+	count int
+}
+
+func newRateLimiter(limit int, window time.Duration) *rateLimiter {
+	return &rateLimiter{
+		limit:   limit,
+		window:  window,
+		windows: make(map[string]*rateWindow),
+	}
+}
+
+// Allow records a hit for key and reports whether it is within the limit.
+// A non-positive limit disables limiting.
+func (rl *rateLimiter) Allow(key string) bool {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+
+	if rl.limit <= 0 {
+		return true
+	}
+
+	now := time.Now()
+	w, ok := rl.windows[key]
+	if !ok || now.Sub(w.start) >= rl.window {
+		// Drop expired windows so the map doesn't grow with every client ever seen
+		for k, old := range rl.windows {
+			if now.Sub(old.start) >= rl.window {
+				delete(rl.windows, k)
+			}
+		}
+		w = &rateWindow{start: now}
+		rl.windows[key] = w
+	}
+
+	if w.count >= rl.limit {
+		return false
+	}
+	w.count++
+	return true
+}
+
+// SetLimit changes the limit; windows already open keep their counts
+func (rl *rateLimiter) SetLimit(limit int) {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+	rl.limit = limit
+}
+
+// clientKey identifies the caller by remote host (port stripped)
+func clientKey(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
@@ -0,0 +1,102 @@
+package psiserver
+
+import (
+	"sync"
+	"time"
+)
+
+// Rebuild states reported by /admin/rebuild/status
+const (
+	RebuildIdle     = "IDLE"
+	RebuildRunning  = "RUNNING"
+	RebuildComplete = "COMPLETED"
+	RebuildFailed   = "FAILED"
+)
+
+// RebuildStatus describes the current or most recent global state rebuild
+type RebuildStatus struct {
+	State        string     `json:"state"`
+	ActiveSlot   string     `json:"activeSlot"`          // Slot serving sessions
+	BuildSlot    string     `json:"buildSlot,omitempty"` // Slot being (or last) built
+	Queued       int        `json:"queued"`              // Rebuilds waiting for this one
+	Records      int        `json:"records"`             // Sanction records in the build
+	BatchesDone  int        `json:"batchesDone"`         // Trees built so far
+	BatchesTotal int        `json:"batchesTotal"`        // Trees in the build
+	StartedAt    *time.Time `json:"startedAt,omitempty"`
+	FinishedAt   *time.Time `json:"finishedAt,omitempty"`
+	Error        string     `json:"error,omitempty"`
+}
+
+// rebuildTracker records rebuild progress for the status endpoint
+type rebuildTracker struct {
+	mu     sync.Mutex
+	queued int
+	cur    RebuildStatus
+}
+
+// standbySlot returns the slot a rebuild should build into
+func standbySlot(active string) string {
+	if active == "blue" {
+		return "green"
+	}
+	return "blue"
+}
+
+// queue counts a rebuild waiting for the rebuild lock
+func (t *rebuildTracker) queue() {
+	t.mu.Lock()
+	t.queued++
+	t.mu.Unlock()
+}
+
+// start marks a queued rebuild as running
+func (t *rebuildTracker) start(slot string) {
+	now := time.Now()
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.queued--
+	t.cur = RebuildStatus{
+		State:     RebuildRunning,
+		BuildSlot: slot,
+		StartedAt: &now,
+	}
+}
+
+func (t *rebuildTracker) setRecords(n int) {
+	t.mu.Lock()
+	t.cur.Records = n
+	t.mu.Unlock()
+}
+
+func (t *rebuildTracker) setBatches(done, total int) {
+	t.mu.Lock()
+	t.cur.BatchesDone = done
+	t.cur.BatchesTotal = total
+	t.mu.Unlock()
+}
+
+// finish records the outcome of the running rebuild
+func (t *rebuildTracker) finish(err error) {
+	now := time.Now()
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.cur.FinishedAt = &now
+	if err != nil {
+		t.cur.State = RebuildFailed
+		t.cur.Error = err.Error()
+		return
+	}
+	t.cur.State = RebuildComplete
+}
+
+// status returns a snapshot of the tracker
+func (t *rebuildTracker) status() RebuildStatus {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	s := t.cur
+	if s.State == "" {
+		s.State = RebuildIdle
+	}
+	s.Queued = t.queued
+	return s
+}
@@ -0,0 +1,134 @@
+package psiserver
+
+import (
+	"crypto/sha256"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/SanthoshCheemala/FLARE/backend/internal/apierror"
+	"github.com/SanthoshCheemala/FLARE/backend/internal/auth"
+)
+
+// maxTrackedNonces bounds the nonce cache. Signed requests are refused
+// rather than evicting nonces that could then be replayed.
+const maxTrackedNonces = 100000
+
+// nonceCache remembers request nonces for as long as their timestamps are
+// accepted, so a captured request cannot be sent twice
+type nonceCache struct {
+	mu   sync.Mutex
+	ttl  time.Duration
+	seen map[string]time.Time // Nonce to the time it can be forgotten
+}
+
+func newNonceCache(ttl time.Duration) *nonceCache {
+	return &nonceCache{
+		ttl:  ttl,
+		seen: make(map[string]time.Time),
+	}
+}
+
+// Use records nonce, returning auth.ErrNonceReused if it was seen already
+func (c *nonceCache) Use(nonce string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := time.Now()
+	if expires, ok := c.seen[nonce]; ok && now.Before(expires) {
+		return auth.ErrNonceReused
+	}
+	if len(c.seen) >= maxTrackedNonces {
+		for n, expires := range c.seen {
+			if !now.Before(expires) {
+				delete(c.seen, n)
+			}
+		}
+		if len(c.seen) >= maxTrackedNonces {
+			return auth.ErrNonceCacheFull
+		}
+	}
+	// Timestamps up to ttl in the future are accepted too
+	c.seen[nonce] = now.Add(2 * c.ttl)
+	return nil
+}
+
+// signatureRequired reports whether requests to the session must be signed.
+// Sessions issued a signing key always require it, so a client cannot skip
+// verification by leaving the headers out.
+func (s *Server) signatureRequired(sc *SessionContext) bool {
+	return sc.SigningKey != "" || s.cfg.PSI.RequireSignedRequests
+}
+
+// checkSignatureHeaders checks the signature headers on r: they must be
+// present if the session requires them, the timestamp must be fresh and the
+// nonce unused. The nonce is consumed. verifySignedRequest checks the
+// signature itself.
+func (s *Server) checkSignatureHeaders(r *http.Request, sc *SessionContext) error {
+	timestamp := r.Header.Get(auth.HeaderTimestamp)
+	nonce := r.Header.Get(auth.HeaderNonce)
+	signature := r.Header.Get(auth.HeaderSignature)
+	if timestamp == "" && nonce == "" && signature == "" && !s.signatureRequired(sc) {
+		return nil
+	}
+	if sc.SigningKey == "" {
+		return auth.ErrUnsignedRequest
+	}
+
+	if err := auth.VerifyHeaders(timestamp, nonce, signature, s.cfg.PSI.SignatureMaxSkew); err != nil {
+		return err
+	}
+	// Nonces only need to be unique per signing key, which is per session
+	return s.nonces.Use(sc.SigningKey[:16] + ":" + nonce)
+}
+
+// verifySignedRequest checks the signature on r, whose body hashes to
+// bodySum, against the session's signing key. Its headers must have passed
+// checkSignatureHeaders.
+func (s *Server) verifySignedRequest(r *http.Request, sc *SessionContext, bodySum []byte) error {
+	signature := r.Header.Get(auth.HeaderSignature)
+	if signature == "" {
+		return nil // Unsigned and not required, as checkSignatureHeaders found
+	}
+	return auth.VerifySignature(sc.SigningKey, r.Method, r.URL.Path,
+		r.Header.Get(auth.HeaderTimestamp), r.Header.Get(auth.HeaderNonce), signature, bodySum)
+}
+
+// spoolSignedBody copies the body of a signed request to a temporary file,
+// hashing it on the way, so the signature can be checked before any of the
+// body is acted on without holding it in memory. It returns the file,
+// rewound, and the SHA-256 of the body, or writes the error response and
+// returns false. Unsigned requests are left to stream and get no file.
+func (s *Server) spoolSignedBody(w http.ResponseWriter, r *http.Request) (*os.File, []byte, bool) {
+	if r.Header.Get(auth.HeaderSignature) == "" {
+		return nil, nil, true
+	}
+	spool, err := os.CreateTemp("", "flare-intersect-")
+	if err != nil {
+		log.Printf("Failed to spool request body: %v", err)
+		apierror.Write(w, r, http.StatusInternalServerError, apierror.CodeInternal, "Failed to read request body")
+		return nil, nil, false
+	}
+	bodySum := sha256.New()
+	_, err = io.Copy(io.MultiWriter(spool, bodySum), r.Body)
+	if err == nil {
+		_, err = spool.Seek(0, io.SeekStart)
+	}
+	if err != nil {
+		removeSpool(spool)
+		if !apierror.TooLarge(w, r, err) {
+			apierror.Write(w, r, http.StatusBadRequest, apierror.CodeBadRequest, "Invalid request body")
+		}
+		return nil, nil, false
+	}
+	return spool, bodySum.Sum(nil), true
+}
+
+// removeSpool closes and deletes a file from spoolSignedBody
+func removeSpool(spool *os.File) {
+	spool.Close()
+	os.Remove(spool.Name())
+}
@@ -0,0 +1,113 @@
+package psiserver
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/SanthoshCheemala/FLARE/backend/internal/auth"
+	"github.com/SanthoshCheemala/FLARE/backend/internal/psiadapter"
+)
+
+// signedSession registers a session with s and returns its ID, access
+// token and signing key
+func signedSession(t *testing.T, s *Server) (string, string, string) {
+	t.Helper()
+	sc := &SessionContext{ServerContext: &psiadapter.ServerContext{}}
+	token, _, err := s.registerSession(httptest.NewRequest(http.MethodPost, "/session/init", nil), "signed", sc)
+	if err != nil {
+		t.Fatalf("register session: %v", err)
+	}
+	return "signed", token, sc.SigningKey
+}
+
+// intersectBody is an intersect request with n ciphertexts
+func intersectBody(sessionID string, n int) string {
+	cts := make([]string, n)
+	for i := range cts {
+		cts[i] = "{}"
+	}
+	return `{"sessionId":"` + sessionID + `","batch":0,"ciphertexts":[` + strings.Join(cts, ",") + `]}`
+}
+
+// intersect sends body to s, signed with key and nonce unless key is empty
+func intersect(s *Server, token, key, nonce, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, "/session/intersect", strings.NewReader(body))
+	req.Header.Set("Authorization", "Bearer "+token)
+	if key != "" {
+		timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+		req.Header.Set(auth.HeaderTimestamp, timestamp)
+		req.Header.Set(auth.HeaderNonce, nonce)
+		req.Header.Set(auth.HeaderSignature, auth.SignRequest(key, req.Method, req.URL.Path, timestamp, nonce, []byte(body)))
+	}
+	rec := httptest.NewRecorder()
+	s.Handler().ServeHTTP(rec, req)
+	return rec
+}
+
+// The server caps requests at one ciphertext, so a request with two is only
+// refused with 401 if its signature headers are checked before the
+// ciphertexts are counted and intersected
+var signingOverrides = map[string]string{"PSI_MAX_CIPHERTEXTS_PER_REQUEST": "1"}
+
+func TestIntersectRejectsStrippedSignature(t *testing.T) {
+	s := newTestServer(t, signingOverrides)
+	id, token, _ := signedSession(t, s)
+
+	rec := intersect(s, token, "", "", intersectBody(id, 2))
+	if rec.Code != http.StatusUnauthorized {
+		t.Fatalf("unsigned request: status = %d, want %d: %s", rec.Code, http.StatusUnauthorized, rec.Body)
+	}
+	if sc, _ := s.sessions.Get(id); sc.Ciphertexts != 0 {
+		t.Errorf("unsigned request recorded %d ciphertexts", sc.Ciphertexts)
+	}
+}
+
+func TestIntersectRejectsReplayedNonce(t *testing.T) {
+	s := newTestServer(t, signingOverrides)
+	id, token, key := signedSession(t, s)
+	body := intersectBody(id, 1)
+
+	if rec := intersect(s, token, key, "nonce-1", body); rec.Code != http.StatusOK {
+		t.Fatalf("first request: status = %d, want %d: %s", rec.Code, http.StatusOK, rec.Body)
+	}
+	if rec := intersect(s, token, key, "nonce-1", body); rec.Code != http.StatusUnauthorized {
+		t.Fatalf("replayed request: status = %d, want %d: %s", rec.Code, http.StatusUnauthorized, rec.Body)
+	}
+	rec := intersect(s, token, key, "nonce-1", intersectBody(id, 2))
+	if rec.Code != http.StatusUnauthorized {
+		t.Fatalf("reused nonce: status = %d, want %d: %s", rec.Code, http.StatusUnauthorized, rec.Body)
+	}
+	if !strings.Contains(rec.Body.String(), auth.ErrNonceReused.Error()) {
+		t.Errorf("reused nonce: response %s does not name the reused nonce", rec.Body)
+	}
+}
+
+func TestIntersectRejectsTamperedBody(t *testing.T) {
+	s := newTestServer(t, signingOverrides)
+	id, token, key := signedSession(t, s)
+
+	req := httptest.NewRequest(http.MethodPost, "/session/intersect", strings.NewReader(intersectBody(id, 2)))
+	req.Header.Set("Authorization", "Bearer "+token)
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	req.Header.Set(auth.HeaderTimestamp, timestamp)
+	req.Header.Set(auth.HeaderNonce, "nonce-1")
+	req.Header.Set(auth.HeaderSignature, auth.SignRequest(key, req.Method, req.URL.Path, timestamp, "nonce-1", []byte(intersectBody(id, 1))))
+	rec := httptest.NewRecorder()
+	s.Handler().ServeHTTP(rec, req)
+
+	// The tampered body has more ciphertexts than the server allows, so it is
+	// only refused with 401 if the signature is checked before intersecting
+	if rec.Code != http.StatusUnauthorized {
+		t.Fatalf("status = %d, want %d: %s", rec.Code, http.StatusUnauthorized, rec.Body)
+	}
+	if !strings.Contains(rec.Body.String(), auth.ErrBadSignature.Error()) {
+		t.Errorf("response %s does not name the bad signature", rec.Body)
+	}
+	if sc, _ := s.sessions.Get(id); sc.Ciphertexts != 0 {
+		t.Errorf("tampered request recorded %d ciphertexts", sc.Ciphertexts)
+	}
+}
@@ -0,0 +1,144 @@
+package psiserver
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/SanthoshCheemala/FLARE/backend/internal/apierror"
+	"github.com/SanthoshCheemala/FLARE/backend/internal/models"
+	"github.com/go-chi/chi/v5"
+)
+
+// hashableColumns are the built-in columns sessions may enable; others
+// must be custom fields of one of the session's lists
+var hashableColumns = []string{"name", "dob", "country", "program", "imo", "registration"}
+
+// handleGetListSchema returns the custom fields of a sanction list
+func (s *Server) handleGetListSchema(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		apierror.Write(w, r, http.StatusBadRequest, apierror.CodeBadRequest, "Invalid list ID")
+		return
+	}
+	fields, err := s.repo.GetSanctionListSchema(r.Context(), id)
+	if errors.Is(err, sql.ErrNoRows) {
+		apierror.Write(w, r, http.StatusNotFound, apierror.CodeListNotFound, "List not found")
+		return
+	}
+	if err != nil {
+		apierror.Write(w, r, http.StatusInternalServerError, apierror.CodeDatabaseError, "Database error")
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"listId": id,
+		"fields": fields,
+	})
+}
+
+// handlePutListSchema replaces the custom fields of a sanction list. They
+// are read from the list's next upload.
+func (s *Server) handlePutListSchema(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		apierror.Write(w, r, http.StatusBadRequest, apierror.CodeBadRequest, "Invalid list ID")
+		return
+	}
+	var req struct {
+		Fields []models.ListField `json:"fields"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		apierror.Write(w, r, http.StatusBadRequest, apierror.CodeBadRequest, "Invalid request body")
+		return
+	}
+	if err := models.ValidateSchema(req.Fields); err != nil {
+		apierror.Write(w, r, http.StatusBadRequest, apierror.CodeBadRequest, "Invalid schema: "+err.Error())
+		return
+	}
+	err = s.repo.SetSanctionListSchema(r.Context(), id, req.Fields)
+	if errors.Is(err, sql.ErrNoRows) {
+		apierror.Write(w, r, http.StatusNotFound, apierror.CodeListNotFound, "List not found")
+		return
+	}
+	if err != nil {
+		apierror.Write(w, r, http.StatusInternalServerError, apierror.CodeDatabaseError, "Failed to save schema")
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"listId": id,
+		"fields": req.Fields,
+	})
+}
+
+// uploadSchema returns the schema an upload's records are read with: the
+// "schema" form field (a JSON array of fields) if set, else the existing
+// schema of the list being versioned. replace reports whether the form set
+// one, which the list should then keep. Like PUT schema, the upload route
+// takes the admin token.
+func (s *Server) uploadSchema(ctx context.Context, r *http.Request, listID int64) (fields []models.ListField, replace bool, err error) {
+	if raw := r.FormValue("schema"); raw != "" {
+		if err := json.Unmarshal([]byte(raw), &fields); err != nil {
+			return nil, false, fmt.Errorf("invalid schema: %w", err)
+		}
+		if err := models.ValidateSchema(fields); err != nil {
+			return nil, false, fmt.Errorf("invalid schema: %w", err)
+		}
+		return fields, true, nil
+	}
+	if listID == 0 {
+		return nil, false, nil
+	}
+	fields, err = s.repo.GetSanctionListSchema(ctx, listID)
+	if errors.Is(err, sql.ErrNoRows) {
+		// The upload reports the missing list
+		return nil, false, nil
+	}
+	return fields, false, err
+}
+
+// checkSessionColumns refuses enabled columns that are neither built in nor
+// a custom field of one of the session's lists (all lists if none are set)
+func (s *Server) checkSessionColumns(ctx context.Context, listIDs []string, columns []string) error {
+	var custom []string
+	for _, col := range columns {
+		if !slices.Contains(hashableColumns, col) {
+			custom = append(custom, col)
+		}
+	}
+	if len(custom) == 0 {
+		return nil
+	}
+
+	lists, err := s.repo.GetSanctionLists(ctx)
+	if err != nil {
+		return err
+	}
+	defined := make(map[string]bool)
+	for _, l := range lists {
+		if len(listIDs) > 0 && !slices.Contains(listIDs, strconv.FormatInt(l.ID, 10)) {
+			continue
+		}
+		for _, f := range l.Schema {
+			defined[f.Name] = true
+		}
+	}
+	var unknown []string
+	for _, col := range custom {
+		if !defined[col] {
+			unknown = append(unknown, col)
+		}
+	}
+	if len(unknown) > 0 {
+		return fmt.Errorf("unknown columns %s: not built in (%s) nor defined in the schema of the session's lists",
+			strings.Join(unknown, ", "), strings.Join(hashableColumns, ", "))
+	}
+	return nil
+}
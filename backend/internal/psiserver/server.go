@@ -0,0 +1,1236 @@
+// Package psiserver implements the sanctions authority PSI server.
+package psiserver
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"maps"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/SanthoshCheemala/FLARE/backend/internal/apierror"
+	"github.com/SanthoshCheemala/FLARE/backend/internal/atrest"
+	"github.com/SanthoshCheemala/FLARE/backend/internal/auth"
+	"github.com/SanthoshCheemala/FLARE/backend/internal/config"
+	flaremiddleware "github.com/SanthoshCheemala/FLARE/backend/internal/middleware"
+	"github.com/SanthoshCheemala/FLARE/backend/internal/models"
+	"github.com/SanthoshCheemala/FLARE/backend/internal/protocol"
+	"github.com/SanthoshCheemala/FLARE/backend/internal/profiling"
+	"github.com/SanthoshCheemala/FLARE/backend/internal/psiadapter"
+	"github.com/SanthoshCheemala/FLARE/backend/internal/rehash"
+	"github.com/SanthoshCheemala/FLARE/backend/internal/repository"
+	"github.com/SanthoshCheemala/FLARE/backend/internal/textenc"
+	"github.com/SanthoshCheemala/FLARE/backend/internal/translit"
+	"github.com/SanthoshCheemala/FLARE/backend/internal/uploadscan"
+	"github.com/SanthoshCheemala/FLARE/backend/internal/xlsx"
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+)
+
+// GlobalState is the pre-computed PSI state for the default schema.
+// It is replaced wholesale on rebuild and never mutated in place.
+type GlobalState struct {
+	// Global pre-computed state (for small datasets)
+	ServerContext *psiadapter.ServerContext
+	Params        *psiadapter.SerializedServerParams
+
+	// Batch PSI state (for large datasets)
+	BatchContext *psiadapter.BatchServerContext
+	UseBatching  bool
+
+	// Slot names the tree files this state was built into. Rebuilds use the
+	// other slot so the live trees are never touched while serving.
+	Slot string
+
+	// Transliteration is the name profile the global trees were hashed with
+	Transliteration translit.Profile
+	// Hash is the scheme the global trees were hashed with. Its salt is
+	// drawn per build, so sessions on one build share it.
+	Hash psiadapter.HashScheme
+}
+
+type Server struct {
+	router   *chi.Mux
+	adapter  *psiadapter.Adapter
+	repo     *repository.Repository
+	cfg      *config.Config
+	sessions *SessionManager
+
+	globalMu  sync.RWMutex // Protects global
+	global    GlobalState
+	rebuildMu sync.Mutex // Serializes initGlobalState runs
+	rebuild   rebuildTracker
+	// validityTimer rebuilds the global state when an entry comes into or
+	// goes out of effect. Guarded by rebuildMu.
+	validityTimer *time.Timer
+
+	resolveLimiter *rateLimiter
+	sessionTokens  *auth.SessionTokenService
+	nonces         *nonceCache
+	packs          *packHub
+	files          *atrest.Cipher // Encrypts uploads and spilled state at rest
+	uploads        *uploadscan.Checker
+	rehash         *rehash.Job // Rebuilds stored record hashes after a version change
+	released       *releases   // Noised statistics released per period
+}
+
+func NewServer(repo *repository.Repository, cfg *config.Config) *Server {
+	s := &Server{
+		router:         chi.NewRouter(),
+		adapter:        psiadapter.NewAdapter(0), // Use all cores
+		repo:           repo,
+		cfg:            cfg,
+		sessions:       NewSessionManager(),
+		resolveLimiter: newRateLimiter(cfg.PSI.ResolveRateLimit, time.Minute),
+		sessionTokens:  auth.NewSessionTokenService(cfg.JWT.SessionSecret, cfg.JWT.SessionExpiry, cfg.JWT.Issuer),
+		nonces:         newNonceCache(cfg.PSI.SignatureMaxSkew),
+		packs:          newPackHub(),
+		uploads:        uploadscan.New(cfg.Upload),
+		rehash:         rehash.New(repo),
+		released:       newReleases(),
+	}
+	files, err := atrest.New(cfg.Storage.EncryptionKey)
+	if err != nil {
+		log.Fatalf("Invalid storage encryption key: %v", err)
+	}
+	s.files = files
+	s.adapter.SetFileCipher(files)
+	s.adapter.SetResidentBatches(cfg.PSI.ResidentBatches)
+	s.adapter.SetTreeWorkers(cfg.PSI.TreeWorkers)
+	s.adapter.SetTreeDBOptions(cfg.PSI.TreeBusyTimeout, cfg.PSI.TreeReopens)
+	go s.expireIdleSessions()
+	s.rehash.StartIfStale(context.Background())
+
+	// Spilled batch contexts from a previous run belong to sessions that no
+	// longer exist. Contexts replaced by a rebuild are kept until restart
+	// because live sessions may still load from them.
+	if stale, err := filepath.Glob("./data/server_trees/global_*_ctx_*"); err == nil {
+		for _, dir := range stale {
+			os.RemoveAll(dir)
+		}
+	}
+	
+	// Initialize global state
+	if err := s.initGlobalState(); err != nil {
+		log.Printf("WARNING: Failed to initialize global PSI state: %v", err)
+	}
+	
+	s.routes()
+	return s
+}
+
+// globalState returns the current global PSI state
+func (s *Server) globalState() GlobalState {
+	s.globalMu.RLock()
+	defer s.globalMu.RUnlock()
+	return s.global
+}
+
+func (s *Server) setGlobalState(g GlobalState) {
+	s.globalMu.Lock()
+	s.global = g
+	s.globalMu.Unlock()
+}
+
+// initGlobalState builds a new global PSI state into the standby slot while
+// the current state keeps serving, then swaps it in
+func (s *Server) initGlobalState() error {
+	s.rebuild.queue()
+	s.rebuildMu.Lock()
+	defer s.rebuildMu.Unlock()
+
+	slot := standbySlot(s.globalState().Slot)
+	s.rebuild.start(slot)
+	err := s.buildGlobalState(slot)
+	s.rebuild.finish(err)
+	return err
+}
+
+func (s *Server) buildGlobalState(slot string) error {
+	log.Printf("Initializing global PSI state in slot %s...", slot)
+	ctx := context.Background()
+	
+	// Load all active sanction lists
+	lists, err := s.repo.GetSanctionLists(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get sanction lists: %w", err)
+	}
+	
+	var listIDs []string
+	for _, l := range lists {
+		if l.Active {
+			listIDs = append(listIDs, fmt.Sprintf("%d", l.ID))
+		}
+	}
+	
+	if len(listIDs) == 0 {
+		log.Println("No active sanction lists found. Skipping PSI init.")
+		s.scheduleValidityRebuild(time.Time{})
+		s.setGlobalState(GlobalState{Slot: slot})
+		return nil
+	}
+	
+	// The profile was checked by config validation
+	names, _ := translit.Parse(s.cfg.PSI.Transliteration)
+	sanctionData, next, err := s.loadSanctionData(ctx, listIDs, nil, names, false, time.Now()) // nil for default schema
+	if err != nil {
+		return fmt.Errorf("failed to load sanction data: %w", err)
+	}
+	s.scheduleValidityRebuild(next)
+	scheme, err := psiadapter.NewHashScheme(s.cfg.PSI.HashAlgorithm)
+	if err != nil {
+		return err
+	}
+	
+	log.Printf("Loaded %d sanction records for global state", len(sanctionData))
+	s.rebuild.setRecords(len(sanctionData))
+	
+	// Initialize PSI Server Context
+	treeDir := "./data/server_trees"
+	os.MkdirAll(treeDir, 0755)
+	
+	// Clear whatever an earlier build left in the standby slot. The live
+	// state uses the other slot and is unaffected.
+	treePath := filepath.Join(treeDir, "global_"+slot)
+	if stale, err := filepath.Glob(treePath + "*"); err == nil {
+		for _, path := range stale {
+			os.RemoveAll(path)
+		}
+	}
+
+	// Check if we should use batching based on dataset size and RAM
+	if s.adapter.ShouldUseBatching(len(sanctionData)) {
+		optimalBatch := s.adapter.CalculateOptimalBatchSize()
+		numBatches := (len(sanctionData) + optimalBatch - 1) / optimalBatch
+		log.Printf("🔄 BATCH PSI ACTIVATED: %d records → %d batches of %d (based on available RAM)",
+			len(sanctionData), numBatches, optimalBatch)
+
+		s.rebuild.setBatches(0, numBatches)
+		batchCtx, err := s.adapter.InitServerBatched(ctx, sanctionData, treePath, scheme, func(done, total int) {
+			log.Printf("   Built batch tree %d/%d", done, total)
+			s.rebuild.setBatches(done, total)
+		})
+		if err != nil {
+			return fmt.Errorf("InitServerBatched failed: %w", err)
+		}
+
+		// Each batch has its own tree root, so clients need every batch's
+		// params. Batch contexts are loaded on demand, so no ServerContext
+		// is pinned here.
+		s.setGlobalState(GlobalState{
+			Params:       batchCtx.Params[0],
+			BatchContext: batchCtx,
+			UseBatching:  true,
+			Slot:         slot,
+
+			Transliteration: names,
+			Hash:            scheme,
+		})
+		log.Printf("✓ Global Batch PSI state initialized: %d batches (%d resident)", batchCtx.Len(), batchCtx.Resident())
+	} else {
+		// Standard PSI for small datasets
+		log.Printf("⚡ Standard PSI: %d records (within RAM limits)", len(sanctionData))
+		s.rebuild.setBatches(0, 1)
+		
+		serverCtx, err := s.adapter.InitServer(ctx, sanctionData, treePath+".db", scheme)
+		if err != nil {
+			return fmt.Errorf("InitServer failed: %w", err)
+		}
+
+		serializedParams, err := s.adapter.SerializeParams(serverCtx)
+		if err != nil {
+			return fmt.Errorf("failed to serialize params: %w", err)
+		}
+		
+		s.setGlobalState(GlobalState{
+			ServerContext: serverCtx,
+			Params:        serializedParams,
+			Slot:          slot,
+
+			Transliteration: names,
+			Hash:            scheme,
+		})
+		s.rebuild.setBatches(1, 1)
+	}
+	
+	log.Println("Global PSI state initialized successfully")
+	return nil
+}
+
+// Handler returns the server's HTTP handler
+func (s *Server) Handler() http.Handler {
+	return s.router
+}
+
+// SetResolveRateLimit changes the per-client resolve limit at runtime
+func (s *Server) SetResolveRateLimit(limit int) {
+	s.resolveLimiter.SetLimit(limit)
+}
+
+// bodyLimits returns the request body limits of the server's routes.
+// Intersect requests may be as large as PSI_MAX_CIPHERTEXTS_PER_REQUEST
+// ciphertexts make them; clients split larger batches (see
+// handleCapabilities).
+func (s *Server) bodyLimits() flaremiddleware.BodyLimits {
+	limits := flaremiddleware.BodyLimits{
+		Default: int64(s.cfg.Server.MaxBodyKB) << 10,
+		Routes: map[string]int64{
+			"/session/intersect":      psiadapter.IntersectBodyBytes(s.cfg.PSI.MaxCiphertextsPerRequest),
+			"/lists/sanctions/upload": int64(s.cfg.Upload.MaxMB)<<20 + 1<<20,
+		},
+	}
+	maps.Copy(limits.Routes, s.cfg.Server.BodyLimitRoutes())
+	return limits
+}
+
+func (s *Server) routes() {
+	s.router.Use(middleware.RequestID)
+	s.router.Use(middleware.Logger)
+	s.router.Use(middleware.Recoverer)
+	s.router.Use(s.corsMiddleware)
+	s.router.Use(flaremiddleware.BodyLimit(s.bodyLimits()))
+	if s.cfg.Server.Compression {
+		s.router.Use(flaremiddleware.Compress(s.cfg.Server.CompressionLevel))
+	}
+
+	s.router.Get("/health", s.handleHealth)
+	s.router.With(s.adminAuth).Get("/dashboard/stats", s.handleGetStats)
+
+	s.router.Group(func(r chi.Router) {
+		r.Use(s.requireAPIKey)
+		r.Get("/capabilities", s.handleCapabilities)
+		r.Post("/session/init", s.handleInitSession)
+		r.Post("/session/intersect", s.handleIntersect)
+		r.Post("/session/{sessionID}/resolve", s.handleResolveSanctions)
+		r.Get("/session/{sessionID}/ping", s.handlePing)
+		r.Delete("/session/{sessionID}", s.handleDeleteSession)
+
+		r.Get("/packs", s.handleListPacks)
+		r.Get("/packs/{id}", s.handleGetPack)
+		r.Get("/packs/{id}/updates", s.handlePackUpdates)
+	})
+
+	s.router.Route("/admin", s.adminRoutes)
+	s.router.With(s.adminAuth).Route("/debug/pprof", profiling.Register)
+
+	s.router.Get("/lists/sanctions", s.handleGetSanctions)
+	s.router.With(s.adminAuth).Post("/lists/sanctions/upload", s.handleUploadSanctions)
+	s.router.With(s.adminAuth).Delete("/lists/sanctions/{id}", s.handleDeleteSanctionList)
+	s.router.With(s.adminAuth).Patch("/lists/sanctions/{id}", s.handlePatchSanctionList)
+	s.router.Get("/lists/sanctions/{id}/schema", s.handleGetListSchema)
+	s.router.With(s.adminAuth).Put("/lists/sanctions/{id}/schema", s.handlePutListSchema)
+}
+
+func (s *Server) handleHealth(w http.ResponseWriter, r *http.Request) {
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte("FLARE Server (Sanctions Authority) is running"))
+}
+
+var (
+	errSessionNotFound = errors.New("session not found")
+	errTokenRevoked    = errors.New("session token revoked")
+)
+
+// registerSession stores the session and issues its access token, bound to
+// the requesting host, and its request signing key (set on sc)
+func (s *Server) registerSession(r *http.Request, sessionID string, sc *SessionContext) (string, time.Time, error) {
+	token, tokenID, expiresAt, err := s.sessionTokens.Generate(sessionID, clientKey(r))
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	if sc.SigningKey, err = auth.GenerateSigningKey(); err != nil {
+		return "", time.Time{}, err
+	}
+	sc.TokenID = tokenID
+	sc.APIKeyID = apiKeyID(r.Context())
+	sc.Client = clientKey(r)
+	s.sessions.Add(sessionID, sc)
+
+	return token, expiresAt, nil
+}
+
+// authorizeSession validates the bearer token on r against sessionID and
+// returns a snapshot of the session it grants access to
+func (s *Server) authorizeSession(r *http.Request, sessionID string) (*SessionContext, error) {
+	sc, ok := s.sessions.Get(sessionID)
+	if !ok {
+		return nil, errSessionNotFound
+	}
+
+	authHeader := r.Header.Get("Authorization")
+	if !strings.HasPrefix(authHeader, "Bearer ") {
+		return nil, auth.ErrInvalidToken
+	}
+
+	claims, err := s.sessionTokens.Validate(strings.TrimPrefix(authHeader, "Bearer "), sessionID, clientKey(r))
+	if err != nil {
+		return nil, err
+	}
+	if sc.TokenID == "" || claims.ID != sc.TokenID {
+		return nil, errTokenRevoked
+	}
+	s.sessions.Touch(sessionID)
+	return &sc, nil
+}
+
+// writeSessionAuthError maps authorizeSession failures to HTTP responses
+func writeSessionAuthError(w http.ResponseWriter, r *http.Request, err error) {
+	if errors.Is(err, errSessionNotFound) {
+		apierror.Write(w, r, http.StatusNotFound, apierror.CodeSessionNotFound, "Session not found or expired")
+		return
+	}
+	apierror.Write(w, r, http.StatusUnauthorized, apierror.CodeUnauthorized, "Invalid session token: "+err.Error())
+}
+
+func (s *Server) handleInitSession(w http.ResponseWriter, r *http.Request) {
+	var req protocol.InitSessionRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		if apierror.TooLarge(w, r, err) {
+			return
+		}
+		log.Printf("Warning: failed to decode init session request: %v", err)
+	}
+	if !s.checkSessionQuota(w, r) {
+		return
+	}
+	listIDs, err := s.sessionListIDs(r.Context(), req)
+	if err != nil {
+		apierror.Write(w, r, http.StatusBadRequest, apierror.CodeBadRequest, err.Error())
+		return
+	}
+	req.SanctionListIDs = listIDs
+	names, err := req.Transliteration.Check()
+	if err != nil {
+		apierror.Write(w, r, http.StatusBadRequest, apierror.CodeBadRequest, err.Error())
+		return
+	}
+	offered := req.HashAlgorithms
+	if len(offered) == 0 {
+		offered = []string{psiadapter.HashSHA256}
+	}
+	if !slices.Contains(offered, s.cfg.PSI.HashAlgorithm) {
+		apierror.Write(w, r, http.StatusBadRequest, apierror.CodeBadRequest,
+			fmt.Sprintf("Server requires hash algorithm %q; client offered %s", s.cfg.PSI.HashAlgorithm, strings.Join(offered, ", ")))
+		return
+	}
+
+	// Determine effective columns. Default to standard set if empty.
+	columns := req.EnabledColumns
+	if len(columns) == 0 {
+		columns = slices.Clone(defaultColumns)
+	}
+	if err := s.checkSessionColumns(r.Context(), listIDs, columns); err != nil {
+		apierror.Write(w, r, http.StatusBadRequest, apierror.CodeBadRequest, err.Error())
+		return
+	}
+	
+	// Check if this matches global state (default)
+	isDefaultSchema := len(columns) == 3 && 
+		columns[0] == "name" && columns[1] == "dob" && columns[2] == "country"
+
+	// If default schema and global state is ready, use it (optimization).
+	// The global trees hold no document numbers.
+	global := s.globalState()
+	if isDefaultSchema && !req.Documents && global.Params != nil && global.Transliteration.String() == names.String() {
+		sessionID := fmt.Sprintf("session_global_%d", time.Now().UnixNano())
+		sc := &SessionContext{
+			ServerContext:   global.ServerContext,
+			ListIDs:         req.SanctionListIDs,
+			EnabledColumns:  columns,
+			Transliteration: names,
+			Hash:            global.Hash,
+		}
+		resp := protocol.InitSessionResponse{
+			SessionID:       sessionID,
+			Params:          global.Params,
+			Transliteration: names,
+			Hash:            global.Hash,
+		}
+		if global.UseBatching {
+			// Batches rebuilt after failing validation have new params
+			sc.BatchContext = global.BatchContext
+			resp.BatchParams = global.BatchContext.ParamsSnapshot()
+			resp.Params = resp.BatchParams[0]
+		}
+		token, expiresAt, err := s.registerSession(r, sessionID, sc)
+		if err != nil {
+			s.recordError(r, sessionID, "init: failed to issue session token")
+			apierror.Write(w, r, http.StatusInternalServerError, apierror.CodeInternal, "Failed to issue session token")
+			return
+		}
+		resp.Token = token
+		resp.ExpiresAt = expiresAt
+		resp.SigningKey = sc.SigningKey
+		resp.IdleTimeoutSeconds = s.idleTimeoutSeconds()
+		s.recordEvent(r, models.SessionEvent{
+			SessionID: sessionID,
+			Event:     models.SessionEventInit,
+			Batch:     max(1, len(resp.BatchParams)),
+			Detail:    fmt.Sprintf("global slot %s, lists %v", global.Slot, req.SanctionListIDs),
+		})
+		
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(resp)
+		return
+	}
+
+	// Dynamic Schema: We must re-compute the tree
+	log.Printf("Initializing dynamic PSI session with columns: %v", columns)
+	
+	// Load requested lists (or all active ones if none specified)
+	if len(listIDs) == 0 {
+		lists, _ := s.repo.GetSanctionLists(r.Context())
+		for _, l := range lists {
+			if l.Active {
+				listIDs = append(listIDs, fmt.Sprintf("%d", l.ID))
+			}
+		}
+	}
+	
+	// Load and Hash Data dynamically
+	initStart := time.Now()
+	sanctionData, _, err := s.loadSanctionData(r.Context(), listIDs, columns, names, req.Documents, initStart)
+	if err != nil {
+		s.recordError(r, "", "init: failed to load sanction data: "+err.Error())
+		apierror.Write(w, r, http.StatusInternalServerError, apierror.CodePSIFailed, "Failed to load sanction data: "+err.Error())
+		return
+	}
+	
+	// Init Server Context (Dynamic Tree)
+	// We use a temporary path for dynamic trees
+	treeDir := fmt.Sprintf("./data/server_trees/dynamic_%d", time.Now().UnixNano())
+	os.MkdirAll(treeDir, 0700)
+	// The session owns the tree once registered and removes it when it ends
+	registered := false
+	defer func() {
+		if !registered {
+			os.RemoveAll(treeDir)
+		}
+	}()
+
+	treePath := filepath.Join(treeDir, "tree.db")
+	scheme, err := psiadapter.NewHashScheme(s.cfg.PSI.HashAlgorithm)
+	if err != nil {
+		s.recordError(r, "", "init: "+err.Error())
+		apierror.Write(w, r, http.StatusInternalServerError, apierror.CodeInternal, "Failed to set up session hash")
+		return
+	}
+	serverCtx, err := s.adapter.InitServer(r.Context(), sanctionData, treePath, scheme)
+	if err != nil {
+		s.recordError(r, "", "init: InitServer failed: "+err.Error())
+		apierror.Write(w, r, http.StatusInternalServerError, apierror.CodePSIFailed, "InitServer failed: "+err.Error())
+		return
+	}
+
+	serializedParams, err := s.adapter.SerializeParams(serverCtx)
+	if err != nil {
+		s.recordError(r, "", "init: SerializeParams failed: "+err.Error())
+		apierror.Write(w, r, http.StatusInternalServerError, apierror.CodePSIFailed, "SerializeParams failed: "+err.Error())
+		return
+	}
+	
+	sessionID := fmt.Sprintf("session_dyn_%d", time.Now().UnixNano())
+	sc := &SessionContext{
+		ServerContext:   serverCtx,
+		ListIDs:         listIDs,
+		EnabledColumns:  columns,
+		Transliteration: names,
+		Documents:       req.Documents,
+		Hash:            scheme,
+		TreeDir:         treeDir,
+	}
+	token, expiresAt, err := s.registerSession(r, sessionID, sc)
+	if err != nil {
+		s.recordError(r, sessionID, "init: failed to issue session token")
+		apierror.Write(w, r, http.StatusInternalServerError, apierror.CodeInternal, "Failed to issue session token")
+		return
+	}
+	registered = true
+	s.recordEvent(r, models.SessionEvent{
+		SessionID:  sessionID,
+		Event:      models.SessionEventInit,
+		Batch:      1,
+		DurationMs: time.Since(initStart).Milliseconds(),
+		Detail:     fmt.Sprintf("dynamic columns %v, documents %v, lists %v, %d records", columns, req.Documents, listIDs, len(sanctionData)),
+	})
+	
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(protocol.InitSessionResponse{
+		SessionID: sessionID,
+		Params:     serializedParams,
+		Token:      token,
+		ExpiresAt:  expiresAt,
+		SigningKey: sc.SigningKey,
+
+		Transliteration: names,
+		Documents:       req.Documents,
+		Hash:            scheme,
+
+		IdleTimeoutSeconds: s.idleTimeoutSeconds(),
+	})
+}
+
+// handleGetSanctions returns a page of sanction lists. Supported query
+// params: limit, offset, sort (prefix with - for descending), q, source,
+// category, created_after and created_before (RFC 3339 or YYYY-MM-DD).
+func (s *Server) handleGetSanctions(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	filter := repository.SanctionListFilter{
+		Query:  strings.TrimSpace(query.Get("q")),
+		Source: query.Get("source"),
+		Sort:   "created_at",
+		Desc:   true,
+		Limit:  50,
+	}
+
+	if c := query.Get("category"); c != "" {
+		category, ok := models.ParseListCategory(c)
+		if !ok {
+			apierror.Write(w, r, http.StatusBadRequest, apierror.CodeBadRequest, "Invalid category")
+			return
+		}
+		filter.Category = category
+	}
+	if l := query.Get("limit"); l != "" {
+		limit, err := strconv.Atoi(l)
+		if err != nil || limit <= 0 {
+			apierror.Write(w, r, http.StatusBadRequest, apierror.CodeBadRequest, "Invalid limit")
+			return
+		}
+		filter.Limit = min(limit, 500)
+	}
+	if o := query.Get("offset"); o != "" {
+		offset, err := strconv.Atoi(o)
+		if err != nil || offset < 0 {
+			apierror.Write(w, r, http.StatusBadRequest, apierror.CodeBadRequest, "Invalid offset")
+			return
+		}
+		filter.Offset = offset
+	}
+	if sort := query.Get("sort"); sort != "" {
+		filter.Desc = strings.HasPrefix(sort, "-")
+		filter.Sort = strings.TrimPrefix(sort, "-")
+	}
+
+	var err error
+	if filter.CreatedAfter, err = parseDateParam(query.Get("created_after")); err != nil {
+		apierror.Write(w, r, http.StatusBadRequest, apierror.CodeBadRequest, "Invalid created_after")
+		return
+	}
+	if filter.CreatedBefore, err = parseDateParam(query.Get("created_before")); err != nil {
+		apierror.Write(w, r, http.StatusBadRequest, apierror.CodeBadRequest, "Invalid created_before")
+		return
+	}
+
+	lists, total, err := s.repo.ListSanctionLists(r.Context(), filter)
+	if err != nil {
+		apierror.Write(w, r, http.StatusInternalServerError, apierror.CodeDatabaseError, "Database error")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"lists":  lists,
+		"total":  total,
+		"limit":  filter.Limit,
+		"offset": filter.Offset,
+	})
+}
+
+// parseDateParam parses an optional RFC 3339 timestamp or YYYY-MM-DD date
+func parseDateParam(v string) (time.Time, error) {
+	if v == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.Parse(time.RFC3339, v); err == nil {
+		return t, nil
+	}
+	return time.Parse("2006-01-02", v)
+}
+
+func (s *Server) handleUploadSanctions(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseMultipartForm(10 << 20); err != nil {
+		if apierror.TooLarge(w, r, err) {
+			return
+		}
+		apierror.Write(w, r, http.StatusBadRequest, apierror.CodeBadRequest, "File too large")
+		return
+	}
+
+	file, header, err := r.FormFile("file")
+	if err != nil {
+		apierror.Write(w, r, http.StatusBadRequest, apierror.CodeBadRequest, "Missing file")
+		return
+	}
+	defer file.Close()
+	content, err := s.uploads.Check(r.Context(), header.Filename, file)
+	if err != nil {
+		log.Printf("Refused sanctions upload %s: %v", header.Filename, err)
+		uploadscan.WriteError(w, r, err)
+		return
+	}
+	if xlsx.IsWorkbook(header.Filename, content) {
+		if content, err = xlsx.ToCSV(content, r.FormValue("sheet")); err != nil {
+			apierror.Write(w, r, http.StatusBadRequest, apierror.CodeBadRequest, fmt.Sprintf("Invalid spreadsheet: %v", err))
+			return
+		}
+	}
+	content, quality := textenc.ToUTF8(content)
+	if len(quality.Warnings) > 0 {
+		log.Printf("Warning: sanctions upload %s read as %s", header.Filename, quality.Encoding)
+	}
+
+	name := r.FormValue("name")
+	source := r.FormValue("source")
+	description := r.FormValue("description")
+	category, ok := models.ParseListCategory(r.FormValue("category"))
+	if !ok {
+		apierror.Write(w, r, http.StatusBadRequest, apierror.CodeBadRequest,
+			fmt.Sprintf("Invalid category %q (one of %s)", r.FormValue("category"), strings.Join(models.ListCategories, ", ")))
+		return
+	}
+	if name == "" {
+		name = fmt.Sprintf("Sanctions %s", time.Now().Format("2006-01-02"))
+	}
+	// active=false stages the list: it is stored but not screened against
+	// until activated
+	var active *bool
+	if v := r.FormValue("active"); v != "" {
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			apierror.Write(w, r, http.StatusBadRequest, apierror.CodeBadRequest, fmt.Sprintf("Invalid active flag %q", v))
+			return
+		}
+		active = &b
+	}
+	// Custom fields are read with the schema sent along, else the list's own
+	versionOf, _ := strconv.ParseInt(r.FormValue("list_id"), 10, 64)
+	schema, replaceSchema, err := s.uploadSchema(r.Context(), r, versionOf)
+	if err != nil {
+		apierror.Write(w, r, http.StatusBadRequest, apierror.CodeBadRequest, err.Error())
+		return
+	}
+
+	uploadDir := "./data/server_uploads"
+	if err := os.MkdirAll(uploadDir, 0700); err != nil {
+		apierror.Write(w, r, http.StatusInternalServerError, apierror.CodeInternal, "Failed to create upload directory")
+		return
+	}
+
+	fileName := fmt.Sprintf("sanctions_%d.csv", time.Now().UnixNano())
+	finalPath := fmt.Sprintf("%s/%s", uploadDir, fileName)
+
+	// Write file
+	if err := s.files.WriteFile(finalPath, bytes.NewReader(content), 0600); err != nil {
+		apierror.Write(w, r, http.StatusInternalServerError, apierror.CodeInternal, "Failed to write file")
+		return
+	}
+	
+	absPath, _ := filepath.Abs(finalPath)
+
+	// A list_id uploads a new version of an existing list, replacing its records
+	var listID int64
+	version := 1
+	if idStr := r.FormValue("list_id"); idStr != "" {
+		listID, err = strconv.ParseInt(idStr, 10, 64)
+		if err != nil {
+			apierror.Write(w, r, http.StatusBadRequest, apierror.CodeBadRequest, "Invalid list ID")
+			return
+		}
+		version, err = s.repo.ReplaceSanctionListVersion(r.Context(), listID, absPath)
+		if errors.Is(err, sql.ErrNoRows) {
+			apierror.Write(w, r, http.StatusNotFound, apierror.CodeListNotFound, "List not found")
+			return
+		}
+		if err != nil {
+			apierror.Write(w, r, http.StatusInternalServerError, apierror.CodeDatabaseError, fmt.Sprintf("Failed to version list: %v", err))
+			return
+		}
+	} else {
+		listID, err = s.repo.CreateSanctionList(r.Context(), name, source, category, description, absPath)
+		if err != nil {
+			apierror.Write(w, r, http.StatusInternalServerError, apierror.CodeDatabaseError, fmt.Sprintf("Failed to create list: %v", err))
+			return
+		}
+	}
+	if replaceSchema {
+		if err := s.repo.SetSanctionListSchema(r.Context(), listID, schema); err != nil {
+			apierror.Write(w, r, http.StatusInternalServerError, apierror.CodeDatabaseError, fmt.Sprintf("Failed to save schema: %v", err))
+			return
+		}
+	}
+	if active != nil {
+		if err := s.repo.SetSanctionListActive(r.Context(), listID, *active); err != nil {
+			apierror.Write(w, r, http.StatusInternalServerError, apierror.CodeDatabaseError, fmt.Sprintf("Failed to set active flag: %v", err))
+			return
+		}
+	}
+
+	// Parse CSV and insert records
+	readFile, err := s.files.OpenFile(finalPath)
+	if err != nil {
+		log.Printf("Failed to open saved file: %v", err)
+	} else {
+		defer readFile.Close()
+		reader := csv.NewReader(readFile)
+		headers, err := reader.Read()
+		if err == nil {
+			log.Printf("CSV Headers found: %v", headers)
+			headerMap := make(map[string]int)
+			for i, h := range headers {
+				headerMap[strings.ToLower(strings.TrimSpace(h))] = i
+			}
+			
+			getValue := func(record []string, colName string) string {
+				if idx, ok := headerMap[colName]; ok && idx < len(record) {
+					return record[idx]
+				}
+				return ""
+			}
+
+			// Dates of birth are read in the order the whole column follows
+			prefer, _ := psiadapter.ParseDateOrder(s.cfg.PSI.DateOrder)
+			var sanctions []*models.Sanction
+			for {
+				record, err := reader.Read()
+				if err == io.EOF {
+					break
+				}
+				if err != nil {
+					continue
+				}
+
+				name := getValue(record, "name")
+				dob := getValue(record, "dob")
+				country := getValue(record, "country")
+				program := getValue(record, "sanction_program")
+				if program == "" {
+					program = getValue(record, "program")
+				}
+				entityType, ok := models.ParseEntityType(firstValue(record, getValue, "entity_type", "type"))
+				if !ok {
+					log.Printf("Warning: skipping %q: unknown entity type %q", name, firstValue(record, getValue, "entity_type", "type"))
+					continue
+				}
+				validFrom, validTo, err := parseValidity(firstValue(record, getValue, "valid_from", "effective_date"),
+					firstValue(record, getValue, "valid_to", "expiry_date"))
+				if err != nil {
+					log.Printf("Warning: skipping %q: %v", name, err)
+					continue
+				}
+
+				if name != "" {
+					sanction := &models.Sanction{
+						Name:         name,
+						DOB:          dob,
+						Country:      country,
+						Program:      program,
+						Source:       source,
+						ListID:       listID,
+						EntityType:   entityType,
+						Aliases:      models.ParseAliases(getValue(record, "aliases")),
+						IMONumber:    firstValue(record, getValue, "imo_number", "imo"),
+						Registration: firstValue(record, getValue, "registration", "registration_number", "tail_number"),
+						ValidFrom:    validFrom,
+						ValidTo:      validTo,
+						SourceRef:    firstValue(record, getValue, "source_ref", "source_url"),
+						Attributes: psiadapter.SchemaAttributes(schema, func(header string) string {
+							return getValue(record, strings.ToLower(strings.TrimSpace(header)))
+						}, prefer),
+					}
+					sanctions = append(sanctions, sanction)
+				}
+			}
+
+			psiadapter.NormalizeSanctionDOBs(sanctions, prefer)
+			for _, sanction := range sanctions {
+				sanction.Hash = psiadapter.RecordHash(sanction.EntityType, sanction.HashValues())
+				sanction.HashVersion = psiadapter.RecordHashVersion
+			}
+
+			count := 0
+			if err := s.repo.CreateSanctions(r.Context(), sanctions); err != nil {
+				log.Printf("Failed to import sanctions for list %d: %v", listID, err)
+			} else {
+				count = len(sanctions)
+			}
+
+			// Update record count in database
+			if err := s.repo.UpdateSanctionListCount(r.Context(), listID, count); err != nil {
+				log.Printf("Failed to update list count: %v", err)
+			}
+			log.Printf("Imported %d sanctions for list %d", count, listID)
+		} else {
+			log.Printf("Failed to read CSV headers: %v", err)
+		}
+	}
+
+	// A new version changes records already in the global state
+	if version > 1 {
+		s.listChanged(r.Context(), listID)
+		go func() {
+			if err := s.initGlobalState(); err != nil {
+				log.Printf("Failed to re-initialize global state after list update: %v", err)
+			}
+		}()
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"id":      listID,
+		"version": version,
+		"quality": quality,
+	})
+}
+
+func (s *Server) handleDeleteSanctionList(w http.ResponseWriter, r *http.Request) {
+	idStr := chi.URLParam(r, "id")
+	id, err := strconv.ParseInt(idStr, 10, 64)
+	if err != nil {
+		apierror.Write(w, r, http.StatusBadRequest, apierror.CodeBadRequest, "Invalid list ID")
+		return
+	}
+
+	// Deleting the list drops it from its packs, so find them first
+	packIDs, err := s.repo.BumpPacksForList(r.Context(), id)
+	if err != nil {
+		log.Printf("Warning: failed to update packs for list %d: %v", id, err)
+	}
+	if err := s.repo.DeleteSanctionList(r.Context(), id); err != nil {
+		log.Printf("Failed to delete sanction list: %v", err)
+		apierror.Write(w, r, http.StatusInternalServerError, apierror.CodeDatabaseError, "Failed to delete sanction list")
+		return
+	}
+	s.notifyPacks(r.Context(), packIDs)
+
+	// Re-initialize global state to reflect changes
+	// In a real system, we might want to do this more gracefully or lazily
+	go func() {
+		if err := s.initGlobalState(); err != nil {
+			log.Printf("Failed to re-initialize global state after deletion: %v", err)
+		}
+	}()
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]bool{"success": true})
+}
+
+// handlePatchSanctionList activates or deactivates a sanction list. An
+// inactive list keeps its records but is left out of the global state and
+// of sessions, so a list can be uploaded inactive, checked and then
+// published.
+func (s *Server) handlePatchSanctionList(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		apierror.Write(w, r, http.StatusBadRequest, apierror.CodeBadRequest, "Invalid list ID")
+		return
+	}
+	var req struct {
+		Active *bool `json:"active"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Active == nil {
+		apierror.Write(w, r, http.StatusBadRequest, apierror.CodeBadRequest, "Request body must set active")
+		return
+	}
+	err = s.repo.SetSanctionListActive(r.Context(), id, *req.Active)
+	if errors.Is(err, sql.ErrNoRows) {
+		apierror.Write(w, r, http.StatusNotFound, apierror.CodeListNotFound, "List not found")
+		return
+	}
+	if err != nil {
+		log.Printf("Failed to set sanction list %d active=%v: %v", id, *req.Active, err)
+		apierror.Write(w, r, http.StatusInternalServerError, apierror.CodeDatabaseError, "Failed to update sanction list")
+		return
+	}
+	log.Printf("Sanction list %d set active=%v", id, *req.Active)
+
+	// Packs holding the list now screen against more or fewer records
+	s.listChanged(r.Context(), id)
+	go func() {
+		if err := s.initGlobalState(); err != nil {
+			log.Printf("Failed to re-initialize global state after list activation change: %v", err)
+		}
+	}()
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"id":     id,
+		"active": *req.Active,
+	})
+}
+
+// loadSanctionData returns the set elements of the lists' sanctions in
+// effect at at under a schema, including their document numbers when
+// documents is set. next is the earliest time after at that one of the
+// lists' entries comes into or goes out of effect, zero if none does.
+func (s *Server) loadSanctionData(ctx context.Context, listIDs []string, columns []string, names translit.Profile, documents bool, at time.Time) (data []string, next time.Time, err error) {
+	var ids []int64
+	for _, idStr := range listIDs {
+		var id int64
+		fmt.Sscanf(idStr, "%d", &id)
+		ids = append(ids, id)
+	}
+	
+	var allStrings []string
+	
+	// Load sanctions directly from database
+	sanctions, err := s.repo.GetSanctionsByListIDs(ctx, ids)
+	if err != nil {
+		return nil, time.Time{}, fmt.Errorf("failed to load sanctions: %w", err)
+	}
+	
+	if len(columns) == 0 {
+		columns = []string{"name", "dob", "country"}
+	}
+	
+	for _, sanction := range sanctions {
+		if !sanction.InEffect(at) {
+			continue
+		}
+		allStrings = append(allStrings, psiadapter.SanctionHashInputs(&sanction, columns, names)...)
+		if documents {
+			allStrings = append(allStrings, psiadapter.DocumentHashInputs(sanction.Attributes, sanction.Country)...)
+		}
+	}
+	
+	// Records and their hashes are not logged: hashes depend on the
+	// session's keyed scheme, and either would leak the list
+	if len(allStrings) > 0 {
+		log.Printf("[DEBUG] Server loaded %d sanction records with schema %v", len(allStrings), columns)
+	}
+	return allStrings, nextValidityChange(sanctions, at), nil
+}
+
+func (s *Server) handleGetStats(w http.ResponseWriter, r *http.Request) {
+	// Server-specific stats
+	lists, _ := s.repo.GetSanctionLists(r.Context())
+	
+	totalEntities, activeLists := 0, 0
+	for _, list := range lists {
+		totalEntities += list.RecordCount
+		if list.Active {
+			activeLists++
+		}
+	}
+	
+	// Screenings and matches are the banks' behaviour; noise them once a
+	// day when the stats are meant to be shared
+	noise := s.newLaplaceNoise()
+	var totalScreenings, totalMatches int
+	var err error
+	if noise.epsilon > 0 {
+		totalScreenings, totalMatches, err = s.released.dailyTotals(r.Context(), s.repo, noise)
+	} else {
+		totalScreenings, totalMatches, err = s.repo.GetSessionTotals(r.Context(), 0)
+	}
+	if err != nil {
+		log.Printf("Warning: failed to total session events: %v", err)
+	}
+
+	stats := map[string]interface{}{
+		"totalScreenings": totalScreenings,
+		"totalMatches":    totalMatches,
+		"activeLists":     activeLists,
+		"totalEntities":   totalEntities,
+		"recentScreenings": []interface{}{},
+		"systemStatus":    "OPERATIONAL",
+		"activeWorkers":   8,
+		"activeSessions":  s.sessions.Len(),
+		"libraryErrors":   psiadapter.LibraryErrors(),
+	}
+	if noise.epsilon > 0 {
+		stats["noise"] = noise.describe()
+	}
+	
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(stats)
+}
+
+// handleResolveSanctions returns full sanction details for matched hashes
+func (s *Server) handleResolveSanctions(w http.ResponseWriter, r *http.Request) {
+	sessionID := chi.URLParam(r, "sessionID")
+	if sessionID == "" {
+		apierror.Write(w, r, http.StatusBadRequest, apierror.CodeBadRequest, "Missing sessionID")
+		return
+	}
+
+	if !s.resolveLimiter.Allow(clientKey(r)) {
+		log.Printf("Resolve rate limit exceeded for %s (session %s)", clientKey(r), sessionID)
+		s.recordError(r, sessionID, "resolve: rate limited")
+		apierror.Write(w, r, http.StatusTooManyRequests, apierror.CodeRateLimited, "Too many resolve requests")
+		return
+	}
+
+	var req protocol.ResolveRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		if apierror.TooLarge(w, r, err) {
+			return
+		}
+		apierror.Write(w, r, http.StatusBadRequest, apierror.CodeBadRequest, "Invalid request body")
+		return
+	}
+
+	// Get the session to find which sanction lists were used
+	serverCtx, err := s.authorizeSession(r, sessionID)
+	if err != nil {
+		s.recordError(r, sessionID, "resolve: "+err.Error())
+		writeSessionAuthError(w, r, err)
+		return
+	}
+	if !s.checkResolveMinimum(w, r, sessionID, *serverCtx) {
+		return
+	}
+
+	var unmatched int
+	for _, hash := range req.Hashes {
+		if !serverCtx.Matches[hash] {
+			unmatched++
+		}
+	}
+
+	// Reject hashes outside the session's match set; otherwise a caller could
+	// enumerate the sanction list by guessing hashes
+	if unmatched > 0 {
+		log.Printf("Rejected resolve for session %s: %d of %d hashes not in match set", sessionID, unmatched, len(req.Hashes))
+		s.recordError(r, sessionID, fmt.Sprintf("resolve: %d of %d hashes not in match set", unmatched, len(req.Hashes)))
+		apierror.Write(w, r, http.StatusForbidden, apierror.CodeForbidden, "Requested hashes were not matched in this session")
+		return
+	}
+
+	// Load all sanctions from the lists used in this session
+	listIDs := make([]int64, len(serverCtx.ListIDs))
+	for i, idStr := range serverCtx.ListIDs {
+		id, _ := strconv.ParseInt(idStr, 10, 64)
+		listIDs[i] = id
+	}
+	log.Printf("[DEBUG] Resolving for session %s with ListIDs: %v", sessionID, listIDs)
+
+	sanctions, err := s.repo.GetSanctionsByListIDs(r.Context(), listIDs)
+	if err != nil {
+		log.Printf("Failed to load sanctions: %v", err)
+		s.recordError(r, sessionID, "resolve: failed to load sanctions: "+err.Error())
+		apierror.Write(w, r, http.StatusInternalServerError, apierror.CodePSIFailed, "Failed to load sanctions")
+		return
+	}
+	log.Printf("[DEBUG] Loaded %d sanctions from DB", len(sanctions))
+
+	// Create hash map for O(1) lookup
+	hashSet := make(map[int64]bool)
+	for _, hash := range req.Hashes {
+		hashSet[int64(hash)] = true
+	}
+
+	// Filter sanctions that match the provided hashes using DYNAMIC hashing
+	var matchedSanctions []protocol.ResolvedSanction
+	
+	// Default columns if not set (legacy sessions)
+	columns := serverCtx.EnabledColumns
+	if len(columns) == 0 {
+		columns = []string{"name", "dob", "country"}
+	}
+	
+	// Distinct records whose truncated hashes collide are all returned; the
+	// client tells them apart by comparing full records
+	resolvedInputs := make(map[int64]string)
+	collisions := 0
+	for _, sanction := range sanctions {
+		// Entries delisted since the session started stay resolvable; ones
+		// listed since were not in its set
+		if !sanction.InEffect(serverCtx.CreatedAt) {
+			continue
+		}
+		// Re-calculate hashes using the session's schema. Aliases and
+		// document numbers hash separately, so one sanction can answer
+		// several matched hashes.
+		inputs := psiadapter.SanctionHashInputs(&sanction, columns, serverCtx.Transliteration)
+		if serverCtx.Documents {
+			inputs = append(inputs, psiadapter.DocumentHashInputs(sanction.Attributes, sanction.Country)...)
+		}
+		for _, serialized := range inputs {
+			dynamicHash := int64(serverCtx.Hash.HashOne(serialized))
+			if !hashSet[dynamicHash] {
+				continue
+			}
+			if prev, ok := resolvedInputs[dynamicHash]; !ok {
+				resolvedInputs[dynamicHash] = serialized
+			} else if prev != serialized {
+				collisions++
+			}
+			matchedSanctions = append(matchedSanctions, protocol.ResolvedSanction{
+				Hash:         dynamicHash, // Return the DYNAMIC hash properly
+				Name:         sanction.Name,
+				DOB:          sanction.DOB,
+				Country:      sanction.Country,
+				Program:      sanction.Program,
+				Source:       sanction.Source,
+				EntityType:   sanction.EntityType,
+				Aliases:      sanction.Aliases,
+				IMONumber:    sanction.IMONumber,
+				Registration: sanction.Registration,
+				Category:     sanction.Category,
+				Attributes:   sanction.Attributes,
+				SourceRef:    sanction.SourceRef,
+			})
+		}
+	}
+
+	log.Printf("Resolved %d sanctions for session %s from %d hashes", len(matchedSanctions), sessionID, len(req.Hashes))
+	detail := fmt.Sprintf("%d hashes requested", len(req.Hashes))
+	if collisions > 0 {
+		log.Printf("Warning: %d hash collisions between distinct sanction records in session %s", collisions, sessionID)
+		detail += fmt.Sprintf(", %d hash collisions", collisions)
+	}
+	s.recordEvent(r, models.SessionEvent{
+		SessionID:  sessionID,
+		Event:      models.SessionEventResolve,
+		MatchCount: len(matchedSanctions),
+		Detail:     detail,
+	})
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(protocol.ResolveResponse{Sanctions: matchedSanctions})
+}
+
+// handleDeleteSession ends a session and revokes its access token
+func (s *Server) handleDeleteSession(w http.ResponseWriter, r *http.Request) {
+	sessionID := chi.URLParam(r, "sessionID")
+	if _, err := s.authorizeSession(r, sessionID); err != nil {
+		writeSessionAuthError(w, r, err)
+		return
+	}
+
+	s.sessions.Delete(sessionID)
+
+	log.Printf("Session %s closed and token revoked", sessionID)
+	s.recordEvent(r, models.SessionEvent{SessionID: sessionID, Event: models.SessionEventClose})
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]bool{"success": true})
+}
+
+func (s *Server) corsMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Access-Control-Allow-Origin", "*")
+		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, PATCH, DELETE, OPTIONS")
+		w.Header().Set("Access-Control-Allow-Headers", "Accept, Authorization, Content-Type, X-API-Key")
+
+		if r.Method == "OPTIONS" {
+			w.WriteHeader(http.StatusOK)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,88 @@
+package psiserver
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/SanthoshCheemala/FLARE/backend/internal/models"
+	"github.com/SanthoshCheemala/FLARE/backend/internal/protocol"
+)
+
+// sessionListIDs returns the lists a session screens against: the
+// requested list IDs plus every active list in the requested categories and
+// packs. Requesting an inactive list by ID is an error. Categories and packs
+// that expand to no list leave the session without lists, which is an error
+// rather than a silent fallback to all lists.
+func (s *Server) sessionListIDs(ctx context.Context, req protocol.InitSessionRequest) ([]string, error) {
+	inactive, err := s.inactiveListIDs(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, id := range req.SanctionListIDs {
+		if inactive[id] {
+			return nil, fmt.Errorf("sanction list %s is inactive", id)
+		}
+	}
+	if len(req.Categories) == 0 && len(req.PackIDs) == 0 {
+		return req.SanctionListIDs, nil
+	}
+
+	var ids []int64
+	if len(req.Categories) > 0 {
+		categories := make([]string, 0, len(req.Categories))
+		for _, c := range req.Categories {
+			category, ok := models.ParseListCategory(c)
+			if !ok {
+				return nil, fmt.Errorf("unknown list category %q", c)
+			}
+			categories = append(categories, category)
+		}
+		categoryIDs, err := s.repo.GetSanctionListIDsByCategory(ctx, categories)
+		if err != nil {
+			return nil, err
+		}
+		ids = append(ids, categoryIDs...)
+	}
+	for _, packID := range req.PackIDs {
+		pack, err := s.repo.GetPack(ctx, packID)
+		if err != nil {
+			return nil, err
+		}
+		if pack == nil {
+			return nil, fmt.Errorf("unknown pack %q", packID)
+		}
+		ids = append(ids, pack.ListIDs...)
+	}
+
+	listIDs := append([]string(nil), req.SanctionListIDs...)
+	seen := make(map[string]bool, len(listIDs))
+	for _, id := range listIDs {
+		seen[id] = true
+	}
+	for _, id := range ids {
+		if idStr := fmt.Sprintf("%d", id); !seen[idStr] && !inactive[idStr] {
+			seen[idStr] = true
+			listIDs = append(listIDs, idStr)
+		}
+	}
+	if len(listIDs) == 0 {
+		return nil, fmt.Errorf("no active lists in categories %v or packs %v", req.Categories, req.PackIDs)
+	}
+	return listIDs, nil
+}
+
+// inactiveListIDs returns the IDs of the lists that are stored but not
+// screened against
+func (s *Server) inactiveListIDs(ctx context.Context) (map[string]bool, error) {
+	lists, err := s.repo.GetSanctionLists(ctx)
+	if err != nil {
+		return nil, err
+	}
+	inactive := make(map[string]bool)
+	for _, l := range lists {
+		if !l.Active {
+			inactive[fmt.Sprintf("%d", l.ID)] = true
+		}
+	}
+	return inactive, nil
+}
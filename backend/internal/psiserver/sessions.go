@@ -0,0 +1,257 @@
+package psiserver
+
+import (
+	"log"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/SanthoshCheemala/FLARE/backend/internal/psiadapter"
+	"github.com/SanthoshCheemala/FLARE/backend/internal/translit"
+)
+
+// SessionContext wraps ServerContext with additional metadata
+type SessionContext struct {
+	*psiadapter.ServerContext
+	// BatchContext is set for global sessions created while batch PSI was
+	// active; it pins the batches the session was initialized against.
+	BatchContext   *psiadapter.BatchServerContext
+	ListIDs        []string // Sanction list IDs used in this session
+	EnabledColumns []string // Schema used for this session
+	// Matches holds the hashes found by intersection for this session.
+	// Only these hashes may be resolved to full sanction records.
+	Matches   map[int64]bool
+	TokenID   string    // ID of the access token issued at init; cleared on revoke
+	APIKeyID  int64     // Key that started the session; 0 if none
+	Client    string    // Address the session was started from
+	CreatedAt time.Time // Set when the session is added
+	// LastSeen is the time of the client's last authorized request. Sessions
+	// idle longer than PSI_SESSION_IDLE_TIMEOUT are expired.
+	LastSeen time.Time
+	// TreeDir holds a dynamic session's tree; it is removed with the session
+	TreeDir string
+	// SigningKey authenticates intersect requests. It is only sent in the
+	// init response, so captured requests cannot be re-signed.
+	SigningKey string
+	// Transliteration romanizes names in this session's hash inputs
+	Transliteration translit.Profile
+	// Documents adds the sanctions' document numbers to the session's set
+	Documents bool
+	// Hash is the session's hash scheme. It is set for batched sessions
+	// too, whose embedded ServerContext is nil.
+	Hash psiadapter.HashScheme
+	// Ciphertexts counts the ciphertexts intersected for this session.
+	// Resolve requires a minimum, so single records cannot be probed.
+	Ciphertexts int
+}
+
+// SessionInfo is the admin view of a live session
+type SessionInfo struct {
+	ID             string    `json:"id"`
+	ListIDs        []string  `json:"listIds"`
+	EnabledColumns []string  `json:"enabledColumns"`
+	Batched        bool      `json:"batched"`
+	MatchCount     int       `json:"matchCount"`
+	Ciphertexts    int       `json:"ciphertexts"`
+	APIKeyID       int64     `json:"apiKeyId"`
+	CreatedAt      time.Time `json:"createdAt"`
+	LastSeen       time.Time `json:"lastSeen"`
+	// Transliteration identifies the session's profile, e.g. "v1:cyrillic"
+	Transliteration string `json:"transliteration,omitempty"`
+	Documents       bool   `json:"documents"` // The set includes document numbers
+	Hash            string `json:"hash"`      // Scheme name, e.g. "siphash/v1"; never the salt
+}
+
+// clone returns a copy whose slices and map can be read without holding
+// the manager lock. PSI contexts are shared and treated as read-only.
+func (sc *SessionContext) clone() SessionContext {
+	c := *sc
+	c.ListIDs = append([]string(nil), sc.ListIDs...)
+	c.EnabledColumns = append([]string(nil), sc.EnabledColumns...)
+	c.Matches = make(map[int64]bool, len(sc.Matches))
+	for h := range sc.Matches {
+		c.Matches[h] = true
+	}
+	return c
+}
+
+// SessionManager owns the live PSI sessions. All access goes through its
+// methods; readers receive copies so handlers never touch shared state.
+type SessionManager struct {
+	mu       sync.RWMutex
+	sessions map[string]*SessionContext
+}
+
+func NewSessionManager() *SessionManager {
+	return &SessionManager{
+		sessions: make(map[string]*SessionContext),
+	}
+}
+
+// Add registers a session. The manager takes ownership of sc.
+func (m *SessionManager) Add(id string, sc *SessionContext) {
+	if sc.CreatedAt.IsZero() {
+		sc.CreatedAt = time.Now()
+	}
+	sc.LastSeen = sc.CreatedAt
+	m.mu.Lock()
+	m.sessions[id] = sc
+	m.mu.Unlock()
+}
+
+// List describes every live session, oldest first
+func (m *SessionManager) List() []SessionInfo {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	infos := make([]SessionInfo, 0, len(m.sessions))
+	for id, sc := range m.sessions {
+		infos = append(infos, SessionInfo{
+			ID:             id,
+			ListIDs:        append([]string(nil), sc.ListIDs...),
+			EnabledColumns: append([]string(nil), sc.EnabledColumns...),
+			Batched:        sc.BatchContext != nil,
+			MatchCount:     len(sc.Matches),
+			Ciphertexts:    sc.Ciphertexts,
+			APIKeyID:       sc.APIKeyID,
+			CreatedAt:      sc.CreatedAt,
+			LastSeen:       sc.LastSeen,
+
+			Transliteration: sc.Transliteration.String(),
+			Documents:       sc.Documents,
+			Hash:            sc.Hash.String(),
+		})
+	}
+	sort.Slice(infos, func(i, j int) bool {
+		return infos[i].CreatedAt.Before(infos[j].CreatedAt)
+	})
+	return infos
+}
+
+// Get returns a snapshot of the session
+func (m *SessionManager) Get(id string) (SessionContext, bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	sc, ok := m.sessions[id]
+	if !ok {
+		return SessionContext{}, false
+	}
+	return sc.clone(), true
+}
+
+// RecordMatches adds intersection results to the session's match set and
+// counts the ciphertexts they came from. It reports false if the session no
+// longer exists.
+func (m *SessionManager) RecordMatches(id string, matches []uint64, ciphertexts int) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	sc, ok := m.sessions[id]
+	if !ok {
+		return false
+	}
+	if sc.Matches == nil {
+		sc.Matches = make(map[int64]bool, len(matches))
+	}
+	for _, h := range matches {
+		sc.Matches[int64(h)] = true
+	}
+	sc.Ciphertexts += ciphertexts
+	return true
+}
+
+// Touch marks the session as seen now. It reports false if the session no
+// longer exists.
+func (m *SessionManager) Touch(id string) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	sc, ok := m.sessions[id]
+	if ok {
+		sc.LastSeen = time.Now()
+	}
+	return ok
+}
+
+// Delete removes the session, reporting whether it existed
+func (m *SessionManager) Delete(id string) bool {
+	m.mu.Lock()
+	sc, ok := m.sessions[id]
+	delete(m.sessions, id)
+	m.mu.Unlock()
+
+	if ok {
+		sc.release()
+	}
+	return ok
+}
+
+// ExpireIdle removes the sessions last seen before cutoff and returns
+// their IDs
+func (m *SessionManager) ExpireIdle(cutoff time.Time) []string {
+	m.mu.Lock()
+	var expired []*SessionContext
+	var ids []string
+	for id, sc := range m.sessions {
+		if sc.LastSeen.Before(cutoff) {
+			expired = append(expired, sc)
+			ids = append(ids, id)
+			delete(m.sessions, id)
+		}
+	}
+	m.mu.Unlock()
+
+	for _, sc := range expired {
+		sc.release()
+	}
+	return ids
+}
+
+// release frees what the session holds outside the manager: the tree files
+// of a dynamic session
+func (sc *SessionContext) release() {
+	if sc.TreeDir == "" {
+		return
+	}
+	if err := os.RemoveAll(sc.TreeDir); err != nil {
+		log.Printf("Warning: failed to remove session tree %s: %v", sc.TreeDir, err)
+	}
+}
+
+// CountForAPIKey returns the number of live sessions started with the key
+func (m *SessionManager) CountForAPIKey(apiKeyID int64) int {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	n := 0
+	for _, sc := range m.sessions {
+		if sc.APIKeyID == apiKeyID {
+			n++
+		}
+	}
+	return n
+}
+
+// CountForClient returns the number of live sessions started without an API
+// key from the client address
+func (m *SessionManager) CountForClient(client string) int {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	n := 0
+	for _, sc := range m.sessions {
+		if sc.APIKeyID == 0 && sc.Client == client {
+			n++
+		}
+	}
+	return n
+}
+
+// Len returns the number of live sessions
+func (m *SessionManager) Len() int {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return len(m.sessions)
+}
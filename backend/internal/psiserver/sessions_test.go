@@ -0,0 +1,147 @@
+package psiserver
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/SanthoshCheemala/FLARE/backend/internal/psiadapter"
+)
+
+// These tests run sessions from parallel goroutines; run them with -race
+// to check the manager and global state locking.
+
+func TestSessionManagerConcurrent(t *testing.T) {
+	m := NewSessionManager()
+	const workers, rounds = 8, 50
+
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := 0; i < rounds; i++ {
+				id := fmt.Sprintf("s%d-%d", w, i)
+				m.Add(id, &SessionContext{ListIDs: []string{"1"}, APIKeyID: int64(w)})
+				if !m.RecordMatches(id, []uint64{uint64(i), uint64(i + 1)}, 2) {
+					t.Errorf("RecordMatches(%s): session missing", id)
+				}
+				m.Touch(id)
+				sc, ok := m.Get(id)
+				if !ok {
+					t.Errorf("Get(%s): session missing", id)
+					continue
+				}
+				// The snapshot is the caller's to change
+				sc.Matches[-1] = true
+				sc.ListIDs[0] = "changed"
+				if !m.Delete(id) {
+					t.Errorf("Delete(%s): session missing", id)
+				}
+			}
+		}(w)
+	}
+	done := make(chan struct{})
+	var readers sync.WaitGroup
+	readers.Add(1)
+	go func() {
+		defer readers.Done()
+		for {
+			select {
+			case <-done:
+				return
+			default:
+			}
+			for _, info := range m.List() {
+				if len(info.ListIDs) == 1 && info.ListIDs[0] == "changed" {
+					t.Errorf("session %s: snapshot change reached the manager", info.ID)
+				}
+			}
+			m.Len()
+			m.CountForAPIKey(0)
+			m.ExpireIdle(time.Now().Add(-time.Hour))
+		}
+	}()
+	wg.Wait()
+	close(done)
+	readers.Wait()
+
+	if n := m.Len(); n != 0 {
+		t.Errorf("%d sessions left after every one was deleted", n)
+	}
+}
+
+func TestServerConcurrentSessions(t *testing.T) {
+	s := newTestServer(t, nil)
+	const workers, rounds = 6, 10
+
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := 0; i < rounds; i++ {
+				id := fmt.Sprintf("s%d-%d", w, i)
+				sc := &SessionContext{ServerContext: &psiadapter.ServerContext{}}
+				token, _, err := s.registerSession(httptest.NewRequest(http.MethodPost, "/session/init", nil), id, sc)
+				if err != nil {
+					t.Errorf("register session %s: %v", id, err)
+					return
+				}
+
+				// A session deleted by the other goroutine is gone, or
+				// closed during intersection
+				rec := intersect(s, token, sc.SigningKey, id, intersectBody(id, 2))
+				switch rec.Code {
+				case http.StatusOK, http.StatusNotFound, http.StatusGone:
+				default:
+					t.Errorf("intersect %s: status = %d: %s", id, rec.Code, rec.Body)
+				}
+
+				req := httptest.NewRequest(http.MethodDelete, "/session/"+id, nil)
+				req.Header.Set("Authorization", "Bearer "+token)
+				rec = httptest.NewRecorder()
+				s.Handler().ServeHTTP(rec, req)
+				if rec.Code != http.StatusOK && rec.Code != http.StatusNotFound {
+					t.Errorf("delete %s: status = %d: %s", id, rec.Code, rec.Body)
+				}
+			}
+		}(w)
+	}
+
+	// Rebuilds swap the global state and sessions are deleted while
+	// requests are in flight
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < rounds; i++ {
+			if err := s.initGlobalState(); err != nil {
+				t.Errorf("init global state: %v", err)
+			}
+			rec := httptest.NewRecorder()
+			s.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/capabilities", nil))
+			if rec.Code != http.StatusOK {
+				t.Errorf("capabilities: status = %d: %s", rec.Code, rec.Body)
+			}
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		for i := 0; i < rounds; i++ {
+			for _, info := range s.sessions.List() {
+				if info.ID[len(info.ID)-1]%2 == 0 {
+					s.sessions.Delete(info.ID)
+				}
+			}
+			s.globalState()
+		}
+	}()
+	wg.Wait()
+
+	if n := s.sessions.Len(); n != 0 {
+		t.Errorf("%d sessions left after every one was deleted", n)
+	}
+}
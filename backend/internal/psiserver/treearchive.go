@@ -0,0 +1,95 @@
+package psiserver
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/SanthoshCheemala/FLARE/backend/internal/apierror"
+	"github.com/SanthoshCheemala/FLARE/backend/internal/psiadapter"
+	"github.com/SanthoshCheemala/FLARE/backend/internal/translit"
+	"github.com/SanthoshCheemala/FLARE/backend/internal/treearchive"
+)
+
+// treeArchiveParams is the params entry of a tree archive: what a session
+// on the archived trees would be given at init
+type treeArchiveParams struct {
+	Slot            string                               `json:"slot"`
+	Params          *psiadapter.SerializedServerParams   `json:"params,omitempty"`
+	BatchParams     []*psiadapter.SerializedServerParams `json:"batchParams,omitempty"`
+	Transliteration translit.Profile                     `json:"transliteration"`
+	Hash            psiadapter.HashScheme                `json:"hash"`
+	CreatedAt       time.Time                            `json:"createdAt"`
+}
+
+// handleAdminTreeArchive streams the live global trees as a tree archive.
+// Rebuilds wait until the download completes, so the trees cannot be
+// replaced while they are read.
+func (s *Server) handleAdminTreeArchive(w http.ResponseWriter, r *http.Request) {
+	s.rebuildMu.Lock()
+	defer s.rebuildMu.Unlock()
+
+	global := s.globalState()
+	if global.Params == nil {
+		apierror.Write(w, r, http.StatusServiceUnavailable, apierror.CodePSIFailed, "Global PSI state is not built")
+		return
+	}
+	params := treeArchiveParams{
+		Slot:            global.Slot,
+		Transliteration: global.Transliteration,
+		Hash:            global.Hash,
+		CreatedAt:       time.Now().UTC(),
+	}
+	var trees []string
+	if global.UseBatching {
+		params.BatchParams = global.BatchContext.ParamsSnapshot()
+		trees = global.BatchContext.TreePaths()
+	} else {
+		params.Params = global.Params
+		trees = []string{global.ServerContext.TreePath}
+	}
+	paramsJSON, err := json.MarshalIndent(params, "", "  ")
+	if err != nil {
+		apierror.Write(w, r, http.StatusInternalServerError, apierror.CodeInternal, "Failed to serialize params")
+		return
+	}
+	for _, tree := range trees {
+		if _, err := os.Stat(tree); err != nil {
+			apierror.Write(w, r, http.StatusServiceUnavailable, apierror.CodePSIFailed, "Tree database unavailable: "+err.Error())
+			return
+		}
+	}
+
+	// Headers are sent with the first entry, so later failures can only
+	// cut the archive short, which fails its verification
+	w.Header().Set("Content-Type", "application/gzip")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"flare-trees-%s-%s.tar.gz\"",
+		global.Slot, params.CreatedAt.Format("20060102T150405Z")))
+	if err := writeTreeArchive(w, trees, paramsJSON); err != nil {
+		log.Printf("Tree archive download failed: %v", err)
+	}
+}
+
+// writeTreeArchive writes the params and each tree database with its
+// manifest, where one was written
+func writeTreeArchive(w io.Writer, trees []string, paramsJSON []byte) error {
+	a := treearchive.NewWriter(w)
+	if err := a.AddBytes(treearchive.ParamsFile, paramsJSON); err != nil {
+		return err
+	}
+	for _, tree := range trees {
+		if err := a.AddFile(treearchive.TreeEntry(tree), tree); err != nil {
+			return err
+		}
+		manifest := tree + psiadapter.TreeManifestSuffix
+		if err := a.AddFile(treearchive.TreeEntry(manifest), manifest); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+	}
+	return a.Close()
+}
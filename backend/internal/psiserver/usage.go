@@ -0,0 +1,153 @@
+package psiserver
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/SanthoshCheemala/FLARE/backend/internal/apierror"
+	"github.com/SanthoshCheemala/FLARE/backend/internal/repository"
+)
+
+// monthLayout is the format of the from and to usage query params
+const monthLayout = "2006-01"
+
+// handleAdminUsage returns PSI usage per API key and month for chargeback.
+// Query params: from and to (YYYY-MM, inclusive; default the current month),
+// format (json or csv) and noise=true for differentially private counts
+// that can be shared externally (requires PSI_STATS_EPSILON). Noised usage
+// covers completed months only, defaulting to the last one, and is drawn
+// once per month.
+func (s *Server) handleAdminUsage(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	noisy, _ := strconv.ParseBool(query.Get("noise"))
+	now := time.Now().UTC()
+	current := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
+	from := current
+	if noisy {
+		from = current.AddDate(0, -1, 0)
+	}
+	to := from
+
+	var err error
+	if v := query.Get("from"); v != "" {
+		if from, err = time.Parse(monthLayout, v); err != nil {
+			apierror.Write(w, r, http.StatusBadRequest, apierror.CodeBadRequest, "Invalid from; use YYYY-MM")
+			return
+		}
+	}
+	if v := query.Get("to"); v != "" {
+		if to, err = time.Parse(monthLayout, v); err != nil {
+			apierror.Write(w, r, http.StatusBadRequest, apierror.CodeBadRequest, "Invalid to; use YYYY-MM")
+			return
+		}
+	} else if query.Get("from") != "" {
+		to = from
+	}
+	if to.Before(from) {
+		apierror.Write(w, r, http.StatusBadRequest, apierror.CodeBadRequest, "to must not be before from")
+		return
+	}
+	if noisy && s.cfg.PSI.StatsEpsilon <= 0 {
+		apierror.Write(w, r, http.StatusBadRequest, apierror.CodeBadRequest, "Noised usage requires PSI_STATS_EPSILON to be set")
+		return
+	}
+	if noisy && !to.Before(current) {
+		apierror.Write(w, r, http.StatusBadRequest, apierror.CodeBadRequest, "Noised usage covers completed months only; to must be before "+current.Format(monthLayout))
+		return
+	}
+	format := query.Get("format")
+	if format != "" && format != "json" && format != "csv" {
+		apierror.Write(w, r, http.StatusBadRequest, apierror.CodeBadRequest, "Invalid format; use json or csv")
+		return
+	}
+
+	if noisy {
+		noise := s.newLaplaceNoise()
+		records := make([]noisyUsage, 0)
+		for month := from; !month.After(to); month = month.AddDate(0, 1, 0) {
+			released, err := s.released.monthlyUsage(r.Context(), s.repo, noise, month)
+			if err != nil {
+				apierror.Write(w, r, http.StatusInternalServerError, apierror.CodeDatabaseError, "Database error")
+				return
+			}
+			records = append(records, released...)
+		}
+		writeNoisyUsage(w, noise, records, from, to, format)
+		return
+	}
+
+	// Chargeback counts everything
+	usage, err := s.repo.GetUsage(r.Context(), from, to.AddDate(0, 1, 0), repository.SessionCaps{})
+	if err != nil {
+		apierror.Write(w, r, http.StatusInternalServerError, apierror.CodeDatabaseError, "Database error")
+		return
+	}
+
+	if format == "csv" {
+		writeUsageCSVHeaders(w, from, to)
+		cw := csv.NewWriter(w)
+		cw.Write([]string{"month", "api_key_id", "api_key_name", "sessions", "intersections", "matches", "ciphertexts", "cpu_seconds", "errors"})
+		for _, u := range usage {
+			cw.Write([]string{
+				u.Month,
+				strconv.FormatInt(u.APIKeyID, 10),
+				u.APIKeyName,
+				strconv.Itoa(u.Sessions),
+				strconv.Itoa(u.Intersections),
+				strconv.Itoa(u.Matches),
+				strconv.Itoa(u.Ciphertexts),
+				strconv.FormatFloat(u.CPUSeconds, 'f', 3, 64),
+				strconv.Itoa(u.Errors),
+			})
+		}
+		cw.Flush()
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"from":  from.Format(monthLayout),
+		"to":    to.Format(monthLayout),
+		"usage": usage,
+	})
+}
+
+// writeNoisyUsage writes released noised usage
+func writeNoisyUsage(w http.ResponseWriter, noise laplaceNoise, records []noisyUsage, from, to time.Time, format string) {
+	if format == "csv" {
+		writeUsageCSVHeaders(w, from, to)
+		cw := csv.NewWriter(w)
+		cw.Write([]string{"month", "api_key_id", "api_key_name", "sessions", "intersections", "matches", "errors"})
+		for _, u := range records {
+			cw.Write([]string{
+				u.Month,
+				strconv.FormatInt(u.APIKeyID, 10),
+				u.APIKeyName,
+				strconv.Itoa(u.Sessions),
+				strconv.Itoa(u.Intersections),
+				strconv.Itoa(u.Matches),
+				strconv.Itoa(u.Errors),
+			})
+		}
+		cw.Flush()
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"from":  from.Format(monthLayout),
+		"to":    to.Format(monthLayout),
+		"usage": records,
+		"noise": noise.describe(),
+	})
+}
+
+func writeUsageCSVHeaders(w http.ResponseWriter, from, to time.Time) {
+	w.Header().Set("Content-Type", "text/csv")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="flare-usage-%s-%s.csv"`,
+		from.Format(monthLayout), to.Format(monthLayout)))
+}
@@ -0,0 +1,70 @@
+package psiserver
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/SanthoshCheemala/FLARE/backend/internal/models"
+)
+
+// nextValidityChange returns the earliest time after at that one of the
+// sanctions comes into or goes out of effect, zero if none does
+func nextValidityChange(sanctions []models.Sanction, at time.Time) time.Time {
+	var next time.Time
+	consider := func(t *time.Time) {
+		if t != nil && t.After(at) && (next.IsZero() || t.Before(next)) {
+			next = *t
+		}
+	}
+	for i := range sanctions {
+		consider(sanctions[i].ValidFrom)
+		consider(sanctions[i].ValidTo)
+	}
+	return next
+}
+
+// scheduleValidityRebuild rebuilds the global state at next so entries
+// listed or delisted from then on take effect without a new upload. It
+// replaces the schedule of the previous build; a zero next cancels it.
+// Callers hold rebuildMu.
+func (s *Server) scheduleValidityRebuild(next time.Time) {
+	if s.validityTimer != nil {
+		s.validityTimer.Stop()
+		s.validityTimer = nil
+	}
+	if next.IsZero() {
+		return
+	}
+	log.Printf("Global state rebuild scheduled for %s, when a sanction entry comes into or goes out of effect",
+		next.Format(time.RFC3339))
+	s.validityTimer = time.AfterFunc(time.Until(next), func() {
+		if err := s.initGlobalState(); err != nil {
+			log.Printf("Failed to re-initialize global state after a validity change: %v", err)
+		}
+	})
+}
+
+// parseValidity parses an upload's valid_from and valid_to values, each an
+// RFC 3339 timestamp or a YYYY-MM-DD date (midnight UTC) and empty when the
+// entry's validity is open on that side
+func parseValidity(from, to string) (validFrom, validTo *time.Time, err error) {
+	if from != "" {
+		t, err := parseDateParam(from)
+		if err != nil {
+			return nil, nil, fmt.Errorf("invalid valid_from %q", from)
+		}
+		validFrom = &t
+	}
+	if to != "" {
+		t, err := parseDateParam(to)
+		if err != nil {
+			return nil, nil, fmt.Errorf("invalid valid_to %q", to)
+		}
+		validTo = &t
+	}
+	if validFrom != nil && validTo != nil && !validTo.After(*validFrom) {
+		return nil, nil, fmt.Errorf("valid_to %s is not after valid_from %s", to, from)
+	}
+	return validFrom, validTo, nil
+}
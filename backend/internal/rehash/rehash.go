@@ -0,0 +1,175 @@
+// Package rehash rebuilds the hashes stored with customer and sanction
+// records after psiadapter.RecordHashVersion changes. Records are rehashed
+// in pages, each stamped with the version it was hashed under, so an
+// interrupted rebuild resumes where it stopped.
+package rehash
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/SanthoshCheemala/FLARE/backend/internal/psiadapter"
+	"github.com/SanthoshCheemala/FLARE/backend/internal/repository"
+)
+
+// Rehash states reported by Status
+const (
+	StateIdle     = "IDLE"
+	StateRunning  = "RUNNING"
+	StateComplete = "COMPLETED"
+	StateFailed   = "FAILED"
+)
+
+// pageSize is the number of records rehashed per transaction
+const pageSize = 500
+
+// Status describes the current or most recent rehash
+type Status struct {
+	State          string     `json:"state"`
+	Version        int        `json:"version"`        // Record hash version being rebuilt to
+	CustomersDone  int        `json:"customersDone"`  // Customers rehashed so far
+	CustomersTotal int        `json:"customersTotal"` // Customers stale when the rehash started
+	SanctionsDone  int        `json:"sanctionsDone"`
+	SanctionsTotal int        `json:"sanctionsTotal"`
+	StartedAt      *time.Time `json:"startedAt,omitempty"`
+	FinishedAt     *time.Time `json:"finishedAt,omitempty"`
+	Error          string     `json:"error,omitempty"`
+}
+
+// Job rehashes a repository's stale records, one run at a time
+type Job struct {
+	repo *repository.Repository
+
+	mu  sync.Mutex
+	cur Status
+}
+
+func New(repo *repository.Repository) *Job {
+	return &Job{repo: repo}
+}
+
+// Start begins a rehash in the background. It reports false if one is
+// already running.
+func (j *Job) Start() bool {
+	now := time.Now()
+	j.mu.Lock()
+	if j.cur.State == StateRunning {
+		j.mu.Unlock()
+		return false
+	}
+	j.cur = Status{State: StateRunning, Version: psiadapter.RecordHashVersion, StartedAt: &now}
+	j.mu.Unlock()
+
+	go j.run(context.Background())
+	return true
+}
+
+// StartIfStale starts a rehash when any record's hash predates the current
+// version, as after an upgrade that bumped it
+func (j *Job) StartIfStale(ctx context.Context) {
+	customers, sanctions, err := j.repo.CountStaleHashes(ctx, psiadapter.RecordHashVersion)
+	if err != nil {
+		log.Printf("Warning: failed to count stale record hashes: %v", err)
+		return
+	}
+	if customers+sanctions > 0 {
+		log.Printf("Rehashing %d customers and %d sanctions to record hash version %d", customers, sanctions, psiadapter.RecordHashVersion)
+		j.Start()
+	}
+}
+
+// Status returns a snapshot of the current or last rehash
+func (j *Job) Status() Status {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	s := j.cur
+	if s.State == "" {
+		s.State = StateIdle
+		s.Version = psiadapter.RecordHashVersion
+	}
+	return s
+}
+
+func (j *Job) run(ctx context.Context) {
+	err := j.rehash(ctx)
+
+	now := time.Now()
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.cur.FinishedAt = &now
+	if err != nil {
+		j.cur.State = StateFailed
+		j.cur.Error = err.Error()
+		log.Printf("Record rehash failed: %v", err)
+		return
+	}
+	j.cur.State = StateComplete
+	log.Printf("Rehashed %d customers and %d sanctions in %s", j.cur.CustomersDone, j.cur.SanctionsDone,
+		now.Sub(*j.cur.StartedAt).Round(time.Millisecond))
+}
+
+func (j *Job) rehash(ctx context.Context) error {
+	version := psiadapter.RecordHashVersion
+	customers, sanctions, err := j.repo.CountStaleHashes(ctx, version)
+	if err != nil {
+		return fmt.Errorf("count stale hashes: %w", err)
+	}
+	j.update(func(s *Status) {
+		s.CustomersTotal = customers
+		s.SanctionsTotal = sanctions
+	})
+
+	// Rehashed records leave the stale set; paging by ID as well keeps the
+	// loop finite whatever the updates did
+	var after int64
+	for {
+		page, err := j.repo.StaleCustomers(ctx, version, after, pageSize)
+		if err != nil {
+			return fmt.Errorf("load customers: %w", err)
+		}
+		if len(page) == 0 {
+			break
+		}
+		hashes := make(map[int64]int64, len(page))
+		for i := range page {
+			c := &page[i]
+			hashes[c.ID] = psiadapter.RecordHash(c.EntityType, c.HashValues())
+		}
+		if err := j.repo.UpdateCustomerHashes(ctx, hashes, version); err != nil {
+			return fmt.Errorf("update customers: %w", err)
+		}
+		after = page[len(page)-1].ID
+		j.update(func(s *Status) { s.CustomersDone += len(page) })
+	}
+
+	after = 0
+	for {
+		page, err := j.repo.StaleSanctions(ctx, version, after, pageSize)
+		if err != nil {
+			return fmt.Errorf("load sanctions: %w", err)
+		}
+		if len(page) == 0 {
+			break
+		}
+		hashes := make(map[int64]int64, len(page))
+		for i := range page {
+			s := &page[i]
+			hashes[s.ID] = psiadapter.RecordHash(s.EntityType, s.HashValues())
+		}
+		if err := j.repo.UpdateSanctionHashes(ctx, hashes, version); err != nil {
+			return fmt.Errorf("update sanctions: %w", err)
+		}
+		after = page[len(page)-1].ID
+		j.update(func(s *Status) { s.SanctionsDone += len(page) })
+	}
+	return nil
+}
+
+func (j *Job) update(fn func(*Status)) {
+	j.mu.Lock()
+	fn(&j.cur)
+	j.mu.Unlock()
+}
@@ -0,0 +1,196 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/SanthoshCheemala/FLARE/backend/internal/models"
+)
+
+func TestGetDashboardStats(t *testing.T) {
+	f := newFixture(t)
+	ctx := context.Background()
+	for _, job := range []string{"job-2", "job-3", "job-4", "job-5", "job-6"} {
+		seedScreening(t, f.repo, job, f.customerListID)
+	}
+
+	screenings, matches, lists, recent, err := f.repo.GetDashboardStats(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if screenings != 6 || matches != 2 || lists != 3 {
+		t.Errorf("screenings %d, matches %d, lists %d; want 6, 2, 3", screenings, matches, lists)
+	}
+	if len(recent) != 5 {
+		t.Errorf("got %d recent screenings, want the 5 newest", len(recent))
+	}
+}
+
+func TestGetScreeningTrend(t *testing.T) {
+	r := newTestRepo(t)
+	ctx := context.Background()
+	listID, _ := seedCustomers(t, r, "customers")
+
+	// Two screenings on a Monday, one on the Wednesday after and one a
+	// week earlier, which falls before since
+	for _, s := range []struct {
+		job, created              string
+		matches, records, seconds int
+	}{
+		{"job-1", "2024-03-04 09:00:00", 2, 100, 1},
+		{"job-2", "2024-03-04 15:00:00", 0, 100, 3},
+		{"job-3", "2024-03-06 09:00:00", 5, 50, 0},
+		{"job-4", "2024-02-26 09:00:00", 9, 10, 0},
+	} {
+		seedScreening(t, r, s.job, listID)
+		exec(t, r, `UPDATE screenings SET created_at = ?, match_count = ?, customer_count = ? WHERE job_id = ?`,
+			s.created, s.matches, s.records, s.job)
+		if s.seconds > 0 {
+			exec(t, r, `UPDATE screenings SET started_at = ?, finished_at = datetime(?, ?) WHERE job_id = ?`,
+				s.created, s.created, fmt.Sprintf("+%d seconds", s.seconds), s.job)
+		}
+	}
+	since := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		interval string
+		want     []models.ScreeningTrendPoint
+	}{
+		{"day", []models.ScreeningTrendPoint{
+			{Period: "2024-03-04", Screenings: 2, Matches: 2, Customers: 200, MatchRate: 0.01, AvgDurationMs: 2000},
+			{Period: "2024-03-06", Screenings: 1, Matches: 5, Customers: 50, MatchRate: 0.1},
+		}},
+		{"week", []models.ScreeningTrendPoint{
+			{Period: "2024-W10", Screenings: 3, Matches: 7, Customers: 250, MatchRate: 0.028, AvgDurationMs: 2000},
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.interval, func(t *testing.T) {
+			points, err := r.GetScreeningTrend(ctx, since, tt.interval)
+			if err != nil {
+				t.Fatal(err)
+			}
+			// Durations come from julianday arithmetic and are not exact
+			for i := range points {
+				points[i].AvgDurationMs = float64(int64(points[i].AvgDurationMs + 0.5))
+			}
+			if !reflect.DeepEqual(points, tt.want) {
+				t.Errorf("got %+v, want %+v", points, tt.want)
+			}
+		})
+	}
+}
+
+func TestListAnalytics(t *testing.T) {
+	f := newFixture(t)
+	ctx := context.Background()
+	exec(t, f.repo, `UPDATE screening_results SET updated_at = datetime(created_at, '+60 seconds') WHERE id = ?`, f.results[1].ID)
+
+	customers, err := f.repo.GetCustomerListAnalytics(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []models.ListAnalytics{{ListID: f.customerListID, Name: "customers", Screenings: 1, Matches: 2, Confirmed: 1,
+		AvgResolutionSeconds: 60}}
+	if !reflect.DeepEqual(roundResolution(customers), want) {
+		t.Errorf("customer list analytics = %+v, want %+v", customers, want)
+	}
+
+	sanctions, err := f.repo.GetSanctionListAnalytics(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want = []models.ListAnalytics{
+		{ListID: f.sanctionListID, Name: "OFAC SDN", Screenings: 1, Matches: 1},
+		{ListID: f.pepListID, Name: "PEP", Screenings: 1, Matches: 1, Confirmed: 1, AvgResolutionSeconds: 60},
+	}
+	if !reflect.DeepEqual(roundResolution(sanctions), want) {
+		t.Errorf("sanction list analytics = %+v, want %+v", sanctions, want)
+	}
+
+	if err := f.repo.UpdateResultStatus(ctx, f.results[0].ID, "FALSE_POSITIVE", "alice"); err != nil {
+		t.Fatal(err)
+	}
+	customers, _ = f.repo.GetCustomerListAnalytics(ctx)
+	if customers[0].FalsePositives != 1 || customers[0].FalsePositiveRate != 0.5 {
+		t.Errorf("after a false positive, analytics = %+v", customers[0])
+	}
+}
+
+// roundResolution rounds the resolution times of lists to whole seconds
+func roundResolution(lists []models.ListAnalytics) []models.ListAnalytics {
+	for i := range lists {
+		lists[i].AvgResolutionSeconds = float64(int64(lists[i].AvgResolutionSeconds + 0.5))
+	}
+	return lists
+}
+
+func TestCustomerRisk(t *testing.T) {
+	f := newFixture(t)
+	ctx := context.Background()
+
+	// Alice matches again in a later screening and a re-upload of the list
+	// adds a new record for her
+	reuploaded := models.Customer{ExternalID: "C1", Name: "Alice Smith", Hash: 101, ListID: f.customerListID}
+	if err := f.repo.CreateCustomer(ctx, &reuploaded); err != nil {
+		t.Fatal(err)
+	}
+	second := seedScreening(t, f.repo, "job-2", f.customerListID)
+	seedResult(t, f.repo, second.ID, &reuploaded, f.sanctions[0], 0.9, "FALSE_POSITIVE")
+
+	tests := []struct {
+		name      string
+		listID    int64
+		tier      string
+		want      []string // External IDs, riskiest first
+		wantTotal int
+	}{
+		{"all", 0, "", []string{"C2", "C1"}, 2},
+		{"list", f.customerListID, "", []string{"C2", "C1"}, 2},
+		{"other list", 999, "", []string{}, 0},
+		{"tier", 0, models.RiskTierMedium, []string{"C1"}, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			risks, total, err := f.repo.ListCustomerRisk(ctx, tt.listID, tt.tier, 10, 0)
+			if err != nil {
+				t.Fatal(err)
+			}
+			ids := make([]string, len(risks))
+			for i, r := range risks {
+				ids[i] = r.ExternalID
+			}
+			if !reflect.DeepEqual(ids, tt.want) || total != tt.wantTotal {
+				t.Errorf("got %v of %d, want %v of %d", ids, total, tt.want, tt.wantTotal)
+			}
+		})
+	}
+
+	// Either of Alice's records finds her history, reported under the latest
+	for _, id := range []int64{f.customers[0].ID, reuploaded.ID} {
+		risk, err := f.repo.GetCustomerRisk(ctx, id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := &models.CustomerRisk{CustomerID: reuploaded.ID, ExternalID: "C1", Name: "Alice Smith", ListID: f.customerListID,
+			Screenings: 2, Hits: 2, FalsePositives: 1, Pending: 1, Programs: []string{"SDGT"}, Score: 17, Tier: models.RiskTierMedium}
+		if !reflect.DeepEqual(risk, want) {
+			t.Errorf("risk of record %d = %+v, want %+v", id, risk, want)
+		}
+	}
+
+	bob, _ := f.repo.GetCustomerRisk(ctx, f.customers[1].ID)
+	if bob.Tier != models.RiskTierHigh || bob.Score != 106 || !reflect.DeepEqual(bob.Programs, []string{"PEP"}) {
+		t.Errorf("risk of confirmed match = %+v", bob)
+	}
+	carol, _ := f.repo.GetCustomerRisk(ctx, f.customers[2].ID)
+	if carol.Tier != models.RiskTierNone || carol.ExternalID != "C3" || carol.Hits != 0 {
+		t.Errorf("risk of unmatched customer = %+v", carol)
+	}
+	if risk, err := f.repo.GetCustomerRisk(ctx, 999); risk != nil || err != nil {
+		t.Errorf("unknown customer: %+v, %v", risk, err)
+	}
+}
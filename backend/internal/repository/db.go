@@ -0,0 +1,70 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+// timedDB runs the repository's statements, bounding each one run outside a
+// transaction by timeout so a stalled database fails the call instead of
+// hanging it. Transactions follow their caller's context: a bulk insert may
+// rightly take longer than any single query.
+type timedDB struct {
+	*sql.DB
+	timeout time.Duration // 0 leaves statements unbounded
+}
+
+func (d *timedDB) withTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	if d.timeout <= 0 {
+		return ctx, func() {}
+	}
+	return context.WithTimeout(ctx, d.timeout)
+}
+
+func (d *timedDB) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	ctx, cancel := d.withTimeout(ctx)
+	defer cancel()
+	return d.DB.ExecContext(ctx, query, args...)
+}
+
+// QueryContext runs a query whose timeout covers reading its rows, up to
+// their Close
+func (d *timedDB) QueryContext(ctx context.Context, query string, args ...interface{}) (*timedRows, error) {
+	ctx, cancel := d.withTimeout(ctx)
+	rows, err := d.DB.QueryContext(ctx, query, args...)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	return &timedRows{Rows: rows, cancel: cancel}, nil
+}
+
+// QueryRowContext runs a query whose timeout covers its Scan
+func (d *timedDB) QueryRowContext(ctx context.Context, query string, args ...interface{}) *timedRow {
+	ctx, cancel := d.withTimeout(ctx)
+	return &timedRow{Row: d.DB.QueryRowContext(ctx, query, args...), cancel: cancel}
+}
+
+// timedRows releases its query's timeout when closed
+type timedRows struct {
+	*sql.Rows
+	cancel context.CancelFunc
+}
+
+func (r *timedRows) Close() error {
+	err := r.Rows.Close()
+	r.cancel()
+	return err
+}
+
+// timedRow releases its query's timeout once scanned
+type timedRow struct {
+	*sql.Row
+	cancel context.CancelFunc
+}
+
+func (r *timedRow) Scan(dest ...interface{}) error {
+	defer r.cancel()
+	return r.Row.Scan(dest...)
+}
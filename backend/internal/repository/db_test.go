@@ -0,0 +1,49 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestQueryTimeout(t *testing.T) {
+	r := newTestRepo(t)
+	ctx := context.Background()
+
+	// A recursive query that runs until it is interrupted
+	const slow = `WITH RECURSIVE n(i) AS (SELECT 1 UNION ALL SELECT i + 1 FROM n) SELECT COUNT(*) FROM n`
+
+	r.SetQueryTimeout(50 * time.Millisecond)
+	var n int
+	start := time.Now()
+	err := r.db.QueryRowContext(ctx, slow).Scan(&n)
+	if err == nil {
+		t.Fatal("unbounded query finished")
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("query ran for %v past its timeout", elapsed)
+	}
+
+	// The timeout is released once a query is read, leaving later ones unaffected
+	rows, err := r.db.QueryContext(ctx, `SELECT 1`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(100 * time.Millisecond)
+	if _, err := r.db.ExecContext(ctx, `UPDATE customers SET name = name`); err != nil {
+		t.Errorf("statement after timeout: %v", err)
+	}
+
+	// A canceled caller ends the query whatever the timeout
+	r.SetQueryTimeout(0)
+	canceled, cancel := context.WithCancel(ctx)
+	cancel()
+	if err := r.db.QueryRowContext(canceled, slow).Scan(&n); !errors.Is(err, context.Canceled) {
+		t.Errorf("query of a canceled context: %v", err)
+	}
+}
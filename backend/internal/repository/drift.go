@@ -0,0 +1,123 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// SchemaDrift is one way the live database differs from SQLiteSchema
+type SchemaDrift struct {
+	Table  string
+	Column string // Empty when the whole table is missing
+	Want   string // Declared type SQLiteSchema gives the column
+	Got    string // Declared type in the database; empty when the column is missing
+}
+
+// String describes the drift and how to repair it
+func (d SchemaDrift) String() string {
+	switch {
+	case d.Column == "":
+		return fmt.Sprintf("table %s is missing; it is created on startup unless the database is read-only or "+
+			"another schema holds the name", d.Table)
+	case d.Got == "":
+		return fmt.Sprintf("column %s.%s is missing; add it with ALTER TABLE %s ADD COLUMN %s %s",
+			d.Table, d.Column, d.Table, d.Column, d.Want)
+	default:
+		return fmt.Sprintf("column %s.%s is declared %s, expected %s; values read from it may fail to scan "+
+			"until the table is rebuilt with the expected type", d.Table, d.Column, d.Got, d.Want)
+	}
+}
+
+// schemaColumn is a column declared in SQLiteSchema
+type schemaColumn struct {
+	name, typ string
+}
+
+var (
+	expectedOnce    sync.Once
+	expectedColumns map[string][]schemaColumn
+	tableStatement  = regexp.MustCompile(`(?s)CREATE TABLE IF NOT EXISTS (\w+) \((.*?)\n\);`)
+)
+
+// schemaColumns parses the tables and columns SQLiteSchema declares, in
+// order. Table constraints such as foreign keys are skipped.
+func schemaColumns() map[string][]schemaColumn {
+	expectedOnce.Do(func() {
+		expectedColumns = make(map[string][]schemaColumn)
+		for _, m := range tableStatement.FindAllStringSubmatch(SQLiteSchema, -1) {
+			var columns []schemaColumn
+			for _, line := range strings.Split(m[2], "\n") {
+				fields := strings.Fields(strings.TrimSuffix(strings.TrimSpace(line), ","))
+				if len(fields) < 2 {
+					continue
+				}
+				switch strings.ToUpper(fields[0]) {
+				case "FOREIGN", "PRIMARY", "UNIQUE", "CHECK", "CONSTRAINT":
+					continue
+				}
+				columns = append(columns, schemaColumn{name: fields[0], typ: strings.ToUpper(fields[1])})
+			}
+			expectedColumns[m[1]] = columns
+		}
+	})
+	return expectedColumns
+}
+
+// CheckSchema compares the tables and columns of the live database with
+// those SQLiteSchema declares, so a database migrated by hand or by an older
+// build is reported at startup rather than by a failed query later. Columns
+// the schema does not declare are ignored. It reads SQLite's catalog and
+// only applies to SQLite databases.
+func (r *Repository) CheckSchema(ctx context.Context) ([]SchemaDrift, error) {
+	expected := schemaColumns()
+	tables := make([]string, 0, len(expected))
+	for table := range expected {
+		tables = append(tables, table)
+	}
+	sort.Strings(tables)
+
+	var drift []SchemaDrift
+	for _, table := range tables {
+		live, err := r.tableColumns(ctx, table)
+		if err != nil {
+			return nil, fmt.Errorf("read columns of %s: %w", table, err)
+		}
+		if len(live) == 0 {
+			drift = append(drift, SchemaDrift{Table: table})
+			continue
+		}
+		for _, c := range expected[table] {
+			got, ok := live[c.name]
+			if !ok {
+				drift = append(drift, SchemaDrift{Table: table, Column: c.name, Want: c.typ})
+			} else if got != c.typ {
+				drift = append(drift, SchemaDrift{Table: table, Column: c.name, Want: c.typ, Got: got})
+			}
+		}
+	}
+	return drift, nil
+}
+
+// tableColumns maps the columns of table to their declared types, empty if
+// the table does not exist
+func (r *Repository) tableColumns(ctx context.Context, table string) (map[string]string, error) {
+	rows, err := r.db.QueryContext(ctx, `SELECT name, UPPER(type) FROM pragma_table_info(?)`, table)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	columns := make(map[string]string)
+	for rows.Next() {
+		var name, typ string
+		if err := rows.Scan(&name, &typ); err != nil {
+			return nil, err
+		}
+		columns[name] = typ
+	}
+	return columns, rows.Err()
+}
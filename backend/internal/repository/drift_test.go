@@ -0,0 +1,65 @@
+package repository
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestCheckSchema(t *testing.T) {
+	r := newTestRepo(t)
+	ctx := context.Background()
+
+	if drift, err := r.CheckSchema(ctx); len(drift) != 0 || err != nil {
+		t.Fatalf("fresh database: %v, %v", drift, err)
+	}
+
+	// An audit log table created by hand, and no API key table
+	exec(t, r, `DROP TABLE audit_logs`)
+	exec(t, r, `CREATE TABLE audit_logs (id INTEGER PRIMARY KEY, actor_id TEXT, action TEXT, details TEXT, created_at datetime, note TEXT)`)
+	exec(t, r, `DROP TABLE api_keys`)
+
+	drift, err := r.CheckSchema(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []SchemaDrift{
+		{Table: "api_keys"},
+		{Table: "audit_logs", Column: "actor_id", Want: "INTEGER", Got: "TEXT"},
+		{Table: "audit_logs", Column: "entity_type", Want: "TEXT"},
+		{Table: "audit_logs", Column: "entity_id", Want: "TEXT"},
+	}
+	if !reflect.DeepEqual(drift, want) {
+		t.Fatalf("got %+v, want %+v", drift, want)
+	}
+	if msg := drift[2].String(); !strings.Contains(msg, "ALTER TABLE audit_logs ADD COLUMN entity_type TEXT") {
+		t.Errorf("missing column reported as %q", msg)
+	}
+
+	// Startup recreates missing tables, but has no migrations for the others
+	if err := r.InitSchema(); err != nil {
+		t.Fatal(err)
+	}
+	drift, _ = r.CheckSchema(ctx)
+	if !reflect.DeepEqual(drift, want[1:]) {
+		t.Errorf("after migrating, drift = %+v", drift)
+	}
+}
+
+func TestCheckSchemaAfterMigrations(t *testing.T) {
+	r := newTestRepo(t)
+
+	// A sanctions table from before entity types, aliases and retention
+	exec(t, r, `DROP VIEW customer_risk`)
+	exec(t, r, `DROP TABLE sanctions`)
+	exec(t, r, `CREATE TABLE sanctions (id INTEGER PRIMARY KEY AUTOINCREMENT, source TEXT NOT NULL, name TEXT NOT NULL,
+		dob TEXT, country TEXT, program TEXT, hash INTEGER NOT NULL, list_id INTEGER NOT NULL,
+		updated_at DATETIME DEFAULT CURRENT_TIMESTAMP, version INTEGER DEFAULT 1)`)
+	if err := r.InitSchema(); err != nil {
+		t.Fatal(err)
+	}
+	if drift, err := r.CheckSchema(context.Background()); len(drift) != 0 || err != nil {
+		t.Errorf("migrated database: %v, %v", drift, err)
+	}
+}
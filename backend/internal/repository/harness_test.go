@@ -0,0 +1,166 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/SanthoshCheemala/FLARE/backend/internal/models"
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// newTestRepo returns a repository over a fresh in-memory SQLite database
+// with the schema applied. The database is private to the test and dropped
+// when it ends. Its connections share one cache, so a query may run while
+// another's rows are still open, as it can against a file.
+func newTestRepo(t *testing.T) *Repository {
+	t.Helper()
+	name := strings.NewReplacer("/", "_", " ", "_").Replace(t.Name())
+	db, err := sql.Open("sqlite3", fmt.Sprintf("file:%s?mode=memory&cache=shared", name))
+	if err != nil {
+		t.Fatalf("open database: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	r := New(db)
+	if err := r.InitSchema(); err != nil {
+		t.Fatalf("initialize schema: %v", err)
+	}
+	return r
+}
+
+// fixture is a seeded database: a customer list, a sanctions list and a PEP
+// list, and a screening of the customers against both with one result per
+// match
+type fixture struct {
+	repo *Repository
+
+	customerListID int64
+	sanctionListID int64
+	pepListID      int64
+
+	customers []*models.Customer // Alice, Bob, Carol
+	sanctions []*models.Sanction // Alice on the sanctions list, Bob on the PEP list
+
+	screening *models.Screening
+	results   []*models.ScreeningResult // Alice, PENDING; Bob, CONFIRMED
+}
+
+// newFixture seeds a fresh test database
+func newFixture(t *testing.T) *fixture {
+	t.Helper()
+	f := &fixture{repo: newTestRepo(t)}
+
+	f.customerListID, f.customers = seedCustomers(t, f.repo, "customers",
+		models.Customer{ExternalID: "C1", Name: "Alice Smith", DOB: "1980-01-01", Country: "US", Hash: 101},
+		models.Customer{ExternalID: "C2", Name: "Bob Jones", DOB: "1975-05-05", Country: "GB", Hash: 102,
+			Attributes: map[string]string{"passport": "X123"}},
+		models.Customer{ExternalID: "C3", Name: "Carol White", DOB: "1990-09-09", Country: "FR", Hash: 103},
+	)
+	f.sanctionListID, f.sanctions = seedSanctions(t, f.repo, "OFAC SDN", models.ListCategorySanctions,
+		models.Sanction{Source: "OFAC", Name: "Alice Smith", DOB: "1980-01-01", Country: "US", Program: "SDGT", Hash: 101,
+			Aliases: []string{"A. Smith", "Alicia Smith"}, SourceRef: "https://sanctionssearch.ofac.treas.gov/Details.aspx?id=1"},
+	)
+	var peps []*models.Sanction
+	f.pepListID, peps = seedSanctions(t, f.repo, "PEP", models.ListCategoryPEP,
+		models.Sanction{Source: "EU", Name: "Bob Jones", DOB: "1975-05-05", Country: "GB", Program: "PEP", Hash: 102},
+	)
+	f.sanctions = append(f.sanctions, peps...)
+
+	f.screening = seedScreening(t, f.repo, "job-1", f.customerListID, f.sanctionListID, f.pepListID)
+	f.results = []*models.ScreeningResult{
+		seedResult(t, f.repo, f.screening.ID, f.customers[0], f.sanctions[0], 0.9, "PENDING"),
+		seedResult(t, f.repo, f.screening.ID, f.customers[1], f.sanctions[1], 0.8, "CONFIRMED"),
+	}
+	return f
+}
+
+// seedCustomers creates a customer list holding customers
+func seedCustomers(t *testing.T, r *Repository, name string, customers ...models.Customer) (int64, []*models.Customer) {
+	t.Helper()
+	ctx := context.Background()
+	listID, err := r.CreateCustomerList(ctx, name, "", "", 1)
+	if err != nil {
+		t.Fatalf("create customer list: %v", err)
+	}
+	seeded := make([]*models.Customer, len(customers))
+	for i := range customers {
+		c := customers[i]
+		c.ListID = listID
+		if err := r.CreateCustomer(ctx, &c); err != nil {
+			t.Fatalf("create customer %s: %v", c.ExternalID, err)
+		}
+		seeded[i] = &c
+	}
+	if err := r.UpdateCustomerListRecordCount(ctx, listID, len(customers)); err != nil {
+		t.Fatalf("count customers: %v", err)
+	}
+	return listID, seeded
+}
+
+// seedSanctions creates a sanction list of category holding sanctions
+func seedSanctions(t *testing.T, r *Repository, name, category string, sanctions ...models.Sanction) (int64, []*models.Sanction) {
+	t.Helper()
+	ctx := context.Background()
+	listID, err := r.CreateSanctionList(ctx, name, strings.Fields(name)[0], category, "", "")
+	if err != nil {
+		t.Fatalf("create sanction list: %v", err)
+	}
+	seeded := make([]*models.Sanction, len(sanctions))
+	for i := range sanctions {
+		s := sanctions[i]
+		s.ListID = listID
+		if err := r.CreateSanction(ctx, &s); err != nil {
+			t.Fatalf("create sanction %s: %v", s.Name, err)
+		}
+		seeded[i] = &s
+	}
+	if err := r.UpdateSanctionListCount(ctx, listID, len(sanctions)); err != nil {
+		t.Fatalf("count sanctions: %v", err)
+	}
+	return listID, seeded
+}
+
+// seedScreening creates a completed screening of a customer list
+func seedScreening(t *testing.T, r *Repository, jobID string, customerListID int64, sanctionListIDs ...int64) *models.Screening {
+	t.Helper()
+	s := &models.Screening{
+		JobID:           jobID,
+		Name:            "Screening " + jobID,
+		CustomerListID:  customerListID,
+		SanctionListIDs: sanctionListIDs,
+		Status:          "COMPLETED",
+		CreatedBy:       1,
+	}
+	if err := r.CreateScreening(context.Background(), s); err != nil {
+		t.Fatalf("create screening %s: %v", jobID, err)
+	}
+	return s
+}
+
+// seedResult records a match of a screening
+func seedResult(t *testing.T, r *Repository, screeningID int64, c *models.Customer, s *models.Sanction, score float64, status string) *models.ScreeningResult {
+	t.Helper()
+	sr := &models.ScreeningResult{
+		ScreeningID: screeningID,
+		CustomerID:  c.ID,
+		SanctionID:  s.ID,
+		MatchScore:  score,
+		Status:      status,
+	}
+	if err := r.CreateScreeningResult(context.Background(), sr); err != nil {
+		t.Fatalf("create result: %v", err)
+	}
+	return sr
+}
+
+// exec runs a statement against the test database directly, for state the
+// repository does not write, such as backdated timestamps
+func exec(t *testing.T, r *Repository, query string, args ...interface{}) {
+	t.Helper()
+	if _, err := r.db.DB.Exec(query, args...); err != nil {
+		t.Fatalf("exec %q: %v", query, err)
+	}
+}
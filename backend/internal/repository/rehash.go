@@ -0,0 +1,98 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/SanthoshCheemala/FLARE/backend/internal/models"
+)
+
+// CountStaleHashes returns the number of customers and sanctions whose
+// stored hash predates version
+func (r *Repository) CountStaleHashes(ctx context.Context, version int) (customers, sanctions int, err error) {
+	err = r.db.QueryRowContext(ctx, `SELECT
+		(SELECT COUNT(*) FROM customers WHERE COALESCE(hash_version, 0) < ?),
+		(SELECT COUNT(*) FROM sanctions WHERE COALESCE(hash_version, 0) < ?)`, version, version).Scan(&customers, &sanctions)
+	return customers, sanctions, err
+}
+
+// StaleCustomers returns up to limit customers with an ID above afterID
+// whose stored hash predates version, in ID order. Only the fields a record
+// hash reads are set.
+func (r *Repository) StaleCustomers(ctx context.Context, version int, afterID int64, limit int) ([]models.Customer, error) {
+	rows, err := r.db.QueryContext(ctx, `SELECT id, name, COALESCE(dob, ''), COALESCE(country, ''),
+		COALESCE(entity_type, 'individual'), COALESCE(registration, ''), COALESCE(imo_number, ''), COALESCE(attributes, '')
+		FROM customers WHERE COALESCE(hash_version, 0) < ? AND id > ? ORDER BY id LIMIT ?`, version, afterID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var customers []models.Customer
+	for rows.Next() {
+		var c models.Customer
+		var attributes string
+		if err := rows.Scan(&c.ID, &c.Name, &c.DOB, &c.Country, &c.EntityType, &c.Registration, &c.IMONumber, &attributes); err != nil {
+			return nil, err
+		}
+		decodeJSON(attributes, &c.Attributes)
+		customers = append(customers, c)
+	}
+	return customers, rows.Err()
+}
+
+// StaleSanctions is StaleCustomers for sanctions
+func (r *Repository) StaleSanctions(ctx context.Context, version int, afterID int64, limit int) ([]models.Sanction, error) {
+	rows, err := r.db.QueryContext(ctx, `SELECT id, name, COALESCE(dob, ''), COALESCE(country, ''), COALESCE(program, ''),
+		COALESCE(entity_type, 'individual'), COALESCE(registration, ''), COALESCE(imo_number, ''), COALESCE(attributes, '')
+		FROM sanctions WHERE COALESCE(hash_version, 0) < ? AND id > ? ORDER BY id LIMIT ?`, version, afterID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var sanctions []models.Sanction
+	for rows.Next() {
+		var s models.Sanction
+		var attributes string
+		if err := rows.Scan(&s.ID, &s.Name, &s.DOB, &s.Country, &s.Program, &s.EntityType, &s.Registration, &s.IMONumber, &attributes); err != nil {
+			return nil, err
+		}
+		decodeJSON(attributes, &s.Attributes)
+		sanctions = append(sanctions, s)
+	}
+	return sanctions, rows.Err()
+}
+
+// UpdateCustomerHashes stores recomputed hashes, keyed by customer ID, at
+// version, in one transaction
+func (r *Repository) UpdateCustomerHashes(ctx context.Context, hashes map[int64]int64, version int) error {
+	return r.updateHashes(ctx, "customers", hashes, version)
+}
+
+// UpdateSanctionHashes is UpdateCustomerHashes for sanctions
+func (r *Repository) UpdateSanctionHashes(ctx context.Context, hashes map[int64]int64, version int) error {
+	return r.updateHashes(ctx, "sanctions", hashes, version)
+}
+
+// updateHashes sets hash and hash_version of rows of table, which callers
+// name with a constant
+func (r *Repository) updateHashes(ctx context.Context, table string, hashes map[int64]int64, version int) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.PrepareContext(ctx, fmt.Sprintf(`UPDATE %s SET hash = ?, hash_version = ? WHERE id = ?`, table))
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+	for id, hash := range hashes {
+		if _, err := stmt.ExecContext(ctx, hash, version, id); err != nil {
+			return fmt.Errorf("update %s %d: %w", table, id, err)
+		}
+	}
+	return tx.Commit()
+}
@@ -0,0 +1,58 @@
+package repository
+
+import (
+	"context"
+	"testing"
+)
+
+func TestRehash(t *testing.T) {
+	f := newFixture(t)
+	ctx := context.Background()
+	exec(t, f.repo, `UPDATE customers SET hash_version = 1 WHERE id = ?`, f.customers[0].ID)
+
+	customers, sanctions, err := f.repo.CountStaleHashes(ctx, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if customers != 2 || sanctions != 2 {
+		t.Errorf("stale hashes = %d customers, %d sanctions; want 2, 2", customers, sanctions)
+	}
+
+	// Paged by ID
+	page, err := f.repo.StaleCustomers(ctx, 1, 0, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(page) != 1 || page[0].ID != f.customers[1].ID || page[0].Attributes["passport"] != "X123" {
+		t.Fatalf("first page = %+v", page)
+	}
+	page, _ = f.repo.StaleCustomers(ctx, 1, page[0].ID, 1)
+	if len(page) != 1 || page[0].ID != f.customers[2].ID {
+		t.Fatalf("second page = %+v", page)
+	}
+	if page, _ := f.repo.StaleCustomers(ctx, 1, page[0].ID, 1); len(page) != 0 {
+		t.Fatalf("page past the end = %+v", page)
+	}
+
+	stale, err := f.repo.StaleSanctions(ctx, 1, 0, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(stale) != 2 || stale[0].Program != "SDGT" {
+		t.Fatalf("stale sanctions = %+v", stale)
+	}
+
+	if err := f.repo.UpdateCustomerHashes(ctx, map[int64]int64{f.customers[1].ID: 902, f.customers[2].ID: 903}, 1); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.repo.UpdateSanctionHashes(ctx, map[int64]int64{stale[0].ID: 901, stale[1].ID: 902}, 1); err != nil {
+		t.Fatal(err)
+	}
+	if customers, sanctions, _ := f.repo.CountStaleHashes(ctx, 1); customers != 0 || sanctions != 0 {
+		t.Errorf("after rehash, %d customers and %d sanctions stale", customers, sanctions)
+	}
+	listed, _ := f.repo.GetCustomersByListID(ctx, f.customerListID)
+	if listed[1].Hash != 902 || listed[2].Hash != 903 {
+		t.Errorf("rehashed customers = %+v", listed)
+	}
+}
@@ -3,19 +3,28 @@ package repository
 import (
 	"context"
 	"database/sql"
+	"encoding/json"
 	"fmt"
+	"math"
 	"strings"
+	"time"
 
 	"github.com/SanthoshCheemala/FLARE/backend/internal/models"
 	_ "github.com/lib/pq"
 )
 
 type Repository struct {
-	db *sql.DB
+	db *timedDB
 }
 
 func New(db *sql.DB) *Repository {
-	return &Repository{db: db}
+	return &Repository{db: &timedDB{DB: db}}
+}
+
+// SetQueryTimeout bounds each statement run outside a transaction; 0 leaves
+// them unbounded
+func (r *Repository) SetQueryTimeout(d time.Duration) {
+	r.db.timeout = d
 }
 
 // Customer operations
@@ -38,11 +47,25 @@ func (r *Repository) UpdateCustomerListRecordCount(ctx context.Context, listID i
 	return err
 }
 
+// customerInsert and customerRow make up an INSERT of customers
+const (
+	customerInsert = `INSERT INTO customers (external_id, name, dob, country, hash, list_id, created_at, entity_type, registration, imo_number,
+		 attributes, hash_version)
+		 VALUES `
+	customerRow = `(?, ?, ?, ?, ?, ?, CURRENT_TIMESTAMP, ?, ?, ?, ?, ?)`
+)
+
+// customerArgs returns the customerRow parameters of c, defaulting its entity type
+func customerArgs(c *models.Customer) []interface{} {
+	if c.EntityType == "" {
+		c.EntityType = models.EntityIndividual
+	}
+	return []interface{}{c.ExternalID, c.Name, c.DOB, c.Country, c.Hash, c.ListID, c.EntityType, c.Registration, c.IMONumber,
+		encodeJSON(c.Attributes), c.HashVersion}
+}
+
 func (r *Repository) CreateCustomer(ctx context.Context, c *models.Customer) error {
-	res, err := r.db.ExecContext(ctx,
-		`INSERT INTO customers (external_id, name, dob, country, hash, list_id, created_at)
-		 VALUES (?, ?, ?, ?, ?, ?, CURRENT_TIMESTAMP)`,
-		c.ExternalID, c.Name, c.DOB, c.Country, c.Hash, c.ListID)
+	res, err := r.db.ExecContext(ctx, customerInsert+customerRow, customerArgs(c)...)
 	if err != nil {
 		return err
 	}
@@ -54,9 +77,18 @@ func (r *Repository) CreateCustomer(ctx context.Context, c *models.Customer) err
 	return nil
 }
 
+// CreateCustomers inserts customers in bulk, in one transaction. Their IDs
+// are not set; use CreateCustomer when they are needed.
+func (r *Repository) CreateCustomers(ctx context.Context, customers []*models.Customer) error {
+	return r.bulkInsert(ctx, customerInsert, customerRow, len(customers), func(i int) []interface{} {
+		return customerArgs(customers[i])
+	})
+}
+
 func (r *Repository) GetCustomersByListID(ctx context.Context, listID int64) ([]models.Customer, error) {
 	rows, err := r.db.QueryContext(ctx,
-		`SELECT id, external_id, name, dob, country, hash, list_id, created_at 
+		`SELECT id, external_id, name, dob, country, hash, list_id, created_at,
+		 COALESCE(entity_type, 'individual'), COALESCE(registration, ''), COALESCE(imo_number, ''), COALESCE(attributes, '')
 		 FROM customers WHERE list_id = ?`, listID)
 	if err != nil {
 		return nil, err
@@ -66,9 +98,12 @@ func (r *Repository) GetCustomersByListID(ctx context.Context, listID int64) ([]
 	customers := make([]models.Customer, 0)
 	for rows.Next() {
 		var c models.Customer
-		if err := rows.Scan(&c.ID, &c.ExternalID, &c.Name, &c.DOB, &c.Country, &c.Hash, &c.ListID, &c.CreatedAt); err != nil {
+		var attributes string
+		if err := rows.Scan(&c.ID, &c.ExternalID, &c.Name, &c.DOB, &c.Country, &c.Hash, &c.ListID, &c.CreatedAt,
+			&c.EntityType, &c.Registration, &c.IMONumber, &attributes); err != nil {
 			return nil, err
 		}
+		decodeJSON(attributes, &c.Attributes)
 		customers = append(customers, c)
 	}
 
@@ -136,22 +171,81 @@ func (r *Repository) DeleteCustomerList(ctx context.Context, listID int64) error
 
 // Sanction operations
 
-func (r *Repository) CreateSanctionList(ctx context.Context, name, source, description, filePath string) (int64, error) {
+// CreateSanctionList creates an empty list. An empty category means SANCTIONS.
+func (r *Repository) CreateSanctionList(ctx context.Context, name, source, category, description, filePath string) (int64, error) {
+	if category == "" {
+		category = models.ListCategorySanctions
+	}
 	res, err := r.db.ExecContext(ctx,
-		`INSERT INTO sanction_lists (name, source, description, file_path, version, created_at, updated_at)
-		 VALUES (?, ?, ?, ?, 1, CURRENT_TIMESTAMP, CURRENT_TIMESTAMP)`,
-		name, source, description, filePath)
+		`INSERT INTO sanction_lists (name, source, category, description, file_path, version, created_at, updated_at)
+		 VALUES (?, ?, ?, ?, ?, 1, CURRENT_TIMESTAMP, CURRENT_TIMESTAMP)`,
+		name, source, category, description, filePath)
 	if err != nil {
 		return 0, err
 	}
 	return res.LastInsertId()
 }
 
+// aliasSeparator joins sanction aliases in the aliases column
+const aliasSeparator = ";"
+
+func joinAliases(aliases []string) string {
+	return strings.Join(aliases, aliasSeparator)
+}
+
+func splitAliases(s string) []string {
+	if s == "" {
+		return nil
+	}
+	return strings.Split(s, aliasSeparator)
+}
+
+// sanctionInsert and sanctionRow make up an INSERT of sanctions
+const (
+	sanctionInsert = `INSERT INTO sanctions (source, name, dob, country, program, hash, list_id, updated_at, version,
+		 entity_type, aliases, imo_number, registration, category, attributes, hash_version, retention, valid_from, valid_to, source_ref)
+		 VALUES `
+	sanctionRow = `(?, ?, ?, ?, ?, ?, ?, CURRENT_TIMESTAMP, 1, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
+)
+
+// sanctionArgs returns the sanctionRow parameters of s, defaulting its entity
+// type and retention
+func sanctionArgs(s *models.Sanction) []interface{} {
+	if s.EntityType == "" {
+		s.EntityType = models.EntityIndividual
+	}
+	if s.Retention == "" {
+		s.Retention = models.RetentionFull
+	}
+	return []interface{}{s.Source, s.Name, s.DOB, s.Country, s.Program, s.Hash, s.ListID,
+		s.EntityType, joinAliases(s.Aliases), s.IMONumber, s.Registration, s.Category, encodeJSON(s.Attributes), s.HashVersion, s.Retention,
+		nullTimePtr(s.ValidFrom), nullTimePtr(s.ValidTo), s.SourceRef}
+}
+
+// encodeJSON stores v in a TEXT column; empty maps and slices are stored as ''
+func encodeJSON(v interface{}) string {
+	raw, err := json.Marshal(v)
+	if err != nil {
+		return ""
+	}
+	switch s := string(raw); s {
+	case "null", "{}", "[]":
+		return ""
+	default:
+		return s
+	}
+}
+
+// decodeJSON parses a TEXT column stored by encodeJSON into v, leaving v
+// unset for '' or unreadable values
+func decodeJSON(raw string, v interface{}) {
+	if raw != "" {
+		json.Unmarshal([]byte(raw), v)
+	}
+}
+
 func (r *Repository) CreateSanction(ctx context.Context, s *models.Sanction) error {
-	res, err := r.db.ExecContext(ctx,
-		`INSERT INTO sanctions (source, name, dob, country, program, hash, list_id, updated_at, version)
-		 VALUES (?, ?, ?, ?, ?, ?, ?, CURRENT_TIMESTAMP, 1)`,
-		s.Source, s.Name, s.DOB, s.Country, s.Program, s.Hash, s.ListID)
+	res, err := r.db.ExecContext(ctx, sanctionInsert+sanctionRow, sanctionArgs(s)...)
 	if err != nil {
 		return err
 	}
@@ -163,6 +257,58 @@ func (r *Repository) CreateSanction(ctx context.Context, s *models.Sanction) err
 	return nil
 }
 
+// CreateSanctions inserts sanctions in bulk, in one transaction. Their IDs
+// are not set; use CreateSanction when they are needed.
+func (r *Repository) CreateSanctions(ctx context.Context, sanctions []*models.Sanction) error {
+	return r.bulkInsert(ctx, sanctionInsert, sanctionRow, len(sanctions), func(i int) []interface{} {
+		return sanctionArgs(sanctions[i])
+	})
+}
+
+// bulkInsertRows is the number of rows per multi-row INSERT, keeping its
+// parameters under SQLite's default limit of 999
+const bulkInsertRows = 64
+
+// bulkInsert inserts n rows in one transaction, as multi-row INSERTs of
+// insert followed by row repeated. args returns the parameters of row i.
+// Full-size chunks share one prepared statement.
+func (r *Repository) bulkInsert(ctx context.Context, insert, row string, n int, args func(i int) []interface{}) error {
+	if n == 0 {
+		return nil
+	}
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var full *sql.Stmt
+	for start := 0; start < n; start += bulkInsertRows {
+		end := min(start+bulkInsertRows, n)
+		var values []interface{}
+		for i := start; i < end; i++ {
+			values = append(values, args(i)...)
+		}
+		query := insert + strings.TrimSuffix(strings.Repeat(row+", ", end-start), ", ")
+
+		if end-start < bulkInsertRows {
+			_, err = tx.ExecContext(ctx, query, values...)
+		} else {
+			if full == nil {
+				if full, err = tx.PrepareContext(ctx, query); err != nil {
+					return err
+				}
+				defer full.Close()
+			}
+			_, err = full.ExecContext(ctx, values...)
+		}
+		if err != nil {
+			return fmt.Errorf("insert rows %d-%d: %w", start, end-1, err)
+		}
+	}
+	return tx.Commit()
+}
+
 func (r *Repository) GetSanctionsByListIDs(ctx context.Context, listIDs []int64) ([]models.Sanction, error) {
 	if len(listIDs) == 0 {
 		return []models.Sanction{}, nil
@@ -175,8 +321,13 @@ func (r *Repository) GetSanctionsByListIDs(ctx context.Context, listIDs []int64)
 		args[i] = id
 	}
 
-	query := fmt.Sprintf(`SELECT id, source, name, dob, country, program, hash, list_id, updated_at, version 
-			  FROM sanctions WHERE list_id IN (%s)`, strings.Join(placeholders, ","))
+	// Entries take their list's category unless they carry their own, as
+	// resolved entries stored by clients do
+	query := fmt.Sprintf(`SELECT s.id, s.source, s.name, s.dob, s.country, s.program, s.hash, s.list_id, s.updated_at, s.version,
+			  COALESCE(s.entity_type, 'individual'), COALESCE(s.aliases, ''), COALESCE(s.imo_number, ''), COALESCE(s.registration, ''),
+			  COALESCE(NULLIF(s.category, ''), sl.category, 'SANCTIONS'), COALESCE(s.attributes, ''), s.valid_from, s.valid_to, COALESCE(s.source_ref, '')
+			  FROM sanctions s LEFT JOIN sanction_lists sl ON sl.id = s.list_id
+			  WHERE s.list_id IN (%s)`, strings.Join(placeholders, ","))
 	rows, err := r.db.QueryContext(ctx, query, args...)
 	if err != nil {
 		return nil, err
@@ -186,9 +337,20 @@ func (r *Repository) GetSanctionsByListIDs(ctx context.Context, listIDs []int64)
 	sanctions := make([]models.Sanction, 0)
 	for rows.Next() {
 		var s models.Sanction
-		if err := rows.Scan(&s.ID, &s.Source, &s.Name, &s.DOB, &s.Country, &s.Program, &s.Hash, &s.ListID, &s.UpdatedAt, &s.Version); err != nil {
+		var aliases, attributes string
+		var validFrom, validTo sql.NullTime
+		if err := rows.Scan(&s.ID, &s.Source, &s.Name, &s.DOB, &s.Country, &s.Program, &s.Hash, &s.ListID, &s.UpdatedAt, &s.Version,
+			&s.EntityType, &aliases, &s.IMONumber, &s.Registration, &s.Category, &attributes, &validFrom, &validTo, &s.SourceRef); err != nil {
 			return nil, err
 		}
+		s.Aliases = splitAliases(aliases)
+		decodeJSON(attributes, &s.Attributes)
+		if validFrom.Valid {
+			s.ValidFrom = &validFrom.Time
+		}
+		if validTo.Valid {
+			s.ValidTo = &validTo.Time
+		}
 		sanctions = append(sanctions, s)
 	}
 
@@ -211,7 +373,9 @@ func (r *Repository) GetSanctionSerializedStrings(ctx context.Context, listIDs [
 
 func (r *Repository) GetSanctionLists(ctx context.Context) ([]models.SanctionList, error) {
 	rows, err := r.db.QueryContext(ctx,
-		`SELECT id, name, source, description, file_path, record_count, version, updated_at, created_at FROM sanction_lists ORDER BY created_at DESC`)
+		`SELECT id, name, source, COALESCE(category, 'SANCTIONS'), description, file_path, record_count, version, updated_at, created_at,
+		 COALESCE(schema, ''), COALESCE(active, 1)
+		 FROM sanction_lists ORDER BY created_at DESC`)
 	if err != nil {
 		return nil, err
 	}
@@ -221,17 +385,150 @@ func (r *Repository) GetSanctionLists(ctx context.Context) ([]models.SanctionLis
 	for rows.Next() {
 		var l models.SanctionList
 		var filePath sql.NullString
-		if err := rows.Scan(&l.ID, &l.Name, &l.Source, &l.Description, &filePath, &l.RecordCount, &l.Version, &l.UpdatedAt, &l.CreatedAt); err != nil {
+		var schema string
+		if err := rows.Scan(&l.ID, &l.Name, &l.Source, &l.Category, &l.Description, &filePath, &l.RecordCount, &l.Version, &l.UpdatedAt, &l.CreatedAt, &schema, &l.Active); err != nil {
 			return nil, err
 		}
 		if filePath.Valid {
 			l.FilePath = filePath.String
 		}
+		decodeJSON(schema, &l.Schema)
 		lists = append(lists, l)
 	}
 	return lists, rows.Err()
 }
 
+// GetSanctionListIDsByCategory returns the IDs of all active lists in any
+// of the given categories
+func (r *Repository) GetSanctionListIDsByCategory(ctx context.Context, categories []string) ([]int64, error) {
+	if len(categories) == 0 {
+		return []int64{}, nil
+	}
+
+	placeholders := make([]string, len(categories))
+	args := make([]interface{}, len(categories))
+	for i, c := range categories {
+		placeholders[i] = "?"
+		args[i] = c
+	}
+
+	rows, err := r.db.QueryContext(ctx,
+		fmt.Sprintf(`SELECT id FROM sanction_lists WHERE COALESCE(category, 'SANCTIONS') IN (%s) AND COALESCE(active, 1) = 1 ORDER BY id`, strings.Join(placeholders, ",")),
+		args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	ids := make([]int64, 0)
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+// SanctionListFilter selects and orders a page of sanction lists.
+// Zero values mean no filter.
+type SanctionListFilter struct {
+	Query         string // Case-insensitive substring of name or description
+	Source        string
+	Category      string // One of the models.ListCategory* constants
+	CreatedAfter  time.Time
+	CreatedBefore time.Time
+	Sort          string // name, source, category, record_count, created_at or updated_at
+	Desc          bool
+	Limit         int
+	Offset        int
+}
+
+// sanctionListSortColumns whitelists the columns lists may be sorted by
+var sanctionListSortColumns = map[string]bool{
+	"name":         true,
+	"source":       true,
+	"category":     true,
+	"record_count": true,
+	"created_at":   true,
+	"updated_at":   true,
+}
+
+// ListSanctionLists returns a filtered page of sanction lists and the total
+// number of lists matching the filter
+func (r *Repository) ListSanctionLists(ctx context.Context, f SanctionListFilter) ([]models.SanctionList, int, error) {
+	var conditions []string
+	var args []interface{}
+	if f.Query != "" {
+		conditions = append(conditions, "(LOWER(name) LIKE ? OR LOWER(COALESCE(description, '')) LIKE ?)")
+		pattern := "%" + strings.ToLower(f.Query) + "%"
+		args = append(args, pattern, pattern)
+	}
+	if f.Source != "" {
+		conditions = append(conditions, "source = ?")
+		args = append(args, f.Source)
+	}
+	if f.Category != "" {
+		conditions = append(conditions, "COALESCE(category, 'SANCTIONS') = ?")
+		args = append(args, f.Category)
+	}
+	if !f.CreatedAfter.IsZero() {
+		conditions = append(conditions, "created_at >= ?")
+		args = append(args, f.CreatedAfter.UTC().Format("2006-01-02 15:04:05"))
+	}
+	if !f.CreatedBefore.IsZero() {
+		conditions = append(conditions, "created_at < ?")
+		args = append(args, f.CreatedBefore.UTC().Format("2006-01-02 15:04:05"))
+	}
+
+	where := ""
+	if len(conditions) > 0 {
+		where = " WHERE " + strings.Join(conditions, " AND ")
+	}
+
+	var total int
+	if err := r.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM sanction_lists"+where, args...).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	sortColumn := "created_at"
+	if sanctionListSortColumns[f.Sort] {
+		sortColumn = f.Sort
+	}
+	direction := "ASC"
+	if f.Desc {
+		direction = "DESC"
+	}
+
+	args = append(args, f.Limit, f.Offset)
+	rows, err := r.db.QueryContext(ctx,
+		`SELECT id, name, source, COALESCE(category, 'SANCTIONS'), description, file_path, record_count, version, updated_at, created_at,
+		 COALESCE(schema, ''), COALESCE(active, 1)
+		 FROM sanction_lists`+where+` ORDER BY `+sortColumn+` `+direction+`, id `+direction+` LIMIT ? OFFSET ?`,
+		args...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	lists := make([]models.SanctionList, 0)
+	for rows.Next() {
+		var l models.SanctionList
+		var filePath sql.NullString
+		var schema string
+		if err := rows.Scan(&l.ID, &l.Name, &l.Source, &l.Category, &l.Description, &filePath, &l.RecordCount, &l.Version, &l.UpdatedAt, &l.CreatedAt, &schema, &l.Active); err != nil {
+			return nil, 0, err
+		}
+		if filePath.Valid {
+			l.FilePath = filePath.String
+		}
+		decodeJSON(schema, &l.Schema)
+		lists = append(lists, l)
+	}
+	return lists, total, rows.Err()
+}
+
 func (r *Repository) DeleteSanctionList(ctx context.Context, listID int64) error {
 	tx, err := r.db.BeginTx(ctx, nil)
 	if err != nil {
@@ -245,6 +542,12 @@ func (r *Repository) DeleteSanctionList(ctx context.Context, listID int64) error
 		return err
 	}
 
+	// Drop the list from any packs bundling it
+	_, err = tx.ExecContext(ctx, "DELETE FROM watchlist_pack_lists WHERE list_id = ?", listID)
+	if err != nil {
+		return err
+	}
+
 	// Delete the list
 	_, err = tx.ExecContext(ctx, "DELETE FROM sanction_lists WHERE id = ?", listID)
 	if err != nil {
@@ -254,6 +557,18 @@ func (r *Repository) DeleteSanctionList(ctx context.Context, listID int64) error
 	return tx.Commit()
 }
 
+// DeleteWatchlist deletes a watchlist hosted by a client backend. Its
+// entries are read from the list's file; those matched by screenings were
+// saved with their results and stay.
+func (r *Repository) DeleteWatchlist(ctx context.Context, listID int64) (bool, error) {
+	res, err := r.db.ExecContext(ctx, "DELETE FROM sanction_lists WHERE id = ?", listID)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
+
 // Screening operations
 
 func (r *Repository) CreateScreening(ctx context.Context, s *models.Screening) error {
@@ -268,13 +583,23 @@ func (r *Repository) CreateScreening(ctx context.Context, s *models.Screening) e
 	} else {
 		sanctionIDsStr = ""
 	}
+	source := s.ListSource
+	if source == "" {
+		source = models.ListSourceRemote
+	}
+	watchlistIDs := make([]string, len(s.WatchlistIDs))
+	for i, id := range s.WatchlistIDs {
+		watchlistIDs[i] = fmt.Sprintf("%d", id)
+	}
 
 	res, err := r.db.ExecContext(ctx,
-		`INSERT INTO screenings (job_id, name, customer_list_id, sanction_list_ids, status, 
-		 customer_count, sanction_count, worker_count, memory_estimate_mb, created_by, created_at)
-		 VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, CURRENT_TIMESTAMP)`,
-		s.JobID, s.Name, s.CustomerListID, sanctionIDsStr, s.Status,
-		s.CustomerCount, s.SanctionCount, s.WorkerCount, s.MemoryEstimateMB, s.CreatedBy)
+		`INSERT INTO screenings (job_id, name, customer_list_id, sanction_list_ids, list_source, watchlist_ids, status, 
+		 customer_count, sanction_count, worker_count, memory_estimate_mb, memory_limit_gb, created_by,
+		 idempotency_scope, idempotency_key, request_hash, created_at)
+		 VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, CURRENT_TIMESTAMP)`,
+		s.JobID, s.Name, s.CustomerListID, sanctionIDsStr, source, strings.Join(watchlistIDs, ","), s.Status,
+		s.CustomerCount, s.SanctionCount, s.WorkerCount, s.MemoryEstimateMB, s.MemoryLimitGB, s.CreatedBy,
+		s.IdempotencyScope, s.IdempotencyKey, s.RequestHash)
 	if err != nil {
 		return err
 	}
@@ -293,282 +618,1989 @@ func (r *Repository) UpdateScreeningStatus(ctx context.Context, jobID, status st
 	return err
 }
 
-// Screening result operations
+// UpdateScreeningFinal persists the final snapshot of a finished job
+func (r *Repository) UpdateScreeningFinal(ctx context.Context, s *models.Screening) error {
+	_, err := r.db.ExecContext(ctx,
+		`UPDATE screenings SET status = ?, match_count = ?, customer_count = ?, sanction_count = ?,
+		 worker_count = ?, memory_estimate_mb = ?, memory_limit_gb = ?, started_at = ?, finished_at = ?, error = ?
+		 WHERE job_id = ?`,
+		s.Status, s.MatchCount, s.CustomerCount, s.SanctionCount,
+		s.WorkerCount, s.MemoryEstimateMB, s.MemoryLimitGB, nullTime(s.StartedAt), nullTime(s.FinishedAt), s.Error, s.JobID)
+	return err
+}
 
-func (r *Repository) CreateScreeningResult(ctx context.Context, sr *models.ScreeningResult) error {
-	res, err := r.db.ExecContext(ctx,
-		`INSERT INTO screening_results (screening_id, customer_id, sanction_id, match_score, status, created_at, updated_at)
-		 VALUES (?, ?, ?, ?, ?, CURRENT_TIMESTAMP, CURRENT_TIMESTAMP)`,
-		sr.ScreeningID, sr.CustomerID, sr.SanctionID, sr.MatchScore, sr.Status)
-	if err != nil {
-		return err
+const screeningColumns = `id, job_id, name, customer_list_id, sanction_list_ids, COALESCE(list_source, 'REMOTE'), COALESCE(watchlist_ids, ''), status, match_count,
+	customer_count, sanction_count, worker_count, memory_estimate_mb, COALESCE(memory_limit_gb, 0), started_at, finished_at,
+	COALESCE(error, ''), created_by, created_at, COALESCE(idempotency_key, ''), COALESCE(request_hash, '')`
+
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanScreening(row rowScanner) (*models.Screening, error) {
+	var s models.Screening
+	var sanctionIDs, watchlistIDs string
+	var startedAt, finishedAt sql.NullTime
+	if err := row.Scan(&s.ID, &s.JobID, &s.Name, &s.CustomerListID, &sanctionIDs, &s.ListSource, &watchlistIDs, &s.Status, &s.MatchCount,
+		&s.CustomerCount, &s.SanctionCount, &s.WorkerCount, &s.MemoryEstimateMB, &s.MemoryLimitGB, &startedAt, &finishedAt,
+		&s.Error, &s.CreatedBy, &s.CreatedAt, &s.IdempotencyKey, &s.RequestHash); err != nil {
+		return nil, err
 	}
-	id, err := res.LastInsertId()
-	if err != nil {
-		return err
+	if startedAt.Valid {
+		s.StartedAt = startedAt.Time
 	}
-	sr.ID = id
-	return nil
+	if finishedAt.Valid {
+		s.FinishedAt = finishedAt.Time
+	}
+	s.SanctionListIDs = parseIDList(sanctionIDs)
+	s.WatchlistIDs = parseIDList(watchlistIDs)
+	return &s, nil
 }
 
-// UpdateResultStatus updates the status of a screening result
-func (r *Repository) UpdateResultStatus(ctx context.Context, resultID int64, status string) error {
-	_, err := r.db.ExecContext(ctx,
-		`UPDATE screening_results SET status = ?, updated_at = CURRENT_TIMESTAMP WHERE id = ?`,
-		status, resultID)
-	return err
+// GetScreeningByJobID returns the stored screening for a job, or nil if none exists
+func (r *Repository) GetScreeningByJobID(ctx context.Context, jobID string) (*models.Screening, error) {
+	s, err := scanScreening(r.db.QueryRowContext(ctx,
+		`SELECT `+screeningColumns+` FROM screenings WHERE job_id = ?`, jobID))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return s, err
 }
 
-// Audit log operations
-
-func (r *Repository) CreateAuditLog(ctx context.Context, log *models.AuditLog) error {
-	_, err := r.db.ExecContext(ctx,
-		`INSERT INTO audit_logs (actor_id, action, entity_type, entity_id, details, created_at)
-		 VALUES (?, ?, ?, ?, ?, CURRENT_TIMESTAMP)`,
-		log.ActorID, log.Action, log.EntityType, log.EntityID, log.Details)
-	return err
+// GetScreeningByIdempotencyKey returns the latest screening started with
+// key in scope at or after since, or nil if there is none. A key sent in
+// another scope never matches.
+func (r *Repository) GetScreeningByIdempotencyKey(ctx context.Context, scope, key string, since time.Time) (*models.Screening, error) {
+	s, err := scanScreening(r.db.QueryRowContext(ctx,
+		`SELECT `+screeningColumns+` FROM screenings WHERE idempotency_scope = ? AND idempotency_key = ? AND created_at >= ?
+		 ORDER BY created_at DESC, id DESC LIMIT 1`,
+		scope, key, since.UTC().Format("2006-01-02 15:04:05")))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return s, err
 }
 
-func (r *Repository) GetScreeningResults(ctx context.Context, screeningID int64, limit, offset int) ([]models.ScreeningResultDetail, int, error) {
-	// Get total count
+// ListScreenings returns screenings newest first, optionally filtered by status,
+// along with the total number matching the filter
+func (r *Repository) ListScreenings(ctx context.Context, statuses []string, limit, offset int) ([]models.Screening, int, error) {
+	where := ""
+	args := make([]interface{}, 0, len(statuses)+2)
+	if len(statuses) > 0 {
+		placeholders := make([]string, len(statuses))
+		for i, status := range statuses {
+			placeholders[i] = "?"
+			args = append(args, status)
+		}
+		where = " WHERE status IN (" + strings.Join(placeholders, ",") + ")"
+	}
+
 	var total int
-	err := r.db.QueryRowContext(ctx,
-		`SELECT COUNT(*) FROM screening_results WHERE screening_id = ?`, screeningID).Scan(&total)
-	if err != nil {
+	if err := r.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM screenings"+where, args...).Scan(&total); err != nil {
 		return nil, 0, err
 	}
 
-	// Get paginated results with joins
+	args = append(args, limit, offset)
 	rows, err := r.db.QueryContext(ctx,
-		`SELECT sr.id, sr.screening_id, sr.customer_id, sr.sanction_id, sr.match_score, sr.status,
-		        sr.investigator_id, sr.notes, sr.created_at, sr.updated_at,
-		        c.id, c.external_id, c.name, c.dob, c.country, c.hash, c.list_id, c.created_at,
-		        s.id, s.source, s.name, s.dob, s.country, s.program, s.hash, s.list_id, s.updated_at, s.version
-		 FROM screening_results sr
-		 JOIN customers c ON sr.customer_id = c.id
-		 JOIN sanctions s ON sr.sanction_id = s.id
-		 WHERE sr.screening_id = ?
-		 ORDER BY sr.match_score DESC, sr.created_at DESC
-		 LIMIT ? OFFSET ?`,
-		screeningID, limit, offset)
+		`SELECT `+screeningColumns+` FROM screenings`+where+` ORDER BY created_at DESC, id DESC LIMIT ? OFFSET ?`,
+		args...)
 	if err != nil {
 		return nil, 0, err
 	}
 	defer rows.Close()
 
-	results := make([]models.ScreeningResultDetail, 0)
+	screenings := make([]models.Screening, 0)
 	for rows.Next() {
-		var r models.ScreeningResultDetail
-		err := rows.Scan(
-			&r.ID, &r.ScreeningID, &r.CustomerID, &r.SanctionID, &r.MatchScore, &r.Status,
-			&r.InvestigatorID, &r.Notes, &r.CreatedAt, &r.UpdatedAt,
-			&r.Customer.ID, &r.Customer.ExternalID, &r.Customer.Name, &r.Customer.DOB,
-			&r.Customer.Country, &r.Customer.Hash, &r.Customer.ListID, &r.Customer.CreatedAt,
-			&r.Sanction.ID, &r.Sanction.Source, &r.Sanction.Name, &r.Sanction.DOB,
-			&r.Sanction.Country, &r.Sanction.Program, &r.Sanction.Hash, &r.Sanction.ListID,
-			&r.Sanction.UpdatedAt, &r.Sanction.Version,
-		)
+		s, err := scanScreening(rows)
 		if err != nil {
 			return nil, 0, err
 		}
-		results = append(results, r)
+		screenings = append(screenings, *s)
 	}
-
-	return results, total, rows.Err()
+	return screenings, total, rows.Err()
 }
 
-//  GetScreeningResultsByJobID gets results by job_id instead of screening_id
-func (r *Repository) GetScreeningResultsByJobID(ctx context.Context, jobID string, limit, offset int) ([]models.ScreeningResultDetail, error) {
-	rows, err := r.db.QueryContext(ctx,
-		`SELECT sr.id, sr.screening_id, sr.customer_id, sr.sanction_id, sr.match_score, sr.status,
-		        sr.investigator_id, COALESCE(sr.notes, ''), sr.created_at, sr.updated_at,
-		        c.id, c.external_id, c.name, c.dob, c.country, c.hash, c.list_id, c.created_at,
-		        s.id, s.source, s.name, s.dob, s.country, s.program, s.hash, s.list_id, s.updated_at, s.version
-		 FROM screening_results sr
-		 JOIN screenings sc ON sr.screening_id = sc.id
-		 JOIN customers c ON sr.customer_id = c.id
-		 JOIN sanctions s ON sr.sanction_id = s.id
-		 WHERE sc.job_id = ?
-		 ORDER BY sr.match_score DESC, sr.created_at DESC
-		 LIMIT ? OFFSET ?`,
-		jobID, limit, offset)
-	if err != nil {
-		return nil, err
-	}
-	defer rows.Close()
-
-	results := make([]models.ScreeningResultDetail, 0)
-	for rows.Next() {
-		var r models.ScreeningResultDetail
-		err := rows.Scan(
-			&r.ID, &r.ScreeningID, &r.CustomerID, &r.SanctionID, &r.MatchScore, &r.Status,
-			&r.InvestigatorID, &r.Notes, &r.CreatedAt, &r.UpdatedAt,
-			&r.Customer.ID, &r.Customer.ExternalID, &r.Customer.Name, &r.Customer.DOB,
-			&r.Customer.Country, &r.Customer.Hash, &r.Customer.ListID, &r.Customer.CreatedAt,
-			&r.Sanction.ID, &r.Sanction.Source, &r.Sanction.Name, &r.Sanction.DOB,
-			&r.Sanction.Country, &r.Sanction.Program, &r.Sanction.Hash, &r.Sanction.ListID,
-			&r.Sanction.UpdatedAt, &r.Sanction.Version,
-		)
-		if err != nil {
-			return nil, err
+// parseIDList parses the comma-separated ID lists stored in sanction_list_ids
+// and watchlist_ids
+func parseIDList(s string) []int64 {
+	ids := make([]int64, 0)
+	for _, part := range strings.Split(s, ",") {
+		var id int64
+		if _, err := fmt.Sscanf(strings.TrimSpace(part), "%d", &id); err == nil {
+			ids = append(ids, id)
 		}
-		results = append(results, r)
 	}
+	return ids
+}
 
-	return results, rows.Err()
+func nullTime(t time.Time) sql.NullTime {
+	return sql.NullTime{Time: t, Valid: !t.IsZero()}
 }
 
-// CountScreeningResultsByJobID counts results for a job
-func (r *Repository) CountScreeningResultsByJobID(ctx context.Context, jobID string) (int64, error) {
-	var count int64
-	err := r.db.QueryRowContext(ctx,
-		`SELECT COUNT(*) FROM screening_results sr
-		 JOIN screenings sc ON sr.screening_id = sc.id
-		 WHERE sc.job_id = ?`, jobID).Scan(&count)
-	return count, err
+// nullTimePtr stores an optional time, NULL when unset
+func nullTimePtr(t *time.Time) sql.NullTime {
+	if t == nil {
+		return sql.NullTime{}
+	}
+	return nullTime(*t)
 }
 
-// User operations
+// Screening match operations
 
-func (r *Repository) GetUserByEmail(ctx context.Context, email string) (*models.User, error) {
-	var u models.User
-	var twoFactorSecret sql.NullString
-	var lastLoginAt sql.NullTime
+// SaveScreeningMatches stores the raw match hashes of a screening
+func (r *Repository) SaveScreeningMatches(ctx context.Context, m *models.ScreeningMatches) error {
+	hashes, err := json.Marshal(m.Hashes)
+	if err != nil {
+		return err
+	}
+	mapping, err := json.Marshal(m.ColumnMapping)
+	if err != nil {
+		return err
+	}
+	_, err = r.db.ExecContext(ctx,
+		`INSERT OR REPLACE INTO screening_matches (screening_id, session_id, hashes, hash_algorithm, hash_version, hash_salt,
+		 column_mapping, enabled_columns, transliteration, resolved, created_at)
+		 VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, 0, CURRENT_TIMESTAMP)`,
+		m.ScreeningID, m.SessionID, string(hashes), m.HashAlgorithm, m.HashVersion, m.HashSalt,
+		string(mapping), strings.Join(m.EnabledColumns, ","), strings.Join(m.Transliteration, ","))
+	return err
+}
 
+// GetScreeningMatches returns the match hashes of a screening, or nil if none were saved
+func (r *Repository) GetScreeningMatches(ctx context.Context, screeningID int64) (*models.ScreeningMatches, error) {
+	var m models.ScreeningMatches
+	var hashes, mapping, columns, scripts string
 	err := r.db.QueryRowContext(ctx,
-		`SELECT id, email, password_hash, role, two_factor_secret, active, last_login_at, created_at, updated_at
-		 FROM users WHERE email = ?`, email).Scan(
-		&u.ID, &u.Email, &u.PasswordHash, &u.Role, &twoFactorSecret, &u.Active, &lastLoginAt, &u.CreatedAt, &u.UpdatedAt)
-
+		`SELECT screening_id, session_id, hashes, COALESCE(hash_algorithm, ''), COALESCE(hash_version, 0), hash_salt,
+		 COALESCE(column_mapping, ''), COALESCE(enabled_columns, ''), COALESCE(transliteration, ''), resolved, created_at
+		 FROM screening_matches WHERE screening_id = ?`, screeningID).
+		Scan(&m.ScreeningID, &m.SessionID, &hashes, &m.HashAlgorithm, &m.HashVersion, &m.HashSalt,
+			&mapping, &columns, &scripts, &m.Resolved, &m.CreatedAt)
 	if err == sql.ErrNoRows {
 		return nil, nil
 	}
 	if err != nil {
-		fmt.Printf("DB Error in GetUserByEmail: %v\n", err)
 		return nil, err
 	}
-
-	if twoFactorSecret.Valid {
-		u.TwoFactorSecret = twoFactorSecret.String
+	if err := json.Unmarshal([]byte(hashes), &m.Hashes); err != nil {
+		return nil, fmt.Errorf("decode match hashes: %w", err)
 	}
-	if lastLoginAt.Valid {
-		t := lastLoginAt.Time
-		u.LastLoginAt = &t
+	if mapping != "" {
+		if err := json.Unmarshal([]byte(mapping), &m.ColumnMapping); err != nil {
+			return nil, fmt.Errorf("decode column mapping: %w", err)
+		}
 	}
-
-	return &u, nil
+	if columns != "" {
+		m.EnabledColumns = strings.Split(columns, ",")
+	}
+	if scripts != "" {
+		m.Transliteration = strings.Split(scripts, ",")
+	}
+	return &m, nil
 }
 
-func (r *Repository) UpdateSanctionListCount(ctx context.Context, listID int64, count int) error {
-	query := `UPDATE sanction_lists SET record_count = $1, updated_at = CURRENT_TIMESTAMP WHERE id = $2`
-	_, err := r.db.ExecContext(ctx, query, count, listID)
-	return err
+// Screening result operations
+
+// SaveScreeningResults replaces the results of a screening with records in
+// one transaction, inserting each customer and sanction not yet stored, and
+// marks the screening's match hashes resolved. Either every record is saved
+// or none is: on error the IDs assigned to records are reset.
+func (r *Repository) SaveScreeningResults(ctx context.Context, screeningID int64, records []models.MatchRecord) (err error) {
+	var customers []*models.Customer
+	var sanctions []*models.Sanction
+	var results []*models.ScreeningResult
+	defer func() {
+		if err == nil {
+			return
+		}
+		for _, c := range customers {
+			c.ID = 0
+		}
+		for _, s := range sanctions {
+			s.ID = 0
+		}
+		for _, sr := range results {
+			sr.ID = 0
+		}
+	}()
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err = tx.ExecContext(ctx, `DELETE FROM screening_results WHERE screening_id = ?`, screeningID); err != nil {
+		return err
+	}
+
+	customerStmt, err := tx.PrepareContext(ctx, customerInsert+customerRow)
+	if err != nil {
+		return err
+	}
+	defer customerStmt.Close()
+	sanctionStmt, err := tx.PrepareContext(ctx, sanctionInsert+sanctionRow)
+	if err != nil {
+		return err
+	}
+	defer sanctionStmt.Close()
+	resultStmt, err := tx.PrepareContext(ctx,
+		`INSERT INTO screening_results (screening_id, customer_id, sanction_id, match_score, status, explanation, suppression_id,
+		 list_source, lists, match_channel, created_at, updated_at)
+		 VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, CURRENT_TIMESTAMP, CURRENT_TIMESTAMP)`)
+	if err != nil {
+		return err
+	}
+	defer resultStmt.Close()
+
+	for _, rec := range records {
+		c, s, sr := rec.Customer, rec.Sanction, rec.Result
+		// A customer or sanction in several matches is inserted once
+		if c.ID == 0 {
+			if c.ID, err = insertID(ctx, customerStmt, customerArgs(c)...); err != nil {
+				return fmt.Errorf("insert customer %s: %w", c.ExternalID, err)
+			}
+			customers = append(customers, c)
+		}
+		if s.ID == 0 {
+			if s.ID, err = insertID(ctx, sanctionStmt, sanctionArgs(s)...); err != nil {
+				return fmt.Errorf("insert sanction %s: %w", s.Name, err)
+			}
+			sanctions = append(sanctions, s)
+		}
+
+		var explanation []byte
+		if sr.Explanation != nil {
+			if explanation, err = json.Marshal(sr.Explanation); err != nil {
+				return fmt.Errorf("encode match explanation: %w", err)
+			}
+		}
+		var lists []byte
+		if len(sr.Lists) > 0 {
+			if lists, err = json.Marshal(sr.Lists); err != nil {
+				return fmt.Errorf("encode list memberships: %w", err)
+			}
+		}
+		sr.ScreeningID, sr.CustomerID, sr.SanctionID = screeningID, c.ID, s.ID
+		if sr.ID, err = insertID(ctx, resultStmt,
+			sr.ScreeningID, sr.CustomerID, sr.SanctionID, sr.MatchScore, sr.Status, string(explanation), sr.SuppressionID,
+			resultListSource(sr), string(lists), resultChannel(sr)); err != nil {
+			return fmt.Errorf("insert result: %w", err)
+		}
+		results = append(results, sr)
+	}
+
+	if _, err = tx.ExecContext(ctx,
+		`UPDATE screening_matches SET resolved = 1 WHERE screening_id = ?`, screeningID); err != nil {
+		return err
+	}
+	return tx.Commit()
 }
 
-func (r *Repository) UpdateUserLastLogin(ctx context.Context, userID int64) error {
+// resultListSource is the list source stored with a result; results whose
+// source is not set come from the PSI server
+func resultListSource(sr *models.ScreeningResult) string {
+	if sr.ListSource == "" {
+		return models.ListSourceRemote
+	}
+	return sr.ListSource
+}
+
+// resultChannel is the match channel stored with a result; results whose
+// channel is not set matched on names
+func resultChannel(sr *models.ScreeningResult) string {
+	if sr.Channel == "" {
+		return models.MatchChannelName
+	}
+	return sr.Channel
+}
+
+// insertID runs a prepared INSERT and returns the new row's ID
+func insertID(ctx context.Context, stmt *sql.Stmt, args ...interface{}) (int64, error) {
+	res, err := stmt.ExecContext(ctx, args...)
+	if err != nil {
+		return 0, err
+	}
+	return res.LastInsertId()
+}
+
+func (r *Repository) CreateScreeningResult(ctx context.Context, sr *models.ScreeningResult) error {
+	var explanation []byte
+	if sr.Explanation != nil {
+		var err error
+		if explanation, err = json.Marshal(sr.Explanation); err != nil {
+			return fmt.Errorf("encode match explanation: %w", err)
+		}
+	}
+	var lists []byte
+	if len(sr.Lists) > 0 {
+		var err error
+		if lists, err = json.Marshal(sr.Lists); err != nil {
+			return fmt.Errorf("encode list memberships: %w", err)
+		}
+	}
+	res, err := r.db.ExecContext(ctx,
+		`INSERT INTO screening_results (screening_id, customer_id, sanction_id, match_score, status, explanation, suppression_id,
+		 list_source, lists, match_channel, created_at, updated_at)
+		 VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, CURRENT_TIMESTAMP, CURRENT_TIMESTAMP)`,
+		sr.ScreeningID, sr.CustomerID, sr.SanctionID, sr.MatchScore, sr.Status, string(explanation), sr.SuppressionID,
+		resultListSource(sr), string(lists), resultChannel(sr))
+	if err != nil {
+		return err
+	}
+	id, err := res.LastInsertId()
+	if err != nil {
+		return err
+	}
+	sr.ID = id
+	return nil
+}
+
+// decodeExplanation parses a stored match explanation. Results recorded
+// before explanations existed, or with an unreadable one, have none.
+func decodeExplanation(raw string) *models.MatchExplanation {
+	if raw == "" {
+		return nil
+	}
+	var e models.MatchExplanation
+	if err := json.Unmarshal([]byte(raw), &e); err != nil {
+		return nil
+	}
+	return &e
+}
+
+// decodeLists parses the stored list memberships of a result. Results
+// recorded before memberships existed, or with unreadable ones, have none.
+func decodeLists(raw string) []models.ListMembership {
+	if raw == "" {
+		return nil
+	}
+	var lists []models.ListMembership
+	if err := json.Unmarshal([]byte(raw), &lists); err != nil {
+		return nil
+	}
+	return lists
+}
+
+// UpdateResultStatus sets the status of a screening result and who proposed
+// it, clearing any earlier approval
+func (r *Repository) UpdateResultStatus(ctx context.Context, resultID int64, status, proposedBy string) error {
 	_, err := r.db.ExecContext(ctx,
-		`UPDATE users SET last_login_at = CURRENT_TIMESTAMP WHERE id = ?`, userID)
+		`UPDATE screening_results
+		 SET status = ?, proposed_by = ?, approved_by = '', approved_at = NULL, updated_at = CURRENT_TIMESTAMP
+		 WHERE id = ?`,
+		status, proposedBy, resultID)
 	return err
 }
 
-// ResolveMatches maps hashes back to customer and sanction records
-func (r *Repository) ResolveMatches(ctx context.Context, hashes []int64, customerListID int64, sanctionListIDs []int64) ([]struct {
-	Customer models.Customer
-	Sanction models.Sanction
-}, error) {
-	// Create hash map for efficient lookup
-	hashMap := make(map[int64]bool)
-	for _, h := range hashes {
-		hashMap[h] = true
+// DecideResultApproval approves a result awaiting four-eyes approval, making
+// it CONFIRMED, or rejects it back to PENDING. It returns false if the result
+// is not awaiting approval or approver proposed it.
+func (r *Repository) DecideResultApproval(ctx context.Context, resultID int64, approver string, approve bool) (bool, error) {
+	query := `UPDATE screening_results
+		 SET status = 'CONFIRMED', approved_by = ?, approved_at = CURRENT_TIMESTAMP, updated_at = CURRENT_TIMESTAMP
+		 WHERE id = ? AND status = 'PENDING_APPROVAL' AND proposed_by != ?`
+	args := []interface{}{approver, resultID, approver}
+	if !approve {
+		query = `UPDATE screening_results
+		 SET status = 'PENDING', proposed_by = '', updated_at = CURRENT_TIMESTAMP
+		 WHERE id = ? AND status = 'PENDING_APPROVAL' AND proposed_by != ?`
+		args = args[1:]
 	}
+	res, err := r.db.ExecContext(ctx, query, args...)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
 
-	// Get matching customers
-	customers, err := r.GetCustomersByListID(ctx, customerListID)
+// Result comment operations
+
+// CreateResultComment stores a comment together with its attachments, whose
+// files must already be written
+func (r *Repository) CreateResultComment(ctx context.Context, c *models.ResultComment) error {
+	tx, err := r.db.BeginTx(ctx, nil)
 	if err != nil {
-		return nil, err
+		return err
 	}
-	customerMap := make(map[int64]models.Customer)
-	for _, c := range customers {
-		if hashMap[c.Hash] {
-			customerMap[c.Hash] = c
+	defer tx.Rollback()
+
+	res, err := tx.ExecContext(ctx,
+		`INSERT INTO result_comments (result_id, parent_id, author_id, author, body, created_at)
+		 VALUES (?, ?, ?, ?, ?, CURRENT_TIMESTAMP)`,
+		c.ResultID, c.ParentID, c.AuthorID, c.Author, c.Body)
+	if err != nil {
+		return err
+	}
+	if c.ID, err = res.LastInsertId(); err != nil {
+		return err
+	}
+
+	for i := range c.Attachments {
+		a := &c.Attachments[i]
+		a.CommentID = c.ID
+		res, err := tx.ExecContext(ctx,
+			`INSERT INTO comment_attachments (comment_id, file_name, content_type, size, sha256, file_path, created_at)
+			 VALUES (?, ?, ?, ?, ?, ?, CURRENT_TIMESTAMP)`,
+			a.CommentID, a.FileName, a.ContentType, a.Size, a.SHA256, a.FilePath)
+		if err != nil {
+			return err
+		}
+		if a.ID, err = res.LastInsertId(); err != nil {
+			return err
 		}
 	}
+	return tx.Commit()
+}
 
-	// Get matching sanctions
-	sanctions, err := r.GetSanctionsByListIDs(ctx, sanctionListIDs)
+// GetResultComment returns a comment without its attachments, or nil if not found
+func (r *Repository) GetResultComment(ctx context.Context, id int64) (*models.ResultComment, error) {
+	var c models.ResultComment
+	var parentID sql.NullInt64
+	err := r.db.QueryRowContext(ctx,
+		`SELECT id, result_id, parent_id, COALESCE(author_id, 0), author, body, created_at
+		 FROM result_comments WHERE id = ?`, id).Scan(
+		&c.ID, &c.ResultID, &parentID, &c.AuthorID, &c.Author, &c.Body, &c.CreatedAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
 	if err != nil {
 		return nil, err
 	}
-	sanctionMap := make(map[int64]models.Sanction)
-	for _, s := range sanctions {
-		if hashMap[s.Hash] {
-			sanctionMap[s.Hash] = s
+	if parentID.Valid {
+		c.ParentID = &parentID.Int64
+	}
+	return &c, nil
+}
+
+// ListResultComments returns a result's comments with their attachments,
+// oldest first
+func (r *Repository) ListResultComments(ctx context.Context, resultID int64) ([]models.ResultComment, error) {
+	rows, err := r.db.QueryContext(ctx,
+		`SELECT id, result_id, parent_id, COALESCE(author_id, 0), author, body, created_at
+		 FROM result_comments WHERE result_id = ? ORDER BY created_at, id`, resultID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	comments := make([]models.ResultComment, 0)
+	index := make(map[int64]int)
+	for rows.Next() {
+		var c models.ResultComment
+		var parentID sql.NullInt64
+		if err := rows.Scan(&c.ID, &c.ResultID, &parentID, &c.AuthorID, &c.Author, &c.Body, &c.CreatedAt); err != nil {
+			return nil, err
+		}
+		if parentID.Valid {
+			c.ParentID = &parentID.Int64
 		}
+		c.Attachments = make([]models.CommentAttachment, 0)
+		index[c.ID] = len(comments)
+		comments = append(comments, c)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
 	}
 
-	// Pair them up
-	var matches []struct {
-		Customer models.Customer
-		Sanction models.Sanction
+	attachments, err := r.db.QueryContext(ctx,
+		`SELECT a.id, a.comment_id, a.file_name, a.content_type, a.size, a.sha256, a.file_path, a.created_at
+		 FROM comment_attachments a
+		 JOIN result_comments c ON a.comment_id = c.id
+		 WHERE c.result_id = ? ORDER BY a.id`, resultID)
+	if err != nil {
+		return nil, err
 	}
-	for hash := range hashMap {
-		if c, cOk := customerMap[hash]; cOk {
-			if s, sOk := sanctionMap[hash]; sOk {
-				matches = append(matches, struct {
-					Customer models.Customer
-					Sanction models.Sanction
-				}{Customer: c, Sanction: s})
-			}
+	defer attachments.Close()
+	for attachments.Next() {
+		var a models.CommentAttachment
+		if err := attachments.Scan(&a.ID, &a.CommentID, &a.FileName, &a.ContentType, &a.Size,
+			&a.SHA256, &a.FilePath, &a.CreatedAt); err != nil {
+			return nil, err
+		}
+		if i, ok := index[a.CommentID]; ok {
+			comments[i].Attachments = append(comments[i].Attachments, a)
 		}
 	}
+	return comments, attachments.Err()
+}
 
-	return matches, nil
+// GetCommentAttachment returns an attachment, or nil if not found
+func (r *Repository) GetCommentAttachment(ctx context.Context, id int64) (*models.CommentAttachment, error) {
+	var a models.CommentAttachment
+	err := r.db.QueryRowContext(ctx,
+		`SELECT id, comment_id, file_name, content_type, size, sha256, file_path, created_at
+		 FROM comment_attachments WHERE id = ?`, id).Scan(
+		&a.ID, &a.CommentID, &a.FileName, &a.ContentType, &a.Size, &a.SHA256, &a.FilePath, &a.CreatedAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &a, nil
 }
 
-// GetDashboardStats returns aggregated statistics for the dashboard
-func (r *Repository) GetDashboardStats(ctx context.Context) (int64, int64, int64, []*models.Screening, error) {
-	var totalScreenings int64
-	if err := r.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM screenings").Scan(&totalScreenings); err != nil {
-		return 0, 0, 0, nil, err
+// Suppression operations
+
+// suppressionColumns are the suppressions columns scanned by scanSuppression
+const suppressionColumns = `id, fingerprint, customer_list_id, customer_external_id, sanction_source, sanction_name,
+	result_id, reason, created_by, created_at, expires_at, revoked_by, revoked_at, hits, last_hit_at,
+	revoked_at IS NULL AND expires_at > CURRENT_TIMESTAMP`
+
+// CreateSuppression stores a suppression expiring the given number of days
+// from now, superseding any active one for the same pair
+func (r *Repository) CreateSuppression(ctx context.Context, s *models.Suppression, days int) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
 	}
+	defer tx.Rollback()
 
-	var totalMatches int64
-	if err := r.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM screening_results").Scan(&totalMatches); err != nil {
-		return 0, 0, 0, nil, err
+	if _, err := tx.ExecContext(ctx,
+		`UPDATE suppressions SET revoked_by = ?, revoked_at = CURRENT_TIMESTAMP
+		 WHERE customer_list_id = ? AND fingerprint = ? AND revoked_at IS NULL`,
+		s.CreatedBy, s.CustomerListID, s.Fingerprint); err != nil {
+		return err
+	}
+	res, err := tx.ExecContext(ctx,
+		`INSERT INTO suppressions (fingerprint, customer_list_id, customer_external_id, sanction_source, sanction_name,
+		 result_id, reason, created_by, created_at, expires_at)
+		 VALUES (?, ?, ?, ?, ?, ?, ?, ?, CURRENT_TIMESTAMP, datetime('now', '+' || ? || ' days'))`,
+		s.Fingerprint, s.CustomerListID, s.CustomerExternalID, s.SanctionSource, s.SanctionName,
+		s.ResultID, s.Reason, s.CreatedBy, days)
+	if err != nil {
+		return err
+	}
+	if s.ID, err = res.LastInsertId(); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return err
 	}
 
-	var activeLists int64
-	var customerLists int64
-	var sanctionLists int64
-	if err := r.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM customer_lists").Scan(&customerLists); err != nil {
-		return 0, 0, 0, nil, err
+	stored, err := r.GetSuppression(ctx, s.ID)
+	if err != nil {
+		return err
 	}
-	if err := r.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM sanction_lists").Scan(&sanctionLists); err != nil {
-		return 0, 0, 0, nil, err
+	*s = *stored
+	return nil
+}
+
+// GetSuppression returns a suppression, or nil if not found
+func (r *Repository) GetSuppression(ctx context.Context, id int64) (*models.Suppression, error) {
+	s, err := scanSuppression(r.db.QueryRowContext(ctx,
+		`SELECT `+suppressionColumns+` FROM suppressions WHERE id = ?`, id))
+	if err == sql.ErrNoRows {
+		return nil, nil
 	}
-	activeLists = customerLists + sanctionLists
+	return s, err
+}
 
-	// Get recent screenings (top 5)
-	rows, err := r.db.QueryContext(ctx, 
-		`SELECT id, job_id, name, status, match_count, finished_at, created_at 
-		 FROM screenings ORDER BY created_at DESC LIMIT 5`)
+// ListSuppressions returns suppressions, newest first. Expired and revoked
+// ones are included only if all is set.
+func (r *Repository) ListSuppressions(ctx context.Context, all bool) ([]models.Suppression, error) {
+	query := `SELECT ` + suppressionColumns + ` FROM suppressions`
+	if !all {
+		query += ` WHERE revoked_at IS NULL AND expires_at > CURRENT_TIMESTAMP`
+	}
+	rows, err := r.db.QueryContext(ctx, query+` ORDER BY created_at DESC, id DESC`)
 	if err != nil {
-		return 0, 0, 0, nil, err
+		return nil, err
 	}
 	defer rows.Close()
 
-	recentScreenings := make([]*models.Screening, 0)
+	suppressions := make([]models.Suppression, 0)
 	for rows.Next() {
-		var s models.Screening
-		var finishedAt sql.NullTime
-		if err := rows.Scan(&s.ID, &s.JobID, &s.Name, &s.Status, &s.MatchCount, &finishedAt, &s.CreatedAt); err != nil {
-			return 0, 0, 0, nil, err
+		s, err := scanSuppression(rows)
+		if err != nil {
+			return nil, err
 		}
-		if finishedAt.Valid {
-			s.FinishedAt = finishedAt.Time
+		suppressions = append(suppressions, *s)
+	}
+	return suppressions, rows.Err()
+}
+
+// ActiveSuppressions maps the fingerprint of each active suppression on a
+// customer list to its ID
+func (r *Repository) ActiveSuppressions(ctx context.Context, customerListID int64) (map[string]int64, error) {
+	rows, err := r.db.QueryContext(ctx,
+		`SELECT fingerprint, id FROM suppressions
+		 WHERE customer_list_id = ? AND revoked_at IS NULL AND expires_at > CURRENT_TIMESTAMP`, customerListID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	active := make(map[string]int64)
+	for rows.Next() {
+		var fingerprint string
+		var id int64
+		if err := rows.Scan(&fingerprint, &id); err != nil {
+			return nil, err
 		}
-		recentScreenings = append(recentScreenings, &s)
+		active[fingerprint] = id
 	}
+	return active, rows.Err()
+}
 
-	return totalScreenings, totalMatches, activeLists, recentScreenings, nil
+// RecordSuppressionHits adds to the hit counts of suppressions applied by a
+// screening
+func (r *Repository) RecordSuppressionHits(ctx context.Context, hits map[int64]int) error {
+	for id, n := range hits {
+		if _, err := r.db.ExecContext(ctx,
+			`UPDATE suppressions SET hits = hits + ?, last_hit_at = CURRENT_TIMESTAMP WHERE id = ?`, n, id); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// RevokeSuppression ends an active suppression. It returns false if the
+// suppression is already revoked or does not exist.
+func (r *Repository) RevokeSuppression(ctx context.Context, id int64, revokedBy string) (bool, error) {
+	res, err := r.db.ExecContext(ctx,
+		`UPDATE suppressions SET revoked_by = ?, revoked_at = CURRENT_TIMESTAMP
+		 WHERE id = ? AND revoked_at IS NULL`, revokedBy, id)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
+
+// scanSuppression scans a row of suppressionColumns
+func scanSuppression(row interface{ Scan(...interface{}) error }) (*models.Suppression, error) {
+	var s models.Suppression
+	if err := row.Scan(&s.ID, &s.Fingerprint, &s.CustomerListID, &s.CustomerExternalID, &s.SanctionSource,
+		&s.SanctionName, &s.ResultID, &s.Reason, &s.CreatedBy, &s.CreatedAt, &s.ExpiresAt, &s.RevokedBy,
+		&s.RevokedAt, &s.Hits, &s.LastHitAt, &s.Active); err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+// Session event operations
+
+func (r *Repository) CreateSessionEvent(ctx context.Context, e *models.SessionEvent) error {
+	res, err := r.db.ExecContext(ctx,
+		`INSERT INTO session_events (session_id, event, client, api_key_id, batch, ciphertext_count, match_count,
+		 duration_ms, detail, created_at)
+		 VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, CURRENT_TIMESTAMP)`,
+		e.SessionID, e.Event, e.Client, e.APIKeyID, e.Batch, e.CiphertextCount, e.MatchCount, e.DurationMs, e.Detail)
+	if err != nil {
+		return err
+	}
+	id, err := res.LastInsertId()
+	if err != nil {
+		return err
+	}
+	e.ID = id
+	return nil
+}
+
+// SessionEventFilter selects a page of session events, newest first.
+// Zero values mean no filter.
+type SessionEventFilter struct {
+	SessionID string
+	Event     string
+	Since     time.Time
+	Limit     int
+	Offset    int
+}
+
+// ListSessionEvents returns a filtered page of session events and the total
+// number of events matching the filter
+func (r *Repository) ListSessionEvents(ctx context.Context, f SessionEventFilter) ([]models.SessionEvent, int, error) {
+	var conditions []string
+	var args []interface{}
+	if f.SessionID != "" {
+		conditions = append(conditions, "session_id = ?")
+		args = append(args, f.SessionID)
+	}
+	if f.Event != "" {
+		conditions = append(conditions, "event = ?")
+		args = append(args, f.Event)
+	}
+	if !f.Since.IsZero() {
+		conditions = append(conditions, "created_at >= ?")
+		args = append(args, f.Since.UTC().Format("2006-01-02 15:04:05"))
+	}
+
+	where := ""
+	if len(conditions) > 0 {
+		where = " WHERE " + strings.Join(conditions, " AND ")
+	}
+
+	var total int
+	if err := r.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM session_events"+where, args...).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	args = append(args, f.Limit, f.Offset)
+	rows, err := r.db.QueryContext(ctx,
+		`SELECT id, session_id, event, COALESCE(client, ''), COALESCE(api_key_id, 0), batch, ciphertext_count, match_count,
+		 duration_ms, COALESCE(detail, ''), created_at
+		 FROM session_events`+where+`
+		 ORDER BY created_at DESC, id DESC LIMIT ? OFFSET ?`, args...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	events := make([]models.SessionEvent, 0)
+	for rows.Next() {
+		var e models.SessionEvent
+		if err := rows.Scan(&e.ID, &e.SessionID, &e.Event, &e.Client, &e.APIKeyID, &e.Batch, &e.CiphertextCount,
+			&e.MatchCount, &e.DurationMs, &e.Detail, &e.CreatedAt); err != nil {
+			return nil, 0, err
+		}
+		events = append(events, e)
+	}
+	return events, total, rows.Err()
+}
+
+// CountSessionEvents counts events of one type recorded for an API key at or after since
+func (r *Repository) CountSessionEvents(ctx context.Context, apiKeyID int64, event string, since time.Time) (int, error) {
+	var n int
+	err := r.db.QueryRowContext(ctx,
+		`SELECT COUNT(*) FROM session_events WHERE api_key_id = ? AND event = ? AND created_at >= ?`,
+		apiKeyID, event, since.UTC().Format("2006-01-02 15:04:05")).Scan(&n)
+	return n, err
+}
+
+// SessionCaps bounds what one session adds to aggregated counts, so each
+// count has a known sensitivity when it is noised. A zero cap counts
+// everything.
+type SessionCaps struct {
+	Intersections int
+	Matches       int
+	Errors        int
+}
+
+// CountClientSessionEvents counts events of one type recorded without an API
+// key for a client address at or after since
+func (r *Repository) CountClientSessionEvents(ctx context.Context, client, event string, since time.Time) (int, error) {
+	var n int
+	err := r.db.QueryRowContext(ctx,
+		`SELECT COUNT(*) FROM session_events WHERE COALESCE(api_key_id, 0) = 0 AND client = ? AND event = ? AND created_at >= ?`,
+		client, event, since.UTC().Format("2006-01-02 15:04:05")).Scan(&n)
+	return n, err
+}
+
+// sessionLimit is the SQL bound of a per-session count for a cap; 0 counts
+// everything
+func sessionLimit(cap int) int64 {
+	if cap <= 0 {
+		return math.MaxInt64
+	}
+	return int64(cap)
+}
+
+// GetSessionTotals returns the number of sessions started and matches found
+// across all clients. Each session counts at most matchCap matches; 0
+// counts them all.
+func (r *Repository) GetSessionTotals(ctx context.Context, matchCap int) (sessions, matches int, err error) {
+	err = r.db.QueryRowContext(ctx,
+		`SELECT COALESCE(SUM(inits), 0), COALESCE(SUM(MIN(matches, ?)), 0)
+		 FROM (SELECT SUM(CASE WHEN event = 'init' THEN 1 ELSE 0 END) AS inits,
+		       SUM(CASE WHEN event = 'intersect' THEN match_count ELSE 0 END) AS matches
+		       FROM session_events GROUP BY session_id)`,
+		sessionLimit(matchCap)).Scan(&sessions, &matches)
+	return sessions, matches, err
+}
+
+// GetUsage aggregates session events in [from, to) per month and API key,
+// oldest month first. Intersections, matches and errors of each session are
+// counted up to caps within a month.
+func (r *Repository) GetUsage(ctx context.Context, from, to time.Time, caps SessionCaps) ([]models.UsageRecord, error) {
+	rows, err := r.db.QueryContext(ctx,
+		`SELECT s.month, s.key_id, COALESCE(k.name, ''),
+		 COALESCE(SUM(s.inits), 0), COALESCE(SUM(MIN(s.intersects, ?)), 0), COALESCE(SUM(MIN(s.matches, ?)), 0),
+		 COALESCE(SUM(s.ciphertexts), 0), COALESCE(SUM(s.duration_ms), 0), COALESCE(SUM(MIN(s.errors, ?)), 0)
+		 FROM (SELECT strftime('%Y-%m', created_at) AS month, COALESCE(api_key_id, 0) AS key_id,
+		       SUM(CASE WHEN event = 'init' THEN 1 ELSE 0 END) AS inits,
+		       SUM(CASE WHEN event = 'intersect' THEN 1 ELSE 0 END) AS intersects,
+		       SUM(CASE WHEN event = 'intersect' THEN match_count ELSE 0 END) AS matches,
+		       SUM(ciphertext_count) AS ciphertexts,
+		       SUM(CASE WHEN event IN ('init', 'intersect') THEN duration_ms ELSE 0 END) AS duration_ms,
+		       SUM(CASE WHEN event = 'error' THEN 1 ELSE 0 END) AS errors
+		       FROM session_events
+		       WHERE created_at >= ? AND created_at < ?
+		       GROUP BY month, key_id, session_id) s
+		 LEFT JOIN api_keys k ON k.id = s.key_id
+		 GROUP BY s.month, s.key_id ORDER BY s.month, s.key_id`,
+		sessionLimit(caps.Intersections), sessionLimit(caps.Matches), sessionLimit(caps.Errors),
+		from.UTC().Format("2006-01-02 15:04:05"), to.UTC().Format("2006-01-02 15:04:05"))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	usage := make([]models.UsageRecord, 0)
+	for rows.Next() {
+		var u models.UsageRecord
+		var cpuMs int64
+		if err := rows.Scan(&u.Month, &u.APIKeyID, &u.APIKeyName, &u.Sessions, &u.Intersections,
+			&u.Matches, &u.Ciphertexts, &cpuMs, &u.Errors); err != nil {
+			return nil, err
+		}
+		u.CPUSeconds = float64(cpuMs) / 1000
+		usage = append(usage, u)
+	}
+	return usage, rows.Err()
+}
+
+// Audit log operations
+
+func (r *Repository) CreateAuditLog(ctx context.Context, log *models.AuditLog) error {
+	details, err := json.Marshal(log.Details)
+	if err != nil {
+		return fmt.Errorf("encode audit details: %w", err)
+	}
+	_, err = r.db.ExecContext(ctx,
+		`INSERT INTO audit_logs (actor_id, action, entity_type, entity_id, details, created_at)
+		 VALUES (?, ?, ?, ?, ?, CURRENT_TIMESTAMP)`,
+		log.ActorID, log.Action, log.EntityType, log.EntityID, string(details))
+	return err
+}
+
+// ListAuditLogs returns the audit trail of one entity, oldest first
+func (r *Repository) ListAuditLogs(ctx context.Context, entityType, entityID string) ([]models.AuditLog, error) {
+	rows, err := r.db.QueryContext(ctx,
+		`SELECT id, actor_id, action, COALESCE(entity_type, ''), COALESCE(entity_id, ''), COALESCE(details, ''), created_at
+		 FROM audit_logs WHERE entity_type = ? AND entity_id = ?
+		 ORDER BY created_at, id`, entityType, entityID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	logs := make([]models.AuditLog, 0)
+	for rows.Next() {
+		var l models.AuditLog
+		var details string
+		if err := rows.Scan(&l.ID, &l.ActorID, &l.Action, &l.EntityType, &l.EntityID, &details, &l.CreatedAt); err != nil {
+			return nil, err
+		}
+		if details != "" {
+			json.Unmarshal([]byte(details), &l.Details)
+		}
+		logs = append(logs, l)
+	}
+	return logs, rows.Err()
+}
+
+func (r *Repository) GetScreeningResults(ctx context.Context, screeningID int64, limit, offset int) ([]models.ScreeningResultDetail, int, error) {
+	// Get total count
+	var total int
+	err := r.db.QueryRowContext(ctx,
+		`SELECT COUNT(*) FROM screening_results WHERE screening_id = ?`, screeningID).Scan(&total)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	// Get paginated results with joins
+	rows, err := r.db.QueryContext(ctx,
+		`SELECT sr.id, sr.screening_id, sr.customer_id, sr.sanction_id, sr.match_score, sr.status,
+		        sr.investigator_id, COALESCE(sr.notes, ''), sr.created_at, sr.updated_at, COALESCE(sr.explanation, ''),
+		        COALESCE(sr.proposed_by, ''), COALESCE(sr.approved_by, ''), sr.approved_at, sr.suppression_id,
+		        COALESCE(sr.list_source, 'REMOTE'), COALESCE(sr.lists, ''), COALESCE(sr.match_channel, 'NAME_MATCH'),
+		        c.id, c.external_id, c.name, c.dob, c.country, c.hash, c.list_id, c.created_at,
+		        COALESCE(c.entity_type, 'individual'), COALESCE(c.registration, ''), COALESCE(c.imo_number, ''), COALESCE(c.attributes, ''),
+		        s.id, s.source, s.name, s.dob, s.country, s.program, s.hash, s.list_id, s.updated_at, s.version,
+		        COALESCE(s.entity_type, 'individual'), COALESCE(s.imo_number, ''), COALESCE(s.registration, ''),
+		        COALESCE(NULLIF(s.category, ''), 'SANCTIONS'), COALESCE(s.attributes, ''), COALESCE(s.retention, 'full'),
+		        COALESCE(s.source_ref, '')
+		 FROM screening_results sr
+		 JOIN customers c ON sr.customer_id = c.id
+		 JOIN sanctions s ON sr.sanction_id = s.id
+		 WHERE sr.screening_id = ?
+		 ORDER BY sr.match_channel = 'DOC_MATCH' DESC, sr.match_score DESC, sr.created_at DESC
+		 LIMIT ? OFFSET ?`,
+		screeningID, limit, offset)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	results := make([]models.ScreeningResultDetail, 0)
+	for rows.Next() {
+		var r models.ScreeningResultDetail
+		var explanation, lists, customerAttributes, sanctionAttributes string
+		err := rows.Scan(
+			&r.ID, &r.ScreeningID, &r.CustomerID, &r.SanctionID, &r.MatchScore, &r.Status,
+			&r.InvestigatorID, &r.Notes, &r.CreatedAt, &r.UpdatedAt, &explanation,
+			&r.ProposedBy, &r.ApprovedBy, &r.ApprovedAt, &r.SuppressionID, &r.ListSource, &lists, &r.Channel,
+			&r.Customer.ID, &r.Customer.ExternalID, &r.Customer.Name, &r.Customer.DOB,
+			&r.Customer.Country, &r.Customer.Hash, &r.Customer.ListID, &r.Customer.CreatedAt,
+			&r.Customer.EntityType, &r.Customer.Registration, &r.Customer.IMONumber, &customerAttributes,
+			&r.Sanction.ID, &r.Sanction.Source, &r.Sanction.Name, &r.Sanction.DOB,
+			&r.Sanction.Country, &r.Sanction.Program, &r.Sanction.Hash, &r.Sanction.ListID,
+			&r.Sanction.UpdatedAt, &r.Sanction.Version,
+			&r.Sanction.EntityType, &r.Sanction.IMONumber, &r.Sanction.Registration, &r.Sanction.Category, &sanctionAttributes, &r.Sanction.Retention,
+			&r.Sanction.SourceRef,
+		)
+		if err != nil {
+			return nil, 0, err
+		}
+		r.Explanation = decodeExplanation(explanation)
+		r.Lists = decodeLists(lists)
+		decodeJSON(customerAttributes, &r.Customer.Attributes)
+		decodeJSON(sanctionAttributes, &r.Sanction.Attributes)
+		results = append(results, r)
+	}
+
+	return results, total, rows.Err()
+}
+
+//  GetScreeningResultsByJobID gets results by job_id instead of screening_id
+func (r *Repository) GetScreeningResultsByJobID(ctx context.Context, jobID string, limit, offset int) ([]models.ScreeningResultDetail, error) {
+	rows, err := r.db.QueryContext(ctx,
+		`SELECT sr.id, sr.screening_id, sr.customer_id, sr.sanction_id, sr.match_score, sr.status,
+		        sr.investigator_id, COALESCE(sr.notes, ''), sr.created_at, sr.updated_at, COALESCE(sr.explanation, ''),
+		        COALESCE(sr.proposed_by, ''), COALESCE(sr.approved_by, ''), sr.approved_at, sr.suppression_id,
+		        COALESCE(sr.list_source, 'REMOTE'), COALESCE(sr.lists, ''), COALESCE(sr.match_channel, 'NAME_MATCH'),
+		        c.id, c.external_id, c.name, c.dob, c.country, c.hash, c.list_id, c.created_at,
+		        COALESCE(c.entity_type, 'individual'), COALESCE(c.registration, ''), COALESCE(c.imo_number, ''), COALESCE(c.attributes, ''),
+		        s.id, s.source, s.name, s.dob, s.country, s.program, s.hash, s.list_id, s.updated_at, s.version,
+		        COALESCE(s.entity_type, 'individual'), COALESCE(s.imo_number, ''), COALESCE(s.registration, ''),
+		        COALESCE(NULLIF(s.category, ''), 'SANCTIONS'), COALESCE(s.attributes, ''), COALESCE(s.retention, 'full'),
+		        COALESCE(s.source_ref, '')
+		 FROM screening_results sr
+		 JOIN screenings sc ON sr.screening_id = sc.id
+		 JOIN customers c ON sr.customer_id = c.id
+		 JOIN sanctions s ON sr.sanction_id = s.id
+		 WHERE sc.job_id = ?
+		 ORDER BY sr.match_channel = 'DOC_MATCH' DESC, sr.match_score DESC, sr.created_at DESC
+		 LIMIT ? OFFSET ?`,
+		jobID, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	results := make([]models.ScreeningResultDetail, 0)
+	for rows.Next() {
+		var r models.ScreeningResultDetail
+		var explanation, lists, customerAttributes, sanctionAttributes string
+		err := rows.Scan(
+			&r.ID, &r.ScreeningID, &r.CustomerID, &r.SanctionID, &r.MatchScore, &r.Status,
+			&r.InvestigatorID, &r.Notes, &r.CreatedAt, &r.UpdatedAt, &explanation,
+			&r.ProposedBy, &r.ApprovedBy, &r.ApprovedAt, &r.SuppressionID, &r.ListSource, &lists, &r.Channel,
+			&r.Customer.ID, &r.Customer.ExternalID, &r.Customer.Name, &r.Customer.DOB,
+			&r.Customer.Country, &r.Customer.Hash, &r.Customer.ListID, &r.Customer.CreatedAt,
+			&r.Customer.EntityType, &r.Customer.Registration, &r.Customer.IMONumber, &customerAttributes,
+			&r.Sanction.ID, &r.Sanction.Source, &r.Sanction.Name, &r.Sanction.DOB,
+			&r.Sanction.Country, &r.Sanction.Program, &r.Sanction.Hash, &r.Sanction.ListID,
+			&r.Sanction.UpdatedAt, &r.Sanction.Version,
+			&r.Sanction.EntityType, &r.Sanction.IMONumber, &r.Sanction.Registration, &r.Sanction.Category, &sanctionAttributes, &r.Sanction.Retention,
+			&r.Sanction.SourceRef,
+		)
+		if err != nil {
+			return nil, err
+		}
+		r.Explanation = decodeExplanation(explanation)
+		r.Lists = decodeLists(lists)
+		decodeJSON(customerAttributes, &r.Customer.Attributes)
+		decodeJSON(sanctionAttributes, &r.Sanction.Attributes)
+		results = append(results, r)
+	}
+
+	return results, rows.Err()
+}
+
+// GetScreeningResultDetail returns one result with its customer and sanction, or nil if not found
+func (r *Repository) GetScreeningResultDetail(ctx context.Context, resultID int64) (*models.ScreeningResultDetail, error) {
+	var d models.ScreeningResultDetail
+	var explanation, lists, customerAttributes, sanctionAttributes string
+	err := r.db.QueryRowContext(ctx,
+		`SELECT sr.id, sr.screening_id, sr.customer_id, sr.sanction_id, sr.match_score, sr.status,
+		        sr.investigator_id, COALESCE(sr.notes, ''), sr.created_at, sr.updated_at, COALESCE(sr.explanation, ''),
+		        COALESCE(sr.proposed_by, ''), COALESCE(sr.approved_by, ''), sr.approved_at, sr.suppression_id,
+		        COALESCE(sr.list_source, 'REMOTE'), COALESCE(sr.lists, ''), COALESCE(sr.match_channel, 'NAME_MATCH'),
+		        c.id, c.external_id, c.name, c.dob, c.country, c.hash, c.list_id, c.created_at,
+		        COALESCE(c.entity_type, 'individual'), COALESCE(c.registration, ''), COALESCE(c.imo_number, ''), COALESCE(c.attributes, ''),
+		        s.id, s.source, s.name, s.dob, s.country, s.program, s.hash, s.list_id, s.updated_at, s.version,
+		        COALESCE(s.entity_type, 'individual'), COALESCE(s.imo_number, ''), COALESCE(s.registration, ''),
+		        COALESCE(NULLIF(s.category, ''), 'SANCTIONS'), COALESCE(s.attributes, ''), COALESCE(s.retention, 'full'),
+		        COALESCE(s.source_ref, '')
+		 FROM screening_results sr
+		 JOIN customers c ON sr.customer_id = c.id
+		 JOIN sanctions s ON sr.sanction_id = s.id
+		 WHERE sr.id = ?`,
+		resultID).Scan(
+		&d.ID, &d.ScreeningID, &d.CustomerID, &d.SanctionID, &d.MatchScore, &d.Status,
+		&d.InvestigatorID, &d.Notes, &d.CreatedAt, &d.UpdatedAt, &explanation,
+			&d.ProposedBy, &d.ApprovedBy, &d.ApprovedAt, &d.SuppressionID, &d.ListSource, &lists, &d.Channel,
+		&d.Customer.ID, &d.Customer.ExternalID, &d.Customer.Name, &d.Customer.DOB,
+		&d.Customer.Country, &d.Customer.Hash, &d.Customer.ListID, &d.Customer.CreatedAt,
+		&d.Customer.EntityType, &d.Customer.Registration, &d.Customer.IMONumber, &customerAttributes,
+		&d.Sanction.ID, &d.Sanction.Source, &d.Sanction.Name, &d.Sanction.DOB,
+		&d.Sanction.Country, &d.Sanction.Program, &d.Sanction.Hash, &d.Sanction.ListID,
+		&d.Sanction.UpdatedAt, &d.Sanction.Version,
+		&d.Sanction.EntityType, &d.Sanction.IMONumber, &d.Sanction.Registration, &d.Sanction.Category, &sanctionAttributes, &d.Sanction.Retention,
+		&d.Sanction.SourceRef,
+	)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	d.Explanation = decodeExplanation(explanation)
+	d.Lists = decodeLists(lists)
+	decodeJSON(customerAttributes, &d.Customer.Attributes)
+	decodeJSON(sanctionAttributes, &d.Sanction.Attributes)
+	return &d, nil
+}
+
+// CountScreeningResultsByJobID counts results for a job
+func (r *Repository) CountScreeningResultsByJobID(ctx context.Context, jobID string) (int64, error) {
+	var count int64
+	err := r.db.QueryRowContext(ctx,
+		`SELECT COUNT(*) FROM screening_results sr
+		 JOIN screenings sc ON sr.screening_id = sc.id
+		 WHERE sc.job_id = ?`, jobID).Scan(&count)
+	return count, err
+}
+
+// User operations
+
+func (r *Repository) GetUserByEmail(ctx context.Context, email string) (*models.User, error) {
+	var u models.User
+	var twoFactorSecret sql.NullString
+	var lastLoginAt sql.NullTime
+
+	err := r.db.QueryRowContext(ctx,
+		`SELECT id, email, password_hash, role, two_factor_secret, active, last_login_at, created_at, updated_at
+		 FROM users WHERE email = ?`, email).Scan(
+		&u.ID, &u.Email, &u.PasswordHash, &u.Role, &twoFactorSecret, &u.Active, &lastLoginAt, &u.CreatedAt, &u.UpdatedAt)
+
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		fmt.Printf("DB Error in GetUserByEmail: %v\n", err)
+		return nil, err
+	}
+
+	if twoFactorSecret.Valid {
+		u.TwoFactorSecret = twoFactorSecret.String
+	}
+	if lastLoginAt.Valid {
+		t := lastLoginAt.Time
+		u.LastLoginAt = &t
+	}
+
+	return &u, nil
+}
+
+func (r *Repository) UpdateSanctionListCount(ctx context.Context, listID int64, count int) error {
+	query := `UPDATE sanction_lists SET record_count = $1, updated_at = CURRENT_TIMESTAMP WHERE id = $2`
+	_, err := r.db.ExecContext(ctx, query, count, listID)
+	return err
+}
+
+// GetCustomerAttributes returns the attributes of a customer, or
+// sql.ErrNoRows if there is no such customer
+func (r *Repository) GetCustomerAttributes(ctx context.Context, customerID int64) (map[string]string, error) {
+	return r.getAttributes(ctx, "customers", customerID)
+}
+
+// SetCustomerAttributes replaces the attributes of a customer
+func (r *Repository) SetCustomerAttributes(ctx context.Context, customerID int64, attributes map[string]string) error {
+	return r.setAttributes(ctx, "customers", customerID, attributes)
+}
+
+// GetSanctionAttributes returns the attributes of a sanction, or
+// sql.ErrNoRows if there is no such sanction
+func (r *Repository) GetSanctionAttributes(ctx context.Context, sanctionID int64) (map[string]string, error) {
+	return r.getAttributes(ctx, "sanctions", sanctionID)
+}
+
+// SetSanctionAttributes replaces the attributes of a sanction
+func (r *Repository) SetSanctionAttributes(ctx context.Context, sanctionID int64, attributes map[string]string) error {
+	return r.setAttributes(ctx, "sanctions", sanctionID, attributes)
+}
+
+// getAttributes and setAttributes access the attributes column of table,
+// which callers pass as a constant
+func (r *Repository) getAttributes(ctx context.Context, table string, id int64) (map[string]string, error) {
+	var raw string
+	err := r.db.QueryRowContext(ctx, `SELECT COALESCE(attributes, '') FROM `+table+` WHERE id = ?`, id).Scan(&raw)
+	if err != nil {
+		return nil, err
+	}
+	attributes := make(map[string]string)
+	decodeJSON(raw, &attributes)
+	return attributes, nil
+}
+
+func (r *Repository) setAttributes(ctx context.Context, table string, id int64, attributes map[string]string) error {
+	res, err := r.db.ExecContext(ctx, `UPDATE `+table+` SET attributes = ? WHERE id = ?`, encodeJSON(attributes), id)
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// GetSanctionListSchema returns the custom fields of a list, or
+// sql.ErrNoRows if there is no such list
+func (r *Repository) GetSanctionListSchema(ctx context.Context, listID int64) ([]models.ListField, error) {
+	var raw string
+	err := r.db.QueryRowContext(ctx, `SELECT COALESCE(schema, '') FROM sanction_lists WHERE id = ?`, listID).Scan(&raw)
+	if err != nil {
+		return nil, err
+	}
+	fields := make([]models.ListField, 0)
+	decodeJSON(raw, &fields)
+	return fields, nil
+}
+
+// SetSanctionListSchema replaces the custom fields of a list. Records keep
+// the attributes they were uploaded with until the next version.
+func (r *Repository) SetSanctionListSchema(ctx context.Context, listID int64, fields []models.ListField) error {
+	res, err := r.db.ExecContext(ctx,
+		`UPDATE sanction_lists SET schema = ?, updated_at = CURRENT_TIMESTAMP WHERE id = ?`, encodeJSON(fields), listID)
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// SetSanctionListActive activates or deactivates a list, or returns
+// sql.ErrNoRows if there is no such list. Its records are kept either way.
+func (r *Repository) SetSanctionListActive(ctx context.Context, listID int64, active bool) error {
+	res, err := r.db.ExecContext(ctx,
+		`UPDATE sanction_lists SET active = ?, updated_at = CURRENT_TIMESTAMP WHERE id = ?`, active, listID)
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+func (r *Repository) UpdateUserLastLogin(ctx context.Context, userID int64) error {
+	_, err := r.db.ExecContext(ctx,
+		`UPDATE users SET last_login_at = CURRENT_TIMESTAMP WHERE id = ?`, userID)
+	return err
+}
+
+// ResolveMatches maps hashes back to customer and sanction records
+func (r *Repository) ResolveMatches(ctx context.Context, hashes []int64, customerListID int64, sanctionListIDs []int64) ([]struct {
+	Customer models.Customer
+	Sanction models.Sanction
+}, error) {
+	// Create hash map for efficient lookup
+	hashMap := make(map[int64]bool)
+	for _, h := range hashes {
+		hashMap[h] = true
+	}
+
+	// Get matching customers
+	customers, err := r.GetCustomersByListID(ctx, customerListID)
+	if err != nil {
+		return nil, err
+	}
+	customerMap := make(map[int64]models.Customer)
+	for _, c := range customers {
+		if hashMap[c.Hash] {
+			customerMap[c.Hash] = c
+		}
+	}
+
+	// Get matching sanctions
+	sanctions, err := r.GetSanctionsByListIDs(ctx, sanctionListIDs)
+	if err != nil {
+		return nil, err
+	}
+	sanctionMap := make(map[int64]models.Sanction)
+	for _, s := range sanctions {
+		if hashMap[s.Hash] {
+			sanctionMap[s.Hash] = s
+		}
+	}
+
+	// Pair them up
+	var matches []struct {
+		Customer models.Customer
+		Sanction models.Sanction
+	}
+	for hash := range hashMap {
+		if c, cOk := customerMap[hash]; cOk {
+			if s, sOk := sanctionMap[hash]; sOk {
+				matches = append(matches, struct {
+					Customer models.Customer
+					Sanction models.Sanction
+				}{Customer: c, Sanction: s})
+			}
+		}
+	}
+
+	return matches, nil
+}
+
+// GetDashboardStats returns aggregated statistics for the dashboard
+func (r *Repository) GetDashboardStats(ctx context.Context) (int64, int64, int64, []*models.Screening, error) {
+	var totalScreenings int64
+	if err := r.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM screenings").Scan(&totalScreenings); err != nil {
+		return 0, 0, 0, nil, err
+	}
+
+	var totalMatches int64
+	if err := r.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM screening_results").Scan(&totalMatches); err != nil {
+		return 0, 0, 0, nil, err
+	}
+
+	var activeLists int64
+	var customerLists int64
+	var sanctionLists int64
+	if err := r.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM customer_lists").Scan(&customerLists); err != nil {
+		return 0, 0, 0, nil, err
+	}
+	if err := r.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM sanction_lists").Scan(&sanctionLists); err != nil {
+		return 0, 0, 0, nil, err
+	}
+	activeLists = customerLists + sanctionLists
+
+	// Get recent screenings (top 5)
+	rows, err := r.db.QueryContext(ctx, 
+		`SELECT id, job_id, name, status, match_count, finished_at, created_at 
+		 FROM screenings ORDER BY created_at DESC LIMIT 5`)
+	if err != nil {
+		return 0, 0, 0, nil, err
+	}
+	defer rows.Close()
+
+	recentScreenings := make([]*models.Screening, 0)
+	for rows.Next() {
+		var s models.Screening
+		var finishedAt sql.NullTime
+		if err := rows.Scan(&s.ID, &s.JobID, &s.Name, &s.Status, &s.MatchCount, &finishedAt, &s.CreatedAt); err != nil {
+			return 0, 0, 0, nil, err
+		}
+		if finishedAt.Valid {
+			s.FinishedAt = finishedAt.Time
+		}
+		recentScreenings = append(recentScreenings, &s)
+	}
+
+	return totalScreenings, totalMatches, activeLists, recentScreenings, nil
+}
+
+// GetScreeningTrend aggregates screenings created since the given time into
+// daily or weekly buckets, oldest first. Interval is "day" or "week".
+func (r *Repository) GetScreeningTrend(ctx context.Context, since time.Time, interval string) ([]models.ScreeningTrendPoint, error) {
+	period := `strftime('%Y-%m-%d', created_at)`
+	if interval == "week" {
+		period = `strftime('%Y-W%W', created_at)`
+	}
+
+	rows, err := r.db.QueryContext(ctx,
+		`SELECT `+period+` AS period, COUNT(*), COALESCE(SUM(match_count), 0), COALESCE(SUM(customer_count), 0),
+		 COALESCE(AVG(CASE WHEN started_at IS NOT NULL AND finished_at IS NOT NULL
+		     THEN (julianday(finished_at) - julianday(started_at)) * 86400000 END), 0)
+		 FROM screenings WHERE created_at >= ?
+		 GROUP BY period ORDER BY period`,
+		since.UTC().Format("2006-01-02 15:04:05"))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	points := make([]models.ScreeningTrendPoint, 0)
+	for rows.Next() {
+		var p models.ScreeningTrendPoint
+		if err := rows.Scan(&p.Period, &p.Screenings, &p.Matches, &p.Customers, &p.AvgDurationMs); err != nil {
+			return nil, err
+		}
+		if p.Customers > 0 {
+			p.MatchRate = float64(p.Matches) / float64(p.Customers)
+		}
+		points = append(points, p)
+	}
+	return points, rows.Err()
+}
+
+// listAnalyticsAggregates are the per-list result aggregates shared by
+// customer and sanction list analytics; sr is screening_results
+const listAnalyticsAggregates = `COUNT(sr.id),
+	COALESCE(SUM(CASE WHEN sr.status = 'FALSE_POSITIVE' THEN 1 ELSE 0 END), 0),
+	COALESCE(SUM(CASE WHEN sr.status = 'CONFIRMED' THEN 1 ELSE 0 END), 0),
+	COALESCE(AVG(CASE WHEN sr.status NOT IN ('PENDING', 'PENDING_APPROVAL', 'SUPPRESSED')
+	    THEN (julianday(sr.updated_at) - julianday(sr.created_at)) * 86400 END), 0)`
+
+// GetCustomerListAnalytics returns screening and disposition statistics per customer list
+func (r *Repository) GetCustomerListAnalytics(ctx context.Context) ([]models.ListAnalytics, error) {
+	return r.queryListAnalytics(ctx,
+		`SELECT cl.id, cl.name, COUNT(DISTINCT s.id), `+listAnalyticsAggregates+`
+		 FROM customer_lists cl
+		 LEFT JOIN screenings s ON s.customer_list_id = cl.id
+		 LEFT JOIN screening_results sr ON sr.screening_id = s.id
+		 GROUP BY cl.id, cl.name ORDER BY cl.id`)
+}
+
+// GetSanctionListAnalytics returns screening and disposition statistics per sanction list
+func (r *Repository) GetSanctionListAnalytics(ctx context.Context) ([]models.ListAnalytics, error) {
+	return r.queryListAnalytics(ctx,
+		`SELECT sl.id, sl.name,
+		 (SELECT COUNT(*) FROM screenings s
+		  WHERE ',' || s.sanction_list_ids || ',' LIKE '%,' || sl.id || ',%'), `+listAnalyticsAggregates+`
+		 FROM sanction_lists sl
+		 LEFT JOIN sanctions sa ON sa.list_id = sl.id
+		 LEFT JOIN screening_results sr ON sr.sanction_id = sa.id
+		 GROUP BY sl.id, sl.name ORDER BY sl.id`)
+}
+
+func (r *Repository) queryListAnalytics(ctx context.Context, query string) ([]models.ListAnalytics, error) {
+	rows, err := r.db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	lists := make([]models.ListAnalytics, 0)
+	for rows.Next() {
+		var a models.ListAnalytics
+		if err := rows.Scan(&a.ListID, &a.Name, &a.Screenings, &a.Matches, &a.FalsePositives, &a.Confirmed,
+			&a.AvgResolutionSeconds); err != nil {
+			return nil, err
+		}
+		if reviewed := a.FalsePositives + a.Confirmed; reviewed > 0 {
+			a.FalsePositiveRate = float64(a.FalsePositives) / float64(reviewed)
+		}
+		lists = append(lists, a)
+	}
+	return lists, rows.Err()
+}
+
+// Customer risk operations
+
+// customerRiskColumns are the customer_risk columns scanned by scanCustomerRisk
+const customerRiskColumns = `cr.customer_id, cr.external_id, cr.name, cr.list_id, cr.screenings, cr.hits,
+	cr.confirmed, cr.false_positives, cr.pending, cr.programs, cr.score, cr.tier`
+
+// ListCustomerRisk returns customers with matches, riskiest first, and how
+// many match the filters. listID 0 and tier "" match everything.
+func (r *Repository) ListCustomerRisk(ctx context.Context, listID int64, tier string, limit, offset int) ([]models.CustomerRisk, int, error) {
+	where := ` WHERE (? = 0 OR cr.list_id = ?) AND (? = '' OR cr.tier = ?)`
+	args := []interface{}{listID, listID, tier, tier}
+
+	var total int
+	if err := r.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM customer_risk cr`+where, args...).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	rows, err := r.db.QueryContext(ctx,
+		`SELECT `+customerRiskColumns+` FROM customer_risk cr`+where+`
+		 ORDER BY cr.score DESC, cr.customer_id
+		 LIMIT ? OFFSET ?`,
+		append(args, limit, offset)...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	customers := make([]models.CustomerRisk, 0)
+	for rows.Next() {
+		c, err := scanCustomerRisk(rows)
+		if err != nil {
+			return nil, 0, err
+		}
+		customers = append(customers, *c)
+	}
+	return customers, total, rows.Err()
+}
+
+// GetCustomerRisk returns the risk of the customer with the given record ID,
+// tier NONE if they never matched, or nil if there is no such customer
+func (r *Repository) GetCustomerRisk(ctx context.Context, customerID int64) (*models.CustomerRisk, error) {
+	c, err := scanCustomerRisk(r.db.QueryRowContext(ctx,
+		`SELECT `+customerRiskColumns+`
+		 FROM customer_risk cr
+		 JOIN customers c ON c.list_id = cr.list_id AND c.external_id = cr.external_id
+		 WHERE c.id = ?`, customerID))
+	if err != sql.ErrNoRows {
+		return c, err
+	}
+
+	c = &models.CustomerRisk{CustomerID: customerID, Programs: []string{}, Tier: models.RiskTierNone}
+	err = r.db.QueryRowContext(ctx,
+		`SELECT external_id, name, list_id FROM customers WHERE id = ?`, customerID).
+		Scan(&c.ExternalID, &c.Name, &c.ListID)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// scanCustomerRisk scans a row of customerRiskColumns
+func scanCustomerRisk(row interface{ Scan(...interface{}) error }) (*models.CustomerRisk, error) {
+	var c models.CustomerRisk
+	var programs string
+	if err := row.Scan(&c.CustomerID, &c.ExternalID, &c.Name, &c.ListID, &c.Screenings, &c.Hits,
+		&c.Confirmed, &c.FalsePositives, &c.Pending, &programs, &c.Score, &c.Tier); err != nil {
+		return nil, err
+	}
+	c.Programs = make([]string, 0)
+	seen := make(map[string]bool)
+	for _, p := range strings.Split(programs, ",") {
+		if p = strings.TrimSpace(p); p != "" && !seen[p] {
+			seen[p] = true
+			c.Programs = append(c.Programs, p)
+		}
+	}
+	return &c, nil
+}
+
+// Screening metrics operations
+
+func (r *Repository) CreateScreeningMetrics(ctx context.Context, m *models.ScreeningMetrics) error {
+	res, err := r.db.ExecContext(ctx,
+		`INSERT INTO screening_metrics (screening_id, encryption_ms, network_ms, intersection_ms, resolve_ms,
+		 persist_ms, total_ms, record_count, hash_collisions, created_at)
+		 VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, CURRENT_TIMESTAMP)`,
+		m.ScreeningID, m.EncryptionMs, m.NetworkMs, m.IntersectionMs, m.ResolveMs,
+		m.PersistMs, m.TotalMs, m.RecordCount, m.HashCollisions)
+	if err != nil {
+		return err
+	}
+	id, err := res.LastInsertId()
+	if err != nil {
+		return err
+	}
+	m.ID = id
+	return nil
+}
+
+const screeningMetricsColumns = `sm.id, sm.screening_id, sm.encryption_ms, sm.network_ms, sm.intersection_ms,
+		 sm.resolve_ms, sm.persist_ms, sm.total_ms, sm.record_count, sm.hash_collisions, sm.created_at`
+
+func scanScreeningMetrics(row rowScanner) (*models.ScreeningMetrics, error) {
+	var m models.ScreeningMetrics
+	err := row.Scan(&m.ID, &m.ScreeningID, &m.EncryptionMs, &m.NetworkMs, &m.IntersectionMs,
+		&m.ResolveMs, &m.PersistMs, &m.TotalMs, &m.RecordCount, &m.HashCollisions, &m.CreatedAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &m, nil
+}
+
+// GetScreeningMetricsByJobID returns the metrics for a job, or nil if none were recorded
+func (r *Repository) GetScreeningMetricsByJobID(ctx context.Context, jobID string) (*models.ScreeningMetrics, error) {
+	return scanScreeningMetrics(r.db.QueryRowContext(ctx,
+		`SELECT `+screeningMetricsColumns+`
+		 FROM screening_metrics sm
+		 JOIN screenings sc ON sm.screening_id = sc.id
+		 WHERE sc.job_id = ?`, jobID))
+}
+
+// GetLatestScreeningMetrics returns the most recently recorded metrics, or nil if none exist
+func (r *Repository) GetLatestScreeningMetrics(ctx context.Context) (*models.ScreeningMetrics, error) {
+	return scanScreeningMetrics(r.db.QueryRowContext(ctx,
+		`SELECT `+screeningMetricsColumns+`
+		 FROM screening_metrics sm
+		 ORDER BY sm.created_at DESC, sm.id DESC LIMIT 1`))
+}
+
+// GetRecentScreeningMetrics returns up to limit of the most recently recorded metrics
+func (r *Repository) GetRecentScreeningMetrics(ctx context.Context, limit int) ([]models.ScreeningMetrics, error) {
+	rows, err := r.db.QueryContext(ctx,
+		`SELECT `+screeningMetricsColumns+`
+		 FROM screening_metrics sm
+		 ORDER BY sm.created_at DESC, sm.id DESC LIMIT ?`, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var metrics []models.ScreeningMetrics
+	for rows.Next() {
+		var m models.ScreeningMetrics
+		if err := rows.Scan(&m.ID, &m.ScreeningID, &m.EncryptionMs, &m.NetworkMs, &m.IntersectionMs,
+			&m.ResolveMs, &m.PersistMs, &m.TotalMs, &m.RecordCount, &m.HashCollisions, &m.CreatedAt); err != nil {
+			return nil, err
+		}
+		metrics = append(metrics, m)
+	}
+	return metrics, rows.Err()
+}
+
+// API key operations
+
+func (r *Repository) CreateAPIKey(ctx context.Context, k *models.APIKey) error {
+	res, err := r.db.ExecContext(ctx,
+		`INSERT INTO api_keys (name, prefix, key_hash, created_at) VALUES (?, ?, ?, CURRENT_TIMESTAMP)`,
+		k.Name, k.Prefix, k.KeyHash)
+	if err != nil {
+		return err
+	}
+	id, err := res.LastInsertId()
+	if err != nil {
+		return err
+	}
+	k.ID = id
+	return nil
+}
+
+const apiKeyColumns = `id, name, prefix, key_hash, created_at, last_used_at, revoked_at,
+	COALESCE(max_sessions_per_day, 0), COALESCE(max_ciphertexts_per_request, 0), COALESCE(max_concurrent_sessions, 0),
+	COALESCE(min_resolve_ciphertexts, 0)`
+
+func scanAPIKey(row rowScanner) (*models.APIKey, error) {
+	var k models.APIKey
+	var lastUsedAt, revokedAt sql.NullTime
+	if err := row.Scan(&k.ID, &k.Name, &k.Prefix, &k.KeyHash, &k.CreatedAt, &lastUsedAt, &revokedAt,
+		&k.Quota.MaxSessionsPerDay, &k.Quota.MaxCiphertextsPerRequest, &k.Quota.MaxConcurrentSessions,
+		&k.Quota.MinResolveCiphertexts); err != nil {
+		return nil, err
+	}
+	if lastUsedAt.Valid {
+		k.LastUsedAt = &lastUsedAt.Time
+	}
+	if revokedAt.Valid {
+		k.RevokedAt = &revokedAt.Time
+	}
+	return &k, nil
+}
+
+func (r *Repository) ListAPIKeys(ctx context.Context) ([]models.APIKey, error) {
+	rows, err := r.db.QueryContext(ctx, `SELECT `+apiKeyColumns+` FROM api_keys ORDER BY id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	keys := make([]models.APIKey, 0)
+	for rows.Next() {
+		k, err := scanAPIKey(rows)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, *k)
+	}
+	return keys, rows.Err()
+}
+
+// GetActiveAPIKeyByHash returns the unrevoked key with the given hash, or nil if none exists
+func (r *Repository) GetActiveAPIKeyByHash(ctx context.Context, keyHash string) (*models.APIKey, error) {
+	k, err := scanAPIKey(r.db.QueryRowContext(ctx,
+		`SELECT `+apiKeyColumns+` FROM api_keys WHERE key_hash = ? AND revoked_at IS NULL`, keyHash))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return k, err
+}
+
+// GetAPIKey returns the key with the given ID, revoked or not, or nil if none exists
+func (r *Repository) GetAPIKey(ctx context.Context, id int64) (*models.APIKey, error) {
+	k, err := scanAPIKey(r.db.QueryRowContext(ctx, `SELECT `+apiKeyColumns+` FROM api_keys WHERE id = ?`, id))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return k, err
+}
+
+// UpdateAPIKeyQuota replaces a key's quota, reporting false if no key has that ID
+func (r *Repository) UpdateAPIKeyQuota(ctx context.Context, id int64, q models.APIKeyQuota) (bool, error) {
+	res, err := r.db.ExecContext(ctx,
+		`UPDATE api_keys SET max_sessions_per_day = ?, max_ciphertexts_per_request = ?, max_concurrent_sessions = ?,
+		 min_resolve_ciphertexts = ?
+		 WHERE id = ?`,
+		q.MaxSessionsPerDay, q.MaxCiphertextsPerRequest, q.MaxConcurrentSessions, q.MinResolveCiphertexts, id)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
+
+// MaxMinResolveCiphertexts returns the largest resolve minimum in the
+// quotas of active keys, or 0 if none sets one
+func (r *Repository) MaxMinResolveCiphertexts(ctx context.Context) (int, error) {
+	var n int
+	err := r.db.QueryRowContext(ctx,
+		`SELECT COALESCE(MAX(min_resolve_ciphertexts), 0) FROM api_keys WHERE revoked_at IS NULL`).Scan(&n)
+	return n, err
+}
+
+func (r *Repository) TouchAPIKey(ctx context.Context, id int64) error {
+	_, err := r.db.ExecContext(ctx, `UPDATE api_keys SET last_used_at = CURRENT_TIMESTAMP WHERE id = ?`, id)
+	return err
+}
+
+// RevokeAPIKey revokes a key, reporting false if no active key has that ID
+func (r *Repository) RevokeAPIKey(ctx context.Context, id int64) (bool, error) {
+	res, err := r.db.ExecContext(ctx,
+		`UPDATE api_keys SET revoked_at = CURRENT_TIMESTAMP WHERE id = ? AND revoked_at IS NULL`, id)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
+
+// ReplaceSanctionListVersion clears a list's sanctions and bumps its version
+// so a new upload can repopulate it. It returns the new version.
+func (r *Repository) ReplaceSanctionListVersion(ctx context.Context, listID int64, filePath string) (int, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	res, err := tx.ExecContext(ctx,
+		`UPDATE sanction_lists SET version = version + 1, file_path = ?, updated_at = CURRENT_TIMESTAMP WHERE id = ?`,
+		filePath, listID)
+	if err != nil {
+		return 0, err
+	}
+	if n, err := res.RowsAffected(); err != nil {
+		return 0, err
+	} else if n == 0 {
+		return 0, sql.ErrNoRows
+	}
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM sanctions WHERE list_id = ?`, listID); err != nil {
+		return 0, err
+	}
+
+	var version int
+	if err := tx.QueryRowContext(ctx, `SELECT version FROM sanction_lists WHERE id = ?`, listID).Scan(&version); err != nil {
+		return 0, err
+	}
+	return version, tx.Commit()
+}
+
+// Watchlist pack operations
+
+// CreatePack stores a new pack and its lists at version 1
+func (r *Repository) CreatePack(ctx context.Context, p *models.WatchlistPack) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx,
+		`INSERT INTO watchlist_packs (id, name, description, version, created_at, updated_at)
+		 VALUES (?, ?, ?, 1, CURRENT_TIMESTAMP, CURRENT_TIMESTAMP)`,
+		p.ID, p.Name, p.Description); err != nil {
+		return err
+	}
+	if err := insertPackLists(ctx, tx, p.ID, p.ListIDs); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// UpdatePack replaces a pack's name, description and lists and bumps its
+// version, reporting false if no pack has that ID
+func (r *Repository) UpdatePack(ctx context.Context, p *models.WatchlistPack) (bool, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	res, err := tx.ExecContext(ctx,
+		`UPDATE watchlist_packs SET name = ?, description = ?, version = version + 1, updated_at = CURRENT_TIMESTAMP
+		 WHERE id = ?`,
+		p.Name, p.Description, p.ID)
+	if err != nil {
+		return false, err
+	}
+	if n, err := res.RowsAffected(); err != nil || n == 0 {
+		return false, err
+	}
+	if _, err := tx.ExecContext(ctx, `DELETE FROM watchlist_pack_lists WHERE pack_id = ?`, p.ID); err != nil {
+		return false, err
+	}
+	if err := insertPackLists(ctx, tx, p.ID, p.ListIDs); err != nil {
+		return false, err
+	}
+	return true, tx.Commit()
+}
+
+func insertPackLists(ctx context.Context, tx *sql.Tx, packID string, listIDs []int64) error {
+	for _, id := range listIDs {
+		if _, err := tx.ExecContext(ctx,
+			`INSERT OR IGNORE INTO watchlist_pack_lists (pack_id, list_id) VALUES (?, ?)`, packID, id); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// DeletePack removes a pack, reporting false if no pack has that ID. The
+// lists it bundled are kept.
+func (r *Repository) DeletePack(ctx context.Context, id string) (bool, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM watchlist_pack_lists WHERE pack_id = ?`, id); err != nil {
+		return false, err
+	}
+	res, err := tx.ExecContext(ctx, `DELETE FROM watchlist_packs WHERE id = ?`, id)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return n > 0, tx.Commit()
+}
+
+// BumpPacksForList increases the version of every pack holding listID, for
+// when the list's records change, and returns their IDs
+func (r *Repository) BumpPacksForList(ctx context.Context, listID int64) ([]string, error) {
+	rows, err := r.db.QueryContext(ctx, `SELECT pack_id FROM watchlist_pack_lists WHERE list_id = ? ORDER BY pack_id`, listID)
+	if err != nil {
+		return nil, err
+	}
+	var ids []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	for _, id := range ids {
+		if _, err := r.db.ExecContext(ctx,
+			`UPDATE watchlist_packs SET version = version + 1, updated_at = CURRENT_TIMESTAMP WHERE id = ?`, id); err != nil {
+			return nil, err
+		}
+	}
+	return ids, nil
+}
+
+// GetPack returns a pack with its lists and their categories, or nil if not found
+func (r *Repository) GetPack(ctx context.Context, id string) (*models.WatchlistPack, error) {
+	var p models.WatchlistPack
+	err := r.db.QueryRowContext(ctx,
+		`SELECT id, name, COALESCE(description, ''), version, updated_at, created_at FROM watchlist_packs WHERE id = ?`, id).
+		Scan(&p.ID, &p.Name, &p.Description, &p.Version, &p.UpdatedAt, &p.CreatedAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := r.loadPackLists(ctx, &p); err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+// ListPacks returns every pack with its lists, ordered by ID
+func (r *Repository) ListPacks(ctx context.Context) ([]models.WatchlistPack, error) {
+	rows, err := r.db.QueryContext(ctx,
+		`SELECT id, name, COALESCE(description, ''), version, updated_at, created_at FROM watchlist_packs ORDER BY id`)
+	if err != nil {
+		return nil, err
+	}
+	packs := make([]models.WatchlistPack, 0)
+	for rows.Next() {
+		var p models.WatchlistPack
+		if err := rows.Scan(&p.ID, &p.Name, &p.Description, &p.Version, &p.UpdatedAt, &p.CreatedAt); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		packs = append(packs, p)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	for i := range packs {
+		if err := r.loadPackLists(ctx, &packs[i]); err != nil {
+			return nil, err
+		}
+	}
+	return packs, nil
+}
+
+// loadPackLists fills in the IDs, count and categories of p's lists. Lists
+// deleted since the pack was built are not counted.
+func (r *Repository) loadPackLists(ctx context.Context, p *models.WatchlistPack) error {
+	rows, err := r.db.QueryContext(ctx,
+		`SELECT sl.id, COALESCE(sl.category, 'SANCTIONS')
+		 FROM watchlist_pack_lists pl JOIN sanction_lists sl ON sl.id = pl.list_id
+		 WHERE pl.pack_id = ? ORDER BY sl.id`, p.ID)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	p.ListIDs = make([]int64, 0)
+	p.Categories = make([]string, 0)
+	seen := make(map[string]bool)
+	for rows.Next() {
+		var id int64
+		var category string
+		if err := rows.Scan(&id, &category); err != nil {
+			return err
+		}
+		p.ListIDs = append(p.ListIDs, id)
+		if !seen[category] {
+			seen[category] = true
+			p.Categories = append(p.Categories, category)
+		}
+	}
+	p.ListCount = len(p.ListIDs)
+	return rows.Err()
+}
+
+// Pack subscription operations (client side)
+
+// SubscribePack records a subscription, reporting false if one already exists
+func (r *Repository) SubscribePack(ctx context.Context, packID, name string, version int) (bool, error) {
+	res, err := r.db.ExecContext(ctx,
+		`INSERT OR IGNORE INTO pack_subscriptions (pack_id, name, version, updated_at, subscribed_at)
+		 VALUES (?, ?, ?, CURRENT_TIMESTAMP, CURRENT_TIMESTAMP)`,
+		packID, name, version)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
+
+// UpdatePackSubscription records the latest pack state pushed by the server
+func (r *Repository) UpdatePackSubscription(ctx context.Context, packID, name string, version int, deleted bool) error {
+	_, err := r.db.ExecContext(ctx,
+		`UPDATE pack_subscriptions SET name = ?, version = ?, deleted = ?, updated_at = CURRENT_TIMESTAMP WHERE pack_id = ?`,
+		name, version, deleted, packID)
+	return err
+}
+
+// UnsubscribePack removes a subscription, reporting false if there was none
+func (r *Repository) UnsubscribePack(ctx context.Context, packID string) (bool, error) {
+	res, err := r.db.ExecContext(ctx, `DELETE FROM pack_subscriptions WHERE pack_id = ?`, packID)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
+
+func (r *Repository) ListPackSubscriptions(ctx context.Context) ([]models.PackSubscription, error) {
+	rows, err := r.db.QueryContext(ctx,
+		`SELECT pack_id, COALESCE(name, ''), COALESCE(version, 0), COALESCE(deleted, 0), updated_at, subscribed_at
+		 FROM pack_subscriptions ORDER BY pack_id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	subs := make([]models.PackSubscription, 0)
+	for rows.Next() {
+		var s models.PackSubscription
+		var updatedAt sql.NullTime
+		if err := rows.Scan(&s.PackID, &s.Name, &s.Version, &s.Deleted, &updatedAt, &s.SubscribedAt); err != nil {
+			return nil, err
+		}
+		if updatedAt.Valid {
+			s.UpdatedAt = &updatedAt.Time
+		}
+		subs = append(subs, s)
+	}
+	return subs, rows.Err()
+}
+
+// Fixture operations
+
+// ListChecksum identifies a list by name along with the checksum of the
+// file it was last loaded from
+type ListChecksum struct {
+	ID       int64
+	Checksum string
+}
+
+// GetSanctionListChecksum returns the newest sanction list with the given name, or nil if none exists
+func (r *Repository) GetSanctionListChecksum(ctx context.Context, name string) (*ListChecksum, error) {
+	return r.getListChecksum(ctx, `SELECT id, COALESCE(checksum, '') FROM sanction_lists WHERE name = ? ORDER BY id DESC LIMIT 1`, name)
+}
+
+// GetCustomerListChecksum returns the newest customer list with the given name, or nil if none exists
+func (r *Repository) GetCustomerListChecksum(ctx context.Context, name string) (*ListChecksum, error) {
+	return r.getListChecksum(ctx, `SELECT id, COALESCE(checksum, '') FROM customer_lists WHERE name = ? ORDER BY id DESC LIMIT 1`, name)
+}
+
+func (r *Repository) getListChecksum(ctx context.Context, query, name string) (*ListChecksum, error) {
+	var lc ListChecksum
+	err := r.db.QueryRowContext(ctx, query, name).Scan(&lc.ID, &lc.Checksum)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &lc, nil
+}
+
+func (r *Repository) SetSanctionListChecksum(ctx context.Context, listID int64, checksum string) error {
+	_, err := r.db.ExecContext(ctx, `UPDATE sanction_lists SET checksum = ? WHERE id = ?`, checksum, listID)
+	return err
+}
+
+func (r *Repository) SetCustomerListChecksum(ctx context.Context, listID int64, checksum string) error {
+	_, err := r.db.ExecContext(ctx, `UPDATE customer_lists SET checksum = ? WHERE id = ?`, checksum, listID)
+	return err
+}
+
+// UpdateCustomerListFile points a customer list at a new source file
+func (r *Repository) UpdateCustomerListFile(ctx context.Context, listID int64, filePath string, count int) error {
+	_, err := r.db.ExecContext(ctx,
+		`UPDATE customer_lists SET file_path = ?, record_count = ? WHERE id = ?`,
+		filePath, count, listID)
+	return err
+}
+
+// UpsertUser creates the user or resets the role and password of an existing
+// one, reporting whether it was created
+func (r *Repository) UpsertUser(ctx context.Context, email, passwordHash, role string) (bool, error) {
+	res, err := r.db.ExecContext(ctx,
+		`UPDATE users SET password_hash = ?, role = ?, active = 1, updated_at = CURRENT_TIMESTAMP WHERE email = ?`,
+		passwordHash, role, email)
+	if err != nil {
+		return false, err
+	}
+	if n, err := res.RowsAffected(); err != nil || n > 0 {
+		return false, err
+	}
+
+	_, err = r.db.ExecContext(ctx,
+		`INSERT INTO users (email, password_hash, role, active, created_at, updated_at)
+		 VALUES (?, ?, ?, 1, CURRENT_TIMESTAMP, CURRENT_TIMESTAMP)`,
+		email, passwordHash, role)
+	return err == nil, err
 }
@@ -0,0 +1,535 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/SanthoshCheemala/FLARE/backend/internal/models"
+)
+
+func TestCustomerLists(t *testing.T) {
+	f := newFixture(t)
+	ctx := context.Background()
+
+	customers, err := f.repo.GetCustomersByListID(ctx, f.customerListID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(customers) != 3 {
+		t.Fatalf("got %d customers, want 3", len(customers))
+	}
+	bob := customers[1]
+	if bob.ExternalID != "C2" || bob.EntityType != models.EntityIndividual || bob.Attributes["passport"] != "X123" {
+		t.Errorf("customer C2 read back as %+v", bob)
+	}
+
+	serialized, err := f.repo.GetCustomerSerializedStrings(ctx, f.customerListID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if serialized[0] != "Alice Smith|1980-01-01|US" {
+		t.Errorf("serialized customer = %q", serialized[0])
+	}
+
+	lists, err := f.repo.GetCustomerLists(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(lists) != 1 || lists[0].RecordCount != 3 || lists[0].Name != "customers" {
+		t.Errorf("customer lists = %+v", lists)
+	}
+
+	if err := f.repo.UpdateCustomerListFile(ctx, f.customerListID, "data/customers-v2.csv", 5); err != nil {
+		t.Fatal(err)
+	}
+	lists, _ = f.repo.GetCustomerLists(ctx)
+	if lists[0].FilePath != "data/customers-v2.csv" || lists[0].RecordCount != 5 {
+		t.Errorf("after file update, list = %+v", lists[0])
+	}
+
+	if err := f.repo.DeleteCustomerList(ctx, f.customerListID); err != nil {
+		t.Fatal(err)
+	}
+	if customers, _ := f.repo.GetCustomersByListID(ctx, f.customerListID); len(customers) != 0 {
+		t.Errorf("%d customers remain after deleting their list", len(customers))
+	}
+	if lists, _ := f.repo.GetCustomerLists(ctx); len(lists) != 0 {
+		t.Errorf("%d customer lists remain after delete", len(lists))
+	}
+}
+
+func TestBulkInsert(t *testing.T) {
+	r := newTestRepo(t)
+	ctx := context.Background()
+	customerListID, _ := seedCustomers(t, r, "bulk customers")
+	sanctionListID, _ := seedSanctions(t, r, "bulk sanctions", "")
+
+	// Span a full prepared chunk and a partial one
+	n := bulkInsertRows*2 + 3
+	customers := make([]*models.Customer, n)
+	sanctions := make([]*models.Sanction, n)
+	for i := range customers {
+		customers[i] = &models.Customer{ExternalID: string(rune('A' + i%26)), Name: "Customer", Hash: int64(i), ListID: customerListID}
+		sanctions[i] = &models.Sanction{Source: "OFAC", Name: "Sanction", Hash: int64(i), ListID: sanctionListID}
+	}
+	if err := r.CreateCustomers(ctx, customers); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.CreateSanctions(ctx, sanctions); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.CreateCustomers(ctx, nil); err != nil {
+		t.Errorf("inserting no customers: %v", err)
+	}
+
+	if got, _ := r.GetCustomersByListID(ctx, customerListID); len(got) != n {
+		t.Errorf("bulk inserted %d customers, read back %d", n, len(got))
+	}
+	got, _ := r.GetSanctionsByListIDs(ctx, []int64{sanctionListID})
+	if len(got) != n {
+		t.Fatalf("bulk inserted %d sanctions, read back %d", n, len(got))
+	}
+	if got[0].EntityType != models.EntityIndividual || got[0].Category != models.ListCategorySanctions {
+		t.Errorf("bulk inserted sanction not defaulted: %+v", got[0])
+	}
+}
+
+func TestGetSanctionsByListIDs(t *testing.T) {
+	f := newFixture(t)
+	ctx := context.Background()
+
+	tests := []struct {
+		name    string
+		listIDs []int64
+		want    []string
+	}{
+		{"none", nil, []string{}},
+		{"one list", []int64{f.sanctionListID}, []string{"Alice Smith"}},
+		{"both lists", []int64{f.sanctionListID, f.pepListID}, []string{"Alice Smith", "Bob Jones"}},
+		{"unknown list", []int64{999}, []string{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sanctions, err := f.repo.GetSanctionsByListIDs(ctx, tt.listIDs)
+			if err != nil {
+				t.Fatal(err)
+			}
+			names := make([]string, len(sanctions))
+			for i, s := range sanctions {
+				names[i] = s.Name
+			}
+			if !reflect.DeepEqual(names, tt.want) {
+				t.Errorf("got %v, want %v", names, tt.want)
+			}
+		})
+	}
+
+	sanctions, _ := f.repo.GetSanctionsByListIDs(ctx, []int64{f.sanctionListID, f.pepListID})
+	if !reflect.DeepEqual(sanctions[0].Aliases, []string{"A. Smith", "Alicia Smith"}) {
+		t.Errorf("aliases = %q", sanctions[0].Aliases)
+	}
+	if sanctions[0].SourceRef != "https://sanctionssearch.ofac.treas.gov/Details.aspx?id=1" || sanctions[1].SourceRef != "" {
+		t.Errorf("source refs = %q, %q", sanctions[0].SourceRef, sanctions[1].SourceRef)
+	}
+	// Entries without a category of their own take their list's
+	if sanctions[1].Category != models.ListCategoryPEP {
+		t.Errorf("PEP entry category = %q", sanctions[1].Category)
+	}
+
+	serialized, err := f.repo.GetSanctionSerializedStrings(ctx, []int64{f.sanctionListID})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if serialized[0] != "Alice Smith|1980-01-01|US|SDGT" {
+		t.Errorf("serialized sanction = %q", serialized[0])
+	}
+}
+
+func TestSanctionValidity(t *testing.T) {
+	r := newTestRepo(t)
+	ctx := context.Background()
+	from := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	to := time.Date(2024, 7, 1, 12, 30, 0, 0, time.UTC)
+
+	listID, _ := seedSanctions(t, r, "OFAC SDN", models.ListCategorySanctions,
+		models.Sanction{Name: "Open", Hash: 1},
+		models.Sanction{Name: "Delisted", Hash: 2, ValidFrom: &from, ValidTo: &to},
+	)
+	// Bulk inserts store the dates as single ones do
+	if err := r.CreateSanctions(ctx, []*models.Sanction{{Name: "Listed later", Hash: 3, ListID: listID, ValidFrom: &to}}); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := r.GetSanctionsByListIDs(ctx, []int64{listID})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 3 {
+		t.Fatalf("got %d sanctions, want 3", len(got))
+	}
+	if got[0].ValidFrom != nil || got[0].ValidTo != nil {
+		t.Errorf("open entry validity = %v, %v", got[0].ValidFrom, got[0].ValidTo)
+	}
+	if got[1].ValidFrom == nil || !got[1].ValidFrom.Equal(from) || got[1].ValidTo == nil || !got[1].ValidTo.Equal(to) {
+		t.Errorf("delisted entry validity = %v, %v", got[1].ValidFrom, got[1].ValidTo)
+	}
+	if got[2].ValidFrom == nil || !got[2].ValidFrom.Equal(to) || got[2].ValidTo != nil {
+		t.Errorf("later entry validity = %v, %v", got[2].ValidFrom, got[2].ValidTo)
+	}
+
+	tests := []struct {
+		at   time.Time
+		want []bool
+	}{
+		{from.Add(-time.Second), []bool{true, false, false}},
+		{from, []bool{true, true, false}},
+		{to.Add(-time.Second), []bool{true, true, false}},
+		{to, []bool{true, false, true}},
+	}
+	for _, tt := range tests {
+		for i, s := range got {
+			if s.InEffect(tt.at) != tt.want[i] {
+				t.Errorf("%s in effect at %s = %v, want %v", s.Name, tt.at, !tt.want[i], tt.want[i])
+			}
+		}
+	}
+}
+
+func TestSanctionListCategories(t *testing.T) {
+	f := newFixture(t)
+	ctx := context.Background()
+
+	tests := []struct {
+		categories []string
+		want       []int64
+	}{
+		{nil, []int64{}},
+		{[]string{models.ListCategorySanctions}, []int64{f.sanctionListID}},
+		{[]string{models.ListCategoryPEP, models.ListCategorySanctions}, []int64{f.sanctionListID, f.pepListID}},
+		{[]string{models.ListCategoryAdverseMedia}, []int64{}},
+	}
+	for _, tt := range tests {
+		ids, err := f.repo.GetSanctionListIDsByCategory(ctx, tt.categories)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(ids, tt.want) {
+			t.Errorf("categories %v: got %v, want %v", tt.categories, ids, tt.want)
+		}
+	}
+
+	lists, err := f.repo.GetSanctionLists(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(lists) != 2 {
+		t.Fatalf("got %d sanction lists, want 2", len(lists))
+	}
+	for _, l := range lists {
+		if l.RecordCount != 1 || l.Version != 1 || !l.Active {
+			t.Errorf("list %s: record count %d, version %d, active %v", l.Name, l.RecordCount, l.Version, l.Active)
+		}
+	}
+}
+
+func TestSetSanctionListActive(t *testing.T) {
+	f := newFixture(t)
+	ctx := context.Background()
+
+	if err := f.repo.SetSanctionListActive(ctx, f.pepListID, false); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.repo.SetSanctionListActive(ctx, 999, false); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("deactivating an unknown list: %v, want sql.ErrNoRows", err)
+	}
+
+	// An inactive list is listed and keeps its records, but no category
+	// expands to it
+	lists, _, err := f.repo.ListSanctionLists(ctx, SanctionListFilter{Sort: "name", Limit: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(lists) != 2 || !lists[0].Active || lists[1].Active {
+		t.Errorf("lists = %+v", lists)
+	}
+	if sanctions, _ := f.repo.GetSanctionsByListIDs(ctx, []int64{f.pepListID}); len(sanctions) != 1 {
+		t.Errorf("%d sanctions on the inactive list, want 1", len(sanctions))
+	}
+	categories := []string{models.ListCategoryPEP, models.ListCategorySanctions}
+	if ids, _ := f.repo.GetSanctionListIDsByCategory(ctx, categories); !reflect.DeepEqual(ids, []int64{f.sanctionListID}) {
+		t.Errorf("active lists by category = %v", ids)
+	}
+
+	if err := f.repo.SetSanctionListActive(ctx, f.pepListID, true); err != nil {
+		t.Fatal(err)
+	}
+	if ids, _ := f.repo.GetSanctionListIDsByCategory(ctx, categories); !reflect.DeepEqual(ids, []int64{f.sanctionListID, f.pepListID}) {
+		t.Errorf("lists by category after reactivating = %v", ids)
+	}
+}
+
+func TestListSanctionLists(t *testing.T) {
+	r := newTestRepo(t)
+	ctx := context.Background()
+	for _, l := range []struct{ name, source, category string }{
+		{"OFAC SDN", "OFAC", models.ListCategorySanctions},
+		{"UN Consolidated", "UN", models.ListCategorySanctions},
+		{"EU PEPs", "EU", models.ListCategoryPEP},
+		{"Adverse media", "Internal", models.ListCategoryAdverseMedia},
+	} {
+		if _, err := r.CreateSanctionList(ctx, l.name, l.source, l.category, l.name+" list", ""); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name      string
+		filter    SanctionListFilter
+		want      []string
+		wantTotal int
+	}{
+		{"all by name", SanctionListFilter{Sort: "name", Limit: 10}, []string{"Adverse media", "EU PEPs", "OFAC SDN", "UN Consolidated"}, 4},
+		{"descending", SanctionListFilter{Sort: "name", Desc: true, Limit: 2}, []string{"UN Consolidated", "OFAC SDN"}, 4},
+		{"page", SanctionListFilter{Sort: "name", Limit: 2, Offset: 2}, []string{"OFAC SDN", "UN Consolidated"}, 4},
+		{"query", SanctionListFilter{Query: "sdn", Limit: 10}, []string{"OFAC SDN"}, 1},
+		{"query matches description", SanctionListFilter{Query: "PEPS LIST", Limit: 10}, []string{"EU PEPs"}, 1},
+		{"source", SanctionListFilter{Source: "UN", Limit: 10}, []string{"UN Consolidated"}, 1},
+		{"category", SanctionListFilter{Category: models.ListCategorySanctions, Sort: "name", Limit: 10}, []string{"OFAC SDN", "UN Consolidated"}, 2},
+		{"unknown sort falls back", SanctionListFilter{Sort: "name; DROP TABLE sanction_lists", Limit: 10}, []string{"OFAC SDN", "UN Consolidated", "EU PEPs", "Adverse media"}, 4},
+		{"created after", SanctionListFilter{CreatedAfter: time.Now().Add(time.Hour), Limit: 10}, []string{}, 0},
+		{"created before", SanctionListFilter{CreatedBefore: time.Now().Add(time.Hour), Source: "EU", Limit: 10}, []string{"EU PEPs"}, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			lists, total, err := r.ListSanctionLists(ctx, tt.filter)
+			if err != nil {
+				t.Fatal(err)
+			}
+			names := make([]string, len(lists))
+			for i, l := range lists {
+				names[i] = l.Name
+			}
+			if !reflect.DeepEqual(names, tt.want) || total != tt.wantTotal {
+				t.Errorf("got %v of %d, want %v of %d", names, total, tt.want, tt.wantTotal)
+			}
+		})
+	}
+}
+
+func TestDeleteSanctionList(t *testing.T) {
+	f := newFixture(t)
+	ctx := context.Background()
+	if err := f.repo.CreatePack(ctx, &models.WatchlistPack{ID: "global", Name: "Global", ListIDs: []int64{f.sanctionListID, f.pepListID}}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := f.repo.DeleteSanctionList(ctx, f.sanctionListID); err != nil {
+		t.Fatal(err)
+	}
+	if sanctions, _ := f.repo.GetSanctionsByListIDs(ctx, []int64{f.sanctionListID}); len(sanctions) != 0 {
+		t.Errorf("%d sanctions remain after deleting their list", len(sanctions))
+	}
+	pack, _ := f.repo.GetPack(ctx, "global")
+	if !reflect.DeepEqual(pack.ListIDs, []int64{f.pepListID}) {
+		t.Errorf("pack lists after delete = %v", pack.ListIDs)
+	}
+
+	// Watchlists keep their entries, which are read from the list's file
+	deleted, err := f.repo.DeleteWatchlist(ctx, f.pepListID)
+	if err != nil || !deleted {
+		t.Fatalf("DeleteWatchlist = %v, %v", deleted, err)
+	}
+	if deleted, _ := f.repo.DeleteWatchlist(ctx, f.pepListID); deleted {
+		t.Error("deleted a watchlist twice")
+	}
+}
+
+func TestReplaceSanctionListVersion(t *testing.T) {
+	f := newFixture(t)
+	ctx := context.Background()
+
+	version, err := f.repo.ReplaceSanctionListVersion(ctx, f.sanctionListID, "data/sdn-v2.csv")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if version != 2 {
+		t.Errorf("version = %d, want 2", version)
+	}
+	if sanctions, _ := f.repo.GetSanctionsByListIDs(ctx, []int64{f.sanctionListID}); len(sanctions) != 0 {
+		t.Errorf("%d sanctions remain in the replaced version", len(sanctions))
+	}
+	if _, err := f.repo.ReplaceSanctionListVersion(ctx, 999, ""); err != sql.ErrNoRows {
+		t.Errorf("replacing an unknown list: %v, want sql.ErrNoRows", err)
+	}
+}
+
+func TestAttributes(t *testing.T) {
+	f := newFixture(t)
+	ctx := context.Background()
+
+	tests := []struct {
+		name string
+		get  func(context.Context, int64) (map[string]string, error)
+		set  func(context.Context, int64, map[string]string) error
+		id   int64
+	}{
+		{"customer", f.repo.GetCustomerAttributes, f.repo.SetCustomerAttributes, f.customers[0].ID},
+		{"sanction", f.repo.GetSanctionAttributes, f.repo.SetSanctionAttributes, f.sanctions[0].ID},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			attributes, err := tt.get(ctx, tt.id)
+			if err != nil || len(attributes) != 0 {
+				t.Fatalf("initial attributes = %v, %v", attributes, err)
+			}
+			want := map[string]string{"passport": "P1", "tax_id": "T1"}
+			if err := tt.set(ctx, tt.id, want); err != nil {
+				t.Fatal(err)
+			}
+			if got, _ := tt.get(ctx, tt.id); !reflect.DeepEqual(got, want) {
+				t.Errorf("got %v, want %v", got, want)
+			}
+			if err := tt.set(ctx, tt.id, nil); err != nil {
+				t.Fatal(err)
+			}
+			if got, _ := tt.get(ctx, tt.id); len(got) != 0 {
+				t.Errorf("cleared attributes read back as %v", got)
+			}
+
+			if _, err := tt.get(ctx, 999); err != sql.ErrNoRows {
+				t.Errorf("get of unknown record: %v, want sql.ErrNoRows", err)
+			}
+			if err := tt.set(ctx, 999, want); err != sql.ErrNoRows {
+				t.Errorf("set of unknown record: %v, want sql.ErrNoRows", err)
+			}
+		})
+	}
+}
+
+func TestSanctionListSchema(t *testing.T) {
+	f := newFixture(t)
+	ctx := context.Background()
+
+	fields, err := f.repo.GetSanctionListSchema(ctx, f.sanctionListID)
+	if err != nil || len(fields) != 0 {
+		t.Fatalf("initial schema = %v, %v", fields, err)
+	}
+	want := []models.ListField{{Name: "passport", Type: models.FieldTypeIdentifier}, {Name: "vessel", Column: "Vessel Name"}}
+	if err := f.repo.SetSanctionListSchema(ctx, f.sanctionListID, want); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := f.repo.GetSanctionListSchema(ctx, f.sanctionListID); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	lists, _ := f.repo.GetSanctionLists(ctx)
+	for _, l := range lists {
+		if l.ID == f.sanctionListID && !reflect.DeepEqual(l.Schema, want) {
+			t.Errorf("listed schema = %v", l.Schema)
+		}
+	}
+
+	if _, err := f.repo.GetSanctionListSchema(ctx, 999); err != sql.ErrNoRows {
+		t.Errorf("get of unknown list: %v, want sql.ErrNoRows", err)
+	}
+	if err := f.repo.SetSanctionListSchema(ctx, 999, want); err != sql.ErrNoRows {
+		t.Errorf("set of unknown list: %v, want sql.ErrNoRows", err)
+	}
+}
+
+func TestListChecksums(t *testing.T) {
+	f := newFixture(t)
+	ctx := context.Background()
+
+	tests := []struct {
+		name string
+		get  func(context.Context, string) (*ListChecksum, error)
+		set  func(context.Context, int64, string) error
+		list string
+		id   int64
+	}{
+		{"customer", f.repo.GetCustomerListChecksum, f.repo.SetCustomerListChecksum, "customers", f.customerListID},
+		{"sanction", f.repo.GetSanctionListChecksum, f.repo.SetSanctionListChecksum, "OFAC SDN", f.sanctionListID},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.set(ctx, tt.id, "abc123"); err != nil {
+				t.Fatal(err)
+			}
+			got, err := tt.get(ctx, tt.list)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got == nil || got.ID != tt.id || got.Checksum != "abc123" {
+				t.Errorf("got %+v", got)
+			}
+			if got, err := tt.get(ctx, "missing"); got != nil || err != nil {
+				t.Errorf("unknown list: %+v, %v", got, err)
+			}
+		})
+	}
+}
+
+func TestUsers(t *testing.T) {
+	r := newTestRepo(t)
+	ctx := context.Background()
+
+	if u, err := r.GetUserByEmail(ctx, "admin@example.com"); u != nil || err != nil {
+		t.Fatalf("unknown user: %+v, %v", u, err)
+	}
+
+	created, err := r.UpsertUser(ctx, "admin@example.com", "hash1", "admin")
+	if err != nil || !created {
+		t.Fatalf("first upsert = %v, %v", created, err)
+	}
+	created, err = r.UpsertUser(ctx, "admin@example.com", "hash2", "compliance")
+	if err != nil || created {
+		t.Fatalf("second upsert = %v, %v", created, err)
+	}
+
+	u, err := r.GetUserByEmail(ctx, "admin@example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if u.PasswordHash != "hash2" || u.Role != "compliance" || !u.Active || u.LastLoginAt != nil {
+		t.Errorf("user = %+v", u)
+	}
+
+	if err := r.UpdateUserLastLogin(ctx, u.ID); err != nil {
+		t.Fatal(err)
+	}
+	if u, _ := r.GetUserByEmail(ctx, "admin@example.com"); u.LastLoginAt == nil {
+		t.Error("last login not recorded")
+	}
+}
+
+func TestAuditLogs(t *testing.T) {
+	r := newTestRepo(t)
+	ctx := context.Background()
+
+	for _, action := range []string{"MATCH_UPDATE", "MATCH_APPROVE"} {
+		if err := r.CreateAuditLog(ctx, &models.AuditLog{
+			ActorID: 7, Action: action, EntityType: "screening_result", EntityID: "42",
+			Details: map[string]interface{}{"status": "CONFIRMED"},
+		}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := r.CreateAuditLog(ctx, &models.AuditLog{ActorID: 7, Action: "LOGIN", EntityType: "user", EntityID: "7"}); err != nil {
+		t.Fatal(err)
+	}
+
+	logs, err := r.ListAuditLogs(ctx, "screening_result", "42")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(logs) != 2 || logs[0].Action != "MATCH_UPDATE" || logs[1].Action != "MATCH_APPROVE" {
+		t.Fatalf("audit trail = %+v", logs)
+	}
+	if logs[0].Details["status"] != "CONFIRMED" || logs[0].ActorID != 7 {
+		t.Errorf("audit entry = %+v", logs[0])
+	}
+}
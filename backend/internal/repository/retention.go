@@ -0,0 +1,73 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/SanthoshCheemala/FLARE/backend/internal/models"
+)
+
+// ReduceSanctionCopies reduces the sanction records the PSI server resolved
+// for results created before before to references, along with the sanction
+// values in the results' explanations, in one transaction. Records of local
+// watchlists are the client's own and are kept. It returns the number of
+// records reduced.
+func (r *Repository) ReduceSanctionCopies(ctx context.Context, before time.Time) (int, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.QueryContext(ctx,
+		`SELECT sr.id, COALESCE(sr.explanation, ''), s.id
+		 FROM screening_results sr
+		 JOIN sanctions s ON sr.sanction_id = s.id
+		 WHERE COALESCE(sr.list_source, 'REMOTE') != ? AND sr.created_at < ?
+		   AND COALESCE(s.retention, 'full') != ?`,
+		models.ListSourceLocal, before.UTC().Format("2006-01-02 15:04:05"), models.RetentionReference)
+	if err != nil {
+		return 0, err
+	}
+	explanations := make(map[int64]string)
+	sanctions := make(map[int64]bool)
+	for rows.Next() {
+		var resultID, sanctionID int64
+		var explanation string
+		if err := rows.Scan(&resultID, &explanation, &sanctionID); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		explanations[resultID] = explanation
+		sanctions[sanctionID] = true
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	for id := range sanctions {
+		if _, err := tx.ExecContext(ctx,
+			`UPDATE sanctions SET name = '', dob = '', country = '', program = '', aliases = '', imo_number = '',
+			 registration = '', category = '', attributes = '', retention = ? WHERE id = ?`,
+			models.RetentionReference, id); err != nil {
+			return 0, err
+		}
+	}
+	for id, raw := range explanations {
+		explanation := decodeExplanation(raw)
+		if explanation == nil {
+			continue
+		}
+		explanation.Retain(models.RetentionReference)
+		encoded, err := json.Marshal(explanation)
+		if err != nil {
+			return 0, err
+		}
+		if _, err := tx.ExecContext(ctx, `UPDATE screening_results SET explanation = ? WHERE id = ?`, string(encoded), id); err != nil {
+			return 0, err
+		}
+	}
+	return len(sanctions), tx.Commit()
+}
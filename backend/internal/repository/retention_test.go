@@ -0,0 +1,62 @@
+package repository
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/SanthoshCheemala/FLARE/backend/internal/models"
+)
+
+func TestReduceSanctionCopies(t *testing.T) {
+	f := newFixture(t)
+	ctx := context.Background()
+
+	explanation := &models.MatchExplanation{
+		Columns:      []string{"name", "dob"},
+		Fields:       []models.FieldComparison{{Column: "name", Customer: "alice smith", Sanction: "alice smith", Equal: true}},
+		MatchedAlias: "A. Smith",
+	}
+	local := &models.Sanction{Source: "Internal", Name: "Carol White", Hash: 103, ListID: 99}
+	records := []models.MatchRecord{
+		{Customer: f.customers[0], Sanction: f.sanctions[0], Result: &models.ScreeningResult{Status: "PENDING", Explanation: explanation}},
+		{Customer: f.customers[1], Sanction: f.sanctions[1], Result: &models.ScreeningResult{Status: "CONFIRMED"}},
+		{Customer: f.customers[2], Sanction: local, Result: &models.ScreeningResult{Status: "PENDING", ListSource: models.ListSourceLocal}},
+	}
+	if err := f.repo.SaveScreeningResults(ctx, f.screening.ID, records); err != nil {
+		t.Fatal(err)
+	}
+
+	// Bob's result is too recent to reduce
+	exec(t, f.repo, `UPDATE screening_results SET created_at = datetime('now', '-10 days') WHERE id != ?`, records[1].Result.ID)
+	before := time.Now().AddDate(0, 0, -5)
+
+	n, err := f.repo.ReduceSanctionCopies(ctx, before)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 1 {
+		t.Errorf("reduced %d sanctions, want 1", n)
+	}
+	if n, _ := f.repo.ReduceSanctionCopies(ctx, before); n != 0 {
+		t.Errorf("reduced %d sanctions again", n)
+	}
+
+	alice, _ := f.repo.GetScreeningResultDetail(ctx, records[0].Result.ID)
+	if s := alice.Sanction; s.Retention != models.RetentionReference || s.Name != "" || s.DOB != "" || s.Program != "" ||
+		s.Source != "OFAC" || s.Hash != 101 || s.SourceRef != f.sanctions[0].SourceRef {
+		t.Errorf("reduced sanction = %+v", s)
+	}
+	if e := alice.Explanation; e.Fields[0].Sanction != "" || e.Fields[0].Customer != "alice smith" || e.MatchedAlias != "" {
+		t.Errorf("reduced explanation = %+v", e)
+	}
+
+	bob, _ := f.repo.GetScreeningResultDetail(ctx, records[1].Result.ID)
+	if bob.Sanction.Retention != models.RetentionFull || bob.Sanction.Name != "Bob Jones" {
+		t.Errorf("recent sanction reduced: %+v", bob.Sanction)
+	}
+	carol, _ := f.repo.GetScreeningResultDetail(ctx, records[2].Result.ID)
+	if carol.Sanction.Name != "Carol White" {
+		t.Errorf("local watchlist entry reduced: %+v", carol.Sanction)
+	}
+}
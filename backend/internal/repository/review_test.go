@@ -0,0 +1,172 @@
+package repository
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/SanthoshCheemala/FLARE/backend/internal/models"
+)
+
+func TestResultComments(t *testing.T) {
+	f := newFixture(t)
+	ctx := context.Background()
+	resultID := f.results[0].ID
+
+	root := &models.ResultComment{ResultID: resultID, AuthorID: 1, Author: "alice", Body: "Checking the DOB",
+		Attachments: []models.CommentAttachment{
+			{FileName: "passport.pdf", ContentType: "application/pdf", Size: 1024, SHA256: "aa", FilePath: "data/a/1"},
+			{FileName: "note.txt", ContentType: "text/plain", Size: 10, SHA256: "bb", FilePath: "data/a/2"},
+		}}
+	if err := f.repo.CreateResultComment(ctx, root); err != nil {
+		t.Fatal(err)
+	}
+	reply := &models.ResultComment{ResultID: resultID, ParentID: &root.ID, Author: "bob", Body: "DOB differs"}
+	if err := f.repo.CreateResultComment(ctx, reply); err != nil {
+		t.Fatal(err)
+	}
+	// A comment on another result stays out of the thread
+	if err := f.repo.CreateResultComment(ctx, &models.ResultComment{ResultID: f.results[1].ID, Author: "carol", Body: "Confirmed"}); err != nil {
+		t.Fatal(err)
+	}
+
+	comments, err := f.repo.ListResultComments(ctx, resultID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(comments) != 2 {
+		t.Fatalf("got %d comments, want 2", len(comments))
+	}
+	if comments[0].ID != root.ID || len(comments[0].Attachments) != 2 || comments[0].Attachments[0].FileName != "passport.pdf" {
+		t.Errorf("root comment = %+v", comments[0])
+	}
+	if comments[1].ParentID == nil || *comments[1].ParentID != root.ID || len(comments[1].Attachments) != 0 {
+		t.Errorf("reply = %+v", comments[1])
+	}
+
+	c, err := f.repo.GetResultComment(ctx, reply.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Author != "bob" || c.AuthorID != 0 || c.ParentID == nil || *c.ParentID != root.ID {
+		t.Errorf("GetResultComment = %+v", c)
+	}
+	if c, err := f.repo.GetResultComment(ctx, 999); c != nil || err != nil {
+		t.Errorf("unknown comment: %+v, %v", c, err)
+	}
+
+	a, err := f.repo.GetCommentAttachment(ctx, root.Attachments[1].ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if a.CommentID != root.ID || a.FilePath != "data/a/2" || a.Size != 10 {
+		t.Errorf("attachment = %+v", a)
+	}
+	if a, err := f.repo.GetCommentAttachment(ctx, 999); a != nil || err != nil {
+		t.Errorf("unknown attachment: %+v, %v", a, err)
+	}
+}
+
+func TestNotesMigrateToComments(t *testing.T) {
+	f := newFixture(t)
+	ctx := context.Background()
+	exec(t, f.repo, `UPDATE screening_results SET notes = 'Legacy note' WHERE id = ?`, f.results[0].ID)
+
+	// Carried over once, however often the schema is applied
+	for i := 0; i < 2; i++ {
+		if err := f.repo.InitSchema(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	comments, err := f.repo.ListResultComments(ctx, f.results[0].ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(comments) != 1 || comments[0].Author != "notes" || comments[0].Body != "Legacy note" {
+		t.Errorf("migrated comments = %+v", comments)
+	}
+}
+
+func TestSuppressions(t *testing.T) {
+	f := newFixture(t)
+	ctx := context.Background()
+
+	s := &models.Suppression{Fingerprint: "fp-1", CustomerListID: f.customerListID, CustomerExternalID: "C1",
+		SanctionSource: "OFAC", SanctionName: "Alice Smith", ResultID: f.results[0].ID, Reason: "Different DOB", CreatedBy: "alice"}
+	if err := f.repo.CreateSuppression(ctx, s, 30); err != nil {
+		t.Fatal(err)
+	}
+	if !s.Active || s.CreatedAt.IsZero() || s.ExpiresAt.Sub(s.CreatedAt) < 29*24*time.Hour {
+		t.Errorf("created suppression = %+v", s)
+	}
+
+	// A new suppression of the same pair supersedes the first
+	superseding := *s
+	superseding.Reason = "Confirmed different person"
+	if err := f.repo.CreateSuppression(ctx, &superseding, 90); err != nil {
+		t.Fatal(err)
+	}
+	if first, _ := f.repo.GetSuppression(ctx, s.ID); first.Active || first.RevokedBy != "alice" || first.RevokedAt == nil {
+		t.Errorf("superseded suppression = %+v", first)
+	}
+
+	// One already expired
+	expired := &models.Suppression{Fingerprint: "fp-2", CustomerListID: f.customerListID, ResultID: f.results[1].ID}
+	if err := f.repo.CreateSuppression(ctx, expired, 0); err != nil {
+		t.Fatal(err)
+	}
+	exec(t, f.repo, `UPDATE suppressions SET expires_at = datetime('now', '-1 day') WHERE id = ?`, expired.ID)
+
+	active, err := f.repo.ActiveSuppressions(ctx, f.customerListID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(active) != 1 || active["fp-1"] != superseding.ID {
+		t.Errorf("active suppressions = %v", active)
+	}
+	if active, _ := f.repo.ActiveSuppressions(ctx, 999); len(active) != 0 {
+		t.Errorf("another list has active suppressions %v", active)
+	}
+
+	tests := []struct {
+		all  bool
+		want int
+	}{
+		{false, 1},
+		{true, 3},
+	}
+	for _, tt := range tests {
+		list, err := f.repo.ListSuppressions(ctx, tt.all)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(list) != tt.want {
+			t.Errorf("ListSuppressions(all=%v) returned %d, want %d", tt.all, len(list), tt.want)
+		}
+	}
+
+	if err := f.repo.RecordSuppressionHits(ctx, map[int64]int{superseding.ID: 2}); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.repo.RecordSuppressionHits(ctx, map[int64]int{superseding.ID: 3}); err != nil {
+		t.Fatal(err)
+	}
+	got, _ := f.repo.GetSuppression(ctx, superseding.ID)
+	if got.Hits != 5 || got.LastHitAt == nil {
+		t.Errorf("hits = %d, last hit %v", got.Hits, got.LastHitAt)
+	}
+
+	revoked, err := f.repo.RevokeSuppression(ctx, superseding.ID, "bob")
+	if err != nil || !revoked {
+		t.Fatalf("RevokeSuppression = %v, %v", revoked, err)
+	}
+	if revoked, _ := f.repo.RevokeSuppression(ctx, superseding.ID, "bob"); revoked {
+		t.Error("revoked a suppression twice")
+	}
+	if active, _ := f.repo.ActiveSuppressions(ctx, f.customerListID); len(active) != 0 {
+		t.Errorf("active suppressions after revoke = %v", active)
+	}
+	if s, err := f.repo.GetSuppression(ctx, 999); s != nil || err != nil {
+		t.Errorf("unknown suppression: %+v, %v", s, err)
+	}
+}
@@ -6,6 +6,7 @@ CREATE TABLE IF NOT EXISTS customer_lists (
     name TEXT NOT NULL,
     description TEXT,
     file_path TEXT,
+    checksum TEXT,
     record_count INTEGER DEFAULT 0,
     uploaded_by INTEGER NOT NULL,
     created_at DATETIME DEFAULT CURRENT_TIMESTAMP
@@ -20,6 +21,11 @@ CREATE TABLE IF NOT EXISTS customers (
     hash INTEGER NOT NULL,
     list_id INTEGER NOT NULL,
     created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    entity_type TEXT DEFAULT 'individual',
+    registration TEXT DEFAULT '',
+    imo_number TEXT DEFAULT '',
+    attributes TEXT DEFAULT '',
+    hash_version INTEGER DEFAULT 0,
     FOREIGN KEY (list_id) REFERENCES customer_lists(id)
 );
 
@@ -27,10 +33,14 @@ CREATE TABLE IF NOT EXISTS sanction_lists (
     id INTEGER PRIMARY KEY AUTOINCREMENT,
     name TEXT NOT NULL,
     source TEXT NOT NULL,
+    category TEXT DEFAULT 'SANCTIONS',
     description TEXT,
     file_path TEXT,
+    checksum TEXT,
     record_count INTEGER DEFAULT 0,
     version INTEGER DEFAULT 1,
+    schema TEXT DEFAULT '',
+    active INTEGER DEFAULT 1,
     created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
     updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
 );
@@ -46,6 +56,17 @@ CREATE TABLE IF NOT EXISTS sanctions (
     list_id INTEGER NOT NULL,
     updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
     version INTEGER DEFAULT 1,
+    entity_type TEXT DEFAULT 'individual',
+    aliases TEXT DEFAULT '',
+    imo_number TEXT DEFAULT '',
+    registration TEXT DEFAULT '',
+    category TEXT DEFAULT '',
+    attributes TEXT DEFAULT '',
+    hash_version INTEGER DEFAULT 0,
+    retention TEXT DEFAULT 'full',
+    valid_from DATETIME,
+    valid_to DATETIME,
+    source_ref TEXT DEFAULT '',
     FOREIGN KEY (list_id) REFERENCES sanction_lists(id)
 );
 
@@ -55,19 +76,41 @@ CREATE TABLE IF NOT EXISTS screenings (
     name TEXT NOT NULL,
     customer_list_id INTEGER NOT NULL,
     sanction_list_ids TEXT NOT NULL,
+    list_source TEXT DEFAULT 'REMOTE',
+    watchlist_ids TEXT DEFAULT '',
+    idempotency_scope TEXT DEFAULT '',
+    idempotency_key TEXT DEFAULT '',
+    request_hash TEXT DEFAULT '',
     status TEXT NOT NULL,
     match_count INTEGER DEFAULT 0,
     customer_count INTEGER DEFAULT 0,
     sanction_count INTEGER DEFAULT 0,
     worker_count INTEGER DEFAULT 0,
     memory_estimate_mb REAL DEFAULT 0,
+    memory_limit_gb REAL DEFAULT 0,
     started_at DATETIME,
     finished_at DATETIME,
+    error TEXT,
     created_by INTEGER NOT NULL,
     created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
     FOREIGN KEY (customer_list_id) REFERENCES customer_lists(id)
 );
 
+CREATE TABLE IF NOT EXISTS screening_matches (
+    screening_id INTEGER PRIMARY KEY,
+    session_id TEXT NOT NULL,
+    hashes TEXT NOT NULL,
+    hash_algorithm TEXT DEFAULT '',
+    hash_version INTEGER DEFAULT 0,
+    hash_salt BLOB,
+    column_mapping TEXT DEFAULT '',
+    enabled_columns TEXT DEFAULT '',
+    transliteration TEXT DEFAULT '',
+    resolved INTEGER DEFAULT 0,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (screening_id) REFERENCES screenings(id)
+);
+
 CREATE TABLE IF NOT EXISTS screening_results (
     id INTEGER PRIMARY KEY AUTOINCREMENT,
     screening_id INTEGER NOT NULL,
@@ -77,6 +120,14 @@ CREATE TABLE IF NOT EXISTS screening_results (
     status TEXT NOT NULL,
     investigator_id INTEGER,
     notes TEXT,
+    explanation TEXT DEFAULT '',
+    proposed_by TEXT DEFAULT '',
+    approved_by TEXT DEFAULT '',
+    approved_at DATETIME,
+    suppression_id INTEGER,
+    list_source TEXT DEFAULT 'REMOTE',
+    lists TEXT DEFAULT '',
+    match_channel TEXT DEFAULT 'NAME_MATCH',
     created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
     updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
     FOREIGN KEY (screening_id) REFERENCES screenings(id),
@@ -84,6 +135,68 @@ CREATE TABLE IF NOT EXISTS screening_results (
     FOREIGN KEY (sanction_id) REFERENCES sanctions(id)
 );
 
+CREATE TABLE IF NOT EXISTS result_comments (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    result_id INTEGER NOT NULL,
+    parent_id INTEGER,
+    author_id INTEGER DEFAULT 0,
+    author TEXT NOT NULL,
+    body TEXT NOT NULL,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (result_id) REFERENCES screening_results(id),
+    FOREIGN KEY (parent_id) REFERENCES result_comments(id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_result_comments_result ON result_comments(result_id);
+
+CREATE TABLE IF NOT EXISTS suppressions (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    fingerprint TEXT NOT NULL,
+    customer_list_id INTEGER NOT NULL,
+    customer_external_id TEXT NOT NULL,
+    sanction_source TEXT DEFAULT '',
+    sanction_name TEXT DEFAULT '',
+    result_id INTEGER NOT NULL,
+    reason TEXT DEFAULT '',
+    created_by TEXT DEFAULT '',
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    expires_at DATETIME NOT NULL,
+    revoked_by TEXT DEFAULT '',
+    revoked_at DATETIME,
+    hits INTEGER DEFAULT 0,
+    last_hit_at DATETIME,
+    FOREIGN KEY (result_id) REFERENCES screening_results(id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_suppressions_fingerprint ON suppressions(customer_list_id, fingerprint);
+
+CREATE TABLE IF NOT EXISTS comment_attachments (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    comment_id INTEGER NOT NULL,
+    file_name TEXT NOT NULL,
+    content_type TEXT NOT NULL,
+    size INTEGER NOT NULL,
+    sha256 TEXT NOT NULL,
+    file_path TEXT NOT NULL,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (comment_id) REFERENCES result_comments(id)
+);
+
+CREATE TABLE IF NOT EXISTS screening_metrics (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    screening_id INTEGER NOT NULL UNIQUE,
+    encryption_ms INTEGER DEFAULT 0,
+    network_ms INTEGER DEFAULT 0,
+    intersection_ms INTEGER DEFAULT 0,
+    resolve_ms INTEGER DEFAULT 0,
+    persist_ms INTEGER DEFAULT 0,
+    total_ms INTEGER DEFAULT 0,
+    record_count INTEGER DEFAULT 0,
+    hash_collisions INTEGER DEFAULT 0,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (screening_id) REFERENCES screenings(id)
+);
+
 CREATE TABLE IF NOT EXISTS users (
     id INTEGER PRIMARY KEY AUTOINCREMENT,
     email TEXT NOT NULL UNIQUE,
@@ -96,6 +209,62 @@ CREATE TABLE IF NOT EXISTS users (
     updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
 );
 
+CREATE TABLE IF NOT EXISTS watchlist_packs (
+    id TEXT PRIMARY KEY,
+    name TEXT NOT NULL,
+    description TEXT DEFAULT '',
+    version INTEGER DEFAULT 1,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS watchlist_pack_lists (
+    pack_id TEXT NOT NULL,
+    list_id INTEGER NOT NULL,
+    PRIMARY KEY (pack_id, list_id),
+    FOREIGN KEY (pack_id) REFERENCES watchlist_packs(id),
+    FOREIGN KEY (list_id) REFERENCES sanction_lists(id)
+);
+
+CREATE TABLE IF NOT EXISTS pack_subscriptions (
+    pack_id TEXT PRIMARY KEY,
+    name TEXT DEFAULT '',
+    version INTEGER DEFAULT 0,
+    deleted BOOLEAN DEFAULT 0,
+    updated_at DATETIME,
+    subscribed_at DATETIME DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS api_keys (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    name TEXT NOT NULL,
+    prefix TEXT NOT NULL,
+    key_hash TEXT NOT NULL UNIQUE,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    last_used_at DATETIME,
+    revoked_at DATETIME,
+    max_sessions_per_day INTEGER DEFAULT 0,
+    max_ciphertexts_per_request INTEGER DEFAULT 0,
+    max_concurrent_sessions INTEGER DEFAULT 0,
+    min_resolve_ciphertexts INTEGER DEFAULT 0
+);
+
+CREATE TABLE IF NOT EXISTS session_events (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    session_id TEXT NOT NULL DEFAULT '',
+    event TEXT NOT NULL,
+    client TEXT,
+    api_key_id INTEGER DEFAULT 0,
+    batch INTEGER DEFAULT 0,
+    ciphertext_count INTEGER DEFAULT 0,
+    match_count INTEGER DEFAULT 0,
+    duration_ms INTEGER DEFAULT 0,
+    detail TEXT,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_session_events_session ON session_events(session_id);
+
 CREATE TABLE IF NOT EXISTS audit_logs (
     id INTEGER PRIMARY KEY AUTOINCREMENT,
     actor_id INTEGER NOT NULL,
@@ -109,6 +278,31 @@ CREATE TABLE IF NOT EXISTS audit_logs (
 
 `
 
+// customerRiskView aggregates each customer's matches across screenings.
+// The score orders customers for review: confirmed matches outweigh open
+// ones, which outweigh breadth of programs and recurrence.
+const customerRiskView = `
+CREATE VIEW customer_risk AS
+SELECT customer_id, external_id, name, list_id, screenings, hits, confirmed, false_positives, pending, programs,
+       confirmed * 100 + pending * 10 + program_count * 5 + screenings AS score,
+       CASE WHEN confirmed > 0 THEN 'HIGH'
+            WHEN pending > 0 OR screenings >= 3 THEN 'MEDIUM'
+            ELSE 'LOW' END AS tier
+FROM (
+    SELECT MAX(c.id) AS customer_id, c.external_id, MAX(c.name) AS name, c.list_id,
+           COUNT(DISTINCT sr.screening_id) AS screenings,
+           COUNT(sr.id) AS hits,
+           SUM(CASE WHEN sr.status = 'CONFIRMED' THEN 1 ELSE 0 END) AS confirmed,
+           SUM(CASE WHEN sr.status IN ('FALSE_POSITIVE', 'SUPPRESSED') THEN 1 ELSE 0 END) AS false_positives,
+           SUM(CASE WHEN sr.status IN ('PENDING', 'PENDING_APPROVAL') THEN 1 ELSE 0 END) AS pending,
+           COALESCE(GROUP_CONCAT(DISTINCT CASE WHEN sr.status NOT IN ('FALSE_POSITIVE', 'SUPPRESSED') THEN NULLIF(s.program, '') END), '') AS programs,
+           COUNT(DISTINCT CASE WHEN sr.status NOT IN ('FALSE_POSITIVE', 'SUPPRESSED') THEN NULLIF(s.program, '') END) AS program_count
+    FROM screening_results sr
+    JOIN customers c ON sr.customer_id = c.id
+    JOIN sanctions s ON sr.sanction_id = s.id
+    GROUP BY c.list_id, c.external_id
+)`
+
 func (r *Repository) InitSchema() error {
 	if _, err := r.db.Exec(SQLiteSchema); err != nil {
 		return err
@@ -119,6 +313,62 @@ func (r *Repository) InitSchema() error {
 	// In a production system, we would use a proper migration tool.
 	r.db.Exec(`ALTER TABLE customer_lists ADD COLUMN file_path TEXT`)
 	r.db.Exec(`ALTER TABLE sanction_lists ADD COLUMN file_path TEXT`)
+	r.db.Exec(`ALTER TABLE screenings ADD COLUMN error TEXT`)
+	r.db.Exec(`ALTER TABLE customer_lists ADD COLUMN checksum TEXT`)
+	r.db.Exec(`ALTER TABLE sanction_lists ADD COLUMN checksum TEXT`)
+	r.db.Exec(`ALTER TABLE screenings ADD COLUMN memory_limit_gb REAL DEFAULT 0`)
+	r.db.Exec(`ALTER TABLE session_events ADD COLUMN api_key_id INTEGER DEFAULT 0`)
+	r.db.Exec(`ALTER TABLE api_keys ADD COLUMN max_sessions_per_day INTEGER DEFAULT 0`)
+	r.db.Exec(`ALTER TABLE api_keys ADD COLUMN max_ciphertexts_per_request INTEGER DEFAULT 0`)
+	r.db.Exec(`ALTER TABLE api_keys ADD COLUMN max_concurrent_sessions INTEGER DEFAULT 0`)
+	r.db.Exec(`ALTER TABLE api_keys ADD COLUMN min_resolve_ciphertexts INTEGER DEFAULT 0`)
+	r.db.Exec(`ALTER TABLE sanctions ADD COLUMN entity_type TEXT DEFAULT 'individual'`)
+	r.db.Exec(`ALTER TABLE sanctions ADD COLUMN aliases TEXT DEFAULT ''`)
+	r.db.Exec(`ALTER TABLE sanctions ADD COLUMN imo_number TEXT DEFAULT ''`)
+	r.db.Exec(`ALTER TABLE sanctions ADD COLUMN registration TEXT DEFAULT ''`)
+	r.db.Exec(`ALTER TABLE customers ADD COLUMN entity_type TEXT DEFAULT 'individual'`)
+	r.db.Exec(`ALTER TABLE customers ADD COLUMN registration TEXT DEFAULT ''`)
+	r.db.Exec(`ALTER TABLE customers ADD COLUMN imo_number TEXT DEFAULT ''`)
+	r.db.Exec(`ALTER TABLE sanction_lists ADD COLUMN category TEXT DEFAULT 'SANCTIONS'`)
+	r.db.Exec(`ALTER TABLE sanctions ADD COLUMN category TEXT DEFAULT ''`)
+	r.db.Exec(`ALTER TABLE screenings ADD COLUMN list_source TEXT DEFAULT 'REMOTE'`)
+	r.db.Exec(`ALTER TABLE screenings ADD COLUMN watchlist_ids TEXT DEFAULT ''`)
+	r.db.Exec(`ALTER TABLE screening_metrics ADD COLUMN hash_collisions INTEGER DEFAULT 0`)
+	r.db.Exec(`ALTER TABLE screening_results ADD COLUMN explanation TEXT DEFAULT ''`)
+	r.db.Exec(`ALTER TABLE screening_results ADD COLUMN proposed_by TEXT DEFAULT ''`)
+	r.db.Exec(`ALTER TABLE screening_results ADD COLUMN approved_by TEXT DEFAULT ''`)
+	r.db.Exec(`ALTER TABLE screening_results ADD COLUMN approved_at DATETIME`)
+	r.db.Exec(`ALTER TABLE screening_results ADD COLUMN suppression_id INTEGER`)
+	r.db.Exec(`ALTER TABLE screening_results ADD COLUMN list_source TEXT DEFAULT 'REMOTE'`)
+	r.db.Exec(`ALTER TABLE screening_results ADD COLUMN lists TEXT DEFAULT ''`)
+	r.db.Exec(`ALTER TABLE sanction_lists ADD COLUMN schema TEXT DEFAULT ''`)
+	r.db.Exec(`ALTER TABLE sanctions ADD COLUMN attributes TEXT DEFAULT ''`)
+	r.db.Exec(`ALTER TABLE customers ADD COLUMN attributes TEXT DEFAULT ''`)
+	r.db.Exec(`ALTER TABLE screening_results ADD COLUMN match_channel TEXT DEFAULT 'NAME_MATCH'`)
+	r.db.Exec(`ALTER TABLE screenings ADD COLUMN idempotency_key TEXT DEFAULT ''`)
+	r.db.Exec(`ALTER TABLE screenings ADD COLUMN request_hash TEXT DEFAULT ''`)
+	r.db.Exec(`CREATE INDEX IF NOT EXISTS idx_screenings_idempotency_key ON screenings(idempotency_key)`)
+	// Keys stored before scopes existed match no request
+	r.db.Exec(`ALTER TABLE screenings ADD COLUMN idempotency_scope TEXT DEFAULT ''`)
+	r.db.Exec(`ALTER TABLE customers ADD COLUMN hash_version INTEGER DEFAULT 0`)
+	r.db.Exec(`ALTER TABLE sanctions ADD COLUMN hash_version INTEGER DEFAULT 0`)
+	r.db.Exec(`ALTER TABLE sanctions ADD COLUMN retention TEXT DEFAULT 'full'`)
+	r.db.Exec(`ALTER TABLE sanction_lists ADD COLUMN active INTEGER DEFAULT 1`)
+	r.db.Exec(`ALTER TABLE sanctions ADD COLUMN valid_from DATETIME`)
+	r.db.Exec(`ALTER TABLE sanctions ADD COLUMN valid_to DATETIME`)
+	r.db.Exec(`ALTER TABLE sanctions ADD COLUMN source_ref TEXT DEFAULT ''`)
+
+	// Notes predate comment threads; carry each over as the result's first
+	// comment
+	r.db.Exec(`INSERT INTO result_comments (result_id, author, body, created_at)
+		SELECT id, 'notes', notes, updated_at FROM screening_results
+		WHERE COALESCE(notes, '') != '' AND id NOT IN (SELECT result_id FROM result_comments)`)
+
+	// Views are recreated so they follow the tables they read
+	r.db.Exec(`DROP VIEW IF EXISTS customer_risk`)
+	if _, err := r.db.Exec(customerRiskView); err != nil {
+		return err
+	}
 
 	return nil
 }
@@ -0,0 +1,426 @@
+package repository
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/SanthoshCheemala/FLARE/backend/internal/models"
+)
+
+func TestScreenings(t *testing.T) {
+	f := newFixture(t)
+	ctx := context.Background()
+
+	s, err := f.repo.GetScreeningByJobID(ctx, "job-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.ID != f.screening.ID || s.ListSource != models.ListSourceRemote ||
+		!reflect.DeepEqual(s.SanctionListIDs, []int64{f.sanctionListID, f.pepListID}) || len(s.WatchlistIDs) != 0 {
+		t.Errorf("screening read back as %+v", s)
+	}
+	if s, err := f.repo.GetScreeningByJobID(ctx, "missing"); s != nil || err != nil {
+		t.Errorf("unknown job: %+v, %v", s, err)
+	}
+
+	if err := f.repo.UpdateScreeningStatus(ctx, "job-1", "FAILED", 4); err != nil {
+		t.Fatal(err)
+	}
+	s, _ = f.repo.GetScreeningByJobID(ctx, "job-1")
+	if s.Status != "FAILED" || s.MatchCount != 4 || s.FinishedAt.IsZero() {
+		t.Errorf("after status update, screening = %+v", s)
+	}
+
+	started := time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC)
+	final := *s
+	final.Status, final.MatchCount, final.CustomerCount, final.WorkerCount = "COMPLETED", 2, 3, 4
+	final.StartedAt, final.FinishedAt, final.Error = started, started.Add(time.Minute), ""
+	if err := f.repo.UpdateScreeningFinal(ctx, &final); err != nil {
+		t.Fatal(err)
+	}
+	s, _ = f.repo.GetScreeningByJobID(ctx, "job-1")
+	if s.Status != "COMPLETED" || s.CustomerCount != 3 || s.WorkerCount != 4 ||
+		!s.StartedAt.Equal(started) || !s.FinishedAt.Equal(started.Add(time.Minute)) {
+		t.Errorf("after final update, screening = %+v", s)
+	}
+
+	hybrid := &models.Screening{JobID: "job-2", CustomerListID: f.customerListID, ListSource: models.ListSourceHybrid,
+		SanctionListIDs: []int64{f.sanctionListID}, WatchlistIDs: []int64{7, 8}, Status: "RUNNING"}
+	if err := f.repo.CreateScreening(ctx, hybrid); err != nil {
+		t.Fatal(err)
+	}
+	s, _ = f.repo.GetScreeningByJobID(ctx, "job-2")
+	if s.ListSource != models.ListSourceHybrid || !reflect.DeepEqual(s.WatchlistIDs, []int64{7, 8}) {
+		t.Errorf("hybrid screening read back as %+v", s)
+	}
+}
+
+func TestListScreenings(t *testing.T) {
+	f := newFixture(t)
+	ctx := context.Background()
+	seedScreening(t, f.repo, "job-2", f.customerListID)
+	if err := f.repo.UpdateScreeningStatus(ctx, "job-2", "FAILED", 0); err != nil {
+		t.Fatal(err)
+	}
+	seedScreening(t, f.repo, "job-3", f.customerListID)
+
+	tests := []struct {
+		name          string
+		statuses      []string
+		limit, offset int
+		want          []string
+		wantTotal     int
+	}{
+		{"all, newest first", nil, 10, 0, []string{"job-3", "job-2", "job-1"}, 3},
+		{"page", nil, 1, 1, []string{"job-2"}, 3},
+		{"status", []string{"FAILED"}, 10, 0, []string{"job-2"}, 1},
+		{"statuses", []string{"FAILED", "COMPLETED"}, 10, 0, []string{"job-3", "job-2", "job-1"}, 3},
+		{"no match", []string{"RUNNING"}, 10, 0, []string{}, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			screenings, total, err := f.repo.ListScreenings(ctx, tt.statuses, tt.limit, tt.offset)
+			if err != nil {
+				t.Fatal(err)
+			}
+			jobs := make([]string, len(screenings))
+			for i, s := range screenings {
+				jobs[i] = s.JobID
+			}
+			if !reflect.DeepEqual(jobs, tt.want) || total != tt.wantTotal {
+				t.Errorf("got %v of %d, want %v of %d", jobs, total, tt.want, tt.wantTotal)
+			}
+		})
+	}
+}
+
+func TestGetScreeningByIdempotencyKey(t *testing.T) {
+	f := newFixture(t)
+	ctx := context.Background()
+	for _, job := range []string{"job-2", "job-3"} {
+		s := &models.Screening{JobID: job, CustomerListID: f.customerListID, Status: "RUNNING", CreatedBy: 7,
+			IdempotencyScope: "user 7", IdempotencyKey: "key-1", RequestHash: "hash-" + job}
+		if err := f.repo.CreateScreening(ctx, s); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	s, err := f.repo.GetScreeningByIdempotencyKey(ctx, "user 7", "key-1", time.Now().Add(-time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s == nil || s.JobID != "job-3" || s.RequestHash != "hash-job-3" {
+		t.Errorf("got %+v, want the latest screening with the key", s)
+	}
+	if s, err := f.repo.GetScreeningByIdempotencyKey(ctx, "user 7", "key-1", time.Now().Add(time.Hour)); s != nil || err != nil {
+		t.Errorf("key used before since: %+v, %v", s, err)
+	}
+	if s, err := f.repo.GetScreeningByIdempotencyKey(ctx, "user 8", "key-1", time.Time{}); s != nil || err != nil {
+		t.Errorf("key of another user: %+v, %v", s, err)
+	}
+	if s, err := f.repo.GetScreeningByIdempotencyKey(ctx, "client 10.0.0.1", "key-1", time.Time{}); s != nil || err != nil {
+		t.Errorf("key of an anonymous client: %+v, %v", s, err)
+	}
+	if s, err := f.repo.GetScreeningByIdempotencyKey(ctx, "user 7", "key-2", time.Time{}); s != nil || err != nil {
+		t.Errorf("unknown key: %+v, %v", s, err)
+	}
+}
+
+func TestScreeningMatches(t *testing.T) {
+	f := newFixture(t)
+	ctx := context.Background()
+
+	if m, err := f.repo.GetScreeningMatches(ctx, f.screening.ID); m != nil || err != nil {
+		t.Fatalf("no matches saved: %+v, %v", m, err)
+	}
+
+	want := &models.ScreeningMatches{
+		ScreeningID:     f.screening.ID,
+		SessionID:       "session-1",
+		Hashes:          []uint64{101, 1<<63 + 5},
+		HashAlgorithm:   "siphash",
+		HashVersion:     2,
+		HashSalt:        []byte{1, 2, 3},
+		ColumnMapping:   map[string]string{"name": "Full Name"},
+		EnabledColumns:  []string{"name", "dob"},
+		Transliteration: []string{"cyrillic"},
+	}
+	if err := f.repo.SaveScreeningMatches(ctx, want); err != nil {
+		t.Fatal(err)
+	}
+	got, err := f.repo.GetScreeningMatches(ctx, f.screening.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got.CreatedAt = time.Time{}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+
+	// Saving again replaces the matches
+	want.SessionID = "session-2"
+	if err := f.repo.SaveScreeningMatches(ctx, want); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := f.repo.GetScreeningMatches(ctx, f.screening.ID); got.SessionID != "session-2" {
+		t.Errorf("session after replace = %q", got.SessionID)
+	}
+}
+
+func TestSaveScreeningResults(t *testing.T) {
+	f := newFixture(t)
+	ctx := context.Background()
+	if err := f.repo.SaveScreeningMatches(ctx, &models.ScreeningMatches{ScreeningID: f.screening.ID, Hashes: []uint64{101}}); err != nil {
+		t.Fatal(err)
+	}
+
+	// A new customer matched against a stored sanction and a new one
+	customer := &models.Customer{ExternalID: "C9", Name: "Dan Brown", Hash: 109, ListID: f.customerListID}
+	sanction := &models.Sanction{Source: "UN", Name: "Dan Brown", Hash: 109, ListID: f.sanctionListID, Category: models.ListCategoryPEP}
+	explanation := &models.MatchExplanation{Profile: "individual", Columns: []string{"name"}, HashScheme: "siphash/v1"}
+	lists := []models.ListMembership{{ListID: f.sanctionListID, Source: "UN", Category: models.ListCategoryPEP}}
+	records := []models.MatchRecord{
+		{Customer: customer, Sanction: sanction, Result: &models.ScreeningResult{MatchScore: 1, Status: "PENDING",
+			Explanation: explanation, Lists: lists}},
+		{Customer: customer, Sanction: f.sanctions[0], Result: &models.ScreeningResult{MatchScore: 0.5, Status: "PENDING",
+			Channel: models.MatchChannelDocument}},
+	}
+	if err := f.repo.SaveScreeningResults(ctx, f.screening.ID, records); err != nil {
+		t.Fatal(err)
+	}
+	if customer.ID == 0 || sanction.ID == 0 || records[0].Result.ID == 0 {
+		t.Fatal("IDs not assigned to saved records")
+	}
+
+	// The results replace the fixture's
+	results, total, err := f.repo.GetScreeningResults(ctx, f.screening.ID, 10, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if total != 2 || len(results) != 2 {
+		t.Fatalf("got %d results of %d, want 2", len(results), total)
+	}
+	// Document matches sort first
+	if results[0].Channel != models.MatchChannelDocument || results[1].Channel != models.MatchChannelName {
+		t.Errorf("channels = %s, %s", results[0].Channel, results[1].Channel)
+	}
+	if results[0].Customer.ID != customer.ID || results[1].Customer.ID != customer.ID {
+		t.Error("customer matched twice was inserted twice")
+	}
+	if !reflect.DeepEqual(results[1].Explanation, explanation) || !reflect.DeepEqual(results[1].Lists, lists) {
+		t.Errorf("explanation %+v, lists %+v", results[1].Explanation, results[1].Lists)
+	}
+	if results[1].Sanction.Category != models.ListCategoryPEP || results[1].ListSource != models.ListSourceRemote {
+		t.Errorf("new sanction result = %+v", results[1])
+	}
+
+	if m, _ := f.repo.GetScreeningMatches(ctx, f.screening.ID); !m.Resolved {
+		t.Error("matches not marked resolved")
+	}
+}
+
+func TestSaveScreeningResultsRollsBack(t *testing.T) {
+	f := newFixture(t)
+	ctx := context.Background()
+
+	customer := &models.Customer{ExternalID: "C9", Name: "Dan Brown", Hash: 109, ListID: f.customerListID}
+	records := []models.MatchRecord{
+		{Customer: customer, Sanction: f.sanctions[0], Result: &models.ScreeningResult{Status: "PENDING"}},
+		{Customer: f.customers[2], Sanction: &models.Sanction{Source: "UN", ListID: f.sanctionListID},
+			Result: &models.ScreeningResult{Status: "PENDING"}},
+	}
+	exec(t, f.repo, `CREATE TRIGGER reject_unnamed BEFORE INSERT ON sanctions WHEN NEW.name = ''
+		BEGIN SELECT RAISE(ABORT, 'unnamed sanction'); END`)
+	if err := f.repo.SaveScreeningResults(ctx, f.screening.ID, records); err == nil {
+		t.Fatal("saving an unnamed sanction succeeded")
+	}
+	if customer.ID != 0 || records[0].Result.ID != 0 {
+		t.Error("IDs of rolled back records not reset")
+	}
+	if _, total, _ := f.repo.GetScreeningResults(ctx, f.screening.ID, 10, 0); total != len(f.results) {
+		t.Errorf("%d results after a failed save, want the %d before it", total, len(f.results))
+	}
+}
+
+func TestScreeningResultQueries(t *testing.T) {
+	f := newFixture(t)
+	ctx := context.Background()
+	exec(t, f.repo, `UPDATE screening_results SET notes = NULL WHERE id = ?`, f.results[1].ID)
+
+	results, total, err := f.repo.GetScreeningResults(ctx, f.screening.ID, 10, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if total != 2 || len(results) != 2 {
+		t.Fatalf("got %d results of %d, want 2", len(results), total)
+	}
+	byJob, err := f.repo.GetScreeningResultsByJobID(ctx, "job-1", 10, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(results, byJob) {
+		t.Errorf("results by screening and by job differ:\n%+v\n%+v", results, byJob)
+	}
+
+	// Highest score first, joined with their customer and sanction
+	alice := results[0]
+	if alice.ID != f.results[0].ID || alice.Customer.ExternalID != "C1" || alice.Sanction.Source != "OFAC" ||
+		alice.Sanction.Retention != models.RetentionFull || alice.Sanction.Category != models.ListCategorySanctions ||
+		alice.Sanction.SourceRef != f.sanctions[0].SourceRef {
+		t.Errorf("first result = %+v", alice)
+	}
+	if results[1].Customer.Attributes["passport"] != "X123" {
+		t.Errorf("customer attributes = %v", results[1].Customer.Attributes)
+	}
+
+	page, total, _ := f.repo.GetScreeningResults(ctx, f.screening.ID, 1, 1)
+	if total != 2 || len(page) != 1 || page[0].ID != f.results[1].ID {
+		t.Errorf("second page = %+v of %d", page, total)
+	}
+
+	detail, err := f.repo.GetScreeningResultDetail(ctx, f.results[1].ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(*detail, results[1]) {
+		t.Errorf("detail differs from listed result:\n%+v\n%+v", *detail, results[1])
+	}
+	if d, err := f.repo.GetScreeningResultDetail(ctx, 999); d != nil || err != nil {
+		t.Errorf("unknown result: %+v, %v", d, err)
+	}
+
+	if n, err := f.repo.CountScreeningResultsByJobID(ctx, "job-1"); n != 2 || err != nil {
+		t.Errorf("CountScreeningResultsByJobID = %d, %v", n, err)
+	}
+	if n, _ := f.repo.CountScreeningResultsByJobID(ctx, "missing"); n != 0 {
+		t.Errorf("unknown job has %d results", n)
+	}
+}
+
+func TestResultApproval(t *testing.T) {
+	f := newFixture(t)
+	ctx := context.Background()
+	id := f.results[0].ID
+
+	if err := f.repo.UpdateResultStatus(ctx, id, "PENDING_APPROVAL", "alice"); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		approver string
+		approve  bool
+		want     bool
+		status   string
+	}{
+		{"proposer cannot approve", "alice", true, false, "PENDING_APPROVAL"},
+		{"proposer cannot reject", "alice", false, false, "PENDING_APPROVAL"},
+		{"second user rejects", "bob", false, true, "PENDING"},
+		{"nothing left to approve", "bob", true, false, "PENDING"},
+	}
+	for _, tt := range tests {
+		decided, err := f.repo.DecideResultApproval(ctx, id, tt.approver, tt.approve)
+		if err != nil {
+			t.Fatal(err)
+		}
+		d, _ := f.repo.GetScreeningResultDetail(ctx, id)
+		if decided != tt.want || d.Status != tt.status {
+			t.Errorf("%s: decided %v, status %s; want %v, %s", tt.name, decided, d.Status, tt.want, tt.status)
+		}
+	}
+
+	if err := f.repo.UpdateResultStatus(ctx, id, "PENDING_APPROVAL", "alice"); err != nil {
+		t.Fatal(err)
+	}
+	if decided, err := f.repo.DecideResultApproval(ctx, id, "bob", true); !decided || err != nil {
+		t.Fatalf("approval = %v, %v", decided, err)
+	}
+	d, _ := f.repo.GetScreeningResultDetail(ctx, id)
+	if d.Status != "CONFIRMED" || d.ProposedBy != "alice" || d.ApprovedBy != "bob" || d.ApprovedAt == nil {
+		t.Errorf("approved result = %+v", d.ScreeningResult)
+	}
+
+	// A new status clears the approval
+	if err := f.repo.UpdateResultStatus(ctx, id, "FALSE_POSITIVE", "carol"); err != nil {
+		t.Fatal(err)
+	}
+	d, _ = f.repo.GetScreeningResultDetail(ctx, id)
+	if d.Status != "FALSE_POSITIVE" || d.ApprovedBy != "" || d.ApprovedAt != nil {
+		t.Errorf("re-proposed result = %+v", d.ScreeningResult)
+	}
+}
+
+func TestResolveMatches(t *testing.T) {
+	f := newFixture(t)
+	ctx := context.Background()
+
+	tests := []struct {
+		name   string
+		hashes []int64
+		lists  []int64
+		want   map[string]string // Customer external ID to sanction name
+	}{
+		{"both", []int64{101, 102}, []int64{f.sanctionListID, f.pepListID}, map[string]string{"C1": "Alice Smith", "C2": "Bob Jones"}},
+		{"one list", []int64{101, 102}, []int64{f.sanctionListID}, map[string]string{"C1": "Alice Smith"}},
+		{"customer only", []int64{103}, []int64{f.sanctionListID, f.pepListID}, map[string]string{}},
+		{"no hashes", nil, []int64{f.sanctionListID}, map[string]string{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			matches, err := f.repo.ResolveMatches(ctx, tt.hashes, f.customerListID, tt.lists)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := make(map[string]string)
+			for _, m := range matches {
+				got[m.Customer.ExternalID] = m.Sanction.Name
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestScreeningMetrics(t *testing.T) {
+	f := newFixture(t)
+	ctx := context.Background()
+
+	if m, err := f.repo.GetLatestScreeningMetrics(ctx); m != nil || err != nil {
+		t.Fatalf("no metrics recorded: %+v, %v", m, err)
+	}
+
+	second := seedScreening(t, f.repo, "job-2", f.customerListID)
+	for i, id := range []int64{f.screening.ID, second.ID} {
+		m := &models.ScreeningMetrics{ScreeningID: id, EncryptionMs: 10, NetworkMs: 20, IntersectionMs: 30,
+			ResolveMs: 5, PersistMs: 1, TotalMs: 66, RecordCount: 3 + i, HashCollisions: i}
+		if err := f.repo.CreateScreeningMetrics(ctx, m); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	m, err := f.repo.GetScreeningMetricsByJobID(ctx, "job-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.ScreeningID != f.screening.ID || m.TotalMs != 66 || m.RecordCount != 3 {
+		t.Errorf("job-1 metrics = %+v", m)
+	}
+	if m, err := f.repo.GetScreeningMetricsByJobID(ctx, "missing"); m != nil || err != nil {
+		t.Errorf("unknown job: %+v, %v", m, err)
+	}
+
+	latest, _ := f.repo.GetLatestScreeningMetrics(ctx)
+	if latest.ScreeningID != second.ID || latest.HashCollisions != 1 {
+		t.Errorf("latest metrics = %+v", latest)
+	}
+	recent, err := f.repo.GetRecentScreeningMetrics(ctx, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(recent) != 1 || recent[0].ID != latest.ID {
+		t.Errorf("recent metrics = %+v", recent)
+	}
+}
@@ -0,0 +1,253 @@
+package repository
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/SanthoshCheemala/FLARE/backend/internal/models"
+)
+
+func TestSessionEvents(t *testing.T) {
+	r := newTestRepo(t)
+	ctx := context.Background()
+
+	key := &models.APIKey{Name: "bank-a", Prefix: "flr_a", KeyHash: "hash-a"}
+	if err := r.CreateAPIKey(ctx, key); err != nil {
+		t.Fatal(err)
+	}
+	for _, e := range []models.SessionEvent{
+		{SessionID: "s1", Event: "init", APIKeyID: key.ID, DurationMs: 1500},
+		{SessionID: "s1", Event: "intersect", APIKeyID: key.ID, Batch: 1, CiphertextCount: 100, MatchCount: 2, DurationMs: 500},
+		{SessionID: "s1", Event: "intersect", APIKeyID: key.ID, Batch: 2, CiphertextCount: 50, MatchCount: 1},
+		{SessionID: "s2", Event: "init", Client: "10.0.0.1"},
+		{SessionID: "s2", Event: "error", Detail: "tree busy"},
+	} {
+		if err := r.CreateSessionEvent(ctx, &e); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name      string
+		filter    SessionEventFilter
+		wantLen   int
+		wantTotal int
+	}{
+		{"all", SessionEventFilter{Limit: 10}, 5, 5},
+		{"page", SessionEventFilter{Limit: 2, Offset: 4}, 1, 5},
+		{"session", SessionEventFilter{SessionID: "s1", Limit: 10}, 3, 3},
+		{"event", SessionEventFilter{Event: "init", Limit: 10}, 2, 2},
+		{"since", SessionEventFilter{Since: time.Now().Add(time.Hour), Limit: 10}, 0, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			events, total, err := r.ListSessionEvents(ctx, tt.filter)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(events) != tt.wantLen || total != tt.wantTotal {
+				t.Errorf("got %d of %d, want %d of %d", len(events), total, tt.wantLen, tt.wantTotal)
+			}
+		})
+	}
+
+	// Newest first
+	events, _, _ := r.ListSessionEvents(ctx, SessionEventFilter{Limit: 1})
+	if events[0].Event != "error" || events[0].Detail != "tree busy" {
+		t.Errorf("newest event = %+v", events[0])
+	}
+
+	if n, err := r.CountSessionEvents(ctx, key.ID, "intersect", time.Now().Add(-time.Hour)); n != 2 || err != nil {
+		t.Errorf("CountSessionEvents = %d, %v", n, err)
+	}
+	sessions, matches, err := r.GetSessionTotals(ctx, 0)
+	if err != nil || sessions != 2 || matches != 3 {
+		t.Errorf("GetSessionTotals = %d, %d, %v", sessions, matches, err)
+	}
+	// s1 found 3 matches over two intersections; a cap of 2 counts two
+	if sessions, matches, err := r.GetSessionTotals(ctx, 2); err != nil || sessions != 2 || matches != 2 {
+		t.Errorf("GetSessionTotals with cap 2 = %d, %d, %v", sessions, matches, err)
+	}
+
+	month := time.Now().UTC().Format("2006-01")
+	usage, err := r.GetUsage(ctx, time.Now().Add(-time.Hour), time.Now().Add(time.Hour), SessionCaps{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []models.UsageRecord{
+		{Month: month, Sessions: 1, Errors: 1},
+		{Month: month, APIKeyID: key.ID, APIKeyName: "bank-a", Sessions: 1, Intersections: 2, Matches: 3, Ciphertexts: 150, CPUSeconds: 2},
+	}
+	if !reflect.DeepEqual(usage, want) {
+		t.Errorf("usage = %+v, want %+v", usage, want)
+	}
+	capped, err := r.GetUsage(ctx, time.Now().Add(-time.Hour), time.Now().Add(time.Hour), SessionCaps{Intersections: 1, Matches: 2, Errors: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want[1].Intersections, want[1].Matches = 1, 2
+	if !reflect.DeepEqual(capped, want) {
+		t.Errorf("capped usage = %+v, want %+v", capped, want)
+	}
+}
+
+func TestAPIKeys(t *testing.T) {
+	r := newTestRepo(t)
+	ctx := context.Background()
+
+	keys := []*models.APIKey{
+		{Name: "bank-a", Prefix: "flr_a", KeyHash: "hash-a"},
+		{Name: "bank-b", Prefix: "flr_b", KeyHash: "hash-b"},
+	}
+	for _, k := range keys {
+		if err := r.CreateAPIKey(ctx, k); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	k, err := r.GetActiveAPIKeyByHash(ctx, "hash-b")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if k == nil || k.ID != keys[1].ID || k.LastUsedAt != nil || k.RevokedAt != nil {
+		t.Errorf("key by hash = %+v", k)
+	}
+
+	if err := r.TouchAPIKey(ctx, keys[0].ID); err != nil {
+		t.Fatal(err)
+	}
+	quota := models.APIKeyQuota{MaxSessionsPerDay: 10, MaxCiphertextsPerRequest: 5000, MaxConcurrentSessions: 2, MinResolveCiphertexts: 100}
+	if updated, err := r.UpdateAPIKeyQuota(ctx, keys[0].ID, quota); !updated || err != nil {
+		t.Fatalf("UpdateAPIKeyQuota = %v, %v", updated, err)
+	}
+	if updated, _ := r.UpdateAPIKeyQuota(ctx, 999, quota); updated {
+		t.Error("updated the quota of an unknown key")
+	}
+	k, _ = r.GetAPIKey(ctx, keys[0].ID)
+	if k.LastUsedAt == nil || k.Quota != quota {
+		t.Errorf("touched key with quota = %+v", k)
+	}
+
+	if revoked, err := r.RevokeAPIKey(ctx, keys[0].ID); !revoked || err != nil {
+		t.Fatalf("RevokeAPIKey = %v, %v", revoked, err)
+	}
+	if revoked, _ := r.RevokeAPIKey(ctx, keys[0].ID); revoked {
+		t.Error("revoked a key twice")
+	}
+	if k, err := r.GetActiveAPIKeyByHash(ctx, "hash-a"); k != nil || err != nil {
+		t.Errorf("revoked key by hash: %+v, %v", k, err)
+	}
+	if k, _ := r.GetAPIKey(ctx, keys[0].ID); k == nil || k.RevokedAt == nil {
+		t.Errorf("revoked key = %+v", k)
+	}
+	if k, err := r.GetAPIKey(ctx, 999); k != nil || err != nil {
+		t.Errorf("unknown key: %+v, %v", k, err)
+	}
+
+	listed, err := r.ListAPIKeys(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(listed) != 2 || listed[0].Name != "bank-a" || listed[1].Name != "bank-b" {
+		t.Errorf("listed keys = %+v", listed)
+	}
+}
+
+func TestPacks(t *testing.T) {
+	f := newFixture(t)
+	ctx := context.Background()
+
+	if err := f.repo.CreatePack(ctx, &models.WatchlistPack{ID: "global", Name: "Global", Description: "All lists",
+		ListIDs: []int64{f.pepListID, f.sanctionListID, f.sanctionListID}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.repo.CreatePack(ctx, &models.WatchlistPack{ID: "peps", Name: "PEPs", ListIDs: []int64{f.pepListID}}); err != nil {
+		t.Fatal(err)
+	}
+
+	p, err := f.repo.GetPack(ctx, "global")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Version != 1 || p.ListCount != 2 || !reflect.DeepEqual(p.ListIDs, []int64{f.sanctionListID, f.pepListID}) ||
+		!reflect.DeepEqual(p.Categories, []string{models.ListCategorySanctions, models.ListCategoryPEP}) {
+		t.Errorf("pack = %+v", p)
+	}
+	if p, err := f.repo.GetPack(ctx, "missing"); p != nil || err != nil {
+		t.Errorf("unknown pack: %+v, %v", p, err)
+	}
+
+	updated, err := f.repo.UpdatePack(ctx, &models.WatchlistPack{ID: "global", Name: "Global sanctions", ListIDs: []int64{f.sanctionListID}})
+	if err != nil || !updated {
+		t.Fatalf("UpdatePack = %v, %v", updated, err)
+	}
+	if updated, _ := f.repo.UpdatePack(ctx, &models.WatchlistPack{ID: "missing"}); updated {
+		t.Error("updated an unknown pack")
+	}
+	p, _ = f.repo.GetPack(ctx, "global")
+	if p.Version != 2 || p.Name != "Global sanctions" || !reflect.DeepEqual(p.ListIDs, []int64{f.sanctionListID}) {
+		t.Errorf("updated pack = %+v", p)
+	}
+
+	bumped, err := f.repo.BumpPacksForList(ctx, f.pepListID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(bumped, []string{"peps"}) {
+		t.Errorf("bumped packs = %v", bumped)
+	}
+
+	packs, err := f.repo.ListPacks(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(packs) != 2 || packs[0].ID != "global" || packs[1].ID != "peps" || packs[1].Version != 2 {
+		t.Errorf("packs = %+v", packs)
+	}
+
+	if deleted, err := f.repo.DeletePack(ctx, "peps"); !deleted || err != nil {
+		t.Fatalf("DeletePack = %v, %v", deleted, err)
+	}
+	if deleted, _ := f.repo.DeletePack(ctx, "peps"); deleted {
+		t.Error("deleted a pack twice")
+	}
+	// The lists a pack bundled are kept
+	if lists, _ := f.repo.GetSanctionLists(ctx); len(lists) != 2 {
+		t.Errorf("%d lists after deleting a pack, want 2", len(lists))
+	}
+}
+
+func TestPackSubscriptions(t *testing.T) {
+	r := newTestRepo(t)
+	ctx := context.Background()
+
+	if subscribed, err := r.SubscribePack(ctx, "global", "Global", 3); !subscribed || err != nil {
+		t.Fatalf("SubscribePack = %v, %v", subscribed, err)
+	}
+	if subscribed, _ := r.SubscribePack(ctx, "global", "Global", 4); subscribed {
+		t.Error("subscribed to a pack twice")
+	}
+	if err := r.UpdatePackSubscription(ctx, "global", "Global sanctions", 5, true); err != nil {
+		t.Fatal(err)
+	}
+
+	subs, err := r.ListPackSubscriptions(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(subs) != 1 {
+		t.Fatalf("got %d subscriptions, want 1", len(subs))
+	}
+	if s := subs[0]; s.PackID != "global" || s.Name != "Global sanctions" || s.Version != 5 || !s.Deleted || s.UpdatedAt == nil {
+		t.Errorf("subscription = %+v", s)
+	}
+
+	if removed, err := r.UnsubscribePack(ctx, "global"); !removed || err != nil {
+		t.Fatalf("UnsubscribePack = %v, %v", removed, err)
+	}
+	if removed, _ := r.UnsubscribePack(ctx, "global"); removed {
+		t.Error("unsubscribed twice")
+	}
+}
@@ -0,0 +1,151 @@
+package secrets
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// KMSProvider decrypts secrets stored as base64 AWS KMS ciphertext blobs.
+// The ciphertext for each secret comes from an inner provider, usually the
+// environment.
+type KMSProvider struct {
+	region       string
+	accessKey    string
+	secretKey    string
+	sessionToken string
+	source       Provider
+	endpoint     string
+	http         *http.Client
+}
+
+// NewKMSProvider creates a provider that decrypts values from source with
+// KMS in region using static credentials
+func NewKMSProvider(region, accessKey, secretKey, sessionToken string, source Provider) (*KMSProvider, error) {
+	if region == "" || accessKey == "" || secretKey == "" {
+		return nil, fmt.Errorf("kms backend requires AWS_REGION, AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY")
+	}
+	return &KMSProvider{
+		region:       region,
+		accessKey:    accessKey,
+		secretKey:    secretKey,
+		sessionToken: sessionToken,
+		source:       source,
+		endpoint:     fmt.Sprintf("https://kms.%s.amazonaws.com/", region),
+		http:         &http.Client{Timeout: 10 * time.Second},
+	}, nil
+}
+
+func (p *KMSProvider) Get(ctx context.Context, name string) (string, error) {
+	blob, err := p.source.Get(ctx, name)
+	if err != nil {
+		return "", err
+	}
+	if _, err := base64.StdEncoding.DecodeString(blob); err != nil {
+		return "", fmt.Errorf("%s is not a base64 KMS ciphertext: %w", name, err)
+	}
+
+	body, err := json.Marshal(map[string]string{"CiphertextBlob": blob})
+	if err != nil {
+		return "", err
+	}
+	req, err := http.NewRequestWithContext(ctx, "POST", p.endpoint, bytes.NewReader(body))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/x-amz-json-1.1")
+	req.Header.Set("X-Amz-Target", "TrentService.Decrypt")
+	p.sign(req, body, time.Now().UTC())
+
+	resp, err := p.http.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("kms request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		var kmsErr struct {
+			Type    string `json:"__type"`
+			Message string `json:"message"`
+		}
+		json.NewDecoder(resp.Body).Decode(&kmsErr)
+		return "", fmt.Errorf("kms decrypt of %s failed with status %d: %s %s", name, resp.StatusCode, kmsErr.Type, kmsErr.Message)
+	}
+
+	var out struct {
+		Plaintext string `json:"Plaintext"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+		return "", fmt.Errorf("decode kms response: %w", err)
+	}
+	plaintext, err := base64.StdEncoding.DecodeString(out.Plaintext)
+	if err != nil {
+		return "", fmt.Errorf("decode kms plaintext: %w", err)
+	}
+	return string(plaintext), nil
+}
+
+// sign adds an AWS Signature Version 4 Authorization header to req
+func (p *KMSProvider) sign(req *http.Request, body []byte, now time.Time) {
+	amzDate := now.Format("20060102T150405Z")
+	date := now.Format("20060102")
+	payloadHash := sha256Hex(body)
+
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+	if p.sessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", p.sessionToken)
+	}
+
+	// Canonical headers must be sorted by name
+	headers := [][2]string{
+		{"content-type", req.Header.Get("Content-Type")},
+		{"host", req.URL.Host},
+		{"x-amz-content-sha256", payloadHash},
+		{"x-amz-date", amzDate},
+	}
+	if p.sessionToken != "" {
+		headers = append(headers, [2]string{"x-amz-security-token", p.sessionToken})
+	}
+	headers = append(headers, [2]string{"x-amz-target", req.Header.Get("X-Amz-Target")})
+
+	var canonicalHeaders, signedHeaders string
+	for i, h := range headers {
+		canonicalHeaders += h[0] + ":" + h[1] + "\n"
+		if i > 0 {
+			signedHeaders += ";"
+		}
+		signedHeaders += h[0]
+	}
+	canonicalRequest := "POST\n/\n\n" + canonicalHeaders + "\n" + signedHeaders + "\n" + payloadHash
+
+	scope := date + "/" + p.region + "/kms/aws4_request"
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + sha256Hex([]byte(canonicalRequest))
+
+	key := hmacSHA256([]byte("AWS4"+p.secretKey), date)
+	key = hmacSHA256(key, p.region)
+	key = hmacSHA256(key, "kms")
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		p.accessKey, scope, signedHeaders, signature))
+}
+
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
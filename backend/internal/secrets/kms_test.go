@@ -0,0 +1,116 @@
+package secrets
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestKMSSign(t *testing.T) {
+	// Signatures computed independently from the AWS Signature Version 4
+	// specification
+	for _, tc := range []struct {
+		token, signedHeaders, signature string
+	}{
+		{"", "content-type;host;x-amz-content-sha256;x-amz-date;x-amz-target",
+			"e673567a84eefcfb3d3bc862416f4b0f6147fb63b80f551c57c0a7338eb1a33d"},
+		{"session-token", "content-type;host;x-amz-content-sha256;x-amz-date;x-amz-security-token;x-amz-target",
+			"6e41f3fdbd0d42c4ba9d48a0975c2d4471563edfda7c9b12c47073a1aeb8dc9e"},
+	} {
+		p, err := NewKMSProvider("us-east-1", "AKIDEXAMPLE", "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY", tc.token, EnvProvider{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		body := []byte(`{"CiphertextBlob":"AQIDBA=="}`)
+		req, err := http.NewRequest("POST", p.endpoint, bytes.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Content-Type", "application/x-amz-json-1.1")
+		req.Header.Set("X-Amz-Target", "TrentService.Decrypt")
+		p.sign(req, body, time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC))
+
+		want := "AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/20240102/us-east-1/kms/aws4_request, SignedHeaders=" +
+			tc.signedHeaders + ", Signature=" + tc.signature
+		if got := req.Header.Get("Authorization"); got != want {
+			t.Errorf("token %q: Authorization\n got  %s\n want %s", tc.token, got, want)
+		}
+		if got := req.Header.Get("X-Amz-Date"); got != "20240102T030405Z" {
+			t.Errorf("X-Amz-Date = %s", got)
+		}
+		if got := req.Header.Get("X-Amz-Security-Token"); got != tc.token {
+			t.Errorf("X-Amz-Security-Token = %q, want %q", got, tc.token)
+		}
+	}
+}
+
+// fakeKMS answers Decrypt with handler after checking the request is a
+// signed Decrypt call
+func fakeKMS(t *testing.T, source Provider, handler func(blob string) (int, string)) *KMSProvider {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Amz-Target") != "TrentService.Decrypt" || !strings.HasPrefix(r.Header.Get("Authorization"), "AWS4-HMAC-SHA256 ") {
+			http.Error(w, "unsigned or not a Decrypt call", http.StatusBadRequest)
+			return
+		}
+		body, _ := io.ReadAll(r.Body)
+		if sha256Hex(body) != r.Header.Get("X-Amz-Content-Sha256") {
+			http.Error(w, "payload hash mismatch", http.StatusBadRequest)
+			return
+		}
+		var in struct{ CiphertextBlob string }
+		json.Unmarshal(body, &in)
+		status, out := handler(in.CiphertextBlob)
+		w.WriteHeader(status)
+		io.WriteString(w, out)
+	}))
+	t.Cleanup(srv.Close)
+
+	p, err := NewKMSProvider("eu-west-1", "AKID", "secret", "", source)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p.endpoint = srv.URL + "/"
+	return p
+}
+
+func TestKMSGet(t *testing.T) {
+	t.Setenv("JWT_ACCESS_SECRET", "AQIDBA==")
+	t.Setenv("BAD_BLOB", "not base64!")
+	p := fakeKMS(t, EnvProvider{}, func(blob string) (int, string) {
+		if blob != "AQIDBA==" {
+			return http.StatusBadRequest, `{"__type":"InvalidCiphertextException","message":"bad blob"}`
+		}
+		return http.StatusOK, `{"Plaintext":"c2VjcmV0LXZhbHVl"}`
+	})
+	ctx := context.Background()
+
+	got, err := p.Get(ctx, "JWT_ACCESS_SECRET")
+	if err != nil || got != "secret-value" {
+		t.Errorf("Get = %q, %v, want secret-value", got, err)
+	}
+	if _, err := p.Get(ctx, "MISSING_SECRET"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("missing secret: err = %v, want ErrNotFound", err)
+	}
+	if _, err := p.Get(ctx, "BAD_BLOB"); err == nil || !strings.Contains(err.Error(), "not a base64") {
+		t.Errorf("invalid blob: err = %v", err)
+	}
+
+	t.Setenv("JWT_ACCESS_SECRET", "BQYHCA==")
+	if _, err := p.Get(ctx, "JWT_ACCESS_SECRET"); err == nil || !strings.Contains(err.Error(), "InvalidCiphertextException") {
+		t.Errorf("rejected blob: err = %v, want the KMS error", err)
+	}
+}
+
+func TestNewKMSProviderRequiresCredentials(t *testing.T) {
+	if _, err := NewKMSProvider("us-east-1", "AKID", "", "", EnvProvider{}); err == nil {
+		t.Error("provider created without a secret key")
+	}
+}
@@ -0,0 +1,71 @@
+// Package secrets loads service secrets (JWT keys, tokens, encryption keys)
+// from a configurable backend: environment variables, files, HashiCorp
+// Vault or values encrypted with AWS KMS.
+package secrets
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// ErrNotFound is returned when a backend has no value for a secret
+var ErrNotFound = errors.New("secret not found")
+
+// Provider resolves secrets by name, e.g. JWT_ACCESS_SECRET
+type Provider interface {
+	Get(ctx context.Context, name string) (string, error)
+}
+
+// EnvProvider reads secrets from environment variables
+type EnvProvider struct{}
+
+func (EnvProvider) Get(ctx context.Context, name string) (string, error) {
+	if v := os.Getenv(name); v != "" {
+		return v, nil
+	}
+	return "", ErrNotFound
+}
+
+// FileProvider reads each secret from a file named after it, as mounted by
+// Docker and Kubernetes secrets
+type FileProvider struct {
+	Dir string
+}
+
+func (p FileProvider) Get(ctx context.Context, name string) (string, error) {
+	data, err := os.ReadFile(filepath.Join(p.Dir, name))
+	if errors.Is(err, os.ErrNotExist) {
+		return "", ErrNotFound
+	}
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(data)), nil
+}
+
+// FromEnv builds the provider selected by SECRETS_BACKEND (env, file, vault
+// or kms; default env). Backend settings are read from the environment.
+func FromEnv() (Provider, error) {
+	backend := os.Getenv("SECRETS_BACKEND")
+	switch backend {
+	case "", "env":
+		return EnvProvider{}, nil
+	case "file":
+		dir := os.Getenv("SECRETS_DIR")
+		if dir == "" {
+			dir = "/run/secrets"
+		}
+		return FileProvider{Dir: dir}, nil
+	case "vault":
+		return NewVaultProvider(os.Getenv("VAULT_ADDR"), os.Getenv("VAULT_TOKEN"), os.Getenv("VAULT_SECRET_PATH"))
+	case "kms":
+		return NewKMSProvider(os.Getenv("AWS_REGION"), os.Getenv("AWS_ACCESS_KEY_ID"),
+			os.Getenv("AWS_SECRET_ACCESS_KEY"), os.Getenv("AWS_SESSION_TOKEN"), EnvProvider{})
+	default:
+		return nil, fmt.Errorf("unknown SECRETS_BACKEND %q (want env, file, vault or kms)", backend)
+	}
+}
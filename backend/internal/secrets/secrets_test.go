@@ -0,0 +1,62 @@
+package secrets
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestFileProvider(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "JWT_ACCESS_SECRET"), []byte("s3cret\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	p := FileProvider{Dir: dir}
+	if got, err := p.Get(context.Background(), "JWT_ACCESS_SECRET"); err != nil || got != "s3cret" {
+		t.Errorf("Get = %q, %v, want s3cret", got, err)
+	}
+	if _, err := p.Get(context.Background(), "MISSING"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("missing file: err = %v, want ErrNotFound", err)
+	}
+}
+
+func TestFromEnv(t *testing.T) {
+	for backend, want := range map[string]string{
+		"":      "secrets.EnvProvider",
+		"env":   "secrets.EnvProvider",
+		"file":  "secrets.FileProvider",
+		"vault": "*secrets.VaultProvider",
+		"kms":   "*secrets.KMSProvider",
+	} {
+		t.Setenv("SECRETS_BACKEND", backend)
+		t.Setenv("VAULT_ADDR", "http://vault:8200")
+		t.Setenv("VAULT_TOKEN", "root")
+		t.Setenv("VAULT_SECRET_PATH", "secret/flare")
+		t.Setenv("AWS_REGION", "us-east-1")
+		t.Setenv("AWS_ACCESS_KEY_ID", "AKID")
+		t.Setenv("AWS_SECRET_ACCESS_KEY", "secret")
+		p, err := FromEnv()
+		if err != nil {
+			t.Errorf("backend %q: %v", backend, err)
+			continue
+		}
+		if got := fmt.Sprintf("%T", p); got != want {
+			t.Errorf("backend %q: provider %s, want %s", backend, got, want)
+		}
+	}
+
+	t.Setenv("SECRETS_BACKEND", "file")
+	t.Setenv("SECRETS_DIR", "")
+	p, _ := FromEnv()
+	if dir := p.(FileProvider).Dir; dir != "/run/secrets" {
+		t.Errorf("default secrets dir %s, want /run/secrets", dir)
+	}
+
+	t.Setenv("SECRETS_BACKEND", "consul")
+	if _, err := FromEnv(); err == nil {
+		t.Error("unknown backend accepted")
+	}
+}
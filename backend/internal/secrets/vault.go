@@ -0,0 +1,98 @@
+package secrets
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// VaultProvider reads secrets from one HashiCorp Vault KV v2 secret, with
+// each key of the secret holding one value
+type VaultProvider struct {
+	addr  string
+	token string
+	mount string // KV v2 mount, e.g. "secret"
+	path  string // Secret path under the mount, e.g. "flare/server"
+	http  *http.Client
+
+	mu     sync.Mutex
+	values map[string]string // Fetched once on first use
+}
+
+// NewVaultProvider creates a provider for the KV v2 secret at secretPath,
+// given as "<mount>/<path>"
+func NewVaultProvider(addr, token, secretPath string) (*VaultProvider, error) {
+	if addr == "" || token == "" {
+		return nil, fmt.Errorf("vault backend requires VAULT_ADDR and VAULT_TOKEN")
+	}
+	mount, path, ok := strings.Cut(strings.Trim(secretPath, "/"), "/")
+	if !ok || path == "" {
+		return nil, fmt.Errorf("VAULT_SECRET_PATH must be <mount>/<path>, got %q", secretPath)
+	}
+	return &VaultProvider{
+		addr:  strings.TrimRight(addr, "/"),
+		token: token,
+		mount: mount,
+		path:  path,
+		http:  &http.Client{Timeout: 10 * time.Second},
+	}, nil
+}
+
+func (p *VaultProvider) Get(ctx context.Context, name string) (string, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.values == nil {
+		values, err := p.fetch(ctx)
+		if err != nil {
+			return "", err
+		}
+		p.values = values
+	}
+	v, ok := p.values[name]
+	if !ok || v == "" {
+		return "", ErrNotFound
+	}
+	return v, nil
+}
+
+// fetch reads the secret's current version
+func (p *VaultProvider) fetch(ctx context.Context) (map[string]string, error) {
+	url := fmt.Sprintf("%s/v1/%s/data/%s", p.addr, p.mount, p.path)
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("X-Vault-Token", p.token)
+
+	resp, err := p.http.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("vault request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("vault returned status %d for %s/%s", resp.StatusCode, p.mount, p.path)
+	}
+
+	var body struct {
+		Data struct {
+			Data map[string]interface{} `json:"data"`
+		} `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return nil, fmt.Errorf("decode vault response: %w", err)
+	}
+
+	values := make(map[string]string, len(body.Data.Data))
+	for k, v := range body.Data.Data {
+		if s, ok := v.(string); ok {
+			values[k] = s
+		}
+	}
+	return values, nil
+}
@@ -0,0 +1,66 @@
+package secrets
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+)
+
+func TestVaultGet(t *testing.T) {
+	var requests atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		if r.Header.Get("X-Vault-Token") != "root" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		if r.URL.Path != "/v1/secret/data/flare/server" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Write([]byte(`{"data":{"data":{"JWT_ACCESS_SECRET":"s3cret","PSI_ADMIN_TOKEN":"","PORT":8080},"metadata":{"version":3}}}`))
+	}))
+	defer srv.Close()
+	ctx := context.Background()
+
+	p, err := NewVaultProvider(srv.URL+"/", "root", "/secret/flare/server/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, err := p.Get(ctx, "JWT_ACCESS_SECRET"); err != nil || got != "s3cret" {
+		t.Errorf("Get = %q, %v, want s3cret", got, err)
+	}
+	// Empty and non-string values are not secrets
+	for _, name := range []string{"PSI_ADMIN_TOKEN", "PORT", "MISSING"} {
+		if _, err := p.Get(ctx, name); !errors.Is(err, ErrNotFound) {
+			t.Errorf("%s: err = %v, want ErrNotFound", name, err)
+		}
+	}
+	if n := requests.Load(); n != 1 {
+		t.Errorf("%d requests to Vault, want the secret read once", n)
+	}
+
+	denied, err := NewVaultProvider(srv.URL, "wrong", "secret/flare/server")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := denied.Get(ctx, "JWT_ACCESS_SECRET"); err == nil || errors.Is(err, ErrNotFound) {
+		t.Errorf("bad token: err = %v, want the Vault error", err)
+	}
+}
+
+func TestNewVaultProviderValidates(t *testing.T) {
+	for _, tc := range []struct{ addr, token, path string }{
+		{"", "root", "secret/flare"},
+		{"http://vault:8200", "", "secret/flare"},
+		{"http://vault:8200", "root", "secret"},
+		{"http://vault:8200", "root", "secret/"},
+	} {
+		if _, err := NewVaultProvider(tc.addr, tc.token, tc.path); err == nil {
+			t.Errorf("NewVaultProvider(%q, %q, %q) accepted", tc.addr, tc.token, tc.path)
+		}
+	}
+}
@@ -0,0 +1,124 @@
+// Package textenc converts uploaded lists to UTF-8. Files with a UTF-16
+// byte order mark are transcoded whole. Otherwise each row that is not
+// valid UTF-8 is read as Windows-1252, a superset of the printable Latin-1
+// characters that spreadsheet software on Windows saves CSVs in, so that
+// names such as "José" are hashed as they appear on the sanction lists
+// rather than as mojibake.
+package textenc
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"unicode/utf16"
+	"unicode/utf8"
+
+	"github.com/SanthoshCheemala/FLARE/backend/internal/models"
+)
+
+// Encodings ToUTF8 detects
+const (
+	UTF8        = "UTF-8"
+	UTF16LE     = "UTF-16LE"
+	UTF16BE     = "UTF-16BE"
+	Windows1252 = "Windows-1252"
+)
+
+// Codes of the warnings ToUTF8 reports
+const (
+	WarnReencoded = "reencoded_rows" // Rows read as Windows-1252
+	WarnTranscode = "transcoded"     // The whole file was UTF-16
+)
+
+// maxListedRows is the number of re-encoded rows a warning lists
+const maxListedRows = 50
+
+var (
+	bomUTF8    = []byte("\xef\xbb\xbf")
+	bomUTF16LE = []byte("\xff\xfe")
+	bomUTF16BE = []byte("\xfe\xff")
+)
+
+// ToUTF8 returns content as UTF-8 without a byte order mark, and a report
+// of the detected encoding with a warning listing the rows that had to be
+// re-encoded
+func ToUTF8(content []byte) ([]byte, models.UploadQuality) {
+	quality := models.UploadQuality{Encoding: UTF8, Warnings: []models.UploadWarning{}}
+
+	switch {
+	case bytes.HasPrefix(content, bomUTF16LE):
+		quality.Encoding = UTF16LE
+		content = decodeUTF16(content[len(bomUTF16LE):], binary.LittleEndian)
+	case bytes.HasPrefix(content, bomUTF16BE):
+		quality.Encoding = UTF16BE
+		content = decodeUTF16(content[len(bomUTF16BE):], binary.BigEndian)
+	default:
+		content = bytes.TrimPrefix(content, bomUTF8)
+	}
+	if quality.Encoding != UTF8 {
+		quality.Warnings = append(quality.Warnings, models.UploadWarning{
+			Code:     WarnTranscode,
+			Message:  fmt.Sprintf("The file was %s and was converted to UTF-8", quality.Encoding),
+			Affected: bytes.Count(bytes.TrimSuffix(content, []byte("\n")), []byte("\n")) + 1,
+		})
+		return content, quality
+	}
+	if utf8.Valid(content) {
+		return content, quality
+	}
+
+	var out bytes.Buffer
+	out.Grow(len(content) + len(content)/8)
+	warning := models.UploadWarning{Code: WarnReencoded}
+	for row := 1; len(content) > 0; row++ {
+		line := content
+		if i := bytes.IndexByte(content, '\n'); i >= 0 {
+			line = content[:i+1]
+		}
+		content = content[len(line):]
+		if utf8.Valid(line) {
+			out.Write(line)
+			continue
+		}
+		for _, b := range line {
+			out.WriteRune(decode1252(b))
+		}
+		warning.Affected++
+		if len(warning.Rows) < maxListedRows {
+			warning.Rows = append(warning.Rows, row)
+		}
+	}
+	quality.Encoding = Windows1252
+	warning.Message = fmt.Sprintf("%d rows were not valid UTF-8 and were read as Windows-1252; check their names for misread characters", warning.Affected)
+	quality.Warnings = append(quality.Warnings, warning)
+	return out.Bytes(), quality
+}
+
+// decodeUTF16 converts UTF-16 text in the given byte order to UTF-8. A
+// trailing odd byte is dropped and unpaired surrogates become U+FFFD.
+func decodeUTF16(content []byte, order binary.ByteOrder) []byte {
+	units := make([]uint16, len(content)/2)
+	for i := range units {
+		units[i] = order.Uint16(content[2*i:])
+	}
+	var out bytes.Buffer
+	out.Grow(len(content))
+	for _, r := range utf16.Decode(units) {
+		out.WriteRune(r)
+	}
+	return out.Bytes()
+}
+
+// cp1252 maps the bytes 0x80-0x9F of Windows-1252; the rest match Latin-1.
+// The five bytes it leaves undefined keep their Latin-1 control codes.
+var cp1252 = [32]rune{
+	'€', 0x81, '‚', 'ƒ', '„', '…', '†', '‡', 'ˆ', '‰', 'Š', '‹', 'Œ', 0x8d, 'Ž', 0x8f,
+	0x90, '‘', '’', '“', '”', '•', '–', '—', '˜', '™', 'š', '›', 'œ', 0x9d, 'ž', 'Ÿ',
+}
+
+func decode1252(b byte) rune {
+	if b >= 0x80 && b < 0xa0 {
+		return cp1252[b-0x80]
+	}
+	return rune(b)
+}
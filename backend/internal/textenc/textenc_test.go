@@ -0,0 +1,66 @@
+package textenc
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestToUTF8Unchanged(t *testing.T) {
+	for _, in := range []string{"", "name,dob\nJosé,1970-01-01\n", "\xef\xbb\xbfname\nJosé\n"} {
+		got, quality := ToUTF8([]byte(in))
+		if want := strings.TrimPrefix(in, "\xef\xbb\xbf"); string(got) != want {
+			t.Errorf("ToUTF8(%q) = %q, want %q", in, got, want)
+		}
+		if quality.Encoding != UTF8 || len(quality.Warnings) != 0 {
+			t.Errorf("ToUTF8(%q): quality %+v", in, quality)
+		}
+	}
+}
+
+func TestToUTF8UTF16(t *testing.T) {
+	// "name\nJosé 😀\n" with a BOM in each byte order, and an odd trailing byte
+	le := "\xff\xfen\x00a\x00m\x00e\x00\n\x00J\x00o\x00s\x00\xe9\x00 \x00\x3d\xd8\x00\xde\n\x00!"
+	be := "\xfe\xff\x00n\x00a\x00m\x00e\x00\n\x00J\x00o\x00s\x00\xe9\x00 \xd8\x3d\xde\x00\x00\n"
+	for in, encoding := range map[string]string{le: UTF16LE, be: UTF16BE} {
+		got, quality := ToUTF8([]byte(in))
+		if want := "name\nJosé 😀\n"; string(got) != want {
+			t.Errorf("%s: got %q, want %q", encoding, got, want)
+		}
+		if quality.Encoding != encoding {
+			t.Errorf("%s: encoding %s", encoding, quality.Encoding)
+		}
+		if len(quality.Warnings) != 1 || quality.Warnings[0].Code != WarnTranscode || quality.Warnings[0].Affected != 2 {
+			t.Errorf("%s: warnings %+v", encoding, quality.Warnings)
+		}
+	}
+
+	// An unpaired surrogate is replaced
+	if got, _ := ToUTF8([]byte("\xff\xfeA\x00\x3d\xd8B\x00")); string(got) != "A�B" {
+		t.Errorf("unpaired surrogate: got %q", got)
+	}
+}
+
+func TestToUTF8Windows1252(t *testing.T) {
+	in := "name\nJos\xe9\nAndré\n\x93Stra\xdfe\x94 \x80\x81\nŒuvre"
+	got, quality := ToUTF8([]byte(in))
+	if want := "name\nJosé\nAndré\n“Straße” €\u0081\nŒuvre"; string(got) != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+	if quality.Encoding != Windows1252 || len(quality.Warnings) != 1 {
+		t.Fatalf("quality %+v", quality)
+	}
+	w := quality.Warnings[0]
+	if w.Code != WarnReencoded || w.Affected != 2 || !reflect.DeepEqual(w.Rows, []int{2, 4}) {
+		t.Errorf("warning %+v, want rows 2 and 4", w)
+	}
+}
+
+func TestReencodedRowsListed(t *testing.T) {
+	in := strings.Repeat("Jos\xe9\n", maxListedRows+10)
+	_, quality := ToUTF8([]byte(in))
+	w := quality.Warnings[0]
+	if w.Affected != maxListedRows+10 || len(w.Rows) != maxListedRows || w.Rows[maxListedRows-1] != maxListedRows {
+		t.Errorf("affected %d, rows %v", w.Affected, w.Rows)
+	}
+}
@@ -0,0 +1,301 @@
+// Package translit romanizes non-Latin names so that sanction entries
+// written in their native script hash alike with romanized customer
+// records.
+//
+// Both PSI parties must produce byte-identical output, so transliteration
+// is table-driven and versioned: a session fixes the Version and scripts
+// it uses. Han ideographs have no table (their reading depends on language
+// and context) and pass through unchanged. Arabic is written without short
+// vowels, so its output is a consonant skeleton that only matches
+// romanizations spelled the same way.
+package translit
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Version identifies the transliteration tables. Bump it whenever a table
+// changes, since hashes built with different tables never match.
+const Version = 1
+
+// Scripts lists the supported scripts
+var Scripts = []string{"arabic", "cyrillic", "greek", "hangul", "kana", "latin"}
+
+// Profile selects the scripts transliterated in a session. The zero value
+// transliterates nothing.
+type Profile struct {
+	Version int      `json:"version"`
+	Scripts []string `json:"scripts"` // Sorted; see Scripts
+}
+
+// Parse reads a comma-separated list of scripts, or "all". Empty disables
+// transliteration.
+func Parse(s string) (Profile, error) {
+	var scripts []string
+	for _, name := range strings.Split(s, ",") {
+		if name = strings.ToLower(strings.TrimSpace(name)); name != "" {
+			scripts = append(scripts, name)
+		}
+	}
+	return New(scripts)
+}
+
+// New returns the current-version profile for scripts. "all" selects every
+// supported script.
+func New(scripts []string) (Profile, error) {
+	if len(scripts) == 0 {
+		return Profile{}, nil
+	}
+	seen := make(map[string]bool)
+	for _, name := range scripts {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "all" {
+			for _, s := range Scripts {
+				seen[s] = true
+			}
+			continue
+		}
+		if _, ok := tables[name]; !ok {
+			return Profile{}, fmt.Errorf("unknown script %q (use %s or all)", name, strings.Join(Scripts, ", "))
+		}
+		seen[name] = true
+	}
+	p := Profile{Version: Version}
+	for name := range seen {
+		p.Scripts = append(p.Scripts, name)
+	}
+	sort.Strings(p.Scripts)
+	return p, nil
+}
+
+// Check validates a profile received from the other party and returns it in
+// canonical form. A profile built for other tables is rejected.
+func (p Profile) Check() (Profile, error) {
+	if len(p.Scripts) == 0 {
+		return Profile{}, nil
+	}
+	if p.Version != Version {
+		return Profile{}, fmt.Errorf("transliteration version %d is not supported (this side uses version %d)", p.Version, Version)
+	}
+	return New(p.Scripts)
+}
+
+// Enabled reports whether the profile transliterates any script
+func (p Profile) Enabled() bool {
+	return len(p.Scripts) > 0
+}
+
+// String identifies the profile, e.g. "v1:cyrillic,greek", or "" when
+// disabled. Equal strings mean identical output.
+func (p Profile) String() string {
+	if !p.Enabled() {
+		return ""
+	}
+	return fmt.Sprintf("v%d:%s", p.Version, strings.Join(p.Scripts, ","))
+}
+
+// Apply lowercases s and romanizes the characters of the profile's scripts.
+// Characters of other scripts are kept.
+func (p Profile) Apply(s string) string {
+	if !p.Enabled() {
+		return s
+	}
+	var kana *kanaState
+	var b strings.Builder
+	for _, r := range strings.ToLower(s) {
+		if kana != nil && !isKana(r) {
+			kana.flush(&b)
+			kana = nil
+		}
+		done := false
+		for _, name := range p.Scripts {
+			if name == "kana" && isKana(r) {
+				if kana == nil {
+					kana = &kanaState{}
+				}
+				kana.add(r)
+				done = true
+				break
+			}
+			if out, ok := tables[name](r); ok {
+				b.WriteString(out)
+				done = true
+				break
+			}
+		}
+		if !done {
+			b.WriteRune(r)
+		}
+	}
+	if kana != nil {
+		kana.flush(&b)
+	}
+	return b.String()
+}
+
+// tables maps each script to a lookup of one lowercase rune. Kana needs
+// context and is handled by kanaState; its entry only reports membership.
+var tables = map[string]func(rune) (string, bool){
+	"arabic":   lookup(arabic),
+	"cyrillic": lookup(cyrillic),
+	"greek":    lookup(greek),
+	"hangul":   hangul,
+	"kana":     func(r rune) (string, bool) { return "", false },
+	"latin":    lookup(latin),
+}
+
+func lookup(table map[rune]string) func(rune) (string, bool) {
+	return func(r rune) (string, bool) {
+		out, ok := table[r]
+		return out, ok
+	}
+}
+
+var cyrillic = map[rune]string{
+	'а': "a", 'б': "b", 'в': "v", 'г': "g", 'д': "d", 'е': "e", 'ё': "e", 'ж': "zh",
+	'з': "z", 'и': "i", 'й': "y", 'к': "k", 'л': "l", 'м': "m", 'н': "n", 'о': "o",
+	'п': "p", 'р': "r", 'с': "s", 'т': "t", 'у': "u", 'ф': "f", 'х': "kh", 'ц': "ts",
+	'ч': "ch", 'ш': "sh", 'щ': "shch", 'ъ': "", 'ы': "y", 'ь': "", 'э': "e", 'ю': "yu",
+	'я': "ya",
+	// Ukrainian, Belarusian and South Slavic letters
+	'і': "i", 'ї': "yi", 'є': "ye", 'ґ': "g", 'ў': "u", 'ј': "j", 'љ': "lj", 'њ': "nj",
+	'ћ': "c", 'ђ': "dj", 'џ': "dz", 'ѓ': "gj", 'ќ': "kj", 'ѕ': "dz",
+}
+
+var greek = map[rune]string{
+	'α': "a", 'β': "v", 'γ': "g", 'δ': "d", 'ε': "e", 'ζ': "z", 'η': "i", 'θ': "th",
+	'ι': "i", 'κ': "k", 'λ': "l", 'μ': "m", 'ν': "n", 'ξ': "x", 'ο': "o", 'π': "p",
+	'ρ': "r", 'σ': "s", 'ς': "s", 'τ': "t", 'υ': "y", 'φ': "f", 'χ': "ch", 'ψ': "ps",
+	'ω': "o",
+	'ά': "a", 'έ': "e", 'ή': "i", 'ί': "i", 'ό': "o", 'ύ': "y", 'ώ': "o", 'ϊ': "i",
+	'ϋ': "y", 'ΐ': "i", 'ΰ': "y",
+}
+
+var arabic = map[rune]string{
+	'ا': "a", 'أ': "a", 'إ': "i", 'آ': "a", 'ٱ': "a", 'ء': "", 'ؤ': "", 'ئ': "",
+	'ب': "b", 'ت': "t", 'ث': "th", 'ج': "j", 'ح': "h", 'خ': "kh", 'د': "d", 'ذ': "dh",
+	'ر': "r", 'ز': "z", 'س': "s", 'ش': "sh", 'ص': "s", 'ض': "d", 'ط': "t", 'ظ': "z",
+	'ع': "", 'غ': "gh", 'ف': "f", 'ق': "q", 'ك': "k", 'ل': "l", 'م': "m", 'ن': "n",
+	'ه': "h", 'و': "w", 'ي': "y", 'ى': "a", 'ة': "a",
+	// Persian and Urdu letters
+	'پ': "p", 'چ': "ch", 'ژ': "zh", 'گ': "g", 'ک': "k", 'ی': "y",
+	// Tatweel and the short vowel and shadda marks
+	'ـ': "", 'ً': "", 'ٌ': "", 'ٍ': "", 'َ': "", 'ُ': "", 'ِ': "", 'ّ': "", 'ْ': "",
+}
+
+var latin = map[rune]string{
+	'à': "a", 'á': "a", 'â': "a", 'ã': "a", 'ä': "a", 'å': "a", 'ā': "a", 'ă': "a", 'ą': "a",
+	'æ': "ae", 'ç': "c", 'ć': "c", 'ĉ': "c", 'ċ': "c", 'č': "c", 'ď': "d", 'đ': "d", 'ð': "d",
+	'è': "e", 'é': "e", 'ê': "e", 'ë': "e", 'ē': "e", 'ĕ': "e", 'ė': "e", 'ę': "e", 'ě': "e",
+	'ĝ': "g", 'ğ': "g", 'ġ': "g", 'ģ': "g", 'ĥ': "h", 'ħ': "h",
+	'ì': "i", 'í': "i", 'î': "i", 'ï': "i", 'ĩ': "i", 'ī': "i", 'ĭ': "i", 'į': "i", 'ı': "i",
+	'ĵ': "j", 'ķ': "k", 'ĺ': "l", 'ļ': "l", 'ľ': "l", 'ŀ': "l", 'ł': "l",
+	'ñ': "n", 'ń': "n", 'ņ': "n", 'ň': "n",
+	'ò': "o", 'ó': "o", 'ô': "o", 'õ': "o", 'ö': "o", 'ø': "o", 'ō': "o", 'ŏ': "o", 'ő': "o",
+	'œ': "oe", 'ŕ': "r", 'ŗ': "r", 'ř': "r", 'ś': "s", 'ŝ': "s", 'ş': "s", 'š': "s", 'ș': "s",
+	'ß': "ss", 'ţ': "t", 'ť': "t", 'ŧ': "t", 'ț': "t", 'þ': "th",
+	'ù': "u", 'ú': "u", 'û': "u", 'ü': "u", 'ũ': "u", 'ū': "u", 'ŭ': "u", 'ů': "u", 'ű': "u", 'ų': "u",
+	'ŵ': "w", 'ý': "y", 'ÿ': "y", 'ŷ': "y", 'ź': "z", 'ż': "z", 'ž': "z",
+}
+
+// Revised Romanization of the jamo of a precomposed Hangul syllable
+var (
+	hangulInitials = []string{"g", "kk", "n", "d", "tt", "r", "m", "b", "pp", "s", "ss", "", "j", "jj", "ch", "k", "t", "p", "h"}
+	hangulMedials  = []string{"a", "ae", "ya", "yae", "eo", "e", "yeo", "ye", "o", "wa", "wae", "oe", "yo", "u", "wo", "we", "wi", "yu", "eu", "ui", "i"}
+	hangulFinals   = []string{"", "k", "k", "k", "n", "n", "n", "t", "l", "k", "m", "l", "l", "l", "p", "l", "m", "p", "p", "t", "t", "ng", "t", "t", "k", "t", "p", "t"}
+)
+
+func hangul(r rune) (string, bool) {
+	if r < 0xAC00 || r > 0xD7A3 {
+		return "", false
+	}
+	i := int(r - 0xAC00)
+	return hangulInitials[i/588] + hangulMedials[(i%588)/28] + hangulFinals[i%28], true
+}
+
+// Hepburn romanization of hiragana; katakana are mapped onto hiragana first
+var hiragana = map[rune]string{
+	'あ': "a", 'い': "i", 'う': "u", 'え': "e", 'お': "o",
+	'か': "ka", 'き': "ki", 'く': "ku", 'け': "ke", 'こ': "ko",
+	'が': "ga", 'ぎ': "gi", 'ぐ': "gu", 'げ': "ge", 'ご': "go",
+	'さ': "sa", 'し': "shi", 'す': "su", 'せ': "se", 'そ': "so",
+	'ざ': "za", 'じ': "ji", 'ず': "zu", 'ぜ': "ze", 'ぞ': "zo",
+	'た': "ta", 'ち': "chi", 'つ': "tsu", 'て': "te", 'と': "to",
+	'だ': "da", 'ぢ': "ji", 'づ': "zu", 'で': "de", 'ど': "do",
+	'な': "na", 'に': "ni", 'ぬ': "nu", 'ね': "ne", 'の': "no",
+	'は': "ha", 'ひ': "hi", 'ふ': "fu", 'へ': "he", 'ほ': "ho",
+	'ば': "ba", 'び': "bi", 'ぶ': "bu", 'べ': "be", 'ぼ': "bo",
+	'ぱ': "pa", 'ぴ': "pi", 'ぷ': "pu", 'ぺ': "pe", 'ぽ': "po",
+	'ま': "ma", 'み': "mi", 'む': "mu", 'め': "me", 'も': "mo",
+	'や': "ya", 'ゆ': "yu", 'よ': "yo",
+	'ら': "ra", 'り': "ri", 'る': "ru", 'れ': "re", 'ろ': "ro",
+	'わ': "wa", 'ゐ': "i", 'ゑ': "e", 'を': "o", 'ん': "n", 'ゔ': "vu",
+	'ぁ': "a", 'ぃ': "i", 'ぅ': "u", 'ぇ': "e", 'ぉ': "o", 'ゎ': "wa",
+}
+
+// Small ya, yu and yo combine with the preceding i-row kana (き + ゃ = kya)
+var smallY = map[rune]string{'ゃ': "a", 'ゅ': "u", 'ょ': "o"}
+
+const (
+	sokuon    = 'っ' // Doubles the following consonant
+	longVowel = 'ー' // Dropped, as in common name romanizations
+)
+
+func isKana(r rune) bool {
+	return (r >= 0x3041 && r <= 0x3096) || (r >= 0x30A1 && r <= 0x30FC)
+}
+
+// kanaState romanizes a run of kana, which needs one character of context
+type kanaState struct {
+	out    []byte
+	double bool // A sokuon is waiting for the next consonant
+}
+
+func (k *kanaState) add(r rune) {
+	if r >= 0x30A1 && r <= 0x30F6 {
+		r -= 0x60 // Katakana to hiragana
+	}
+	switch {
+	case r == longVowel || r == 0x30FB: // Long vowel mark, middle dot
+		return
+	case r == sokuon || r == 'ッ':
+		k.double = true
+		return
+	}
+	if v, ok := smallY[r]; ok {
+		s := string(k.out)
+		if base, found := strings.CutSuffix(s, "i"); found && base != "" {
+			if strings.HasSuffix(base, "sh") || strings.HasSuffix(base, "ch") || strings.HasSuffix(base, "j") {
+				k.out = append([]byte(base), v...)
+			} else {
+				k.out = append([]byte(base), 'y')
+				k.out = append(k.out, v...)
+			}
+			return
+		}
+		k.out = append(k.out, 'y')
+		k.out = append(k.out, v...)
+		return
+	}
+	out, ok := hiragana[r]
+	if !ok {
+		out = string(r)
+	}
+	if k.double && out != "" && !strings.ContainsRune("aeiou", rune(out[0])) {
+		if strings.HasPrefix(out, "ch") {
+			k.out = append(k.out, 't')
+		} else {
+			k.out = append(k.out, out[0])
+		}
+	}
+	k.double = false
+	k.out = append(k.out, out...)
+}
+
+func (k *kanaState) flush(b *strings.Builder) {
+	b.Write(k.out)
+	k.out = k.out[:0]
+	k.double = false
+}
@@ -0,0 +1,107 @@
+package translit
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestApply(t *testing.T) {
+	all, err := Parse("all")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for in, want := range map[string]string{
+		"Владимир Путин":      "vladimir putin",
+		"Щукин Ёлкин":         "shchukin elkin",
+		"Їжак Ґонта":          "yizhak gonta",
+		"Ψωμάς Αλέξης":        "psomas alexis",
+		"محمد عبد الله":       "mhmd bd allh",
+		"مُحَمَّد":            "mhmd",
+		"김정은":                 "gimjeongeun",
+		"きょうこ":                "kyouko",
+		"シャチョウ":               "shachou",
+		"マッチャ":                "matcha",
+		"ホッカイドウ":              "hokkaidou",
+		"ラーメン":                "ramen",
+		"きむ Kim":              "kimu kim",
+		"José Müller-Øberg":   "jose muller-oberg",
+		"Straße Œuvre":        "strasse oeuvre",
+		"習近平":                 "習近平",
+		"Ivan Petrov 1970-01": "ivan petrov 1970-01",
+	} {
+		if got := all.Apply(in); got != want {
+			t.Errorf("Apply(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestApplyOnlyProfileScripts(t *testing.T) {
+	p, err := New([]string{"cyrillic"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := p.Apply("Пётр Ωμέγα José"), "petr ωμέγα josé"; got != want {
+		t.Errorf("Apply = %q, want %q", got, want)
+	}
+	// A disabled profile leaves names as they are
+	if got := (Profile{}).Apply("Пётр"); got != "Пётр" {
+		t.Errorf("disabled Apply = %q", got)
+	}
+}
+
+func TestParse(t *testing.T) {
+	for in, want := range map[string]Profile{
+		"":                         {},
+		" , ":                      {},
+		"Greek, cyrillic,greek":    {Version: Version, Scripts: []string{"cyrillic", "greek"}},
+		"all":                      {Version: Version, Scripts: Scripts},
+		"latin,ALL":                {Version: Version, Scripts: Scripts},
+		"hangul":                   {Version: Version, Scripts: []string{"hangul"}},
+		"kana,arabic,latin,hangul": {Version: Version, Scripts: []string{"arabic", "hangul", "kana", "latin"}},
+	} {
+		got, err := Parse(in)
+		if err != nil {
+			t.Errorf("Parse(%q): %v", in, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("Parse(%q) = %+v, want %+v", in, got, want)
+		}
+	}
+	if _, err := Parse("cyrillic,klingon"); err == nil {
+		t.Error("unknown script accepted")
+	}
+}
+
+func TestProfileString(t *testing.T) {
+	p, _ := Parse("greek,cyrillic")
+	if got := p.String(); got != "v1:cyrillic,greek" {
+		t.Errorf("String() = %q", got)
+	}
+	if got := (Profile{}).String(); got != "" {
+		t.Errorf("disabled String() = %q, want empty", got)
+	}
+}
+
+func TestCheck(t *testing.T) {
+	got, err := Profile{Version: Version, Scripts: []string{"greek", "Cyrillic", "greek"}}.Check()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := (Profile{Version: Version, Scripts: []string{"cyrillic", "greek"}}); !reflect.DeepEqual(got, want) {
+		t.Errorf("Check() = %+v, want %+v", got, want)
+	}
+
+	// Without scripts the version does not matter
+	if got, err := (Profile{Version: 99}).Check(); err != nil || got.Enabled() {
+		t.Errorf("empty profile: %+v, %v", got, err)
+	}
+	for _, p := range []Profile{
+		{Version: Version + 1, Scripts: []string{"greek"}},
+		{Version: Version, Scripts: []string{"klingon"}},
+	} {
+		if _, err := p.Check(); err == nil {
+			t.Errorf("Check accepted %+v", p)
+		}
+	}
+}
@@ -0,0 +1,196 @@
+// Package treearchive packages the PSI server's tree databases for handing
+// off to another party: a gzipped tar of each tree with its manifest, the
+// public params, and a SHA256SUMS file covering every other entry, so the
+// receiver can check the archive before loading anything from it.
+package treearchive
+
+import (
+	"archive/tar"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	// ChecksumsFile is the archive entry listing the SHA-256 of every other
+	// entry, in sha256sum format
+	ChecksumsFile = "SHA256SUMS"
+	// ParamsFile is the archive entry holding the trees' public params
+	ParamsFile = "params.json"
+	// TreeDir is the archive directory holding the tree databases and their
+	// manifests
+	TreeDir = "trees"
+)
+
+// ErrChecksum is returned by Verify when an entry does not match its
+// recorded checksum, or an entry and checksum do not pair up
+var ErrChecksum = errors.New("archive checksum mismatch")
+
+// Writer writes an archive. Files are added in order; Close writes the
+// checksums and flushes the archive.
+type Writer struct {
+	gz      *gzip.Writer
+	tw      *tar.Writer
+	sums    map[string]string
+	modTime time.Time
+}
+
+// NewWriter starts an archive on w
+func NewWriter(w io.Writer) *Writer {
+	gz := gzip.NewWriter(w)
+	return &Writer{gz: gz, tw: tar.NewWriter(gz), sums: make(map[string]string), modTime: time.Now().UTC()}
+}
+
+// AddFile adds the file at src as the entry name
+func (a *Writer) AddFile(name, src string) error {
+	f, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	return a.add(name, info.Size(), f)
+}
+
+// AddBytes adds data as the entry name
+func (a *Writer) AddBytes(name string, data []byte) error {
+	return a.add(name, int64(len(data)), bytes.NewReader(data))
+}
+
+func (a *Writer) add(name string, size int64, r io.Reader) error {
+	if _, dup := a.sums[name]; dup || name == ChecksumsFile {
+		return fmt.Errorf("duplicate archive entry %s", name)
+	}
+	if err := a.tw.WriteHeader(&tar.Header{Name: name, Mode: 0600, Size: size, ModTime: a.modTime}); err != nil {
+		return err
+	}
+	h := sha256.New()
+	// A file that changes size while it is copied fails the tar writer
+	if _, err := io.Copy(io.MultiWriter(a.tw, h), r); err != nil {
+		return fmt.Errorf("archive %s: %w", name, err)
+	}
+	a.sums[name] = hex.EncodeToString(h.Sum(nil))
+	return nil
+}
+
+// Close writes the checksums entry and finishes the archive
+func (a *Writer) Close() error {
+	names := make([]string, 0, len(a.sums))
+	for name := range a.sums {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var sums strings.Builder
+	for _, name := range names {
+		fmt.Fprintf(&sums, "%s  %s\n", a.sums[name], name)
+	}
+	data := sums.String()
+	if err := a.tw.WriteHeader(&tar.Header{Name: ChecksumsFile, Mode: 0600, Size: int64(len(data)), ModTime: a.modTime}); err != nil {
+		return err
+	}
+	if _, err := io.WriteString(a.tw, data); err != nil {
+		return err
+	}
+	if err := a.tw.Close(); err != nil {
+		return err
+	}
+	return a.gz.Close()
+}
+
+// Entry is a verified archive entry
+type Entry struct {
+	Name   string
+	Size   int64
+	SHA256 string
+}
+
+// Verify reads an archive and checks every entry against SHA256SUMS. It
+// fails if an entry is missing, unlisted or altered.
+func Verify(r io.Reader) ([]Entry, error) {
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, fmt.Errorf("not a tree archive: %w", err)
+	}
+	defer gz.Close()
+
+	var entries []Entry
+	var listed map[string]string
+	tr := tar.NewReader(gz)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("read archive: %w", err)
+		}
+		if hdr.Name == ChecksumsFile {
+			if listed, err = parseChecksums(tr); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		h := sha256.New()
+		size, err := io.Copy(h, tr)
+		if err != nil {
+			return nil, fmt.Errorf("read %s: %w", hdr.Name, err)
+		}
+		entries = append(entries, Entry{Name: hdr.Name, Size: size, SHA256: hex.EncodeToString(h.Sum(nil))})
+	}
+
+	if listed == nil {
+		return nil, fmt.Errorf("%w: no %s", ErrChecksum, ChecksumsFile)
+	}
+	for _, e := range entries {
+		sum, ok := listed[e.Name]
+		if !ok {
+			return nil, fmt.Errorf("%w: %s is not listed", ErrChecksum, e.Name)
+		}
+		if sum != e.SHA256 {
+			return nil, fmt.Errorf("%w: %s", ErrChecksum, e.Name)
+		}
+		delete(listed, e.Name)
+	}
+	if len(listed) > 0 {
+		missing := make([]string, 0, len(listed))
+		for name := range listed {
+			missing = append(missing, name)
+		}
+		sort.Strings(missing)
+		return nil, fmt.Errorf("%w: %s missing", ErrChecksum, strings.Join(missing, ", "))
+	}
+	return entries, nil
+}
+
+// parseChecksums reads sha256sum output into a map of name to checksum
+func parseChecksums(r io.Reader) (map[string]string, error) {
+	sums := make(map[string]string)
+	sc := bufio.NewScanner(r)
+	for sc.Scan() {
+		sum, name, ok := strings.Cut(sc.Text(), "  ")
+		if !ok || len(sum) != sha256.Size*2 {
+			return nil, fmt.Errorf("%w: malformed %s line %q", ErrChecksum, ChecksumsFile, sc.Text())
+		}
+		sums[name] = sum
+	}
+	return sums, sc.Err()
+}
+
+// TreeEntry names the archive entry of the tree database at treePath
+func TreeEntry(treePath string) string {
+	return path.Join(TreeDir, filepath.Base(treePath))
+}
@@ -0,0 +1,65 @@
+package uploadscan
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+)
+
+// clamChunk is the size of the chunks a file is streamed to clamd in
+const clamChunk = 64 << 10
+
+// ClamAV scans files with a clamd daemon over its INSTREAM command
+type ClamAV struct {
+	Addr    string // host:port, or the path of a unix socket
+	Timeout time.Duration
+}
+
+func (c ClamAV) Scan(ctx context.Context, name string, content []byte) error {
+	network := "tcp"
+	if strings.HasPrefix(c.Addr, "/") {
+		network = "unix"
+	}
+	dialer := net.Dialer{Timeout: c.Timeout}
+	conn, err := dialer.DialContext(ctx, network, c.Addr)
+	if err != nil {
+		return fmt.Errorf("connect to clamd: %w", err)
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(c.Timeout))
+
+	w := bufio.NewWriter(conn)
+	w.WriteString("zINSTREAM\x00")
+	var size [4]byte
+	for start := 0; start < len(content); start += clamChunk {
+		chunk := content[start:min(start+clamChunk, len(content))]
+		binary.BigEndian.PutUint32(size[:], uint32(len(chunk)))
+		w.Write(size[:])
+		w.Write(chunk)
+	}
+	binary.BigEndian.PutUint32(size[:], 0)
+	w.Write(size[:])
+	if err := w.Flush(); err != nil {
+		return fmt.Errorf("send to clamd: %w", err)
+	}
+
+	reply, err := bufio.NewReader(conn).ReadBytes(0)
+	if err != nil && len(reply) == 0 {
+		return fmt.Errorf("read clamd reply: %w", err)
+	}
+	// The reply is "stream: OK" or "stream: <signature> FOUND"
+	result := strings.TrimSpace(string(bytes.TrimRight(reply, "\x00")))
+	result = strings.TrimPrefix(result, "stream: ")
+	switch {
+	case result == "OK":
+		return nil
+	case strings.HasSuffix(result, " FOUND"):
+		return Reject(ReasonMalware, "%s contains malware (%s)", name, strings.TrimSuffix(result, " FOUND"))
+	}
+	return fmt.Errorf("clamd: %s", result)
+}
@@ -0,0 +1,82 @@
+package uploadscan
+
+import (
+	"bytes"
+	"context"
+	"path/filepath"
+	"strings"
+)
+
+// signatures are the leading bytes of file types that are never lists
+var signatures = []struct {
+	prefix string
+	kind   string
+}{
+	{"MZ", "a Windows executable"},
+	{"\x7fELF", "an ELF executable"},
+	{"\xfe\xed\xfa\xce", "a Mach-O executable"},
+	{"\xfe\xed\xfa\xcf", "a Mach-O executable"},
+	{"\xce\xfa\xed\xfe", "a Mach-O executable"},
+	{"\xcf\xfa\xed\xfe", "a Mach-O executable"},
+	{"\xca\xfe\xba\xbe", "a Mach-O or Java class file"},
+	{"#!", "a script"},
+	{"PK\x03\x04", "a ZIP archive or XLSX/ODS spreadsheet"},
+	{"\xd0\xcf\x11\xe0\xa1\xb1\x1a\xe1", "an XLS or other Office document"},
+	{"%PDF-", "a PDF document"},
+	{"\x1f\x8b", "a gzip archive"},
+	{"Rar!\x1a\x07", "a RAR archive"},
+	{"7z\xbc\xaf\x27\x1c", "a 7-Zip archive"},
+}
+
+// markupSignatures are markup documents spreadsheet software saves under
+// spreadsheet names, matched case-insensitively at the start
+var markupSignatures = []struct {
+	prefix string
+	kind   string
+}{
+	{"<html", "an HTML document"},
+	{"<!doctype html", "an HTML document"},
+	{"<table", "an HTML table"},
+}
+
+// sniffLength is how much of a file is inspected for binary content
+const sniffLength = 8 << 10
+
+// ContentCheck refuses files that are not delimited text: executables,
+// archives, PDFs and spreadsheets in binary or markup formats, even when
+// named .csv. Files named .xlsx may be ZIP archives, which the upload
+// handlers convert to CSV.
+type ContentCheck struct{}
+
+func (ContentCheck) Scan(_ context.Context, name string, content []byte) error {
+	workbook := strings.EqualFold(filepath.Ext(name), ".xlsx")
+	for _, s := range signatures {
+		if workbook && s.prefix == "PK\x03\x04" {
+			continue
+		}
+		if bytes.HasPrefix(content, []byte(s.prefix)) {
+			return Reject(ReasonFileType, "%s is %s, not a CSV file", name, s.kind)
+		}
+	}
+
+	// UTF-16 text is full of NUL bytes; anything else with them is binary
+	if bytes.HasPrefix(content, []byte("\xff\xfe")) || bytes.HasPrefix(content, []byte("\xfe\xff")) {
+		return nil
+	}
+	text := bytes.TrimPrefix(content, []byte("\xef\xbb\xbf"))
+	head := text[:min(len(text), sniffLength)]
+	if bytes.IndexByte(head, 0) >= 0 {
+		return Reject(ReasonFileType, "%s is a binary file, not a CSV file", name)
+	}
+
+	lead := bytes.ToLower(bytes.TrimSpace(head))
+	for _, s := range markupSignatures {
+		if bytes.HasPrefix(lead, []byte(s.prefix)) {
+			return Reject(ReasonFileType, "%s is %s, not a CSV file", name, s.kind)
+		}
+	}
+	if bytes.HasPrefix(lead, []byte("<?xml")) && bytes.Contains(lead, []byte("urn:schemas-microsoft-com:office:spreadsheet")) {
+		return Reject(ReasonFileType, "%s is an Excel XML spreadsheet, not a CSV file", name)
+	}
+	return nil
+}
@@ -0,0 +1,121 @@
+// Package uploadscan checks uploaded list files before they are stored: a
+// size limit, a content check that refuses files that are not text (such
+// as executables, or spreadsheets renamed to .csv), an optional ClamAV scan
+// and any custom validators.
+//
+// A refused file returns a *Rejection, which WriteError turns into a 4xx
+// response. Other errors mean a scanner could not run; the upload is
+// refused with a 503 rather than stored unscanned.
+package uploadscan
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/SanthoshCheemala/FLARE/backend/internal/apierror"
+	"github.com/SanthoshCheemala/FLARE/backend/internal/config"
+)
+
+// Reason classifies why a file was refused
+type Reason string
+
+const (
+	ReasonTooLarge Reason = "too_large"
+	ReasonFileType Reason = "file_type"
+	ReasonMalware  Reason = "malware"
+	ReasonInvalid  Reason = "invalid" // Refused by a custom validator
+)
+
+// Rejection is the error a scanner returns for a file it refuses
+type Rejection struct {
+	Reason  Reason
+	Message string
+}
+
+func (r *Rejection) Error() string {
+	return r.Message
+}
+
+// Reject returns a Rejection, for custom validators
+func Reject(reason Reason, format string, args ...interface{}) *Rejection {
+	return &Rejection{Reason: reason, Message: fmt.Sprintf(format, args...)}
+}
+
+// Scanner inspects the content of an uploaded file. It returns a
+// *Rejection to refuse the file and any other error when it cannot tell.
+type Scanner interface {
+	Scan(ctx context.Context, name string, content []byte) error
+}
+
+// ScannerFunc adapts a function to a Scanner
+type ScannerFunc func(ctx context.Context, name string, content []byte) error
+
+func (f ScannerFunc) Scan(ctx context.Context, name string, content []byte) error {
+	return f(ctx, name, content)
+}
+
+// Checker runs the configured scanners over uploads. A nil *Checker accepts
+// every file.
+type Checker struct {
+	maxBytes int64
+	scanners []Scanner
+}
+
+// New returns a Checker for cfg: the size limit, the content check and, if
+// an address is set, ClamAV
+func New(cfg config.UploadConfig) *Checker {
+	c := &Checker{maxBytes: int64(cfg.MaxMB) << 20}
+	c.Use(ContentCheck{})
+	if cfg.ClamAVAddr != "" {
+		c.Use(ClamAV{Addr: cfg.ClamAVAddr, Timeout: cfg.ScanTimeout})
+	}
+	return c
+}
+
+// Use adds a scanner, run after those added before it
+func (c *Checker) Use(s Scanner) {
+	c.scanners = append(c.scanners, s)
+}
+
+// Check reads an upload named name and runs every scanner over it,
+// stopping at the first that refuses it. It returns the content.
+func (c *Checker) Check(ctx context.Context, name string, r io.Reader) ([]byte, error) {
+	if c == nil {
+		return io.ReadAll(r)
+	}
+	content, err := io.ReadAll(io.LimitReader(r, c.maxBytes+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(content)) > c.maxBytes {
+		return nil, Reject(ReasonTooLarge, "file exceeds the %d MB upload limit", c.maxBytes>>20)
+	}
+	for _, s := range c.scanners {
+		if err := s.Scan(ctx, name, content); err != nil {
+			return nil, err
+		}
+	}
+	return content, nil
+}
+
+// WriteError sends the response for an upload Check refused or could not
+// scan
+func WriteError(w http.ResponseWriter, r *http.Request, err error) {
+	var rejection *Rejection
+	if !errors.As(err, &rejection) {
+		apierror.Write(w, r, http.StatusServiceUnavailable, apierror.CodeUpstreamFailed, fmt.Sprintf("Upload could not be scanned: %v", err))
+		return
+	}
+	status := http.StatusUnprocessableEntity
+	switch rejection.Reason {
+	case ReasonTooLarge:
+		status = http.StatusRequestEntityTooLarge
+	case ReasonFileType:
+		status = http.StatusUnsupportedMediaType
+	}
+	apierror.WriteDetails(w, r, status, apierror.CodeUploadRejected, "Upload rejected: "+rejection.Message,
+		map[string]string{"reason": string(rejection.Reason)})
+}
@@ -0,0 +1,67 @@
+package xlsx
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"time"
+)
+
+// columnIndex returns the 0-based column of a cell reference such as "C7"
+func columnIndex(ref string) (int, error) {
+	col := 0
+	i := 0
+	for ; i < len(ref) && ref[i] >= 'A' && ref[i] <= 'Z'; i++ {
+		col = col*26 + int(ref[i]-'A'+1)
+	}
+	// XFD is the last column Excel allows
+	if i == 0 || col > 16384 {
+		return 0, fmt.Errorf("invalid cell reference %q", ref)
+	}
+	return col - 1, nil
+}
+
+// builtinDateFormat reports whether a built-in number format shows a date
+func builtinDateFormat(id int) bool {
+	return (id >= 14 && id <= 22) || (id >= 45 && id <= 47)
+}
+
+// isDateFormat reports whether a custom number format code shows a date:
+// it has day, month or year placeholders outside quoted text, escapes and
+// bracketed sections such as colors
+func isDateFormat(code string) bool {
+	inQuote, inBracket := false, false
+	for i := 0; i < len(code); i++ {
+		c := code[i]
+		switch {
+		case inQuote:
+			inQuote = c != '"'
+		case inBracket:
+			inBracket = c != ']'
+		case c == '"':
+			inQuote = true
+		case c == '[':
+			inBracket = true
+		case c == '\\' || c == '_' || c == '*':
+			i++ // Escaped or padding character
+		case strings.IndexByte("dmyDMY", c) >= 0:
+			return true
+		}
+	}
+	return false
+}
+
+// serialDate converts an Excel date serial number to YYYY-MM-DD. In the
+// default 1900 date system Excel counts the nonexistent 29 February 1900,
+// so serials from 61 on count from 30 December 1899.
+func serialDate(serial float64, date1904 bool) string {
+	days := int(math.Floor(serial))
+	base := time.Date(1899, time.December, 30, 0, 0, 0, 0, time.UTC)
+	switch {
+	case date1904:
+		base = time.Date(1904, time.January, 1, 0, 0, 0, 0, time.UTC)
+	case days < 61:
+		base = time.Date(1899, time.December, 31, 0, 0, 0, 0, time.UTC)
+	}
+	return base.AddDate(0, 0, days).Format("2006-01-02")
+}
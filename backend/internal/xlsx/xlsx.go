@@ -0,0 +1,328 @@
+// Package xlsx reads the cell values of Excel workbooks (Office Open XML,
+// .xlsx) so they can be uploaded where CSV files are expected. Only values
+// are read: shared and inline strings, numbers, booleans and dates, which
+// are written as YYYY-MM-DD. Formulas contribute their cached result.
+package xlsx
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/csv"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"strconv"
+	"strings"
+)
+
+// maxPartBytes bounds the decompressed size of each part of a workbook, so
+// a small upload cannot expand without limit
+const maxPartBytes = 256 << 20
+
+// ErrNoSheet is returned when a workbook has no sheet of the requested
+// name or index
+var ErrNoSheet = errors.New("no such sheet")
+
+// IsWorkbook reports whether an upload named name is an XLSX workbook
+func IsWorkbook(name string, content []byte) bool {
+	return strings.EqualFold(path.Ext(name), ".xlsx") && bytes.HasPrefix(content, []byte("PK\x03\x04"))
+}
+
+// Workbook is an opened XLSX file
+type Workbook struct {
+	files    map[string]*zip.File
+	sheets   []sheetRef
+	strings  []string
+	dateXfs  map[int]bool // Cell styles that format numbers as dates
+	date1904 bool
+}
+
+type sheetRef struct {
+	name string
+	path string
+}
+
+// Open reads the workbook structure, shared strings and styles of data
+func Open(data []byte) (*Workbook, error) {
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, fmt.Errorf("not an XLSX file: %w", err)
+	}
+	wb := &Workbook{files: make(map[string]*zip.File, len(zr.File))}
+	for _, f := range zr.File {
+		wb.files[f.Name] = f
+	}
+
+	var book struct {
+		Properties struct {
+			Date1904 bool `xml:"date1904,attr"`
+		} `xml:"workbookPr"`
+		Sheets []struct {
+			Name string `xml:"name,attr"`
+			RID  string `xml:"http://schemas.openxmlformats.org/officeDocument/2006/relationships id,attr"`
+		} `xml:"sheets>sheet"`
+	}
+	if err := wb.decode("xl/workbook.xml", &book); err != nil {
+		return nil, err
+	}
+	var rels struct {
+		Relationships []struct {
+			ID     string `xml:"Id,attr"`
+			Target string `xml:"Target,attr"`
+		} `xml:"Relationship"`
+	}
+	if err := wb.decode("xl/_rels/workbook.xml.rels", &rels); err != nil {
+		return nil, err
+	}
+	targets := make(map[string]string, len(rels.Relationships))
+	for _, rel := range rels.Relationships {
+		// Targets are relative to xl/ unless absolute
+		if strings.HasPrefix(rel.Target, "/") {
+			targets[rel.ID] = strings.TrimPrefix(rel.Target, "/")
+		} else {
+			targets[rel.ID] = path.Join("xl", rel.Target)
+		}
+	}
+	wb.date1904 = book.Properties.Date1904
+	for _, s := range book.Sheets {
+		wb.sheets = append(wb.sheets, sheetRef{name: s.Name, path: targets[s.RID]})
+	}
+
+	if err := wb.readSharedStrings(); err != nil {
+		return nil, err
+	}
+	if err := wb.readStyles(); err != nil {
+		return nil, err
+	}
+	return wb, nil
+}
+
+// Sheets returns the names of the workbook's sheets in order
+func (wb *Workbook) Sheets() []string {
+	names := make([]string, len(wb.sheets))
+	for i, s := range wb.sheets {
+		names[i] = s.name
+	}
+	return names
+}
+
+// Rows returns the cell values of a sheet, selected by name or by 1-based
+// index; empty selects the first. Empty rows are dropped and the rest are
+// padded to the same width, as CSV readers expect.
+func (wb *Workbook) Rows(sheet string) ([][]string, error) {
+	ref, err := wb.sheet(sheet)
+	if err != nil {
+		return nil, err
+	}
+
+	var ws struct {
+		Rows []struct {
+			Cells []struct {
+				Ref    string   `xml:"r,attr"`
+				Type   string   `xml:"t,attr"`
+				Style  int      `xml:"s,attr"`
+				Value  string   `xml:"v"`
+				Inline richText `xml:"is"`
+			} `xml:"c"`
+		} `xml:"sheetData>row"`
+	}
+	if err := wb.decode(ref.path, &ws); err != nil {
+		return nil, err
+	}
+
+	rows := make([][]string, 0, len(ws.Rows))
+	width := 0
+	for _, row := range ws.Rows {
+		var values []string
+		for i, c := range row.Cells {
+			col := i
+			if c.Ref != "" {
+				if col, err = columnIndex(c.Ref); err != nil {
+					return nil, err
+				}
+			}
+			value, err := wb.cellValue(c.Type, c.Style, c.Value, c.Inline)
+			if err != nil {
+				return nil, fmt.Errorf("sheet %q cell %s: %w", ref.name, c.Ref, err)
+			}
+			if value == "" {
+				continue
+			}
+			for len(values) < col {
+				values = append(values, "")
+			}
+			values = append(values[:col], value)
+		}
+		if len(values) > 0 {
+			rows = append(rows, values)
+			width = max(width, len(values))
+		}
+	}
+	for i, row := range rows {
+		for len(row) < width {
+			row = append(row, "")
+		}
+		rows[i] = row
+	}
+	return rows, nil
+}
+
+// ToCSV converts a sheet of an XLSX file, selected as for Rows, to CSV
+func ToCSV(data []byte, sheet string) ([]byte, error) {
+	wb, err := Open(data)
+	if err != nil {
+		return nil, err
+	}
+	rows, err := wb.Rows(sheet)
+	if err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+	if err := w.WriteAll(rows); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// sheet finds a sheet by name, then by 1-based index
+func (wb *Workbook) sheet(sheet string) (sheetRef, error) {
+	if len(wb.sheets) == 0 {
+		return sheetRef{}, fmt.Errorf("%w: the workbook has no sheets", ErrNoSheet)
+	}
+	if sheet == "" {
+		return wb.sheets[0], nil
+	}
+	for _, s := range wb.sheets {
+		if s.name == sheet {
+			return s, nil
+		}
+	}
+	if i, err := strconv.Atoi(sheet); err == nil && i >= 1 && i <= len(wb.sheets) {
+		return wb.sheets[i-1], nil
+	}
+	return sheetRef{}, fmt.Errorf("%w %q (sheets: %s)", ErrNoSheet, sheet, strings.Join(wb.Sheets(), ", "))
+}
+
+// richText is a string item: plain text or formatted runs. Phonetic runs
+// are not part of the value.
+type richText struct {
+	Text string `xml:"t"`
+	Runs []struct {
+		Text string `xml:"t"`
+	} `xml:"r"`
+}
+
+func (t richText) String() string {
+	if len(t.Runs) == 0 {
+		return t.Text
+	}
+	var b strings.Builder
+	b.WriteString(t.Text)
+	for _, r := range t.Runs {
+		b.WriteString(r.Text)
+	}
+	return b.String()
+}
+
+// cellValue returns a cell's value as text
+func (wb *Workbook) cellValue(typ string, style int, value string, inline richText) (string, error) {
+	switch typ {
+	case "s":
+		i, err := strconv.Atoi(value)
+		if err != nil || i < 0 || i >= len(wb.strings) {
+			return "", fmt.Errorf("invalid shared string %q", value)
+		}
+		return wb.strings[i], nil
+	case "inlineStr":
+		return inline.String(), nil
+	case "b":
+		if value == "1" {
+			return "TRUE", nil
+		}
+		return "FALSE", nil
+	case "str", "e", "d":
+		// Formula strings, errors and ISO 8601 dates are stored as text
+		return value, nil
+	}
+	if value != "" && wb.dateXfs[style] {
+		if serial, err := strconv.ParseFloat(value, 64); err == nil {
+			return serialDate(serial, wb.date1904), nil
+		}
+	}
+	return value, nil
+}
+
+func (wb *Workbook) readSharedStrings() error {
+	if wb.files["xl/sharedStrings.xml"] == nil {
+		return nil
+	}
+	var sst struct {
+		Items []richText `xml:"si"`
+	}
+	if err := wb.decode("xl/sharedStrings.xml", &sst); err != nil {
+		return err
+	}
+	wb.strings = make([]string, len(sst.Items))
+	for i, item := range sst.Items {
+		wb.strings[i] = item.String()
+	}
+	return nil
+}
+
+func (wb *Workbook) readStyles() error {
+	wb.dateXfs = make(map[int]bool)
+	if wb.files["xl/styles.xml"] == nil {
+		return nil
+	}
+	var styles struct {
+		NumFmts []struct {
+			ID   int    `xml:"numFmtId,attr"`
+			Code string `xml:"formatCode,attr"`
+		} `xml:"numFmts>numFmt"`
+		CellXfs []struct {
+			NumFmtID int `xml:"numFmtId,attr"`
+		} `xml:"cellXfs>xf"`
+	}
+	if err := wb.decode("xl/styles.xml", &styles); err != nil {
+		return err
+	}
+	custom := make(map[int]bool, len(styles.NumFmts))
+	for _, f := range styles.NumFmts {
+		custom[f.ID] = isDateFormat(f.Code)
+	}
+	for i, xf := range styles.CellXfs {
+		if isDate, ok := custom[xf.NumFmtID]; ok {
+			wb.dateXfs[i] = isDate
+		} else {
+			wb.dateXfs[i] = builtinDateFormat(xf.NumFmtID)
+		}
+	}
+	return nil
+}
+
+// decode unmarshals a part of the workbook
+func (wb *Workbook) decode(name string, v interface{}) error {
+	f := wb.files[name]
+	if f == nil {
+		return fmt.Errorf("not an XLSX file: %s is missing", name)
+	}
+	rc, err := f.Open()
+	if err != nil {
+		return fmt.Errorf("open %s: %w", name, err)
+	}
+	defer rc.Close()
+	data, err := io.ReadAll(io.LimitReader(rc, maxPartBytes+1))
+	if err != nil {
+		return fmt.Errorf("read %s: %w", name, err)
+	}
+	if len(data) > maxPartBytes {
+		return fmt.Errorf("%s expands beyond %d MB", name, maxPartBytes>>20)
+	}
+	if err := xml.Unmarshal(data, v); err != nil {
+		return fmt.Errorf("parse %s: %w", name, err)
+	}
+	return nil
+}
@@ -0,0 +1,199 @@
+package xlsx
+
+import (
+	"archive/zip"
+	"bytes"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+// workbook zips parts into an XLSX file
+func workbook(t *testing.T, parts map[string]string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for name, content := range parts {
+		w, err := zw.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := w.Write([]byte(content)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// sanctionsBook has a sparse first sheet of every cell type and a second
+// sheet named "Aliases". Style 1 is a built-in date format, 2 a custom one
+// and 3 a number with a color.
+func sanctionsBook(t *testing.T, date1904 bool) []byte {
+	props := ""
+	if date1904 {
+		props = `<workbookPr date1904="1"/>`
+	}
+	return workbook(t, map[string]string{
+		"xl/workbook.xml": `<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main"
+			xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships">` + props + `
+			<sheets><sheet name="Entities" r:id="rId1"/><sheet name="Aliases" r:id="rId2"/></sheets></workbook>`,
+		"xl/_rels/workbook.xml.rels": `<Relationships>
+			<Relationship Id="rId1" Target="worksheets/sheet1.xml"/>
+			<Relationship Id="rId2" Target="/xl/worksheets/sheet2.xml"/></Relationships>`,
+		"xl/sharedStrings.xml": `<sst><si><t>name</t></si><si><t>dob</t></si><si><r><t>Ivan </t></r><r><t>Petrov</t></r></si></sst>`,
+		"xl/styles.xml": `<styleSheet>
+			<numFmts><numFmt numFmtId="164" formatCode="dd/mm/yyyy"/><numFmt numFmtId="165" formatCode="[Red]0.00"/></numFmts>
+			<cellXfs><xf numFmtId="0"/><xf numFmtId="14"/><xf numFmtId="164"/><xf numFmtId="165"/></cellXfs></styleSheet>`,
+		"xl/worksheets/sheet1.xml": `<worksheet><sheetData>
+			<row><c r="A1" t="s"><v>0</v></c><c r="B1" t="s"><v>1</v></c><c r="D1" t="inlineStr"><is><t>listed</t></is></c></row>
+			<row><c r="A2" t="s"><v>2</v></c><c r="B2" s="1"><v>29221</v></c><c r="C2" t="b"><v>1</v></c><c r="D2" s="2"><v>45292</v></c></row>
+			<row></row>
+			<row><c r="A4" t="str"><v>formula</v></c><c r="B4" s="3"><v>12.5</v></c></row>
+		</sheetData></worksheet>`,
+		"xl/worksheets/sheet2.xml": `<worksheet><sheetData><row><c t="inlineStr"><is><t>Ivan Petrov</t></is></c><c t="inlineStr"><is><t>I. Petrov</t></is></c></row></sheetData></worksheet>`,
+	})
+}
+
+func TestRows(t *testing.T) {
+	wb, err := Open(sanctionsBook(t, false))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := wb.Sheets(), []string{"Entities", "Aliases"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Sheets() = %v, want %v", got, want)
+	}
+
+	want := [][]string{
+		{"name", "dob", "", "listed"},
+		{"Ivan Petrov", "1980-01-01", "TRUE", "2024-01-01"},
+		{"formula", "12.5", "", ""},
+	}
+	for _, sheet := range []string{"", "Entities", "1"} {
+		rows, err := wb.Rows(sheet)
+		if err != nil {
+			t.Fatalf("sheet %q: %v", sheet, err)
+		}
+		if !reflect.DeepEqual(rows, want) {
+			t.Errorf("sheet %q: rows %q, want %q", sheet, rows, want)
+		}
+	}
+
+	rows, err := wb.Rows("2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := [][]string{{"Ivan Petrov", "I. Petrov"}}; !reflect.DeepEqual(rows, want) {
+		t.Errorf("sheet 2: rows %q, want %q", rows, want)
+	}
+
+	for _, sheet := range []string{"Missing", "3", "0"} {
+		if _, err := wb.Rows(sheet); !errors.Is(err, ErrNoSheet) {
+			t.Errorf("sheet %q: err = %v, want ErrNoSheet", sheet, err)
+		}
+	}
+}
+
+func TestDate1904(t *testing.T) {
+	wb, err := Open(sanctionsBook(t, true))
+	if err != nil {
+		t.Fatal(err)
+	}
+	rows, err := wb.Rows("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := rows[1][1]; got != "1984-01-02" {
+		t.Errorf("1904 date = %s, want 1984-01-02", got)
+	}
+}
+
+func TestToCSV(t *testing.T) {
+	data := sanctionsBook(t, false)
+	if !IsWorkbook("list.XLSX", data) {
+		t.Error("workbook not recognized")
+	}
+	if IsWorkbook("list.csv", data) || IsWorkbook("list.xlsx", []byte("name,dob\n")) {
+		t.Error("non-workbook recognized as one")
+	}
+
+	got, err := ToCSV(data, "Aliases")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "Ivan Petrov,I. Petrov\n"; string(got) != want {
+		t.Errorf("ToCSV = %q, want %q", got, want)
+	}
+
+	if _, err := ToCSV([]byte("PK\x03\x04 truncated"), ""); err == nil {
+		t.Error("truncated workbook accepted")
+	}
+	missing := workbook(t, map[string]string{"xl/workbook.xml": `<workbook/>`})
+	if _, err := ToCSV(missing, ""); err == nil {
+		t.Error("workbook without relationships accepted")
+	}
+}
+
+func TestInvalidCells(t *testing.T) {
+	for name, cell := range map[string]string{
+		"shared string out of range": `<c r="A1" t="s"><v>5</v></c>`,
+		"bad reference":              `<c r="1A"><v>1</v></c>`,
+		"column past XFD":            `<c r="XFE1"><v>1</v></c>`,
+	} {
+		data := workbook(t, map[string]string{
+			"xl/workbook.xml": `<workbook xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships">
+				<sheets><sheet name="S" r:id="rId1"/></sheets></workbook>`,
+			"xl/_rels/workbook.xml.rels": `<Relationships><Relationship Id="rId1" Target="worksheets/sheet1.xml"/></Relationships>`,
+			"xl/worksheets/sheet1.xml":   `<worksheet><sheetData><row>` + cell + `</row></sheetData></worksheet>`,
+		})
+		if _, err := ToCSV(data, ""); err == nil {
+			t.Errorf("%s: accepted", name)
+		}
+	}
+}
+
+func TestColumnIndex(t *testing.T) {
+	for ref, want := range map[string]int{"A1": 0, "Z9": 25, "AA10": 26, "AZ1": 51, "XFD1048576": 16383} {
+		if got, err := columnIndex(ref); err != nil || got != want {
+			t.Errorf("columnIndex(%q) = %d, %v, want %d", ref, got, err, want)
+		}
+	}
+}
+
+func TestIsDateFormat(t *testing.T) {
+	for code, want := range map[string]bool{
+		"yyyy-mm-dd":     true,
+		"[$-409]mmm d":   true,
+		"0.00":           false,
+		"[Red]0.00":      false,
+		`"day "0`:        false,
+		`\d0`:            false,
+		`_(* #,##0_)`:    false,
+		"#,##0;[Blue]-0": false,
+	} {
+		if got := isDateFormat(code); got != want {
+			t.Errorf("isDateFormat(%q) = %v, want %v", code, got, want)
+		}
+	}
+}
+
+func TestSerialDate(t *testing.T) {
+	for _, tc := range []struct {
+		serial   float64
+		date1904 bool
+		want     string
+	}{
+		{1, false, "1900-01-01"},
+		{59, false, "1900-02-28"},
+		{61, false, "1900-03-01"},
+		{45292.75, false, "2024-01-01"}, // The time of day is dropped
+		{0, true, "1904-01-01"},
+		{43830, true, "2024-01-01"},
+	} {
+		if got := serialDate(tc.serial, tc.date1904); got != tc.want {
+			t.Errorf("serialDate(%v, %v) = %s, want %s", tc.serial, tc.date1904, got, tc.want)
+		}
+	}
+}
@@ -22,13 +22,47 @@ type CSVToSQLiteConfig struct {
 	BatchSize    int      // Number of rows to insert in a single transaction
 	CreateTables bool     // Whether to create tables automatically
 	DropExisting bool     // Whether to drop existing tables before creating new ones
+	Mode         LoadMode // How rows meet an existing table; empty follows DropExisting
+	KeyColumns   []string // CSV columns identifying a row, for upsert and keyed append
+
+	// Progress, if set, is called after each committed batch. Files are
+	// converted concurrently, so it is called from several goroutines.
+	Progress func(CSVProgress)
+	// Output receives the converter's messages; nil writes them to stdout
+	Output io.Writer
 }
 
+// CSVProgress is how far the conversion of one file has got
+type CSVProgress struct {
+	FileName   string
+	Rows       int   // Rows committed so far
+	BytesRead  int64 // Of the CSV file
+	BytesTotal int64
+}
+
+// LoadMode decides what happens to rows already in a table
+type LoadMode string
+
+const (
+	// LoadReplace drops the table and loads the file into a new one
+	LoadReplace LoadMode = "replace"
+	// LoadAppend adds the file's rows to the table. With key columns, rows
+	// whose key is already present are skipped.
+	LoadAppend LoadMode = "append"
+	// LoadUpsert inserts rows with a new key and overwrites rows whose key
+	// is already present. It requires key columns.
+	LoadUpsert LoadMode = "upsert"
+)
+
 // CSVConversionResult holds the result of a CSV conversion
 type CSVConversionResult struct {
 	FileName  string
 	TableName string
 	RowCount  int
+	Inserted  int // Rows added to the table
+	Updated   int // Upserted rows that overwrote a row with other values
+	Unchanged int // Upserted rows identical to the row already stored
+	Skipped   int // Appended rows whose key was already present
 	Error     error
 }
 
@@ -51,6 +85,23 @@ func NewCSVToSQLiteConverter(config *CSVToSQLiteConfig) (*CSVToSQLiteConverter,
 	if config.OutputDBPath == "" {
 		config.OutputDBPath = "data/output.db"
 	}
+	if config.Output == nil {
+		config.Output = os.Stdout
+	}
+	switch config.Mode {
+	case "":
+		config.Mode = LoadAppend
+		if config.DropExisting {
+			config.Mode = LoadReplace
+		}
+	case LoadReplace, LoadAppend:
+	case LoadUpsert:
+		if len(config.KeyColumns) == 0 {
+			return nil, fmt.Errorf("upsert mode needs key columns")
+		}
+	default:
+		return nil, fmt.Errorf("unknown load mode %q (use replace, append or upsert)", config.Mode)
+	}
 
 	// Ensure the data directory exists
 	dbDir := filepath.Dir(config.OutputDBPath)
@@ -100,7 +151,7 @@ func (c *CSVToSQLiteConverter) Convert() ([]CSVConversionResult, error) {
 	semaphore := make(chan struct{}, c.config.MaxWorkers)
 	var wg sync.WaitGroup
 
-	fmt.Printf("Starting conversion with %d workers (CPU cores available: %d)\n",
+	fmt.Fprintf(c.config.Output, "Starting conversion with %d workers (CPU cores available: %d)\n",
 		c.config.MaxWorkers, runtime.NumCPU())
 
 	// Process each CSV file concurrently
@@ -117,10 +168,11 @@ func (c *CSVToSQLiteConverter) Convert() ([]CSVConversionResult, error) {
 			results[index] = result
 
 			if result.Error != nil {
-				fmt.Printf("❌ Failed to convert %s: %v\n", result.FileName, result.Error)
+				fmt.Fprintf(c.config.Output, "❌ Failed to convert %s: %v\n", result.FileName, result.Error)
 			} else {
-				fmt.Printf("✅ Converted %s -> %s (%d rows)\n",
-					result.FileName, result.TableName, result.RowCount)
+				fmt.Fprintf(c.config.Output, "✅ Converted %s -> %s (%d rows: %d inserted, %d updated, %d unchanged, %d skipped)\n",
+					result.FileName, result.TableName, result.RowCount,
+					result.Inserted, result.Updated, result.Unchanged, result.Skipped)
 			}
 		}(i, csvFile)
 	}
@@ -128,7 +180,7 @@ func (c *CSVToSQLiteConverter) Convert() ([]CSVConversionResult, error) {
 	// Wait for all conversions to complete
 	wg.Wait()
 
-	fmt.Println("\n🎉 Conversion completed!")
+	fmt.Fprintln(c.config.Output, "\n🎉 Conversion completed!")
 	return results, nil
 }
 
@@ -145,9 +197,20 @@ func (c *CSVToSQLiteConverter) convertFile(csvPath string) CSVConversionResult {
 		return result
 	}
 	defer file.Close()
+	info, err := file.Stat()
+	if err != nil {
+		result.Error = fmt.Errorf("failed to stat CSV file: %v", err)
+		return result
+	}
+	counted := &countingReader{r: file}
+	progress := func(rows int) {
+		if c.config.Progress != nil {
+			c.config.Progress(CSVProgress{FileName: result.FileName, Rows: rows, BytesRead: counted.n, BytesTotal: info.Size()})
+		}
+	}
 
 	// Read CSV
-	reader := csv.NewReader(file)
+	reader := csv.NewReader(counted)
 	reader.LazyQuotes = true
 	reader.TrimLeadingSpace = true
 
@@ -167,6 +230,15 @@ func (c *CSVToSQLiteConverter) convertFile(csvPath string) CSVConversionResult {
 		result.Error = err
 		return result
 	}
+	keys, err := c.keyColumns(headers)
+	if err != nil {
+		result.Error = err
+		return result
+	}
+	if err := c.createKeyIndex(tableName, keys); err != nil {
+		result.Error = err
+		return result
+	}
 
 	// Insert data in batches
 	rowCount := 0
@@ -186,23 +258,25 @@ func (c *CSVToSQLiteConverter) convertFile(csvPath string) CSVConversionResult {
 
 		// Insert batch when it reaches the configured size
 		if len(batch) >= c.config.BatchSize {
-			if err := c.insertBatch(tableName, headers, batch); err != nil {
+			if err := c.insertBatch(tableName, headers, keys, batch, &result); err != nil {
 				result.Error = err
 				return result
 			}
 			rowCount += len(batch)
 			batch = [][]string{}
+			progress(rowCount)
 		}
 	}
 
 	// Insert remaining records
 	if len(batch) > 0 {
-		if err := c.insertBatch(tableName, headers, batch); err != nil {
+		if err := c.insertBatch(tableName, headers, keys, batch, &result); err != nil {
 			result.Error = err
 			return result
 		}
 		rowCount += len(batch)
 	}
+	progress(rowCount)
 
 	result.RowCount = rowCount
 	return result
@@ -218,7 +292,7 @@ func (c *CSVToSQLiteConverter) createTable(tableName string, columns []string) e
 	}
 
 	// Drop table if requested
-	if c.config.DropExisting {
+	if c.config.Mode == LoadReplace {
 		dropSQL := fmt.Sprintf("DROP TABLE IF EXISTS %s", tableName)
 		if _, err := c.db.Exec(dropSQL); err != nil {
 			return fmt.Errorf("failed to drop table: %v", err)
@@ -241,8 +315,54 @@ func (c *CSVToSQLiteConverter) createTable(tableName string, columns []string) e
 	return nil
 }
 
-// insertBatch inserts a batch of rows into the database
-func (c *CSVToSQLiteConverter) insertBatch(tableName string, headers []string, batch [][]string) error {
+// keyColumns returns the positions in headers of the configured key
+// columns, or nil when rows are not keyed
+func (c *CSVToSQLiteConverter) keyColumns(headers []string) ([]int, error) {
+	if c.config.Mode == LoadReplace || len(c.config.KeyColumns) == 0 {
+		return nil, nil
+	}
+	keys := make([]int, 0, len(c.config.KeyColumns))
+	for _, key := range c.config.KeyColumns {
+		pos := -1
+		for i, h := range headers {
+			if sanitizeColumnName(h) == sanitizeColumnName(key) {
+				pos = i
+				break
+			}
+		}
+		if pos < 0 {
+			return nil, fmt.Errorf("key column %q is not in the CSV header", key)
+		}
+		keys = append(keys, pos)
+	}
+	return keys, nil
+}
+
+// createKeyIndex adds the unique index that keyed loads detect conflicts
+// with. It fails if rows already in the table share a key.
+func (c *CSVToSQLiteConverter) createKeyIndex(tableName string, keys []int) error {
+	if len(keys) == 0 {
+		return nil
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	columns := make([]string, len(c.config.KeyColumns))
+	for i, key := range c.config.KeyColumns {
+		columns[i] = sanitizeColumnName(key)
+	}
+	indexName := sanitizeTableName(tableName + "_key_" + strings.Join(columns, "_"))
+	createSQL := fmt.Sprintf("CREATE UNIQUE INDEX IF NOT EXISTS %s ON %s (%s)",
+		indexName, tableName, strings.Join(columns, ", "))
+	if _, err := c.db.Exec(createSQL); err != nil {
+		return fmt.Errorf("failed to create key index: %v", err)
+	}
+	return nil
+}
+
+// insertBatch inserts a batch of rows into the database and adds what
+// happened to each row to result once the batch is committed
+func (c *CSVToSQLiteConverter) insertBatch(tableName string, headers []string, keys []int, batch [][]string, result *CSVConversionResult) error {
 	if len(batch) == 0 {
 		return nil
 	}
@@ -269,6 +389,13 @@ func (c *CSVToSQLiteConverter) insertBatch(tableName string, headers []string, b
 		tableName,
 		strings.Join(sanitizedHeaders, ", "),
 		strings.Join(placeholders, ", "))
+	keyed := len(keys) > 0
+	switch {
+	case keyed && c.config.Mode == LoadUpsert:
+		insertSQL += upsertClause(sanitizedHeaders, keys)
+	case keyed:
+		insertSQL = strings.Replace(insertSQL, "INSERT INTO", "INSERT OR IGNORE INTO", 1)
+	}
 
 	stmt, err := tx.Prepare(insertSQL)
 	if err != nil {
@@ -276,7 +403,24 @@ func (c *CSVToSQLiteConverter) insertBatch(tableName string, headers []string, b
 	}
 	defer stmt.Close()
 
+	// An upsert only reports whether it wrote a row, so whether the key was
+	// already stored is looked up first
+	var exists *sql.Stmt
+	if keyed && c.config.Mode == LoadUpsert {
+		conditions := make([]string, len(keys))
+		for i, k := range keys {
+			conditions[i] = sanitizedHeaders[k] + " = ?"
+		}
+		exists, err = tx.Prepare(fmt.Sprintf("SELECT EXISTS (SELECT 1 FROM %s WHERE %s)",
+			tableName, strings.Join(conditions, " AND ")))
+		if err != nil {
+			return fmt.Errorf("failed to prepare key lookup: %v", err)
+		}
+		defer exists.Close()
+	}
+
 	// Insert all rows in the batch
+	var counts CSVConversionResult
 	for _, record := range batch {
 		// Convert []string to []interface{}
 		values := make([]interface{}, len(record))
@@ -284,9 +428,35 @@ func (c *CSVToSQLiteConverter) insertBatch(tableName string, headers []string, b
 			values[i] = v
 		}
 
-		if _, err := stmt.Exec(values...); err != nil {
+		stored := false
+		if exists != nil {
+			keyValues := make([]interface{}, len(keys))
+			for i, k := range keys {
+				keyValues[i] = values[k]
+			}
+			if err := exists.QueryRow(keyValues...).Scan(&stored); err != nil {
+				return fmt.Errorf("failed to look up record key: %v", err)
+			}
+		}
+
+		res, err := stmt.Exec(values...)
+		if err != nil {
 			return fmt.Errorf("failed to insert record: %v", err)
 		}
+		written, err := res.RowsAffected()
+		if err != nil {
+			return fmt.Errorf("failed to insert record: %v", err)
+		}
+		switch {
+		case stored && written > 0:
+			counts.Updated++
+		case stored:
+			counts.Unchanged++
+		case written > 0:
+			counts.Inserted++
+		default:
+			counts.Skipped++
+		}
 	}
 
 	// Commit transaction
@@ -294,9 +464,50 @@ func (c *CSVToSQLiteConverter) insertBatch(tableName string, headers []string, b
 		return fmt.Errorf("failed to commit transaction: %v", err)
 	}
 
+	result.Inserted += counts.Inserted
+	result.Updated += counts.Updated
+	result.Unchanged += counts.Unchanged
+	result.Skipped += counts.Skipped
 	return nil
 }
 
+// upsertClause overwrites the non-key columns of a row whose key is already
+// stored, leaving it untouched when no value differs
+func upsertClause(columns []string, keys []int) string {
+	isKey := make(map[int]bool, len(keys))
+	keyColumns := make([]string, len(keys))
+	for i, k := range keys {
+		isKey[k] = true
+		keyColumns[i] = columns[k]
+	}
+
+	var sets, changed []string
+	for i, col := range columns {
+		if isKey[i] {
+			continue
+		}
+		sets = append(sets, fmt.Sprintf("%s = excluded.%s", col, col))
+		changed = append(changed, fmt.Sprintf("%s IS NOT excluded.%s", col, col))
+	}
+	if len(sets) == 0 {
+		return fmt.Sprintf(" ON CONFLICT (%s) DO NOTHING", strings.Join(keyColumns, ", "))
+	}
+	return fmt.Sprintf(" ON CONFLICT (%s) DO UPDATE SET %s WHERE %s",
+		strings.Join(keyColumns, ", "), strings.Join(sets, ", "), strings.Join(changed, " OR "))
+}
+
+// countingReader counts the bytes read through it
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (cr *countingReader) Read(p []byte) (int, error) {
+	n, err := cr.r.Read(p)
+	cr.n += int64(n)
+	return n, err
+}
+
 // sanitizeTableName cleans table name for SQLite
 func sanitizeTableName(name string) string {
 	// Replace invalid characters with underscores
@@ -0,0 +1,19 @@
+package utils
+
+import (
+	"time"
+
+	"github.com/SanthoshCheemala/FLARE/backend/internal/metrics"
+)
+
+// LatencyStats and HistogramBucket are the latency summary of package
+// metrics, shared with backend screenings
+type (
+	LatencyStats    = metrics.LatencyStats
+	HistogramBucket = metrics.HistogramBucket
+)
+
+// ComputeLatencyStats summarizes samples. Negative samples count as zero.
+func ComputeLatencyStats(samples []time.Duration) LatencyStats {
+	return metrics.ComputeLatencyStats(samples)
+}
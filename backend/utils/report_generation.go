@@ -1,13 +1,18 @@
 package utils
 
 import (
+	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"html/template"
 	"math"
 	"os"
+	"path"
 	"path/filepath"
 	"time"
+
+	"github.com/SanthoshCheemala/FLARE/backend/internal/metrics"
 )
 
 // Enhanced data structures for comprehensive statistics
@@ -19,6 +24,9 @@ type PSIStatistics struct {
 	TimingAnalysis  TimingAnalysisStats `json:"timingAnalysis"`
 	DetailedMetrics []DetailedMetric    `json:"detailedMetrics"`
 	Metadata        MetadataStats       `json:"metadata"`
+	// Metrics is the run's instrumentation in the schema backend
+	// screenings report too
+	Metrics metrics.Report `json:"metrics"`
 }
 
 type SummaryStats struct {
@@ -42,6 +50,41 @@ type LEParameterStats struct {
 	SecurityLevel   string  `json:"securityLevel"`
 	MemoryUsage     int64   `json:"memoryUsage"`
 	OptimalityScore float64 `json:"optimalityScore"`
+	// CorrectnessThreshold is the match percentage at or above which the
+	// report counts an operation as correct
+	CorrectnessThreshold float64 `json:"correctnessThreshold"`
+}
+
+// Correctness thresholds a run's LE parameters may set as
+// "CorrectnessThreshold"; runs without one use the default
+const (
+	DefaultCorrectnessThreshold = 0.95
+	MinCorrectnessThreshold     = 0.8
+	MaxCorrectnessThreshold     = 1.0
+)
+
+// ValidateCorrectnessThreshold checks that t is within
+// [MinCorrectnessThreshold, MaxCorrectnessThreshold]. Below the minimum the
+// threshold would overlap the report's warning band.
+func ValidateCorrectnessThreshold(t float64) error {
+	if math.IsNaN(t) || t < MinCorrectnessThreshold || t > MaxCorrectnessThreshold {
+		return fmt.Errorf("correctness threshold %v is outside [%v, %v]", t, MinCorrectnessThreshold, MaxCorrectnessThreshold)
+	}
+	return nil
+}
+
+// correctnessThreshold returns the run's threshold, or the default when it
+// sets none or an invalid one
+func correctnessThreshold(leAnalysis map[string]interface{}) float64 {
+	t, ok := leAnalysis["CorrectnessThreshold"].(float64)
+	if !ok {
+		return DefaultCorrectnessThreshold
+	}
+	if err := ValidateCorrectnessThreshold(t); err != nil {
+		fmt.Printf("Warning: %v; using %v\n", err, DefaultCorrectnessThreshold)
+		return DefaultCorrectnessThreshold
+	}
+	return t
 }
 
 type NoiseAnalysisStats struct {
@@ -71,6 +114,8 @@ type TimingAnalysisStats struct {
 	PerformanceScore   float64            `json:"performanceScore"`
 	BottleneckAnalysis BottleneckAnalysis `json:"bottleneckAnalysis"`
 	Benchmarks         []BenchmarkPoint   `json:"benchmarks"`
+	// Latency summarizes the per-operation durations the caller measured
+	Latency LatencyStats `json:"latency"`
 }
 
 type DetailedMetric struct {
@@ -189,6 +234,50 @@ func WriteEnhancedPSIReport(
 	}
 }
 
+// BuildPSIStatistics computes the statistics of a PSI run, for
+// PublishPSIReport
+func BuildPSIStatistics(
+	noiseStats []map[string]interface{},
+	errorStats []map[string]interface{},
+	totalMatches int,
+	totalMaxNoise, totalAvgNoise float64,
+	totalErrors int,
+	duration, encDuration, serverEncDuration, decDuration time.Duration,
+	leAnalysis map[string]interface{},
+) PSIStatistics {
+	return generateComprehensiveStats(
+		noiseStats, errorStats, totalMatches, totalMaxNoise, totalAvgNoise,
+		totalErrors, duration, encDuration, serverEncDuration, decDuration, leAnalysis,
+	)
+}
+
+// PublishPSIReport stores the JSON statistics and HTML report of a run in
+// sink, under names from naming, and returns the names written. The HTML
+// report loads the statistics from its sibling JSON file.
+func PublishPSIReport(ctx context.Context, sink ReportSink, naming ReportNaming, stats PSIStatistics) ([]string, error) {
+	name, err := naming.Name()
+	if err != nil {
+		return nil, err
+	}
+	jsonName, htmlName := name+".json", name+".html"
+
+	statsJSON, err := renderStatsJSON(stats)
+	if err != nil {
+		return nil, err
+	}
+	html, err := renderEnhancedHTML(path.Base(jsonName))
+	if err != nil {
+		return nil, err
+	}
+	if err := sink.Put(ctx, jsonName, "application/json", statsJSON); err != nil {
+		return nil, fmt.Errorf("failed to store %s: %v", jsonName, err)
+	}
+	if err := sink.Put(ctx, htmlName, "text/html; charset=utf-8", html); err != nil {
+		return []string{jsonName}, fmt.Errorf("failed to store %s: %v", htmlName, err)
+	}
+	return []string{jsonName, htmlName}, nil
+}
+
 func generateComprehensiveStats(
 	noiseStats []map[string]interface{},
 	errorStats []map[string]interface{},
@@ -252,6 +341,8 @@ func generateComprehensiveStats(
 		}
 	}
 
+	threshold := correctnessThreshold(leAnalysis)
+	run := runMetrics(noiseStats, errorStats, duration, encDuration, serverEncDuration, decDuration)
 	stats := PSIStatistics{
 		Summary: SummaryStats{
 			TotalOperations:    len(noiseStats),
@@ -273,11 +364,13 @@ func generateComprehensiveStats(
 			SecurityLevel:   determineSecurityLevel(d, q),
 			MemoryUsage:     estimateMemoryUsage(leAnalysis),
 			OptimalityScore: calculateOptimalityScore(leAnalysis),
+
+			CorrectnessThreshold: threshold,
 		},
 		NoiseAnalysis:   generateNoiseAnalysis(noiseStats, totalMaxNoise, totalAvgNoise),
-		ErrorAnalysis:   generateErrorAnalysis(errorStats, totalErrors),
-		TimingAnalysis:  generateTimingAnalysis(duration, encDuration, serverEncDuration, decDuration, totalMatches),
-		DetailedMetrics: generateDetailedMetrics(noiseStats, errorStats),
+		ErrorAnalysis:   generateErrorAnalysis(errorStats, totalErrors, threshold),
+		TimingAnalysis:  generateTimingAnalysis(duration, encDuration, serverEncDuration, decDuration, totalMatches, run.Latency),
+		DetailedMetrics: generateDetailedMetrics(noiseStats, errorStats, threshold),
 		Metadata: MetadataStats{
 			Timestamp:        time.Now().Format("2006-01-02 15:04:05"),
 			Version:          "FLARE v2.0",
@@ -286,47 +379,60 @@ func generateComprehensiveStats(
 			DatasetSize:      len(noiseStats),
 			AlgorithmVariant: "Laconic PSI with Lattice Encryption",
 		},
+		Metrics: run,
 	}
 
 	return stats
 }
 
 func saveStatsToJSON(filepath string, stats PSIStatistics) error {
-	file, err := os.Create(filepath)
+	data, err := renderStatsJSON(stats)
 	if err != nil {
 		return err
 	}
-	defer file.Close()
+	return os.WriteFile(filepath, data, 0644)
+}
 
-	encoder := json.NewEncoder(file)
+func renderStatsJSON(stats PSIStatistics) ([]byte, error) {
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
 	encoder.SetIndent("", "  ")
-	return encoder.Encode(stats)
+	if err := encoder.Encode(stats); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
 }
 
 func generateEnhancedHTML(htmlPath, jsonPath string) error {
-	htmlContent := getSimpleHTMLTemplate()
-
 	// Get relative path for JSON file
-	jsonFileName := filepath.Base(jsonPath)
+	html, err := renderEnhancedHTML(filepath.Base(jsonPath))
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(htmlPath, html, 0644)
+}
+
+// renderEnhancedHTML renders the report page, which fetches its statistics
+// from jsonName relative to itself
+func renderEnhancedHTML(jsonName string) ([]byte, error) {
+	htmlContent := getSimpleHTMLTemplate()
 
 	data := struct {
 		JSONPath string
 	}{
-		JSONPath: jsonFileName, // Use just the filename for relative path
+		JSONPath: jsonName,
 	}
 
 	tmpl, err := template.New("report").Parse(htmlContent)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	file, err := os.Create(htmlPath)
-	if err != nil {
-		return err
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return nil, err
 	}
-	defer file.Close()
-
-	return tmpl.Execute(file, data)
+	return buf.Bytes(), nil
 }
 
 func getSimpleHTMLTemplate() string {
@@ -667,11 +773,25 @@ func getSimpleHTMLTemplate() string {
                         '<span class="info-label">Throughput</span>' +
                         '<span class="info-value">' + timing.throughput.toFixed(1) + ' ops/sec</span>' +
                     '</div>' +
+                    latencyRows(timing.latency) +
                 '</div>';
             
             document.getElementById('performanceResults').innerHTML = html;
         }
 
+        function latencyRows(latency) {
+            if (!latency || !latency.count) {
+                return '';
+            }
+            return [['Median Latency', latency.median], ['P95 Latency', latency.p95], ['P99 Latency', latency.p99]]
+                .map(row =>
+                    '<div class="info-row">' +
+                        '<span class="info-label">' + row[0] + '</span>' +
+                        '<span class="info-value">' + (row[1] / 1000000).toFixed(2) + ' ms</span>' +
+                    '</div>'
+                ).join('');
+        }
+
         function renderSystemConfig() {
             const params = data.leParameters;
             
@@ -708,6 +828,10 @@ func getSimpleHTMLTemplate() string {
                         '<span class="info-label">Collision Probability</span>' +
                         '<span class="info-value">' + params.collisionProb.toExponential(2) + '</span>' +
                     '</div>' +
+                    '<div class="info-row">' +
+                        '<span class="info-label">Correctness Threshold</span>' +
+                        '<span class="info-value">' + (params.correctnessThreshold * 100).toFixed(1) + '%</span>' +
+                    '</div>' +
                     '<div class="info-row">' +
                         '<span class="info-label">Max Noise Level</span>' +
                         '<span class="info-value">' + (noise.globalMaxNoise * 100).toFixed(3) + '%</span>' +
@@ -820,7 +944,7 @@ func generateNoiseAnalysis(noiseStats []map[string]interface{}, totalMaxNoise, t
 	}
 }
 
-func generateErrorAnalysis(errorStats []map[string]interface{}, totalErrors int) ErrorAnalysisStats {
+func generateErrorAnalysis(errorStats []map[string]interface{}, totalErrors int, threshold float64) ErrorAnalysisStats {
 	errorDist := make(map[string]int)
 	criticalErrors := make([]CriticalError, 0)
 	errorTrends := make([]ErrorTrendPoint, 0)
@@ -860,7 +984,7 @@ func generateErrorAnalysis(errorStats []map[string]interface{}, totalErrors int)
 			})
 		} else if matchPct < 0.8 {
 			errorDist["Warning"]++
-		} else if matchPct < 0.95 {
+		} else if matchPct < threshold {
 			errorDist["Minor"]++
 		} else {
 			errorDist["Normal"]++
@@ -891,7 +1015,7 @@ func generateErrorAnalysis(errorStats []map[string]interface{}, totalErrors int)
 	}
 }
 
-func generateTimingAnalysis(duration, encDuration, serverEncDuration, decDuration time.Duration, totalMatches int) TimingAnalysisStats {
+func generateTimingAnalysis(duration, encDuration, serverEncDuration, decDuration time.Duration, totalMatches int, latency LatencyStats) TimingAnalysisStats {
 	throughput := float64(totalMatches) / duration.Seconds()
 
 	// Analyze bottlenecks
@@ -939,10 +1063,52 @@ func generateTimingAnalysis(duration, encDuration, serverEncDuration, decDuratio
 			Recommendations:   recommendations,
 		},
 		Benchmarks: benchmarks,
+		Latency:    latency,
 	}
 }
 
-func generateDetailedMetrics(noiseStats, errorStats []map[string]interface{}) []DetailedMetric {
+// runMetrics records a finished run the way backend screenings record
+// theirs: one operation per noise stats entry, with its noise and duration
+func runMetrics(noiseStats, errorStats []map[string]interface{}, duration, encDuration, serverEncDuration, decDuration time.Duration) metrics.Report {
+	rec := metrics.NewRecorder()
+	rec.SetElapsed(duration)
+	rec.RecordPhase("encryption", encDuration)
+	rec.RecordPhase("serverEncryption", serverEncDuration)
+	rec.RecordPhase("decryption", decDuration)
+	rec.AddOperations(len(noiseStats))
+	for _, stat := range noiseStats {
+		maxNoise, _ := stat["MaxNoise"].(float64)
+		avgNoise, _ := stat["AvgNoise"].(float64)
+		rec.ObserveNoise(maxNoise, avgNoise)
+	}
+	for _, d := range operationDurations(noiseStats, errorStats) {
+		rec.ObserveLatency(d)
+	}
+	return rec.Report()
+}
+
+// operationDurations collects the "Duration" of each operation, taken from
+// its error stats or else its noise stats. Operations without one are left
+// out rather than estimated.
+func operationDurations(noiseStats, errorStats []map[string]interface{}) []time.Duration {
+	var durations []time.Duration
+	for i := 0; i < max(len(noiseStats), len(errorStats)); i++ {
+		if i < len(errorStats) {
+			if d, ok := errorStats[i]["Duration"].(time.Duration); ok {
+				durations = append(durations, d)
+				continue
+			}
+		}
+		if i < len(noiseStats) {
+			if d, ok := noiseStats[i]["Duration"].(time.Duration); ok {
+				durations = append(durations, d)
+			}
+		}
+	}
+	return durations
+}
+
+func generateDetailedMetrics(noiseStats, errorStats []map[string]interface{}, threshold float64) []DetailedMetric {
 	minLen := len(noiseStats)
 	if len(errorStats) < minLen {
 		minLen = len(errorStats)
@@ -957,6 +1123,7 @@ func generateDetailedMetrics(noiseStats, errorStats []map[string]interface{}) []
 		var maxNoise, avgNoise, matchPct float64
 		var serverIdx, clientIdx, matches, mismatches int
 		var noiseDist map[string]int
+		var duration time.Duration
 
 		// Safe type assertions
 		if val, ok := noiseStat["MaxNoise"]; ok {
@@ -1009,6 +1176,16 @@ func generateDetailedMetrics(noiseStats, errorStats []map[string]interface{}) []
 			}
 		}
 
+		if val, ok := errorStat["Duration"]; ok {
+			if durationVal, ok := val.(time.Duration); ok {
+				duration = durationVal
+			}
+		} else if val, ok := noiseStat["Duration"]; ok {
+			if durationVal, ok := val.(time.Duration); ok {
+				duration = durationVal
+			}
+		}
+
 		qualityScore := calculateQualityScore(maxNoise, avgNoise, matchPct)
 		risk := determineRiskLevel(qualityScore)
 
@@ -1025,10 +1202,10 @@ func generateDetailedMetrics(noiseStats, errorStats []map[string]interface{}) []
 				Matches:      matches,
 				Mismatches:   mismatches,
 				MatchPct:     matchPct,
-				ErrorPattern: determineErrorPattern(matchPct),
+				ErrorPattern: determineErrorPattern(matchPct, threshold),
 			},
 			TimingMetrics: TimingMetric{
-				Duration:     time.Duration(float64(time.Millisecond) * (1.0 + float64(i)*0.1)),
+				Duration:     duration,
 				Efficiency:   calculateEfficiency(matchPct),
 				Optimization: suggestOptimization(maxNoise, matchPct),
 			},
@@ -1166,8 +1343,8 @@ func calculateStability(maxNoise, avgNoise float64) float64 {
 	return math.Max(0, 100.0-variance*50.0)
 }
 
-func determineErrorPattern(matchPct float64) string {
-	if matchPct >= 0.95 {
+func determineErrorPattern(matchPct, threshold float64) string {
+	if matchPct >= threshold {
 		return "Excellent"
 	} else if matchPct >= 0.8 {
 		return "Good"
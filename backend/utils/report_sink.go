@@ -0,0 +1,290 @@
+package utils
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// ReportSink stores a finished report file under a name
+type ReportSink interface {
+	Put(ctx context.Context, name, contentType string, data []byte) error
+}
+
+// FileSink writes reports into a local directory
+type FileSink struct {
+	Dir string
+}
+
+// Put writes data to Dir/name, creating directories as needed
+func (s FileSink) Put(ctx context.Context, name, contentType string, data []byte) error {
+	target := filepath.Join(s.Dir, filepath.FromSlash(name))
+	if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+		return fmt.Errorf("failed to create report directory: %v", err)
+	}
+	return os.WriteFile(target, data, 0644)
+}
+
+// HTTPSink POSTs each report to URL, naming it in the X-Report-Name header,
+// for dashboards that collect runs
+type HTTPSink struct {
+	URL    string
+	Header http.Header  // Added to every request, e.g. Authorization
+	Client *http.Client // nil uses a client with a 30 second timeout
+}
+
+// Put posts data to the sink URL
+func (s HTTPSink) Put(ctx context.Context, name, contentType string, data []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.URL, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	for k, v := range s.Header {
+		req.Header[k] = v
+	}
+	req.Header.Set("Content-Type", contentType)
+	req.Header.Set("X-Report-Name", name)
+	return doReportRequest(s.Client, req)
+}
+
+// S3Sink uploads reports to an S3 (or S3-compatible) bucket with a SigV4
+// signed PutObject
+type S3Sink struct {
+	Bucket string
+	Prefix string // Key prefix, e.g. "flare/reports/"
+	Region string
+	// Endpoint is the service host, e.g. "minio.internal:9000"; empty uses
+	// s3.<Region>.amazonaws.com. Objects are addressed path-style.
+	Endpoint string
+	Insecure bool // Plain HTTP, for local S3-compatible stores
+
+	AccessKeyID     string
+	SecretAccessKey string
+	SessionToken    string
+
+	Client *http.Client // nil uses a client with a 30 second timeout
+}
+
+// NewS3SinkFromEnv returns a sink for bucket whose region and credentials
+// come from the standard AWS environment variables
+func NewS3SinkFromEnv(bucket, prefix string) S3Sink {
+	region := os.Getenv("AWS_REGION")
+	if region == "" {
+		region = os.Getenv("AWS_DEFAULT_REGION")
+	}
+	if region == "" {
+		region = "us-east-1"
+	}
+	return S3Sink{
+		Bucket:          bucket,
+		Prefix:          prefix,
+		Region:          region,
+		Endpoint:        os.Getenv("AWS_ENDPOINT_URL_S3"),
+		AccessKeyID:     os.Getenv("AWS_ACCESS_KEY_ID"),
+		SecretAccessKey: os.Getenv("AWS_SECRET_ACCESS_KEY"),
+		SessionToken:    os.Getenv("AWS_SESSION_TOKEN"),
+	}
+}
+
+// Put uploads data as Prefix+name
+func (s S3Sink) Put(ctx context.Context, name, contentType string, data []byte) error {
+	if s.AccessKeyID == "" || s.SecretAccessKey == "" {
+		return fmt.Errorf("S3 report sink has no credentials")
+	}
+	host := s.Endpoint
+	if host == "" {
+		host = fmt.Sprintf("s3.%s.amazonaws.com", s.Region)
+	}
+	scheme := "https"
+	if s.Insecure {
+		scheme = "http"
+	}
+	if u, err := url.Parse(host); err == nil && u.Host != "" {
+		// A full URL, as AWS_ENDPOINT_URL_S3 usually is
+		scheme, host = u.Scheme, u.Host
+	}
+	objectPath := "/" + s.Bucket + "/" + s.Prefix + name
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, scheme+"://"+host+awsURIEncode(objectPath), bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", contentType)
+	s.sign(req, host, objectPath, data, time.Now().UTC())
+	return doReportRequest(s.Client, req)
+}
+
+// sign adds an AWS Signature Version 4 Authorization header to req
+func (s S3Sink) sign(req *http.Request, host, objectPath string, payload []byte, now time.Time) {
+	amzDate := now.Format("20060102T150405Z")
+	date := now.Format("20060102")
+	payloadHash := sha256Hex(payload)
+
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+	headers := []string{"content-type", "host", "x-amz-content-sha256", "x-amz-date"}
+	values := map[string]string{
+		"content-type":         req.Header.Get("Content-Type"),
+		"host":                 host,
+		"x-amz-content-sha256": payloadHash,
+		"x-amz-date":           amzDate,
+	}
+	if s.SessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", s.SessionToken)
+		headers = append(headers, "x-amz-security-token")
+		values["x-amz-security-token"] = s.SessionToken
+	}
+
+	var canonicalHeaders strings.Builder
+	for _, h := range headers {
+		canonicalHeaders.WriteString(h + ":" + strings.TrimSpace(values[h]) + "\n")
+	}
+	signedHeaders := strings.Join(headers, ";")
+	canonicalRequest := strings.Join([]string{
+		req.Method, awsURIEncode(objectPath), "", canonicalHeaders.String(), signedHeaders, payloadHash,
+	}, "\n")
+
+	scope := date + "/" + s.Region + "/s3/aws4_request"
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + sha256Hex([]byte(canonicalRequest))
+
+	key := hmacSHA256([]byte("AWS4"+s.SecretAccessKey), date)
+	for _, part := range []string{s.Region, "s3", "aws4_request"} {
+		key = hmacSHA256(key, part)
+	}
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		s.AccessKeyID, scope, signedHeaders, signature))
+}
+
+// awsURIEncode percent-encodes a path as SigV4 requires: every byte but
+// unreserved characters and '/'
+func awsURIEncode(p string) string {
+	var b strings.Builder
+	for i := 0; i < len(p); i++ {
+		c := p[i]
+		if (c >= 'A' && c <= 'Z') || (c >= 'a' && c <= 'z') || (c >= '0' && c <= '9') ||
+			c == '-' || c == '_' || c == '.' || c == '~' || c == '/' {
+			b.WriteByte(c)
+		} else {
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
+
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+// doReportRequest sends req and fails on any non-2xx status
+func doReportRequest(client *http.Client, req *http.Request) error {
+	if client == nil {
+		client = &http.Client{Timeout: 30 * time.Second}
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("report upload failed: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("report upload failed: status %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+	return nil
+}
+
+// ParseReportSink returns the sink a destination names: an s3://bucket/prefix
+// URL (credentials from the AWS environment), an http(s) URL to POST to, or
+// a local directory, optionally as a file:// URL
+func ParseReportSink(dest string) (ReportSink, error) {
+	u, err := url.Parse(dest)
+	if err != nil || u.Scheme == "" || len(u.Scheme) == 1 {
+		// A plain path; one-letter schemes are Windows drive letters
+		return FileSink{Dir: dest}, nil
+	}
+	switch u.Scheme {
+	case "file":
+		return FileSink{Dir: u.Path}, nil
+	case "http", "https":
+		return HTTPSink{URL: dest}, nil
+	case "s3":
+		if u.Host == "" {
+			return nil, fmt.Errorf("report destination %q names no bucket", dest)
+		}
+		prefix := strings.TrimPrefix(u.Path, "/")
+		if prefix != "" && !strings.HasSuffix(prefix, "/") {
+			prefix += "/"
+		}
+		return NewS3SinkFromEnv(u.Host, prefix), nil
+	default:
+		return nil, fmt.Errorf("unsupported report destination %q (use a directory, file://, http(s):// or s3://)", dest)
+	}
+}
+
+// DefaultReportName names reports by run time, so repeated runs do not
+// overwrite each other
+const DefaultReportName = "psi_report_{{.Timestamp}}{{if .JobID}}_{{.JobID}}{{end}}"
+
+// ReportNaming names the files of one report run. Template is a
+// text/template over ReportNameData; the extension is added per file.
+type ReportNaming struct {
+	Template string    // Empty uses DefaultReportName
+	JobID    string    // Screening job or run the report belongs to
+	Time     time.Time // Run time; zero uses the current time
+}
+
+// ReportNameData is what a report name template can refer to
+type ReportNameData struct {
+	Timestamp string // UTC, e.g. 20240131T150405Z
+	Date      string // UTC, e.g. 2024-01-31
+	Unix      int64
+	JobID     string
+}
+
+// Name returns the base name shared by a run's report files
+func (n ReportNaming) Name() (string, error) {
+	text := n.Template
+	if text == "" {
+		text = DefaultReportName
+	}
+	tmpl, err := template.New("report name").Option("missingkey=error").Parse(text)
+	if err != nil {
+		return "", fmt.Errorf("invalid report name template: %v", err)
+	}
+	t := n.Time
+	if t.IsZero() {
+		t = time.Now()
+	}
+	t = t.UTC()
+
+	var b strings.Builder
+	data := ReportNameData{Timestamp: t.Format("20060102T150405Z"), Date: t.Format("2006-01-02"), Unix: t.Unix(), JobID: n.JobID}
+	if err := tmpl.Execute(&b, data); err != nil {
+		return "", fmt.Errorf("invalid report name template: %v", err)
+	}
+	name := path.Clean(strings.TrimSpace(b.String()))
+	if name == "." || name == "" || strings.HasPrefix(name, "..") || strings.HasPrefix(name, "/") {
+		return "", fmt.Errorf("report name template gives an unusable name %q", b.String())
+	}
+	return name, nil
+}
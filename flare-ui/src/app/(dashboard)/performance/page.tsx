@@ -6,21 +6,17 @@ import { Activity, Clock, Cpu, HardDrive, Zap, RefreshCw } from "lucide-react";
 import { Button } from "@/components/ui/button";
 import { apiClient } from "@/lib/api-client";
 
+interface PhaseMetric {
+  name: string;
+  time_seconds: number;
+  time_formatted: string;
+  percent: number;
+}
+
 interface PerformanceMetrics {
   total_time_seconds: number;
   total_time_formatted: string;
-  key_gen_time_seconds: number;
-  key_gen_time_formatted: string;
-  key_gen_percent: number;
-  hashing_time_seconds: number;
-  hashing_time_formatted: string;
-  hashing_percent: number;
-  witness_time_seconds: number;
-  witness_time_formatted: string;
-  witness_percent: number;
-  intersection_time_seconds: number;
-  intersection_time_formatted: string;
-  intersection_percent: number;
+  phases: PhaseMetric[];
   num_workers: number;
   total_operations: number;
   throughput_ops_per_sec: number;
@@ -84,12 +80,19 @@ export default function PerformancePage() {
     return <div className="p-8">No performance data available yet. Run a screening to generate metrics.</div>;
   }
 
-  const phases = [
-    { name: "Key Generation", percent: perfMetrics.key_gen_percent, color: "from-blue-500 to-blue-600", time: perfMetrics.key_gen_time_formatted },
-    { name: "Hashing", percent: perfMetrics.hashing_percent, color: "from-purple-500 to-purple-600", time: perfMetrics.hashing_time_formatted },
-    { name: "Witness Generation", percent: perfMetrics.witness_percent, color: "from-green-500 to-green-600", time: perfMetrics.witness_time_formatted },
-    { name: "Intersection", percent: perfMetrics.intersection_percent, color: "from-amber-500 to-amber-600", time: perfMetrics.intersection_time_formatted },
+  const phaseColors = [
+    "from-blue-500 to-blue-600",
+    "from-purple-500 to-purple-600",
+    "from-green-500 to-green-600",
+    "from-amber-500 to-amber-600",
+    "from-rose-500 to-rose-600",
   ];
+  const phases = (perfMetrics.phases ?? []).map((phase, i) => ({
+    name: phase.name,
+    percent: phase.percent,
+    color: phaseColors[i % phaseColors.length],
+    time: phase.time_formatted,
+  }));
 
   return (
     <div className="space-y-8">
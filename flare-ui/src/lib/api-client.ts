@@ -66,6 +66,27 @@ export interface SanctionList {
   createdAt: string;
 }
 
+// Error envelope returned by the backend
+export interface ApiErrorBody {
+  code: string;
+  message: string;
+  details?: unknown;
+  requestId?: string;
+}
+
+export class ApiError extends Error {
+  constructor(
+    public status: number,
+    public code: string,
+    message: string,
+    public details?: unknown,
+    public requestId?: string
+  ) {
+    super(message);
+    this.name = "ApiError";
+  }
+}
+
 export interface DashboardStats {
   totalScreenings: number;
   totalMatches: number;
@@ -81,6 +102,18 @@ export interface DashboardStats {
   }>;
   systemStatus: string;
   activeWorkers: number;
+  trend: {
+    windowDays: number;
+    interval: "day" | "week";
+    points: Array<{
+      period: string;
+      screenings: number;
+      matches: number;
+      customers: number;
+      matchRate: number;
+      avgDurationMs: number;
+    }>;
+  };
 }
 
 // Token management
@@ -141,7 +174,19 @@ class APIClient {
         }
       }
       const errorText = await response.text();
-      throw new Error(errorText || `HTTP ${response.status}`);
+      let apiError: ApiErrorBody | null = null;
+      try {
+        apiError = JSON.parse(errorText);
+      } catch {
+        // Not an error envelope
+      }
+      throw new ApiError(
+        response.status,
+        apiError?.code ?? "UNKNOWN",
+        apiError?.message || errorText || `HTTP ${response.status}`,
+        apiError?.details,
+        apiError?.requestId
+      );
     }
     return response.json();
   }
@@ -382,8 +427,13 @@ class APIClient {
     return { status: "OK" };
   }
 
-  async getDashboardStats(): Promise<DashboardStats> {
-    return this.request<DashboardStats>("/dashboard/stats");
+  async getDashboardStats(
+    window = 30,
+    interval: "day" | "week" = "day"
+  ): Promise<DashboardStats> {
+    return this.request<DashboardStats>(
+      `/dashboard/stats?window=${window}&interval=${interval}`
+    );
   }
 
   async getPerformanceMetrics(): Promise<{